@@ -0,0 +1,366 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// defaultAdminAttribute is the certificate attribute that marks a client
+// identity as a registry administrator when no other name is configured
+const defaultAdminAttribute = "did.admin"
+
+// adminAttributeEnv names the environment variable that may be used to
+// override the admin attribute when the chaincode starts
+const adminAttributeEnv = "DID_ADMIN_ATTRIBUTE"
+
+// Roles a client identity must have to call a transaction function
+const (
+	roleMember = "member"
+	roleAdmin  = "admin"
+)
+
+// didContractAccess, credentialContractAccess, adminContractAccess,
+// policyContractAccess, consentContractAccess and sharingContractAccess are the
+// access control matrices of the contracts. They map each transaction function
+// to the role required to call it. Functions that depend on the controller of a
+// did check the controller's signature themselves
+var didContractAccess = map[string]string{
+	"CreateDid":                   roleMember,
+	"CreateDidPrivate":            roleMember,
+	"CreateDidTransient":          roleMember,
+	"CreateDidWithCid":            roleMember,
+	"CreateDidFromCertificate":    roleMember,
+	"BatchCreateDids":             roleMember,
+	"ProcessSidetreeOperation":    roleMember,
+	"AnchorDidBatch":              roleMember,
+	"UpdateDid":                   roleMember,
+	"UpdateDidTransient":          roleMember,
+	"DeactivateDid":               roleMember,
+	"AddVerificationMethod":       roleMember,
+	"RemoveVerificationMethod":    roleMember,
+	"AddRecoveryMethod":           roleMember,
+	"RemoveRecoveryMethod":        roleMember,
+	"RecoverDid":                  roleMember,
+	"SetMultisigPolicy":           roleMember,
+	"RotateKey":                   roleMember,
+	"SetDocumentMetadata":         roleMember,
+	"SetDidCommService":           roleMember,
+	"SetEncryptedServiceEndpoint": roleMember,
+	"RenewDid":                    roleMember,
+	"DeleteDid":                   roleMember,
+	"RestoreDid":                  roleMember,
+	"SetPrivateServiceEndpoint":   roleMember,
+	"SetDidPersonalData":          roleMember,
+	"EraseDidPersonalData":        roleMember,
+	"AddDidEndorser":              roleMember,
+	"RemoveDidEndorser":           roleMember,
+	"SetDidEndorsementPolicy":     roleMember,
+	"GetDidEndorsementPolicy":     roleMember,
+	"SetPrivateDidEndorsement":    roleMember,
+	"DelegateControl":             roleMember,
+	"RevokeDelegation":            roleMember,
+	"UpdateService":               roleMember,
+	"ProposeDidUpdate":            roleMember,
+	"ApproveDidUpdate":            roleMember,
+	"RejectDidUpdate":             roleMember,
+	"ProposeTransfer":             roleMember,
+	"AcceptTransfer":              roleMember,
+	"CreateAuthChallenge":         roleMember,
+	"VerifyAuthResponse":          roleMember,
+	"RegisterIdentityBinding":     roleMember,
+	"SyncIdentityAttributes":      roleMember,
+	"QueryDidByKey":               roleMember,
+	"QueryDidById":                roleMember,
+	"QueryDidHash":                roleMember,
+	"QueryAllDids":                roleMember,
+	"QueryAllDidsWithPagination":  roleMember,
+	"QueryAllDidsKeyedById":       roleMember,
+	"QueryDidsByIdPrefix":         roleMember,
+	"QueryAllDidsSorted":          roleMember,
+	"QueryDidNamespace":           roleMember,
+	"QueryDidsByNamespace":        roleMember,
+	"QueryDidsCreatedBetween":     roleMember,
+	"QueryDidsUpdatedBetween":     roleMember,
+	"SearchDids":                  roleMember,
+	"QueryDidHistory":             roleMember,
+	"DiffDidVersions":             roleMember,
+	"QueryDidPrivate":             roleMember,
+	"QueryDidPersonalData":        roleMember,
+	"QueryDidProvenance":          roleMember,
+	"GetInclusionProof":           roleMember,
+	"GetAnchorBatch":              roleMember,
+	"QueryAuditLog":               roleMember,
+	"QueryAuditLogByOrg":          roleMember,
+	"QueryPrivateServiceEndpoint": roleMember,
+	"VerifyServiceEndpoint":       roleMember,
+	"VerifyDomainLinkage":         roleMember,
+	"QueryTransfer":               roleMember,
+	"QueryDelegations":            roleMember,
+	"QueryDidUpdateProposal":      roleMember,
+	"QueryEndpointSchemes":        roleMember,
+	"QueryRegistrationQuotas":     roleMember,
+	"QueryRegistrationFee":        roleMember,
+	"QueryOffChainThreshold":      roleMember,
+	"QueryDocumentLimits":         roleMember,
+	"QueryDidCaps":                roleMember,
+	"GetDidForIdentity":           roleMember,
+	"QueryCertificateAttributes":  roleMember,
+	"Resolve":                     roleMember,
+	"ResolveVersion":              roleMember,
+	"ResolveRemote":               roleMember,
+	"Dereference":                 roleMember,
+	"ExportAllDids":               roleMember,
+	"GetRegistryStats":            roleMember,
+	"GetContractInfo":             roleMember,
+}
+
+var credentialContractAccess = map[string]string{
+	"AccreditIssuer":               roleMember,
+	"RevokeAccreditation":          roleMember,
+	"QueryAccreditation":           roleMember,
+	"IsAccredited":                 roleMember,
+	"IssueCredential":              roleMember,
+	"QueryCredential":              roleMember,
+	"QueryCredentialsByIssuer":     roleMember,
+	"QueryCredentialsBySubject":    roleMember,
+	"CreateStatusList":             roleMember,
+	"RevokeCredential":             roleMember,
+	"IsRevoked":                    roleMember,
+	"GetStatusList":                roleMember,
+	"VerifyCredential":             roleMember,
+	"VerifyPresentation":           roleMember,
+	"RegisterCredentialDefinition": roleMember,
+	"GetCredentialDefinition":      roleMember,
+	"RegisterRevocationRegistry":   roleMember,
+	"UpdateAccumulator":            roleMember,
+	"GetRevocationRegistry":        roleMember,
+	"GetRevocationState":           roleMember,
+}
+
+var adminContractAccess = map[string]string{
+	"InitLedger":                   roleAdmin,
+	"SetEndpointSchemes":           roleAdmin,
+	"SetRegistrationQuotas":        roleAdmin,
+	"PurgeDeletedDids":             roleAdmin,
+	"SetRegistrationFee":           roleAdmin,
+	"SetOffChainThreshold":         roleAdmin,
+	"MigrateData":                  roleAdmin,
+	"SetDocumentLimits":            roleAdmin,
+	"SetDidCaps":                   roleAdmin,
+	"DeactivateRevokedCertificate": roleAdmin,
+	"SetCertificateAttributes":     roleAdmin,
+}
+
+var policyContractAccess = map[string]string{
+	"SetOperationPolicy":     roleAdmin,
+	"RemoveOperationPolicy":  roleAdmin,
+	"QueryOperationPolicy":   roleMember,
+	"QueryOperationPolicies": roleMember,
+}
+
+var consentContractAccess = map[string]string{
+	"GrantConsent":              roleMember,
+	"RevokeConsent":             roleMember,
+	"QueryConsent":              roleMember,
+	"QueryConsentsBySubject":    roleMember,
+	"QueryConsentsByController": roleMember,
+	"HasConsent":                roleMember,
+}
+
+var sharingContractAccess = map[string]string{
+	"ProposeSharingAgreement":       roleAdmin,
+	"AcceptSharingAgreement":        roleAdmin,
+	"RevokeSharingAgreement":        roleAdmin,
+	"QuerySharingAgreement":         roleMember,
+	"QuerySharingAgreementsByOwner": roleMember,
+}
+
+// auditLog receives an entry for every transaction function called on the contracts
+var auditLog = log.New(os.Stdout, "audit ", 0)
+
+// AuditEntry describes a call of a transaction function by a client identity
+type AuditEntry struct {
+	Contract string `json:"contract"`
+	Function string `json:"function"`
+	Role     string `json:"role"`
+	ProvenanceEntry
+}
+
+// AdminAccess is embedded in contracts that provide administrative functions
+type AdminAccess struct {
+	// AdminAttribute is the certificate attribute that must be set to "true"
+	// for a client to call administrative functions. Defaults to did.admin
+	AdminAttribute string
+}
+
+// adminAttribute returns the name of the certificate attribute that
+// grants access to administrative functions
+func (a *AdminAccess) adminAttribute() string {
+	if a.AdminAttribute == "" {
+		return defaultAdminAttribute
+	}
+
+	return a.AdminAttribute
+}
+
+// assertAdmin returns an error unless the submitting client identity
+// carries the admin attribute with a value of "true"
+func (a *AdminAccess) assertAdmin(ctx contractapi.TransactionContextInterface) error {
+	attribute := a.adminAttribute()
+
+	err := ctx.GetClientIdentity().AssertAttributeValue(attribute, "true")
+
+	if err != nil {
+		return newError(codeUnauthorized, "Caller is not a registry administrator. Attribute %s=true is required. %s", attribute, err.Error())
+	}
+
+	return nil
+}
+
+// adminAttributeFromEnv returns the admin attribute configured through the
+// environment, or an empty string so the default is used
+func adminAttributeFromEnv() string {
+	return os.Getenv(adminAttributeEnv)
+}
+
+// transactionFunction returns the name of the function called by the current
+// transaction as resolved by the contract API, without its contract namespace
+func transactionFunction(ctx contractapi.TransactionContextInterface) string {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	function = function[strings.LastIndex(function, ":")+1:]
+
+	if function == "" {
+		return function
+	}
+
+	runes := []rune(function)
+	runes[0] = unicode.ToUpper(runes[0])
+
+	return string(runes)
+}
+
+// beforeTransaction enforces the access control matrix of contract for the
+// called function, requires the ledger to be initialized for submit functions
+// other than InitLedger and records an audit entry of the call. Functions missing
+// from the matrix are rejected, calls of functions the contract does not have
+// are left to its unknown transaction handler
+func beforeTransaction(ctx contractapi.TransactionContextInterface, contract contractapi.ContractInterface, access *AdminAccess, matrix map[string]string) error {
+	function := transactionFunction(ctx)
+
+	if _, ok := reflect.TypeOf(contract).MethodByName(function); !ok {
+		return nil
+	}
+
+	role, ok := matrix[function]
+
+	if !ok {
+		return newError(codeUnauthorized, "%s is not in the access control matrix of %s", function, contract.GetName())
+	}
+
+	entry, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if role == roleAdmin {
+		if err := access.assertAdmin(ctx); err != nil {
+			return err
+		}
+	}
+
+	if function != initLedgerFunction && !isEvaluateTransaction(contract, function) {
+		if err := assertInitialized(ctx); err != nil {
+			return err
+		}
+	}
+
+	entryAsBytes, err := json.Marshal(AuditEntry{Contract: contract.GetName(), Function: function, Role: role, ProvenanceEntry: *entry})
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode audit entry. %s", err.Error())
+	}
+
+	auditLog.Println(string(entryAsBytes))
+
+	return nil
+}
+
+// GetBeforeTransaction returns the handler called before every transaction
+// function of the did contract, which also applies the operation policy of the
+// function
+func (s *DidContract) GetBeforeTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		if err := beforeTransaction(ctx, s, &s.AdminAccess, didContractAccess); err != nil {
+			return err
+		}
+
+		return enforceOperationPolicy(ctx, s)
+	}
+}
+
+// GetBeforeTransaction returns the handler called before every transaction
+// function of the credential contract
+func (c *CredentialContract) GetBeforeTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return beforeTransaction(ctx, c, &c.AdminAccess, credentialContractAccess)
+	}
+}
+
+// GetBeforeTransaction returns the handler called before every transaction
+// function of the admin contract
+func (a *AdminContract) GetBeforeTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return beforeTransaction(ctx, a, &a.AdminAccess, adminContractAccess)
+	}
+}
+
+// GetBeforeTransaction returns the handler called before every transaction
+// function of the policy contract
+func (p *PolicyContract) GetBeforeTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return beforeTransaction(ctx, p, &p.AdminAccess, policyContractAccess)
+	}
+}
+
+// GetBeforeTransaction returns the handler called before every transaction
+// function of the consent contract
+func (c *ConsentContract) GetBeforeTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return beforeTransaction(ctx, c, &c.AdminAccess, consentContractAccess)
+	}
+}
+
+// GetBeforeTransaction returns the handler called before every transaction
+// function of the sharing contract
+func (c *SharingContract) GetBeforeTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return beforeTransaction(ctx, c, &c.AdminAccess, sharingContractAccess)
+	}
+}
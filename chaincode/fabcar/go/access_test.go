@@ -0,0 +1,158 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// before runs the before transaction handler of contract for a call of function
+func (l *testLedger) before(contract contractapi.ContractInterface, function string) error {
+	l.stub.GetFunctionAndParametersReturns(function, []string{})
+
+	return contract.GetBeforeTransaction().(func(ctx contractapi.TransactionContextInterface) error)(l.ctx)
+}
+
+// captureAuditLog redirects the audit log to a buffer. Callers restore it with
+// restoreAuditLog
+func captureAuditLog() *bytes.Buffer {
+	buffer := new(bytes.Buffer)
+	auditLog.SetOutput(buffer)
+
+	return buffer
+}
+
+// restoreAuditLog writes the audit log to stdout again
+func restoreAuditLog() {
+	auditLog.SetOutput(os.Stdout)
+}
+
+func TestAssertAdmin(t *testing.T) {
+	l := newTestLedger(t)
+	access := AdminAccess{}
+
+	assertErrorCode(t, access.assertAdmin(l.ctx), codeUnauthorized, "should reject clients without the admin attribute")
+
+	l.setAdmin(true)
+	assert.Nil(t, access.assertAdmin(l.ctx), "should accept clients with did.admin=true")
+
+	access.AdminAttribute = "registry.admin"
+	assertErrorCode(t, access.assertAdmin(l.ctx), codeUnauthorized, "should check the configured attribute")
+
+	l.attributes["registry.admin"] = "true"
+	assert.Nil(t, access.assertAdmin(l.ctx), "should accept clients with the configured attribute")
+
+	name, value := l.identity.AssertAttributeValueArgsForCall(l.identity.AssertAttributeValueCallCount() - 1)
+	assert.Equal(t, []string{"registry.admin", "true"}, []string{name, value}, "should require the attribute to be true")
+}
+
+func TestAdminAttributeFromEnv(t *testing.T) {
+	os.Setenv(adminAttributeEnv, "registry.admin")
+	defer os.Unsetenv(adminAttributeEnv)
+
+	assert.Equal(t, "registry.admin", adminAttributeFromEnv(), "should read the attribute from the environment")
+}
+
+func TestBeforeTransaction(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	audit := captureAuditLog()
+	defer restoreAuditLog()
+
+	err := l.before(s, "did:queryDidByKey")
+	require.NoError(t, err, "should let members call queries")
+
+	entry := AuditEntry{}
+	require.NoError(t, json.Unmarshal(bytes.TrimPrefix(audit.Bytes(), []byte("audit ")), &entry), "should log the entry as JSON")
+	assert.Equal(t, didContractName, entry.Contract, "should log the contract")
+	assert.Equal(t, "QueryDidByKey", entry.Function, "should log the function without its namespace")
+	assert.Equal(t, roleMember, entry.Role, "should log the required role")
+	assert.Equal(t, testClientID, entry.ClientID, "should log the client identity")
+	assert.Equal(t, testMSPID, entry.MSPID, "should log the client MSP")
+	assert.Equal(t, l.stub.GetTxID(), entry.TxID, "should log the transaction")
+
+	audit.Reset()
+
+	err = l.before(new(AdminContract), "admin:SetEndpointSchemes")
+	assertErrorCode(t, err, codeUnauthorized, "should require the role of the matrix")
+	assert.Zero(t, audit.Len(), "should not log rejected calls")
+
+	err = l.before(s, "UnknownFunction")
+	assert.Nil(t, err, "should leave unknown functions to the contract API")
+
+	l.stub.GetFunctionAndParametersReturns("QueryDidByKey", []string{})
+
+	err = beforeTransaction(l.ctx, s, &s.AdminAccess, map[string]string{})
+	assertErrorCode(t, err, codeUnauthorized, "should reject functions missing from the matrix")
+
+	l.identity.GetMSPIDReturns("", errors.New("GetMSPID error"))
+
+	err = l.before(s, "QueryDidByKey")
+	assert.EqualError(t, err, "Failed to read client MSP ID. GetMSPID error", "should require the client identity")
+}
+
+func TestAccessMatrix(t *testing.T) {
+	ccm := chaincodeMetadata(t)
+
+	matrices := map[string]map[string]string{
+		didContractName:        didContractAccess,
+		credentialContractName: credentialContractAccess,
+		adminContractName:      adminContractAccess,
+		policyContractName:     policyContractAccess,
+		consentContractName:    consentContractAccess,
+		sharingContractName:    sharingContractAccess,
+	}
+
+	for contract, matrix := range matrices {
+		tags := transactionTags(t, ccm, contract)
+
+		for function := range tags {
+			assert.Contains(t, matrix, function, "should define the role of %s in %s", function, contract)
+		}
+
+		for function := range matrix {
+			assert.Contains(t, tags, function, "should only define roles of transaction functions of %s", contract)
+		}
+	}
+
+	for function, role := range adminContractAccess {
+		assert.Equal(t, roleAdmin, role, "should reserve %s to administrators", function)
+	}
+
+	stub := newCodedStub(t)
+	captureAuditLog()
+	defer restoreAuditLog()
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("admin:InitLedger")})
+	assert.Equal(t, int32(shim.ERROR), response.Status, "should run the handler for every invoke")
+	assert.Equal(t, codeUnauthorized, responseError(t, response.Message).Code, "should reject callers without the role")
+	assert.Contains(t, responseError(t, response.Message).Message, defaultAdminAttribute, "should name the missing attribute")
+
+	response = stub.MockInvoke("tx2", [][]byte{[]byte("InitLedger")})
+	assert.Equal(t, codeUnknownFunction, responseError(t, response.Message).Code, "should not offer admin functions in the default contract")
+}
@@ -0,0 +1,254 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// accreditationObjectType is the composite key object type under which issuer
+// accreditations are stored
+const accreditationObjectType = "accreditation"
+
+// maxAccreditationLevel limits how far accreditation may be delegated. Level 1
+// issuers are accredited by a registry administrator, level n issuers by an
+// issuer of level n-1
+const maxAccreditationLevel = 3
+
+// Accreditation describes the right of an issuer did to issue credentials of a
+// credential type
+type Accreditation struct {
+	IssuerDid      string           `json:"issuerDid"`
+	CredentialType string           `json:"credentialType"`
+	Level          int              `json:"level"`
+	AccreditedBy   string           `json:"accreditedBy,omitempty" metadata:"accreditedBy,optional"`
+	RecordedBy     *ProvenanceEntry `json:"recordedBy"`
+}
+
+// accreditationKey returns the key of the accreditation of an issuer for a credential type
+func accreditationKey(ctx contractapi.TransactionContextInterface, issuerDid string, credentialType string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(accreditationObjectType, []string{issuerDid, credentialType})
+}
+
+// getAccreditation reads the accreditation of an issuer for a credential type,
+// returning nil if there is none
+func getAccreditation(ctx contractapi.TransactionContextInterface, issuerDid string, credentialType string) (*Accreditation, error) {
+	key, err := accreditationKey(ctx, issuerDid, credentialType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accreditationAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if accreditationAsBytes == nil {
+		return nil, nil
+	}
+
+	accreditation := new(Accreditation)
+
+	if err := unmarshalRecord(key, accreditationAsBytes, accreditation); err != nil {
+		return nil, err
+	}
+
+	return accreditation, nil
+}
+
+// AccreditIssuer accredits an issuer did to issue credentials of a credential type.
+// With an empty accreditorDid the caller must be a registry administrator,
+// otherwise the caller must control accreditorDid, another did whose whole
+// accreditation chain for the credential type holds. An existing accreditation
+// must be revoked before the issuer is accredited again
+func (c *CredentialContract) AccreditIssuer(ctx contractapi.TransactionContextInterface, issuerDid string, credentialType string, accreditorDid string) error {
+	if _, err := findDidById(ctx, issuerDid); err != nil {
+		return err
+	}
+
+	if accreditorDid == issuerDid {
+		return newError(codeInvalidArgument, "%s cannot accredit itself", issuerDid)
+	}
+
+	level := 1
+
+	if accreditorDid == "" {
+		if err := c.assertAdmin(ctx); err != nil {
+			return err
+		}
+	} else {
+		if err := assertIssuerController(ctx, accreditorDid); err != nil {
+			return err
+		}
+
+		accreditor, err := getAccreditation(ctx, accreditorDid, credentialType)
+
+		if err != nil {
+			return err
+		}
+
+		accredited, err := isAccredited(ctx, accreditorDid, credentialType)
+
+		if err != nil {
+			return err
+		}
+
+		if accreditor == nil || !accredited {
+			return newError(codeUnauthorized, "%s is not accredited for %s", accreditorDid, credentialType)
+		}
+
+		level = accreditor.Level + 1
+	}
+
+	if level > maxAccreditationLevel {
+		return newError(codeUnauthorized, "%s cannot accredit further issuers for %s", accreditorDid, credentialType)
+	}
+
+	existing, err := getAccreditation(ctx, issuerDid, credentialType)
+
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		return newError(codeAlreadyExists, "%s is already accredited for %s, revoke the accreditation first", issuerDid, credentialType)
+	}
+
+	recordedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	accreditation := Accreditation{
+		IssuerDid:      issuerDid,
+		CredentialType: credentialType,
+		Level:          level,
+		AccreditedBy:   accreditorDid,
+		RecordedBy:     recordedBy,
+	}
+
+	key, err := accreditationKey(ctx, issuerDid, credentialType)
+
+	if err != nil {
+		return err
+	}
+
+	accreditationAsBytes, err := marshalRecord(key, accreditation)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, accreditationAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// RevokeAccreditation removes the accreditation of an issuer for a credential type.
+// Registry administrators may revoke any accreditation, otherwise the caller must
+// control the did that granted it
+func (c *CredentialContract) RevokeAccreditation(ctx contractapi.TransactionContextInterface, issuerDid string, credentialType string) error {
+	accreditation, err := getAccreditation(ctx, issuerDid, credentialType)
+
+	if err != nil {
+		return err
+	}
+
+	if accreditation == nil {
+		return newError(codeUnauthorized, "%s is not accredited for %s", issuerDid, credentialType)
+	}
+
+	if err := c.assertAdmin(ctx); err != nil {
+		if accreditation.AccreditedBy == "" {
+			return err
+		}
+
+		if err := assertIssuerController(ctx, accreditation.AccreditedBy); err != nil {
+			return err
+		}
+	}
+
+	key, err := accreditationKey(ctx, issuerDid, credentialType)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryAccreditation returns the accreditation of an issuer for a credential type
+func (c *CredentialContract) QueryAccreditation(ctx contractapi.TransactionContextInterface, issuerDid string, credentialType string) (*Accreditation, error) {
+	accreditation, err := getAccreditation(ctx, issuerDid, credentialType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if accreditation == nil {
+		return nil, newError(codeUnauthorized, "%s is not accredited for %s", issuerDid, credentialType)
+	}
+
+	return accreditation, nil
+}
+
+// IsAccredited reports whether an issuer is accredited for a credential type. An
+// accreditation only holds while every accreditation above it in the chain holds
+func (c *CredentialContract) IsAccredited(ctx contractapi.TransactionContextInterface, issuerDid string, credentialType string) (bool, error) {
+	return isAccredited(ctx, issuerDid, credentialType)
+}
+
+// isAccredited walks the accreditation chain of an issuer up to a registry administrator
+func isAccredited(ctx contractapi.TransactionContextInterface, issuerDid string, credentialType string) (bool, error) {
+	for level := 0; level < maxAccreditationLevel; level++ {
+		accreditation, err := getAccreditation(ctx, issuerDid, credentialType)
+
+		if err != nil {
+			return false, err
+		}
+
+		if accreditation == nil {
+			return false, nil
+		}
+
+		if accreditation.AccreditedBy == "" {
+			return true, nil
+		}
+
+		issuerDid = accreditation.AccreditedBy
+	}
+
+	return false, nil
+}
@@ -0,0 +1,162 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCredentialType = "UniversityDegreeCredential"
+
+func TestAccreditIssuer(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuers := []string{}
+
+	for i := 0; i < maxAccreditationLevel+1; i++ {
+		issuers = append(issuers, createTestDid(t, l, newTestKey(t)))
+	}
+
+	err := c.AccreditIssuer(l.ctx, issuers[0], testCredentialType, "")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators accredit top level issuers")
+
+	l.setAdmin(true)
+	err = c.AccreditIssuer(l.ctx, issuers[0], testCredentialType, "")
+	require.NoError(t, err, "should accredit the issuer")
+	l.setAdmin(false)
+
+	accreditation, err := c.QueryAccreditation(l.ctx, issuers[0], testCredentialType)
+	require.NoError(t, err)
+	assert.Equal(t, 1, accreditation.Level, "should accredit top level issuers at level 1")
+	assert.Equal(t, testClientID, accreditation.RecordedBy.ClientID, "should record the accrediting client")
+
+	for level := 1; level < maxAccreditationLevel; level++ {
+		err = c.AccreditIssuer(l.ctx, issuers[level], testCredentialType, issuers[level-1])
+		require.NoError(t, err, "should let accredited issuers accredit further issuers")
+
+		accreditation, err = c.QueryAccreditation(l.ctx, issuers[level], testCredentialType)
+		require.NoError(t, err)
+		assert.Equal(t, level+1, accreditation.Level, "should accredit delegated issuers one level below their accreditor")
+		assert.Equal(t, issuers[level-1], accreditation.AccreditedBy, "should record the accreditor")
+	}
+
+	err = c.AccreditIssuer(l.ctx, issuers[maxAccreditationLevel], testCredentialType, issuers[maxAccreditationLevel-1])
+	assertErrorCode(t, err, codeUnauthorized, "should limit the accreditation depth")
+
+	err = c.AccreditIssuer(l.ctx, issuers[0], "OtherCredential", issuers[1])
+	assertErrorCode(t, err, codeUnauthorized, "should require the accreditor to be accredited for the type")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = c.AccreditIssuer(l.ctx, issuers[3], testCredentialType, issuers[0])
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the accreditor")
+
+	err = c.AccreditIssuer(l.ctx, "did:example:unknown", testCredentialType, "")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown issuers")
+
+	l.setClient(testClientID, testMSPID)
+	err = c.AccreditIssuer(l.ctx, issuers[1], testCredentialType, issuers[1])
+	assertErrorCode(t, err, codeInvalidArgument, "should not let an issuer accredit itself")
+
+	err = c.AccreditIssuer(l.ctx, issuers[2], testCredentialType, issuers[0])
+	assertErrorCode(t, err, codeAlreadyExists, "should not replace an existing accreditation")
+
+	accreditation, err = c.QueryAccreditation(l.ctx, issuers[2], testCredentialType)
+	require.NoError(t, err)
+	assert.Equal(t, 3, accreditation.Level, "should keep the existing accreditation")
+
+	l.stub.PutStateReturns(errors.New("PutState error"))
+	err = c.AccreditIssuer(l.ctx, issuers[3], testCredentialType, issuers[0])
+	assert.EqualError(t, err, "Failed to put to world state. PutState error", "should return ledger errors")
+}
+
+func TestRevokeAccreditation(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	root := createTestDid(t, l, newTestKey(t))
+	delegate := createTestDid(t, l, newTestKey(t))
+
+	l.setAdmin(true)
+	require.NoError(t, c.AccreditIssuer(l.ctx, root, testCredentialType, ""))
+	l.setAdmin(false)
+	require.NoError(t, c.AccreditIssuer(l.ctx, delegate, testCredentialType, root))
+
+	err := c.RevokeAccreditation(l.ctx, root, testCredentialType)
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators revoke top level accreditations")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = c.RevokeAccreditation(l.ctx, delegate, testCredentialType)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the accreditor revoke delegated accreditations")
+
+	l.setClient(testClientID, testMSPID)
+	err = c.RevokeAccreditation(l.ctx, delegate, testCredentialType)
+	require.NoError(t, err, "should let the accreditor revoke")
+
+	_, err = c.QueryAccreditation(l.ctx, delegate, testCredentialType)
+	assertErrorCode(t, err, codeUnauthorized, "should remove the accreditation")
+
+	err = c.RevokeAccreditation(l.ctx, delegate, testCredentialType)
+	assertErrorCode(t, err, codeUnauthorized, "should fail for issuers that are not accredited")
+
+	l.setAdmin(true)
+	err = c.RevokeAccreditation(l.ctx, root, testCredentialType)
+	assert.Nil(t, err, "should let administrators revoke any accreditation")
+}
+
+func TestIsAccredited(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	root := createTestDid(t, l, newTestKey(t))
+	delegate := createTestDid(t, l, newTestKey(t))
+
+	accredited, err := c.IsAccredited(l.ctx, root, testCredentialType)
+	assert.Nil(t, err, "should not fail for issuers without accreditation")
+	assert.False(t, accredited, "should not accredit unknown issuers")
+
+	l.setAdmin(true)
+	require.NoError(t, c.AccreditIssuer(l.ctx, root, testCredentialType, ""))
+	require.NoError(t, c.AccreditIssuer(l.ctx, delegate, testCredentialType, root))
+
+	accredited, err = c.IsAccredited(l.ctx, delegate, testCredentialType)
+	assert.Nil(t, err, "should walk the accreditation chain")
+	assert.True(t, accredited, "should accredit issuers with an unbroken chain")
+
+	accredited, err = c.IsAccredited(l.ctx, delegate, "OtherCredential")
+	assert.Nil(t, err, "should not fail for other types")
+	assert.False(t, accredited, "should only accredit the accredited type")
+
+	require.NoError(t, c.RevokeAccreditation(l.ctx, root, testCredentialType))
+
+	accredited, err = c.IsAccredited(l.ctx, delegate, testCredentialType)
+	assert.Nil(t, err, "should walk the accreditation chain")
+	assert.False(t, accredited, "should not accredit issuers whose accreditor lost accreditation")
+
+	l.setAdmin(false)
+	other := createTestDid(t, l, newTestKey(t))
+	err = c.AccreditIssuer(l.ctx, other, testCredentialType, delegate)
+	assertErrorCode(t, err, codeUnauthorized, "should not let issuers whose chain is broken accredit others")
+	l.setAdmin(true)
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = c.IsAccredited(l.ctx, delegate, testCredentialType)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
@@ -0,0 +1,260 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AdminContract provides the functions reserved to registry administrators
+type AdminContract struct {
+	contractapi.Contract
+	AdminAccess
+}
+
+// initializedConfig names the configuration entry recording who initialized the
+// ledger with InitLedger
+const initializedConfig = "initialized"
+
+// initLedgerFunction is the only submit transaction that may be called before
+// the ledger is initialized
+const initLedgerFunction = "InitLedger"
+
+func initializedKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configObjectType, []string{initializedConfig})
+}
+
+// getInitializedMarker returns the client identity and transaction that ran
+// InitLedger, or nil if the ledger is not initialized
+func getInitializedMarker(ctx contractapi.TransactionContextInterface) (*ProvenanceEntry, error) {
+	key, err := initializedKey(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	markerAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if markerAsBytes == nil {
+		return nil, nil
+	}
+
+	marker := new(ProvenanceEntry)
+
+	if err := unmarshalRecord(key, markerAsBytes, marker); err != nil {
+		return nil, err
+	}
+
+	return marker, nil
+}
+
+// isInitialized reports whether InitLedger has run on the ledger
+func isInitialized(ctx contractapi.TransactionContextInterface) (bool, error) {
+	marker, err := getInitializedMarker(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	return marker != nil, nil
+}
+
+// assertInitialized returns an error unless InitLedger has run on the ledger
+func assertInitialized(ctx contractapi.TransactionContextInterface) error {
+	initialized, err := isInitialized(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !initialized {
+		return newError(codeNotInitialized, "Ledger is not initialized. A registry administrator must call %s first", initLedgerFunction)
+	}
+
+	return nil
+}
+
+// seedPublicKeys are the RSA authentication keys of the dids added by InitLedger
+var seedPublicKeys = []string{
+	"-----BEGIN PUBLIC KEY-----\n" +
+		"MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAltDJsX+IgtHH6g7tN5bC\n" +
+		"FU7EMhu/Dv1SLM2bpLJ0PHGinPDbAR3RIkTJArulULs7KVMUhruPsyMrs2d9CCgY\n" +
+		"wxU4+nVaLv9h+P4rXO1GtJ+oUGKrNI7F72w5/nARJLdUXAHTVgFp8ifrYi9xxeVm\n" +
+		"oQWupXLu1W4j0yFiXDVEnJP4975CD5vhJm6sgLtl2rWZ9u4+MQ/zSl0IgLz7HOlB\n" +
+		"iDe1WWwfepToiWHbhMeGRFXraXGXKJVg9Ykv7IkhmZ3abjfW+ls37XEfvTj8fax1\n" +
+		"oYHBZTYG7OQC6bg8btcyAmQVsOAekAm9ZKJFkm0wqhmA3hqaFDNThDleH2yNrYlN\n" +
+		"6wIDAQAB\n" +
+		"-----END PUBLIC KEY-----\n",
+
+	"-----BEGIN PUBLIC KEY-----\n" +
+		"MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEAuJ1W/vbN3kjh9JUH7i6L\n" +
+		"T08zsk9Ubgoo66Zyg2WH+J40BdlW+zyEZwYfRZ5XuF8YXs/7BspGoHlaFJfKf0Pp\n" +
+		"z3QZovwIVojG36JXrQoTDiMvlr1APAzc40pMZk9JWCQmx8EH5PZHUDnB1YUDL2z9\n" +
+		"GwtgASg/BMZc6cBTbWe4swspP26+8Cl9X4Ts6LjsjfT5QYb1/ry6RVnIGJ5+TZie\n" +
+		"G1ickPCMK0LtKomlzLpqHT/oh5FQ/Rnlb7JGXa1WUyTgfUEVSlcUNU+W/y2W1YNt\n" +
+		"9GIKJtbbyMFGzSHus3/xitAnlRN+xqIQfAlzxzeUeigxFmM7pXQKqVZp8YG9aogN\n" +
+		"DQIDAQAB\n" +
+		"-----END PUBLIC KEY-----\n",
+}
+
+// sampleSeedDids are the dids added by InitLedger when no seed is given
+var sampleSeedDids = []Did{
+	Did{Id: "did:example:12346789abcdefghi", AuthenticationId: "did:example:12346789abcdefghi#keys-1",
+		AuthenticationType: "RsaVerificationKey2018", AuthenticationController: "did:example:12346789abcdefghi",
+		AuthenticationPublicKeyPerm: seedPublicKeys[0],
+		ServiceId:                   "did:example:12346789abcdefghi#vcs", ServiceType: "VerifiableCredentialService",
+		ServiceEndPoint: "https://example.com/vc/"},
+
+	Did{Id: "did:example:12346789asdfghjkl", AuthenticationId: "did:example:12346789asdfghjkl#keys-1",
+		AuthenticationType: "RsaVerificationKey2018", AuthenticationController: "did:example:12346789asdfghjkl",
+		AuthenticationPublicKeyPerm: seedPublicKeys[1],
+		ServiceId:                   "did:example:12346789aasdfghjkl#vcs", ServiceType: "VerifiableCredentialService",
+		ServiceEndPoint: "https://example2.com/vc/"},
+}
+
+// seedDids returns the dids given as a JSON array in seedJSON, or a copy of the
+// sample dids when seedJSON is empty. Given dids are checked as CreateDid checks
+// new dids
+func seedDids(ctx contractapi.TransactionContextInterface, seedJSON string) ([]Did, error) {
+	if seedJSON == "" {
+		return append([]Did{}, sampleSeedDids...), nil
+	}
+
+	dids := []Did{}
+
+	if err := decodeStrict([]byte(seedJSON), &dids); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode seed dids. %s", err.Error())
+	}
+
+	for i := range dids {
+		did := &dids[i]
+
+		if did.Controller != "" || did.Provenance != nil {
+			return nil, newError(codeInvalidArgument, "Seed did %d must not set its controller or provenance", i)
+		}
+
+		err := validateDidSyntax(did)
+
+		if err == nil {
+			err = validateService(ctx, did)
+		}
+
+		if err == nil {
+			err = normalizePublicKey(did)
+		}
+
+		if err == nil {
+			err = validateDidMetadata(ctx, did.Metadata)
+		}
+
+		if err == nil {
+			err = validateExpires(ctx, did.Expires)
+		}
+
+		normalizeDidMetadata(did)
+
+		if err != nil {
+			return nil, newError(codeInvalidArgument, "Seed did %d is invalid. %s", i, errorMessage(err))
+		}
+	}
+
+	return dids, nil
+}
+
+// InitLedger initializes the ledger with the dids given as a JSON array in
+// seedJSON, stored as DID0, DID1 and so on in the namespace of the calling
+// administrator's organization. Seeds are created as by CreateDid, controlled by
+// the calling administrator, and reported in a single dids.created event. An
+// empty seedJSON adds the sample dids, "[]" adds none. It is meant to be called
+// once as the init transaction of the chaincode, as admin:InitLedger, and only
+// registry administrators may call it
+func (a *AdminContract) InitLedger(ctx contractapi.TransactionContextInterface, seedJSON string) error {
+	initialized, err := isInitialized(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if initialized {
+		return newError(codeAlreadyExists, "Ledger is already initialized")
+	}
+
+	dids, err := seedDids(ctx, seedJSON)
+
+	if err != nil {
+		return err
+	}
+
+	events := []DidEvent{}
+
+	for i := range dids {
+		didNumber := "DID" + strconv.Itoa(i)
+
+		if err := new(DidContract).createDid(ctx, didNumber, &dids[i]); err != nil {
+			return err
+		}
+
+		events = append(events, DidEvent{DidNumber: didNumber, Did: &dids[i]})
+	}
+
+	if len(events) > 0 {
+		if err := emitDidsEvent(ctx, didsCreatedEvent, events); err != nil {
+			return err
+		}
+	}
+
+	return putInitializedMarker(ctx)
+}
+
+// putInitializedMarker records the identity and transaction that initialized the ledger
+func putInitializedMarker(ctx contractapi.TransactionContextInterface) error {
+	entry, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	key, err := initializedKey(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	markerAsBytes, err := marshalRecord(key, entry)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, markerAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
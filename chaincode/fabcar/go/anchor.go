@@ -0,0 +1,419 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// anchorBatchObjectType and inclusionProofObjectType are the composite key
+// object types under which anchored batches and the inclusion proofs of their
+// dids are stored
+const (
+	anchorBatchObjectType    = "anchorbatch"
+	inclusionProofObjectType = "inclusionproof"
+)
+
+// Merkle tree hash prefixes of leaves and interior nodes, as in RFC 6962, so a
+// leaf can never be passed off as an interior node
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// Positions of the sibling hash of a proof step relative to the running hash
+const (
+	merkleLeft  = "left"
+	merkleRight = "right"
+)
+
+// AnchorBatch describes a batch of anchored dids by the root of the Merkle tree
+// over their documents. The batch is identified by the anchoring transaction
+type AnchorBatch struct {
+	BatchId    string           `json:"batchId"`
+	Root       string           `json:"root"`
+	Size       int              `json:"size"`
+	AnchoredBy *ProvenanceEntry `json:"anchoredBy"`
+}
+
+// AnchorBatchResult describes an anchored batch and the documents of its dids in
+// the order given. The documents are not stored, their holders present them
+// together with the inclusion proof of the did
+type AnchorBatchResult struct {
+	Batch *AnchorBatch `json:"batch"`
+	Dids  []*Did       `json:"dids"`
+}
+
+// MerkleProofStep is a sibling hash on the path from a leaf to the root
+type MerkleProofStep struct {
+	Position string `json:"position"`
+	Hash     string `json:"hash"`
+}
+
+// InclusionProof proves that the document of an anchored did is a leaf of the
+// Merkle tree of its batch. LeafHash is the hash of the canonical JSON of the
+// document prefixed with 0x00, hashing it with the steps of the path in order, each
+// prefixed with 0x01, yields the root
+type InclusionProof struct {
+	DidId     string            `json:"didId"`
+	BatchId   string            `json:"batchId"`
+	Root      string            `json:"root"`
+	LeafIndex int               `json:"leafIndex"`
+	LeafHash  string            `json:"leafHash"`
+	Path      []MerkleProofStep `json:"path"`
+}
+
+// merkleLeaf returns the hash of a leaf with given data
+func merkleLeaf(data []byte) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, data...))
+
+	return hash[:]
+}
+
+// merkleNode returns the hash of the interior node with given children
+func merkleNode(left []byte, right []byte) []byte {
+	data := append([]byte{merkleNodePrefix}, left...)
+	hash := sha256.Sum256(append(data, right...))
+
+	return hash[:]
+}
+
+// merkleTree returns the root of the Merkle tree over leaves and the path of
+// every leaf to it. The last node of a level without a sibling moves up unchanged
+func merkleTree(leaves [][]byte) ([]byte, [][]MerkleProofStep) {
+	paths := make([][]MerkleProofStep, len(leaves))
+	positions := make([]int, len(leaves))
+
+	for i := range leaves {
+		positions[i] = i
+	}
+
+	level := leaves
+
+	for len(level) > 1 {
+		next := [][]byte{}
+
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+
+			next = append(next, merkleNode(level[i], level[i+1]))
+		}
+
+		for leaf, position := range positions {
+			if position%2 == 0 && position+1 < len(level) {
+				paths[leaf] = append(paths[leaf], MerkleProofStep{Position: merkleRight, Hash: hex.EncodeToString(level[position+1])})
+			} else if position%2 == 1 {
+				paths[leaf] = append(paths[leaf], MerkleProofStep{Position: merkleLeft, Hash: hex.EncodeToString(level[position-1])})
+			}
+
+			positions[leaf] = position / 2
+		}
+
+		level = next
+	}
+
+	return level[0], paths
+}
+
+// anchorBatchKey returns the key of the anchored batch with given id
+func anchorBatchKey(ctx contractapi.TransactionContextInterface, batchId string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(anchorBatchObjectType, []string{batchId})
+}
+
+// inclusionProofKey returns the key of the inclusion proof of the anchored did with given id
+func inclusionProofKey(ctx contractapi.TransactionContextInterface, didId string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(inclusionProofObjectType, []string{didId})
+}
+
+// isAnchored reports whether the did with given id is anchored in a batch
+func isAnchored(ctx contractapi.TransactionContextInterface, didId string) (bool, error) {
+	key, err := inclusionProofKey(ctx, didId)
+
+	if err != nil {
+		return false, err
+	}
+
+	proofAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	return proofAsBytes != nil, nil
+}
+
+// anchorDid validates the did of a single batch item as CreateDid would and
+// returns its document, controlled by controller. Writes of the transaction are
+// not visible to its own reads, so anchored holds the identifiers already taken
+// by earlier items
+func anchorDid(ctx contractapi.TransactionContextInterface, itemJSON json.RawMessage, controller string, anchored map[string]int) (*Did, error) {
+	details, err := decodeDidDetails(itemJSON)
+
+	if err != nil {
+		return nil, err
+	}
+
+	did := Did{
+		AuthenticationId:            details.AuthenticationId,
+		AuthenticationType:          details.AuthenticationType,
+		AuthenticationController:    details.AuthenticationController,
+		AuthenticationPublicKeyPerm: details.AuthenticationPublicKeyPerm,
+		ServiceId:                   details.ServiceId,
+		ServiceType:                 details.ServiceType,
+		ServiceEndPoint:             details.ServiceEndPoint,
+		Expires:                     details.Expires,
+	}
+
+	if err := assignIdentifier(ctx, &did); err != nil {
+		return nil, err
+	}
+
+	if previous, ok := anchored[did.Id]; ok {
+		return nil, newError(codeDidAlreadyExists, "%s already exists as item %d", did.Id, previous)
+	}
+
+	key, err := didKey(ctx, did.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if existing != nil {
+		return nil, newError(codeDidAlreadyExists, "%s already exists", did.Id)
+	}
+
+	exists, err := isAnchored(ctx, did.Id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, newError(codeDidAlreadyExists, "%s is already anchored", did.Id)
+	}
+
+	if err := validateDidSyntax(&did); err != nil {
+		return nil, err
+	}
+
+	if err := validateService(ctx, &did); err != nil {
+		return nil, err
+	}
+
+	if err := validateExpires(ctx, did.Expires); err != nil {
+		return nil, err
+	}
+
+	if err := normalizePublicKey(&did); err != nil {
+		return nil, err
+	}
+
+	did.Controller = controller
+
+	return &did, nil
+}
+
+// AnchorDidBatch anchors every did of a JSON array of DidDetails in a single
+// transaction. Unlike BatchCreateDids, the documents are not stored: the ledger
+// only records the root of the Merkle tree over their canonical JSON and an
+// inclusion proof per did, which GetInclusionProof returns. Anchored dids are
+// controlled by the submitting client but cannot be resolved, updated or
+// deactivated through the registry. Items are validated as by BatchCreateDids and
+// count against the registration quota and fee the same way
+func (s *DidContract) AnchorDidBatch(ctx contractapi.TransactionContextInterface, didsJSON string) (*AnchorBatchResult, error) {
+	items := []json.RawMessage{}
+
+	if err := json.Unmarshal([]byte(didsJSON), &items); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode dids. %s", err.Error())
+	}
+
+	if len(items) == 0 {
+		return nil, newError(codeInvalidArgument, "At least one did must be given")
+	}
+
+	if err := admitRegistration(ctx, len(items)); err != nil {
+		return nil, err
+	}
+
+	anchoredBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dids := []*Did{}
+	leaves := [][]byte{}
+	failures := map[string]string{}
+	anchored := map[string]int{}
+
+	for i, itemJSON := range items {
+		did, err := anchorDid(ctx, itemJSON, anchoredBy.ClientID, anchored)
+
+		if err != nil {
+			failures[strconv.Itoa(i)] = errorMessage(err)
+			continue
+		}
+
+		documentAsBytes, err := canonicalJSON(did)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode %s. %s", did.Id, err.Error())
+		}
+
+		anchored[did.Id] = i
+		dids = append(dids, did)
+		leaves = append(leaves, merkleLeaf(documentAsBytes))
+	}
+
+	if len(failures) > 0 {
+		batchErr := newError(codeBatchRejected, "Failed to anchor %d of %d dids", len(failures), len(items))
+		batchErr.Details = failures
+
+		return nil, batchErr
+	}
+
+	root, paths := merkleTree(leaves)
+
+	batch := AnchorBatch{
+		BatchId:    anchoredBy.TxID,
+		Root:       hex.EncodeToString(root),
+		Size:       len(dids),
+		AnchoredBy: anchoredBy,
+	}
+
+	key, err := anchorBatchKey(ctx, batch.BatchId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	batchAsBytes, err := marshalRecord(key, batch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.GetStub().PutState(key, batchAsBytes); err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	for i, did := range dids {
+		proof := InclusionProof{
+			DidId:     did.Id,
+			BatchId:   batch.BatchId,
+			Root:      batch.Root,
+			LeafIndex: i,
+			LeafHash:  hex.EncodeToString(leaves[i]),
+			Path:      paths[i],
+		}
+
+		if proof.Path == nil {
+			proof.Path = []MerkleProofStep{}
+		}
+
+		proofKey, err := inclusionProofKey(ctx, did.Id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		proofAsBytes, err := marshalRecord(proofKey, proof)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := ctx.GetStub().PutState(proofKey, proofAsBytes); err != nil {
+			return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+		}
+	}
+
+	return &AnchorBatchResult{Batch: &batch, Dids: dids}, nil
+}
+
+// GetInclusionProof returns the proof that the document of an anchored did is
+// part of its batch
+func (s *DidContract) GetInclusionProof(ctx contractapi.TransactionContextInterface, didId string) (*InclusionProof, error) {
+	key, err := inclusionProofKey(ctx, didId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	proofAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if proofAsBytes == nil {
+		return nil, newError(codeNotFound, "%s is not anchored", didId)
+	}
+
+	proof := new(InclusionProof)
+
+	if err := unmarshalRecord(key, proofAsBytes, proof); err != nil {
+		return nil, err
+	}
+
+	return proof, nil
+}
+
+// GetAnchorBatch returns the anchored batch with given id
+func (s *DidContract) GetAnchorBatch(ctx contractapi.TransactionContextInterface, batchId string) (*AnchorBatch, error) {
+	key, err := anchorBatchKey(ctx, batchId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	batchAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if batchAsBytes == nil {
+		return nil, newError(codeNotFound, "Anchored batch %s does not exist", batchId)
+	}
+
+	batch := new(AnchorBatch)
+
+	if err := unmarshalRecord(key, batchAsBytes, batch); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
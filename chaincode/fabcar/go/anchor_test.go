@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// proofRoot returns the root the path of an inclusion proof leads to from leafHash
+func proofRoot(t *testing.T, leafHash []byte, path []MerkleProofStep) []byte {
+	hash := leafHash
+
+	for _, step := range path {
+		sibling, err := hex.DecodeString(step.Hash)
+		require.NoError(t, err)
+
+		if step.Position == merkleLeft {
+			hash = merkleNode(sibling, hash)
+		} else {
+			hash = merkleNode(hash, sibling)
+		}
+	}
+
+	return hash
+}
+
+func TestMerkleTree(t *testing.T) {
+	for size := 1; size <= 9; size++ {
+		leaves := [][]byte{}
+
+		for i := 0; i < size; i++ {
+			leaves = append(leaves, merkleLeaf([]byte{byte(i)}))
+		}
+
+		root, paths := merkleTree(leaves)
+		require.Len(t, paths, size)
+
+		for i, leaf := range leaves {
+			assert.Equal(t, root, proofRoot(t, leaf, paths[i]), "should prove leaf %d of %d", i, size)
+		}
+
+		if size > 1 {
+			assert.NotEqual(t, root, proofRoot(t, leaves[0], paths[1]), "should not prove a leaf with the path of another")
+		}
+	}
+
+	leaf := merkleLeaf([]byte("did"))
+	root, paths := merkleTree([][]byte{leaf})
+	assert.Equal(t, leaf, root, "should use the single leaf as root")
+	assert.Empty(t, paths[0], "should not need a path for a single leaf")
+
+	assert.False(t, bytes.Equal(merkleLeaf(append(leaf, leaf...)), merkleNode(leaf, leaf)), "should hash leaves and nodes differently")
+}
+
+func TestAnchorDidBatch(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	items := []json.RawMessage{}
+
+	for i := 0; i < 3; i++ {
+		items = append(items, batchItem(t, newTestKey(t)))
+	}
+
+	result, err := s.AnchorDidBatch(l.ctx, batchJSON(t, items...))
+	require.NoError(t, err, "should anchor every did")
+	require.Len(t, result.Dids, 3, "should return every document")
+	assert.Equal(t, l.stub.GetTxID(), result.Batch.BatchId, "should identify the batch by transaction")
+	assert.Equal(t, 3, result.Batch.Size)
+	assert.Equal(t, testClientID, result.Dids[0].Controller, "should record the submitting client as controller")
+	assert.Equal(t, result.Dids[0].Id+"#vcs", result.Dids[0].ServiceId, "should qualify references as CreateDid")
+	assert.Equal(t, 0, l.stub.SetEventCallCount(), "should not emit the documents")
+
+	batch, err := s.GetAnchorBatch(l.ctx, result.Batch.BatchId)
+	require.NoError(t, err, "should store the batch")
+	assert.Equal(t, result.Batch, batch)
+
+	for i, did := range result.Dids {
+		_, err := s.QueryDidByKey(l.ctx, did.Id)
+		assertErrorCode(t, err, codeDidNotFound, "should not store the documents")
+
+		proof, err := s.GetInclusionProof(l.ctx, did.Id)
+		require.NoError(t, err, "should store an inclusion proof per did")
+		assert.Equal(t, i, proof.LeafIndex, "should keep the batch order")
+		assert.Equal(t, batch.Root, proof.Root, "should prove inclusion in the batch")
+
+		documentAsBytes, err := canonicalJSON(did)
+		require.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(merkleLeaf(documentAsBytes)), proof.LeafHash, "should hash the canonical JSON of the document")
+		assert.Equal(t, batch.Root, hex.EncodeToString(proofRoot(t, merkleLeaf(documentAsBytes), proof.Path)), "should prove the document against the root")
+	}
+
+	l.nextTx()
+
+	_, err = s.AnchorDidBatch(l.ctx, batchJSON(t, items[0]))
+	assertErrorCode(t, err, codeBatchRejected, "should not anchor a did twice")
+	assert.Contains(t, err.(*ContractError).Details["0"], "is already anchored")
+
+	err = createDidFromItem(t, l, items[1])
+	assertErrorCode(t, err, codeDidAlreadyExists, "should not create anchored dids")
+
+	other := batchItem(t, newTestKey(t))
+	require.NoError(t, createDidFromItem(t, l, other))
+	l.nextTx()
+
+	_, err = s.AnchorDidBatch(l.ctx, batchJSON(t, other))
+	assertErrorCode(t, err, codeBatchRejected, "should not anchor created dids")
+	assert.Contains(t, err.(*ContractError).Details["0"], "already exists")
+
+	fourth := batchItem(t, newTestKey(t))
+	_, err = s.AnchorDidBatch(l.ctx, batchJSON(t, fourth, fourth, json.RawMessage(`{"unknown":true}`)))
+	assertErrorCode(t, err, codeBatchRejected, "should validate every item")
+	assert.Contains(t, err.(*ContractError).Details["1"], "already exists as item 0", "should reject duplicates within the batch")
+	assert.Contains(t, err.(*ContractError).Details["2"], "Failed to decode did", "should report the decoding failure")
+
+	_, err = s.AnchorDidBatch(l.ctx, "[]")
+	assertErrorCode(t, err, codeInvalidArgument, "should require at least one did")
+
+	_, err = s.GetInclusionProof(l.ctx, "did:example:unknown")
+	assertErrorCode(t, err, codeNotFound, "should fail for dids that are not anchored")
+
+	_, err = s.GetAnchorBatch(l.ctx, "tx99")
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown batches")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.GetInclusionProof(l.ctx, result.Dids[0].Id)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+// createDidFromItem creates the did of a batch item with CreateDid
+func createDidFromItem(t *testing.T, l *testLedger, item json.RawMessage) error {
+	details := DidDetails{}
+	require.NoError(t, json.Unmarshal(item, &details))
+
+	_, err := new(DidContract).CreateDid(l.ctx, details.AuthenticationId, details.AuthenticationType, details.AuthenticationController,
+		details.AuthenticationPublicKeyPerm, details.ServiceId, details.ServiceType, details.ServiceEndPoint, details.Expires)
+
+	return err
+}
@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// certificateAttributesConfig names the configuration entry holding the
+// certificate attributes projected into dids
+const certificateAttributesConfig = "certificateAttributes"
+
+// maxCertificateAttributes is the maximum number of certificate attributes that
+// can be projected into dids
+const maxCertificateAttributes = 16
+
+// CertificateAttributes lists the attributes of client certificates, such as
+// role or department, that are copied into the dids created from them. No
+// attributes are projected until a registry administrator configures them
+type CertificateAttributes struct {
+	Attributes []string `json:"attributes"`
+}
+
+// CertificateAttribute is an attribute of the certificate a did was created
+// from or last synchronized with
+type CertificateAttribute struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func certificateAttributesKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configObjectType, []string{certificateAttributesConfig})
+}
+
+// getCertificateAttributes returns the configured certificate attributes, or
+// none if they have not been configured
+func getCertificateAttributes(ctx contractapi.TransactionContextInterface) (*CertificateAttributes, error) {
+	key, err := certificateAttributesKey(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributesAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if attributesAsBytes == nil {
+		return &CertificateAttributes{Attributes: []string{}}, nil
+	}
+
+	attributes := new(CertificateAttributes)
+
+	if err := unmarshalRecord(key, attributesAsBytes, attributes); err != nil {
+		return nil, err
+	}
+
+	return attributes, nil
+}
+
+// SetCertificateAttributes replaces the certificate attributes projected into
+// dids with the given JSON array of attribute names. An empty array turns the
+// projection off. Only registry administrators may call it. Dids keep the
+// attributes they hold until they are synchronized again
+func (a *AdminContract) SetCertificateAttributes(ctx contractapi.TransactionContextInterface, attributesJSON string) error {
+	attributes := CertificateAttributes{Attributes: []string{}}
+
+	if err := decodeStrict([]byte(attributesJSON), &attributes.Attributes); err != nil {
+		return newError(codeInvalidArgument, "Failed to decode attributes. %s", err.Error())
+	}
+
+	if len(attributes.Attributes) > maxCertificateAttributes {
+		return newError(codeInvalidArgument, "At most %d attributes can be projected", maxCertificateAttributes)
+	}
+
+	seen := map[string]bool{}
+
+	for _, name := range attributes.Attributes {
+		if strings.TrimSpace(name) == "" {
+			return newError(codeInvalidArgument, "Attribute names must not be empty")
+		}
+
+		if seen[name] {
+			return newError(codeInvalidArgument, "Attribute %s is listed more than once", name)
+		}
+
+		seen[name] = true
+	}
+
+	key, err := certificateAttributesKey(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	attributesAsBytes, err := marshalRecord(key, attributes)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, attributesAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryCertificateAttributes returns the certificate attributes projected into
+// dids
+func (s *DidContract) QueryCertificateAttributes(ctx contractapi.TransactionContextInterface) (*CertificateAttributes, error) {
+	return getCertificateAttributes(ctx)
+}
+
+// projectCertificateAttributes returns the configured attributes carried by the
+// certificate of the submitting client, in the configured order. Attributes
+// missing from the certificate are left out
+func projectCertificateAttributes(ctx contractapi.TransactionContextInterface) ([]CertificateAttribute, error) {
+	attributes, err := getCertificateAttributes(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	projected := []CertificateAttribute{}
+
+	for _, name := range attributes.Attributes {
+		value, found, err := ctx.GetClientIdentity().GetAttributeValue(name)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read client attribute %s. %s", name, err.Error())
+		}
+
+		if found {
+			projected = append(projected, CertificateAttribute{Name: name, Value: value})
+		}
+	}
+
+	if len(projected) == 0 {
+		return nil, nil
+	}
+
+	return projected, nil
+}
+
+// SyncIdentityAttributes replaces the certificate attributes of the did stored
+// with given key with the configured attributes of the submitting client's
+// certificate, so that changes made by the certificate authority at
+// reenrollment are reflected in the did. Only the controlling client identity
+// of the did may synchronize it
+func (s *DidContract) SyncIdentityAttributes(ctx contractapi.TransactionContextInterface, didNumber string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", didNumber)
+	}
+
+	did.CertificateAttributes, err = projectCertificateAttributes(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return putUpdatedDid(ctx, didNumber, did)
+}
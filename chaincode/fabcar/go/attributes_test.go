@@ -0,0 +1,129 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetCertificateAttributes(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+
+	attributes, err := s.QueryCertificateAttributes(l.ctx)
+	require.NoError(t, err, "should return the default attributes")
+	assert.Empty(t, attributes.Attributes, "should not project attributes by default")
+
+	l.setAdmin(true)
+	require.NoError(t, a.SetCertificateAttributes(l.ctx, `["role", "department"]`), "should set the attributes")
+
+	attributes, err = s.QueryCertificateAttributes(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"role", "department"}, attributes.Attributes, "should keep the order of the attributes")
+
+	err = a.SetCertificateAttributes(l.ctx, `["role", "role"]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject duplicate attributes")
+
+	err = a.SetCertificateAttributes(l.ctx, `[" "]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject empty attribute names")
+
+	err = a.SetCertificateAttributes(l.ctx, `["a","b","c","d","e","f","g","h","i","j","k","l","m","n","o","p","q"]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should bound the number of attributes")
+
+	err = a.SetCertificateAttributes(l.ctx, `"role"`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a JSON array")
+
+	err = a.SetCertificateAttributes(l.ctx, `["role"] ["department"]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject trailing data")
+
+	require.NoError(t, a.SetCertificateAttributes(l.ctx, `[]`), "should turn the projection off")
+
+	attributes, err = s.QueryCertificateAttributes(l.ctx)
+	require.NoError(t, err)
+	assert.Empty(t, attributes.Attributes)
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.QueryCertificateAttributes(l.ctx)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestSyncIdentityAttributes(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	l.identity.GetX509CertificateReturns(&x509.Certificate{PublicKey: &key.private.PublicKey}, nil)
+	l.attributes["role"] = "auditor"
+	l.attributes["department"] = "finance"
+
+	id, err := s.CreateDidFromCertificate(l.ctx, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, did.CertificateAttributes, "should not project attributes unless configured")
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).SetCertificateAttributes(l.ctx, `["department", "role", "level"]`))
+	l.setAdmin(false)
+
+	_, err = s.CreateDidFromCertificate(l.ctx, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeDidAlreadyExists, "should derive the same did from the same key")
+
+	key = newTestKey(t)
+	l.identity.GetX509CertificateReturns(&x509.Certificate{PublicKey: &key.private.PublicKey}, nil)
+
+	id, err = s.CreateDidFromCertificate(l.ctx, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	expected := []CertificateAttribute{{Name: "department", Value: "finance"}, {Name: "role", Value: "auditor"}}
+
+	resolution, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.Equal(t, expected, resolution.DidDocumentMetadata.CertificateAttributes, "should project the configured attributes the certificate carries")
+
+	l.attributes["role"] = "manager"
+	delete(l.attributes, "department")
+	require.NoError(t, s.SyncIdentityAttributes(l.ctx, id), "should synchronize the attributes")
+	l.nextTx()
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []CertificateAttribute{{Name: "role", Value: "manager"}}, did.CertificateAttributes, "should replace the attributes with those of the current certificate")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.SyncIdentityAttributes(l.ctx, id)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller synchronize the did")
+
+	l.setClient(testClientID, testMSPID)
+	err = s.SyncIdentityAttributes(l.ctx, "DID9")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.identity.GetAttributeValueReturns("", false, errors.New("GetAttributeValue error"))
+	l.identity.GetAttributeValueCalls(nil)
+	err = s.SyncIdentityAttributes(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read client attribute department. GetAttributeValue error", "should return identity errors")
+}
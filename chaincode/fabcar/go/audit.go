@@ -0,0 +1,201 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// auditObjectType is the composite key object type under which the audit
+// records of did operations are stored, keyed by did, time and transaction
+const auditObjectType = "audit~did"
+
+// auditOrgIndex names the composite key index used to look up audit records by
+// the MSP ID of the submitting organization
+const auditOrgIndex = "audit~msp"
+
+// auditTimeLayout formats the time in audit keys with a fixed width, so that the
+// records of a did or an organization are returned oldest first
+const auditTimeLayout = "2006-01-02T15:04:05.000000000Z"
+
+// AuditRecord describes a transaction that changed the world state of a did.
+// Erasure is set for transactions that erased the personal data of the did
+type AuditRecord struct {
+	Operation  string           `json:"operation"`
+	DidNumber  string           `json:"didNumber"`
+	RecordedBy *ProvenanceEntry `json:"recordedBy"`
+	Erasure    *ErasureReceipt  `json:"erasure,omitempty" metadata:"erasure,optional"`
+}
+
+// putAuditRecord stores an audit record of the current transaction for the did
+// stored with didNumber, indexed by the MSP ID of the submitting client. The
+// operation is the called transaction function. A transaction that changes a
+// did several times leaves a single record
+func putAuditRecord(ctx contractapi.TransactionContextInterface, didNumber string) error {
+	return putAuditEntry(ctx, didNumber, nil)
+}
+
+// putAuditEntry stores the audit record of the current transaction for the did
+// stored with didNumber like putAuditRecord, carrying the receipt of an erasure
+// of its personal data if erasure is not nil
+func putAuditEntry(ctx contractapi.TransactionContextInterface, didNumber string, erasure *ErasureReceipt) error {
+	entry, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	timeKey := now.Format(auditTimeLayout)
+	key, err := ctx.GetStub().CreateCompositeKey(auditObjectType, []string{didNumber, timeKey, entry.TxID})
+
+	if err != nil {
+		return err
+	}
+
+	record := AuditRecord{Operation: transactionFunction(ctx), DidNumber: didNumber, RecordedBy: entry, Erasure: erasure}
+	recordAsBytes, err := marshalRecord(key, record)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, recordAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(auditOrgIndex, []string{entry.MSPID, timeKey, didNumber, entry.TxID})
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// getAuditRecord reads the audit record stored under key
+func getAuditRecord(ctx contractapi.TransactionContextInterface, key string) (*AuditRecord, error) {
+	recordAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if recordAsBytes == nil {
+		return nil, newError(codeNotFound, "Audit record %s does not exist", printableKey(key))
+	}
+
+	record := new(AuditRecord)
+
+	if err := unmarshalRecord(key, recordAsBytes, record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// QueryAuditLog returns the audit records of the did stored with given key,
+// oldest first. Dids without recorded operations return no records, dids with
+// more than maxQueryRecords records fail
+func (s *DidContract) QueryAuditLog(ctx contractapi.TransactionContextInterface, didNumber string) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+
+	err := scanState(ctx, auditObjectType, []string{didNumber}, func(key string, value []byte) (bool, error) {
+		if len(records) == maxQueryRecords {
+			return false, newTooManyRecordsError("audit records")
+		}
+
+		record := new(AuditRecord)
+
+		if err := unmarshalRecord(key, value, record); err != nil {
+			return false, err
+		}
+
+		records = append(records, *record)
+
+		return true, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// QueryAuditLogByOrg returns the audit records of the operations submitted by
+// clients of the organization with given MSP ID, oldest first. Organizations
+// with more than maxQueryRecords records fail
+func (s *DidContract) QueryAuditLogByOrg(ctx contractapi.TransactionContextInterface, mspID string) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+
+	err := scanState(ctx, auditOrgIndex, []string{mspID}, func(indexKey string, _ []byte) (bool, error) {
+		if len(records) == maxQueryRecords {
+			return false, newTooManyRecordsError("audit records")
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(indexKey)
+
+		if err != nil {
+			return false, err
+		}
+
+		if len(keyParts) != 4 {
+			return false, newError(codeCorruptRecord, "%s is not an audit index entry", printableKey(indexKey))
+		}
+
+		key, err := ctx.GetStub().CreateCompositeKey(auditObjectType, []string{keyParts[2], keyParts[1], keyParts[3]})
+
+		if err != nil {
+			return false, err
+		}
+
+		record, err := getAuditRecord(ctx, key)
+
+		if err != nil {
+			return false, err
+		}
+
+		records = append(records, *record)
+
+		return true, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
@@ -0,0 +1,97 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryAuditLog(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+
+	l.stub.GetFunctionAndParametersReturns("did:createDid", []string{})
+	id := createTestDid(t, l, key)
+
+	l.stub.GetFunctionAndParametersReturns("did:AddDidEndorser", []string{})
+	require.NoError(t, s.AddDidEndorser(l.ctx, id, otherMSPID))
+	l.nextTx()
+
+	l.stub.GetFunctionAndParametersReturns("UpdateDid", []string{})
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+
+	records, err := s.QueryAuditLog(l.ctx, id)
+	require.NoError(t, err, "should return the audit log")
+	require.Len(t, records, 3, "should record every operation")
+	assert.Equal(t, []string{"CreateDid", "AddDidEndorser", "UpdateDid"}, []string{records[0].Operation, records[1].Operation, records[2].Operation},
+		"should record the operations oldest first")
+	assert.Equal(t, id, records[0].DidNumber, "should record the did")
+	assert.Equal(t, &ProvenanceEntry{ClientID: testClientID, MSPID: testMSPID, TxID: "tx1", Timestamp: testStart.Add(time.Second).Format(time.RFC3339Nano)},
+		records[0].RecordedBy, "should record the submitter and transaction")
+	assert.Equal(t, "tx2", records[1].RecordedBy.TxID, "should record the transaction of each operation")
+
+	records, err = s.QueryAuditLog(l.ctx, "DID9")
+	require.NoError(t, err)
+	assert.Empty(t, records, "should return no records for dids without operations")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	_, err = s.QueryAuditLog(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read from world state. GetStateByPartialCompositeKeyWithPagination error", "should return query errors")
+}
+
+func TestQueryAuditLogByOrg(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	l.stub.GetFunctionAndParametersReturns("CreateDid", []string{})
+	first := createTestDid(t, l, newTestKey(t))
+	l.setClient(otherClientID, otherMSPID)
+	createTestDid(t, l, newTestKey(t))
+	l.setClient(testClientID, testMSPID)
+	third := createTestDid(t, l, newTestKey(t))
+
+	records, err := s.QueryAuditLogByOrg(l.ctx, testMSPID)
+	require.NoError(t, err, "should return the audit log of the organization")
+	require.Len(t, records, 2, "should only return the operations of the organization")
+	assert.Equal(t, []string{first, third}, []string{records[0].DidNumber, records[1].DidNumber}, "should return the records oldest first")
+
+	records, err = s.QueryAuditLogByOrg(l.ctx, "Org3MSP")
+	require.NoError(t, err)
+	assert.Empty(t, records, "should return no records for organizations without operations")
+
+	for key := range l.state {
+		if objectType, parts, _ := l.stub.SplitCompositeKey(key); objectType == auditObjectType && parts[0] == first {
+			delete(l.state, key)
+		}
+	}
+
+	_, err = s.QueryAuditLogByOrg(l.ctx, testMSPID)
+	assertErrorCode(t, err, codeNotFound, "should fail for index entries without a record")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	_, err = s.QueryAuditLogByOrg(l.ctx, testMSPID)
+	assert.EqualError(t, err, "Failed to read from world state. GetStateByPartialCompositeKeyWithPagination error", "should return query errors")
+}
@@ -0,0 +1,138 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// DidDetails describes the details of a new did, as given to CreateDid
+type DidDetails struct {
+	AuthenticationId            string `json:"authenticationId"`
+	AuthenticationType          string `json:"authenticationType"`
+	AuthenticationController    string `json:"authenticationController"`
+	AuthenticationPublicKeyPerm string `json:"authenticationPublicKeyPerm"`
+	ServiceId                   string `json:"serviceId"`
+	ServiceType                 string `json:"serviceType"`
+	ServiceEndPoint             string `json:"serviceEndPoint"`
+	Expires                     string `json:"expires,omitempty"`
+}
+
+// decodeDidDetails decodes a single batch item, rejecting unknown members
+func decodeDidDetails(itemJSON json.RawMessage) (*DidDetails, error) {
+	details := new(DidDetails)
+
+	if err := decodeStrict(itemJSON, details); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode did. %s", err.Error())
+	}
+
+	return details, nil
+}
+
+// BatchCreateDids adds every did of a JSON array of DidDetails in a single
+// transaction and returns their generated identifiers in the order given. Each
+// item is validated as by CreateDid; if any item fails, no did is created and
+// the details of the error hold the failure of every failing item by its index.
+// The whole batch counts against the registration quota of the submitting client
+// and is charged the registration fee in a single payment
+func (s *DidContract) BatchCreateDids(ctx contractapi.TransactionContextInterface, didsJSON string) ([]string, error) {
+	items := []json.RawMessage{}
+
+	if err := json.Unmarshal([]byte(didsJSON), &items); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode dids. %s", err.Error())
+	}
+
+	if len(items) == 0 {
+		return nil, newError(codeInvalidArgument, "At least one did must be given")
+	}
+
+	if err := admitRegistration(ctx, len(items)); err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	events := []DidEvent{}
+	failures := map[string]string{}
+	created := map[string]int{}
+
+	for i, itemJSON := range items {
+		did, err := s.batchCreateDid(ctx, itemJSON, created)
+
+		if err != nil {
+			failures[strconv.Itoa(i)] = errorMessage(err)
+			continue
+		}
+
+		created[did.Id] = i
+		ids = append(ids, did.Id)
+		events = append(events, DidEvent{DidNumber: did.Id, Did: did})
+	}
+
+	if len(failures) > 0 {
+		batchErr := newError(codeBatchRejected, "Failed to create %d of %d dids", len(failures), len(items))
+		batchErr.Details = failures
+
+		return nil, batchErr
+	}
+
+	if err := emitDidsEvent(ctx, didsCreatedEvent, events); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// batchCreateDid creates the did of a single batch item. Writes of the
+// transaction are not visible to its own reads, so created holds the
+// identifiers already created by earlier items
+func (s *DidContract) batchCreateDid(ctx contractapi.TransactionContextInterface, itemJSON json.RawMessage, created map[string]int) (*Did, error) {
+	details, err := decodeDidDetails(itemJSON)
+
+	if err != nil {
+		return nil, err
+	}
+
+	did := Did{
+		AuthenticationId:            details.AuthenticationId,
+		AuthenticationType:          details.AuthenticationType,
+		AuthenticationController:    details.AuthenticationController,
+		AuthenticationPublicKeyPerm: details.AuthenticationPublicKeyPerm,
+		ServiceId:                   details.ServiceId,
+		ServiceType:                 details.ServiceType,
+		ServiceEndPoint:             details.ServiceEndPoint,
+		Expires:                     details.Expires,
+	}
+
+	if err := assignIdentifier(ctx, &did); err != nil {
+		return nil, err
+	}
+
+	if previous, ok := created[did.Id]; ok {
+		return nil, newError(codeDidAlreadyExists, "%s already exists as item %d", did.Id, previous)
+	}
+
+	if err := s.createDid(ctx, did.Id, &did); err != nil {
+		return nil, err
+	}
+
+	return &did, nil
+}
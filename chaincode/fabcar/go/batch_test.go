@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// batchItem returns the JSON of a batch item with key as its authentication key
+func batchItem(t *testing.T, key *testKey) json.RawMessage {
+	item, err := json.Marshal(DidDetails{
+		AuthenticationId:            "#keys-1",
+		AuthenticationType:          testKeyType,
+		AuthenticationPublicKeyPerm: key.pem,
+		ServiceId:                   "#vcs",
+		ServiceType:                 "VerifiableCredentialService",
+		ServiceEndPoint:             testEndpoint,
+	})
+	require.NoError(t, err)
+
+	return item
+}
+
+// batchJSON returns the JSON array of items
+func batchJSON(t *testing.T, items ...json.RawMessage) string {
+	batch, err := json.Marshal(items)
+	require.NoError(t, err)
+
+	return string(batch)
+}
+
+func TestBatchCreateDids(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	first := batchItem(t, newTestKey(t))
+	second := batchItem(t, newTestKey(t))
+
+	ids, err := s.BatchCreateDids(l.ctx, batchJSON(t, first, second))
+	require.NoError(t, err, "should create every did")
+	require.Len(t, ids, 2, "should return an id per did")
+
+	for _, id := range ids {
+		did, err := s.QueryDidByKey(l.ctx, id)
+		assert.Nil(t, err, "should store every did")
+		assert.Equal(t, id, did.Id, "should store the dids under their ids")
+	}
+
+	name, payload := l.event(t)
+	assert.Equal(t, didsCreatedEvent, name, "should emit a single dids.created event")
+
+	event := new(DidsEvent)
+	require.NoError(t, json.Unmarshal(payload, event))
+	require.Len(t, event.Dids, 2, "should carry every did in the event")
+	assert.Equal(t, ids[1], event.Dids[1].DidNumber, "should keep the batch order in the event")
+
+	l = newTestLedger(t)
+	_, err = s.BatchCreateDids(l.ctx, batchJSON(t, first, first))
+	assertErrorCode(t, err, codeBatchRejected, "should reject duplicates within the batch")
+	assert.Contains(t, err.(*ContractError).Details["1"], "already exists as item 0", "should report the failing item by index")
+	assert.NotContains(t, err.(*ContractError).Details, "0", "should not report items that succeeded")
+
+	_, err = s.BatchCreateDids(l.ctx, batchJSON(t, second, json.RawMessage(`{"authenticationId":"#keys-1","unknown":true}`)))
+	assertErrorCode(t, err, codeBatchRejected, "should reject items with unknown members")
+	assert.Contains(t, err.(*ContractError).Details["1"], "Failed to decode did", "should report the decoding failure")
+
+	_, err = s.BatchCreateDids(l.ctx, "[]")
+	assertErrorCode(t, err, codeInvalidArgument, "should require at least one did")
+
+	_, err = s.BatchCreateDids(l.ctx, "{}")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a JSON array")
+}
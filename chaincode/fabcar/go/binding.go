@@ -0,0 +1,218 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// identityBindingObjectType is the composite key object type under which the
+// bindings of enrollment ids to dids are stored, keyed by enrollment id and
+// MSP ID
+const identityBindingObjectType = "identityBinding"
+
+// enrollmentIdAttribute is the certificate attribute in which Fabric CA
+// records the enrollment id of an identity
+const enrollmentIdAttribute = "hf.EnrollmentID"
+
+// IdentityBinding links the enrollment id of a client identity of an
+// organization to the did it is known by. CertificateSerial is the hexadecimal
+// serial number of the certificate the binding was registered with
+type IdentityBinding struct {
+	EnrollmentId      string           `json:"enrollmentId"`
+	MSPID             string           `json:"mspId"`
+	ClientID          string           `json:"clientId"`
+	CertificateSerial string           `json:"certificateSerial,omitempty"`
+	DidNumber         string           `json:"didNumber"`
+	Bound             *ProvenanceEntry `json:"bound"`
+}
+
+// clientEnrollmentId returns the enrollment id of the submitting client
+// identity: the hf.EnrollmentID attribute of its certificate, or the common
+// name of certificates not issued with attributes
+func clientEnrollmentId(ctx contractapi.TransactionContextInterface) (string, error) {
+	enrollmentId, found, err := ctx.GetClientIdentity().GetAttributeValue(enrollmentIdAttribute)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read client attribute %s. %s", enrollmentIdAttribute, err.Error())
+	}
+
+	if found && enrollmentId != "" {
+		return enrollmentId, nil
+	}
+
+	certificate, err := ctx.GetClientIdentity().GetX509Certificate()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read client certificate. %s", err.Error())
+	}
+
+	if certificate == nil || certificate.Subject.CommonName == "" {
+		return "", newError(codeUnauthorized, "Caller has no enrollment id")
+	}
+
+	return certificate.Subject.CommonName, nil
+}
+
+func identityBindingKey(ctx contractapi.TransactionContextInterface, enrollmentId string, mspID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(identityBindingObjectType, []string{enrollmentId, mspID})
+}
+
+// RegisterIdentityBinding binds the enrollment id of the submitting client
+// identity in its organization to the did stored with given key, replacing any
+// did it was bound to before. Only the controlling client identity of the did
+// may bind to it
+func (s *DidContract) RegisterIdentityBinding(ctx contractapi.TransactionContextInterface, didNumber string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", didNumber)
+	}
+
+	enrollmentId, err := clientEnrollmentId(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	entry, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	key, err := identityBindingKey(ctx, enrollmentId, entry.MSPID)
+
+	if err != nil {
+		return err
+	}
+
+	serial, err := clientCertificateSerial(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	binding := IdentityBinding{EnrollmentId: enrollmentId, MSPID: entry.MSPID, ClientID: entry.ClientID, CertificateSerial: serial, DidNumber: didNumber, Bound: entry}
+
+	if err := putCertificateBinding(ctx, key, &binding); err != nil {
+		return err
+	}
+
+	bindingAsBytes, err := marshalRecord(key, binding)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, bindingAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// GetDidForIdentity returns the binding of the given enrollment id to a did.
+// Enrollment ids are only unique within an organization: if several
+// organizations bound the enrollment id, the binding of the caller's
+// organization is returned, and the call fails if it has none. Bindings to dids
+// that were deleted since are not found
+func (s *DidContract) GetDidForIdentity(ctx contractapi.TransactionContextInterface, enrollmentId string) (*IdentityBinding, error) {
+	if enrollmentId == "" {
+		return nil, newError(codeInvalidArgument, "Enrollment id must not be empty")
+	}
+
+	bindings := []IdentityBinding{}
+
+	err := scanState(ctx, identityBindingObjectType, []string{enrollmentId}, func(key string, value []byte) (bool, error) {
+		if len(bindings) == maxQueryRecords {
+			return false, newTooManyRecordsError("bindings")
+		}
+
+		binding := IdentityBinding{}
+
+		if err := unmarshalRecord(key, value, &binding); err != nil {
+			return false, err
+		}
+
+		bindings = append(bindings, binding)
+
+		return true, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	binding, err := selectIdentityBinding(ctx, enrollmentId, bindings)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.QueryDidByKey(ctx, binding.DidNumber); err != nil {
+		return nil, err
+	}
+
+	return binding, nil
+}
+
+// selectIdentityBinding returns the only binding of an enrollment id, or the
+// binding of the caller's organization if several organizations bound it
+func selectIdentityBinding(ctx contractapi.TransactionContextInterface, enrollmentId string, bindings []IdentityBinding) (*IdentityBinding, error) {
+	if len(bindings) == 0 {
+		return nil, newError(codeNotFound, "No did is bound to enrollment id %s", enrollmentId)
+	}
+
+	if len(bindings) == 1 {
+		return &bindings[0], nil
+	}
+
+	mspID, err := clientMSPID(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	mspIDs := []string{}
+
+	for i, binding := range bindings {
+		if binding.MSPID == mspID {
+			return &bindings[i], nil
+		}
+
+		mspIDs = append(mspIDs, binding.MSPID)
+	}
+
+	return nil, newError(codeConflict, "Enrollment id %s is bound by %s", enrollmentId, strings.Join(mspIDs, ", "))
+}
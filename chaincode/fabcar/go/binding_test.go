@@ -0,0 +1,95 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterIdentityBinding(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	err := s.RegisterIdentityBinding(l.ctx, id)
+	assertErrorCode(t, err, codeUnauthorized, "should require an enrollment id")
+
+	l.attributes[enrollmentIdAttribute] = "user1"
+	require.NoError(t, s.RegisterIdentityBinding(l.ctx, id), "should bind the enrollment id of the caller")
+	l.nextTx()
+
+	binding, err := s.GetDidForIdentity(l.ctx, "user1")
+	require.NoError(t, err, "should return the binding")
+	assert.Equal(t, IdentityBinding{EnrollmentId: "user1", MSPID: testMSPID, ClientID: testClientID, DidNumber: id, Bound: binding.Bound}, *binding)
+	assert.Equal(t, "tx2", binding.Bound.TxID, "should record the binding transaction")
+
+	l.setClient(otherClientID, otherMSPID)
+	other := createTestDid(t, l, newTestKey(t))
+
+	err = s.RegisterIdentityBinding(l.ctx, id)
+	assertErrorCode(t, err, codeUnauthorized, "should only bind the controller of the did")
+
+	require.NoError(t, s.RegisterIdentityBinding(l.ctx, other), "should bind the same enrollment id in another organization")
+	l.nextTx()
+
+	binding, err = s.GetDidForIdentity(l.ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, other, binding.DidNumber, "should return the binding of the caller's organization")
+
+	l.setClient(testClientID, testMSPID)
+	binding, err = s.GetDidForIdentity(l.ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, id, binding.DidNumber, "should keep the bindings of organizations apart")
+
+	l.setClient("x509::CN=user3", "Org3MSP")
+	_, err = s.GetDidForIdentity(l.ctx, "user1")
+	assertErrorCode(t, err, codeConflict, "should not choose between the bindings of other organizations")
+
+	l.setClient(testClientID, testMSPID)
+	delete(l.attributes, enrollmentIdAttribute)
+	l.identity.GetX509CertificateReturns(&x509.Certificate{Subject: pkix.Name{CommonName: "peer-admin"}}, nil)
+	require.NoError(t, s.RegisterIdentityBinding(l.ctx, id), "should fall back to the common name of the certificate")
+	l.nextTx()
+
+	binding, err = s.GetDidForIdentity(l.ctx, "peer-admin")
+	require.NoError(t, err)
+	assert.Equal(t, id, binding.DidNumber)
+
+	deleteTestDid(t, l, key, id)
+
+	_, err = s.GetDidForIdentity(l.ctx, "peer-admin")
+	assertErrorCode(t, err, codeDidNotFound, "should not return bindings to deleted dids")
+
+	_, err = s.GetDidForIdentity(l.ctx, "user9")
+	assertErrorCode(t, err, codeNotFound, "should fail for enrollment ids without a binding")
+
+	_, err = s.GetDidForIdentity(l.ctx, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require an enrollment id")
+
+	l.identity.GetX509CertificateReturns(nil, errors.New("GetX509Certificate error"))
+	_, err = clientEnrollmentId(l.ctx)
+	assert.EqualError(t, err, "Failed to read client certificate. GetX509Certificate error", "should return identity errors")
+}
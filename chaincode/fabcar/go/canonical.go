@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// canonicalJSON encodes value as canonical JSON: object members ordered by key,
+// null members left out, no HTML escaping and integer numbers only. Every peer
+// endorsing a transaction therefore writes the same bytes for the same value,
+// whatever the order of its map members or the formatting of its numbers
+func canonicalJSON(value interface{}) ([]byte, error) {
+	valueAsBytes, err := json.Marshal(value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(valueAsBytes))
+	decoder.UseNumber()
+
+	var document interface{}
+
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	document, err = canonicalValue(document)
+
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := new(bytes.Buffer)
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// canonicalValue returns document with the null members of its objects removed.
+// Null elements of arrays are kept so that positions do not change. Numbers that
+// are not integers are rejected, as their encoding is ambiguous
+func canonicalValue(document interface{}) (interface{}, error) {
+	switch value := document.(type) {
+	case map[string]interface{}:
+		for key, member := range value {
+			if member == nil {
+				delete(value, key)
+				continue
+			}
+
+			canonical, err := canonicalValue(member)
+
+			if err != nil {
+				return nil, err
+			}
+
+			value[key] = canonical
+		}
+	case []interface{}:
+		for i, element := range value {
+			canonical, err := canonicalValue(element)
+
+			if err != nil {
+				return nil, err
+			}
+
+			value[i] = canonical
+		}
+	case json.Number:
+		if strings.ContainsAny(value.String(), ".eE") || value.String() == "-0" {
+			return nil, fmt.Errorf("Number %s is not an integer and has no canonical encoding", value.String())
+		}
+	}
+
+	return document, nil
+}
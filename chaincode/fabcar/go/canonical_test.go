@@ -0,0 +1,112 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	document := map[string]interface{}{"b": 1, "a": []interface{}{nil, "x&y"}, "c": nil}
+
+	documentAsBytes, err := canonicalJSON(document)
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[null,"x&y"],"b":1}`, string(documentAsBytes), "should order members, drop null members and not escape HTML")
+
+	documentAsBytes, err = canonicalJSON(map[string]interface{}{"z": map[string]int{"y": 2, "x": -1}, "a": int64(1) << 62})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":4611686018427387904,"z":{"x":-1,"y":2}}`, string(documentAsBytes), "should order nested members and keep integers exact")
+
+	for _, number := range []float64{1.5, 1e21} {
+		_, err = canonicalJSON(map[string]float64{"number": number})
+		assert.Error(t, err, "should reject %v as it is not an integer", number)
+	}
+
+	documentAsBytes, err = canonicalJSON(map[string]float64{"number": 2})
+	require.NoError(t, err)
+	assert.Equal(t, `{"number":2}`, string(documentAsBytes), "should accept integral floats")
+}
+
+func TestMarshalRecord(t *testing.T) {
+	l := newTestLedger(t)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	did, err := new(DidContract).QueryDidById(l.ctx, id)
+	require.NoError(t, err)
+
+	didAsBytes, err := json.Marshal(did)
+	require.NoError(t, err)
+
+	record := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(didAsBytes, &record))
+	record[schemaVersionMember] = json.RawMessage(strconv.Itoa(didSchemaVersion))
+
+	canonical, err := canonicalJSON(record)
+	require.NoError(t, err)
+	assert.Equal(t, string(canonical), string(l.state[testDidKey(id)]), "should write records as canonical JSON with their schema version")
+
+	_, err = marshalRecord("DID0", map[string]float64{"number": 0.1})
+	assert.EqualError(t, err, "Failed to encode DID0. Number 0.1 is not an integer and has no canonical encoding", "should reject records without canonical encoding")
+}
+
+func TestCompressRecord(t *testing.T) {
+	l := newTestLedger(t)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	assert.False(t, bytes.HasPrefix(l.state[testDidKey(id)], compressedRecordPrefix), "should store small documents as they are")
+
+	did, err := getDid(l.ctx, id)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		did.VerificationMethods = append(did.VerificationMethods, VerificationMethod{Id: id + "#keys-" + strconv.Itoa(i+2), Type: testKeyType, Controller: id, PublicKeyPem: key.pem})
+	}
+
+	storageKey, err := didKey(l.ctx, id)
+	require.NoError(t, err)
+	require.NoError(t, putDid(l.ctx, storageKey, id, did))
+
+	stored := l.state[testDidKey(id)]
+	assert.True(t, bytes.HasPrefix(stored, compressedRecordPrefix), "should compress documents above the threshold")
+
+	expected, err := marshalRecord(id, did)
+	require.NoError(t, err)
+	assert.Less(t, len(stored), len(expected), "should store fewer bytes")
+
+	decompressed, err := decompressRecord(id, stored)
+	require.NoError(t, err)
+	assert.Equal(t, expected, decompressed, "should restore the encoded document")
+
+	read, err := getDid(l.ctx, id)
+	require.NoError(t, err, "should read compressed documents")
+	assert.Equal(t, did, read)
+
+	l.state[testDidKey(id)] = append(append([]byte{}, compressedRecordPrefix...), []byte("not gzip")...)
+	_, err = getDid(l.ctx, id)
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject corrupt compressed records")
+}
@@ -0,0 +1,191 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// didCapsConfig names the configuration entry holding the caps on the
+// verification methods and services of each did
+const didCapsConfig = "didCaps"
+
+// DidCap limits the number of verification methods, recovery methods
+// included, and service entries a single did may have. A cap of zero means no
+// cap below the document limits of the registry
+type DidCap struct {
+	MaxVerificationMethods int `json:"maxVerificationMethods"`
+	MaxServices            int `json:"maxServices"`
+}
+
+// DidCapOverride replaces the default cap for a did, such as the did of
+// infrastructure that needs more keys or endpoints than others
+type DidCapOverride struct {
+	Did                    string `json:"did"`
+	MaxVerificationMethods int    `json:"maxVerificationMethods"`
+	MaxServices            int    `json:"maxServices"`
+}
+
+// DidCaps describes the default cap of dids and the overrides of single dids
+type DidCaps struct {
+	Default DidCap           `json:"default"`
+	Dids    []DidCapOverride `json:"dids"`
+}
+
+func didCapsKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configObjectType, []string{didCapsConfig})
+}
+
+// getDidCaps returns the configured caps, or no caps if none have been
+// configured
+func getDidCaps(ctx contractapi.TransactionContextInterface) (*DidCaps, error) {
+	key, err := didCapsKey(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	capsAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if capsAsBytes == nil {
+		return &DidCaps{Dids: []DidCapOverride{}}, nil
+	}
+
+	caps := new(DidCaps)
+
+	if err := unmarshalRecord(key, capsAsBytes, caps); err != nil {
+		return nil, err
+	}
+
+	return caps, nil
+}
+
+// capOf returns the cap applying to the did with given id
+func (c *DidCaps) capOf(didNumber string) DidCap {
+	for _, override := range c.Dids {
+		if override.Did == didNumber {
+			return DidCap{MaxVerificationMethods: override.MaxVerificationMethods, MaxServices: override.MaxServices}
+		}
+	}
+
+	return c.Default
+}
+
+// validateDidCap returns an error unless both caps of didCap are not negative
+func validateDidCap(didCap DidCap) error {
+	if didCap.MaxVerificationMethods < 0 || didCap.MaxServices < 0 {
+		return newError(codeInvalidArgument, "Caps must not be negative")
+	}
+
+	return nil
+}
+
+// SetDidCaps replaces the caps on the verification methods and services of
+// dids with the given JSON DidCaps. Only registry administrators may call it.
+// Dids already above their cap are kept, but cannot add more
+func (a *AdminContract) SetDidCaps(ctx contractapi.TransactionContextInterface, capsJSON string) error {
+	caps := DidCaps{Dids: []DidCapOverride{}}
+
+	if err := decodeStrict([]byte(capsJSON), &caps); err != nil {
+		return newError(codeInvalidArgument, "Failed to decode caps. %s", err.Error())
+	}
+
+	if err := validateDidCap(caps.Default); err != nil {
+		return err
+	}
+
+	if caps.Dids == nil {
+		caps.Dids = []DidCapOverride{}
+	}
+
+	dids := map[string]bool{}
+
+	for _, override := range caps.Dids {
+		if override.Did == "" {
+			return newError(codeInvalidArgument, "did must be set in the caps of dids")
+		}
+
+		if dids[override.Did] {
+			return newError(codeInvalidArgument, "Cap of %s is given more than once", override.Did)
+		}
+
+		dids[override.Did] = true
+
+		if err := validateDidCap(DidCap{MaxVerificationMethods: override.MaxVerificationMethods, MaxServices: override.MaxServices}); err != nil {
+			return err
+		}
+	}
+
+	key, err := didCapsKey(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	capsAsBytes, err := marshalRecord(key, caps)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, capsAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryDidCaps returns the caps on the verification methods and services of
+// dids
+func (s *DidContract) QueryDidCaps(ctx contractapi.TransactionContextInterface) (*DidCaps, error) {
+	return getDidCaps(ctx)
+}
+
+// assertDidCap returns an error if update, the extended document of the did
+// with given id, has more verification methods or services than the cap of the
+// did allows. It is called by the transactions that add either
+func assertDidCap(ctx contractapi.TransactionContextInterface, didNumber string, update *Did) error {
+	caps, err := getDidCaps(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	didCap := caps.capOf(didNumber)
+
+	if methods := methodCount(update); didCap.MaxVerificationMethods > 0 && methods > didCap.MaxVerificationMethods {
+		return newLimitError(codeQuotaExceeded, limitMaxVerificationMethods, methods, didCap.MaxVerificationMethods,
+			"%s may have at most %d verification methods", didNumber, didCap.MaxVerificationMethods)
+	}
+
+	if services := serviceCount(update); didCap.MaxServices > 0 && services > didCap.MaxServices {
+		return newLimitError(codeQuotaExceeded, limitMaxServices, services, didCap.MaxServices, "%s may have at most %d services", didNumber, didCap.MaxServices)
+	}
+
+	return nil
+}
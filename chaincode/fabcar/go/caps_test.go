@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetDidCaps(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+
+	caps, err := s.QueryDidCaps(l.ctx)
+	require.NoError(t, err, "should return the default caps")
+	assert.Equal(t, &DidCaps{Dids: []DidCapOverride{}}, caps, "should not cap dids by default")
+
+	err = l.before(a, "SetDidCaps")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators set the caps")
+
+	l.setAdmin(true)
+	require.NoError(t, a.SetDidCaps(l.ctx, `{"default":{"maxVerificationMethods":4,"maxServices":1},"dids":[{"did":"DID0","maxVerificationMethods":50,"maxServices":10}]}`),
+		"should set the caps")
+
+	caps, err = s.QueryDidCaps(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, DidCap{MaxVerificationMethods: 4, MaxServices: 1}, caps.capOf("DID1"), "should apply the default cap")
+	assert.Equal(t, DidCap{MaxVerificationMethods: 50, MaxServices: 10}, caps.capOf("DID0"), "should apply the override of the did")
+
+	require.NoError(t, a.SetDidCaps(l.ctx, `{"default":{"maxVerificationMethods":4,"maxServices":1}}`), "should not require overrides")
+
+	caps, err = s.QueryDidCaps(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []DidCapOverride{}, caps.Dids, "should replace the overrides")
+
+	assertErrorCode(t, a.SetDidCaps(l.ctx, `{"default":{"maxVerificationMethods":-1,"maxServices":1}}`), codeInvalidArgument, "should reject negative caps")
+	assertErrorCode(t, a.SetDidCaps(l.ctx, `{"default":{},"dids":[{"maxServices":1}]}`), codeInvalidArgument, "should require the did of overrides")
+	assertErrorCode(t, a.SetDidCaps(l.ctx, `{"default":{},"dids":[{"did":"DID0"},{"did":"DID0"}]}`), codeInvalidArgument, "should reject duplicate overrides")
+	assertErrorCode(t, a.SetDidCaps(l.ctx, `{"default":{},"orgs":[]}`), codeInvalidArgument, "should reject unknown members")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.QueryDidCaps(l.ctx)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestAssertDidCap(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	l.setAdmin(true)
+	require.NoError(t, a.SetDidCaps(l.ctx, `{"default":{"maxVerificationMethods":2,"maxServices":1}}`))
+
+	signature := signAddMethod(t, l, key, id, id+"#keys-2", testKeyType, id, newTestKey(t).pem)
+	err := s.AddVerificationMethod(l.ctx, id, id+"#keys-2", testKeyType, id, newTestKey(t).pem, signature)
+	assertErrorCode(t, err, codeInvalidSignature, "should check the signature of methods within the cap")
+
+	material := newTestKey(t).pem
+	require.NoError(t, s.AddVerificationMethod(l.ctx, id, id+"#keys-2", testKeyType, id, material, signAddMethod(t, l, key, id, id+"#keys-2", testKeyType, id, material)),
+		"should add methods within the cap")
+	l.nextTx()
+
+	err = s.AddVerificationMethod(l.ctx, id, id+"#keys-3", testKeyType, id, newTestKey(t).pem, "")
+	assertLimitError(t, err, codeQuotaExceeded, limitMaxVerificationMethods, "3", "2", "should cap the verification methods of the did")
+
+	err = s.AddRecoveryMethod(l.ctx, id, id+"#recovery-1", testKeyType, id, newTestKey(t).pem, "")
+	assertErrorCode(t, err, codeQuotaExceeded, "should count recovery methods against the cap")
+
+	err = s.SetDidCommService(l.ctx, id, "#didcomm-1", `[{"uri":"https://example.com"},{"uri":"https://example.org"}]`, "")
+	assertErrorCode(t, err, codeQuotaExceeded, "should cap the services of the did")
+
+	require.NoError(t, a.SetDidCaps(l.ctx, `{"default":{"maxVerificationMethods":2,"maxServices":1},"dids":[{"did":"`+id+`","maxVerificationMethods":3,"maxServices":0}]}`))
+
+	material = newTestKey(t).pem
+	require.NoError(t, s.AddVerificationMethod(l.ctx, id, id+"#keys-3", testKeyType, id, material, signAddMethod(t, l, key, id, id+"#keys-3", testKeyType, id, material)),
+		"should apply the override of the did")
+
+	err = s.SetDidCommService(l.ctx, id, "#didcomm-1", `[{"uri":"https://example.com"},{"uri":"https://example.org"}]`, "")
+	assertErrorCode(t, err, codeInvalidSignature, "should not cap services of dids overridden with no cap")
+}
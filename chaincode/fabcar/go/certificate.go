@@ -0,0 +1,117 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+)
+
+// certificateMethodId is the id, relative to the new did, of the
+// authentication method taken from the caller's certificate
+const certificateMethodId = "#keys-1"
+
+// certificateMethodType returns the verification method type of the public
+// key of a client certificate. Fabric CAs issue P-256 keys by default, other
+// curves are described as JsonWebKey2020
+func certificateMethodType(publicKey crypto.PublicKey) (string, error) {
+	switch key := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve == elliptic.P256() {
+			return ecdsaSecp256r1VerificationKey2019, nil
+		}
+
+		return jsonWebKey2020, nil
+	case ed25519.PublicKey:
+		return ed25519VerificationKey2020, nil
+	case *rsa.PublicKey:
+		return rsaVerificationKey2018, nil
+	default:
+		return "", newError(codeInvalidArgument, "Certificate keys of type %T are not supported", publicKey)
+	}
+}
+
+// CreateDidFromCertificate adds a new did whose id and authentication method
+// are derived from the public key of the submitting client's X.509
+// certificate, so the registrant is known to hold the key: the proposal is
+// signed with it. The service and expiry are set as by CreateDid, and the
+// certificate attributes configured with SetCertificateAttributes are copied
+// into the did
+func (s *DidContract) CreateDidFromCertificate(ctx contractapi.TransactionContextInterface, serviceId string, serviceType string, serviceEndPoint string,
+	expires string) (string, error) {
+	certificate, err := ctx.GetClientIdentity().GetX509Certificate()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read client certificate. %s", err.Error())
+	}
+
+	if certificate == nil {
+		return "", newError(codeUnauthorized, "Caller has no X.509 certificate")
+	}
+
+	methodType, err := certificateMethodType(certificate.PublicKey)
+
+	if err != nil {
+		return "", err
+	}
+
+	publicKeyPem, err := keyencoding.PemFromPublicKey(certificate.PublicKey)
+
+	if err != nil {
+		return "", newError(codeInvalidArgument, "Failed to encode certificate key. %s", err.Error())
+	}
+
+	did := Did{
+		AuthenticationId:            certificateMethodId,
+		AuthenticationType:          methodType,
+		AuthenticationPublicKeyPerm: publicKeyPem,
+		ServiceId:                   serviceId,
+		ServiceType:                 serviceType,
+		ServiceEndPoint:             serviceEndPoint,
+		Expires:                     expires,
+	}
+
+	did.CertificateAttributes, err = projectCertificateAttributes(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := admitRegistration(ctx, 1); err != nil {
+		return "", err
+	}
+
+	if err := assignIdentifier(ctx, &did); err != nil {
+		return "", err
+	}
+
+	if err := s.createDid(ctx, did.Id, &did); err != nil {
+		return "", err
+	}
+
+	return did.Id, nil
+}
@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateMethodType(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	ed, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	types := map[string]interface{}{
+		ecdsaSecp256r1VerificationKey2019: &p256.PublicKey,
+		jsonWebKey2020:                    &p384.PublicKey,
+		ed25519VerificationKey2020:        ed,
+		rsaVerificationKey2018:            &rsaKey.PublicKey,
+	}
+
+	for expected, publicKey := range types {
+		methodType, err := certificateMethodType(publicKey)
+		require.NoError(t, err)
+		assert.Equal(t, expected, methodType, "should describe %T keys as %s", publicKey, expected)
+	}
+
+	_, err = certificateMethodType("key")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject unsupported keys")
+}
+
+func TestCreateDidFromCertificate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	_, err := s.CreateDidFromCertificate(l.ctx, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeUnauthorized, "should require a certificate")
+
+	key := newTestKey(t)
+	l.identity.GetX509CertificateReturns(&x509.Certificate{PublicKey: &key.private.PublicKey}, nil)
+
+	id, err := s.CreateDidFromCertificate(l.ctx, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	require.NoError(t, err, "should create the did from the certificate key")
+	l.nextTx()
+
+	expected, err := generateDidId(l.ctx, key.pem)
+	require.NoError(t, err)
+	assert.Equal(t, expected, id, "should derive the id from the certificate key")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id+certificateMethodId, did.AuthenticationId, "should add the key as the first verification method")
+	assert.Equal(t, testKeyType, did.AuthenticationType, "should describe the key by its curve")
+	assert.Equal(t, id, did.AuthenticationController)
+	assert.Equal(t, id+"#vcs", did.ServiceId, "should qualify the service id")
+	assert.Equal(t, testClientID, did.Controller, "should be controlled by the caller")
+
+	publicKey, err := publicKeyOf(did, id+certificateMethodId)
+	require.NoError(t, err)
+	assert.True(t, key.private.PublicKey.Equal(publicKey), "should store the certificate key")
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)), "should accept updates signed with the certificate key")
+
+	_, err = s.CreateDidFromCertificate(l.ctx, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeDidAlreadyExists, "should create a single did per certificate key")
+
+	l.identity.GetX509CertificateReturns(nil, errors.New("GetX509Certificate error"))
+	_, err = s.CreateDidFromCertificate(l.ctx, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assert.EqualError(t, err, "Failed to read client certificate. GetX509Certificate error", "should return identity errors")
+}
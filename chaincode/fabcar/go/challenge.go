@@ -0,0 +1,177 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// challengeObjectType is the composite key object type under which pending
+// authentication challenges are stored
+const challengeObjectType = "challenge"
+
+// challengeTTL is how long an authentication challenge can be answered
+const challengeTTL = 5 * time.Minute
+
+// AuthChallenge describes a nonce that the controller of a did must sign with its
+// authentication key to authenticate as the did
+type AuthChallenge struct {
+	DidNumber string `json:"didNumber"`
+	Nonce     string `json:"nonce"`
+	Expires   string `json:"expires"`
+}
+
+// challengeKey returns the key of the pending challenge of the given did
+func challengeKey(ctx contractapi.TransactionContextInterface, didNumber string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(challengeObjectType, []string{didNumber})
+}
+
+// txTime returns the timestamp of the current transaction
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	txTimestamp, err := ctx.GetStub().GetTxTimestamp()
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Failed to read transaction timestamp. %s", err.Error())
+	}
+
+	return time.Unix(txTimestamp.GetSeconds(), int64(txTimestamp.GetNanos())).UTC(), nil
+}
+
+// CreateAuthChallenge stores a new authentication challenge for a did, replacing
+// any pending one. The nonce is derived from the transaction id so that every
+// endorsing peer computes the same value
+func (s *DidContract) CreateAuthChallenge(ctx contractapi.TransactionContextInterface, didNumber string) (*AuthChallenge, error) {
+	if _, err := s.QueryDidByKey(ctx, didNumber); err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := AuthChallenge{
+		DidNumber: didNumber,
+		Nonce:     hashValue(didNumber + ctx.GetStub().GetTxID()),
+		Expires:   now.Add(challengeTTL).Format(time.RFC3339Nano),
+	}
+
+	key, err := challengeKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	challengeAsBytes, err := marshalRecord(key, challenge)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.GetStub().PutState(key, challengeAsBytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	if err := putAuditRecord(ctx, didNumber); err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+// VerifyAuthResponse checks that signature is a base64 encoded signature over the
+// pending challenge nonce made with the authentication key of the did. A
+// successful response consumes the challenge so it cannot be replayed
+func (s *DidContract) VerifyAuthResponse(ctx contractapi.TransactionContextInterface, didNumber string, signature string) (bool, error) {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return false, err
+	}
+
+	key, err := challengeKey(ctx, didNumber)
+
+	if err != nil {
+		return false, err
+	}
+
+	challengeAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if challengeAsBytes == nil {
+		return false, newError(codeNotFound, "%s has no pending authentication challenge", didNumber)
+	}
+
+	challenge := new(AuthChallenge)
+
+	if err := unmarshalRecord(key, challengeAsBytes, challenge); err != nil {
+		return false, err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return false, err
+	}
+
+	expires, err := time.Parse(time.RFC3339Nano, challenge.Expires)
+
+	if err != nil || now.After(expires) {
+		return false, newError(codeChallengeExpired, "Authentication challenge of %s has expired", didNumber)
+	}
+
+	signatureAsBytes, err := base64.StdEncoding.DecodeString(signature)
+
+	if err != nil {
+		return false, newError(codeInvalidSignature, "Signature must be base64 encoded. %s", err.Error())
+	}
+
+	publicKey, err := currentPublicKey(ctx, didNumber, did)
+
+	if err != nil {
+		return false, err
+	}
+
+	if err := verifySignature(publicKey, []byte(challenge.Nonce), signatureAsBytes); err != nil {
+		return false, nil
+	}
+
+	err = ctx.GetStub().DelState(key)
+
+	if err != nil {
+		return false, fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	if err := putAuditRecord(ctx, didNumber); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
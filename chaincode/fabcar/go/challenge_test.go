@@ -0,0 +1,85 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAuthChallenge(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	challenge, err := s.CreateAuthChallenge(l.ctx, id)
+	require.NoError(t, err, "should create a challenge")
+	assert.Equal(t, hashValue(id+"tx2"), challenge.Nonce, "should derive the nonce from the transaction id")
+	assert.Equal(t, testStart.Add(2*time.Second+challengeTTL).Format(time.RFC3339Nano), challenge.Expires, "should expire after the challenge TTL")
+
+	key, err := challengeKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.NotNil(t, l.state[key], "should store the challenge under a composite key")
+
+	_, err = s.CreateAuthChallenge(l.ctx, "DID9")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.stub.GetTxTimestampReturns(nil, errors.New("GetTxTimestamp error"))
+	_, err = s.CreateAuthChallenge(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read transaction timestamp. GetTxTimestamp error", "should need the transaction timestamp")
+}
+
+func TestVerifyAuthResponse(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	_, err := s.VerifyAuthResponse(l.ctx, id, "")
+	assertErrorCode(t, err, codeNotFound, "should require a pending challenge")
+
+	challenge, err := s.CreateAuthChallenge(l.ctx, id)
+	require.NoError(t, err)
+	l.nextTx()
+
+	_, err = s.VerifyAuthResponse(l.ctx, id, "not base64!")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a base64 signature")
+
+	verified, err := s.VerifyAuthResponse(l.ctx, id, newTestKey(t).sign(t, []byte(challenge.Nonce)))
+	assert.Nil(t, err, "should not fail for wrong signatures")
+	assert.False(t, verified, "should not verify signatures of other keys")
+
+	verified, err = s.VerifyAuthResponse(l.ctx, id, key.sign(t, []byte(challenge.Nonce)))
+	assert.Nil(t, err, "should verify the response")
+	assert.True(t, verified, "should verify signatures of the authentication key")
+
+	_, err = s.VerifyAuthResponse(l.ctx, id, key.sign(t, []byte(challenge.Nonce)))
+	assertErrorCode(t, err, codeNotFound, "should consume the challenge")
+
+	challenge, err = s.CreateAuthChallenge(l.ctx, id)
+	require.NoError(t, err)
+	l.setTime(testStart.Add(time.Hour))
+
+	_, err = s.VerifyAuthResponse(l.ctx, id, key.sign(t, []byte(challenge.Nonce)))
+	assertErrorCode(t, err, codeChallengeExpired, "should reject expired challenges")
+}
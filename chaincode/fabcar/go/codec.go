@@ -0,0 +1,281 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// CorruptRecordError is returned when a record read from the ledger cannot be
+// decoded into the type it is stored as. It is reported with codeCorruptRecord
+type CorruptRecordError struct {
+	Key string
+	Err error
+}
+
+func (e *CorruptRecordError) Error() string {
+	return newError(codeCorruptRecord, "Record %s is corrupt. %s", e.Key, e.Err.Error()).Error()
+}
+
+func (e *CorruptRecordError) Unwrap() error {
+	return e.Err
+}
+
+// printableKey returns a key with the separators of composite keys replaced, for
+// use in error messages
+func printableKey(key string) string {
+	return strings.TrimSpace(strings.ReplaceAll(key, "\x00", " "))
+}
+
+// decodeStrict decodes a single JSON value into value, rejecting members value
+// does not define and any data following the value
+func decodeStrict(data []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("Unexpected data after JSON value")
+	}
+
+	return nil
+}
+
+// schemaVersionMember is the member of stored records holding the version of
+// the format they are stored in
+const schemaVersionMember = "schemaVersion"
+
+// recordMigration up-converts the JSON members of a stored record from one
+// schema version to the next. Migrations work on the members rather than on the
+// record type so that they can read members later versions remove or rename
+type recordMigration func(record map[string]json.RawMessage) error
+
+// recordSchema describes the current version of the format a type of record is
+// stored in and the migrations of its earlier versions, indexed by the version
+// they convert from
+type recordSchema struct {
+	version    int
+	migrations map[int]recordMigration
+}
+
+// recordSchemas are the schemas of the record types whose format changed.
+// Records of other types are stored in version 1
+var recordSchemas = map[reflect.Type]recordSchema{
+	reflect.TypeOf(Did{}): {version: didSchemaVersion, migrations: didMigrations},
+}
+
+// schemaOf returns the schema of the records value is stored as. Only structs
+// are versioned
+func schemaOf(value interface{}) (recordSchema, bool) {
+	recordType := reflect.TypeOf(value)
+
+	for recordType != nil && recordType.Kind() == reflect.Ptr {
+		recordType = recordType.Elem()
+	}
+
+	if recordType == nil || recordType.Kind() != reflect.Struct {
+		return recordSchema{}, false
+	}
+
+	if schema, ok := recordSchemas[recordType]; ok {
+		return schema, true
+	}
+
+	return recordSchema{version: 1}, true
+}
+
+// migrateRecord returns the members of the record stored under key, without
+// its schema version, up-converted to the current version of schema, and the
+// version it is stored in. Records stored before versioning are of version 1
+func migrateRecord(key string, data []byte, schema recordSchema) ([]byte, int, error) {
+	record := map[string]json.RawMessage{}
+
+	if err := decodeStrict(data, &record); err != nil {
+		return nil, 0, &CorruptRecordError{Key: printableKey(key), Err: err}
+	}
+
+	version := 1
+
+	if member, ok := record[schemaVersionMember]; ok {
+		if err := json.Unmarshal(member, &version); err != nil || version < 1 {
+			return nil, 0, &CorruptRecordError{Key: printableKey(key), Err: fmt.Errorf("Invalid schema version %s", member)}
+		}
+
+		delete(record, schemaVersionMember)
+	}
+
+	if version > schema.version {
+		return nil, 0, &CorruptRecordError{Key: printableKey(key), Err: fmt.Errorf("Schema version %d is newer than version %d of the chaincode", version, schema.version)}
+	}
+
+	for from := version; from < schema.version; from++ {
+		migration, ok := schema.migrations[from]
+
+		if !ok {
+			return nil, 0, fmt.Errorf("No migration of %s from schema version %d", printableKey(key), from)
+		}
+
+		if err := migration(record); err != nil {
+			return nil, 0, &CorruptRecordError{Key: printableKey(key), Err: err}
+		}
+	}
+
+	migrated, err := json.Marshal(record)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return migrated, version, nil
+}
+
+// marshalRecord encodes a record to be stored under key as canonical JSON, see
+// canonicalJSON. Structs are stored with the schema version of their type
+func marshalRecord(key string, value interface{}) ([]byte, error) {
+	schema, versioned := schemaOf(value)
+
+	if reflect.ValueOf(value).Kind() == reflect.Ptr && reflect.ValueOf(value).IsNil() {
+		versioned = false
+	}
+
+	if versioned {
+		valueAsBytes, err := json.Marshal(value)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to encode %s. %s", printableKey(key), err.Error())
+		}
+
+		record := map[string]json.RawMessage{}
+
+		if err := json.Unmarshal(valueAsBytes, &record); err != nil {
+			return nil, fmt.Errorf("Failed to encode %s. %s", printableKey(key), err.Error())
+		}
+
+		record[schemaVersionMember] = json.RawMessage(strconv.Itoa(schema.version))
+		value = record
+	}
+
+	valueAsBytes, err := canonicalJSON(value)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode %s. %s", printableKey(key), err.Error())
+	}
+
+	return valueAsBytes, nil
+}
+
+// compressedRecordPrefix marks records stored gzip compressed. Records are
+// otherwise JSON, which never starts with it
+var compressedRecordPrefix = []byte{0x00, 'g', 'z'}
+
+// compressionThreshold is the size in bytes above which did documents are
+// stored compressed
+const compressionThreshold = 8192
+
+// compressRecord returns the encoded record to be stored under key gzip
+// compressed behind compressedRecordPrefix if it is larger than
+// compressionThreshold, and unchanged otherwise or if compression does not make
+// it smaller. CouchDB stores compressed records as attachments, which rich
+// queries such as SearchDids do not match, so only did documents are
+// compressed. The gzip header carries no time or host, so peers building the
+// chaincode with the same Go release write the same value
+func compressRecord(key string, data []byte) ([]byte, error) {
+	if len(data) <= compressionThreshold {
+		return data, nil
+	}
+
+	buffer := bytes.NewBuffer(append([]byte{}, compressedRecordPrefix...))
+	writer := gzip.NewWriter(buffer)
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, fmt.Errorf("Failed to compress %s. %s", printableKey(key), err.Error())
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("Failed to compress %s. %s", printableKey(key), err.Error())
+	}
+
+	if buffer.Len() >= len(data) {
+		return data, nil
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// decompressRecord returns the record stored under key decompressed if it was
+// stored by compressRecord, and unchanged otherwise
+func decompressRecord(key string, data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, compressedRecordPrefix) {
+		return data, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data[len(compressedRecordPrefix):]))
+
+	if err != nil {
+		return nil, &CorruptRecordError{Key: printableKey(key), Err: err}
+	}
+	defer reader.Close()
+
+	decompressed, err := ioutil.ReadAll(reader)
+
+	if err != nil {
+		return nil, &CorruptRecordError{Key: printableKey(key), Err: err}
+	}
+
+	return decompressed, nil
+}
+
+// unmarshalRecord decodes a record stored under key, returning a
+// CorruptRecordError if it is not a valid record of the type of value.
+// Compressed records are decompressed first, structs are read according to the
+// schema version they are stored in, see migrateRecord
+func unmarshalRecord(key string, data []byte, value interface{}) error {
+	data, err := decompressRecord(key, data)
+
+	if err != nil {
+		return err
+	}
+
+	if schema, versioned := schemaOf(value); versioned {
+		migrated, _, err := migrateRecord(key, data, schema)
+
+		if err != nil {
+			return err
+		}
+
+		data = migrated
+	}
+
+	if err := decodeStrict(data, value); err != nil {
+		return &CorruptRecordError{Key: printableKey(key), Err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ConsentContract records the consent of did subjects to the processing of
+// their data by controllers identified by dids
+type ConsentContract struct {
+	contractapi.Contract
+	AdminAccess
+}
+
+// consentObjectType is the composite key object type under which consent
+// records are stored, keyed by consent id
+const consentObjectType = "consent"
+
+// Names of the composite key indexes used to look up consent records by the
+// subject and the controller they were given to
+const (
+	consentSubjectIndex    = "subject~consent"
+	consentControllerIndex = "controller~consent"
+)
+
+// Consent describes the consent of a subject did to the processing of its data
+// by a controller did for a purpose. Each grant is a separate record identified
+// by the transaction that recorded it, so revoked and expired consents remain
+// as evidence. RevokedBy is set once the subject has revoked the consent
+type Consent struct {
+	ConsentId     string           `json:"consentId"`
+	SubjectDid    string           `json:"subjectDid"`
+	ControllerDid string           `json:"controllerDid"`
+	Purpose       string           `json:"purpose"`
+	Expires       string           `json:"expires,omitempty" metadata:"expires,optional"`
+	GrantedBy     *ProvenanceEntry `json:"grantedBy"`
+	RevokedBy     *ProvenanceEntry `json:"revokedBy,omitempty" metadata:"revokedBy,optional"`
+}
+
+// isActive reports whether the consent was neither revoked nor expired at now
+func (c *Consent) isActive(now time.Time) bool {
+	if c.RevokedBy != nil {
+		return false
+	}
+
+	if c.Expires == "" {
+		return true
+	}
+
+	expiry, err := time.Parse(time.RFC3339, c.Expires)
+
+	return err == nil && expiry.After(now)
+}
+
+// consentKey returns the key of the consent with given id
+func consentKey(ctx contractapi.TransactionContextInterface, consentId string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(consentObjectType, []string{consentId})
+}
+
+// putConsent writes consent to the world state
+func putConsent(ctx contractapi.TransactionContextInterface, consent *Consent) error {
+	key, err := consentKey(ctx, consent.ConsentId)
+
+	if err != nil {
+		return err
+	}
+
+	consentAsBytes, err := marshalRecord(key, consent)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, consentAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// findActiveConsent returns the consent of subjectDid to controllerDid for
+// purpose that is active at the time of the transaction, or nil if there is none
+func findActiveConsent(ctx contractapi.TransactionContextInterface, subjectDid string, controllerDid string, purpose string) (*Consent, error) {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	consents, err := queryConsentsByIndex(ctx, consentSubjectIndex, subjectDid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, consent := range consents {
+		if consent.ControllerDid == controllerDid && consent.Purpose == purpose && consent.isActive(now) {
+			return consent, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// GrantConsent records the consent of a subject did, controlled by the
+// submitting client, to the processing of its data by a controller did for a
+// purpose, until expires if it is not empty, and returns the consent record.
+// Only one consent may be active for a subject, controller and purpose, and
+// only one consent may be granted per transaction
+func (c *ConsentContract) GrantConsent(ctx contractapi.TransactionContextInterface, subjectDid string, controllerDid string, purpose string,
+	expires string) (*Consent, error) {
+	if purpose == "" {
+		return nil, newError(codeInvalidArgument, "purpose must not be empty")
+	}
+
+	if err := validateExpires(ctx, expires); err != nil {
+		return nil, err
+	}
+
+	if err := assertActiveIssuerController(ctx, subjectDid); err != nil {
+		return nil, err
+	}
+
+	controller, err := findDidById(ctx, controllerDid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if controller.Record.Deactivated {
+		return nil, newError(codeDidDeactivated, "%s is deactivated", controllerDid)
+	}
+
+	active, err := findActiveConsent(ctx, subjectDid, controllerDid, purpose)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if active != nil {
+		return nil, newError(codeAlreadyExists, "%s already consented to %s for %s in %s", subjectDid, controllerDid, purpose, active.ConsentId)
+	}
+
+	grantedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := consentKey(ctx, grantedBy.TxID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if existing != nil {
+		return nil, newError(codeConflict, "Transaction %s already granted a consent", grantedBy.TxID)
+	}
+
+	consent := Consent{
+		ConsentId:     grantedBy.TxID,
+		SubjectDid:    subjectDid,
+		ControllerDid: controllerDid,
+		Purpose:       purpose,
+		Expires:       expires,
+		GrantedBy:     grantedBy,
+	}
+
+	if err := putConsent(ctx, &consent); err != nil {
+		return nil, err
+	}
+
+	err = putIndexEntry(ctx, consentSubjectIndex, subjectDid, consent.ConsentId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = putIndexEntry(ctx, consentControllerIndex, controllerDid, consent.ConsentId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &consent, nil
+}
+
+// RevokeConsent revokes a consent. The submitting client must control the
+// subject did of the consent, which may have been deactivated since. The
+// consent record is kept with the revocation
+func (c *ConsentContract) RevokeConsent(ctx contractapi.TransactionContextInterface, consentId string) (*Consent, error) {
+	consent, err := getConsent(ctx, consentId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertIssuerController(ctx, consent.SubjectDid); err != nil {
+		return nil, err
+	}
+
+	if consent.RevokedBy != nil {
+		return nil, newError(codeConflict, "Consent %s was already revoked", consentId)
+	}
+
+	consent.RevokedBy, err = newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := putConsent(ctx, consent); err != nil {
+		return nil, err
+	}
+
+	return consent, nil
+}
+
+// QueryConsent returns the consent recorded with given id
+func (c *ConsentContract) QueryConsent(ctx contractapi.TransactionContextInterface, consentId string) (*Consent, error) {
+	return getConsent(ctx, consentId)
+}
+
+// getConsent reads the consent recorded with given id
+func getConsent(ctx contractapi.TransactionContextInterface, consentId string) (*Consent, error) {
+	key, err := consentKey(ctx, consentId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	consentAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if consentAsBytes == nil {
+		return nil, newError(codeNotFound, "Consent %s does not exist", consentId)
+	}
+
+	consent := new(Consent)
+
+	if err := unmarshalRecord(key, consentAsBytes, consent); err != nil {
+		return nil, err
+	}
+
+	return consent, nil
+}
+
+// QueryConsentsBySubject returns all consents given by the subject did,
+// including revoked and expired ones
+func (c *ConsentContract) QueryConsentsBySubject(ctx contractapi.TransactionContextInterface, subjectDid string) ([]*Consent, error) {
+	return queryConsentsByIndex(ctx, consentSubjectIndex, subjectDid)
+}
+
+// QueryConsentsByController returns all consents given to the controller did,
+// including revoked and expired ones
+func (c *ConsentContract) QueryConsentsByController(ctx contractapi.TransactionContextInterface, controllerDid string) ([]*Consent, error) {
+	return queryConsentsByIndex(ctx, consentControllerIndex, controllerDid)
+}
+
+// HasConsent reports whether the subject did has an active consent to the
+// processing of its data by the controller did for purpose
+func (c *ConsentContract) HasConsent(ctx contractapi.TransactionContextInterface, subjectDid string, controllerDid string, purpose string) (bool, error) {
+	consent, err := findActiveConsent(ctx, subjectDid, controllerDid, purpose)
+
+	if err != nil {
+		return false, err
+	}
+
+	return consent != nil, nil
+}
+
+// queryConsentsByIndex returns the consents referenced by the given index
+// entries for a did
+func queryConsentsByIndex(ctx contractapi.TransactionContextInterface, index string, did string) ([]*Consent, error) {
+	consentIds, err := queryIndexEntries(ctx, index, did)
+
+	if err != nil {
+		return nil, err
+	}
+
+	consents := []*Consent{}
+
+	for _, consentId := range consentIds {
+		consent, err := getConsent(ctx, consentId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		consents = append(consents, consent)
+	}
+
+	return consents, nil
+}
@@ -0,0 +1,158 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGrantConsent(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(ConsentContract)
+	subject := createTestDid(t, l, newTestKey(t))
+	controller := createTestDid(t, l, newTestKey(t))
+
+	consent, err := c.GrantConsent(l.ctx, subject, controller, "marketing", "")
+	require.NoError(t, err, "should record the consent")
+	assert.Equal(t, l.stub.GetTxID(), consent.ConsentId, "should identify the consent by its transaction")
+	assert.Equal(t, testClientID, consent.GrantedBy.ClientID, "should record the granting client")
+	l.nextTx()
+
+	stored, err := c.QueryConsent(l.ctx, consent.ConsentId)
+	require.NoError(t, err)
+	assert.Equal(t, consent, stored, "should store the consent")
+
+	granted, err := c.HasConsent(l.ctx, subject, controller, "marketing")
+	require.NoError(t, err)
+	assert.True(t, granted, "should report active consents")
+
+	granted, err = c.HasConsent(l.ctx, subject, controller, "analytics")
+	require.NoError(t, err)
+	assert.False(t, granted, "should only report consents for the purpose")
+
+	_, err = c.GrantConsent(l.ctx, subject, controller, "marketing", "")
+	assertErrorCode(t, err, codeAlreadyExists, "should reject a second active consent for the purpose")
+
+	expires := testStart.Add(time.Hour).Format(time.RFC3339)
+	_, err = c.GrantConsent(l.ctx, subject, controller, "analytics", expires)
+	require.NoError(t, err, "should record consents that expire")
+
+	_, err = c.GrantConsent(l.ctx, subject, controller, "support", "")
+	assertErrorCode(t, err, codeConflict, "should grant one consent per transaction")
+
+	l.nextTx()
+	l.setTime(testStart.Add(2 * time.Hour))
+	granted, err = c.HasConsent(l.ctx, subject, controller, "analytics")
+	require.NoError(t, err)
+	assert.False(t, granted, "should not report expired consents")
+
+	_, err = c.GrantConsent(l.ctx, subject, controller, "analytics", "")
+	require.NoError(t, err, "should accept a new consent once the previous one expired")
+	l.nextTx()
+
+	_, err = c.GrantConsent(l.ctx, subject, controller, "", "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a purpose")
+
+	_, err = c.GrantConsent(l.ctx, subject, controller, "support", "tomorrow")
+	assertErrorCode(t, err, codeInvalidArgument, "should require RFC 3339 expiries")
+
+	_, err = c.GrantConsent(l.ctx, subject, didMethodPrefix+"unknown", "support", "")
+	assertErrorCode(t, err, codeDidNotFound, "should require a registered controller")
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = c.GrantConsent(l.ctx, subject, controller, "support", "")
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller of the subject grant consent")
+}
+
+func TestRevokeConsent(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(ConsentContract)
+	subject := createTestDid(t, l, newTestKey(t))
+	controller := createTestDid(t, l, newTestKey(t))
+
+	consent, err := c.GrantConsent(l.ctx, subject, controller, "marketing", "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = c.RevokeConsent(l.ctx, consent.ConsentId)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the subject revoke consent")
+
+	l.setClient(testClientID, testMSPID)
+	revoked, err := c.RevokeConsent(l.ctx, consent.ConsentId)
+	require.NoError(t, err, "should revoke the consent")
+	require.NotNil(t, revoked.RevokedBy, "should record the revocation")
+	assert.Equal(t, l.stub.GetTxID(), revoked.RevokedBy.TxID, "should record the revoking transaction")
+	l.nextTx()
+
+	granted, err := c.HasConsent(l.ctx, subject, controller, "marketing")
+	require.NoError(t, err)
+	assert.False(t, granted, "should not report revoked consents")
+
+	_, err = c.RevokeConsent(l.ctx, consent.ConsentId)
+	assertErrorCode(t, err, codeConflict, "should not revoke a consent twice")
+
+	_, err = c.RevokeConsent(l.ctx, "unknown")
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown consents")
+
+	_, err = c.GrantConsent(l.ctx, subject, controller, "marketing", "")
+	require.NoError(t, err, "should accept a new consent after a revocation")
+}
+
+func TestQueryConsents(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(ConsentContract)
+	subject := createTestDid(t, l, newTestKey(t))
+	controller := createTestDid(t, l, newTestKey(t))
+	other := createTestDid(t, l, newTestKey(t))
+
+	first, err := c.GrantConsent(l.ctx, subject, controller, "marketing", "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	second, err := c.GrantConsent(l.ctx, subject, other, "marketing", "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	_, err = c.RevokeConsent(l.ctx, first.ConsentId)
+	require.NoError(t, err)
+	l.nextTx()
+
+	consents, err := c.QueryConsentsBySubject(l.ctx, subject)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{first.ConsentId, second.ConsentId}, []string{consents[0].ConsentId, consents[1].ConsentId}, "should return every consent of the subject")
+
+	consents, err = c.QueryConsentsByController(l.ctx, controller)
+	require.NoError(t, err)
+	require.Len(t, consents, 1, "should return the consents given to the controller")
+	assert.NotNil(t, consents[0].RevokedBy, "should include revoked consents")
+
+	consents, err = c.QueryConsentsByController(l.ctx, subject)
+	require.NoError(t, err)
+	assert.Empty(t, consents, "should return no consents for controllers without any")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = c.QueryConsent(l.ctx, first.ConsentId)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
@@ -0,0 +1,212 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// credentialObjectType is the composite key object type under which credential
+// issuance records are stored
+const credentialObjectType = "credential"
+
+// credentialIssuerIndex and credentialSubjectIndex name the composite key indexes
+// used to look up credentials by issuer and subject did
+const (
+	credentialIssuerIndex  = "issuer~credential"
+	credentialSubjectIndex = "subject~credential"
+)
+
+// CredentialContract provides functions for recording verifiable credential issuances
+type CredentialContract struct {
+	contractapi.Contract
+	AdminAccess
+}
+
+// Credential describes the issuance of a verifiable credential. Only the hash of
+// the credential is recorded, the credential itself stays with its holder
+type Credential struct {
+	CredentialId   string           `json:"credentialId"`
+	IssuerDid      string           `json:"issuerDid"`
+	SubjectDid     string           `json:"subjectDid"`
+	Schema         string           `json:"schema"`
+	IssuanceDate   string           `json:"issuanceDate"`
+	CredentialHash string           `json:"credentialHash"`
+	RecordedBy     *ProvenanceEntry `json:"recordedBy"`
+}
+
+// credentialKey returns the key of the credential with given id
+func credentialKey(ctx contractapi.TransactionContextInterface, credentialId string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(credentialObjectType, []string{credentialId})
+}
+
+// IssueCredential records the issuance of a credential. The issuer did must be
+// registered, controlled by the submitting client, not deactivated and
+// accredited for the schema of the credential
+func (c *CredentialContract) IssueCredential(ctx contractapi.TransactionContextInterface, credentialId string, issuerDid string, subjectDid string,
+	schema string, issuanceDate string, credentialHash string) error {
+	if credentialId == "" || subjectDid == "" || credentialHash == "" {
+		return newError(codeInvalidArgument, "credentialId, subjectDid and credentialHash must not be empty")
+	}
+
+	if _, err := time.Parse(time.RFC3339, issuanceDate); err != nil {
+		return newError(codeInvalidArgument, "issuanceDate must be an RFC3339 date. %s", err.Error())
+	}
+
+	issuer, err := findDidById(ctx, issuerDid)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, issuer.Key, issuer.Record); err != nil {
+		return err
+	}
+
+	if issuer.Record.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", issuerDid)
+	}
+
+	accredited, err := isAccredited(ctx, issuerDid, schema)
+
+	if err != nil {
+		return err
+	}
+
+	if !accredited {
+		return newError(codeUnauthorized, "%s is not accredited to issue %s credentials", issuerDid, schema)
+	}
+
+	key, err := credentialKey(ctx, credentialId)
+
+	if err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if existing != nil {
+		return newError(codeAlreadyExists, "Credential %s already exists", credentialId)
+	}
+
+	recordedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	credential := Credential{
+		CredentialId:   credentialId,
+		IssuerDid:      issuerDid,
+		SubjectDid:     subjectDid,
+		Schema:         schema,
+		IssuanceDate:   issuanceDate,
+		CredentialHash: credentialHash,
+		RecordedBy:     recordedBy,
+	}
+
+	credentialAsBytes, err := marshalRecord(key, credential)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, credentialAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = putIndexEntry(ctx, credentialIssuerIndex, issuerDid, credentialId)
+
+	if err != nil {
+		return err
+	}
+
+	return putIndexEntry(ctx, credentialSubjectIndex, subjectDid, credentialId)
+}
+
+// QueryCredential returns the credential issuance recorded with given id
+func (c *CredentialContract) QueryCredential(ctx contractapi.TransactionContextInterface, credentialId string) (*Credential, error) {
+	key, err := credentialKey(ctx, credentialId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credentialAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if credentialAsBytes == nil {
+		return nil, newError(codeNotFound, "Credential %s does not exist", credentialId)
+	}
+
+	credential := new(Credential)
+
+	if err := unmarshalRecord(key, credentialAsBytes, credential); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// QueryCredentialsByIssuer returns all credential issuances recorded for the given issuer did
+func (c *CredentialContract) QueryCredentialsByIssuer(ctx contractapi.TransactionContextInterface, issuerDid string) ([]*Credential, error) {
+	return c.queryCredentialsByIndex(ctx, credentialIssuerIndex, issuerDid)
+}
+
+// QueryCredentialsBySubject returns all credential issuances recorded for the given subject did
+func (c *CredentialContract) QueryCredentialsBySubject(ctx contractapi.TransactionContextInterface, subjectDid string) ([]*Credential, error) {
+	return c.queryCredentialsByIndex(ctx, credentialSubjectIndex, subjectDid)
+}
+
+// queryCredentialsByIndex returns the credentials referenced by the given index
+// entries for a did
+func (c *CredentialContract) queryCredentialsByIndex(ctx contractapi.TransactionContextInterface, index string, did string) ([]*Credential, error) {
+	credentialIds, err := queryIndexEntries(ctx, index, did)
+
+	if err != nil {
+		return nil, err
+	}
+
+	credentials := []*Credential{}
+
+	for _, credentialId := range credentialIds {
+		credential, err := c.QueryCredential(ctx, credentialId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		credentials = append(credentials, credential)
+	}
+
+	return credentials, nil
+}
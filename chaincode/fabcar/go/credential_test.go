@@ -0,0 +1,142 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testIssuanceDate = "2020-06-01T12:00:00Z"
+
+// accreditedIssuer creates a did accredited for testCredentialType by an administrator
+func accreditedIssuer(t *testing.T, l *testLedger, key *testKey) string {
+	issuer := createTestDid(t, l, key)
+
+	l.setAdmin(true)
+	require.NoError(t, new(CredentialContract).AccreditIssuer(l.ctx, issuer, testCredentialType, ""))
+	l.setAdmin(false)
+
+	return issuer
+}
+
+func TestIssueCredential(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuer := accreditedIssuer(t, l, newTestKey(t))
+	subject := createTestDid(t, l, newTestKey(t))
+
+	err := c.IssueCredential(l.ctx, "urn:uuid:1", issuer, subject, testCredentialType, testIssuanceDate, "hash1")
+	require.NoError(t, err, "should record the credential")
+
+	credential, err := c.QueryCredential(l.ctx, "urn:uuid:1")
+	require.NoError(t, err, "should return the credential")
+	assert.Equal(t, issuer, credential.IssuerDid, "should record the issuer")
+	assert.Equal(t, "hash1", credential.CredentialHash, "should record the credential hash")
+	assert.Equal(t, testClientID, credential.RecordedBy.ClientID, "should record the issuing client")
+
+	err = c.IssueCredential(l.ctx, "urn:uuid:1", issuer, subject, testCredentialType, testIssuanceDate, "hash1")
+	assertErrorCode(t, err, codeAlreadyExists, "should not record a credential id twice")
+
+	err = c.IssueCredential(l.ctx, "", issuer, subject, testCredentialType, testIssuanceDate, "hash2")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a credential id")
+
+	err = c.IssueCredential(l.ctx, "urn:uuid:2", issuer, subject, testCredentialType, "yesterday", "hash2")
+	assertErrorCode(t, err, codeInvalidArgument, "should require an RFC3339 issuance date")
+
+	err = c.IssueCredential(l.ctx, "urn:uuid:2", issuer, subject, "OtherCredential", testIssuanceDate, "hash2")
+	assertErrorCode(t, err, codeUnauthorized, "should require the issuer to be accredited for the schema")
+
+	err = c.IssueCredential(l.ctx, "urn:uuid:2", "did:example:unknown", subject, testCredentialType, testIssuanceDate, "hash2")
+	assertErrorCode(t, err, codeDidNotFound, "should require a registered issuer")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = c.IssueCredential(l.ctx, "urn:uuid:2", issuer, subject, testCredentialType, testIssuanceDate, "hash2")
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the issuer")
+}
+
+func TestIssueCredentialDeactivated(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	c := new(CredentialContract)
+	key := newTestKey(t)
+	issuer := accreditedIssuer(t, l, key)
+	subject := createTestDid(t, l, newTestKey(t))
+
+	did, err := s.QueryDidByKey(l.ctx, issuer)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, issuer, signUpdate(t, l, key, issuer, &update)))
+	l.nextTx()
+
+	err = c.IssueCredential(l.ctx, "urn:uuid:1", issuer, subject, testCredentialType, testIssuanceDate, "hash1")
+	assertErrorCode(t, err, codeDidDeactivated, "should not record credentials of deactivated issuers")
+}
+
+func TestQueryCredential(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+
+	_, err := c.QueryCredential(l.ctx, "urn:uuid:9")
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown credentials")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = c.QueryCredential(l.ctx, "urn:uuid:9")
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestQueryCredentialsByIssuerAndSubject(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuer := accreditedIssuer(t, l, newTestKey(t))
+	first := createTestDid(t, l, newTestKey(t))
+	second := createTestDid(t, l, newTestKey(t))
+
+	require.NoError(t, c.IssueCredential(l.ctx, "urn:uuid:1", issuer, first, testCredentialType, testIssuanceDate, "hash1"))
+	require.NoError(t, c.IssueCredential(l.ctx, "urn:uuid:2", issuer, second, testCredentialType, testIssuanceDate, "hash2"))
+
+	credentials, err := c.QueryCredentialsByIssuer(l.ctx, issuer)
+	require.NoError(t, err, "should return the credentials of the issuer")
+	require.Len(t, credentials, 2, "should return every credential of the issuer")
+	assert.Equal(t, "urn:uuid:1", credentials[0].CredentialId, "should return the credentials in id order")
+
+	credentials, err = c.QueryCredentialsBySubject(l.ctx, second)
+	require.NoError(t, err, "should return the credentials of the subject")
+	require.Len(t, credentials, 1, "should only return credentials of the subject")
+	assert.Equal(t, "urn:uuid:2", credentials[0].CredentialId, "should return the credential of the subject")
+
+	credentials, err = c.QueryCredentialsBySubject(l.ctx, issuer)
+	assert.Nil(t, err, "should not fail for dids without credentials")
+	assert.Empty(t, credentials, "should return no credentials")
+
+	iterator := failingStateIterator(errors.New("Next error"))
+	l.stub.GetStateByPartialCompositeKeyReturns(iterator, nil)
+	_, err = c.QueryCredentialsByIssuer(l.ctx, issuer)
+	assert.EqualError(t, err, "Next error", "should return iterator errors")
+	assert.Equal(t, 1, iterator.CloseCallCount(), "should close the iterator")
+
+	l.stub.GetStateByPartialCompositeKeyReturns(nil, errors.New("GetStateByPartialCompositeKey error"))
+	_, err = c.QueryCredentialsBySubject(l.ctx, first)
+	assert.EqualError(t, err, "GetStateByPartialCompositeKey error", "should return query errors")
+}
@@ -0,0 +1,199 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// credentialDefinitionObjectType is the composite key object type under which
+// AnonCreds credential definitions are stored
+const credentialDefinitionObjectType = "credentialdefinition"
+
+// credentialDefinitionSignatureType is the only signature type of AnonCreds
+// credential definitions, Camenisch-Lysyanskaya signatures
+const credentialDefinitionSignatureType = "CL"
+
+// CredentialDefinitionValue holds the public keys of an AnonCreds credential
+// definition. Revocation is only present for definitions that support revocation
+type CredentialDefinitionValue struct {
+	Primary    interface{} `json:"primary"`
+	Revocation interface{} `json:"revocation,omitempty" metadata:"revocation,optional"`
+}
+
+// CredentialDefinition describes an AnonCreds credential definition, linking the
+// public keys an issuer did signs credentials of a schema with. RecordedBy is set
+// by the ledger when the definition is registered
+type CredentialDefinition struct {
+	Id         string                    `json:"id"`
+	IssuerId   string                    `json:"issuerId"`
+	SchemaId   string                    `json:"schemaId"`
+	Type       string                    `json:"type"`
+	Tag        string                    `json:"tag"`
+	Value      CredentialDefinitionValue `json:"value"`
+	RecordedBy *ProvenanceEntry          `json:"recordedBy,omitempty" metadata:"recordedBy,optional"`
+}
+
+// credentialDefinitionId returns the identifier of the credential definition of
+// an issuer for a schema and tag, in the AnonCreds did-linked resource format
+func credentialDefinitionId(issuerId string, schemaId string, tag string) string {
+	return issuerId + "/anoncreds/v0/CLAIM_DEF/" + schemaId + "/" + tag
+}
+
+// credentialDefinitionKey returns the key of the credential definition with given id
+func credentialDefinitionKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(credentialDefinitionObjectType, []string{id})
+}
+
+// isJSONObject reports whether value was decoded from a JSON object
+func isJSONObject(value interface{}) bool {
+	_, ok := value.(map[string]interface{})
+
+	return ok
+}
+
+// assertActiveIssuerController returns an error unless the issuer did is registered,
+// not deactivated and controlled by the submitting client
+func assertActiveIssuerController(ctx contractapi.TransactionContextInterface, issuerDid string) error {
+	issuer, err := findDidById(ctx, issuerDid)
+
+	if err != nil {
+		return err
+	}
+
+	if issuer.Record.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", issuerDid)
+	}
+
+	return assertController(ctx, issuer.Key, issuer.Record)
+}
+
+// RegisterCredentialDefinition registers an AnonCreds credential definition of
+// an issuer did controlled by the submitting client and returns its identifier.
+// The definition is given as an AnonCreds credential definition object with the
+// members issuerId, schemaId, type, tag and value
+func (c *CredentialContract) RegisterCredentialDefinition(ctx contractapi.TransactionContextInterface, credentialDefinitionJSON string) (string, error) {
+	var definition CredentialDefinition
+
+	if err := decodeStrict([]byte(credentialDefinitionJSON), &definition); err != nil {
+		return "", newError(codeInvalidArgument, "credentialDefinitionJSON must be an AnonCreds credential definition. %s", err.Error())
+	}
+
+	if definition.Id != "" || definition.RecordedBy != nil {
+		return "", newError(codeInvalidArgument, "id and recordedBy are set by the ledger")
+	}
+
+	if definition.IssuerId == "" || definition.SchemaId == "" || definition.Tag == "" {
+		return "", newError(codeInvalidArgument, "issuerId, schemaId and tag must not be empty")
+	}
+
+	if strings.Contains(definition.Tag, "/") {
+		return "", newError(codeInvalidArgument, "tag must not contain /")
+	}
+
+	if definition.Type != credentialDefinitionSignatureType {
+		return "", newError(codeInvalidArgument, "Unsupported credential definition type %s, expected %s", definition.Type, credentialDefinitionSignatureType)
+	}
+
+	if !isJSONObject(definition.Value.Primary) {
+		return "", newError(codeInvalidArgument, "value.primary must be a JSON object")
+	}
+
+	if definition.Value.Revocation != nil && !isJSONObject(definition.Value.Revocation) {
+		return "", newError(codeInvalidArgument, "value.revocation must be a JSON object")
+	}
+
+	if err := assertActiveIssuerController(ctx, definition.IssuerId); err != nil {
+		return "", err
+	}
+
+	definition.Id = credentialDefinitionId(definition.IssuerId, definition.SchemaId, definition.Tag)
+
+	key, err := credentialDefinitionKey(ctx, definition.Id)
+
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if existing != nil {
+		return "", newError(codeAlreadyExists, "Credential definition %s already exists", definition.Id)
+	}
+
+	definition.RecordedBy, err = newProvenanceEntry(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	definitionAsBytes, err := marshalRecord(key, definition)
+
+	if err != nil {
+		return "", err
+	}
+
+	err = ctx.GetStub().PutState(key, definitionAsBytes)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return definition.Id, nil
+}
+
+// getCredentialDefinition reads the credential definition with given id
+func getCredentialDefinition(ctx contractapi.TransactionContextInterface, credentialDefinitionId string) (*CredentialDefinition, error) {
+	key, err := credentialDefinitionKey(ctx, credentialDefinitionId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	definitionAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if definitionAsBytes == nil {
+		return nil, newError(codeNotFound, "Credential definition %s does not exist", credentialDefinitionId)
+	}
+
+	definition := new(CredentialDefinition)
+
+	if err := unmarshalRecord(key, definitionAsBytes, definition); err != nil {
+		return nil, err
+	}
+
+	return definition, nil
+}
+
+// GetCredentialDefinition returns the AnonCreds credential definition with given id
+func (c *CredentialContract) GetCredentialDefinition(ctx contractapi.TransactionContextInterface, credentialDefinitionId string) (*CredentialDefinition, error) {
+	return getCredentialDefinition(ctx, credentialDefinitionId)
+}
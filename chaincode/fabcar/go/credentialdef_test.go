@@ -0,0 +1,121 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchemaId = "did:example:schemas/anoncreds/v0/SCHEMA/degree/1.0"
+
+// testCredentialDefinition returns a credential definition of issuer in the
+// AnonCreds object format, with revocation keys unless revocation is empty
+func testCredentialDefinition(issuer string, tag string, revocation string) string {
+	value := `{"primary":{"n":"779","s":"750","r":{"master_secret":"521","name":"410"},"rctxt":"774","z":"632"}`
+
+	if revocation != "" {
+		value += `,"revocation":` + revocation
+	}
+
+	return `{"issuerId":"` + issuer + `","schemaId":"` + testSchemaId + `","type":"CL","tag":"` + tag + `","value":` + value + `}}`
+}
+
+func TestRegisterCredentialDefinition(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuer := createTestDid(t, l, newTestKey(t))
+
+	id, err := c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition(issuer, "default", ""))
+	require.NoError(t, err, "should register the credential definition")
+	assert.Equal(t, issuer+"/anoncreds/v0/CLAIM_DEF/"+testSchemaId+"/default", id, "should derive the id from the issuer, schema and tag")
+
+	definition, err := c.GetCredentialDefinition(l.ctx, id)
+	require.NoError(t, err, "should return the credential definition")
+	assert.Equal(t, issuer, definition.IssuerId, "should link the issuer")
+	assert.Equal(t, testSchemaId, definition.SchemaId, "should link the schema")
+	assert.Equal(t, "CL", definition.Type)
+	assert.Equal(t, "779", definition.Value.Primary.(map[string]interface{})["n"], "should keep the public keys")
+	assert.Nil(t, definition.Value.Revocation, "should not add revocation keys")
+	assert.Equal(t, testClientID, definition.RecordedBy.ClientID, "should record the registering client")
+
+	_, err = c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition(issuer, "default", ""))
+	assertErrorCode(t, err, codeAlreadyExists, "should not register a definition twice")
+
+	_, err = c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition(issuer, "revocable", `{"g":"1 1F","h":"25"}`))
+	assert.Nil(t, err, "should accept revocation keys")
+
+	invalid := map[string]string{
+		"should require an object":              `"CL"`,
+		"should reject unknown members":         `{"issuerId":"` + issuer + `","schemaId":"s","type":"CL","tag":"t","value":{"primary":{}},"ver":"1.0"}`,
+		"should not accept ids":                 `{"id":"x","issuerId":"` + issuer + `","schemaId":"s","type":"CL","tag":"t","value":{"primary":{}}}`,
+		"should require a schema":               `{"issuerId":"` + issuer + `","type":"CL","tag":"t","value":{"primary":{}}}`,
+		"should require a tag":                  `{"issuerId":"` + issuer + `","schemaId":"s","type":"CL","value":{"primary":{}}}`,
+		"should reject tags with slashes":       `{"issuerId":"` + issuer + `","schemaId":"s","type":"CL","tag":"a/b","value":{"primary":{}}}`,
+		"should only accept CL signatures":      `{"issuerId":"` + issuer + `","schemaId":"s","type":"BBS","tag":"t","value":{"primary":{}}}`,
+		"should require primary keys":           `{"issuerId":"` + issuer + `","schemaId":"s","type":"CL","tag":"t","value":{}}`,
+		"should require object revocation keys": `{"issuerId":"` + issuer + `","schemaId":"s","type":"CL","tag":"t","value":{"primary":{},"revocation":"1"}}`,
+	}
+
+	for message, definitionJSON := range invalid {
+		_, err = c.RegisterCredentialDefinition(l.ctx, definitionJSON)
+		assertErrorCode(t, err, codeInvalidArgument, message)
+	}
+
+	_, err = c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition("DID9", "default", ""))
+	assertErrorCode(t, err, codeDidNotFound, "should require a registered issuer")
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition(issuer, "other", ""))
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the issuer")
+}
+
+func TestRegisterCredentialDefinitionDeactivated(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	c := new(CredentialContract)
+	key := newTestKey(t)
+	issuer := createTestDid(t, l, key)
+
+	did, err := s.QueryDidByKey(l.ctx, issuer)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, issuer, signUpdate(t, l, key, issuer, &update)))
+	l.nextTx()
+
+	_, err = c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition(issuer, "default", ""))
+	assertErrorCode(t, err, codeDidDeactivated, "should not register definitions of deactivated issuers")
+}
+
+func TestGetCredentialDefinition(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+
+	_, err := c.GetCredentialDefinition(l.ctx, "did:example:1/anoncreds/v0/CLAIM_DEF/s/t")
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown definitions")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = c.GetCredentialDefinition(l.ctx, "did:example:1/anoncreds/v0/CLAIM_DEF/s/t")
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
@@ -0,0 +1,207 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Names of the composite key indexes of dids by the day they were created and
+// updated. Entries are keyed by day, time and did key, so that each day is a
+// bucket that can be read with a partial key query
+const (
+	createdDateIndex = "createdDate~key"
+	updatedDateIndex = "updatedDate~key"
+)
+
+// dateBucketLayout formats the day of a date index entry
+const dateBucketLayout = "2006-01-02"
+
+// maxDateRangeDays is the number of day buckets a date range query may read
+const maxDateRangeDays = 366
+
+// putDateIndexEntry records in index that the did stored with didNumber was
+// written at the time of the transaction
+func putDateIndexEntry(ctx contractapi.TransactionContextInterface, index string, didNumber string) error {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	now = now.UTC()
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{now.Format(dateBucketLayout), now.Format(auditTimeLayout), didNumber})
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// parseRangeBound parses a bound of a date range, given as an RFC 3339
+// timestamp or a date. Dates stand for the start of the day, or for the end
+// of the day if end is set
+func parseRangeBound(name string, value string, end bool) (time.Time, error) {
+	if bound, err := time.Parse(time.RFC3339, value); err == nil {
+		return bound.UTC(), nil
+	}
+
+	bound, err := time.Parse(dateBucketLayout, value)
+
+	if err != nil {
+		return time.Time{}, newError(codeInvalidArgument, "%s %s must be an RFC 3339 timestamp or a date", name, value)
+	}
+
+	if end {
+		bound = bound.AddDate(0, 0, 1)
+	}
+
+	return bound, nil
+}
+
+// queryDidsByDate returns the dids with an entry in the date index between
+// start, inclusive, and end, exclusive. Each did is returned once, in the order
+// of its first entry. Deleted and purged dids are skipped
+func queryDidsByDate(ctx contractapi.TransactionContextInterface, index string, start string, end string) ([]QueryResult, error) {
+	from, err := parseRangeBound("start", start, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseRangeBound("end", end, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !from.Before(to) {
+		return nil, newError(codeInvalidArgument, "start %s must be before end %s", start, end)
+	}
+
+	firstDay := from.Truncate(24 * time.Hour)
+
+	if to.Sub(firstDay) > maxDateRangeDays*24*time.Hour {
+		return nil, newError(codeInvalidArgument, "Date ranges may span at most %d days", maxDateRangeDays)
+	}
+
+	fromKey, toKey := from.Format(auditTimeLayout), to.Format(auditTimeLayout)
+	seen := map[string]bool{}
+	records := []QueryResult{}
+
+	for day := firstDay; day.Before(to); day = day.AddDate(0, 0, 1) {
+		err := scanState(ctx, index, []string{day.Format(dateBucketLayout)}, func(indexKey string, _ []byte) (bool, error) {
+			_, keyParts, err := ctx.GetStub().SplitCompositeKey(indexKey)
+
+			if err != nil {
+				return false, err
+			}
+
+			if len(keyParts) != 3 {
+				return false, newError(codeCorruptRecord, "%s is not a date index entry", printableKey(indexKey))
+			}
+
+			if keyParts[1] < fromKey || keyParts[1] >= toKey || seen[keyParts[2]] {
+				return true, nil
+			}
+
+			seen[keyParts[2]] = true
+			did, err := indexedDid(ctx, keyParts[2])
+
+			if err != nil {
+				return false, err
+			}
+
+			if did == nil {
+				return true, nil
+			}
+
+			if len(records) == maxQueryRecords {
+				return false, newError(codeInvalidArgument, "More than %d dids match, narrow the date range", maxQueryRecords)
+			}
+
+			records = append(records, *newQueryResult(keyParts[2], did))
+
+			return true, nil
+		})
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return records, nil
+}
+
+// indexedDid returns the did stored with didNumber found in an index, or nil
+// if the did was deleted or purged since the index entry was written
+func indexedDid(ctx contractapi.TransactionContextInterface, didNumber string) (*Did, error) {
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	didAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if didAsBytes == nil {
+		return nil, nil
+	}
+
+	did := new(Did)
+
+	if err := unmarshalRecord(key, didAsBytes, did); err != nil {
+		return nil, err
+	}
+
+	if did.Deleted {
+		return nil, nil
+	}
+
+	return did, nil
+}
+
+// QueryDidsCreatedBetween returns the dids created between start, inclusive,
+// and end, exclusive. The bounds are RFC 3339 timestamps or dates, an end date
+// includes the whole day, so 2024-05-01 and 2024-05-31 return the dids created
+// in May 2024. The range may span at most maxDateRangeDays days
+func (s *DidContract) QueryDidsCreatedBetween(ctx contractapi.TransactionContextInterface, start string, end string) ([]QueryResult, error) {
+	return queryDidsByDate(ctx, createdDateIndex, start, end)
+}
+
+// QueryDidsUpdatedBetween returns the dids updated between start and end, see
+// QueryDidsCreatedBetween. Dids updated again since are still returned
+func (s *DidContract) QueryDidsUpdatedBetween(ctx contractapi.TransactionContextInterface, start string, end string) ([]QueryResult, error) {
+	return queryDidsByDate(ctx, updatedDateIndex, start, end)
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// resultKeys returns the keys of query results in order
+func resultKeys(results []QueryResult) []string {
+	keys := []string{}
+
+	for _, result := range results {
+		keys = append(keys, result.Key)
+	}
+
+	return keys
+}
+
+func TestQueryDidsCreatedBetween(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	first := createTestDid(t, l, key)
+	secondKey := newTestKey(t)
+	second := createTestDid(t, l, secondKey)
+
+	l.setTime(testStart.AddDate(0, 0, 2))
+	third := createTestDid(t, l, newTestKey(t))
+
+	results, err := s.QueryDidsCreatedBetween(l.ctx, "2020-06-01", "2020-06-01")
+	require.NoError(t, err, "should query a single day")
+	assert.Equal(t, []string{first, second}, resultKeys(results), "should return the dids created that day in order")
+
+	results, err = s.QueryDidsCreatedBetween(l.ctx, "2020-06-01", "2020-06-30")
+	require.NoError(t, err)
+	assert.Equal(t, []string{first, second, third}, resultKeys(results), "should return the dids created in the month")
+
+	results, err = s.QueryDidsCreatedBetween(l.ctx, testStart.Add(1500*time.Millisecond).Format(time.RFC3339Nano), "2020-06-30")
+	require.NoError(t, err)
+	assert.Equal(t, []string{second, third}, resultKeys(results), "should compare timestamps within a day")
+
+	deleteTestDid(t, l, secondKey, second)
+
+	results, err = s.QueryDidsCreatedBetween(l.ctx, "2020-06-01", "2020-06-30")
+	require.NoError(t, err)
+	assert.Equal(t, []string{first, third}, resultKeys(results), "should skip deleted dids")
+
+	results, err = s.QueryDidsCreatedBetween(l.ctx, "2020-05-01", "2020-05-31")
+	require.NoError(t, err)
+	assert.Empty(t, results, "should return no dids for ranges without registrations")
+
+	_, err = s.QueryDidsCreatedBetween(l.ctx, "2020-06-30", "2020-06-01")
+	assertErrorCode(t, err, codeInvalidArgument, "should require start to be before end")
+
+	_, err = s.QueryDidsCreatedBetween(l.ctx, "last month", "2020-06-01")
+	assertErrorCode(t, err, codeInvalidArgument, "should require timestamps or dates")
+
+	_, err = s.QueryDidsCreatedBetween(l.ctx, "2019-01-01", "2020-06-01")
+	assertErrorCode(t, err, codeInvalidArgument, "should limit the number of days read")
+}
+
+func TestQueryDidsUpdatedBetween(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	createTestDid(t, l, newTestKey(t))
+
+	results, err := s.QueryDidsUpdatedBetween(l.ctx, "2020-06-01", "2020-06-30")
+	require.NoError(t, err)
+	assert.Empty(t, results, "should not index creations as updates")
+
+	l.setTime(testStart.AddDate(0, 0, 1))
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+	l.nextTx()
+	l.setTime(testStart.AddDate(0, 0, 1).Add(time.Hour))
+	update = testUpdate(id, key, "https://example.net/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+
+	results, err = s.QueryDidsUpdatedBetween(l.ctx, "2020-06-02", "2020-06-02")
+	require.NoError(t, err)
+	assert.Equal(t, []string{id}, resultKeys(results), "should return dids updated several times once")
+	assert.Equal(t, "https://example.net/vc/", results[0].Record.ServiceEndPoint, "should return the current did")
+
+	results, err = s.QueryDidsUpdatedBetween(l.ctx, "2020-06-01", "2020-06-01")
+	require.NoError(t, err)
+	assert.Empty(t, results, "should only return dids updated in the range")
+}
@@ -0,0 +1,341 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// delegationObjectType is the composite key object type under which the
+// delegates of dids are stored
+const delegationObjectType = "delegation"
+
+// permissionUpdateService lets a delegate replace the service of a did with
+// UpdateService. Delegates can never change the keys of a did
+const permissionUpdateService = "updateService"
+
+// delegationPermissions are the permissions that may be delegated
+var delegationPermissions = []string{permissionUpdateService}
+
+// Delegation describes the time-limited rights of a delegate did to change
+// another did, similar to the delegates of ERC-1056
+type Delegation struct {
+	DidNumber   string           `json:"didNumber"`
+	Delegate    string           `json:"delegate"`
+	Permissions []string         `json:"permissions"`
+	Expires     string           `json:"expires"`
+	GrantedBy   *ProvenanceEntry `json:"grantedBy"`
+}
+
+// delegationKey returns the key of the delegation of the did stored with given
+// key to delegateDid
+func delegationKey(ctx contractapi.TransactionContextInterface, didNumber string, delegateDid string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(delegationObjectType, []string{didNumber, delegateDid})
+}
+
+// getDelegation reads the delegation of the did stored with given key to
+// delegateDid, returning nil if there is none
+func getDelegation(ctx contractapi.TransactionContextInterface, didNumber string, delegateDid string) (*Delegation, error) {
+	key, err := delegationKey(ctx, didNumber, delegateDid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delegationAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if delegationAsBytes == nil {
+		return nil, nil
+	}
+
+	delegation := new(Delegation)
+
+	if err := unmarshalRecord(key, delegationAsBytes, delegation); err != nil {
+		return nil, err
+	}
+
+	return delegation, nil
+}
+
+// DelegateControl grants delegateDid the permissions, given as a JSON array, to
+// change the did stored with given key until expiry, an RFC 3339 timestamp in the
+// future. Granting them again replaces the earlier delegation. Only the
+// controlling client identity of the did may delegate
+func (s *DidContract) DelegateControl(ctx contractapi.TransactionContextInterface, didNumber string, delegateDid string, permissions string, expiry string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", didNumber)
+	}
+
+	delegate, err := findDidById(ctx, delegateDid)
+
+	if err != nil {
+		return err
+	}
+
+	if delegate.Key == didNumber {
+		return newError(codeInvalidArgument, "%s cannot delegate to itself", didNumber)
+	}
+
+	delegation := Delegation{DidNumber: didNumber, Delegate: delegateDid, Expires: expiry}
+
+	if err := decodeStrict([]byte(permissions), &delegation.Permissions); err != nil {
+		return newError(codeInvalidArgument, "Permissions must be a JSON array. %s", err.Error())
+	}
+
+	if len(delegation.Permissions) == 0 {
+		return newError(codeInvalidArgument, "At least one permission is required")
+	}
+
+	for _, permission := range delegation.Permissions {
+		if !containsString(delegationPermissions, permission) {
+			return newError(codeInvalidArgument, "%s is not a permission that may be delegated, use one of %v", permission, delegationPermissions)
+		}
+	}
+
+	if expiry == "" {
+		return newError(codeInvalidArgument, "Delegations must expire")
+	}
+
+	if err := validateExpires(ctx, expiry); err != nil {
+		return err
+	}
+
+	delegation.GrantedBy, err = newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	key, err := delegationKey(ctx, didNumber, delegateDid)
+
+	if err != nil {
+		return err
+	}
+
+	delegationAsBytes, err := marshalRecord(key, delegation)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, delegationAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// RevokeDelegation removes the delegation of the did stored with given key to
+// delegateDid before it expires. Only the controlling client identity of the did
+// may revoke delegations
+func (s *DidContract) RevokeDelegation(ctx contractapi.TransactionContextInterface, didNumber string, delegateDid string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	delegation, err := getDelegation(ctx, didNumber, delegateDid)
+
+	if err != nil {
+		return err
+	}
+
+	if delegation == nil {
+		return newError(codeNotFound, "%s is not a delegate of %s", delegateDid, didNumber)
+	}
+
+	key, err := delegationKey(ctx, didNumber, delegateDid)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// QueryDelegations returns the delegations of the did stored with given key,
+// including expired ones. Dids with more than maxQueryRecords delegations fail
+func (s *DidContract) QueryDelegations(ctx contractapi.TransactionContextInterface, didNumber string) ([]Delegation, error) {
+	if _, err := s.QueryDidByKey(ctx, didNumber); err != nil {
+		return nil, err
+	}
+
+	delegations := []Delegation{}
+
+	err := scanState(ctx, delegationObjectType, []string{didNumber}, func(key string, value []byte) (bool, error) {
+		if len(delegations) == maxQueryRecords {
+			return false, newTooManyRecordsError("delegations")
+		}
+
+		delegation := Delegation{}
+
+		if err := unmarshalRecord(key, value, &delegation); err != nil {
+			return false, err
+		}
+
+		delegations = append(delegations, delegation)
+
+		return true, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return delegations, nil
+}
+
+// deleteDelegations removes every delegation of the did stored with given key
+func deleteDelegations(ctx contractapi.TransactionContextInterface, didNumber string) error {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(delegationObjectType, []string{didNumber})
+
+	if err != nil {
+		return err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.GetStub().DelState(queryResponse.Key); err != nil {
+			return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// assertDelegate returns the stored delegate did if delegateDid holds an
+// unexpired delegation of permission for the did stored with given key and the
+// submitting client controls it
+func assertDelegate(ctx contractapi.TransactionContextInterface, didNumber string, delegateDid string, permission string) (*QueryResult, error) {
+	delegation, err := getDelegation(ctx, didNumber, delegateDid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if delegation == nil || !containsString(delegation.Permissions, permission) {
+		return nil, newError(codeUnauthorized, "%s may not %s of %s", delegateDid, permission, didNumber)
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	expiry, err := time.Parse(time.RFC3339, delegation.Expires)
+
+	if err != nil || !expiry.After(now) {
+		return nil, newError(codeUnauthorized, "Delegation of %s to %s expired", didNumber, delegateDid)
+	}
+
+	delegate, err := findDidById(ctx, delegateDid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if delegate.Record.Deactivated {
+		return nil, newError(codeDidDeactivated, "%s is deactivated", delegateDid)
+	}
+
+	if err := assertController(ctx, delegate.Key, delegate.Record); err != nil {
+		return nil, err
+	}
+
+	return delegate, nil
+}
+
+// UpdateService replaces the service of a did, keeping its keys. With an empty
+// delegateDid the controlling client identity must submit it and the signature
+// must be made with the current authentication key of the did. Otherwise
+// delegateDid must hold the updateService permission, the submitting client must
+// control it and the signature must be made with its authentication key, both
+// over the update payload of the did with the new service
+func (s *DidContract) UpdateService(ctx contractapi.TransactionContextInterface, didNumber string, serviceId string, serviceType string, serviceEndPoint string,
+	delegateDid string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.ServiceId = serviceId
+	update.ServiceType = serviceType
+	update.ServiceEndPoint = serviceEndPoint
+	update.ServiceEndPointHash = ""
+
+	if delegateDid == "" {
+		return s.updateDid(ctx, didNumber, &update, signature, "")
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", didNumber)
+	}
+
+	delegate, err := assertDelegate(ctx, didNumber, delegateDid, permissionUpdateService)
+
+	if err != nil {
+		return err
+	}
+
+	if err := verifySignedUpdate(ctx, delegate.Key, delegate.Record, didNumber, did, &update, signature); err != nil {
+		return err
+	}
+
+	return applyUpdate(ctx, didNumber, did, &update, "")
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serviceUpdate returns the stored did with its service replaced by endpoint
+func serviceUpdate(t *testing.T, l *testLedger, didNumber string, endpoint string) *Did {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.ServiceEndPoint = endpoint
+
+	return &update
+}
+
+// updateService calls UpdateService with the service of update
+func updateService(l *testLedger, didNumber string, update *Did, delegateDid string, signature string) error {
+	return new(DidContract).UpdateService(l.ctx, didNumber, update.ServiceId, update.ServiceType, update.ServiceEndPoint, delegateDid, signature)
+}
+
+func TestDelegateControl(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	l.setClient(otherClientID, testMSPID)
+	delegate := createTestDid(t, l, newTestKey(t))
+	expiry := testStart.Add(time.Hour).Format(time.RFC3339)
+
+	err := s.DelegateControl(l.ctx, id, delegate, `["updateService"]`, expiry)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller delegate")
+
+	l.setClient(testClientID, testMSPID)
+
+	invalid := map[string][]string{
+		"should require a JSON array":               {delegate, `"updateService"`, expiry},
+		"should require a permission":               {delegate, `[]`, expiry},
+		"should not delegate rights to change keys": {delegate, `["rotateKey"]`, expiry},
+		"should require an expiry":                  {delegate, `["updateService"]`, ""},
+		"should require an expiry in the future":    {delegate, `["updateService"]`, testStart.Format(time.RFC3339)},
+		"should not delegate to the did itself":     {id, `["updateService"]`, expiry},
+	}
+
+	for message, args := range invalid {
+		assertErrorCode(t, s.DelegateControl(l.ctx, id, args[0], args[1], args[2]), codeInvalidArgument, message)
+	}
+
+	err = s.DelegateControl(l.ctx, id, "did:fabric:unknown", `["updateService"]`, expiry)
+	assertErrorCode(t, err, codeDidNotFound, "should require the delegate to exist")
+
+	require.NoError(t, s.DelegateControl(l.ctx, id, delegate, `["updateService"]`, expiry), "should delegate control")
+	l.nextTx()
+
+	delegations, err := s.QueryDelegations(l.ctx, id)
+	require.NoError(t, err, "should return the delegations")
+	require.Len(t, delegations, 1)
+	assert.Equal(t, Delegation{DidNumber: id, Delegate: delegate, Permissions: []string{permissionUpdateService}, Expires: expiry, GrantedBy: delegations[0].GrantedBy},
+		delegations[0], "should store the delegation")
+	assert.Equal(t, testClientID, delegations[0].GrantedBy.ClientID, "should record the granting client")
+
+	_, err = s.QueryDelegations(l.ctx, "DID9")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+}
+
+func TestUpdateService(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	l.setClient(otherClientID, testMSPID)
+	delegateKey := newTestKey(t)
+	delegate := createTestDid(t, l, delegateKey)
+
+	l.setClient(testClientID, testMSPID)
+	update := serviceUpdate(t, l, id, "https://example.org/vc/")
+	require.NoError(t, updateService(l, id, update, "", signUpdate(t, l, key, id, update)), "should let the controller update the service")
+	l.nextTx()
+
+	update = serviceUpdate(t, l, id, "https://example.org/delegated/")
+	l.setClient(otherClientID, testMSPID)
+	err := updateService(l, id, update, delegate, signUpdate(t, l, delegateKey, id, update))
+	assertErrorCode(t, err, codeUnauthorized, "should require a delegation")
+
+	l.setClient(testClientID, testMSPID)
+	require.NoError(t, s.DelegateControl(l.ctx, id, delegate, `["updateService"]`, testStart.Add(time.Hour).Format(time.RFC3339)))
+	l.nextTx()
+
+	err = updateService(l, id, update, delegate, signUpdate(t, l, delegateKey, id, update))
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the delegate")
+
+	l.setClient(otherClientID, testMSPID)
+	err = updateService(l, id, update, delegate, signUpdate(t, l, key, id, update))
+	assertErrorCode(t, err, codeInvalidSignature, "should require the signature of the delegate")
+
+	require.NoError(t, updateService(l, id, update, delegate, signUpdate(t, l, delegateKey, id, update)), "should let the delegate update the service")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/delegated/", did.ServiceEndPoint, "should replace the service")
+	assert.Equal(t, key.pem, did.AuthenticationPublicKeyPerm, "should keep the keys")
+	assert.Equal(t, testClientID, did.Controller, "should keep the controller")
+	assert.Equal(t, otherClientID, did.Provenance.Updated.ClientID, "should record the delegate's client as updater")
+
+	update = serviceUpdate(t, l, id, "https://example.org/late/")
+	l.setTime(testStart.Add(2 * time.Hour))
+	err = updateService(l, id, update, delegate, signUpdate(t, l, delegateKey, id, update))
+	assertErrorCode(t, err, codeUnauthorized, "should reject expired delegations")
+
+	l.nextTx()
+	l.setClient(testClientID, testMSPID)
+	require.NoError(t, s.DelegateControl(l.ctx, id, delegate, `["updateService"]`, testStart.Add(time.Hour).Format(time.RFC3339)))
+
+	l.setClient(otherClientID, testMSPID)
+	err = s.RevokeDelegation(l.ctx, id, delegate)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller revoke delegations")
+
+	l.setClient(testClientID, testMSPID)
+	require.NoError(t, s.RevokeDelegation(l.ctx, id, delegate), "should revoke the delegation")
+
+	delegations, err := s.QueryDelegations(l.ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, delegations, "should remove the delegation")
+
+	l.setClient(otherClientID, testMSPID)
+	err = updateService(l, id, update, delegate, signUpdate(t, l, delegateKey, id, update))
+	assertErrorCode(t, err, codeUnauthorized, "should reject revoked delegations")
+
+	l.setClient(testClientID, testMSPID)
+	err = s.RevokeDelegation(l.ctx, id, delegate)
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown delegations")
+}
@@ -0,0 +1,234 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// deletedDidRetention is how long deleted dids are kept, and may be restored,
+// before PurgeDeletedDids removes them
+const deletedDidRetention = 30 * 24 * time.Hour
+
+// DeleteDid marks a did as deleted. Deleted dids are left out of queries and
+// only resolve when asked for, but keep their identifier until they are purged
+// and may be restored by their controller with RestoreDid. The signature must
+// be made with the current authentication key over the update payload of the
+// deleted document
+func (s *DidContract) DeleteDid(ctx contractapi.TransactionContextInterface, didNumber string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	return setDeleted(ctx, didNumber, did, true, signature)
+}
+
+// RestoreDid undoes the deletion of a did that has not been purged yet. The
+// signature must be made with the authentication key of the deleted did over the
+// update payload of the restored document
+func (s *DidContract) RestoreDid(ctx contractapi.TransactionContextInterface, didNumber string, signature string) error {
+	did, err := getDid(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if !did.Deleted {
+		return newError(codeInvalidArgument, "%s is not deleted", didNumber)
+	}
+
+	return setDeleted(ctx, didNumber, did, false, signature)
+}
+
+// setDeleted marks the did deleted or restores it after checking that the
+// submitting client controls it and that the signature proves possession of its
+// authentication key
+func setDeleted(ctx contractapi.TransactionContextInterface, didNumber string, did *Did, deleted bool, signature string) error {
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.Deleted = deleted
+
+	if err := verifyKeyPossession(ctx, didNumber, did, &update, signature); err != nil {
+		return err
+	}
+
+	did.Deleted = deleted
+
+	return putUpdatedDid(ctx, didNumber, did)
+}
+
+// deletedAt returns when the did was deleted, which is its last update
+func deletedAt(did *Did) (time.Time, error) {
+	if did.Provenance == nil || did.Provenance.Updated == nil {
+		return time.Time{}, newError(codeCorruptRecord, "Deleted did %s has no update time", did.Id)
+	}
+
+	return time.Parse(time.RFC3339Nano, did.Provenance.Updated.Timestamp)
+}
+
+// PurgeDeletedDids removes the dids deleted longer than deletedDidRetention ago
+// from the world state together with their private key details, and returns
+// their keys. Dids deleted more recently are kept so they may still be restored.
+// At most maxQueryRecords dids are purged per call, so administrators call it
+// again until it returns no keys. Only registry administrators may call it
+func (a *AdminContract) PurgeDeletedDids(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(didObjectType, []string{})
+
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	purged := []string{}
+	events := []DidEvent{}
+
+	for len(purged) < maxQueryRecords && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := didQueryResult(ctx, queryResponse.Key, queryResponse.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !result.Record.Deleted {
+			continue
+		}
+
+		deleted, err := deletedAt(result.Record)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if now.Sub(deleted) < deletedDidRetention {
+			continue
+		}
+
+		if err := purgeDid(ctx, queryResponse.Key, result); err != nil {
+			return nil, err
+		}
+
+		purged = append(purged, result.Key)
+		events = append(events, DidEvent{DidNumber: result.Key, Did: result.Record})
+	}
+
+	if len(events) > 0 {
+		if err := emitDidsEvent(ctx, didsPurgedEvent, events); err != nil {
+			return nil, err
+		}
+	}
+
+	return purged, nil
+}
+
+// purgeDid deletes the did stored under the given world state key with its
+// namespace, id and modification index entries, pending transfer and
+// challenge, delegations, private key details and personal data, and appends
+// an audit record. Service endpoints kept in the collections of organizations
+// are left to them
+func purgeDid(ctx contractapi.TransactionContextInterface, key string, result *QueryResult) error {
+	transfer, err := transferKey(ctx, result.Key)
+
+	if err != nil {
+		return err
+	}
+
+	challenge, err := challengeKey(ctx, result.Key)
+
+	if err != nil {
+		return err
+	}
+
+	namespace, err := namespaceKey(ctx, result.Key)
+
+	if err != nil {
+		return err
+	}
+
+	proposal, err := proposalKey(ctx, result.Key)
+
+	if err != nil {
+		return err
+	}
+
+	idIndex, err := idIndexKey(ctx, result.Record.Id, result.Key)
+
+	if err != nil {
+		return err
+	}
+
+	if err := countDid(ctx, key, result.Key, nil); err != nil {
+		return err
+	}
+
+	keys := []string{key, namespace, transfer, challenge, proposal, idIndex}
+
+	if result.Record.Provenance != nil && result.Record.Provenance.ModifiedAt != "" {
+		modified, err := ctx.GetStub().CreateCompositeKey(modifiedIndex, []string{result.Record.Provenance.ModifiedAt, result.Key})
+
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, modified)
+	}
+
+	for _, key := range keys {
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+		}
+	}
+
+	if err := deleteDelegations(ctx, result.Key); err != nil {
+		return err
+	}
+
+	if result.Record.AuthenticationPublicKeyHash != "" {
+		if err := ctx.GetStub().DelPrivateData(didPrivateCollection, result.Key); err != nil {
+			return fmt.Errorf("Failed to delete from private data collection. %s", err.Error())
+		}
+	}
+
+	if result.Record.PersonalData != nil && result.Record.PersonalData.ErasedBy == nil {
+		if err := ctx.GetStub().DelPrivateData(didPersonalCollection, result.Key); err != nil {
+			return fmt.Errorf("Failed to delete from private data collection. %s", err.Error())
+		}
+	}
+
+	return putAuditRecord(ctx, result.Key)
+}
@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// deleteTestDid deletes the did with key as its authentication key and starts
+// the next transaction
+func deleteTestDid(t *testing.T, l *testLedger, key *testKey, didNumber string) {
+	did, err := new(DidContract).QueryDidByKey(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deleted = true
+	require.NoError(t, new(DidContract).DeleteDid(l.ctx, didNumber, signUpdate(t, l, key, didNumber, &update)))
+
+	l.nextTx()
+}
+
+func TestDeleteDid(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	other := createTestDid(t, l, newTestKey(t))
+
+	err := s.DeleteDid(l.ctx, id, "")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature")
+
+	deleteTestDid(t, l, key, id)
+
+	_, err = s.QueryDidByKey(l.ctx, id)
+	assertErrorCode(t, err, codeDidNotFound, "should hide deleted dids by key")
+
+	_, err = s.QueryDidById(l.ctx, id)
+	assertErrorCode(t, err, codeDidNotFound, "should hide deleted dids by id")
+
+	page, err := s.QueryAllDids(l.ctx, "", "")
+	require.NoError(t, err)
+	require.Len(t, page.Records, 1, "should leave deleted dids out of queries")
+	assert.Equal(t, other, page.Records[0].Key)
+
+	resolution, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.Equal(t, resolutionNotFound, resolution.DidResolutionMetadata.Error, "should not resolve deleted dids by default")
+
+	resolution, err = s.Resolve(l.ctx, id, true)
+	require.NoError(t, err)
+	assert.Equal(t, id, resolution.DidDocument.Id, "should resolve deleted dids when asked to")
+	assert.True(t, resolution.DidDocumentMetadata.Deleted, "should flag deleted dids in the document metadata")
+
+	err = updateDid(l, id, testUpdate(id, key, testEndpoint), "")
+	assertErrorCode(t, err, codeDidNotFound, "should not update deleted dids")
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeDidAlreadyExists, "should keep the identifier of deleted dids")
+
+	did, err := getDid(l.ctx, id)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deleted = false
+	signature := signUpdate(t, l, key, id, &update)
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.RestoreDid(l.ctx, id, signature)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller restore the did")
+
+	l.setClient(testClientID, testMSPID)
+	err = s.RestoreDid(l.ctx, id, signature)
+	require.NoError(t, err, "should restore the did")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err, "should find restored dids")
+	assert.False(t, did.Deleted)
+
+	err = s.RestoreDid(l.ctx, other, signature)
+	assertErrorCode(t, err, codeInvalidArgument, "should only restore deleted dids")
+}
+
+func TestPurgeDeletedDids(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	kept := createTestDid(t, l, newTestKey(t))
+	require.NoError(t, s.ProposeTransfer(l.ctx, id, otherClientID))
+	require.NoError(t, s.DelegateControl(l.ctx, id, kept, `["updateService"]`, testStart.Add(time.Hour).Format(time.RFC3339)))
+	require.NoError(t, s.ProposeDidUpdate(l.ctx, id, `{"serviceId":"#other"}`))
+	l.nextTx()
+
+	deleteTestDid(t, l, key, id)
+
+	err := l.before(a, "PurgeDeletedDids")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators purge dids")
+
+	l.setAdmin(true)
+
+	purged, err := a.PurgeDeletedDids(l.ctx)
+	require.NoError(t, err)
+	assert.Empty(t, purged, "should keep dids within the retention window")
+
+	l.setTime(testStart.Add(deletedDidRetention + time.Minute))
+
+	purged, err = a.PurgeDeletedDids(l.ctx)
+	require.NoError(t, err, "should purge dids deleted before the retention window")
+	assert.Equal(t, []string{id}, purged, "should return the purged dids")
+	assert.NotContains(t, l.state, testDidKey(id), "should remove the did from the world state")
+	assert.Contains(t, l.state, testDidKey(kept), "should keep dids that are not deleted")
+
+	namespace, err := namespaceKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.NotContains(t, l.state, namespace, "should remove the namespace of the did")
+
+	delegation, err := delegationKey(l.ctx, id, kept)
+	require.NoError(t, err)
+	assert.NotContains(t, l.state, delegation, "should remove the delegations of the did")
+
+	transfer, err := transferKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.NotContains(t, l.state, transfer, "should remove the pending transfer")
+
+	proposal, err := proposalKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.NotContains(t, l.state, proposal, "should remove the update proposal")
+
+	name, _ := l.event(t)
+	assert.Equal(t, didsPurgedEvent, name, "should emit dids.purged")
+
+	_, err = getDid(l.ctx, id)
+	assertErrorCode(t, err, codeDidNotFound, "should not find purged dids")
+
+	records, err := s.QueryAuditLog(l.ctx, id)
+	require.NoError(t, err)
+	assert.NotEmpty(t, records, "should keep the audit log of purged dids")
+}
+
+func TestPurgeDeletedPrivateDid(t *testing.T) {
+	l := newTestLedger(t)
+	key := newTestKey(t)
+	createPrivateDid(t, l, key)
+	l.nextTx()
+
+	deleteTestDid(t, l, key, "DID5")
+
+	l.setTime(testStart.Add(deletedDidRetention + time.Minute))
+
+	purged, err := new(AdminContract).PurgeDeletedDids(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DID5"}, purged)
+	assert.NotContains(t, l.private[didPrivateCollection], "DID5", "should remove the private key details")
+}
@@ -0,0 +1,206 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// uriListContentType is the media type of dereferenced service endpoint URLs
+const uriListContentType = "text/uri-list"
+
+// Error codes of did url dereferencing metadata
+const dereferencingInvalidDidUrl = "invalidDidUrl"
+
+// DidDereferencingMetadata describes the outcome of dereferencing a did url
+type DidDereferencingMetadata struct {
+	ContentType string `json:"contentType"`
+	Error       string `json:"error,omitempty" metadata:"error,optional"`
+}
+
+// DidDereferencingResult is the result of dereferencing a did url as defined by
+// DID Resolution. The content stream holds the JSON encoded resource or, for
+// service endpoints, the computed URL
+type DidDereferencingResult struct {
+	ContentStream         string                    `json:"contentStream,omitempty" metadata:"contentStream,optional"`
+	ContentMetadata       *DidDocumentMetadata      `json:"contentMetadata"`
+	DereferencingMetadata *DidDereferencingMetadata `json:"dereferencingMetadata"`
+}
+
+// failedDereferencing returns the result of a dereferencing that failed with given
+// error code
+func failedDereferencing(code string, metadata *DidDocumentMetadata) *DidDereferencingResult {
+	if metadata == nil {
+		metadata = new(DidDocumentMetadata)
+	}
+
+	return &DidDereferencingResult{
+		ContentMetadata:       metadata,
+		DereferencingMetadata: &DidDereferencingMetadata{ContentType: didContentType, Error: code},
+	}
+}
+
+// matchesFragment reports whether id is the did followed by given fragment, or a
+// relative reference to the fragment
+func matchesFragment(id string, did string, fragment string) bool {
+	return id == did+"#"+fragment || id == "#"+fragment
+}
+
+// serviceEndpointUri returns the endpoint URI of a service, the uri of the first
+// endpoint object of DIDCommMessaging services
+func serviceEndpointUri(service Service) string {
+	switch endpoint := service.ServiceEndpoint.(type) {
+	case string:
+		return endpoint
+	case []DidCommEndpoint:
+		return endpoint[0].Uri
+	default:
+		return ""
+	}
+}
+
+// resolveUrlVersion resolves the did of a did url, or the version of it the
+// versionId or versionTime query parameter selects, see ResolveVersion. It
+// returns nil if the parameters do not select a version
+func (s *DidContract) resolveUrlVersion(ctx contractapi.TransactionContextInterface, did string, query url.Values) (*DidResolutionResult, error) {
+	versionId, versionTime := query.Get("versionId"), query.Get("versionTime")
+
+	if versionId == "" && versionTime == "" {
+		return s.Resolve(ctx, did, false)
+	}
+
+	if _, err := parseVersionTime(versionId, versionTime); err != nil {
+		return nil, nil
+	}
+
+	return s.ResolveVersion(ctx, did, versionId, versionTime)
+}
+
+// Dereference returns the resource identified by a did url. A bare did returns
+// its document, a fragment returns the matching verification method or service,
+// and the service and relativeRef query parameters return the service endpoint
+// URL computed from the selected service. The versionId and versionTime query
+// parameters dereference the url in a past version of the document. Encrypted
+// service endpoints cannot be dereferenced
+func (s *DidContract) Dereference(ctx contractapi.TransactionContextInterface, didUrl string) (*DidDereferencingResult, error) {
+	parsed, err := url.Parse(didUrl)
+
+	if err != nil || parsed.Scheme != "did" || parsed.Opaque == "" {
+		return failedDereferencing(dereferencingInvalidDidUrl, nil), nil
+	}
+
+	did := "did:" + parsed.Opaque
+	query := parsed.Query()
+	resolution, err := s.resolveUrlVersion(ctx, did, query)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resolution == nil {
+		return failedDereferencing(dereferencingInvalidDidUrl, nil), nil
+	}
+
+	if resolution.DidResolutionMetadata.Error != "" {
+		return failedDereferencing(resolution.DidResolutionMetadata.Error, resolution.DidDocumentMetadata), nil
+	}
+
+	document := resolution.DidDocument
+
+	if serviceId := query.Get("service"); serviceId != "" {
+		for _, service := range document.Service {
+			if !matchesFragment(service.Id, did, serviceId) {
+				continue
+			}
+
+			if resolution.DidResolutionMetadata.EncryptedEndpoints {
+				return failedDereferencing(resolutionNotFound, resolution.DidDocumentMetadata), nil
+			}
+
+			endpoint, err := url.Parse(serviceEndpointUri(service))
+
+			if err != nil {
+				return failedDereferencing(resolutionNotFound, resolution.DidDocumentMetadata), nil
+			}
+
+			if relativeRef := query.Get("relativeRef"); relativeRef != "" {
+				ref, err := url.Parse(relativeRef)
+
+				if err != nil {
+					return failedDereferencing(dereferencingInvalidDidUrl, resolution.DidDocumentMetadata), nil
+				}
+
+				endpoint = endpoint.ResolveReference(ref)
+			}
+
+			if parsed.Fragment != "" {
+				endpoint.Fragment = parsed.Fragment
+			}
+
+			return &DidDereferencingResult{
+				ContentStream:         endpoint.String(),
+				ContentMetadata:       resolution.DidDocumentMetadata,
+				DereferencingMetadata: &DidDereferencingMetadata{ContentType: uriListContentType},
+			}, nil
+		}
+
+		return failedDereferencing(resolutionNotFound, resolution.DidDocumentMetadata), nil
+	}
+
+	var content interface{} = document
+
+	if parsed.Fragment != "" {
+		content = nil
+
+		for i := range document.VerificationMethod {
+			if matchesFragment(document.VerificationMethod[i].Id, did, parsed.Fragment) {
+				content = &document.VerificationMethod[i]
+				break
+			}
+		}
+
+		for i := range document.Service {
+			if content == nil && matchesFragment(document.Service[i].Id, did, parsed.Fragment) {
+				content = &document.Service[i]
+				break
+			}
+		}
+
+		if content == nil {
+			return failedDereferencing(resolutionNotFound, resolution.DidDocumentMetadata), nil
+		}
+	}
+
+	contentAsBytes, err := json.Marshal(content)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode %s. %s", didUrl, err.Error())
+	}
+
+	return &DidDereferencingResult{
+		ContentStream:         string(contentAsBytes),
+		ContentMetadata:       resolution.DidDocumentMetadata,
+		DereferencingMetadata: &DidDereferencingMetadata{ContentType: didContentType},
+	}, nil
+}
@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDereference(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	result, err := s.Dereference(l.ctx, id)
+	require.NoError(t, err, "should dereference the did")
+	assert.Equal(t, didContentType, result.DereferencingMetadata.ContentType, "should return a did document")
+
+	document := new(DidDocument)
+	require.NoError(t, json.Unmarshal([]byte(result.ContentStream), document))
+	assert.Equal(t, id, document.Id, "should return the document of the did")
+
+	result, err = s.Dereference(l.ctx, id+"#keys-1")
+	require.NoError(t, err, "should dereference the verification method")
+
+	method := new(VerificationMethod)
+	require.NoError(t, json.Unmarshal([]byte(result.ContentStream), method))
+	assert.Equal(t, id+"#keys-1", method.Id, "should return the verification method of the fragment")
+
+	result, err = s.Dereference(l.ctx, id+"#vcs")
+	require.NoError(t, err, "should dereference the service")
+	assert.Contains(t, result.ContentStream, testEndpoint, "should return the service of the fragment")
+
+	result, err = s.Dereference(l.ctx, id+"?service=vcs&relativeRef=credentials/1#proof")
+	require.NoError(t, err, "should dereference the service endpoint")
+	assert.Equal(t, uriListContentType, result.DereferencingMetadata.ContentType, "should return a URL")
+	assert.Equal(t, testEndpoint+"credentials/1#proof", result.ContentStream, "should resolve the relative reference against the endpoint")
+
+	result, err = s.Dereference(l.ctx, id+"?service=unknown")
+	assert.Nil(t, err, "should not fail for unknown services")
+	assert.Equal(t, resolutionNotFound, result.DereferencingMetadata.Error, "should report unknown services")
+
+	result, err = s.Dereference(l.ctx, id+"#keys-9")
+	assert.Nil(t, err, "should not fail for unknown fragments")
+	assert.Equal(t, resolutionNotFound, result.DereferencingMetadata.Error, "should report unknown fragments")
+
+	result, err = s.Dereference(l.ctx, "https://example.com")
+	assert.Nil(t, err, "should not fail for invalid did urls")
+	assert.Equal(t, dereferencingInvalidDidUrl, result.DereferencingMetadata.Error, "should report invalid did urls")
+
+	result, err = s.Dereference(l.ctx, "did:example:unknown#keys-1")
+	assert.Nil(t, err, "should not fail for unknown dids")
+	assert.Equal(t, resolutionNotFound, result.DereferencingMetadata.Error, "should report unknown dids")
+}
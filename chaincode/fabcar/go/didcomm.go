@@ -0,0 +1,123 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// didCommMessagingServiceType is the service type of DIDComm v2 messaging
+// services, whose service endpoint is an array of endpoint objects
+const didCommMessagingServiceType = "DIDCommMessaging"
+
+// DidCommEndpoint describes a service endpoint of a DIDComm v2 messaging
+// service: the URI messages are sent to, the media types the agent accepts and
+// the keys of the mediators messages are routed through, outermost first
+type DidCommEndpoint struct {
+	Uri         string   `json:"uri"`
+	Accept      []string `json:"accept,omitempty" metadata:"accept,optional"`
+	RoutingKeys []string `json:"routingKeys,omitempty" metadata:"routingKeys,optional"`
+}
+
+// validateService checks the service endpoint of the did, its encrypted
+// endpoint and the endpoint objects of a DIDCommMessaging service. Only
+// DIDCommMessaging services may have endpoint objects and they cannot also have
+// a plain endpoint
+func validateService(ctx contractapi.TransactionContextInterface, did *Did) error {
+	if err := validateServiceEndpoint(ctx, did.ServiceEndPoint); err != nil {
+		return err
+	}
+
+	if err := validateEncryptedServiceEndpoint(did); err != nil {
+		return err
+	}
+
+	if len(did.ServiceEndpoints) == 0 {
+		return nil
+	}
+
+	if did.ServiceType != didCommMessagingServiceType {
+		return newError(codeInvalidArgument, "Only %s services may have endpoint objects", didCommMessagingServiceType)
+	}
+
+	if did.ServiceEndPoint != "" || did.ServiceEndPointHash != "" {
+		return newError(codeInvalidArgument, "%s services cannot have both a service endpoint and endpoint objects", didCommMessagingServiceType)
+	}
+
+	for i, endpoint := range did.ServiceEndpoints {
+		if endpoint.Uri == "" {
+			return newError(codeInvalidArgument, "Endpoint %d of the %s service must have a uri", i, didCommMessagingServiceType)
+		}
+
+		if err := validateServiceEndpoint(ctx, endpoint.Uri); err != nil {
+			return err
+		}
+
+		for _, accept := range endpoint.Accept {
+			if accept == "" || strings.IndexFunc(accept, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+				return newError(codeInvalidArgument, "Endpoint %d of the %s service accepts invalid media type %q", i, didCommMessagingServiceType, accept)
+			}
+		}
+
+		for _, routingKey := range endpoint.RoutingKeys {
+			if err := validateDidUrl("routing key", routingKey, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// SetDidCommService replaces the service of a did with a DIDComm v2 messaging
+// service with given id whose endpoint objects are given as a JSON array of
+// DidCommEndpoint. The signature must prove possession of the current
+// authentication key over the update payload of the document with the new service
+func (s *DidContract) SetDidCommService(ctx contractapi.TransactionContextInterface, didNumber string, serviceId string, endpoints string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.ServiceId = serviceId
+	update.ServiceType = didCommMessagingServiceType
+	update.ServiceEndPoint = ""
+	update.ServiceEndPointHash = ""
+	update.EncryptedServiceEndPoint = ""
+	update.ServiceEndpoints = []DidCommEndpoint{}
+
+	if err := decodeStrict([]byte(endpoints), &update.ServiceEndpoints); err != nil {
+		return newError(codeInvalidArgument, "Endpoints must be a JSON array of DIDComm endpoint objects. %s", err.Error())
+	}
+
+	if len(update.ServiceEndpoints) == 0 {
+		return newError(codeInvalidArgument, "At least one endpoint is required")
+	}
+
+	if err := assertDidCap(ctx, didNumber, &update); err != nil {
+		return err
+	}
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
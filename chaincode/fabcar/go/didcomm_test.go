@@ -0,0 +1,134 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDidCommEndpoints are endpoint objects of a DIDComm service routed through
+// a mediator
+var testDidCommEndpoints = []DidCommEndpoint{{Uri: "https://mediator.example.com/didcomm", Accept: []string{"didcomm/v2"}, RoutingKeys: []string{"did:example:mediator#key-1"}}}
+
+// didCommUpdate returns the stored did with its service replaced by a DIDComm
+// service with given endpoints
+func didCommUpdate(t *testing.T, l *testLedger, didNumber string, endpoints []DidCommEndpoint) *Did {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.ServiceId = "#didcomm-1"
+	update.ServiceType = didCommMessagingServiceType
+	update.ServiceEndPoint = ""
+	update.ServiceEndpoints = endpoints
+
+	return &update
+}
+
+// setDidCommService calls SetDidCommService with the endpoints of update
+func setDidCommService(t *testing.T, l *testLedger, didNumber string, update *Did, signature string) error {
+	endpointsAsBytes, err := json.Marshal(update.ServiceEndpoints)
+	require.NoError(t, err)
+
+	return new(DidContract).SetDidCommService(l.ctx, didNumber, update.ServiceId, string(endpointsAsBytes), signature)
+}
+
+func TestValidateService(t *testing.T) {
+	l := newTestLedger(t)
+
+	did := Did{ServiceType: didCommMessagingServiceType, ServiceEndpoints: testDidCommEndpoints}
+	assert.Nil(t, validateService(l.ctx, &did), "should accept DIDComm endpoint objects")
+
+	invalid := map[string]Did{
+		"should require a DIDComm service":       {ServiceType: "VerifiableCredentialService", ServiceEndpoints: testDidCommEndpoints},
+		"should not mix endpoint kinds":          {ServiceType: didCommMessagingServiceType, ServiceEndPoint: testEndpoint, ServiceEndpoints: testDidCommEndpoints},
+		"should require a uri":                   {ServiceType: didCommMessagingServiceType, ServiceEndpoints: []DidCommEndpoint{{Accept: []string{"didcomm/v2"}}}},
+		"should check the uri scheme":            {ServiceType: didCommMessagingServiceType, ServiceEndpoints: []DidCommEndpoint{{Uri: "http://example.com"}}},
+		"should check the accepted media types":  {ServiceType: didCommMessagingServiceType, ServiceEndpoints: []DidCommEndpoint{{Uri: testEndpoint, Accept: []string{"didcomm v2"}}}},
+		"should require routing keys to be keys": {ServiceType: didCommMessagingServiceType, ServiceEndpoints: []DidCommEndpoint{{Uri: testEndpoint, RoutingKeys: []string{"did:example:mediator"}}}},
+	}
+
+	for message, did := range invalid {
+		assertErrorCode(t, validateService(l.ctx, &did), codeInvalidArgument, message)
+	}
+}
+
+func TestSetDidCommService(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	assertErrorCode(t, s.SetDidCommService(l.ctx, id, "#didcomm-1", `{"uri":"https://example.com"}`, ""), codeInvalidArgument, "should require a JSON array")
+	assertErrorCode(t, s.SetDidCommService(l.ctx, id, "#didcomm-1", `[]`, ""), codeInvalidArgument, "should require an endpoint")
+	assertErrorCode(t, s.SetDidCommService(l.ctx, id, "#didcomm-1", `[{"url":"https://example.com"}]`, ""), codeInvalidArgument, "should reject unknown members")
+
+	update := didCommUpdate(t, l, id, []DidCommEndpoint{{Uri: "https://example.com", RoutingKeys: []string{"mediator"}}})
+	err := setDidCommService(t, l, id, update, signUpdate(t, l, key, id, update))
+	assertErrorCode(t, err, codeInvalidArgument, "should validate the endpoints on write")
+
+	update = didCommUpdate(t, l, id, testDidCommEndpoints)
+	assertErrorCode(t, setDidCommService(t, l, id, update, signUpdate(t, l, newTestKey(t), id, update)), codeInvalidSignature, "should require the did's key")
+	require.NoError(t, setDidCommService(t, l, id, update, signUpdate(t, l, key, id, update)), "should set the service")
+	l.nextTx()
+
+	result, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.Equal(t, []Service{{Id: "#didcomm-1", Type: didCommMessagingServiceType, ServiceEndpoint: testDidCommEndpoints}}, result.DidDocument.Service,
+		"should resolve the endpoint objects as the service endpoint")
+
+	str, err := toString(result.DidDocument.Service, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `[{"id":"#didcomm-1","serviceEndpoint":[{"accept":["didcomm/v2"],"routingKeys":["did:example:mediator#key-1"],`+
+		`"uri":"https://mediator.example.com/didcomm"}],"type":"DIDCommMessaging"}]`, str, "should use the DIDComm v2 service shape")
+
+	dereferenced, err := s.Dereference(l.ctx, id+"?service=didcomm-1")
+	require.NoError(t, err)
+	assert.Equal(t, testDidCommEndpoints[0].Uri, dereferenced.ContentStream, "should dereference to the first endpoint")
+
+	rotated := newTestKey(t)
+	rotation := rotation(t, l, id, rotated)
+	rotation.ServiceEndpoints = nil
+	require.NoError(t, updateDid(l, id, rotation, signUpdate(t, l, key, id, rotation)), "should keep the endpoints on other updates")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, testDidCommEndpoints, did.ServiceEndpoints, "should keep the endpoint objects")
+
+	service := testUpdate(id, rotated, "https://example.org/vc/")
+	service.AuthenticationId = id + "#keys-2"
+	require.NoError(t, updateDid(l, id, service, signUpdate(t, l, rotated, id, service)), "should replace the service")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, did.ServiceEndpoints, "should drop the endpoint objects with the DIDComm service")
+}
+
+func TestServiceEndpointSchema(t *testing.T) {
+	ccm := chaincodeMetadata(t)
+
+	service, ok := ccm.Components.Schemas["Service"]
+	require.True(t, ok, "should describe services")
+	assert.Empty(t, service.Properties["serviceEndpoint"].Type, "should accept URIs and endpoint objects as the service endpoint")
+}
@@ -0,0 +1,218 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// FieldChange describes a member of the stored did that differs between two
+// versions. From is missing for added members, To for removed ones
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from,omitempty" metadata:"from,optional"`
+	To    interface{} `json:"to,omitempty" metadata:"to,optional"`
+}
+
+// DidVersionDiff describes the changes between two versions of a did: the
+// verification methods and services of its did document that were added,
+// removed or changed, matched by id, with changed ones as in the later version,
+// and the members of the stored did that changed other than its provenance
+type DidVersionDiff struct {
+	FromVersion     string               `json:"fromVersion"`
+	ToVersion       string               `json:"toVersion"`
+	AddedKeys       []VerificationMethod `json:"addedKeys"`
+	RemovedKeys     []VerificationMethod `json:"removedKeys"`
+	ChangedKeys     []VerificationMethod `json:"changedKeys"`
+	AddedServices   []Service            `json:"addedServices"`
+	RemovedServices []Service            `json:"removedServices"`
+	ChangedServices []Service            `json:"changedServices"`
+	Fields          []FieldChange        `json:"fields"`
+}
+
+// findVersion returns the modification of the history of a did that wrote the
+// version with given id, or the latest one if versionId is empty
+func findVersion(modifications []*queryresult.KeyModification, versionId string) *queryresult.KeyModification {
+	for i := len(modifications) - 1; i >= 0; i-- {
+		if modifications[i].IsDelete {
+			continue
+		}
+
+		if versionId == "" || modifications[i].TxId == versionId {
+			return modifications[i]
+		}
+	}
+
+	return nil
+}
+
+// diffFields returns the changes of the members of two encoded versions of a
+// did, ordered by member, leaving out their provenance
+func diffFields(fromAsBytes []byte, toAsBytes []byte) ([]FieldChange, error) {
+	from := map[string]interface{}{}
+	to := map[string]interface{}{}
+
+	if err := json.Unmarshal(fromAsBytes, &from); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(toAsBytes, &to); err != nil {
+		return nil, err
+	}
+
+	fields := []string{}
+
+	for field := range from {
+		fields = append(fields, field)
+	}
+
+	for field := range to {
+		if _, ok := from[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+
+	sort.Strings(fields)
+	changes := []FieldChange{}
+
+	for _, field := range fields {
+		if field == "provenance" || reflect.DeepEqual(from[field], to[field]) {
+			continue
+		}
+
+		changes = append(changes, FieldChange{Field: field, From: from[field], To: to[field]})
+	}
+
+	return changes, nil
+}
+
+// DiffDidVersions compares two versions of the did stored with given key, read
+// from its ledger history. The versions are named by the ids of the
+// transactions that wrote them, as returned in the versionId document metadata
+// or by QueryDidHistory. An empty toVersion compares with the latest version
+func (s *DidContract) DiffDidVersions(ctx contractapi.TransactionContextInterface, didNumber string, fromVersion string, toVersion string) (*DidVersionDiff, error) {
+	if fromVersion == "" {
+		return nil, newError(codeInvalidArgument, "A version to compare from is required")
+	}
+
+	modifications, err := didHistory(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(modifications) == 0 {
+		return nil, newError(codeDidNotFound, "%s does not exist", didNumber)
+	}
+
+	versions := []*Did{}
+	modified := []*queryresult.KeyModification{}
+
+	for _, versionId := range []string{fromVersion, toVersion} {
+		modification := findVersion(modifications, versionId)
+
+		if modification == nil {
+			return nil, newError(codeNotFound, "Version %s of %s does not exist", versionId, didNumber)
+		}
+
+		version := new(Did)
+
+		if err := unmarshalRecord(didNumber, modification.Value, version); err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, version)
+		modified = append(modified, modification)
+	}
+
+	fields, err := diffFields(modified[0].Value, modified[1].Value)
+
+	if err != nil {
+		return nil, &CorruptRecordError{Key: didNumber, Err: err}
+	}
+
+	diff := DidVersionDiff{
+		FromVersion:     modified[0].TxId,
+		ToVersion:       modified[1].TxId,
+		AddedKeys:       []VerificationMethod{},
+		RemovedKeys:     []VerificationMethod{},
+		ChangedKeys:     []VerificationMethod{},
+		AddedServices:   []Service{},
+		RemovedServices: []Service{},
+		ChangedServices: []Service{},
+		Fields:          fields,
+	}
+
+	from, to := versions[0].document(), versions[1].document()
+	fromKeys := map[string]VerificationMethod{}
+
+	for _, method := range from.VerificationMethod {
+		fromKeys[method.Id] = method
+	}
+
+	for _, method := range to.VerificationMethod {
+		previous, ok := fromKeys[method.Id]
+
+		if !ok {
+			diff.AddedKeys = append(diff.AddedKeys, method)
+		} else if !reflect.DeepEqual(previous, method) {
+			diff.ChangedKeys = append(diff.ChangedKeys, method)
+		}
+
+		delete(fromKeys, method.Id)
+	}
+
+	for _, method := range from.VerificationMethod {
+		if _, ok := fromKeys[method.Id]; ok {
+			diff.RemovedKeys = append(diff.RemovedKeys, method)
+		}
+	}
+
+	fromServices := map[string]Service{}
+
+	for _, service := range from.Service {
+		fromServices[service.Id] = service
+	}
+
+	for _, service := range to.Service {
+		previous, ok := fromServices[service.Id]
+
+		if !ok {
+			diff.AddedServices = append(diff.AddedServices, service)
+		} else if !reflect.DeepEqual(previous, service) {
+			diff.ChangedServices = append(diff.ChangedServices, service)
+		}
+
+		delete(fromServices, service.Id)
+	}
+
+	for _, service := range from.Service {
+		if _, ok := fromServices[service.Id]; ok {
+			diff.RemovedServices = append(diff.RemovedServices, service)
+		}
+	}
+
+	return &diff, nil
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffDidVersions(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+	l.nextTx()
+
+	addKeyAgreementKey(t, l, key, id, "keys-2")
+
+	diff, err := s.DiffDidVersions(l.ctx, id, "tx1", "tx2")
+	require.NoError(t, err, "should compare two versions")
+	assert.Equal(t, "tx1", diff.FromVersion)
+	assert.Equal(t, "tx2", diff.ToVersion)
+	assert.Empty(t, diff.AddedKeys, "should not report unchanged keys")
+	assert.Empty(t, diff.ChangedKeys)
+	require.Len(t, diff.ChangedServices, 1, "should report changed services")
+	assert.Equal(t, Service{Id: id + "#vcs", Type: "VerifiableCredentialService", ServiceEndpoint: "https://example.org/vc/"}, diff.ChangedServices[0], "should return services as in the later version")
+	assert.Equal(t, []FieldChange{{Field: "serviceEndPoint", From: testEndpoint, To: "https://example.org/vc/"}}, diff.Fields, "should report the changed members without the provenance")
+
+	diff, err = s.DiffDidVersions(l.ctx, id, "tx2", "")
+	require.NoError(t, err, "should compare with the latest version")
+	assert.Equal(t, "tx3", diff.ToVersion, "should name the latest version")
+	require.Len(t, diff.AddedKeys, 1, "should report added keys")
+	assert.Equal(t, id+"#keys-2", diff.AddedKeys[0].Id)
+	assert.Empty(t, diff.ChangedServices)
+	require.Len(t, diff.Fields, 1)
+	assert.Equal(t, "verificationMethods", diff.Fields[0].Field, "should report added members")
+	assert.Nil(t, diff.Fields[0].From, "should leave out the value of added members")
+
+	diff, err = s.DiffDidVersions(l.ctx, id, "tx3", "tx1")
+	require.NoError(t, err)
+	require.Len(t, diff.RemovedKeys, 1, "should report removed keys")
+	assert.Equal(t, id+"#keys-2", diff.RemovedKeys[0].Id)
+	assert.Nil(t, diff.Fields[len(diff.Fields)-1].To, "should leave out the value of removed members")
+
+	diff, err = s.DiffDidVersions(l.ctx, id, "tx2", "tx2")
+	require.NoError(t, err)
+	assert.Empty(t, diff.Fields, "should find no changes within a version")
+
+	_, err = s.DiffDidVersions(l.ctx, id, "tx9", "")
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown versions")
+
+	_, err = s.DiffDidVersions(l.ctx, id, "", "tx2")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a version to compare from")
+
+	_, err = s.DiffDidVersions(l.ctx, "DID9", "tx1", "")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// linkedDomainsServiceType is the service type of the DIF Well Known DID
+// Configuration, whose service endpoint is a web origin the did is linked to
+const linkedDomainsServiceType = "LinkedDomains"
+
+// domainLinkageCredentialType is the credential type with which a did owner
+// asserts control of an origin in its DID configuration resource
+const domainLinkageCredentialType = "DomainLinkageCredential"
+
+// DomainLinkageSubject is the subject of a domain linkage credential
+type DomainLinkageSubject struct {
+	Id     string `json:"id"`
+	Origin string `json:"origin"`
+}
+
+// normalizeOrigin returns value as scheme://host in lower case, or an error
+// unless it is an https origin without path, query or fragment
+func normalizeOrigin(value string) (string, error) {
+	origin, err := url.Parse(value)
+
+	if err != nil {
+		return "", fmt.Errorf("Origin %s is not a valid URI. %s", value, err.Error())
+	}
+
+	if !strings.EqualFold(origin.Scheme, "https") || origin.Host == "" {
+		return "", fmt.Errorf("Origin %s must be an https URI with a host", value)
+	}
+
+	if origin.User != nil || (origin.Path != "" && origin.Path != "/") || origin.RawQuery != "" || origin.Fragment != "" || origin.ForceQuery {
+		return "", fmt.Errorf("Origin %s must not have a path, query or fragment", value)
+	}
+
+	return "https://" + strings.ToLower(origin.Host), nil
+}
+
+// validateLinkedDomains returns an error if the did has a LinkedDomains service
+// whose public endpoint is not an origin
+func validateLinkedDomains(did *Did) error {
+	if did.ServiceType != linkedDomainsServiceType || did.ServiceEndPoint == "" {
+		return nil
+	}
+
+	if _, err := normalizeOrigin(did.ServiceEndPoint); err != nil {
+		return newError(codeInvalidArgument, "%s service endpoint must be an origin. %s", linkedDomainsServiceType, err.Error())
+	}
+
+	return nil
+}
+
+// checkDomainLinkage returns an error unless vcJSON is a domain linkage
+// credential issued by did about itself for an origin of its LinkedDomains
+// service
+func checkDomainLinkage(did *Did, vcJSON string) (string, error) {
+	vc := new(VerifiableCredential)
+
+	if err := json.Unmarshal([]byte(vcJSON), vc); err != nil {
+		return "", err
+	}
+
+	if !containsString(vc.Type, "VerifiableCredential") || !containsString(vc.Type, domainLinkageCredentialType) {
+		return "", fmt.Errorf("Credential must be a %s", domainLinkageCredentialType)
+	}
+
+	issuerDid, err := vc.issuerId()
+
+	if err != nil {
+		return "", err
+	}
+
+	if issuerDid != did.Id {
+		return "", fmt.Errorf("Credential is issued by %s, not %s", issuerDid, did.Id)
+	}
+
+	subject := DomainLinkageSubject{}
+
+	if err := json.Unmarshal(vc.CredentialSubject, &subject); err != nil {
+		return "", fmt.Errorf("Credential subject must be an object with an id and origin. %s", err.Error())
+	}
+
+	if subject.Id != did.Id {
+		return "", fmt.Errorf("Credential subject %s is not %s", subject.Id, did.Id)
+	}
+
+	if _, err := time.Parse(time.RFC3339, vc.IssuanceDate); err != nil {
+		return "", fmt.Errorf("Credential must have an RFC 3339 issuance date")
+	}
+
+	if _, err := time.Parse(time.RFC3339, vc.ExpirationDate); err != nil {
+		return "", fmt.Errorf("Credential must have an RFC 3339 expiration date")
+	}
+
+	return normalizeOrigin(subject.Origin)
+}
+
+// VerifyDomainLinkage verifies a domain linkage credential of the DID
+// configuration resource of a web origin against the did stored with given key.
+// Besides the checks of VerifyCredential, the credential must be a
+// DomainLinkageCredential issued by the did about itself and its origin must
+// be the endpoint of the LinkedDomains service of the did, so that the did and
+// the domain refer to each other. The outcome of every step is returned
+func (s *DidContract) VerifyDomainLinkage(ctx contractapi.TransactionContextInterface, didNumber string, vcJSON string) (*VerificationResult, error) {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := VerificationResult{Verified: true, Checks: []VerificationCheck{}}
+
+	verifyCredential(ctx, vcJSON, &result)
+
+	origin, err := checkDomainLinkage(did, vcJSON)
+
+	if !result.addCheck("domainLinkage", err) {
+		return &result, nil
+	}
+
+	var linkErr error
+
+	if linked, _ := normalizeOrigin(did.ServiceEndPoint); did.ServiceType != linkedDomainsServiceType || linked != origin {
+		linkErr = fmt.Errorf("Origin %s is not the %s service endpoint of %s", origin, linkedDomainsServiceType, didNumber)
+	}
+
+	result.addCheck("linkedDomains", linkErr)
+
+	return &result, nil
+}
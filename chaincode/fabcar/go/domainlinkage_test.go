@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createLinkedDomainsDid creates a did with key whose LinkedDomains service
+// links it to origin
+func createLinkedDomainsDid(t *testing.T, l *testLedger, key *testKey, origin string) string {
+	id, err := new(DidContract).CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#linked-domain", linkedDomainsServiceType, origin, "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	return id
+}
+
+// testDomainLinkage returns an unsigned domain linkage credential of did for origin
+func testDomainLinkage(did string, origin string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":              []string{"VerifiableCredential", domainLinkageCredentialType},
+		"issuer":            did,
+		"issuanceDate":      testIssuanceDate,
+		"expirationDate":    testStart.AddDate(1, 0, 0).Format(time.RFC3339),
+		"credentialSubject": map[string]interface{}{"id": did, "origin": origin},
+	}
+}
+
+func TestValidateLinkedDomains(t *testing.T) {
+	valid := []string{"", "https://example.com", "https://Example.com:8443/"}
+	invalid := []string{"https://example.com/vc/", "https://example.com?x=1", "https://example.com#top", "https://user@example.com", "didcomm:transport/queue"}
+
+	for _, endpoint := range valid {
+		assert.Nil(t, validateLinkedDomains(&Did{ServiceType: linkedDomainsServiceType, ServiceEndPoint: endpoint}), "should accept %q", endpoint)
+	}
+
+	for _, endpoint := range invalid {
+		assertErrorCode(t, validateLinkedDomains(&Did{ServiceType: linkedDomainsServiceType, ServiceEndPoint: endpoint}), codeInvalidArgument, "should reject "+endpoint)
+	}
+
+	assert.Nil(t, validateLinkedDomains(&Did{ServiceType: "VerifiableCredentialService", ServiceEndPoint: testEndpoint}), "should only check LinkedDomains services")
+
+	l := newTestLedger(t)
+	_, err := new(DidContract).CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#linked-domain", linkedDomainsServiceType, testEndpoint, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should validate the service of new dids")
+}
+
+func TestVerifyDomainLinkage(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createLinkedDomainsDid(t, l, key, "https://example.com")
+
+	result, err := s.VerifyDomainLinkage(l.ctx, id, signDocument(t, key, testDomainLinkage(id, "https://EXAMPLE.com/"), assertionProof(id)))
+	require.NoError(t, err, "should not fail the transaction")
+	assert.True(t, result.Verified, "should verify credentials linking the did to its domain: %v", result.Checks)
+
+	result, err = s.VerifyDomainLinkage(l.ctx, id, signDocument(t, key, testDomainLinkage(id, "https://example.org"), assertionProof(id)))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "linkedDomains").Passed, "should require the origin to be a linked domain of the did")
+
+	result, err = s.VerifyDomainLinkage(l.ctx, id, signDocument(t, newTestKey(t), testDomainLinkage(id, "https://example.com"), assertionProof(id)))
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should require the signature of the did")
+	assert.False(t, checkOf(t, result, "proof").Passed, "should fail the proof check")
+
+	other := createTestDid(t, l, newTestKey(t))
+	credential := testDomainLinkage(id, "https://example.com")
+	credential["credentialSubject"] = map[string]interface{}{"id": other, "origin": "https://example.com"}
+	result, err = s.VerifyDomainLinkage(l.ctx, id, signDocument(t, key, credential, assertionProof(id)))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "domainLinkage").Passed, "should require the did to be the subject")
+
+	credential = testDomainLinkage(id, "https://example.com")
+	delete(credential, "expirationDate")
+	result, err = s.VerifyDomainLinkage(l.ctx, id, signDocument(t, key, credential, assertionProof(id)))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "domainLinkage").Passed, "should require an expiration date")
+
+	credential = testDomainLinkage(id, "https://example.com")
+	credential["type"] = []string{"VerifiableCredential"}
+	result, err = s.VerifyDomainLinkage(l.ctx, id, signDocument(t, key, credential, assertionProof(id)))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "domainLinkage").Passed, "should require a domain linkage credential")
+
+	otherKey := newTestKey(t)
+	other = createTestDid(t, l, otherKey)
+	result, err = s.VerifyDomainLinkage(l.ctx, other, signDocument(t, otherKey, testDomainLinkage(other, "https://example.com"), assertionProof(other)))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "linkedDomains").Passed, "should require a LinkedDomains service")
+
+	_, err = s.VerifyDomainLinkage(l.ctx, "DID9", "{}")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+}
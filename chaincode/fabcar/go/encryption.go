@@ -0,0 +1,202 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+)
+
+// jweKeyAgreementAlgorithms are the JWE key management algorithms accepted for
+// encrypted service endpoints, all deriving the key by ECDH with an ephemeral key
+var jweKeyAgreementAlgorithms = []string{"ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A256KW"}
+
+// jweEncryptions are the JWE content encryption algorithms accepted for
+// encrypted service endpoints
+var jweEncryptions = []string{"A128GCM", "A256GCM", "A128CBC-HS256", "A256CBC-HS512"}
+
+// JweHeader describes the members of the protected header of a JWE checked by
+// the registry
+type JweHeader struct {
+	Alg string           `json:"alg"`
+	Enc string           `json:"enc"`
+	Kid string           `json:"kid"`
+	Epk *keyencoding.Jwk `json:"epk"`
+}
+
+// parseJwe decodes the protected header of a JWE in compact serialization and
+// checks that its other parts are base64url encoded
+func parseJwe(jwe string) (*JweHeader, error) {
+	parts := strings.Split(jwe, ".")
+
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("Encrypted service endpoint must be a JWE in compact serialization")
+	}
+
+	decoded := make([][]byte, len(parts))
+
+	for i, part := range parts {
+		var err error
+
+		if decoded[i], err = base64.RawURLEncoding.DecodeString(part); err != nil {
+			return nil, fmt.Errorf("Part %d of the JWE is not base64url encoded. %s", i+1, err.Error())
+		}
+	}
+
+	if len(decoded[2]) == 0 || len(decoded[3]) == 0 || len(decoded[4]) == 0 {
+		return nil, fmt.Errorf("JWE must have an initialization vector, ciphertext and authentication tag")
+	}
+
+	header := new(JweHeader)
+
+	if err := json.Unmarshal(decoded[0], header); err != nil {
+		return nil, fmt.Errorf("Failed to decode JWE header. %s", err.Error())
+	}
+
+	if !containsString(jweKeyAgreementAlgorithms, header.Alg) {
+		return nil, fmt.Errorf("JWE algorithm %s is not one of %v", header.Alg, jweKeyAgreementAlgorithms)
+	}
+
+	if !containsString(jweEncryptions, header.Enc) {
+		return nil, fmt.Errorf("JWE encryption %s is not one of %v", header.Enc, jweEncryptions)
+	}
+
+	if (header.Alg == "ECDH-ES") != (len(decoded[1]) == 0) {
+		return nil, fmt.Errorf("JWE must have an encrypted key exactly when the algorithm wraps one")
+	}
+
+	return header, nil
+}
+
+// keyAgreementMethod returns the verification method of the did an encrypted
+// service endpoint is encrypted to, or an empty string if it has none
+func (d *Did) keyAgreementMethod() string {
+	if d.EncryptedServiceEndPoint == "" {
+		return ""
+	}
+
+	header, err := parseJwe(d.EncryptedServiceEndPoint)
+
+	if err != nil {
+		return ""
+	}
+
+	return header.Kid
+}
+
+// sameCurve reports whether two public keys are elliptic curve keys of the same curve
+func sameCurve(a interface{}, b interface{}) bool {
+	switch a := a.(type) {
+	case *ecdsa.PublicKey:
+		b, ok := b.(*ecdsa.PublicKey)
+
+		return ok && a.Curve == b.Curve
+	case *secp256k1.PublicKey:
+		_, ok := b.(*secp256k1.PublicKey)
+
+		return ok
+	default:
+		return false
+	}
+}
+
+// validateEncryptedServiceEndpoint returns an error unless the encrypted
+// service endpoint of the did is a JWE whose kid is an additional verification
+// method of the did with an elliptic curve key, its key agreement key, and whose
+// ephemeral key is on the same curve. The registry cannot decrypt the endpoint
+func validateEncryptedServiceEndpoint(did *Did) error {
+	if did.EncryptedServiceEndPoint == "" {
+		return nil
+	}
+
+	if did.ServiceEndPoint != "" || did.ServiceEndPointHash != "" || len(did.ServiceEndpoints) > 0 {
+		return newError(codeInvalidArgument, "Services with an encrypted endpoint cannot have other endpoints")
+	}
+
+	header, err := parseJwe(did.EncryptedServiceEndPoint)
+
+	if err != nil {
+		return newError(codeInvalidArgument, "Invalid encrypted service endpoint. %s", err.Error())
+	}
+
+	var recipient interface{}
+
+	for _, method := range did.VerificationMethods {
+		if method.Id == header.Kid {
+			if recipient, err = method.publicKey(); err != nil {
+				return newError(codeInvalidArgument, "Failed to parse public key of %s. %s", header.Kid, err.Error())
+			}
+		}
+	}
+
+	if recipient == nil {
+		return newError(codeInvalidArgument, "JWE kid %s must be an additional verification method of %s", header.Kid, did.Id)
+	}
+
+	if header.Epk == nil {
+		return newError(codeInvalidArgument, "JWE header must have an ephemeral public key")
+	}
+
+	ephemeral, err := header.Epk.PublicKey()
+
+	if err != nil {
+		return newError(codeInvalidArgument, "Failed to parse the ephemeral public key of the JWE. %s", err.Error())
+	}
+
+	if !sameCurve(recipient, ephemeral) {
+		return newError(codeInvalidArgument, "Key agreement key %s must be an elliptic curve key on the curve of the ephemeral key", header.Kid)
+	}
+
+	return nil
+}
+
+// SetEncryptedServiceEndpoint replaces the service endpoint of a did with a JWE
+// encrypted by the client to one of its verification methods, the key agreement
+// key named by the kid of the JWE. Only the ciphertext is stored, resolution
+// flags documents with encrypted endpoints and lists the key under keyAgreement.
+// UpdateDid replaces the encrypted endpoint with a plain one. The signature must
+// prove possession of the current authentication key over the update payload of
+// the document with the encrypted endpoint
+func (s *DidContract) SetEncryptedServiceEndpoint(ctx contractapi.TransactionContextInterface, didNumber string, encryptedEndpoint string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if encryptedEndpoint == "" {
+		return newError(codeInvalidArgument, "Encrypted service endpoint must not be empty")
+	}
+
+	update := updatableDetails(did)
+	update.ServiceEndPoint = ""
+	update.ServiceEndPointHash = ""
+	update.ServiceEndpoints = nil
+	update.EncryptedServiceEndPoint = encryptedEndpoint
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
@@ -0,0 +1,179 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testJwe returns a JWE with given header members and ephemeral key whose
+// ciphertext parts are random bytes, as the registry cannot decrypt it
+func testJwe(t *testing.T, alg string, kid string, epk crypto.PublicKey, encryptedKey string) string {
+	jwk, err := keyencoding.JwkFromPublicKey(epk)
+	require.NoError(t, err)
+
+	headerAsBytes, err := json.Marshal(JweHeader{Alg: alg, Enc: "A256GCM", Kid: kid, Epk: jwk})
+	require.NoError(t, err)
+
+	random := func() string {
+		value := make([]byte, 16)
+		_, err := rand.Read(value)
+		require.NoError(t, err)
+
+		return base64.RawURLEncoding.EncodeToString(value)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(headerAsBytes) + "." + encryptedKey + "." + random() + "." + random() + "." + random()
+}
+
+// addKeyAgreementKey adds a P-256 verification method with given fragment to
+// the did signed with key
+func addKeyAgreementKey(t *testing.T, l *testLedger, key *testKey, didNumber string, fragment string) {
+	agreement := newTestKey(t)
+	methodId := didNumber + "#" + fragment
+
+	signature := signAddMethod(t, l, key, didNumber, methodId, testKeyType, didNumber, agreement.pem)
+	require.NoError(t, new(DidContract).AddVerificationMethod(l.ctx, didNumber, methodId, testKeyType, didNumber, agreement.pem, signature))
+	l.nextTx()
+}
+
+// encryptedUpdate returns the stored did with its service endpoint replaced by jwe
+func encryptedUpdate(t *testing.T, l *testLedger, didNumber string, jwe string) *Did {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.ServiceEndPoint = ""
+	update.EncryptedServiceEndPoint = jwe
+
+	return &update
+}
+
+func TestParseJwe(t *testing.T) {
+	epk := newTestKey(t).private.Public()
+
+	header, err := parseJwe(testJwe(t, "ECDH-ES", "did:example:123#keys-2", epk, ""))
+	require.NoError(t, err, "should parse JWEs")
+	assert.Equal(t, "did:example:123#keys-2", header.Kid, "should return the kid")
+
+	_, err = parseJwe(testJwe(t, "ECDH-ES+A256KW", "did:example:123#keys-2", epk, "a2V5"))
+	assert.Nil(t, err, "should accept wrapped keys")
+
+	invalid := map[string]string{
+		"should require the compact serialization": "a.b.c",
+		"should require base64url parts":           testJwe(t, "ECDH-ES", "did:example:123#keys-2", epk, "") + "!",
+		"should only accept key agreement":         testJwe(t, "RSA-OAEP", "did:example:123#keys-2", epk, "a2V5"),
+		"should not wrap direct keys":              testJwe(t, "ECDH-ES", "did:example:123#keys-2", epk, "a2V5"),
+		"should require wrapped keys":              testJwe(t, "ECDH-ES+A128KW", "did:example:123#keys-2", epk, ""),
+	}
+
+	for message, jwe := range invalid {
+		_, err := parseJwe(jwe)
+		assert.Error(t, err, message)
+	}
+}
+
+func TestSetEncryptedServiceEndpoint(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	addKeyAgreementKey(t, l, key, id, "keys-2")
+	epk := newTestKey(t).private.Public()
+	edKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	invalid := map[string]string{
+		"should require a key agreement method":    testJwe(t, "ECDH-ES", id+"#keys-1", epk, ""),
+		"should require a method of the did":       testJwe(t, "ECDH-ES", id+"#keys-9", epk, ""),
+		"should require an ephemeral key on curve": testJwe(t, "ECDH-ES", id+"#keys-2", edKey, ""),
+		"should require a JWE":                     testEndpoint,
+	}
+
+	for message, jwe := range invalid {
+		update := encryptedUpdate(t, l, id, jwe)
+		assertErrorCode(t, s.SetEncryptedServiceEndpoint(l.ctx, id, jwe, signUpdate(t, l, key, id, update)), codeInvalidArgument, message)
+	}
+
+	jwe := testJwe(t, "ECDH-ES", id+"#keys-2", epk, "")
+	update := encryptedUpdate(t, l, id, jwe)
+	assertErrorCode(t, s.SetEncryptedServiceEndpoint(l.ctx, id, jwe, signUpdate(t, l, newTestKey(t), id, update)), codeInvalidSignature, "should require the did's key")
+	require.NoError(t, s.SetEncryptedServiceEndpoint(l.ctx, id, jwe, signUpdate(t, l, key, id, update)), "should store the encrypted endpoint")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, jwe, did.EncryptedServiceEndPoint, "should store the ciphertext")
+	assert.Empty(t, did.ServiceEndPoint, "should remove the plain endpoint")
+
+	result, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.True(t, result.DidResolutionMetadata.EncryptedEndpoints, "should flag encrypted endpoints")
+	assert.Equal(t, []Service{{Id: id + "#vcs", Type: "VerifiableCredentialService", ServiceEndpoint: jwe}}, result.DidDocument.Service, "should resolve to the ciphertext")
+	assert.Equal(t, []string{id + "#keys-2"}, result.DidDocument.KeyAgreement, "should list the key agreement key")
+
+	dereferenced, err := s.Dereference(l.ctx, id+"?service=vcs")
+	require.NoError(t, err)
+	assert.Equal(t, resolutionNotFound, dereferenced.DereferencingMetadata.Error, "should not dereference encrypted endpoints")
+
+	removal := updatableDetails(did)
+	removal.VerificationMethods = []VerificationMethod{}
+	err = s.RemoveVerificationMethod(l.ctx, id, id+"#keys-2", signUpdate(t, l, key, id, &removal))
+	assertErrorCode(t, err, codeInvalidArgument, "should keep the key agreement key of the encrypted endpoint")
+
+	plain := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, plain, signUpdate(t, l, key, id, plain)), "should replace the encrypted endpoint")
+	l.nextTx()
+
+	result, err = s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.False(t, result.DidResolutionMetadata.EncryptedEndpoints, "should not flag plain endpoints")
+	assert.Empty(t, result.DidDocument.KeyAgreement, "should drop the key agreement key with the encrypted endpoint")
+}
+
+func TestEncryptDidCommServiceEndpoint(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	addKeyAgreementKey(t, l, key, id, "keys-2")
+
+	service := didCommUpdate(t, l, id, testDidCommEndpoints)
+	require.NoError(t, setDidCommService(t, l, id, service, signUpdate(t, l, key, id, service)))
+	l.nextTx()
+
+	jwe := testJwe(t, "ECDH-ES", id+"#keys-2", newTestKey(t).private.Public(), "")
+	update := encryptedUpdate(t, l, id, jwe)
+	update.ServiceEndpoints = nil
+	require.NoError(t, s.SetEncryptedServiceEndpoint(l.ctx, id, jwe, signUpdate(t, l, key, id, update)), "should replace the endpoint objects")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, did.ServiceEndpoints, "should remove the endpoint objects")
+	assert.Equal(t, jwe, did.EncryptedServiceEndPoint, "should store the ciphertext")
+}
@@ -0,0 +1,400 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// DidEndorsementPolicy lists the organizations whose peers must all endorse
+// changes to a did
+type DidEndorsementPolicy struct {
+	DidNumber string   `json:"didNumber"`
+	Orgs      []string `json:"orgs"`
+}
+
+// privateDidCollections are the private data collections holding the private
+// material of dids, keyed by did number
+var privateDidCollections = []string{didPrivateCollection, didPersonalCollection}
+
+// setOwnerEndorsement sets the key-level endorsement policy of the given key so
+// that only peers of the submitting client's organization can endorse changes to it
+func setOwnerEndorsement(ctx contractapi.TransactionContextInterface, key string) error {
+	endorsementPolicy, err := ownerEndorsementPolicy(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return putEndorsementPolicy(ctx, key, endorsementPolicy)
+}
+
+// ownerEndorsementPolicy returns an endorsement policy satisfied by the peers of
+// the submitting client's organization
+func ownerEndorsementPolicy(ctx contractapi.TransactionContextInterface) (statebased.KeyEndorsementPolicy, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	return orgsEndorsementPolicy([]string{mspID})
+}
+
+// orgsEndorsementPolicy returns an endorsement policy requiring the peers of
+// every given organization
+func orgsEndorsementPolicy(orgs []string) (statebased.KeyEndorsementPolicy, error) {
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, orgs...)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to add %s to endorsement policy. %s", strings.Join(orgs, ", "), err.Error())
+	}
+
+	return endorsementPolicy, nil
+}
+
+// setPrivateOwnerEndorsement sets the key-level endorsement policy of the private
+// data stored with given key in a collection so that only peers of the
+// submitting client's organization can endorse changes to it, unless a policy
+// was set before, see SetPrivateDidEndorsement
+func setPrivateOwnerEndorsement(ctx contractapi.TransactionContextInterface, collection string, key string) error {
+	policy, err := ctx.GetStub().GetPrivateDataValidationParameter(collection, key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read endorsement policy of %s in %s. %s", printableKey(key), collection, err.Error())
+	}
+
+	if len(policy) > 0 {
+		return nil
+	}
+
+	endorsementPolicy, err := ownerEndorsementPolicy(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return putPrivateEndorsementPolicy(ctx, collection, key, endorsementPolicy)
+}
+
+// putPrivateEndorsementPolicy writes the key-level endorsement policy for the
+// private data stored with given key in a collection
+func putPrivateEndorsementPolicy(ctx contractapi.TransactionContextInterface, collection string, key string, endorsementPolicy statebased.KeyEndorsementPolicy) error {
+	policy, err := endorsementPolicy.Policy()
+
+	if err != nil {
+		return fmt.Errorf("Failed to create endorsement policy bytes. %s", err.Error())
+	}
+
+	return ctx.GetStub().SetPrivateDataValidationParameter(collection, key, policy)
+}
+
+// decodeEndorsingOrgs decodes a JSON array of the MSP IDs of the organizations
+// that must endorse changes to a did, which must list at least one
+// organization and each once
+func decodeEndorsingOrgs(didNumber string, orgsJSON string) ([]string, error) {
+	orgs := []string{}
+
+	if err := decodeStrict([]byte(orgsJSON), &orgs); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode organizations. %s", err.Error())
+	}
+
+	if len(orgs) == 0 {
+		return nil, newError(codeInvalidArgument, "%s must keep at least one endorsing organization", didNumber)
+	}
+
+	for i, mspID := range orgs {
+		if strings.TrimSpace(mspID) == "" {
+			return nil, newError(codeInvalidArgument, "MSP IDs must not be empty")
+		}
+
+		if containsString(orgs[:i], mspID) {
+			return nil, newError(codeInvalidArgument, "%s is listed more than once", mspID)
+		}
+	}
+
+	return orgs, nil
+}
+
+// getEndorsementPolicy reads the key-level endorsement policy currently set for
+// the given key
+func getEndorsementPolicy(ctx contractapi.TransactionContextInterface, key string) (statebased.KeyEndorsementPolicy, error) {
+	policy, err := ctx.GetStub().GetStateValidationParameter(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read endorsement policy of %s. %s", printableKey(key), err.Error())
+	}
+
+	return statebased.NewStateEP(policy)
+}
+
+// putEndorsementPolicy writes the key-level endorsement policy for the given key
+func putEndorsementPolicy(ctx contractapi.TransactionContextInterface, key string, endorsementPolicy statebased.KeyEndorsementPolicy) error {
+	policy, err := endorsementPolicy.Policy()
+
+	if err != nil {
+		return fmt.Errorf("Failed to create endorsement policy bytes. %s", err.Error())
+	}
+
+	return ctx.GetStub().SetStateValidationParameter(key, policy)
+}
+
+// AddDidEndorser adds an organization to the set of organizations that must
+// endorse changes to the did stored with the given key. The submitting client
+// must control the did, which must be in the namespace of its organization
+func (s *DidContract) AddDidEndorser(ctx contractapi.TransactionContextInterface, didNumber string, mspID string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if err := assertNamespace(ctx, didNumber); err != nil {
+		return err
+	}
+
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	endorsementPolicy, err := getEndorsementPolicy(ctx, key)
+
+	if err != nil {
+		return err
+	}
+
+	err = endorsementPolicy.AddOrgs(statebased.RoleTypePeer, mspID)
+
+	if err != nil {
+		return fmt.Errorf("Failed to add %s to endorsement policy. %s", mspID, err.Error())
+	}
+
+	if err := putEndorsementPolicy(ctx, key, endorsementPolicy); err != nil {
+		return err
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// RemoveDidEndorser removes an organization from the set of organizations that
+// must endorse changes to the did stored with the given key. The last endorsing
+// organization cannot be removed. The submitting client must control the did,
+// which must be in the namespace of its organization
+func (s *DidContract) RemoveDidEndorser(ctx contractapi.TransactionContextInterface, didNumber string, mspID string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if err := assertNamespace(ctx, didNumber); err != nil {
+		return err
+	}
+
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	endorsementPolicy, err := getEndorsementPolicy(ctx, key)
+
+	if err != nil {
+		return err
+	}
+
+	if !containsString(endorsementPolicy.ListOrgs(), mspID) {
+		return newError(codeNotFound, "%s is not an endorser of %s", mspID, didNumber)
+	}
+
+	endorsementPolicy.DelOrgs(mspID)
+
+	if len(endorsementPolicy.ListOrgs()) == 0 {
+		return newError(codeInvalidArgument, "Cannot remove %s, %s must keep at least one endorsing organization", mspID, didNumber)
+	}
+
+	if err := putEndorsementPolicy(ctx, key, endorsementPolicy); err != nil {
+		return err
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// SetDidEndorsementPolicy replaces the organizations that must endorse changes to
+// the did stored with the given key with the MSP IDs of the given JSON array,
+// so that changes to critical dids can require several organizations. The
+// submitting client must control the did, which must be in the namespace of its
+// organization. The organization leaves the policy if it is not listed
+func (s *DidContract) SetDidEndorsementPolicy(ctx contractapi.TransactionContextInterface, didNumber string, orgsJSON string) error {
+	orgs, err := decodeEndorsingOrgs(didNumber, orgsJSON)
+
+	if err != nil {
+		return err
+	}
+
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if err := assertNamespace(ctx, didNumber); err != nil {
+		return err
+	}
+
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	endorsementPolicy, err := orgsEndorsementPolicy(orgs)
+
+	if err != nil {
+		return err
+	}
+
+	if err := putEndorsementPolicy(ctx, key, endorsementPolicy); err != nil {
+		return err
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// SetPrivateDidEndorsement replaces the organizations that must endorse changes
+// to the private material of the did stored with the given key, in every
+// private data collection holding some, with the MSP IDs of the given JSON
+// array. Private material is endorsed by the organization that wrote it
+// until then. The submitting client must control the did, which must be in the
+// namespace of its organization
+func (s *DidContract) SetPrivateDidEndorsement(ctx contractapi.TransactionContextInterface, didNumber string, orgsJSON string) error {
+	orgs, err := decodeEndorsingOrgs(didNumber, orgsJSON)
+
+	if err != nil {
+		return err
+	}
+
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if err := assertNamespace(ctx, didNumber); err != nil {
+		return err
+	}
+
+	endorsementPolicy, err := orgsEndorsementPolicy(orgs)
+
+	if err != nil {
+		return err
+	}
+
+	found := false
+
+	for _, collection := range privateDidCollections {
+		hash, err := ctx.GetStub().GetPrivateDataHash(collection, didNumber)
+
+		if err != nil {
+			return fmt.Errorf("Failed to read from private data collection. %s", err.Error())
+		}
+
+		if hash == nil {
+			continue
+		}
+
+		if err := putPrivateEndorsementPolicy(ctx, collection, didNumber, endorsementPolicy); err != nil {
+			return err
+		}
+
+		found = true
+	}
+
+	if !found {
+		return newError(codeNotFound, "%s has no private data", didNumber)
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// GetDidEndorsementPolicy returns the organizations that must endorse changes
+// to the did stored with the given key, sorted by MSP ID
+func (s *DidContract) GetDidEndorsementPolicy(ctx contractapi.TransactionContextInterface, didNumber string) (*DidEndorsementPolicy, error) {
+	if _, err := s.QueryDidByKey(ctx, didNumber); err != nil {
+		return nil, err
+	}
+
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	endorsementPolicy, err := getEndorsementPolicy(ctx, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := endorsementPolicy.ListOrgs()
+	sort.Strings(orgs)
+
+	return &DidEndorsementPolicy{DidNumber: didNumber, Orgs: orgs}, nil
+}
+
+// containsString reports whether value is present in values
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,188 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddDidEndorser(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	err := s.AddDidEndorser(l.ctx, id, otherMSPID)
+	require.NoError(t, err, "should add the endorser")
+	assert.ElementsMatch(t, []string{testMSPID, otherMSPID}, l.endorsers(t, id), "should keep the existing endorsers")
+
+	err = s.AddDidEndorser(l.ctx, "DID9", otherMSPID)
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.setClient(otherClientID, testMSPID)
+	err = s.AddDidEndorser(l.ctx, id, "Org3MSP")
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller add endorsers")
+	assert.ElementsMatch(t, []string{testMSPID, otherMSPID}, l.endorsers(t, id), "should keep the policy")
+	l.setClient(testClientID, testMSPID)
+
+	l.stub.GetStateValidationParameterReturns(nil, errors.New("GetStateValidationParameter error"))
+	err = s.AddDidEndorser(l.ctx, id, otherMSPID)
+	assert.EqualError(t, err, "Failed to read endorsement policy of did "+testMSPID+" "+id+". GetStateValidationParameter error", "should return policy errors")
+}
+
+func TestRemoveDidEndorser(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+	require.NoError(t, s.AddDidEndorser(l.ctx, id, otherMSPID))
+
+	err := s.RemoveDidEndorser(l.ctx, id, otherMSPID)
+	require.NoError(t, err, "should remove the endorser")
+	assert.Equal(t, []string{testMSPID}, l.endorsers(t, id), "should keep the other endorsers")
+
+	err = s.RemoveDidEndorser(l.ctx, id, otherMSPID)
+	assertErrorCode(t, err, codeNotFound, "should fail for organizations that do not endorse the did")
+
+	err = s.RemoveDidEndorser(l.ctx, id, testMSPID)
+	assertErrorCode(t, err, codeInvalidArgument, "should keep at least one endorser")
+
+	err = s.RemoveDidEndorser(l.ctx, "DID9", testMSPID)
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	require.NoError(t, s.AddDidEndorser(l.ctx, id, otherMSPID))
+	l.setClient(otherClientID, testMSPID)
+	err = s.RemoveDidEndorser(l.ctx, id, otherMSPID)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller remove endorsers")
+}
+
+func TestSetDidEndorsementPolicy(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	policy, err := s.GetDidEndorsementPolicy(l.ctx, id)
+	require.NoError(t, err, "should return the policy")
+	assert.Equal(t, &DidEndorsementPolicy{DidNumber: id, Orgs: []string{testMSPID}}, policy, "should start with the owning organization")
+
+	err = s.SetDidEndorsementPolicy(l.ctx, id, `["Org3MSP", "`+testMSPID+`", "`+otherMSPID+`"]`)
+	require.NoError(t, err, "should set the policy")
+	assert.ElementsMatch(t, []string{testMSPID, otherMSPID, "Org3MSP"}, l.endorsers(t, id), "should require every listed organization")
+
+	policy, err = s.GetDidEndorsementPolicy(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{testMSPID, otherMSPID, "Org3MSP"}, policy.Orgs, "should sort the organizations")
+
+	require.NoError(t, s.SetDidEndorsementPolicy(l.ctx, id, `["`+otherMSPID+`"]`), "should replace the policy")
+	assert.Equal(t, []string{otherMSPID}, l.endorsers(t, id), "should drop organizations that are not listed")
+
+	err = s.SetDidEndorsementPolicy(l.ctx, id, `[]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should keep at least one endorser")
+
+	err = s.SetDidEndorsementPolicy(l.ctx, id, `["Org3MSP", "Org3MSP"]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject duplicate organizations")
+
+	err = s.SetDidEndorsementPolicy(l.ctx, id, `[""]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject empty MSP IDs")
+
+	err = s.SetDidEndorsementPolicy(l.ctx, id, `"Org3MSP"`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a JSON array")
+
+	err = s.SetDidEndorsementPolicy(l.ctx, id, `["Org3MSP"] ["Org3MSP"]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject trailing data")
+
+	l.setClient(otherClientID, testMSPID)
+	err = s.SetDidEndorsementPolicy(l.ctx, id, `["`+testMSPID+`"]`)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller set the policy")
+	assert.Equal(t, []string{otherMSPID}, l.endorsers(t, id), "should keep the policy")
+	l.setClient(testClientID, testMSPID)
+
+	err = s.SetDidEndorsementPolicy(l.ctx, "DID9", `["Org3MSP"]`)
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.SetDidEndorsementPolicy(l.ctx, id, `["`+otherMSPID+`"]`)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the owning organization set the policy")
+
+	_, err = s.GetDidEndorsementPolicy(l.ctx, "DID9")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.stub.GetStateValidationParameterReturns(nil, errors.New("GetStateValidationParameter error"))
+	_, err = s.GetDidEndorsementPolicy(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read endorsement policy of did "+testMSPID+" "+id+". GetStateValidationParameter error", "should return policy errors")
+}
+
+func TestSetPrivateDidEndorsement(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	createPrivateDid(t, l, newTestKey(t))
+
+	assert.Equal(t, []string{testMSPID}, l.privateEndorsers(t, didPrivateCollection, "DID5"), "should let the writing organization endorse the private details")
+
+	err := s.SetPrivateDidEndorsement(l.ctx, "DID5", `["`+testMSPID+`", "`+otherMSPID+`"]`)
+	require.NoError(t, err, "should set the policy of the private details")
+	assert.ElementsMatch(t, []string{testMSPID, otherMSPID}, l.privateEndorsers(t, didPrivateCollection, "DID5"), "should require every listed organization")
+	assert.Nil(t, l.privateValidation[didPersonalCollection]["DID5"], "should skip collections without private data of the did")
+	l.nextTx()
+
+	setPersonalData(l, `{"fields":{"name":"Alice Example"},"salt":"s1"}`)
+	require.NoError(t, s.SetDidPersonalData(l.ctx, "DID5"))
+	l.nextTx()
+
+	assert.Equal(t, []string{testMSPID}, l.privateEndorsers(t, didPersonalCollection, "DID5"), "should let the writing organization endorse the personal data")
+
+	require.NoError(t, s.SetPrivateDidEndorsement(l.ctx, "DID5", `["`+otherMSPID+`"]`))
+	assert.Equal(t, []string{otherMSPID}, l.privateEndorsers(t, didPrivateCollection, "DID5"), "should replace the policy in every collection")
+	assert.Equal(t, []string{otherMSPID}, l.privateEndorsers(t, didPersonalCollection, "DID5"))
+	l.nextTx()
+
+	setPersonalData(l, `{"fields":{"name":"Alice Rewritten"},"salt":"s2"}`)
+	require.NoError(t, s.SetDidPersonalData(l.ctx, "DID5"))
+	assert.Equal(t, []string{otherMSPID}, l.privateEndorsers(t, didPersonalCollection, "DID5"), "should keep the policy when the private data is rewritten")
+
+	id := createTestDid(t, l, newTestKey(t))
+	err = s.SetPrivateDidEndorsement(l.ctx, id, `["`+otherMSPID+`"]`)
+	assertErrorCode(t, err, codeNotFound, "should fail for dids without private data")
+
+	err = s.SetPrivateDidEndorsement(l.ctx, "DID5", `[]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should keep at least one endorser")
+
+	err = s.SetPrivateDidEndorsement(l.ctx, "DID9", `["`+otherMSPID+`"]`)
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.SetPrivateDidEndorsement(l.ctx, "DID5", `["`+otherMSPID+`"]`)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the owning organization set the policy")
+
+	l.setClient(otherClientID, testMSPID)
+	err = s.SetPrivateDidEndorsement(l.ctx, "DID5", `["`+testMSPID+`"]`)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller set the policy")
+	assert.Equal(t, []string{otherMSPID}, l.privateEndorsers(t, didPrivateCollection, "DID5"), "should keep the policy")
+
+	l.setClient(testClientID, testMSPID)
+	l.stub.GetPrivateDataHashReturns(nil, errors.New("GetPrivateDataHash error"))
+	err = s.SetPrivateDidEndorsement(l.ctx, "DID5", `["`+otherMSPID+`"]`)
+	assert.EqualError(t, err, "Failed to read from private data collection. GetPrivateDataHash error", "should return private data errors")
+
+	l.stub.GetPrivateDataValidationParameterReturns(nil, errors.New("GetPrivateDataValidationParameter error"))
+	err = setPrivateOwnerEndorsement(l.ctx, didPrivateCollection, "DID5")
+	assert.EqualError(t, err, "Failed to read endorsement policy of DID5 in didPrivateCollection. GetPrivateDataValidationParameter error", "should return policy errors")
+}
@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PrivateServiceEndpoint describes a service endpoint held in an organization's
+// implicit private data collection together with the salt used to hash it
+type PrivateServiceEndpoint struct {
+	ServiceEndPoint string `json:"serviceEndPoint"`
+	Salt            string `json:"salt"`
+}
+
+// implicitCollection returns the name of the implicit private data collection
+// of the submitting client's organization
+func implicitCollection(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	return "_implicit_org_" + mspID, nil
+}
+
+// saltedHash returns the hash of a service endpoint combined with its salt
+func saltedHash(endpoint string, salt string) string {
+	return hashValue(salt + endpoint)
+}
+
+// SetPrivateServiceEndpoint moves the service endpoint of a did into the
+// implicit private data collection of the caller's organization, leaving only
+// its salted hash in the public document. It replaces the endpoint objects of
+// DIDCommMessaging services and encrypted endpoints. Like UpdateDid, the change
+// must be signed and approved as described by updateDid, the signature covering
+// the updatable details with the plain endpoint
+func (s *DidContract) SetPrivateServiceEndpoint(ctx contractapi.TransactionContextInterface, didNumber string, endpoint string, salt string, signature string) error {
+	if salt == "" {
+		return newError(codeInvalidArgument, "Salt must not be empty")
+	}
+
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.ServiceEndPoint = endpoint
+	update.ServiceEndPointHash = ""
+	update.ServiceEndpoints = nil
+	update.EncryptedServiceEndPoint = ""
+
+	return s.updateDid(ctx, didNumber, &update, signature, salt)
+}
+
+// putPrivateServiceEndpoint writes the service endpoint of the did to the implicit
+// private data collection of the caller's organization and replaces it in the
+// public document with its salted hash
+func putPrivateServiceEndpoint(ctx contractapi.TransactionContextInterface, didNumber string, did *Did, salt string) error {
+	if salt == "" {
+		return newError(codeInvalidArgument, "Salt must not be empty")
+	}
+
+	if err := validateServiceEndpoint(ctx, did.ServiceEndPoint); err != nil {
+		return err
+	}
+
+	collection, err := implicitCollection(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	private := PrivateServiceEndpoint{ServiceEndPoint: did.ServiceEndPoint, Salt: salt}
+	privateAsBytes, err := marshalRecord(didNumber, private)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutPrivateData(collection, didNumber, privateAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to private data collection. %s", err.Error())
+	}
+
+	did.ServiceEndPointHash = saltedHash(did.ServiceEndPoint, salt)
+	did.ServiceEndPoint = ""
+
+	return nil
+}
+
+// QueryPrivateServiceEndpoint returns the service endpoint of a did held in the
+// implicit private data collection of the caller's organization
+func (s *DidContract) QueryPrivateServiceEndpoint(ctx contractapi.TransactionContextInterface, didNumber string) (*PrivateServiceEndpoint, error) {
+	collection, err := implicitCollection(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	privateAsBytes, err := ctx.GetStub().GetPrivateData(collection, didNumber)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from private data collection. %s", err.Error())
+	}
+
+	if privateAsBytes == nil {
+		return nil, newError(codeNotFound, "%s has no private service endpoint", didNumber)
+	}
+
+	private := new(PrivateServiceEndpoint)
+
+	if err := unmarshalRecord(didNumber, privateAsBytes, private); err != nil {
+		return nil, err
+	}
+
+	return private, nil
+}
+
+// VerifyServiceEndpoint checks whether an endpoint and salt disclosed by the
+// controller of a did match the salted hash in its public document
+func (s *DidContract) VerifyServiceEndpoint(ctx contractapi.TransactionContextInterface, didNumber string, endpoint string, salt string) (bool, error) {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return false, err
+	}
+
+	if did.ServiceEndPointHash == "" {
+		return false, newError(codeNotFound, "%s does not have a private service endpoint", didNumber)
+	}
+
+	return saltedHash(endpoint, salt) == did.ServiceEndPointHash, nil
+}
@@ -0,0 +1,158 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signPrivateEndpoint returns the signature of moving the service endpoint of
+// the did into the private data collection
+func signPrivateEndpoint(t *testing.T, l *testLedger, key *testKey, didNumber string, endpoint string) string {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.ServiceEndPoint = endpoint
+	update.ServiceEndPointHash = ""
+	update.ServiceEndpoints = nil
+	update.EncryptedServiceEndPoint = ""
+
+	return signUpdate(t, l, key, didNumber, &update)
+}
+
+// setPrivateEndpoint moves the service endpoint of the did into the private
+// data collection with salt
+func setPrivateEndpoint(t *testing.T, l *testLedger, key *testKey, didNumber string, endpoint string, salt string) {
+	signature := signPrivateEndpoint(t, l, key, didNumber, endpoint)
+	require.NoError(t, new(DidContract).SetPrivateServiceEndpoint(l.ctx, didNumber, endpoint, salt, signature))
+}
+
+func TestSetPrivateServiceEndpoint(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	signature := signPrivateEndpoint(t, l, key, id, "https://internal.example.com/vc/")
+
+	err := s.SetPrivateServiceEndpoint(l.ctx, id, "https://internal.example.com/vc/", "", signature)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a salt")
+
+	err = s.SetPrivateServiceEndpoint(l.ctx, id, "http://internal.example.com/vc/", "salt", signPrivateEndpoint(t, l, key, id, "http://internal.example.com/vc/"))
+	assertErrorCode(t, err, codeInvalidArgument, "should validate the endpoint")
+
+	err = s.SetPrivateServiceEndpoint(l.ctx, id, "https://internal.example.com/vc/", "salt", "")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature")
+
+	err = s.SetPrivateServiceEndpoint(l.ctx, id, "https://internal.example.com/other/", "salt", signature)
+	assertErrorCode(t, err, codeInvalidSignature, "should require the signature to cover the endpoint")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.SetPrivateServiceEndpoint(l.ctx, id, "https://internal.example.com/vc/", "salt", signature)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller set the endpoint")
+
+	l.setClient(testClientID, testMSPID)
+	err = s.SetPrivateServiceEndpoint(l.ctx, id, "https://internal.example.com/vc/", "salt", signature)
+	require.NoError(t, err, "should set the private endpoint")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "", did.ServiceEndPoint, "should remove the endpoint from the public document")
+	assert.Equal(t, saltedHash("https://internal.example.com/vc/", "salt"), did.ServiceEndPointHash, "should store the salted hash")
+	assert.NotNil(t, l.private["_implicit_org_"+testMSPID][id], "should write to the implicit collection of the caller")
+
+	l.nextTx()
+	l.stub.PutPrivateDataReturns(errors.New("PutPrivateData error"))
+	err = s.SetPrivateServiceEndpoint(l.ctx, id, "https://internal.example.com/vc/", "salt", signPrivateEndpoint(t, l, key, id, "https://internal.example.com/vc/"))
+	assert.EqualError(t, err, "Failed to put to private data collection. PutPrivateData error", "should return private data errors")
+}
+
+func TestSetPrivateServiceEndpointDeactivated(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, id, signUpdate(t, l, key, id, &update)))
+	l.nextTx()
+
+	err = s.SetPrivateServiceEndpoint(l.ctx, id, "https://internal.example.com/vc/", "salt", signPrivateEndpoint(t, l, key, id, "https://internal.example.com/vc/"))
+	assertErrorCode(t, err, codeDidDeactivated, "should not change the services of deactivated dids")
+}
+
+func TestQueryPrivateServiceEndpoint(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	_, err := s.QueryPrivateServiceEndpoint(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should fail for dids without a private endpoint")
+
+	setPrivateEndpoint(t, l, key, id, "https://internal.example.com/vc/", "salt")
+
+	private, err := s.QueryPrivateServiceEndpoint(l.ctx, id)
+	assert.Nil(t, err, "should return the private endpoint")
+	assert.Equal(t, &PrivateServiceEndpoint{ServiceEndPoint: "https://internal.example.com/vc/", Salt: "salt"}, private, "should return the endpoint and salt")
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = s.QueryPrivateServiceEndpoint(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should read the collection of the caller's organization")
+
+	l.identity.GetMSPIDReturns("", errors.New("GetMSPID error"))
+	_, err = s.QueryPrivateServiceEndpoint(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read client MSP ID. GetMSPID error", "should need the client MSP ID")
+
+	l.stub.GetPrivateDataReturns(nil, errors.New("GetPrivateData error"))
+	l.setClient(testClientID, testMSPID)
+	_, err = s.QueryPrivateServiceEndpoint(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read from private data collection. GetPrivateData error", "should return private data errors")
+}
+
+func TestVerifyServiceEndpoint(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	_, err := s.VerifyServiceEndpoint(l.ctx, id, testEndpoint, "salt")
+	assertErrorCode(t, err, codeNotFound, "should fail for dids without a private endpoint")
+
+	setPrivateEndpoint(t, l, key, id, "https://internal.example.com/vc/", "salt")
+
+	matches, err := s.VerifyServiceEndpoint(l.ctx, id, "https://internal.example.com/vc/", "salt")
+	assert.Nil(t, err, "should compare the disclosed endpoint")
+	assert.True(t, matches, "should match the disclosed endpoint and salt")
+
+	matches, err = s.VerifyServiceEndpoint(l.ctx, id, "https://internal.example.com/vc/", "pepper")
+	assert.Nil(t, err, "should compare the disclosed endpoint")
+	assert.False(t, matches, "should not match another salt")
+
+	_, err = s.VerifyServiceEndpoint(l.ctx, "DID9", testEndpoint, "salt")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+}
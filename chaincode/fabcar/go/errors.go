@@ -0,0 +1,103 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Codes of the errors returned by the contracts
+const (
+	codeInvalidArgument   = "INVALID_ARGUMENT"
+	codeDidNotFound       = "DID_NOT_FOUND"
+	codeDidAlreadyExists  = "DID_ALREADY_EXISTS"
+	codeDidDeactivated    = "DID_DEACTIVATED"
+	codeNotFound          = "NOT_FOUND"
+	codeAlreadyExists     = "ALREADY_EXISTS"
+	codeUnauthorized      = "UNAUTHORIZED"
+	codeInvalidSignature  = "INVALID_SIGNATURE"
+	codeChallengeExpired  = "CHALLENGE_EXPIRED"
+	codeConflict          = "CONFLICT"
+	codeCorruptRecord     = "CORRUPT_RECORD"
+	codeBatchRejected     = "BATCH_REJECTED"
+	codeQuotaExceeded     = "QUOTA_EXCEEDED"
+	codeFeeRejected       = "FEE_REJECTED"
+	codeUnknownFunction   = "UNKNOWN_FUNCTION"
+	codeNotInitialized    = "NOT_INITIALIZED"
+	codeTransactionFailed = "TRANSACTION_FAILED"
+)
+
+// ContractError describes a failed transaction. It is returned to clients as the
+// JSON encoded message of the chaincode response, so that they can branch on
+// Code rather than on the wording of Message
+type ContractError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (e *ContractError) Error() string {
+	errorAsBytes, _ := json.Marshal(e)
+
+	return string(errorAsBytes)
+}
+
+// newError returns a ContractError with given code and formatted message
+func newError(code string, format string, args ...interface{}) *ContractError {
+	return &ContractError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// errorMessage returns the message of err without its code
+func errorMessage(err error) string {
+	if contractErr, ok := err.(*ContractError); ok {
+		return contractErr.Message
+	}
+
+	return err.Error()
+}
+
+// isContractError reports whether message is an encoded ContractError
+func isContractError(message string) bool {
+	contractErr := new(ContractError)
+
+	return json.Unmarshal([]byte(message), contractErr) == nil && contractErr.Code != ""
+}
+
+// codedChaincode returns every failed transaction with a ContractError message.
+// Errors raised without a code, including those of the contract API itself, are
+// returned with codeTransactionFailed
+type codedChaincode struct {
+	*contractapi.ContractChaincode
+}
+
+// Invoke invokes the transaction and encodes its error if it failed
+func (c *codedChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	response := c.ContractChaincode.Invoke(stub)
+
+	if response.Status >= shim.ERRORTHRESHOLD && !isContractError(response.Message) {
+		response.Message = newError(codeTransactionFailed, "%s", response.Message).Error()
+	}
+
+	return response
+}
@@ -0,0 +1,108 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCodedStub returns a mock stub running the chaincode as started by main,
+// invoked by a client of testMSPID
+func newCodedStub(t *testing.T) *shimtest.MockStub {
+	chaincode, err := newChaincode()
+	require.NoError(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "user1"}, NotBefore: testStart, NotAfter: testStart.Add(24 * time.Hour)}
+	certificate, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: testMSPID, IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate})})
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub(testChaincode, &codedChaincode{chaincode})
+	stub.Creator = creator
+
+	return stub
+}
+
+// responseError decodes the ContractError of a failed response
+func responseError(t *testing.T, message string) *ContractError {
+	contractErr := new(ContractError)
+	require.NoError(t, json.Unmarshal([]byte(message), contractErr), "should encode the error as JSON")
+
+	return contractErr
+}
+
+func TestContractError(t *testing.T) {
+	err := newError(codeDidNotFound, "%s does not exist", "DID9")
+	assert.Equal(t, `{"code":"DID_NOT_FOUND","message":"DID9 does not exist"}`, err.Error(), "should encode the code and message")
+
+	err.Details = map[string]string{"0": "failed"}
+	assert.Equal(t, `{"code":"DID_NOT_FOUND","message":"DID9 does not exist","details":{"0":"failed"}}`, err.Error(), "should encode the details")
+
+	assert.Equal(t, "DID9 does not exist", errorMessage(err), "should return the message without the code")
+	assert.Equal(t, "plain", errorMessage(errors.New("plain")), "should return uncoded errors as they are")
+
+	assert.True(t, isContractError(err.Error()), "should recognize encoded errors")
+	assert.False(t, isContractError("Function Unknown not found"), "should not recognize plain messages")
+	assert.False(t, isContractError(`{"message":"no code"}`), "should require a code")
+
+	corrupt := &CorruptRecordError{Key: "DID9", Err: errors.New("unexpected EOF")}
+	assert.True(t, isContractError(corrupt.Error()), "should encode corrupt records with a code")
+	assert.Equal(t, codeCorruptRecord, responseError(t, corrupt.Error()).Code, "should report corrupt records")
+}
+
+func TestCodedChaincode(t *testing.T) {
+	stub := newCodedStub(t)
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("QueryDidByKey"), []byte("DID9")})
+	assert.Equal(t, int32(shim.ERROR), response.Status, "should fail for unknown dids")
+	assert.Equal(t, codeDidNotFound, responseError(t, response.Message).Code, "should keep the code of contract errors")
+
+	response = stub.MockInvoke("tx2", [][]byte{[]byte("UnknownContract:QueryDidByKey"), []byte("DID9")})
+	assert.Equal(t, int32(shim.ERROR), response.Status, "should fail for unknown contracts")
+
+	contractErr := responseError(t, response.Message)
+	assert.Equal(t, codeTransactionFailed, contractErr.Code, "should code errors of the contract API")
+	assert.Contains(t, contractErr.Message, "UnknownContract", "should keep the message of uncoded errors")
+
+	response = stub.MockInvoke("tx3", [][]byte{[]byte("QueryEndpointSchemes")})
+	assert.Equal(t, int32(shim.OK), response.Status, "should not change successful responses")
+	assert.JSONEq(t, `{"schemes":["https","didcomm"]}`, string(response.Payload), "should return the payload")
+}
@@ -0,0 +1,171 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Operations of the chaincode events emitted when a did changes. The event of a
+// did is named did.<operation>.<method> after the method of its id, such as
+// did.created.fabric or did.key.rotated.fabric, so that gateway listeners can
+// select events by name without decoding their payload
+const (
+	didCreatedOperation     = "created"
+	didUpdatedOperation     = "updated"
+	didDeactivatedOperation = "deactivated"
+)
+
+// Names of the chaincode events emitted when several dids change in one
+// transaction
+const (
+	didsCreatedEvent = "dids.created"
+	didsPurgedEvent  = "dids.purged"
+)
+
+// didOperations names the operation of the events of the transaction functions
+// that change a part of a did. Other functions emit did.updated, or
+// did.deactivated when the did is deactivated
+var didOperations = map[string]string{
+	"AddVerificationMethod":       "key.added",
+	"RemoveVerificationMethod":    "key.removed",
+	"RotateKey":                   "key.rotated",
+	"AddRecoveryMethod":           "recovery.added",
+	"RemoveRecoveryMethod":        "recovery.removed",
+	"RecoverDid":                  "recovered",
+	"SetMultisigPolicy":           "multisig.updated",
+	"UpdateService":               "service.updated",
+	"SetDidCommService":           "service.updated",
+	"SetPrivateServiceEndpoint":   "service.updated",
+	"SetEncryptedServiceEndpoint": "service.updated",
+	"AcceptTransfer":              "transferred",
+	"RenewDid":                    "renewed",
+	"DeleteDid":                   "deleted",
+	"RestoreDid":                  "restored",
+}
+
+// didEventName returns the name of the event of operation on the did with given
+// id. Ids that are not of the form did:<method>:<id> leave the method out
+func didEventName(operation string, id string) string {
+	parts := strings.SplitN(id, ":", 3)
+
+	if len(parts) != 3 || parts[0] != "did" || parts[1] == "" {
+		return "did." + operation
+	}
+
+	return "did." + operation + "." + parts[1]
+}
+
+// updateOperation returns the operation of the event emitted when the called
+// transaction function writes did
+func updateOperation(ctx contractapi.TransactionContextInterface, did *Did) string {
+	if operation, ok := didOperations[transactionFunction(ctx)]; ok {
+		return operation
+	}
+
+	if did.Deactivated {
+		return didDeactivatedOperation
+	}
+
+	return didUpdatedOperation
+}
+
+// DidEvent describes the payload of the events emitted when a did changes. The
+// document is the public did document as written to the world state
+type DidEvent struct {
+	DidNumber string `json:"didNumber"`
+	Did       *Did   `json:"did"`
+}
+
+// DidHash identifies the current document of a did by the hex SHA-256 of its
+// canonical JSON, the encoding carried by did events, so that off-chain caches
+// built from events can check a document against the ledger
+type DidHash struct {
+	DidNumber string `json:"didNumber"`
+	Hash      string `json:"hash"`
+}
+
+// DidsEvent describes the payload of the event emitted when several dids change
+// in one transaction
+type DidsEvent struct {
+	Dids []DidEvent `json:"dids"`
+}
+
+// emitDidEvent sets the chaincode event of the transaction to a DidEvent. A
+// transaction carries a single event, so a later event replaces an earlier one.
+// Events are part of the endorsement and are encoded canonically like records
+func emitDidEvent(ctx contractapi.TransactionContextInterface, operation string, didNumber string, did *Did) error {
+	name := didEventName(operation, did.Id)
+	eventAsBytes, err := canonicalJSON(DidEvent{DidNumber: didNumber, Did: did})
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode event %s. %s", name, err.Error())
+	}
+
+	err = ctx.GetStub().SetEvent(name, eventAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to set event %s. %s", name, err.Error())
+	}
+
+	return nil
+}
+
+// emitDidsEvent sets the chaincode event of the transaction to a DidsEvent,
+// replacing the events of the individual dids
+func emitDidsEvent(ctx contractapi.TransactionContextInterface, name string, events []DidEvent) error {
+	eventAsBytes, err := canonicalJSON(DidsEvent{Dids: events})
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode event %s. %s", name, err.Error())
+	}
+
+	err = ctx.GetStub().SetEvent(name, eventAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to set event %s. %s", name, err.Error())
+	}
+
+	return nil
+}
+
+// QueryDidHash returns the hash of the document of the did with given id
+func (s *DidContract) QueryDidHash(ctx contractapi.TransactionContextInterface, id string) (*DidHash, error) {
+	result, err := findDidById(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	didAsBytes, err := canonicalJSON(result.Record)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode %s. %s", id, err.Error())
+	}
+
+	hash := sha256.Sum256(didAsBytes)
+
+	return &DidHash{DidNumber: result.Key, Hash: hex.EncodeToString(hash[:])}, nil
+}
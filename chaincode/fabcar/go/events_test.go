@@ -0,0 +1,77 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDidEventName(t *testing.T) {
+	assert.Equal(t, "did.created.example", didEventName(didCreatedOperation, "did:example:123456789abcdefghi"), "should append the did method")
+	assert.Equal(t, "did.key.rotated.fabric", didEventName("key.rotated", "did:fabric:abc:def"), "should only take the method from the id")
+	assert.Equal(t, "did.updated", didEventName(didUpdatedOperation, "DID1"), "should leave out the method of other ids")
+	assert.Equal(t, "did.updated", didEventName(didUpdatedOperation, "did::abc"), "should leave out empty methods")
+}
+
+func TestUpdateOperation(t *testing.T) {
+	l := newTestLedger(t)
+
+	assert.Equal(t, didUpdatedOperation, updateOperation(l.ctx, &Did{}), "should default to updated")
+	assert.Equal(t, didDeactivatedOperation, updateOperation(l.ctx, &Did{Deactivated: true}), "should report deactivations")
+
+	l.stub.GetFunctionAndParametersReturns("did:rotateKey", []string{})
+	assert.Equal(t, "key.rotated", updateOperation(l.ctx, &Did{}), "should name the operation of the called function")
+
+	l.stub.GetFunctionAndParametersReturns("DeleteDid", []string{})
+	assert.Equal(t, "deleted", updateOperation(l.ctx, &Did{Deactivated: true}), "should prefer the operation of the function to deactivated")
+}
+
+func TestQueryDidHash(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+
+	_, payload := l.event(t)
+	event := struct {
+		Did json.RawMessage `json:"did"`
+	}{}
+	require.NoError(t, json.Unmarshal(payload, &event))
+	hash := sha256.Sum256(event.Did)
+
+	didHash, err := s.QueryDidHash(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, didHash.DidNumber, "should return the key of the did")
+	assert.Equal(t, hex.EncodeToString(hash[:]), didHash.Hash, "should hash the document as carried by its event")
+
+	l.nextTx()
+	deleteTestDid(t, l, key, id)
+
+	_, err = s.QueryDidHash(l.ctx, id)
+	assertErrorCode(t, err, codeDidNotFound, "should not hash deleted dids")
+}
@@ -0,0 +1,97 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// validateExpires returns an error unless expires is empty or an RFC 3339
+// timestamp after the transaction
+func validateExpires(ctx contractapi.TransactionContextInterface, expires string) error {
+	if expires == "" {
+		return nil
+	}
+
+	expiry, err := time.Parse(time.RFC3339, expires)
+
+	if err != nil {
+		return newError(codeInvalidArgument, "expires %s must be an RFC 3339 timestamp", expires)
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !expiry.After(now) {
+		return newError(codeInvalidArgument, "expires %s must be in the future", expires)
+	}
+
+	return nil
+}
+
+// isExpired reports whether the did has an expiry that is not after now
+func isExpired(did *Did, now time.Time) bool {
+	if did.Expires == "" {
+		return false
+	}
+
+	expiry, err := time.Parse(time.RFC3339, did.Expires)
+
+	return err == nil && !expiry.After(now)
+}
+
+// RenewDid replaces the expiry of a did with expires, an RFC 3339 timestamp in
+// the future, or removes it when expires is empty. Expired dids may be renewed.
+// The signature must be made with the current authentication key over the update
+// payload of the renewed document
+func (s *DidContract) RenewDid(ctx contractapi.TransactionContextInterface, didNumber string, expires string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", didNumber)
+	}
+
+	if err := validateExpires(ctx, expires); err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.Expires = expires
+
+	if err := verifyKeyPossession(ctx, didNumber, did, &update, signature); err != nil {
+		return err
+	}
+
+	did.Expires = expires
+
+	return putUpdatedDid(ctx, didNumber, did)
+}
@@ -0,0 +1,140 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateDidExpires(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	expires := testStart.Add(time.Hour).Format(time.RFC3339)
+
+	id, err := s.CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#vcs", "VerifiableCredentialService", testEndpoint, expires)
+	require.NoError(t, err, "should create dids that expire")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, expires, did.Expires, "should store the expiry")
+
+	resolution, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.Equal(t, expires, resolution.DidDocumentMetadata.Expires, "should return the expiry in the document metadata")
+	assert.False(t, resolution.DidResolutionMetadata.Expired, "should not flag dids before their expiry")
+
+	l.setTime(testStart.Add(time.Hour))
+
+	resolution, err = s.Resolve(l.ctx, id, false)
+	require.NoError(t, err, "should resolve expired dids")
+	assert.True(t, resolution.DidResolutionMetadata.Expired, "should flag expired dids")
+	assert.Equal(t, "", resolution.DidResolutionMetadata.Error, "should not report an error for expired dids")
+	assert.NotNil(t, resolution.DidDocument, "should return the document of expired dids")
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, "tomorrow")
+	assertErrorCode(t, err, codeInvalidArgument, "should require an RFC 3339 expiry")
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, expires)
+	assertErrorCode(t, err, codeInvalidArgument, "should require the expiry to be in the future")
+
+	item, err := json.Marshal(DidDetails{AuthenticationId: "#keys-1", AuthenticationType: testKeyType, AuthenticationPublicKeyPerm: newTestKey(t).pem,
+		ServiceId: "#vcs", ServiceType: "VerifiableCredentialService", ServiceEndPoint: testEndpoint, Expires: "2020-06-02T12:00:00Z"})
+	require.NoError(t, err)
+
+	ids, err := s.BatchCreateDids(l.ctx, batchJSON(t, item))
+	require.NoError(t, err, "should accept an expiry in batches")
+
+	did, err = s.QueryDidByKey(l.ctx, ids[0])
+	require.NoError(t, err)
+	assert.Equal(t, "2020-06-02T12:00:00Z", did.Expires, "should store the expiry of batch items")
+}
+
+func TestRenewDid(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	expires := testStart.Add(time.Hour).Format(time.RFC3339)
+
+	id, err := s.CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#vcs", "VerifiableCredentialService", testEndpoint, expires)
+	require.NoError(t, err)
+	l.setTime(testStart.Add(2 * time.Hour))
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	renewal := testStart.Add(48 * time.Hour).Format(time.RFC3339)
+	update := updatableDetails(did)
+	update.Expires = renewal
+	signature := signUpdate(t, l, key, id, &update)
+
+	err = s.RenewDid(l.ctx, id, renewal, "")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature")
+
+	err = s.RenewDid(l.ctx, id, testStart.Format(time.RFC3339), signature)
+	assertErrorCode(t, err, codeInvalidArgument, "should require the new expiry to be in the future")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.RenewDid(l.ctx, id, renewal, signature)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller renew the did")
+
+	l.setClient(testClientID, testMSPID)
+	l.stub.GetFunctionAndParametersReturns("RenewDid", []string{})
+	err = s.RenewDid(l.ctx, id, renewal, signature)
+	require.NoError(t, err, "should renew expired dids")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, renewal, did.Expires, "should store the new expiry")
+
+	name, _ := l.event(t)
+	assert.Equal(t, "did.renewed.fabric", name, "should emit did.renewed")
+
+	resolution, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.False(t, resolution.DidResolutionMetadata.Expired, "should not flag renewed dids")
+
+	l.nextTx()
+	l.setTime(testStart.Add(3 * time.Hour))
+
+	err = updateDid(l, id, testUpdate(id, key, "https://example.org/vc/"), signUpdate(t, l, key, id, &Did{
+		AuthenticationId: id + "#keys-1", AuthenticationType: testKeyType, AuthenticationController: id, AuthenticationPublicKeyPerm: key.pem,
+		ServiceId: id + "#vcs", ServiceType: "VerifiableCredentialService", ServiceEndPoint: "https://example.org/vc/", Expires: renewal,
+	}))
+	require.NoError(t, err, "should sign updates over the current expiry")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, renewal, did.Expires, "should keep the expiry on updates")
+
+	update = updatableDetails(did)
+	update.Expires = ""
+	err = s.RenewDid(l.ctx, id, "", signUpdate(t, l, key, id, &update))
+	require.NoError(t, err, "should remove the expiry")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "", did.Expires, "should no longer expire the did")
+}
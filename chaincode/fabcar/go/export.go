@@ -0,0 +1,101 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// exportVersion is the version of the export page format, which clients record
+// in the snapshots they assemble
+const exportVersion = "1"
+
+// ExportPage describes a page of the registry export. Each page names the channel
+// and chaincode the dids were read from, which together with the generated
+// identifiers tie a snapshot to its registry
+type ExportPage struct {
+	Version             string        `json:"version"`
+	Channel             string        `json:"channel"`
+	Chaincode           string        `json:"chaincode"`
+	ExportedAt          string        `json:"exportedAt"`
+	Records             []QueryResult `json:"records"`
+	FetchedRecordsCount int32         `json:"fetchedRecordsCount"`
+	Bookmark            string        `json:"bookmark"`
+}
+
+// ExportAllDids returns a page of at most pageSize did documents of the world
+// state for export, starting at the bookmark returned with the previous page.
+// Private data is not exported, deleted dids are
+func (s *DidContract) ExportAllDids(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*ExportPage, error) {
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	name, err := chaincodeName(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(didObjectType, []string{}, pageSize, bookmark)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	records := []QueryResult{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := didQueryResult(ctx, queryResponse.Key, queryResponse.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		records = append(records, *result)
+	}
+
+	page := ExportPage{
+		Version:             exportVersion,
+		Channel:             ctx.GetStub().GetChannelID(),
+		Chaincode:           name,
+		ExportedAt:          now.Format(time.RFC3339Nano),
+		Records:             records,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+		Bookmark:            metadata.Bookmark,
+	}
+
+	return &page, nil
+}
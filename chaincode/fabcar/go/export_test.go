@@ -0,0 +1,70 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportAllDids(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	createTestDid(t, l, newTestKey(t))
+	createTestDid(t, l, newTestKey(t))
+	prefix, err := shim.CreateCompositeKey(didObjectType, []string{})
+	require.NoError(t, err)
+	dids := l.prefixKVs(prefix)
+
+	_, err = s.ExportAllDids(l.ctx, 0, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a positive page size")
+
+	_, err = s.ExportAllDids(l.ctx, maxQueryRecords+1, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should bound the page size")
+
+	page, err := s.ExportAllDids(l.ctx, 1, "")
+	require.NoError(t, err, "should export the first page")
+	assert.Equal(t, exportVersion, page.Version, "should name the export version")
+	assert.Equal(t, testChannel, page.Channel, "should name the channel")
+	assert.Equal(t, testChaincode, page.Chaincode, "should name the chaincode")
+	assert.Equal(t, testStart.Add(3*time.Second).Format(time.RFC3339Nano), page.ExportedAt, "should record the export time")
+	require.Len(t, page.Records, 1, "should return at most pageSize dids")
+	assert.Equal(t, dids[0].Key, testDidKey(page.Records[0].Key), "should start at the first did")
+	assert.NotEqual(t, "", page.Bookmark, "should return the bookmark of the next page")
+
+	next, err := s.ExportAllDids(l.ctx, 1, page.Bookmark)
+	require.NoError(t, err, "should export the next page")
+	require.Len(t, next.Records, 1, "should return the remaining did")
+	assert.Equal(t, dids[1].Key, testDidKey(next.Records[0].Key), "should continue at the bookmark")
+	assert.Equal(t, "", next.Bookmark, "should return an empty bookmark on the last page")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	_, err = s.ExportAllDids(l.ctx, 1, "")
+	assert.EqualError(t, err, "Failed to read from world state. GetStateByPartialCompositeKeyWithPagination error", "should return query errors")
+
+	l.stub.GetSignedProposalReturns(nil, errors.New("GetSignedProposal error"))
+	_, err = s.ExportAllDids(l.ctx, 1, "")
+	assert.EqualError(t, err, "Failed to read signed proposal", "should need the invoked chaincode name")
+}
@@ -20,69 +20,118 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
-	"strconv"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
 )
 
-// SmartContract provides functions for managing a did
-type SmartContract struct {
+// DidContract provides functions for managing a did
+type DidContract struct {
 	contractapi.Contract
+	AdminAccess
 }
 
 // Did describes basic details of what makes up a did document
 type Did struct {
-	Id                          string `json:"id"`
-	AuthenticationId            string `json:"authenticationId"`
-	AuthenticationType          string `json:"authenticationType"`
-	AuthenticationController    string `json:"authenticationController"`
-	AuthenticationPublicKeyPerm string `json:"authenticationPublicKeyPerm"`
-	ServiceId                   string `json:"serviceId"`
-	ServiceType                 string `json:"serviceType"`
-	ServiceEndPoint             string `json:"serviceEndPoint"`
+	Id                               string                 `json:"id"`
+	AuthenticationId                 string                 `json:"authenticationId"`
+	AuthenticationType               string                 `json:"authenticationType"`
+	AuthenticationController         string                 `json:"authenticationController"`
+	AuthenticationPublicKeyPerm      string                 `json:"authenticationPublicKeyPerm"`
+	AuthenticationPublicKeyHash      string                 `json:"authenticationPublicKeyHash,omitempty" metadata:"authenticationPublicKeyHash,optional"`
+	AuthenticationPublicKeyMultibase string                 `json:"authenticationPublicKeyMultibase,omitempty" metadata:"authenticationPublicKeyMultibase,optional"`
+	AuthenticationPublicKeyJwk       *keyencoding.Jwk       `json:"authenticationPublicKeyJwk,omitempty" metadata:"authenticationPublicKeyJwk,optional"`
+	VerificationMethods              []VerificationMethod   `json:"verificationMethods,omitempty" metadata:"verificationMethods,optional"`
+	RecoveryMethods                  []VerificationMethod   `json:"recoveryMethods,omitempty" metadata:"recoveryMethods,optional"`
+	Multisig                         *MultisigPolicy        `json:"multisig,omitempty" metadata:"multisig,optional"`
+	ServiceId                        string                 `json:"serviceId"`
+	ServiceType                      string                 `json:"serviceType"`
+	ServiceEndPoint                  string                 `json:"serviceEndPoint"`
+	ServiceEndPointHash              string                 `json:"serviceEndPointHash,omitempty" metadata:"serviceEndPointHash,optional"`
+	ServiceEndpoints                 []DidCommEndpoint      `json:"serviceEndpoints,omitempty" metadata:"serviceEndpoints,optional"`
+	EncryptedServiceEndPoint         string                 `json:"encryptedServiceEndPoint,omitempty" metadata:"encryptedServiceEndPoint,optional"`
+	Controller                       string                 `json:"controller,omitempty" metadata:"controller,optional"`
+	Deactivated                      bool                   `json:"deactivated,omitempty" metadata:"deactivated,optional"`
+	Expires                          string                 `json:"expires,omitempty" metadata:"expires,optional"`
+	Deleted                          bool                   `json:"deleted,omitempty" metadata:"deleted,optional"`
+	Metadata                         *DidMetadata           `json:"metadata,omitempty" metadata:"metadata,optional"`
+	CertificateAttributes            []CertificateAttribute `json:"certificateAttributes,omitempty" metadata:"certificateAttributes,optional"`
+	OffChainDocument                 *OffChainDocument      `json:"offChainDocument,omitempty" metadata:"offChainDocument,optional"`
+	PersonalData                     *PersonalDataHashes    `json:"personalData,omitempty" metadata:"personalData,optional"`
+	Provenance                       *Provenance            `json:"provenance,omitempty" metadata:"provenance,optional"`
 }
 
-// QueryResult structure used for handling result of query
+// didObjectType is the composite key object type under which dids are stored,
+// so that scans of all dids never read other records of the registry
+const didObjectType = "did"
+
+// queryPageSize is the number of dids read from the world state at a time by
+// queries that scan all dids
+const queryPageSize int32 = 100
+
+// maxQueryRecords is the maximum number of dids QueryAllDids returns per call
+const maxQueryRecords = 1000
+
+// QueryResult structure used for handling result of query. TxId and
+// Timestamp describe the transaction that last changed the did, VersionId is
+// the versionId of its document metadata
 type QueryResult struct {
-	Key    string `json:"Key"`
-	Record *Did
+	Key       string `json:"Key"`
+	Record    *Did
+	TxId      string `json:"txId,omitempty" metadata:"txId,optional"`
+	Timestamp string `json:"timestamp,omitempty" metadata:"timestamp,optional"`
+	VersionId string `json:"versionId,omitempty" metadata:"versionId,optional"`
 }
 
-// InitLedger adds a base set of dids to the ledger
-func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-	dids := []Did{
-		Did{Id: "did:example:12346789abcdefghi", AuthenticationId: "did:example:12346789abcdefghi#keys-1",
-			AuthenticationType: "RsaVerificationKey2018", AuthenticationController: "did:example:12346789abcdefghi",
-			AuthenticationPublicKeyPerm: "-----BEGIN PUBLIC KEY...END PUBLIC KEY-----\r\n",
-			ServiceId:                   "did:example:12346789abcdefghi#vcs", ServiceType: "VerifiableCredentialService",
-			ServiceEndPoint: "https://example.com/vc/"},
+// newQueryResult returns the query result of the did stored with didNumber,
+// taking the transaction that last changed it from its provenance
+func newQueryResult(didNumber string, did *Did) *QueryResult {
+	result := QueryResult{Key: didNumber, Record: did, VersionId: did.documentMetadata().VersionId}
 
-		Did{Id: "did:example:12346789asdfghjkl", AuthenticationId: "did:example:12346789asdfghjkl#keys-1",
-			AuthenticationType: "RsaVerificationKey2018", AuthenticationController: "did:example:12346789asdfghjkl",
-			AuthenticationPublicKeyPerm: "-----BEGIN PUBLIC KEY...END PUBLIC KEY-----\r\n",
-			ServiceId:                   "did:example:12346789aasdfghjkl#vcs", ServiceType: "VerifiableCredentialService",
-			ServiceEndPoint: "https://example2.com/vc/"},
-	}
+	if did.Provenance != nil {
+		last := did.Provenance.Updated
 
-	for i, did := range dids {
-		didAsBytes, _ := json.Marshal(did)
-		err := ctx.GetStub().PutState("DID"+strconv.Itoa(i), didAsBytes)
+		if last == nil {
+			last = did.Provenance.Created
+		}
 
-		if err != nil {
-			return fmt.Errorf("Failed to put to world state. %s", err.Error())
+		if last != nil {
+			result.TxId = last.TxID
+			result.Timestamp = last.Timestamp
 		}
 	}
 
-	return nil
+	return &result
+}
+
+// PaginatedQueryResult structure used for handling a page of query results
+type PaginatedQueryResult struct {
+	Records             []QueryResult `json:"records"`
+	FetchedRecordsCount int32         `json:"fetchedRecordsCount"`
+	Bookmark            string        `json:"bookmark"`
+}
+
+// KeyedQueryResult structure used for handling query results keyed by did id
+type KeyedQueryResult struct {
+	Dids                map[string]*Did `json:"dids"`
+	FetchedRecordsCount int32           `json:"fetchedRecordsCount"`
+	Bookmark            string          `json:"bookmark"`
 }
 
-// CreateDid adds a new did to the world state with given details
-func (s *SmartContract) CreateDid(ctx contractapi.TransactionContextInterface, didNumber string, id string, authenticationId string, authenticationType string,
-	authenticationController string, authenticationPublicKeyPerm string, serviceId string, serviceType string, serviceEndPoint string) error {
+// CreateDid adds a new did to the world state with given details and returns its
+// generated did:fabric identifier, under which the did is also stored. The
+// authentication id, controller and service id may be given relative to the new
+// did, for example #keys-1, and must then follow the did syntax, see
+// validateDidSyntax. Changes to the did must afterwards be endorsed by the
+// creating organization. Each did counts against the registration quota of the
+// submitting client and is charged the registration fee, see admitRegistration.
+// A non empty expires must be an RFC 3339 timestamp in the future, after which
+// the did resolves as expired unless it is renewed with RenewDid
+func (s *DidContract) CreateDid(ctx contractapi.TransactionContextInterface, authenticationId string, authenticationType string,
+	authenticationController string, authenticationPublicKeyPerm string, serviceId string, serviceType string, serviceEndPoint string, expires string) (string, error) {
 	did := Did{
-		Id:                          id,
 		AuthenticationId:            authenticationId,
 		AuthenticationType:          authenticationType,
 		AuthenticationController:    authenticationController,
@@ -90,45 +139,515 @@ func (s *SmartContract) CreateDid(ctx contractapi.TransactionContextInterface, d
 		ServiceId:                   serviceId,
 		ServiceType:                 serviceType,
 		ServiceEndPoint:             serviceEndPoint,
+		Expires:                     expires,
+	}
+
+	if err := admitRegistration(ctx, 1); err != nil {
+		return "", err
+	}
+
+	if err := assignIdentifier(ctx, &did); err != nil {
+		return "", err
+	}
+
+	if err := s.createDid(ctx, did.Id, &did); err != nil {
+		return "", err
+	}
+
+	return did.Id, nil
+}
+
+// admitRegistration counts the registration of count dids against the quota of
+// the submitting client and charges it the registration fee. Every transaction
+// creating dids must call it once with the number of dids it creates
+func admitRegistration(ctx contractapi.TransactionContextInterface, count int) error {
+	if err := consumeRegistrationQuota(ctx, count); err != nil {
+		return err
+	}
+
+	return chargeRegistrationFee(ctx, count)
+}
+
+// createDid stores a new did under the given key in the namespace of the
+// submitting client's organization, recording the client as its creator and
+// controller in the did and the audit log and restricting endorsement to the
+// creator's organization
+func (s *DidContract) createDid(ctx contractapi.TransactionContextInterface, didNumber string, did *Did) error {
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if existing != nil {
+		return newError(codeDidAlreadyExists, "%s already exists", didNumber)
+	}
+
+	legacy, err := getLegacyDid(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if legacy != nil {
+		return newError(codeDidAlreadyExists, "%s already exists", didNumber)
+	}
+
+	anchored, err := isAnchored(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if anchored {
+		return newError(codeDidAlreadyExists, "%s is already anchored", didNumber)
+	}
+
+	creator, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if err := validateDidSyntax(did); err != nil {
+		return err
+	}
+
+	if err := validateService(ctx, did); err != nil {
+		return err
+	}
+
+	if err := validateExpires(ctx, did.Expires); err != nil {
+		return err
+	}
+
+	if err := normalizePublicKey(did); err != nil {
+		return err
+	}
+
+	did.Controller = creator.ClientID
+	did.Provenance = &Provenance{Created: creator}
+
+	if err := putSortKeys(ctx, didNumber, did, true); err != nil {
+		return err
+	}
+
+	didAsBytes, err := marshalRecord(didNumber, did)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertDocumentSize(ctx, didNumber, didAsBytes); err != nil {
+		return err
+	}
+
+	if err := assertDidLimits(ctx, didNumber, did, didAsBytes); err != nil {
+		return err
+	}
+
+	didAsBytes, err = compressRecord(didNumber, didAsBytes)
+
+	if err != nil {
+		return err
+	}
+
+	if err := countDid(ctx, key, didNumber, did); err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, didAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	if err := putDidNamespace(ctx, didNumber, &DidNamespace{MSPID: creator.MSPID}); err != nil {
+		return err
+	}
+
+	if err := putDateIndexEntry(ctx, createdDateIndex, didNumber); err != nil {
+		return err
+	}
+
+	if err := putIdIndexEntry(ctx, did.Id, didNumber); err != nil {
+		return err
+	}
+
+	if err := emitDidEvent(ctx, didCreatedOperation, didNumber, did); err != nil {
+		return err
+	}
+
+	if err := putAuditRecord(ctx, didNumber); err != nil {
+		return err
+	}
+
+	return setOwnerEndorsement(ctx, key)
+}
+
+// UpdateDid replaces the details of an existing did. Only the controlling client
+// identity may update a did and the id of the did cannot be changed. The new
+// identifiers must follow the did syntax and the signature must prove possession
+// of the current authentication key, see didUpdatePayload
+func (s *DidContract) UpdateDid(ctx contractapi.TransactionContextInterface, didNumber string, authenticationId string, authenticationType string,
+	authenticationController string, authenticationPublicKeyPerm string, serviceId string, serviceType string, serviceEndPoint string, signature string) error {
+	update := Did{
+		AuthenticationId:            authenticationId,
+		AuthenticationType:          authenticationType,
+		AuthenticationController:    authenticationController,
+		AuthenticationPublicKeyPerm: authenticationPublicKeyPerm,
+		ServiceId:                   serviceId,
+		ServiceType:                 serviceType,
+		ServiceEndPoint:             serviceEndPoint,
+	}
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
+
+// updateDid copies the updatable details of update onto the stored did after
+// checking that the submitting client controls it and that the signature was made
+// with its current authentication key. Dids whose key is kept in the private data
+// collection keep the new key there as well, and a non empty serviceEndPointSalt
+// keeps the new service endpoint in the caller's organization collection. Updates
+// that keep a DIDCommMessaging service without a plain endpoint keep its endpoint
+// objects. The expiry of the did is only changed by RenewDid
+func (s *DidContract) updateDid(ctx contractapi.TransactionContextInterface, didNumber string, update *Did, signature string, serviceEndPointSalt string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", didNumber)
+	}
+
+	if update.VerificationMethods == nil {
+		update.VerificationMethods = did.VerificationMethods
+	}
+
+	if update.RecoveryMethods == nil {
+		update.RecoveryMethods = did.RecoveryMethods
+	}
+
+	if update.Metadata == nil {
+		update.Metadata = did.Metadata
+	}
+
+	if update.Multisig == nil {
+		update.Multisig = did.Multisig
+	}
+
+	if update.ServiceEndpoints == nil && update.ServiceType == didCommMessagingServiceType && update.ServiceEndPoint == "" && update.EncryptedServiceEndPoint == "" {
+		update.ServiceEndpoints = did.ServiceEndpoints
+	}
+
+	update.Expires = did.Expires
+
+	if err := verifyUpdateApproval(ctx, didNumber, did, update, signature); err != nil {
+		return err
+	}
+
+	return applyUpdate(ctx, didNumber, did, update, serviceEndPointSalt)
+}
+
+// applyUpdate copies the updatable details of an authorized update onto the
+// stored did, validates the result and writes it, see updateDid
+func applyUpdate(ctx contractapi.TransactionContextInterface, didNumber string, did *Did, update *Did, serviceEndPointSalt string) error {
+	if err := mergeUpdate(ctx, did, update); err != nil {
+		return err
+	}
+
+	if serviceEndPointSalt != "" {
+		err := putPrivateServiceEndpoint(ctx, didNumber, did, serviceEndPointSalt)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if did.AuthenticationPublicKeyHash != "" && did.AuthenticationPublicKeyPerm != "" {
+		err := putPrivateDetails(ctx, didNumber, did)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return putUpdatedDid(ctx, didNumber, did)
+}
+
+// mergeUpdate copies the updatable details of update onto did and validates
+// the result without writing it
+func mergeUpdate(ctx contractapi.TransactionContextInterface, did *Did, update *Did) error {
+	did.AuthenticationId = update.AuthenticationId
+	did.AuthenticationType = update.AuthenticationType
+	did.AuthenticationController = update.AuthenticationController
+	did.AuthenticationPublicKeyPerm = update.AuthenticationPublicKeyPerm
+	did.AuthenticationPublicKeyMultibase = update.AuthenticationPublicKeyMultibase
+	did.AuthenticationPublicKeyJwk = update.AuthenticationPublicKeyJwk
+	did.VerificationMethods = update.VerificationMethods
+	did.RecoveryMethods = update.RecoveryMethods
+	did.Multisig = update.Multisig
+
+	if did.Multisig != nil && len(did.Multisig.Controllers) == 0 {
+		did.Multisig = nil
+	}
+	did.ServiceId = update.ServiceId
+	did.ServiceType = update.ServiceType
+	did.ServiceEndPoint = update.ServiceEndPoint
+	did.ServiceEndPointHash = update.ServiceEndPointHash
+	did.ServiceEndpoints = update.ServiceEndpoints
+	did.EncryptedServiceEndPoint = update.EncryptedServiceEndPoint
+	did.Metadata = update.Metadata
+	normalizeDidMetadata(did)
+
+	if err := validateDidSyntax(did); err != nil {
+		return err
+	}
+
+	if err := validateService(ctx, did); err != nil {
+		return err
+	}
+
+	return normalizePublicKey(did)
+}
+
+// DeactivateDid marks a did as deactivated so that it no longer resolves and
+// cannot be updated. The signature must be made with the current authentication
+// key over the update payload of the deactivated document
+func (s *DidContract) DeactivateDid(ctx contractapi.TransactionContextInterface, didNumber string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is already deactivated", didNumber)
+	}
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+
+	if err := verifyUpdateApproval(ctx, didNumber, did, &update, signature); err != nil {
+		return err
+	}
+
+	did.Deactivated = true
+
+	return putUpdatedDid(ctx, didNumber, did)
+}
+
+// putUpdatedDid records the submitting client as the last updater of the did,
+// writes it to the world state, appends an audit record and emits the matching
+// did event. The did must be in the namespace of the client's organization
+func putUpdatedDid(ctx contractapi.TransactionContextInterface, didNumber string, did *Did) error {
+	if err := assertNamespace(ctx, didNumber); err != nil {
+		return err
+	}
+
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	return putDid(ctx, key, didNumber, did)
+}
+
+// putDid writes the updated did to the given world state key, see putUpdatedDid
+func putDid(ctx contractapi.TransactionContextInterface, key string, didNumber string, did *Did) error {
+	updater, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
 	}
 
-	didAsBytes, _ := json.Marshal(did)
+	if did.Provenance == nil {
+		did.Provenance = new(Provenance)
+	}
+	did.Provenance.Updated = updater
+
+	if err := putSortKeys(ctx, didNumber, did, false); err != nil {
+		return err
+	}
+
+	didAsBytes, err := marshalRecord(didNumber, did)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertDocumentSize(ctx, didNumber, didAsBytes); err != nil {
+		return err
+	}
+
+	if err := assertDidLimits(ctx, didNumber, did, didAsBytes); err != nil {
+		return err
+	}
 
-	return ctx.GetStub().PutState(didNumber, didAsBytes)
+	didAsBytes, err = compressRecord(didNumber, didAsBytes)
+
+	if err != nil {
+		return err
+	}
+
+	if err := retireLegacyDid(ctx, key, didNumber, did); err != nil {
+		return err
+	}
+
+	if err := countDid(ctx, key, didNumber, did); err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, didAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	if err := putDateIndexEntry(ctx, updatedDateIndex, didNumber); err != nil {
+		return err
+	}
+
+	if err := putAuditRecord(ctx, didNumber); err != nil {
+		return err
+	}
+
+	return emitDidEvent(ctx, updateOperation(ctx, did), didNumber, did)
 }
 
-// QueryDidByKey returns the did stored in the world state with given key
-func (s *SmartContract) QueryDidByKey(ctx contractapi.TransactionContextInterface, didNumber string) (*Did, error) {
-	didAsBytes, err := ctx.GetStub().GetState(didNumber)
+// QueryDidByKey returns the did stored in the world state with given key. Deleted
+// dids are not found, so that they can no longer be changed
+func (s *DidContract) QueryDidByKey(ctx contractapi.TransactionContextInterface, didNumber string) (*Did, error) {
+	did, err := getDid(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if did.Deleted {
+		return nil, newError(codeDidNotFound, "%s is deleted", didNumber)
+	}
+
+	return did, nil
+}
+
+// getDid returns the did stored in the world state with given key, including
+// deleted dids and legacy dids, see getLegacyDid
+func getDid(ctx contractapi.TransactionContextInterface, didNumber string) (*Did, error) {
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	didAsBytes, err := ctx.GetStub().GetState(key)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
 	}
 
 	if didAsBytes == nil {
-		return nil, fmt.Errorf("%s does not exist", didNumber)
+		legacy, err := getLegacyDid(ctx, didNumber)
+
+		if err != nil || legacy != nil {
+			return legacy, err
+		}
+
+		return nil, newError(codeDidNotFound, "%s does not exist", didNumber)
 	}
 
 	did := new(Did)
-	_ = json.Unmarshal(didAsBytes, did)
+
+	if err := unmarshalRecord(didNumber, didAsBytes, did); err != nil {
+		return nil, err
+	}
 
 	return did, nil
 }
 
 // QueryDidById returns the did stored in the world state with given id
+func (s *DidContract) QueryDidById(ctx contractapi.TransactionContextInterface, id string) (*Did, error) {
+	result, err := findDidById(ctx, id)
 
-func (s *SmartContract) QueryDidById(ctx contractapi.TransactionContextInterface, id string) (*Did, error) {
-	startKey := "DID0"
-	endKey := "DID99"
+	if err != nil {
+		return nil, err
+	}
 
-	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+	return result.Record, nil
+}
+
+// findDidById returns the key and record of the did stored in the world state
+// with given id. Deleted dids are not found
+func findDidById(ctx contractapi.TransactionContextInterface, id string) (*QueryResult, error) {
+	result, err := lookupDidById(ctx, id)
 
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
 
-	results := []QueryResult{}
+	if result == nil {
+		return nil, newError(codeDidNotFound, "%s does not exist", id)
+	}
+
+	if result.Record.Deleted {
+		return nil, newError(codeDidNotFound, "%s is deleted", id)
+	}
+
+	return result, nil
+}
+
+// lookupDidById returns the key and record of the did with given id, or nil if
+// no such did is stored in the world state. Generated dids are stored under their
+// id, other dids are searched for by scanning the did namespace one record at a time,
+// then the legacy dids. The scan does not use paginated queries, which peers only
+// allow in read-only transactions, as issuers are looked up when submitting
+// credentials
+func lookupDidById(ctx contractapi.TransactionContextInterface, id string) (*QueryResult, error) {
+	key, err := didKey(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	didAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if didAsBytes != nil {
+		did := new(Did)
+
+		if err := unmarshalRecord(id, didAsBytes, did); err != nil {
+			return nil, err
+		}
+
+		return newQueryResult(id, did), nil
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(didObjectType, []string{})
+
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
 
 	for resultsIterator.HasNext() {
 		queryResponse, err := resultsIterator.Next()
@@ -137,29 +656,30 @@ func (s *SmartContract) QueryDidById(ctx contractapi.TransactionContextInterface
 			return nil, err
 		}
 
-		did := new(Did)
-		_ = json.Unmarshal(queryResponse.Value, did)
+		result, err := didQueryResult(ctx, queryResponse.Key, queryResponse.Value)
 
-		if did.Id == id {
-			return did, nil
+		if err != nil {
+			return nil, err
 		}
 
-		queryResult := QueryResult{Key: queryResponse.Key, Record: did}
-		results = append(results, queryResult)
+		if result.Record.Id == id {
+			return result, nil
+		}
 	}
 
-	return nil, fmt.Errorf("%s does not exist", id)
+	return lookupLegacyDidById(ctx, id)
 }
 
-// QueryAllDids returns all did documents found in world state
-func (s *SmartContract) QueryAllDids(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
-	startKey := "DID0"
-	endKey := "DID99"
-
-	resultsIterator, err := ctx.GetStub().GetStateByRange(startKey, endKey)
+// queryDidPage returns the dids of a page of at most pageSize dids of the world
+// state, starting at bookmark, and the bookmark of the next page, which is empty
+// on the last page. Namespace limits the page to the dids of one organization,
+// all dids are read if it is empty. Deleted dids and, unless include is nil,
+// dids include rejects are left out, so pages may hold fewer dids
+func queryDidPage(ctx contractapi.TransactionContextInterface, namespace []string, include func(did *Did) bool, pageSize int32, bookmark string) ([]QueryResult, string, error) {
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(didObjectType, namespace, pageSize, bookmark)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer resultsIterator.Close()
 
@@ -169,29 +689,175 @@ func (s *SmartContract) QueryAllDids(ctx contractapi.TransactionContextInterface
 		queryResponse, err := resultsIterator.Next()
 
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
-		did := new(Did)
-		_ = json.Unmarshal(queryResponse.Value, did)
+		result, err := didQueryResult(ctx, queryResponse.Key, queryResponse.Value)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		if result.Record.Deleted || (include != nil && !include(result.Record)) {
+			continue
+		}
+
+		results = append(results, *result)
+	}
+
+	return results, metadata.Bookmark, nil
+}
+
+// didQueryResult decodes the did stored under the given world state key
+func didQueryResult(ctx contractapi.TransactionContextInterface, key string, didAsBytes []byte) (*QueryResult, error) {
+	didNumber, err := didNumberOfKey(ctx, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	did := new(Did)
+
+	if err := unmarshalRecord(didNumber, didAsBytes, did); err != nil {
+		return nil, err
+	}
+
+	return newQueryResult(didNumber, did), nil
+}
+
+// queryDids returns at most limit dids of the world state that include
+// accepts, starting at bookmark, and the bookmark to continue at, which is empty
+// once all dids have been read. The world state is read in pages of at most
+// queryPageSize dids
+func queryDids(ctx contractapi.TransactionContextInterface, include func(did *Did) bool, limit int, bookmark string) ([]QueryResult, string, error) {
+	records := []QueryResult{}
+
+	for {
+		pageSize := queryPageSize
+
+		if remaining := int32(limit - len(records)); remaining < pageSize {
+			pageSize = remaining
+		}
+
+		results, next, err := queryDidPage(ctx, []string{}, include, pageSize, bookmark)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		records = append(records, results...)
+		bookmark = next
+
+		if bookmark == "" || len(records) >= limit {
+			return records, bookmark, nil
+		}
+	}
+}
+
+// QueryAllDids returns at most maxQueryRecords did documents of the given
+// status found in world state, starting at the bookmark returned with the
+// previous call. Status is one of active, the default, deactivated, expired
+// and all. The world state is read in pages of queryPageSize dids, and the
+// returned bookmark is empty once all dids have been returned
+func (s *DidContract) QueryAllDids(ctx contractapi.TransactionContextInterface, status string, bookmark string) (*PaginatedQueryResult, error) {
+	include, err := didStatusFilter(ctx, status)
+
+	if err != nil {
+		return nil, err
+	}
+
+	records, next, err := queryDids(ctx, include, maxQueryRecords, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := PaginatedQueryResult{
+		Records:             records,
+		FetchedRecordsCount: int32(len(records)),
+		Bookmark:            next,
+	}
+
+	return &page, nil
+}
+
+// validatePageSize checks the page size of a paginated query, which must be
+// positive and at most maxQueryRecords
+func validatePageSize(pageSize int32) error {
+	if pageSize < 1 {
+		return newError(codeInvalidArgument, "Page size must be positive")
+	}
+
+	if pageSize > maxQueryRecords {
+		return newError(codeInvalidArgument, "Page size must not exceed %d", maxQueryRecords)
+	}
+
+	return nil
+}
+
+// QueryAllDidsWithPagination returns a page of at most pageSize did documents
+// of the given status found in world state, see QueryAllDids, starting at the
+// bookmark returned with the previous page
+func (s *DidContract) QueryAllDidsWithPagination(ctx contractapi.TransactionContextInterface, status string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	include, err := didStatusFilter(ctx, status)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results, next, err := queryDids(ctx, include, int(pageSize), bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := PaginatedQueryResult{
+		Records:             results,
+		FetchedRecordsCount: int32(len(results)),
+		Bookmark:            next,
+	}
+
+	return &page, nil
+}
+
+// QueryAllDidsKeyedById returns the same dids as QueryAllDids, as an object
+// mapping the id of each did to its document
+func (s *DidContract) QueryAllDidsKeyedById(ctx contractapi.TransactionContextInterface, status string, bookmark string) (*KeyedQueryResult, error) {
+	page, err := s.QueryAllDids(ctx, status, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	dids := make(map[string]*Did, len(page.Records))
+
+	for _, result := range page.Records {
+		dids[result.Record.Id] = result.Record
+	}
 
-		queryResult := QueryResult{Key: queryResponse.Key, Record: did}
-		results = append(results, queryResult)
+	keyed := KeyedQueryResult{
+		Dids:                dids,
+		FetchedRecordsCount: page.FetchedRecordsCount,
+		Bookmark:            page.Bookmark,
 	}
 
-	return results, nil
+	return &keyed, nil
 }
 
 func main() {
 
-	chaincode, err := contractapi.NewChaincode(new(SmartContract))
+	chaincode, err := newChaincode()
 
 	if err != nil {
 		fmt.Printf("Error create fabcar chaincode: %s", err.Error())
 		return
 	}
 
-	if err := chaincode.Start(); err != nil {
+	if err := shim.Start(&codedChaincode{chaincode}); err != nil {
 		fmt.Printf("Error starting fabcar chaincode: %s", err.Error())
 	}
 }
@@ -0,0 +1,1068 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+//go:generate counterfeiter -o mocks/transaction.go -fake-name TransactionContext . transactionContext
+type transactionContext interface {
+	contractapi.TransactionContextInterface
+}
+
+//go:generate counterfeiter -o mocks/chaincodestub.go -fake-name ChaincodeStub . chaincodeStub
+type chaincodeStub interface {
+	shim.ChaincodeStubInterface
+}
+
+//go:generate counterfeiter -o mocks/statequeryiterator.go -fake-name StateQueryIterator . stateQueryIterator
+type stateQueryIterator interface {
+	shim.StateQueryIteratorInterface
+}
+
+//go:generate counterfeiter -o mocks/historyqueryiterator.go -fake-name HistoryQueryIterator . historyQueryIterator
+type historyQueryIterator interface {
+	shim.HistoryQueryIteratorInterface
+}
+
+//go:generate counterfeiter -o mocks/clientidentity.go -fake-name ClientIdentity . clientIdentity
+type clientIdentity interface {
+	cid.ClientIdentity
+}
+
+// #########
+// HELPERS
+// #########
+
+const (
+	testChannel   = "mychannel"
+	testChaincode = "fabcar"
+	testClientID  = "x509::CN=user1,OU=client::CN=ca.org1.example.com"
+	testMSPID     = "Org1MSP"
+	otherClientID = "x509::CN=user2,OU=client::CN=ca.org2.example.com"
+	otherMSPID    = "Org2MSP"
+	testKeyType   = ecdsaSecp256r1VerificationKey2019
+	testEndpoint  = "https://example.com/vc/"
+)
+
+// testStart is the timestamp of the first transaction of a test ledger
+var testStart = time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// testLedger is an in-memory world state behind a fake stub. Reads see the
+// writes of the same transaction, unlike on a peer
+type testLedger struct {
+	ctx        *mocks.TransactionContext
+	stub       *mocks.ChaincodeStub
+	identity   *mocks.ClientIdentity
+	state      map[string][]byte
+	private    map[string]map[string][]byte
+	validation map[string][]byte
+	// privateValidation holds the key-level endorsement policies of private
+	// data by collection
+	privateValidation map[string]map[string][]byte
+	history           map[string][]*queryresult.KeyModification
+	attributes        map[string]string
+	tx                int
+}
+
+// newTestLedger returns an empty test ledger whose transactions are submitted by
+// testClientID of testMSPID
+func newTestLedger(t *testing.T) *testLedger {
+	l := &testLedger{
+		ctx:               new(mocks.TransactionContext),
+		stub:              new(mocks.ChaincodeStub),
+		identity:          new(mocks.ClientIdentity),
+		state:             map[string][]byte{},
+		private:           map[string]map[string][]byte{},
+		validation:        map[string][]byte{},
+		privateValidation: map[string]map[string][]byte{},
+		history:           map[string][]*queryresult.KeyModification{},
+		attributes:        map[string]string{},
+	}
+
+	l.ctx.GetStubReturns(l.stub)
+	l.ctx.GetClientIdentityReturns(l.identity)
+
+	l.identity.GetIDReturns(testClientID, nil)
+	l.identity.GetMSPIDReturns(testMSPID, nil)
+	l.identity.AssertAttributeValueCalls(func(name string, value string) error {
+		if l.attributes[name] != value {
+			return fmt.Errorf("attribute %s is not %s", name, value)
+		}
+
+		return nil
+	})
+	l.identity.GetAttributeValueCalls(func(name string) (string, bool, error) {
+		value, found := l.attributes[name]
+		return value, found, nil
+	})
+
+	l.stub.GetChannelIDReturns(testChannel)
+	l.stub.GetSignedProposalReturns(testSignedProposal(t), nil)
+	l.stub.CreateCompositeKeyCalls(shim.CreateCompositeKey)
+	l.stub.SplitCompositeKeyCalls(new(shim.ChaincodeStub).SplitCompositeKey)
+	l.stub.GetStateCalls(func(key string) ([]byte, error) {
+		return l.state[key], nil
+	})
+	l.stub.PutStateCalls(func(key string, value []byte) error {
+		l.state[key] = value
+		l.record(key, value, false)
+		return nil
+	})
+	l.stub.DelStateCalls(func(key string) error {
+		delete(l.state, key)
+		l.record(key, nil, true)
+		return nil
+	})
+	l.stub.GetStateByRangeCalls(func(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+		return newStateIterator(l.rangeKVs(startKey, endKey)), nil
+	})
+	l.stub.GetStateByRangeWithPaginationCalls(l.rangePage)
+	l.stub.GetStateByPartialCompositeKeyWithPaginationCalls(l.prefixPage)
+	l.stub.GetStateByPartialCompositeKeyCalls(func(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, attributes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newStateIterator(l.prefixKVs(prefix)), nil
+	})
+	l.stub.GetHistoryForKeyCalls(func(key string) (shim.HistoryQueryIteratorInterface, error) {
+		return newHistoryIterator(l.history[key]), nil
+	})
+	l.stub.GetPrivateDataCalls(func(collection string, key string) ([]byte, error) {
+		return l.private[collection][key], nil
+	})
+	l.stub.PutPrivateDataCalls(func(collection string, key string, value []byte) error {
+		if l.private[collection] == nil {
+			l.private[collection] = map[string][]byte{}
+		}
+		l.private[collection][key] = value
+		return nil
+	})
+	l.stub.DelPrivateDataCalls(func(collection string, key string) error {
+		delete(l.private[collection], key)
+		return nil
+	})
+	l.stub.GetPrivateDataHashCalls(func(collection string, key string) ([]byte, error) {
+		value, ok := l.private[collection][key]
+
+		if !ok {
+			return nil, nil
+		}
+
+		hash := sha256.Sum256(value)
+		return hash[:], nil
+	})
+	l.stub.GetPrivateDataValidationParameterCalls(func(collection string, key string) ([]byte, error) {
+		return l.privateValidation[collection][key], nil
+	})
+	l.stub.SetPrivateDataValidationParameterCalls(func(collection string, key string, policy []byte) error {
+		if l.privateValidation[collection] == nil {
+			l.privateValidation[collection] = map[string][]byte{}
+		}
+		l.privateValidation[collection][key] = policy
+		return nil
+	})
+	l.stub.GetStateValidationParameterCalls(func(key string) ([]byte, error) {
+		return l.validation[key], nil
+	})
+	l.stub.SetStateValidationParameterCalls(func(key string, policy []byte) error {
+		l.validation[key] = policy
+		return nil
+	})
+
+	l.nextTx()
+
+	return l
+}
+
+// nextTx starts a new transaction one second after the previous one
+func (l *testLedger) nextTx() {
+	l.tx++
+	l.stub.GetTxIDReturns(fmt.Sprintf("tx%d", l.tx))
+	l.setTime(testStart.Add(time.Duration(l.tx) * time.Second))
+}
+
+// setTime sets the timestamp of the current transaction
+func (l *testLedger) setTime(now time.Time) {
+	l.stub.GetTxTimestampReturns(&timestamp.Timestamp{Seconds: now.Unix(), Nanos: int32(now.Nanosecond())}, nil)
+}
+
+// setClient makes the client identity with given id and MSP ID submit the
+// following transactions
+func (l *testLedger) setClient(clientID string, mspID string) {
+	l.identity.GetIDReturns(clientID, nil)
+	l.identity.GetMSPIDReturns(mspID, nil)
+}
+
+// setAdmin grants or removes the default admin attribute of the client
+func (l *testLedger) setAdmin(admin bool) {
+	if admin {
+		l.attributes[defaultAdminAttribute] = "true"
+	} else {
+		delete(l.attributes, defaultAdminAttribute)
+	}
+}
+
+// record appends a change of key to its history
+func (l *testLedger) record(key string, value []byte, isDelete bool) {
+	now, _ := l.stub.GetTxTimestamp()
+
+	l.history[key] = append(l.history[key], &queryresult.KeyModification{
+		TxId:      l.stub.GetTxID(),
+		Value:     value,
+		Timestamp: now,
+		IsDelete:  isDelete,
+	})
+}
+
+// sortedKeys returns the keys of the world state in key order
+func (l *testLedger) sortedKeys() []string {
+	keys := []string{}
+
+	for key := range l.state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// rangeKVs returns the simple keys between startKey and endKey. As on a peer,
+// composite keys are not returned by range queries
+func (l *testLedger) rangeKVs(startKey string, endKey string) []*queryresult.KV {
+	kvs := []*queryresult.KV{}
+
+	for _, key := range l.sortedKeys() {
+		if strings.HasPrefix(key, "\x00") || key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+
+		kvs = append(kvs, &queryresult.KV{Namespace: testChaincode, Key: key, Value: l.state[key]})
+	}
+
+	return kvs
+}
+
+// rangePage returns a page of a range query. The bookmark is the key of the
+// first record of the next page
+func (l *testLedger) rangePage(startKey string, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	kvs := l.rangeKVs(startKey, endKey)
+	next := ""
+
+	if len(kvs) > int(pageSize) {
+		next = kvs[pageSize].Key
+		kvs = kvs[:pageSize]
+	}
+
+	metadata := peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(kvs)), Bookmark: next}
+
+	return newStateIterator(kvs), &metadata, nil
+}
+
+// prefixKVs returns the keys starting with prefix
+func (l *testLedger) prefixKVs(prefix string) []*queryresult.KV {
+	kvs := []*queryresult.KV{}
+
+	for _, key := range l.sortedKeys() {
+		if strings.HasPrefix(key, prefix) {
+			kvs = append(kvs, &queryresult.KV{Namespace: testChaincode, Key: key, Value: l.state[key]})
+		}
+	}
+
+	return kvs
+}
+
+// prefixPage returns a page of a partial composite key query. The bookmark is
+// the key of the first record of the next page
+func (l *testLedger) prefixPage(objectType string, attributes []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	prefix, err := shim.CreateCompositeKey(objectType, attributes)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	kvs := []*queryresult.KV{}
+	next := ""
+
+	for _, kv := range l.prefixKVs(prefix) {
+		if kv.Key < bookmark {
+			continue
+		}
+
+		if len(kvs) == int(pageSize) {
+			next = kv.Key
+			break
+		}
+
+		kvs = append(kvs, kv)
+	}
+
+	metadata := peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(kvs)), Bookmark: next}
+
+	return newStateIterator(kvs), &metadata, nil
+}
+
+// testDidKey returns the world state key of the did stored with didNumber in the
+// namespace of testMSPID
+func testDidKey(didNumber string) string {
+	return testNamespacedDidKey(testMSPID, didNumber)
+}
+
+// testNamespacedDidKey returns the world state key of the did stored with
+// didNumber in the namespace of mspID
+func testNamespacedDidKey(mspID string, didNumber string) string {
+	key, _ := shim.CreateCompositeKey(didObjectType, []string{mspID, didNumber})
+
+	return key
+}
+
+// event returns the name and payload of the last event set
+func (l *testLedger) event(t *testing.T) (string, []byte) {
+	require.NotZero(t, l.stub.SetEventCallCount(), "should set an event")
+
+	return l.stub.SetEventArgsForCall(l.stub.SetEventCallCount() - 1)
+}
+
+// endorsers returns the organizations of the key-level endorsement policy of
+// the did stored with didNumber in its current namespace
+func (l *testLedger) endorsers(t *testing.T, didNumber string) []string {
+	key, err := didKey(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	policy, err := statebased.NewStateEP(l.validation[key])
+	require.NoError(t, err)
+
+	return policy.ListOrgs()
+}
+
+// privateEndorsers returns the organizations of the key-level endorsement
+// policy of the private data of the did stored with didNumber in a collection
+func (l *testLedger) privateEndorsers(t *testing.T, collection string, didNumber string) []string {
+	policy, err := statebased.NewStateEP(l.privateValidation[collection][didNumber])
+	require.NoError(t, err)
+
+	return policy.ListOrgs()
+}
+
+// newStateIterator returns a fake iterator over kvs
+func newStateIterator(kvs []*queryresult.KV) *mocks.StateQueryIterator {
+	iterator := new(mocks.StateQueryIterator)
+	iterator.HasNextCalls(func() bool {
+		return len(kvs) > 0
+	})
+	iterator.NextCalls(func() (*queryresult.KV, error) {
+		kv := kvs[0]
+		kvs = kvs[1:]
+		return kv, nil
+	})
+
+	return iterator
+}
+
+// newHistoryIterator returns a fake iterator over modifications
+func newHistoryIterator(modifications []*queryresult.KeyModification) *mocks.HistoryQueryIterator {
+	iterator := new(mocks.HistoryQueryIterator)
+	iterator.HasNextCalls(func() bool {
+		return len(modifications) > 0
+	})
+	iterator.NextCalls(func() (*queryresult.KeyModification, error) {
+		modification := modifications[0]
+		modifications = modifications[1:]
+		return modification, nil
+	})
+
+	return iterator
+}
+
+// failingStateIterator returns a fake iterator with one result that returns err
+func failingStateIterator(err error) *mocks.StateQueryIterator {
+	iterator := new(mocks.StateQueryIterator)
+	iterator.HasNextReturns(true)
+	iterator.NextReturns(nil, err)
+
+	return iterator
+}
+
+// testSignedProposal returns a signed proposal invoking testChaincode
+func testSignedProposal(t *testing.T) *peer.SignedProposal {
+	input, err := proto.Marshal(&peer.ChaincodeInvocationSpec{ChaincodeSpec: &peer.ChaincodeSpec{ChaincodeId: &peer.ChaincodeID{Name: testChaincode}}})
+	require.NoError(t, err)
+
+	payload, err := proto.Marshal(&peer.ChaincodeProposalPayload{Input: input})
+	require.NoError(t, err)
+
+	proposal, err := proto.Marshal(&peer.Proposal{Payload: payload})
+	require.NoError(t, err)
+
+	return &peer.SignedProposal{ProposalBytes: proposal}
+}
+
+// testKey is a P-256 key pair used as the authentication key of test dids
+type testKey struct {
+	private *ecdsa.PrivateKey
+	pem     string
+}
+
+// newTestKey generates a new test key
+func newTestKey(t *testing.T) *testKey {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	pemKey, err := keyencoding.PemFromPublicKey(&private.PublicKey)
+	require.NoError(t, err)
+
+	return &testKey{private: private, pem: pemKey}
+}
+
+// sign returns the base64 encoded ASN.1 signature of message
+func (k *testKey) sign(t *testing.T, message []byte) string {
+	digest := sha256.Sum256(message)
+
+	signature, err := ecdsa.SignASN1(rand.Reader, k.private, digest[:])
+	require.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(signature)
+}
+
+// signUpdate returns the proof of possession of key for changing the stored did
+// to the details of update
+func signUpdate(t *testing.T, l *testLedger, key *testKey, didNumber string, update *Did) string {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	if update.VerificationMethods == nil {
+		update.VerificationMethods = did.VerificationMethods
+	}
+
+	if update.RecoveryMethods == nil {
+		update.RecoveryMethods = did.RecoveryMethods
+	}
+
+	if update.Multisig == nil {
+		update.Multisig = did.Multisig
+	}
+
+	if update.Metadata == nil {
+		update.Metadata = did.Metadata
+	}
+
+	if update.ServiceEndpoints == nil && update.ServiceType == didCommMessagingServiceType && update.ServiceEndPoint == "" && update.EncryptedServiceEndPoint == "" {
+		update.ServiceEndpoints = did.ServiceEndpoints
+	}
+
+	payload, err := didUpdatePayload(didNumber, did, update)
+	require.NoError(t, err)
+
+	return key.sign(t, payload)
+}
+
+// createTestDid creates a did with key as its authentication key and starts the
+// next transaction
+func createTestDid(t *testing.T, l *testLedger, key *testKey) string {
+	id, err := new(DidContract).CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	require.NoError(t, err)
+
+	l.nextTx()
+
+	return id
+}
+
+// testUpdate returns the details passed to UpdateDid by tests for the did
+func testUpdate(id string, key *testKey, endpoint string) *Did {
+	return &Did{
+		AuthenticationId:            id + "#keys-1",
+		AuthenticationType:          testKeyType,
+		AuthenticationController:    id,
+		AuthenticationPublicKeyPerm: key.pem,
+		ServiceId:                   id + "#vcs",
+		ServiceType:                 "VerifiableCredentialService",
+		ServiceEndPoint:             endpoint,
+	}
+}
+
+// updateDid calls UpdateDid with the details of update
+func updateDid(l *testLedger, didNumber string, update *Did, signature string) error {
+	return new(DidContract).UpdateDid(l.ctx, didNumber, update.AuthenticationId, update.AuthenticationType, update.AuthenticationController,
+		update.AuthenticationPublicKeyPerm, update.ServiceId, update.ServiceType, update.ServiceEndPoint, signature)
+}
+
+// assertErrorCode asserts that err is a ContractError with given code
+func assertErrorCode(t *testing.T, err error, code string, message string) {
+	contractErr, ok := err.(*ContractError)
+
+	if assert.True(t, ok, "%s: expected a ContractError, got %v", message, err) {
+		assert.Equal(t, code, contractErr.Code, message)
+	}
+}
+
+// #########
+// TESTS
+// #########
+
+func TestInitLedger(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+
+	err := l.before(a, "InitLedger")
+	assertErrorCode(t, err, codeUnauthorized, "should require the admin attribute")
+
+	l.setAdmin(true)
+
+	err = l.before(a, "InitLedger")
+	require.NoError(t, err, "should let administrators seed the ledger")
+
+	err = a.InitLedger(l.ctx, "")
+	assert.Nil(t, err, "should add the seed dids")
+
+	did, err := s.QueryDidByKey(l.ctx, "DID1")
+	require.NoError(t, err)
+	assert.Equal(t, "did:example:12346789asdfghjkl", did.Id, "should store the seed dids by number")
+	assert.Equal(t, seedPublicKeys[1], did.AuthenticationPublicKeyPerm, "should store the seed keys")
+
+	marker, err := l.stub.CreateCompositeKey(configObjectType, []string{initializedConfig})
+	require.NoError(t, err)
+	assert.Contains(t, string(l.state[marker]), `"txId":"`+l.stub.GetTxID()+`"`, "should record the initializing transaction")
+
+	err = l.before(a, "InitLedger")
+	require.NoError(t, err, "should let InitLedger report that it already ran")
+
+	err = a.InitLedger(l.ctx, "")
+	assertErrorCode(t, err, codeAlreadyExists, "should refuse to run twice")
+
+	l = newTestLedger(t)
+	l.stub.PutStateReturns(errors.New("PutState error"))
+
+	err = a.InitLedger(l.ctx, "")
+	assert.EqualError(t, err, "Failed to put to world state. PutState error", "should return ledger errors")
+}
+
+func TestSeedDidsCopiesSamples(t *testing.T) {
+	l := newTestLedger(t)
+
+	dids, err := seedDids(l.ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, sampleSeedDids, dids, "should return the sample dids")
+
+	dids[0].Controller = testClientID
+	dids[1].ServiceEndPoint = "https://changed.example.com/vc/"
+
+	assert.Empty(t, sampleSeedDids[0].Controller, "should not share the sample dids")
+	assert.Equal(t, "https://example2.com/vc/", sampleSeedDids[1].ServiceEndPoint)
+}
+
+func TestInitLedgerSeed(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+	key := newTestKey(t)
+
+	seed := []Did{Did{Id: "did:example:seed", AuthenticationId: "did:example:seed#keys-1", AuthenticationType: testKeyType,
+		AuthenticationController: "did:example:seed", AuthenticationPublicKeyPerm: key.pem,
+		ServiceId: "did:example:seed#vcs", ServiceType: "VerifiableCredentialService", ServiceEndPoint: testEndpoint}}
+	seedAsBytes, err := json.Marshal(seed)
+	require.NoError(t, err)
+
+	err = a.InitLedger(l.ctx, `{}`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a JSON array")
+
+	err = a.InitLedger(l.ctx, `[{"id":"did:example:seed","unknown":true}]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject unknown members")
+
+	invalid := append([]Did{}, seed...)
+	invalid[0].ServiceEndPoint = "ftp://example.com"
+	invalidAsBytes, err := json.Marshal(invalid)
+	require.NoError(t, err)
+
+	err = a.InitLedger(l.ctx, string(invalidAsBytes))
+	assertErrorCode(t, err, codeInvalidArgument, "should check the seed dids")
+	assert.Contains(t, err.Error(), "Seed did 0 is invalid", "should name the invalid seed")
+
+	invalid[0].ServiceEndPoint = testEndpoint
+	invalid[0].AuthenticationPublicKeyPerm = "not a key"
+	invalidAsBytes, err = json.Marshal(invalid)
+	require.NoError(t, err)
+
+	err = a.InitLedger(l.ctx, string(invalidAsBytes))
+	assertErrorCode(t, err, codeInvalidArgument, "should check the seed keys")
+
+	invalid[0].AuthenticationPublicKeyPerm = key.pem
+	invalid[0].Metadata = &DidMetadata{CanonicalId: "example:seed"}
+	invalidAsBytes, err = json.Marshal(invalid)
+	require.NoError(t, err)
+
+	err = a.InitLedger(l.ctx, string(invalidAsBytes))
+	assertErrorCode(t, err, codeInvalidArgument, "should check the seed metadata")
+
+	controlled := append([]Did{}, seed...)
+	controlled[0].Controller = testClientID
+	controlledAsBytes, err := json.Marshal(controlled)
+	require.NoError(t, err)
+
+	err = a.InitLedger(l.ctx, string(controlledAsBytes))
+	assertErrorCode(t, err, codeInvalidArgument, "should not let seeds set their controller")
+	assert.Empty(t, l.state, "should not write invalid seeds")
+
+	err = a.InitLedger(l.ctx, string(seedAsBytes))
+	require.NoError(t, err, "should seed the given dids")
+
+	did, err := s.QueryDidByKey(l.ctx, "DID0")
+	require.NoError(t, err)
+	assert.Equal(t, "did:example:seed", did.Id, "should store the seed dids by number")
+	assert.Equal(t, key.pem, did.AuthenticationPublicKeyPerm, "should store the seed keys")
+	assert.Equal(t, testClientID, did.Controller, "should let the seeding administrator control the seeds")
+	require.NotNil(t, did.Provenance, "should record the provenance of the seeds")
+	assert.Equal(t, testClientID, did.Provenance.Created.ClientID)
+	assert.Equal(t, []string{testMSPID}, l.endorsers(t, "DID0"), "should restrict endorsement of the seeds to the seeding organization")
+
+	name, payload := l.event(t)
+	assert.Equal(t, didsCreatedEvent, name, "should report the seeds in a single event")
+	assert.Contains(t, string(payload), `"didNumber":"DID0"`)
+
+	l.stub.GetQueryResultWithPaginationReturns(nil, nil, errors.New("ExecuteQuery not supported for leveldb"))
+	page, err := s.QueryAllDidsSorted(l.ctx, "created", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DID0"}, resultKeys(page.Records), "should index the seeds for sorted queries")
+	l.nextTx()
+
+	update := testUpdate("did:example:seed", key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, "DID0", update, signUpdate(t, l, key, "DID0", update)), "should let the controller update the seeds")
+
+	_, err = s.QueryDidByKey(l.ctx, "DID1")
+	assertErrorCode(t, err, codeDidNotFound, "should not add the sample dids")
+
+	l = newTestLedger(t)
+
+	err = a.InitLedger(l.ctx, `[]`)
+	require.NoError(t, err, "should initialize without dids")
+	assert.Len(t, l.state, 1, "should only write the initialization marker")
+}
+
+func TestInitializationGuard(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	err := l.before(s, "CreateDid")
+	assertErrorCode(t, err, codeNotInitialized, "should refuse writes before initialization")
+
+	err = l.before(new(CredentialContract), "IssueCredential")
+	assertErrorCode(t, err, codeNotInitialized, "should guard the writes of every contract")
+
+	err = l.before(s, "QueryAllDids")
+	assert.Nil(t, err, "should allow queries before initialization")
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+
+	err = l.before(s, "CreateDid")
+	assert.Nil(t, err, "should allow writes once initialized")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+
+	err = l.before(s, "CreateDid")
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestCreateDid(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+
+	id, err := s.CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	require.NoError(t, err, "should create the did")
+
+	hash := sha256.Sum256([]byte(testChannel + ":" + testChaincode + ":" + key.pem))
+	assert.Equal(t, didMethodPrefix+keyencoding.Base58Encode(hash[:]), id, "should derive the id from channel, chaincode and key")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id+"#keys-1", did.AuthenticationId, "should qualify the authentication id")
+	assert.Equal(t, id, did.AuthenticationController, "should default the authentication controller to the did")
+	assert.Equal(t, id+"#vcs", did.ServiceId, "should qualify the service id")
+	assert.Equal(t, testClientID, did.Controller, "should record the creator as controller")
+	assert.Equal(t, "tx1", did.Provenance.Created.TxID, "should record the creating transaction")
+	assert.Equal(t, []string{testMSPID}, l.endorsers(t, id), "should restrict endorsement to the creator's organization")
+
+	name, payload := l.event(t)
+	assert.Equal(t, "did.created.fabric", name, "should name the event after the operation and did method")
+	assert.Contains(t, string(payload), id, "should carry the did in the event")
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeDidAlreadyExists, "should not create the same did twice")
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", "", "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a public key")
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", "not a key", "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject keys that do not parse")
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", rsaVerificationKey2018, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject keys of another type")
+
+	_, err = s.CreateDid(l.ctx, "keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject an authentication id that is not a did url")
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", "http://example.com/vc/", "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject endpoint schemes that are not allowed")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+
+	l = newTestLedger(t)
+	l.stub.GetSignedProposalReturns(nil, errors.New("GetSignedProposal error"))
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assert.EqualError(t, err, "Failed to read signed proposal", "should need the invoked chaincode name")
+
+	l = newTestLedger(t)
+	l.identity.GetIDReturns("", errors.New("GetID error"))
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", key.pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assert.EqualError(t, err, "Failed to read client identity. GetID error", "should need the client identity")
+}
+
+func TestUpdateDid(t *testing.T) {
+	l := newTestLedger(t)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	signature := signUpdate(t, l, key, id, update)
+
+	l.setClient(otherClientID, otherMSPID)
+	err := updateDid(l, id, update, signature)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller update")
+
+	l.setClient(testClientID, testMSPID)
+	err = updateDid(l, id, update, "")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature")
+
+	err = updateDid(l, id, update, "not base64!")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a base64 signature")
+
+	err = updateDid(l, id, update, signUpdate(t, l, newTestKey(t), id, update))
+	assertErrorCode(t, err, codeInvalidSignature, "should require the current authentication key")
+
+	err = updateDid(l, id, update, signature)
+	require.NoError(t, err, "should update the did")
+
+	did, err := new(DidContract).QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/vc/", did.ServiceEndPoint, "should store the new details")
+	assert.Equal(t, "tx2", did.Provenance.Updated.TxID, "should record the updating transaction")
+	assert.Equal(t, "tx1", did.Provenance.Created.TxID, "should keep the creating transaction")
+
+	name, _ := l.event(t)
+	assert.Equal(t, "did.updated.fabric", name, "should emit did.updated")
+
+	l.nextTx()
+	err = updateDid(l, id, update, signature)
+	assertErrorCode(t, err, codeInvalidSignature, "should not accept a signature over an earlier version")
+
+	invalid := testUpdate(id, key, testEndpoint)
+	invalid.AuthenticationId = id
+	err = updateDid(l, id, invalid, signUpdate(t, l, key, id, invalid))
+	assertErrorCode(t, err, codeInvalidArgument, "should validate the did syntax")
+
+	err = updateDid(l, "DID9", update, signature)
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.stub.PutStateReturns(errors.New("PutState error"))
+	err = updateDid(l, id, update, signUpdate(t, l, key, id, update))
+	assert.EqualError(t, err, "Failed to put to world state. PutState error", "should return ledger errors")
+}
+
+func TestDeactivateDid(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+	signature := signUpdate(t, l, key, id, &update)
+
+	err = s.DeactivateDid(l.ctx, id, "")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature")
+
+	err = s.DeactivateDid(l.ctx, id, signature)
+	require.NoError(t, err, "should deactivate the did")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.True(t, did.Deactivated, "should mark the did deactivated")
+
+	name, _ := l.event(t)
+	assert.Equal(t, "did.deactivated.fabric", name, "should emit did.deactivated")
+
+	err = s.DeactivateDid(l.ctx, id, signature)
+	assertErrorCode(t, err, codeDidDeactivated, "should not deactivate twice")
+
+	err = updateDid(l, id, testUpdate(id, key, testEndpoint), signature)
+	assertErrorCode(t, err, codeDidDeactivated, "should not update a deactivated did")
+}
+
+func TestQueryDidByKey(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	assert.Nil(t, err, "should return stored dids")
+	assert.Equal(t, id, did.Id, "should return the did stored under the key")
+	assert.NotNil(t, l.state[testDidKey(id)], "should store dids under the did namespace")
+	assert.Nil(t, l.state[id], "should not store dids under simple keys")
+
+	_, err = s.QueryDidByKey(l.ctx, "DID9")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown keys")
+
+	l.state[testDidKey("DID9")] = []byte(`{"id":"did:example:1","unknown":true}`)
+	_, err = s.QueryDidByKey(l.ctx, "DID9")
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject records with unknown members")
+
+	l.state[testDidKey("DID9")] = []byte(`{"id":"did:example:1"} {}`)
+	_, err = s.QueryDidByKey(l.ctx, "DID9")
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject trailing data")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.QueryDidByKey(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestQueryDidById(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+
+	did, err := s.QueryDidById(l.ctx, id)
+	assert.Nil(t, err, "should find generated dids by key")
+	assert.Equal(t, id, did.Id, "should return the generated did")
+
+	did, err = s.QueryDidById(l.ctx, "did:example:12346789asdfghjkl")
+	assert.Nil(t, err, "should find other dids by scanning")
+	assert.Equal(t, "did:example:12346789asdfghjkl", did.Id, "should return the matching did")
+
+	_, err = s.QueryDidById(l.ctx, "did:example:unknown")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown ids")
+
+	iterator := failingStateIterator(errors.New("Next error"))
+	l.stub.GetStateByPartialCompositeKeyReturns(iterator, nil)
+	_, err = s.QueryDidById(l.ctx, "did:example:unknown")
+	assert.EqualError(t, err, "Next error", "should return iterator errors")
+	assert.Equal(t, 1, iterator.CloseCallCount(), "should close the iterator")
+	assert.Zero(t, l.stub.GetStateByPartialCompositeKeyWithPaginationCallCount(), "should not use paginated queries")
+
+	objectType, attributes := l.stub.GetStateByPartialCompositeKeyArgsForCall(l.stub.GetStateByPartialCompositeKeyCallCount() - 1)
+	assert.Equal(t, didObjectType, objectType, "should only scan the did namespace")
+	assert.Empty(t, attributes, "should scan all dids")
+
+	l.stub.GetStateByPartialCompositeKeyReturns(nil, errors.New("GetStateByPartialCompositeKey error"))
+	_, err = s.QueryDidById(l.ctx, "did:example:unknown")
+	assert.EqualError(t, err, "GetStateByPartialCompositeKey error", "should return query errors")
+}
+
+func TestQueryAllDids(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	page, err := s.QueryAllDids(l.ctx, "", "")
+	assert.Nil(t, err, "should not error on an empty ledger")
+	assert.Equal(t, &PaginatedQueryResult{Records: []QueryResult{}}, page, "should return no dids on an empty ledger")
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+	require.NoError(t, new(AdminContract).SetEndpointSchemes(l.ctx, `["https"]`))
+
+	page, err = s.QueryAllDids(l.ctx, "", "")
+	assert.Nil(t, err, "should return all dids")
+	require.Len(t, page.Records, 2, "should skip records stored under composite keys")
+	assert.Equal(t, "DID0", page.Records[0].Key, "should return the dids in key order")
+	assert.Equal(t, "did:example:12346789abcdefghi", page.Records[0].Record.Id, "should decode the records")
+	assert.Equal(t, int32(2), page.FetchedRecordsCount, "should return the number of dids")
+	assert.Equal(t, "", page.Bookmark, "should return an empty bookmark once all dids are returned")
+
+	objectType, _, pageSize, _ := l.stub.GetStateByPartialCompositeKeyWithPaginationArgsForCall(l.stub.GetStateByPartialCompositeKeyWithPaginationCallCount() - 1)
+	assert.Equal(t, didObjectType, objectType, "should only read the did namespace")
+	assert.Equal(t, queryPageSize, pageSize, "should read the world state in pages")
+
+	for i := 2; i < maxQueryRecords+int(queryPageSize)/2; i++ {
+		didNumber := fmt.Sprintf("DID%04d", i)
+		didAsBytes, err := marshalRecord(didNumber, Did{Id: fmt.Sprintf("did:example:%d", i)})
+		require.NoError(t, err)
+		l.state[testDidKey(didNumber)] = didAsBytes
+	}
+
+	page, err = s.QueryAllDids(l.ctx, "", "")
+	assert.Nil(t, err, "should return the first dids")
+	assert.Len(t, page.Records, maxQueryRecords, "should cap the number of dids per call")
+	assert.NotEqual(t, "", page.Bookmark, "should return a continuation bookmark")
+
+	bookmark := page.Bookmark
+	page, err = s.QueryAllDids(l.ctx, "", bookmark)
+	assert.Nil(t, err, "should continue at the bookmark")
+	assert.Len(t, page.Records, int(queryPageSize)/2, "should return the remaining dids")
+	assert.Equal(t, bookmark, testDidKey(page.Records[0].Key), "should start at the bookmark")
+	assert.Equal(t, "", page.Bookmark, "should return an empty bookmark on the last call")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(newStateIterator([]*queryresult.KV{{Key: testDidKey("DID9"), Value: []byte(`[]`)}}), new(peer.QueryResponseMetadata), nil)
+	_, err = s.QueryAllDids(l.ctx, "", "")
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject corrupt records")
+
+	iterator := failingStateIterator(errors.New("Next error"))
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(iterator, new(peer.QueryResponseMetadata), nil)
+	_, err = s.QueryAllDids(l.ctx, "", "")
+	assert.EqualError(t, err, "Next error", "should return iterator errors")
+	assert.Equal(t, 1, iterator.CloseCallCount(), "should close the iterator")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	_, err = s.QueryAllDids(l.ctx, "", "")
+	assert.EqualError(t, err, "GetStateByPartialCompositeKeyWithPagination error", "should return query errors")
+}
+
+func TestQueryAllDidsWithPagination(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+
+	page, err := s.QueryAllDidsWithPagination(l.ctx, "", 1, "")
+	assert.Nil(t, err, "should return the first page")
+	require.Len(t, page.Records, 1, "should return at most pageSize dids")
+	assert.Equal(t, "DID0", page.Records[0].Key, "should start at the first did")
+	assert.Equal(t, int32(1), page.FetchedRecordsCount, "should return the fetched count")
+	assert.Equal(t, testDidKey("DID1"), page.Bookmark, "should return the bookmark of the next page")
+
+	page, err = s.QueryAllDidsWithPagination(l.ctx, "", 1, page.Bookmark)
+	assert.Nil(t, err, "should return the next page")
+	require.Len(t, page.Records, 1, "should return the remaining did")
+	assert.Equal(t, "DID1", page.Records[0].Key, "should continue at the bookmark")
+	assert.Equal(t, "", page.Bookmark, "should return an empty bookmark on the last page")
+
+	objectType, attributes, pageSize, bookmark := l.stub.GetStateByPartialCompositeKeyWithPaginationArgsForCall(1)
+	assert.Equal(t, []interface{}{didObjectType, []string{}, int32(1), testDidKey("DID1")}, []interface{}{objectType, attributes, pageSize, bookmark}, "should pass the page size and bookmark")
+
+	iterator := failingStateIterator(errors.New("Next error"))
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(iterator, new(peer.QueryResponseMetadata), nil)
+	_, err = s.QueryAllDidsWithPagination(l.ctx, "", 1, "")
+	assert.EqualError(t, err, "Next error", "should return iterator errors")
+	assert.Equal(t, 1, iterator.CloseCallCount(), "should close the iterator")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	_, err = s.QueryAllDidsWithPagination(l.ctx, "", 1, "")
+	assert.EqualError(t, err, "GetStateByPartialCompositeKeyWithPagination error", "should return query errors")
+}
+
+func TestQueryAllDidsKeyedById(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	keyed, err := s.QueryAllDidsKeyedById(l.ctx, "", "")
+	require.NoError(t, err, "should not error on an empty ledger")
+	assert.Equal(t, &KeyedQueryResult{Dids: map[string]*Did{}}, keyed, "should return no dids on an empty ledger")
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+
+	page, err := s.QueryAllDids(l.ctx, "", "")
+	require.NoError(t, err)
+
+	keyed, err = s.QueryAllDidsKeyedById(l.ctx, "", "")
+	require.NoError(t, err, "should return all dids")
+	require.Len(t, keyed.Dids, 2, "should return the dids of QueryAllDids")
+	assert.Equal(t, page.Records[0].Record, keyed.Dids["did:example:12346789abcdefghi"], "should key each did by its id")
+	assert.Equal(t, page.Records[1].Record, keyed.Dids[page.Records[1].Record.Id])
+	assert.Equal(t, int32(2), keyed.FetchedRecordsCount, "should return the number of dids")
+	assert.Equal(t, "", keyed.Bookmark, "should return the bookmark of QueryAllDids")
+
+	asBytes, err := json.Marshal(keyed)
+	require.NoError(t, err)
+	assert.Contains(t, string(asBytes), `"dids":{"did:example:12346789abcdefghi":{`, "should encode the dids as an object")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	_, err = s.QueryAllDidsKeyedById(l.ctx, "", "")
+	assert.Error(t, err, "should return ledger errors")
+}
+
+func TestQueryResultMetadata(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	page, err := s.QueryAllDids(l.ctx, "", "")
+	require.NoError(t, err)
+	require.Len(t, page.Records, 1)
+	assert.Equal(t, "tx1", page.Records[0].TxId, "should return the creating transaction of new dids")
+	assert.Equal(t, testStart.Add(time.Second).Format(time.RFC3339Nano), page.Records[0].Timestamp, "should return the time of the creating transaction")
+	assert.Equal(t, "tx1", page.Records[0].VersionId, "should return the version of the document")
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+	l.nextTx()
+
+	page, err = s.QueryAllDids(l.ctx, "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "tx2", page.Records[0].TxId, "should return the last modifying transaction")
+	assert.Equal(t, testStart.Add(2*time.Second).Format(time.RFC3339Nano), page.Records[0].Timestamp, "should return the time of the last modification")
+	assert.Equal(t, "tx2", page.Records[0].VersionId, "should follow the version of the document")
+
+	result := newQueryResult("DID9", &Did{Id: "did:example:9"})
+	assert.Equal(t, &QueryResult{Key: "DID9", Record: &Did{Id: "did:example:9"}}, result, "should leave the metadata of dids without provenance empty")
+}
@@ -0,0 +1,161 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// registrationFeeConfig names the configuration entry holding the registration fee
+const registrationFeeConfig = "registrationFee"
+
+// defaultFeeFunction is the function of the token chaincode called to pay the
+// fee, the transfer function of an ERC-20 style token chaincode
+const defaultFeeFunction = "Transfer"
+
+// RegistrationFee describes the fee paid for every registered did by calling
+// Function of the token chaincode named Chaincode with the recipient and the
+// amount due. The token chaincode must be installed on the same channel, and
+// it sees the client identity registering the dids as its caller. An empty
+// chaincode means registrations are free
+type RegistrationFee struct {
+	Chaincode string `json:"chaincode"`
+	Function  string `json:"function"`
+	Recipient string `json:"recipient"`
+	Amount    int    `json:"amount"`
+}
+
+func registrationFeeKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configObjectType, []string{registrationFeeConfig})
+}
+
+// getRegistrationFee returns the configured registration fee, or a fee that
+// charges nothing if none has been configured
+func getRegistrationFee(ctx contractapi.TransactionContextInterface) (*RegistrationFee, error) {
+	key, err := registrationFeeKey(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	feeAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if feeAsBytes == nil {
+		return &RegistrationFee{Function: defaultFeeFunction}, nil
+	}
+
+	fee := new(RegistrationFee)
+
+	if err := unmarshalRecord(key, feeAsBytes, fee); err != nil {
+		return nil, err
+	}
+
+	return fee, nil
+}
+
+// SetRegistrationFee replaces the registration fee with the given JSON
+// RegistrationFee. The function defaults to Transfer, and an empty chaincode
+// turns the fee off. Only registry administrators may call it
+func (a *AdminContract) SetRegistrationFee(ctx contractapi.TransactionContextInterface, feeJSON string) error {
+	fee := RegistrationFee{}
+
+	if err := decodeStrict([]byte(feeJSON), &fee); err != nil {
+		return newError(codeInvalidArgument, "Failed to decode fee. %s", err.Error())
+	}
+
+	if fee.Function == "" {
+		fee.Function = defaultFeeFunction
+	}
+
+	if fee.Chaincode == "" {
+		fee = RegistrationFee{Function: fee.Function}
+	} else {
+		if fee.Recipient == "" {
+			return newError(codeInvalidArgument, "recipient of the fee must be set")
+		}
+
+		if fee.Amount <= 0 {
+			return newError(codeInvalidArgument, "Fee amount %d must be positive", fee.Amount)
+		}
+	}
+
+	key, err := registrationFeeKey(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	feeAsBytes, err := marshalRecord(key, fee)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, feeAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryRegistrationFee returns the registration fee
+func (s *DidContract) QueryRegistrationFee(ctx contractapi.TransactionContextInterface) (*RegistrationFee, error) {
+	return getRegistrationFee(ctx)
+}
+
+// chargeRegistrationFee debits the fee for count dids from the submitting client
+// by invoking the configured token chaincode, failing the registration if the
+// token chaincode rejects the payment
+func chargeRegistrationFee(ctx contractapi.TransactionContextInterface, count int) error {
+	fee, err := getRegistrationFee(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if fee.Chaincode == "" {
+		return nil
+	}
+
+	return payFee(ctx, fee, fee.Amount*count)
+}
+
+// payFee transfers amount from the submitting client to the recipient of fee by
+// invoking its token chaincode
+func payFee(ctx contractapi.TransactionContextInterface, fee *RegistrationFee, amount int) error {
+	args := [][]byte{[]byte(fee.Function), []byte(fee.Recipient), []byte(strconv.Itoa(amount))}
+
+	response := ctx.GetStub().InvokeChaincode(fee.Chaincode, args, "")
+
+	if response.Status != shim.OK {
+		return newError(codeFeeRejected, "Failed to pay fee of %d to %s with %s. %s", amount, fee.Recipient, fee.Chaincode, response.Message)
+	}
+
+	return nil
+}
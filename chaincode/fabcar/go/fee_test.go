@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRegistrationFee(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+
+	fee, err := s.QueryRegistrationFee(l.ctx)
+	require.NoError(t, err, "should return the default fee")
+	assert.Equal(t, "", fee.Chaincode, "should not charge for registrations by default")
+
+	err = l.before(a, "SetRegistrationFee")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators set the fee")
+
+	l.setAdmin(true)
+
+	err = a.SetRegistrationFee(l.ctx, `{"chaincode":"token_erc20","recipient":"registry","amount":5}`)
+	require.NoError(t, err, "should set the fee")
+
+	fee, err = s.QueryRegistrationFee(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &RegistrationFee{Chaincode: "token_erc20", Function: defaultFeeFunction, Recipient: "registry", Amount: 5}, fee, "should default to the Transfer function")
+
+	err = a.SetRegistrationFee(l.ctx, `{"chaincode":"token_erc20","amount":5}`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a recipient")
+
+	err = a.SetRegistrationFee(l.ctx, `{"chaincode":"token_erc20","recipient":"registry","amount":0}`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a positive amount")
+
+	err = a.SetRegistrationFee(l.ctx, `{"chaincode":"token_erc20","recipient":"registry","amount":5,"currency":"EUR"}`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject unknown members")
+
+	err = a.SetRegistrationFee(l.ctx, `{"chaincode":"","amount":5}`)
+	require.NoError(t, err, "should turn the fee off")
+
+	fee, err = s.QueryRegistrationFee(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &RegistrationFee{Function: defaultFeeFunction}, fee, "should clear the fee when it is turned off")
+}
+
+func TestRegistrationFee(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).SetRegistrationFee(l.ctx, `{"chaincode":"token_erc20","function":"TransferFrom","recipient":"registry","amount":5}`))
+
+	l.stub.InvokeChaincodeReturns(peer.Response{Status: shim.OK})
+	createTestDid(t, l, newTestKey(t))
+
+	require.Equal(t, 1, l.stub.InvokeChaincodeCallCount(), "should pay the fee when creating a did")
+	chaincode, args, channel := l.stub.InvokeChaincodeArgsForCall(0)
+	assert.Equal(t, "token_erc20", chaincode, "should call the configured token chaincode")
+	assert.Equal(t, [][]byte{[]byte("TransferFrom"), []byte("registry"), []byte("5")}, args, "should transfer the fee to the recipient")
+	assert.Equal(t, "", channel, "should call the token chaincode on the same channel")
+
+	_, err := s.BatchCreateDids(l.ctx, batchJSON(t, batchItem(t, newTestKey(t)), batchItem(t, newTestKey(t))))
+	require.NoError(t, err)
+
+	require.Equal(t, 2, l.stub.InvokeChaincodeCallCount(), "should pay for a batch at once")
+	_, args, _ = l.stub.InvokeChaincodeArgsForCall(1)
+	assert.Equal(t, []byte("10"), args[2], "should charge the fee for every did of the batch")
+
+	l.stub.InvokeChaincodeReturns(peer.Response{Status: shim.ERROR, Message: "insufficient funds"})
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeFeeRejected, "should not register dids whose fee is not paid")
+	assert.Contains(t, err.Error(), "insufficient funds", "should report why the payment failed")
+}
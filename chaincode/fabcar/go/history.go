@@ -0,0 +1,71 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// HistoryQueryResult describes a change of a did recorded in the ledger history
+type HistoryQueryResult struct {
+	TxId      string `json:"txId"`
+	Timestamp string `json:"timestamp"`
+	IsDelete  bool   `json:"isDelete"`
+	Record    *Did   `json:"record,omitempty" metadata:"record,optional"`
+}
+
+// QueryDidHistory returns every change of the did stored with given key, oldest
+// first, including the changes made before it was moved to another namespace
+func (s *DidContract) QueryDidHistory(ctx contractapi.TransactionContextInterface, didNumber string) ([]HistoryQueryResult, error) {
+	modifications, err := didHistory(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []HistoryQueryResult{}
+
+	for _, modification := range modifications {
+		result := HistoryQueryResult{TxId: modification.TxId, IsDelete: modification.IsDelete}
+
+		if modification.Timestamp != nil {
+			result.Timestamp = time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC().Format(time.RFC3339Nano)
+		}
+
+		if !modification.IsDelete {
+			did := new(Did)
+
+			if err := unmarshalRecord(didNumber, modification.Value, did); err != nil {
+				return nil, err
+			}
+			result.Record = did
+		}
+
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		return nil, newError(codeDidNotFound, "%s does not exist", didNumber)
+	}
+
+	return results, nil
+}
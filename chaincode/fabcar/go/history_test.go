@@ -0,0 +1,77 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryDidHistory(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+
+	results, err := s.QueryDidHistory(l.ctx, id)
+	require.NoError(t, err, "should return the history")
+	require.Len(t, results, 2, "should return every change")
+	assert.Equal(t, "tx1", results[0].TxId, "should return the oldest change first")
+	assert.Equal(t, testStart.Add(time.Second).Format(time.RFC3339Nano), results[0].Timestamp, "should return the time of each change")
+	assert.Equal(t, testEndpoint, results[0].Record.ServiceEndPoint, "should return the did as written by each change")
+	assert.Equal(t, "https://example.org/vc/", results[1].Record.ServiceEndPoint, "should return the latest version last")
+
+	_, err = s.QueryDidHistory(l.ctx, "DID9")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for dids without history")
+
+	deleted := &queryresult.KeyModification{TxId: "tx9", IsDelete: true, Timestamp: &timestamp.Timestamp{Seconds: testStart.Unix()}}
+	l.stub.GetHistoryForKeyReturns(newHistoryIterator([]*queryresult.KeyModification{deleted}), nil)
+
+	results, err = s.QueryDidHistory(l.ctx, id)
+	require.NoError(t, err)
+	assert.True(t, results[0].IsDelete, "should return deletions")
+	assert.Nil(t, results[0].Record, "should not return a record for deletions")
+
+	l.stub.GetHistoryForKeyReturns(newHistoryIterator([]*queryresult.KeyModification{{TxId: "tx9", Value: []byte("{")}}), nil)
+	_, err = s.QueryDidHistory(l.ctx, id)
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject corrupt versions")
+
+	iterator := new(mocks.HistoryQueryIterator)
+	iterator.HasNextReturns(true)
+	iterator.NextReturns(nil, errors.New("Next error"))
+	l.stub.GetHistoryForKeyReturns(iterator, nil)
+
+	_, err = s.QueryDidHistory(l.ctx, id)
+	assert.EqualError(t, err, "Next error", "should return iterator errors")
+	assert.Equal(t, 1, iterator.CloseCallCount(), "should close the iterator")
+
+	l.stub.GetHistoryForKeyReturns(nil, errors.New("GetHistoryForKey error"))
+	_, err = s.QueryDidHistory(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read history of did "+testMSPID+" "+id+". GetHistoryForKey error", "should return history errors")
+}
@@ -0,0 +1,127 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+)
+
+// didMethodPrefix is the prefix of dids generated by this registry
+const didMethodPrefix = "did:fabric:"
+
+// chaincodeName returns the name of the chaincode invoked by the current
+// transaction proposal
+func chaincodeName(ctx contractapi.TransactionContextInterface) (string, error) {
+	signedProposal, err := ctx.GetStub().GetSignedProposal()
+
+	if err != nil || signedProposal == nil {
+		return "", fmt.Errorf("Failed to read signed proposal")
+	}
+
+	proposal := new(peer.Proposal)
+
+	if err := proto.Unmarshal(signedProposal.ProposalBytes, proposal); err != nil {
+		return "", fmt.Errorf("Failed to read proposal. %s", err.Error())
+	}
+
+	payload := new(peer.ChaincodeProposalPayload)
+
+	if err := proto.Unmarshal(proposal.Payload, payload); err != nil {
+		return "", fmt.Errorf("Failed to read proposal payload. %s", err.Error())
+	}
+
+	invocation := new(peer.ChaincodeInvocationSpec)
+
+	if err := proto.Unmarshal(payload.Input, invocation); err != nil {
+		return "", fmt.Errorf("Failed to read chaincode invocation. %s", err.Error())
+	}
+
+	if invocation.ChaincodeSpec == nil || invocation.ChaincodeSpec.ChaincodeId == nil {
+		return "", fmt.Errorf("Proposal does not name the invoked chaincode")
+	}
+
+	return invocation.ChaincodeSpec.ChaincodeId.Name, nil
+}
+
+// generateDidId derives the method specific identifier of a new did from its
+// initial public key and the channel and chaincode it is registered with, so the
+// same key always yields the same did within a registry
+func generateDidId(ctx contractapi.TransactionContextInterface, publicKey string) (string, error) {
+	if publicKey == "" {
+		return "", newError(codeInvalidArgument, "An authentication public key is required to generate a did")
+	}
+
+	name, err := chaincodeName(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	if parsed, err := keyencoding.Parse(publicKey); err == nil {
+		if pemKey, err := keyencoding.PemFromPublicKey(parsed); err == nil {
+			publicKey = pemKey
+		}
+	}
+
+	hash := sha256.Sum256([]byte(ctx.GetStub().GetChannelID() + ":" + name + ":" + publicKey))
+
+	return didMethodPrefix + keyencoding.Base58Encode(hash[:]), nil
+}
+
+// qualifyId expands a reference relative to a did, such as #keys-1, into an
+// absolute did url. Empty references refer to the did itself
+func qualifyId(did string, ref string) string {
+	if ref == "" {
+		return did
+	}
+
+	if strings.HasPrefix(ref, "#") {
+		return did + ref
+	}
+
+	return ref
+}
+
+// assignIdentifier generates the id of a new did and expands the references of
+// its authentication key and service relative to it
+func assignIdentifier(ctx contractapi.TransactionContextInterface, did *Did) error {
+	id, err := generateDidId(ctx, did.AuthenticationPublicKeyPerm)
+
+	if err != nil {
+		return err
+	}
+
+	did.Id = id
+	did.AuthenticationId = qualifyId(id, did.AuthenticationId)
+	did.AuthenticationController = qualifyId(id, did.AuthenticationController)
+
+	if did.ServiceId != "" {
+		did.ServiceId = qualifyId(id, did.ServiceId)
+	}
+
+	return nil
+}
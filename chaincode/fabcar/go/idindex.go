@@ -0,0 +1,146 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// didIdIndex is the name of the composite key index of dids by id. Entries are
+// keyed by the colon separated segments of the id followed by the did key, so
+// that the dids under a method or organization prefix can be read with a
+// partial key query
+const didIdIndex = "didId~key"
+
+// idIndexKey returns the world state key of the id index entry of the did
+// stored with didNumber
+func idIndexKey(ctx contractapi.TransactionContextInterface, id string, didNumber string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(didIdIndex, append(strings.Split(id, ":"), didNumber))
+}
+
+// putIdIndexEntry records the id of the did stored with didNumber in the id index
+func putIdIndexEntry(ctx contractapi.TransactionContextInterface, id string, didNumber string) error {
+	if id == "" {
+		return nil
+	}
+
+	indexKey, err := idIndexKey(ctx, id, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// idPrefixSegments returns the id segments a prefix such as did:example:org1:*
+// selects, and whether the prefix ends with a colon, so that only ids with
+// further segments match. The prefix must consist of whole segments, the
+// trailing wildcard is optional
+func idPrefixSegments(prefix string) ([]string, bool, error) {
+	trimmed := prefix
+
+	if strings.HasSuffix(trimmed, ":*") {
+		trimmed = strings.TrimSuffix(trimmed, "*")
+	}
+
+	nested := strings.HasSuffix(trimmed, ":")
+	trimmed = strings.TrimSuffix(trimmed, ":")
+
+	if trimmed == "" || strings.Contains(trimmed, "*") {
+		return nil, false, newError(codeInvalidArgument, "Prefix %s must be whole segments of a did id, optionally followed by :*", prefix)
+	}
+
+	return strings.Split(trimmed, ":"), nested, nil
+}
+
+// QueryDidsByIdPrefix returns a page of at most pageSize dids whose id starts
+// with the given segments, for example did:example:org1:* for the dids of an
+// organization under the example method, starting at the bookmark returned
+// with the previous page. Deleted dids are skipped, so pages may hold fewer
+// than pageSize dids
+func (s *DidContract) QueryDidsByIdPrefix(ctx contractapi.TransactionContextInterface, prefix string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	segments, nested, err := idPrefixSegments(prefix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(didIdIndex, segments, pageSize, bookmark)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	defer resultsIterator.Close()
+
+	results := []QueryResult{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if nested && len(keyParts) < len(segments)+2 {
+			continue
+		}
+
+		didNumber := keyParts[len(keyParts)-1]
+		did, err := indexedDid(ctx, didNumber)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if did == nil {
+			continue
+		}
+
+		results = append(results, *newQueryResult(didNumber, did))
+	}
+
+	page := PaginatedQueryResult{
+		Records:             results,
+		FetchedRecordsCount: int32(len(results)),
+		Bookmark:            metadata.Bookmark,
+	}
+
+	return &page, nil
+}
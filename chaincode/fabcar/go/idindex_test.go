@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryDidsByIdPrefix(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+	l.nextTx()
+
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	other := createTestDid(t, l, newTestKey(t))
+
+	page, err := s.QueryDidsByIdPrefix(l.ctx, "did:example:*", 10, "")
+	require.NoError(t, err, "should return the dids under the prefix")
+	assert.Equal(t, []string{"DID0", "DID1"}, resultKeys(page.Records), "should return the seeded dids of the example method")
+	assert.Equal(t, "did:example:12346789abcdefghi", page.Records[0].Record.Id, "should decode the records")
+	assert.Equal(t, int32(2), page.FetchedRecordsCount, "should return the fetched count")
+
+	page, err = s.QueryDidsByIdPrefix(l.ctx, didMethodPrefix+"*", 10, "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{id, other}, resultKeys(page.Records), "should return the generated dids")
+
+	page, err = s.QueryDidsByIdPrefix(l.ctx, "did", 10, "")
+	require.NoError(t, err)
+	assert.Len(t, page.Records, 4, "should accept prefixes without wildcard")
+
+	page, err = s.QueryDidsByIdPrefix(l.ctx, id, 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{id}, resultKeys(page.Records), "should match whole ids")
+
+	page, err = s.QueryDidsByIdPrefix(l.ctx, "did:example:12346789abcdefghi:*", 10, "")
+	require.NoError(t, err)
+	assert.Empty(t, page.Records, "should only match whole segments")
+
+	page, err = s.QueryDidsByIdPrefix(l.ctx, "did:example:*", 1, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"DID0"}, resultKeys(page.Records), "should return at most pageSize dids")
+	assert.NotEqual(t, "", page.Bookmark, "should return the bookmark of the next page")
+
+	page, err = s.QueryDidsByIdPrefix(l.ctx, "did:example:*", 1, page.Bookmark)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DID1"}, resultKeys(page.Records), "should continue at the bookmark")
+
+	_, err = s.QueryDidsByIdPrefix(l.ctx, "did:example:*", 0, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a positive page size")
+
+	_, err = s.QueryDidsByIdPrefix(l.ctx, "did:example:*", maxQueryRecords+1, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should bound the page size")
+
+	deleteTestDid(t, l, key, id)
+
+	page, err = s.QueryDidsByIdPrefix(l.ctx, didMethodPrefix+"*", 10, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{other}, resultKeys(page.Records), "should skip deleted dids")
+
+	l.setTime(testStart.Add(deletedDidRetention + time.Minute))
+	_, err = new(AdminContract).PurgeDeletedDids(l.ctx)
+	require.NoError(t, err)
+
+	indexKey, err := idIndexKey(l.ctx, id, id)
+	require.NoError(t, err)
+	assert.NotContains(t, l.state, indexKey, "should remove the index entries of purged dids")
+
+	for _, prefix := range []string{"", "*", ":*", "did:exa*", "did:*:org1"} {
+		_, err = s.QueryDidsByIdPrefix(l.ctx, prefix, 10, "")
+		assertErrorCode(t, err, codeInvalidArgument, "should reject prefix "+prefix)
+	}
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	_, err = s.QueryDidsByIdPrefix(l.ctx, "did:*", 10, "")
+	assert.EqualError(t, err, "Failed to read from world state. GetStateByPartialCompositeKeyWithPagination error", "should return query errors")
+}
@@ -0,0 +1,79 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// putIndexEntry stores an index entry linking value to id. As with the marbles
+// sample, only the composite key is needed so the value is a single null byte
+func putIndexEntry(ctx contractapi.TransactionContextInterface, index string, value string, id string) error {
+	indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{value, id})
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// queryIndexEntries returns the ids linked to value in the given index. Values
+// linked to more than maxQueryRecords ids fail
+func queryIndexEntries(ctx contractapi.TransactionContextInterface, index string, value string) ([]string, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, []string{value})
+
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	ids := []string{}
+
+	for resultsIterator.HasNext() {
+		if len(ids) == maxQueryRecords {
+			return nil, newTooManyRecordsError("index entries of " + value)
+		}
+
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ids = append(ids, keyParts[len(keyParts)-1])
+	}
+
+	return ids, nil
+}
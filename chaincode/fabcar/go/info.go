@@ -0,0 +1,107 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+// chaincodeVersion, chaincodeCommit and chaincodeBuildTime describe the build of
+// the chaincode. They are set by the linker, as in
+//
+//	go build -ldflags "-X main.chaincodeVersion=1.2.0 -X main.chaincodeCommit=$(git rev-parse HEAD) -X main.chaincodeBuildTime=$(date -u +%FT%TZ)"
+var (
+	chaincodeVersion   = "dev"
+	chaincodeCommit    = ""
+	chaincodeBuildTime = ""
+)
+
+// ContractInfo describes the deployed chaincode for health checks: its build,
+// its contracts and whether the ledger is initialized. Timestamp is the time of
+// the transaction that read it
+type ContractInfo struct {
+	Version       string            `json:"version"`
+	Commit        string            `json:"commit,omitempty" metadata:"commit,optional"`
+	BuildTime     string            `json:"buildTime,omitempty" metadata:"buildTime,optional"`
+	Contracts     []ContractSummary `json:"contracts"`
+	Initialized   bool              `json:"initialized"`
+	InitializedBy *ProvenanceEntry  `json:"initializedBy,omitempty" metadata:"initializedBy,optional"`
+	Timestamp     string            `json:"timestamp"`
+}
+
+// ContractSummary describes a contract of the chaincode and the number of
+// transaction functions it offers
+type ContractSummary struct {
+	Name         string `json:"name"`
+	Title        string `json:"title"`
+	Version      string `json:"version"`
+	Transactions int    `json:"transactions"`
+}
+
+// contractSummaries lists the contracts of the chaincode in the order they are
+// added by newChaincode
+var contractSummaries = []struct {
+	name   string
+	info   *metadata.InfoMetadata
+	access map[string]string
+}{
+	{didContractName, &didContractInfo, didContractAccess},
+	{credentialContractName, &credentialContractInfo, credentialContractAccess},
+	{adminContractName, &adminContractInfo, adminContractAccess},
+	{policyContractName, &policyContractInfo, policyContractAccess},
+	{consentContractName, &consentContractInfo, consentContractAccess},
+	{sharingContractName, &sharingContractInfo, sharingContractAccess},
+}
+
+// GetContractInfo returns the version and build of the chaincode, its contracts
+// and the initialization status of the ledger, so that monitoring systems and
+// gateways can check the deployment. It may be called before InitLedger
+func (s *DidContract) GetContractInfo(ctx contractapi.TransactionContextInterface) (*ContractInfo, error) {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	marker, err := getInitializedMarker(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info := ContractInfo{
+		Version:       chaincodeVersion,
+		Commit:        chaincodeCommit,
+		BuildTime:     chaincodeBuildTime,
+		Contracts:     []ContractSummary{},
+		Initialized:   marker != nil,
+		InitializedBy: marker,
+		Timestamp:     now.Format(time.RFC3339Nano),
+	}
+
+	for _, contract := range contractSummaries {
+		info.Contracts = append(info.Contracts, ContractSummary{Name: contract.name, Title: contract.info.Title, Version: contract.info.Version, Transactions: len(contract.access)})
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,64 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetContractInfo(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	require.NoError(t, l.before(s, "GetContractInfo"), "should answer before the ledger is initialized")
+
+	l.setTime(testStart.Add(time.Hour))
+
+	info, err := s.GetContractInfo(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, chaincodeVersion, info.Version, "should return the chaincode version")
+	assert.False(t, info.Initialized, "should report uninitialized ledgers")
+	assert.Nil(t, info.InitializedBy, "should not name an initializer")
+	assert.Equal(t, testStart.Add(time.Hour).Format(time.RFC3339Nano), info.Timestamp, "should return the time of the transaction")
+
+	require.Len(t, info.Contracts, len(contractSummaries), "should describe every contract")
+	assert.Equal(t, ContractSummary{Name: didContractName, Title: didContractInfo.Title, Version: chaincodeVersion, Transactions: len(didContractAccess)},
+		info.Contracts[0], "should describe the did contract first")
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+	initTx := l.stub.GetTxID()
+	l.nextTx()
+
+	info, err = s.GetContractInfo(l.ctx)
+	require.NoError(t, err)
+	assert.True(t, info.Initialized, "should report initialized ledgers")
+	require.NotNil(t, info.InitializedBy, "should name the initializer")
+	assert.Equal(t, initTx, info.InitializedBy.TxID, "should name the initializing transaction")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.GetContractInfo(l.ctx)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
@@ -0,0 +1,81 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package keyencoding
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+)
+
+// base58Alphabet is the bitcoin base58 alphabet used by base58btc multibase values
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// Base58Encode encodes data using the bitcoin base58 alphabet
+func Base58Encode(data []byte) string {
+	value := new(big.Int).SetBytes(data)
+	radix := big.NewInt(58)
+	modulus := new(big.Int)
+
+	encoded := []byte{}
+
+	for value.Sign() > 0 {
+		value.DivMod(value, radix, modulus)
+		encoded = append(encoded, base58Alphabet[modulus.Int64()])
+	}
+
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		encoded = append(encoded, base58Alphabet[0])
+	}
+
+	for i, j := 0, len(encoded)-1; i < j; i, j = i+1, j-1 {
+		encoded[i], encoded[j] = encoded[j], encoded[i]
+	}
+
+	return string(encoded)
+}
+
+// Base58Decode decodes a value encoded with the bitcoin base58 alphabet
+func Base58Decode(encoded string) ([]byte, error) {
+	value := new(big.Int)
+	radix := big.NewInt(58)
+
+	for _, c := range []byte(encoded) {
+		digit := bytes.IndexByte([]byte(base58Alphabet), c)
+
+		if digit < 0 {
+			return nil, fmt.Errorf("Invalid base58 character %q", c)
+		}
+
+		value.Mul(value, radix)
+		value.Add(value, big.NewInt(int64(digit)))
+	}
+
+	leadingZeros := 0
+
+	for leadingZeros < len(encoded) && encoded[leadingZeros] == base58Alphabet[0] {
+		leadingZeros++
+	}
+
+	return append(make([]byte, leadingZeros), value.Bytes()...), nil
+}
@@ -0,0 +1,222 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package keyencoding
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// Jwk describes the public members of a JSON Web Key as used by publicKeyJwk
+type Jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty" metadata:"crv,optional"`
+	X   string `json:"x,omitempty" metadata:"x,optional"`
+	Y   string `json:"y,omitempty" metadata:"y,optional"`
+	N   string `json:"n,omitempty" metadata:"n,optional"`
+	E   string `json:"e,omitempty" metadata:"e,optional"`
+	Kid string `json:"kid,omitempty" metadata:"kid,optional"`
+}
+
+// ParseJwk decodes and validates a JSON encoded public JWK. Private key members
+// are rejected
+func ParseJwk(jwkJSON string) (*Jwk, error) {
+	members := map[string]interface{}{}
+
+	if err := json.Unmarshal([]byte(jwkJSON), &members); err != nil {
+		return nil, fmt.Errorf("Failed to decode JWK. %s", err.Error())
+	}
+
+	for _, private := range []string{"d", "p", "q", "dp", "dq", "qi"} {
+		if _, ok := members[private]; ok {
+			return nil, fmt.Errorf("JWK must not contain private key member %s", private)
+		}
+	}
+
+	jwk := new(Jwk)
+	decoder := json.NewDecoder(strings.NewReader(jwkJSON))
+
+	if err := decoder.Decode(jwk); err != nil {
+		return nil, fmt.Errorf("Failed to decode JWK. %s", err.Error())
+	}
+
+	if _, err := jwk.PublicKey(); err != nil {
+		return nil, err
+	}
+
+	return jwk, nil
+}
+
+// decodeJwkMember decodes a base64url encoded JWK member
+func decodeJwkMember(name string, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("JWK member %s is required", name)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil {
+		return nil, fmt.Errorf("JWK member %s must be base64url encoded. %s", name, err.Error())
+	}
+
+	return decoded, nil
+}
+
+// jwkCurve returns the NIST curve with given JWK curve name
+func jwkCurve(crv string) (elliptic.Curve, error) {
+	switch crv {
+	case "P-256":
+		return elliptic.P256(), nil
+	case "P-384":
+		return elliptic.P384(), nil
+	case "P-521":
+		return elliptic.P521(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported EC curve %s", crv)
+	}
+}
+
+// parseSecp256k1Coordinates parses a secp256k1 public key from its big-endian
+// affine coordinates
+func parseSecp256k1Coordinates(x []byte, y []byte) (*secp256k1.PublicKey, error) {
+	if len(x) > 32 || len(y) > 32 {
+		return nil, fmt.Errorf("secp256k1 coordinates must be at most 32 bytes")
+	}
+
+	uncompressed := make([]byte, 65)
+	uncompressed[0] = 0x04
+	copy(uncompressed[33-len(x):33], x)
+	copy(uncompressed[65-len(y):], y)
+
+	return secp256k1.ParsePubKey(uncompressed)
+}
+
+// PublicKey validates the structure of the JWK and returns the public key it describes
+func (j *Jwk) PublicKey() (crypto.PublicKey, error) {
+	switch j.Kty {
+	case "RSA":
+		n, err := decodeJwkMember("n", j.N)
+
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeJwkMember("e", j.E)
+
+		if err != nil {
+			return nil, err
+		}
+
+		exponent := new(big.Int).SetBytes(e)
+
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("JWK RSA exponent is invalid")
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "EC":
+		x, err := decodeJwkMember("x", j.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeJwkMember("y", j.Y)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if j.Crv == "secp256k1" {
+			return parseSecp256k1Coordinates(x, y)
+		}
+
+		curve, err := jwkCurve(j.Crv)
+
+		if err != nil {
+			return nil, err
+		}
+
+		publicKey := ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+
+		if !curve.IsOnCurve(publicKey.X, publicKey.Y) {
+			return nil, fmt.Errorf("JWK point is not on curve %s", j.Crv)
+		}
+
+		return &publicKey, nil
+	case "OKP":
+		if j.Crv != "Ed25519" {
+			return nil, fmt.Errorf("Unsupported OKP curve %s", j.Crv)
+		}
+
+		x, err := decodeJwkMember("x", j.X)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 public key must be %d bytes", ed25519.PublicKeySize)
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("Unsupported JWK key type %s", j.Kty)
+	}
+}
+
+// fixedBytes returns the big-endian encoding of value padded to size bytes
+func fixedBytes(value *big.Int, size int) []byte {
+	encoded := value.Bytes()
+
+	return append(make([]byte, size-len(encoded)), encoded...)
+}
+
+// JwkFromPublicKey describes a public key as a JWK
+func JwkFromPublicKey(publicKey crypto.PublicKey) (*Jwk, error) {
+	encode := base64.RawURLEncoding.EncodeToString
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return &Jwk{Kty: "RSA", N: encode(key.N.Bytes()), E: encode(big.NewInt(int64(key.E)).Bytes())}, nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+
+		return &Jwk{Kty: "EC", Crv: key.Curve.Params().Name, X: encode(fixedBytes(key.X, size)), Y: encode(fixedBytes(key.Y, size))}, nil
+	case *secp256k1.PublicKey:
+		uncompressed := key.SerializeUncompressed()
+
+		return &Jwk{Kty: "EC", Crv: "secp256k1", X: encode(uncompressed[1:33]), Y: encode(uncompressed[33:])}, nil
+	case ed25519.PublicKey:
+		return &Jwk{Kty: "OKP", Crv: "Ed25519", X: encode(key)}, nil
+	default:
+		return nil, fmt.Errorf("%T keys cannot be described as a JWK", publicKey)
+	}
+}
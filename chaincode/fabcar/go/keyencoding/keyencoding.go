@@ -0,0 +1,73 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+// Package keyencoding converts public keys between the PEM, publicKeyJwk and
+// publicKeyMultibase representations used in did documents, so the registry can
+// store keys canonically whatever format they were registered in
+package keyencoding
+
+import (
+	"crypto"
+	"strings"
+)
+
+// Format identifies a public key representation
+type Format string
+
+// Supported public key representations
+const (
+	FormatPem       Format = "publicKeyPem"
+	FormatJwk       Format = "publicKeyJwk"
+	FormatMultibase Format = "publicKeyMultibase"
+)
+
+// DetectFormat returns the representation of key material, recognising JSON
+// objects as JWKs and base58btc values as multibase. Anything else is taken to be PEM
+func DetectFormat(material string) Format {
+	trimmed := strings.TrimSpace(material)
+
+	switch {
+	case strings.HasPrefix(trimmed, "{"):
+		return FormatJwk
+	case strings.HasPrefix(trimmed, string(MultibaseBase58btc)):
+		return FormatMultibase
+	default:
+		return FormatPem
+	}
+}
+
+// Parse parses key material in any supported representation
+func Parse(material string) (crypto.PublicKey, error) {
+	trimmed := strings.TrimSpace(material)
+
+	switch DetectFormat(trimmed) {
+	case FormatJwk:
+		jwk, err := ParseJwk(trimmed)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return jwk.PublicKey()
+	case FormatMultibase:
+		return ParseMultibase(trimmed)
+	default:
+		return ParsePem(material)
+	}
+}
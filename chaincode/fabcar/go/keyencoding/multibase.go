@@ -0,0 +1,106 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package keyencoding
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// MultibaseBase58btc is the multibase prefix of base58btc encoded values
+const MultibaseBase58btc = 'z'
+
+// Unsigned varint multicodec prefixes of the supported public key types
+var (
+	Ed25519PubMulticodec   = []byte{0xed, 0x01}
+	Secp256k1PubMulticodec = []byte{0xe7, 0x01}
+	P256PubMulticodec      = []byte{0x80, 0x24}
+)
+
+// DecodeMultibase decodes a base58btc multibase value
+func DecodeMultibase(value string) ([]byte, error) {
+	if value == "" || value[0] != MultibaseBase58btc {
+		return nil, fmt.Errorf("Only base58btc (z) multibase values are supported")
+	}
+
+	return Base58Decode(value[1:])
+}
+
+// EncodeMultibase encodes data as a base58btc multibase value
+func EncodeMultibase(data []byte) string {
+	return string(MultibaseBase58btc) + Base58Encode(data)
+}
+
+// ParseMultibase parses a publicKeyMultibase value, validating that it carries
+// the multicodec prefix of a supported key type
+func ParseMultibase(publicKeyMultibase string) (crypto.PublicKey, error) {
+	decoded, err := DecodeMultibase(publicKeyMultibase)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(decoded, Ed25519PubMulticodec):
+		key := decoded[len(Ed25519PubMulticodec):]
+
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Ed25519 public key must be %d bytes", ed25519.PublicKeySize)
+		}
+
+		return ed25519.PublicKey(key), nil
+	case bytes.HasPrefix(decoded, Secp256k1PubMulticodec):
+		return secp256k1.ParsePubKey(decoded[len(Secp256k1PubMulticodec):])
+	case bytes.HasPrefix(decoded, P256PubMulticodec):
+		x, y := elliptic.UnmarshalCompressed(elliptic.P256(), decoded[len(P256PubMulticodec):])
+
+		if x == nil {
+			return nil, fmt.Errorf("P-256 public key must be a compressed point")
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("publicKeyMultibase does not carry a supported multicodec prefix")
+	}
+}
+
+// MultibaseFromPublicKey encodes a public key as a publicKeyMultibase value
+func MultibaseFromPublicKey(publicKey crypto.PublicKey) (string, error) {
+	switch key := publicKey.(type) {
+	case ed25519.PublicKey:
+		return EncodeMultibase(append(append([]byte{}, Ed25519PubMulticodec...), key...)), nil
+	case *secp256k1.PublicKey:
+		return EncodeMultibase(append(append([]byte{}, Secp256k1PubMulticodec...), key.SerializeCompressed()...)), nil
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("Only P-256 ECDSA keys can be encoded as publicKeyMultibase")
+		}
+
+		return EncodeMultibase(append(append([]byte{}, P256PubMulticodec...), elliptic.MarshalCompressed(key.Curve, key.X, key.Y)...)), nil
+	default:
+		return "", fmt.Errorf("%T keys cannot be encoded as publicKeyMultibase", publicKey)
+	}
+}
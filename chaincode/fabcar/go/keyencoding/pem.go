@@ -0,0 +1,113 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package keyencoding
+
+import (
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// oidPublicKeyECDSA and oidNamedCurveSecp256k1 identify secp256k1 keys in a PKIX
+// SubjectPublicKeyInfo, which crypto/x509 does not handle
+var (
+	oidPublicKeyECDSA      = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidNamedCurveSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+)
+
+// subjectPublicKeyInfo mirrors the PKIX SubjectPublicKeyInfo structure
+type subjectPublicKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	PublicKey asn1.BitString
+}
+
+// ParsePem parses a PEM encoded PKIX public key, including secp256k1 keys
+func ParsePem(publicKeyPem string) (crypto.PublicKey, error) {
+	block, _ := pem.Decode([]byte(publicKeyPem))
+
+	if block == nil {
+		return nil, fmt.Errorf("Public key is not PEM encoded")
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(block.Bytes)
+
+	if err == nil {
+		return publicKey, nil
+	}
+
+	secp256k1Key, secpErr := parseSecp256k1PKIX(block.Bytes)
+
+	if secpErr != nil || secp256k1Key == nil {
+		return nil, err
+	}
+
+	return secp256k1Key, nil
+}
+
+// parseSecp256k1PKIX parses a DER encoded PKIX secp256k1 public key, returning
+// nil without error when the key is of another algorithm
+func parseSecp256k1PKIX(der []byte) (*secp256k1.PublicKey, error) {
+	info := subjectPublicKeyInfo{}
+
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, nil
+	}
+
+	if !info.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, nil
+	}
+
+	curve := asn1.ObjectIdentifier{}
+
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &curve); err != nil || !curve.Equal(oidNamedCurveSecp256k1) {
+		return nil, nil
+	}
+
+	return secp256k1.ParsePubKey(info.PublicKey.RightAlign())
+}
+
+// PemFromPublicKey encodes a public key as a PEM encoded PKIX public key
+func PemFromPublicKey(publicKey crypto.PublicKey) (string, error) {
+	var der []byte
+	var err error
+
+	if key, ok := publicKey.(*secp256k1.PublicKey); ok {
+		curve, _ := asn1.Marshal(oidNamedCurveSecp256k1)
+		uncompressed := key.SerializeUncompressed()
+
+		der, err = asn1.Marshal(subjectPublicKeyInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: curve}},
+			PublicKey: asn1.BitString{Bytes: uncompressed, BitLength: 8 * len(uncompressed)},
+		})
+	} else {
+		der, err = x509.MarshalPKIXPublicKey(publicKey)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
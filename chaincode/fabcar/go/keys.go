@@ -0,0 +1,314 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secpecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+)
+
+// Verification method types with a canonical key representation other than PEM
+const (
+	ed25519VerificationKey2020        = "Ed25519VerificationKey2020"
+	jsonWebKey2020                    = "JsonWebKey2020"
+	ecdsaSecp256k1VerificationKey2019 = "EcdsaSecp256k1VerificationKey2019"
+)
+
+// Verification method types whose keys are stored as PEM
+const (
+	rsaVerificationKey2018            = "RsaVerificationKey2018"
+	ed25519VerificationKey2018        = "Ed25519VerificationKey2018"
+	ecdsaSecp256r1VerificationKey2019 = "EcdsaSecp256r1VerificationKey2019"
+)
+
+// VerificationMethod describes a verification method of a did document. Exactly
+// one of the public key members is set
+type VerificationMethod struct {
+	Id                 string           `json:"id"`
+	Type               string           `json:"type"`
+	Controller         string           `json:"controller"`
+	PublicKeyPem       string           `json:"publicKeyPem,omitempty" metadata:"publicKeyPem,optional"`
+	PublicKeyMultibase string           `json:"publicKeyMultibase,omitempty" metadata:"publicKeyMultibase,optional"`
+	PublicKeyJwk       *keyencoding.Jwk `json:"publicKeyJwk,omitempty" metadata:"publicKeyJwk,optional"`
+}
+
+// canonicalFormat returns the representation in which keys of a verification
+// method type are stored
+func canonicalFormat(methodType string) keyencoding.Format {
+	switch methodType {
+	case ed25519VerificationKey2020:
+		return keyencoding.FormatMultibase
+	case jsonWebKey2020, ecdsaSecp256k1VerificationKey2019:
+		return keyencoding.FormatJwk
+	default:
+		return keyencoding.FormatPem
+	}
+}
+
+// encodeCanonical encodes a public key in the canonical representation of the
+// verification method type, returning the PEM, multibase and JWK members of which
+// exactly one is set
+func encodeCanonical(methodType string, publicKey crypto.PublicKey) (string, string, *keyencoding.Jwk, error) {
+	switch canonicalFormat(methodType) {
+	case keyencoding.FormatMultibase:
+		multibaseKey, err := keyencoding.MultibaseFromPublicKey(publicKey)
+
+		return "", multibaseKey, nil, err
+	case keyencoding.FormatJwk:
+		jwk, err := keyencoding.JwkFromPublicKey(publicKey)
+
+		return "", "", jwk, err
+	default:
+		pemKey, err := keyencoding.PemFromPublicKey(publicKey)
+
+		return pemKey, "", nil, err
+	}
+}
+
+// checkKeyType returns an error unless publicKey uses the algorithm of the
+// verification method type. JsonWebKey2020 accepts any supported key
+func checkKeyType(methodType string, publicKey crypto.PublicKey) error {
+	matches := false
+
+	switch methodType {
+	case jsonWebKey2020:
+		matches = true
+	case rsaVerificationKey2018:
+		_, matches = publicKey.(*rsa.PublicKey)
+	case ed25519VerificationKey2018, ed25519VerificationKey2020:
+		_, matches = publicKey.(ed25519.PublicKey)
+	case ecdsaSecp256k1VerificationKey2019:
+		_, matches = publicKey.(*secp256k1.PublicKey)
+	case ecdsaSecp256r1VerificationKey2019:
+		ecdsaKey, ok := publicKey.(*ecdsa.PublicKey)
+		matches = ok && ecdsaKey.Curve == elliptic.P256()
+	default:
+		return fmt.Errorf("Unsupported verification method type %s", methodType)
+	}
+
+	if !matches {
+		return fmt.Errorf("Public key is not a %s key", methodType)
+	}
+
+	return nil
+}
+
+// parseKeyOfType parses key material and checks it against the verification
+// method type of the method with given id
+func parseKeyOfType(id string, methodType string, material string) (crypto.PublicKey, error) {
+	publicKey, err := keyencoding.Parse(material)
+
+	if err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to parse public key of %s. %s", id, err.Error())
+	}
+
+	if err := checkKeyType(methodType, publicKey); err != nil {
+		return nil, newError(codeInvalidArgument, "Public key of %s cannot be used with %s. %s", id, methodType, err.Error())
+	}
+
+	return publicKey, nil
+}
+
+// newVerificationMethod creates a verification method from key material given as
+// PEM, publicKeyMultibase or a JSON encoded JWK. The key is stored in the
+// canonical representation of the method type
+func newVerificationMethod(id string, methodType string, controller string, material string) (*VerificationMethod, error) {
+	publicKey, err := parseKeyOfType(id, methodType, material)
+
+	if err != nil {
+		return nil, err
+	}
+
+	method := VerificationMethod{Id: id, Type: methodType, Controller: controller}
+
+	method.PublicKeyPem, method.PublicKeyMultibase, method.PublicKeyJwk, err = encodeCanonical(methodType, publicKey)
+
+	if err != nil {
+		return nil, newError(codeInvalidArgument, "Public key of %s cannot be used with %s. %s", id, methodType, err.Error())
+	}
+
+	return &method, nil
+}
+
+// publicKey parses the public key of the verification method
+func (m *VerificationMethod) publicKey() (crypto.PublicKey, error) {
+	switch {
+	case m.PublicKeyJwk != nil:
+		return m.PublicKeyJwk.PublicKey()
+	case m.PublicKeyMultibase != "":
+		return keyencoding.ParseMultibase(m.PublicKeyMultibase)
+	case m.PublicKeyPem != "":
+		return keyencoding.ParsePem(m.PublicKeyPem)
+	default:
+		return nil, fmt.Errorf("Public key of %s is not available on the public ledger", m.Id)
+	}
+}
+
+// authenticationMethod returns the primary authentication key of the did as a
+// verification method
+func (d *Did) authenticationMethod() VerificationMethod {
+	return VerificationMethod{
+		Id:                 d.AuthenticationId,
+		Type:               d.AuthenticationType,
+		Controller:         d.AuthenticationController,
+		PublicKeyPem:       d.AuthenticationPublicKeyPerm,
+		PublicKeyMultibase: d.AuthenticationPublicKeyMultibase,
+		PublicKeyJwk:       d.AuthenticationPublicKeyJwk,
+	}
+}
+
+// verificationMethods returns the primary authentication key followed by the
+// additional verification methods of the did
+func (d *Did) verificationMethods() []VerificationMethod {
+	return append([]VerificationMethod{d.authenticationMethod()}, d.VerificationMethods...)
+}
+
+// publicKeyOf returns the public key of the verification method with given id
+// registered in the did
+func publicKeyOf(did *Did, methodId string) (crypto.PublicKey, error) {
+	for _, method := range did.verificationMethods() {
+		if method.Id != methodId {
+			continue
+		}
+
+		publicKey, err := method.publicKey()
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse public key of %s. %s", methodId, err.Error())
+		}
+
+		return publicKey, nil
+	}
+
+	return nil, fmt.Errorf("Verification method %s is not registered in %s", methodId, did.Id)
+}
+
+// normalizePublicKey converts key material passed in the authenticationPublicKeyPerm
+// argument into the canonical representation of the authentication type, so PEM,
+// publicKeyMultibase and JWK keys can all be passed the same way. Key material
+// that does not parse or does not match the authentication type is rejected
+func normalizePublicKey(did *Did) error {
+	material := did.AuthenticationPublicKeyPerm
+
+	if material == "" {
+		if did.AuthenticationPublicKeyMultibase == "" && did.AuthenticationPublicKeyJwk == nil && did.AuthenticationPublicKeyHash == "" {
+			return newError(codeInvalidArgument, "Public key of %s must be set", did.AuthenticationId)
+		}
+
+		return nil
+	}
+
+	publicKey, err := parseKeyOfType(did.AuthenticationId, did.AuthenticationType, material)
+
+	if err != nil {
+		return err
+	}
+
+	pemKey, multibaseKey, jwk, err := encodeCanonical(did.AuthenticationType, publicKey)
+
+	if err != nil {
+		return newError(codeInvalidArgument, "Public key of %s cannot be used with %s. %s", did.AuthenticationId, did.AuthenticationType, err.Error())
+	}
+
+	did.AuthenticationPublicKeyPerm = pemKey
+	did.AuthenticationPublicKeyMultibase = multibaseKey
+	did.AuthenticationPublicKeyJwk = jwk
+
+	return nil
+}
+
+// verifySignature verifies a signature over message made with the private key
+// matching publicKey. RSA signatures use PKCS #1 v1.5 and ECDSA signatures are
+// either ASN.1 encoded or in the R || S form used by JWS, all over a SHA-256 digest
+func verifySignature(publicKey crypto.PublicKey, message []byte, signature []byte) error {
+	digest := sha256.Sum256(message)
+
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+
+		if len(signature) == 2*size {
+			r := new(big.Int).SetBytes(signature[:size])
+			s := new(big.Int).SetBytes(signature[size:])
+
+			if !ecdsa.Verify(key, digest[:], r, s) {
+				return fmt.Errorf("ECDSA signature is invalid")
+			}
+
+			return nil
+		}
+
+		if !ecdsa.VerifyASN1(key, digest[:], signature) {
+			return fmt.Errorf("ECDSA signature is invalid")
+		}
+	case *secp256k1.PublicKey:
+		return verifySecp256k1Signature(key, digest[:], signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, message, signature) {
+			return fmt.Errorf("Ed25519 signature is invalid")
+		}
+	default:
+		return fmt.Errorf("Unsupported public key type %T", publicKey)
+	}
+
+	return nil
+}
+
+// verifySecp256k1Signature verifies an ECDSA signature over a digest. The
+// signature may be DER encoded or the 64 byte R || S form used by JWS
+func verifySecp256k1Signature(publicKey *secp256k1.PublicKey, digest []byte, signature []byte) error {
+	var parsed *secpecdsa.Signature
+
+	if len(signature) == 64 {
+		var r, s secp256k1.ModNScalar
+
+		if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:]) {
+			return fmt.Errorf("secp256k1 signature is invalid")
+		}
+
+		parsed = secpecdsa.NewSignature(&r, &s)
+	} else {
+		var err error
+
+		parsed, err = secpecdsa.ParseDERSignature(signature)
+
+		if err != nil {
+			return fmt.Errorf("secp256k1 signature is invalid. %s", err.Error())
+		}
+	}
+
+	if !parsed.Verify(digest, publicKey) {
+		return fmt.Errorf("secp256k1 signature is invalid")
+	}
+
+	return nil
+}
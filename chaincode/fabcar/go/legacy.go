@@ -0,0 +1,157 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// legacyDid is the flat structure dids were stored in by the first version of
+// the chaincode, under their plain key rather than in the namespace of the
+// organization that registered them
+type legacyDid struct {
+	Id                          string `json:"id"`
+	AuthenticationId            string `json:"authenticationId"`
+	AuthenticationType          string `json:"authenticationType"`
+	AuthenticationController    string `json:"authenticationController"`
+	AuthenticationPublicKeyPerm string `json:"authenticationPublicKeyPerm"`
+	ServiceId                   string `json:"serviceId"`
+	ServiceType                 string `json:"serviceType"`
+	ServiceEndPoint             string `json:"serviceEndPoint"`
+}
+
+// isLegacyDidKey reports whether a did may be stored with given key by the first
+// version of the chaincode. Composite keys were not used then
+func isLegacyDidKey(didNumber string) bool {
+	return didNumber != "" && !strings.HasPrefix(didNumber, "\x00")
+}
+
+// upconvertLegacyDid decodes a did stored in the legacy flat structure into the
+// current model. Records with members the legacy structure does not define are
+// reported corrupt
+func upconvertLegacyDid(didNumber string, data []byte) (*Did, error) {
+	legacy := legacyDid{}
+
+	if err := decodeStrict(data, &legacy); err != nil {
+		return nil, &CorruptRecordError{Key: printableKey(didNumber), Err: fmt.Errorf("Not a legacy did. %s", err.Error())}
+	}
+
+	return &Did{
+		Id:                          legacy.Id,
+		AuthenticationId:            legacy.AuthenticationId,
+		AuthenticationType:          legacy.AuthenticationType,
+		AuthenticationController:    legacy.AuthenticationController,
+		AuthenticationPublicKeyPerm: legacy.AuthenticationPublicKeyPerm,
+		ServiceId:                   legacy.ServiceId,
+		ServiceType:                 legacy.ServiceType,
+		ServiceEndPoint:             legacy.ServiceEndPoint,
+	}, nil
+}
+
+// getLegacyDid returns the did stored with given key by the first version of the
+// chaincode, up-converted to the current model, or nil if there is none
+func getLegacyDid(ctx contractapi.TransactionContextInterface, didNumber string) (*Did, error) {
+	if !isLegacyDidKey(didNumber) {
+		return nil, nil
+	}
+
+	didAsBytes, err := ctx.GetStub().GetState(didNumber)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if didAsBytes == nil {
+		return nil, nil
+	}
+
+	return upconvertLegacyDid(didNumber, didAsBytes)
+}
+
+// lookupLegacyDidById returns the key and record of the legacy did with given
+// id, or nil if there is none. Range queries return the records stored under
+// plain keys without the composite keys of the current records. Records that
+// are not legacy dids are skipped
+func lookupLegacyDidById(ctx contractapi.TransactionContextInterface, id string) (*QueryResult, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		did, err := upconvertLegacyDid(queryResponse.Key, queryResponse.Value)
+
+		if err != nil {
+			continue
+		}
+
+		if did.Id == id {
+			return newQueryResult(queryResponse.Key, did), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// retireLegacyDid deletes the legacy record of the did about to be written to
+// the given namespaced world state key, and records the namespace it moves to
+// and its id so that it is found as any other did from then on
+func retireLegacyDid(ctx contractapi.TransactionContextInterface, key string, didNumber string, did *Did) error {
+	namespace, err := getDidNamespace(ctx, didNumber)
+
+	if err != nil || namespace != nil {
+		return err
+	}
+
+	legacy, err := getLegacyDid(ctx, didNumber)
+
+	if err != nil || legacy == nil {
+		return err
+	}
+
+	_, keyParts, err := ctx.GetStub().SplitCompositeKey(key)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(didNumber)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	if err := putDidNamespace(ctx, didNumber, &DidNamespace{MSPID: keyParts[0]}); err != nil {
+		return err
+	}
+
+	return putIdIndexEntry(ctx, did.Id, didNumber)
+}
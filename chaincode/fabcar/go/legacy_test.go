@@ -0,0 +1,74 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// legacyDidJSON is a did as stored by the first version of the chaincode
+const legacyDidJSON = `{"id":"did:example:legacy","authenticationId":"did:example:legacy#keys-1","authenticationType":"RsaVerificationKey2018",` +
+	`"authenticationController":"did:example:legacy","authenticationPublicKeyPerm":"","serviceId":"did:example:legacy#vcs",` +
+	`"serviceType":"VerifiableCredentialService","serviceEndPoint":"https://example.com/vc/"}`
+
+func TestLegacyDids(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	l.state["DID0"] = []byte(legacyDidJSON)
+
+	did, err := s.QueryDidByKey(l.ctx, "DID0")
+	require.NoError(t, err, "should read dids stored under plain keys")
+	assert.Equal(t, &Did{Id: "did:example:legacy", AuthenticationId: "did:example:legacy#keys-1", AuthenticationType: "RsaVerificationKey2018",
+		AuthenticationController: "did:example:legacy", ServiceId: "did:example:legacy#vcs", ServiceType: "VerifiableCredentialService",
+		ServiceEndPoint: "https://example.com/vc/"}, did, "should up-convert the legacy structure")
+
+	result, err := findDidById(l.ctx, "did:example:legacy")
+	require.NoError(t, err, "should find legacy dids by id")
+	assert.Equal(t, "DID0", result.Key, "should return the plain key")
+
+	_, err = findDidById(l.ctx, "did:example:unknown")
+	assertErrorCode(t, err, codeDidNotFound, "should still fail for unknown ids")
+
+	err = s.createDid(l.ctx, "DID0", &Did{Id: "did:example:other"})
+	assertErrorCode(t, err, codeDidAlreadyExists, "should not create dids over legacy dids")
+
+	key, err := didKey(l.ctx, "DID0")
+	require.NoError(t, err)
+	require.NoError(t, putDid(l.ctx, key, "DID0", did))
+
+	assert.NotContains(t, l.state, "DID0", "should remove the legacy record once the did is written")
+	assert.Contains(t, l.state, testDidKey("DID0"), "should write the did to the namespace of the writer")
+
+	namespace, err := getDidNamespace(l.ctx, "DID0")
+	require.NoError(t, err)
+	assert.Equal(t, &DidNamespace{MSPID: testMSPID}, namespace, "should record the namespace of the did")
+
+	result, err = lookupDidById(l.ctx, "did:example:legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "DID0", result.Key, "should find the written did as any other")
+
+	l.state["DID1"] = []byte(`{"id":"did:example:legacy","controller":"x509::CN=user1"}`)
+
+	_, err = s.QueryDidByKey(l.ctx, "DID1")
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject plain records that are not legacy dids")
+}
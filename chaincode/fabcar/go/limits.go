@@ -0,0 +1,219 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// documentLimitsConfig names the configuration entry holding the limits of
+// the documents of the registry
+const documentLimitsConfig = "documentLimits"
+
+// Names of the document limits, as reported in the details of errors
+const (
+	limitMaxDocumentBytes       = "maxDocumentBytes"
+	limitMaxServices            = "maxServices"
+	limitMaxVerificationMethods = "maxVerificationMethods"
+	limitMaxEndpointLength      = "maxEndpointLength"
+)
+
+// defaultDocumentLimits apply until a registry administrator configures others
+var defaultDocumentLimits = DocumentLimits{
+	MaxDocumentBytes:       256 << 10,
+	MaxServices:            32,
+	MaxVerificationMethods: 128,
+	MaxEndpointLength:      2048,
+}
+
+// DocumentLimits bounds the documents of the registry. MaxDocumentBytes is the
+// largest encoded document before compression, MaxServices and
+// MaxVerificationMethods the number of entries a document may list and
+// MaxEndpointLength the length in bytes of a single service endpoint URI
+type DocumentLimits struct {
+	MaxDocumentBytes       int `json:"maxDocumentBytes"`
+	MaxServices            int `json:"maxServices"`
+	MaxVerificationMethods int `json:"maxVerificationMethods"`
+	MaxEndpointLength      int `json:"maxEndpointLength"`
+}
+
+func documentLimitsKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configObjectType, []string{documentLimitsConfig})
+}
+
+// getDocumentLimits returns the configured document limits, or the defaults if
+// none have been configured
+func getDocumentLimits(ctx contractapi.TransactionContextInterface) (*DocumentLimits, error) {
+	key, err := documentLimitsKey(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	limitsAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if limitsAsBytes == nil {
+		limits := defaultDocumentLimits
+		return &limits, nil
+	}
+
+	limits := new(DocumentLimits)
+
+	if err := unmarshalRecord(key, limitsAsBytes, limits); err != nil {
+		return nil, err
+	}
+
+	return limits, nil
+}
+
+// SetDocumentLimits replaces the document limits with the given JSON
+// DocumentLimits. Only registry administrators may call it. Every limit must be
+// set, documents already stored are only checked again when they are written
+func (a *AdminContract) SetDocumentLimits(ctx contractapi.TransactionContextInterface, limitsJSON string) error {
+	limits := DocumentLimits{}
+
+	if err := decodeStrict([]byte(limitsJSON), &limits); err != nil {
+		return newError(codeInvalidArgument, "Failed to decode document limits. %s", err.Error())
+	}
+
+	values := []struct {
+		name  string
+		value int
+	}{
+		{limitMaxDocumentBytes, limits.MaxDocumentBytes},
+		{limitMaxServices, limits.MaxServices},
+		{limitMaxVerificationMethods, limits.MaxVerificationMethods},
+		{limitMaxEndpointLength, limits.MaxEndpointLength},
+	}
+
+	for _, limit := range values {
+		if limit.value <= 0 {
+			return newError(codeInvalidArgument, "%s must be a positive number", limit.name)
+		}
+	}
+
+	key, err := documentLimitsKey(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	limitsAsBytes, err := marshalRecord(key, limits)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, limitsAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryDocumentLimits returns the limits of the documents of the registry
+func (s *DidContract) QueryDocumentLimits(ctx contractapi.TransactionContextInterface) (*DocumentLimits, error) {
+	return getDocumentLimits(ctx)
+}
+
+// newLimitError returns an error with given code naming the exceeded limit,
+// its maximum and the actual value in its details
+func newLimitError(code string, limit string, actual int, maximum int, format string, args ...interface{}) *ContractError {
+	err := newError(code, format, args...)
+	err.Details = map[string]string{"limit": limit, "actual": strconv.Itoa(actual), "maximum": strconv.Itoa(maximum)}
+
+	return err
+}
+
+// assertDocumentLimits returns an error if the document of id, encoded as
+// documentAsBytes and listing the given number of verification methods and
+// services, exceeds the document limits of the registry
+func assertDocumentLimits(ctx contractapi.TransactionContextInterface, id string, documentAsBytes []byte, methods int, services int) error {
+	limits, err := getDocumentLimits(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if len(documentAsBytes) > limits.MaxDocumentBytes {
+		return newLimitError(codeInvalidArgument, limitMaxDocumentBytes, len(documentAsBytes), limits.MaxDocumentBytes, "%s is %d bytes, at most %d are allowed", id, len(documentAsBytes), limits.MaxDocumentBytes)
+	}
+
+	if methods > limits.MaxVerificationMethods {
+		return newLimitError(codeInvalidArgument, limitMaxVerificationMethods, methods, limits.MaxVerificationMethods, "%s has %d verification methods, at most %d are allowed", id, methods, limits.MaxVerificationMethods)
+	}
+
+	if services > limits.MaxServices {
+		return newLimitError(codeInvalidArgument, limitMaxServices, services, limits.MaxServices, "%s has %d services, at most %d are allowed", id, services, limits.MaxServices)
+	}
+
+	return nil
+}
+
+// methodCount returns the number of verification methods of did counted
+// against limits, including its recovery methods
+func methodCount(did *Did) int {
+	return len(did.verificationMethods()) + len(did.RecoveryMethods)
+}
+
+// serviceCount returns the number of services of did counted against limits,
+// counting every endpoint object of a DIDComm service as a service
+func serviceCount(did *Did) int {
+	if len(did.ServiceEndpoints) > 0 {
+		return len(did.ServiceEndpoints)
+	}
+
+	if did.ServiceId != "" {
+		return 1
+	}
+
+	return 0
+}
+
+// assertDidLimits returns an error if did, encoded as didAsBytes, exceeds the
+// document limits of the registry
+func assertDidLimits(ctx contractapi.TransactionContextInterface, didNumber string, did *Did, didAsBytes []byte) error {
+	return assertDocumentLimits(ctx, didNumber, didAsBytes, methodCount(did), serviceCount(did))
+}
+
+// assertEndpointLength returns an error if endpoint is longer than the
+// document limits of the registry allow
+func assertEndpointLength(ctx contractapi.TransactionContextInterface, endpoint string) error {
+	limits, err := getDocumentLimits(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if len(endpoint) > limits.MaxEndpointLength {
+		return newLimitError(codeInvalidArgument, limitMaxEndpointLength, len(endpoint), limits.MaxEndpointLength, "Service endpoint is %d bytes, at most %d are allowed", len(endpoint), limits.MaxEndpointLength)
+	}
+
+	return nil
+}
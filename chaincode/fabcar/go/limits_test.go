@@ -0,0 +1,117 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertLimitError asserts that err is a ContractError with given code
+// reporting the exceeded limit and its values in its details
+func assertLimitError(t *testing.T, err error, code string, limit string, actual string, maximum string, message string) {
+	assertErrorCode(t, err, code, message)
+
+	if contractErr, ok := err.(*ContractError); ok {
+		assert.Equal(t, map[string]string{"limit": limit, "actual": actual, "maximum": maximum}, contractErr.Details, message)
+	}
+}
+
+func TestSetDocumentLimits(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+
+	limits, err := s.QueryDocumentLimits(l.ctx)
+	require.NoError(t, err, "should return the default limits")
+	assert.Equal(t, defaultDocumentLimits, *limits)
+
+	err = l.before(a, "SetDocumentLimits")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators set the limits")
+
+	l.setAdmin(true)
+	require.NoError(t, a.SetDocumentLimits(l.ctx, `{"maxDocumentBytes":4096,"maxServices":2,"maxVerificationMethods":3,"maxEndpointLength":100}`), "should set the limits")
+
+	limits, err = s.QueryDocumentLimits(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, DocumentLimits{MaxDocumentBytes: 4096, MaxServices: 2, MaxVerificationMethods: 3, MaxEndpointLength: 100}, *limits, "should return the configured limits")
+
+	err = a.SetDocumentLimits(l.ctx, `{"maxDocumentBytes":4096,"maxServices":2,"maxVerificationMethods":3}`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require every limit")
+
+	err = a.SetDocumentLimits(l.ctx, `{"maxDocumentBytes":4096,"maxServices":2,"maxVerificationMethods":3,"maxEndpointLength":100,"maxKeys":1}`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject unknown limits")
+
+	err = a.SetDocumentLimits(l.ctx, `{"maxDocumentBytes":-1,"maxServices":2,"maxVerificationMethods":3,"maxEndpointLength":100}`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require positive limits")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.QueryDocumentLimits(l.ctx)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestAssertDocumentLimits(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	l.setAdmin(true)
+	require.NoError(t, a.SetDocumentLimits(l.ctx, `{"maxDocumentBytes":2048,"maxServices":1,"maxVerificationMethods":1,"maxEndpointLength":40}`))
+
+	did, err := getDid(l.ctx, id)
+	require.NoError(t, err)
+
+	storageKey, err := didKey(l.ctx, id)
+	require.NoError(t, err)
+	require.NoError(t, putDid(l.ctx, storageKey, id, did), "should write dids within the limits")
+
+	methods := *did
+	methods.VerificationMethods = []VerificationMethod{VerificationMethod{Id: id + "#keys-2", Type: testKeyType, Controller: id, PublicKeyPem: key.pem}}
+	err = putDid(l.ctx, storageKey, id, &methods)
+	assertLimitError(t, err, codeInvalidArgument, limitMaxVerificationMethods, "2", "1", "should count the verification methods of the document")
+
+	recovery := *did
+	recovery.RecoveryMethods = methods.VerificationMethods
+	err = putDid(l.ctx, storageKey, id, &recovery)
+	assertLimitError(t, err, codeInvalidArgument, limitMaxVerificationMethods, "2", "1", "should count recovery methods as verification methods")
+
+	services := *did
+	services.ServiceEndPoint = ""
+	services.ServiceEndpoints = []DidCommEndpoint{DidCommEndpoint{Uri: testEndpoint}, DidCommEndpoint{Uri: "didcomm:transport/queue"}}
+	err = putDid(l.ctx, storageKey, id, &services)
+	assertLimitError(t, err, codeInvalidArgument, limitMaxServices, "2", "1", "should count every endpoint object as a service")
+
+	large := *did
+	large.Expires = strings.Repeat("x", 2048)
+	err = putDid(l.ctx, storageKey, id, &large)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject documents above the size limit")
+	assert.Equal(t, limitMaxDocumentBytes, err.(*ContractError).Details["limit"], "should name the size limit")
+
+	assert.Nil(t, validateServiceEndpoint(l.ctx, "https://example.com/"+strings.Repeat("x", 20)), "should accept endpoints within the length limit")
+	err = validateServiceEndpoint(l.ctx, "https://example.com/"+strings.Repeat("x", 21))
+	assertLimitError(t, err, codeInvalidArgument, limitMaxEndpointLength, "41", "40", "should reject endpoints above the length limit")
+
+	_, err = new(DidContract).CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", "https://example.com/"+strings.Repeat("x", 21), "")
+	assertErrorCode(t, err, codeInvalidArgument, "should check the endpoints of new dids")
+}
@@ -0,0 +1,279 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+// apacheLicense is the license under which the contracts are published
+var apacheLicense = &metadata.LicenseMetadata{
+	Name: "Apache-2.0",
+	URL:  "http://www.apache.org/licenses/LICENSE-2.0",
+}
+
+// Names of the contracts, which prefix their transaction functions as in
+// did:CreateDid. The did contract is the default contract of the chaincode, so
+// its functions may also be called without prefix
+const (
+	didContractName        = "did"
+	credentialContractName = "credential"
+	adminContractName      = "admin"
+	policyContractName     = "policy"
+	consentContractName    = "consent"
+	sharingContractName    = "sharing"
+)
+
+// didContractInfo documents the did registry contract in the chaincode metadata.
+// The metadata of contractapi v1.0.0 has no transaction descriptions, the doc
+// comments of the transaction functions remain their reference
+var didContractInfo = metadata.InfoMetadata{
+	Title:       "DID registry",
+	Description: "Creates, updates, resolves and deactivates decentralized identifiers and their did documents",
+	Version:     chaincodeVersion,
+	License:     apacheLicense,
+}
+
+// credentialContractInfo documents the credential contract in the chaincode metadata
+var credentialContractInfo = metadata.InfoMetadata{
+	Title:       "Verifiable credential registry",
+	Description: "Records issuer accreditations, credential issuances and status lists, and verifies credentials and presentations against the did registry",
+	Version:     chaincodeVersion,
+	License:     apacheLicense,
+}
+
+// adminContractInfo documents the admin contract in the chaincode metadata
+var adminContractInfo = metadata.InfoMetadata{
+	Title:       "DID registry administration",
+	Description: "Seeds the registry and configures the service endpoint schemes. Every function requires the admin attribute",
+	Version:     chaincodeVersion,
+	License:     apacheLicense,
+}
+
+// policyContractInfo documents the policy contract in the chaincode metadata
+var policyContractInfo = metadata.InfoMetadata{
+	Title:       "DID registry policies",
+	Description: "Stores the fee, allowed organizations and maximum document size applied to each transaction function of the did contract. Changes require the admin attribute",
+	Version:     chaincodeVersion,
+	License:     apacheLicense,
+}
+
+// consentContractInfo documents the consent contract in the chaincode metadata
+var consentContractInfo = metadata.InfoMetadata{
+	Title:       "Data processing consent",
+	Description: "Records the grants and revocations of consent by subject dids to the processing of their data by controller dids",
+	Version:     chaincodeVersion,
+	License:     apacheLicense,
+}
+
+// sharingContractInfo documents the sharing contract in the chaincode metadata
+var sharingContractInfo = metadata.InfoMetadata{
+	Title:       "Private data sharing agreements",
+	Description: "Records the bilateral agreements between organizations on the private did fields one shares with the other, which private data reads check",
+	Version:     chaincodeVersion,
+	License:     apacheLicense,
+}
+
+// GetName returns the namespace of the did contract
+func (s *DidContract) GetName() string {
+	return didContractName
+}
+
+// GetName returns the namespace of the credential contract
+func (c *CredentialContract) GetName() string {
+	return credentialContractName
+}
+
+// GetName returns the namespace of the admin contract
+func (a *AdminContract) GetName() string {
+	return adminContractName
+}
+
+// GetName returns the namespace of the policy contract
+func (p *PolicyContract) GetName() string {
+	return policyContractName
+}
+
+// GetName returns the namespace of the consent contract
+func (c *ConsentContract) GetName() string {
+	return consentContractName
+}
+
+// GetName returns the namespace of the sharing contract
+func (c *SharingContract) GetName() string {
+	return sharingContractName
+}
+
+// GetEvaluateTransactions returns the functions of the contract that only read
+// the world state. They are tagged as evaluate in the chaincode metadata so that
+// clients query them rather than submit them for ordering
+func (s *DidContract) GetEvaluateTransactions() []string {
+	return []string{
+		"QueryDidByKey",
+		"QueryDidById",
+		"QueryDidHash",
+		"QueryAllDids",
+		"QueryAllDidsWithPagination",
+		"QueryAllDidsKeyedById",
+		"QueryDidsByIdPrefix",
+		"QueryAllDidsSorted",
+		"QueryDidNamespace",
+		"QueryDidsByNamespace",
+		"QueryDidsCreatedBetween",
+		"QueryDidsUpdatedBetween",
+		"SearchDids",
+		"QueryDidHistory",
+		"DiffDidVersions",
+		"QueryDidPrivate",
+		"QueryDidPersonalData",
+		"QueryDidProvenance",
+		"GetInclusionProof",
+		"GetAnchorBatch",
+		"QueryAuditLog",
+		"QueryAuditLogByOrg",
+		"QueryPrivateServiceEndpoint",
+		"VerifyServiceEndpoint",
+		"VerifyDomainLinkage",
+		"QueryTransfer",
+		"QueryDelegations",
+		"QueryDidUpdateProposal",
+		"QueryEndpointSchemes",
+		"QueryRegistrationQuotas",
+		"QueryRegistrationFee",
+		"QueryOffChainThreshold",
+		"QueryDocumentLimits",
+		"QueryDidCaps",
+		"GetDidForIdentity",
+		"QueryCertificateAttributes",
+		"GetDidEndorsementPolicy",
+		"Resolve",
+		"ResolveVersion",
+		"ResolveRemote",
+		"Dereference",
+		"ExportAllDids",
+		"GetRegistryStats",
+		"GetContractInfo",
+	}
+}
+
+// GetEvaluateTransactions returns the functions of the contract that only read
+// the world state
+func (c *CredentialContract) GetEvaluateTransactions() []string {
+	return []string{
+		"QueryAccreditation",
+		"IsAccredited",
+		"QueryCredential",
+		"QueryCredentialsByIssuer",
+		"QueryCredentialsBySubject",
+		"VerifyCredential",
+		"VerifyPresentation",
+		"IsRevoked",
+		"GetStatusList",
+		"GetCredentialDefinition",
+		"GetRevocationRegistry",
+		"GetRevocationState",
+	}
+}
+
+// GetEvaluateTransactions returns the functions of the contract that only read
+// the world state
+func (p *PolicyContract) GetEvaluateTransactions() []string {
+	return []string{
+		"QueryOperationPolicy",
+		"QueryOperationPolicies",
+	}
+}
+
+// GetEvaluateTransactions returns the functions of the contract that only read
+// the world state
+func (c *ConsentContract) GetEvaluateTransactions() []string {
+	return []string{
+		"QueryConsent",
+		"QueryConsentsBySubject",
+		"QueryConsentsByController",
+		"HasConsent",
+	}
+}
+
+// GetEvaluateTransactions returns the functions of the contract that only read
+// the world state
+func (c *SharingContract) GetEvaluateTransactions() []string {
+	return []string{
+		"QuerySharingAgreement",
+		"QuerySharingAgreementsByOwner",
+	}
+}
+
+// isEvaluateTransaction reports whether function of contract only reads the world state
+func isEvaluateTransaction(contract contractapi.ContractInterface, function string) bool {
+	evaluation, ok := contract.(contractapi.EvaluationContractInterface)
+
+	if !ok {
+		return false
+	}
+
+	for _, evaluate := range evaluation.GetEvaluateTransactions() {
+		if evaluate == function {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newChaincode returns the chaincode made of the did, credential, admin, policy,
+// consent and sharing contracts, with their info populated for the generated metadata and results
+// returned by documentSerializer
+func newChaincode() (*contractapi.ContractChaincode, error) {
+	didContract := new(DidContract)
+	didContract.Info = didContractInfo
+	didContract.AdminAttribute = adminAttributeFromEnv()
+
+	credentialContract := new(CredentialContract)
+	credentialContract.Info = credentialContractInfo
+	credentialContract.AdminAttribute = adminAttributeFromEnv()
+
+	adminContract := new(AdminContract)
+	adminContract.Info = adminContractInfo
+	adminContract.AdminAttribute = adminAttributeFromEnv()
+
+	policyContract := new(PolicyContract)
+	policyContract.Info = policyContractInfo
+	policyContract.AdminAttribute = adminAttributeFromEnv()
+
+	consentContract := new(ConsentContract)
+	consentContract.Info = consentContractInfo
+	consentContract.AdminAttribute = adminAttributeFromEnv()
+
+	sharingContract := new(SharingContract)
+	sharingContract.Info = sharingContractInfo
+	sharingContract.AdminAttribute = adminAttributeFromEnv()
+
+	chaincode, err := contractapi.NewChaincode(didContract, credentialContract, adminContract, policyContract, consentContract, sharingContract)
+
+	if err != nil {
+		return nil, err
+	}
+
+	chaincode.TransactionSerializer = new(documentSerializer)
+
+	return chaincode, nil
+}
@@ -0,0 +1,118 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ================================
+// HELPERS
+// ================================
+
+// chaincodeMetadata returns the metadata generated for the chaincode as
+// returned to clients by the system contract
+func chaincodeMetadata(t *testing.T) metadata.ContractChaincodeMetadata {
+	stub := newCodedStub(t)
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("org.hyperledger.fabric:GetMetadata")})
+	require.Equal(t, int32(shim.OK), response.Status, "should return the metadata")
+
+	ccm := metadata.ContractChaincodeMetadata{}
+	require.NoError(t, json.Unmarshal(response.Payload, &ccm), "should return metadata as JSON")
+
+	return ccm
+}
+
+// transactionTags returns the tags of each transaction of a contract by name
+func transactionTags(t *testing.T, ccm metadata.ContractChaincodeMetadata, contract string) map[string][]string {
+	contractMetadata, ok := ccm.Contracts[contract]
+	require.True(t, ok, "should contain contract %s", contract)
+
+	tags := make(map[string][]string)
+
+	for _, transaction := range contractMetadata.Transactions {
+		tags[transaction.Name] = transaction.Tag
+	}
+
+	return tags
+}
+
+// ================================
+// TESTS
+// ================================
+
+func TestContractInfo(t *testing.T) {
+	ccm := chaincodeMetadata(t)
+
+	assert.Equal(t, didContractInfo.Title, ccm.Contracts[didContractName].Info.Title, "should document the did contract")
+	assert.Equal(t, didContractInfo.Description, ccm.Contracts[didContractName].Info.Description, "should document the did contract")
+	assert.Equal(t, "Apache-2.0", ccm.Contracts[didContractName].Info.License.Name, "should document the license")
+	assert.Equal(t, credentialContractInfo.Title, ccm.Contracts[credentialContractName].Info.Title, "should document the credential contract")
+	assert.Equal(t, credentialContractInfo.Description, ccm.Contracts[credentialContractName].Info.Description, "should document the credential contract")
+	assert.Equal(t, adminContractInfo.Title, ccm.Contracts[adminContractName].Info.Title, "should document the admin contract")
+	assert.Equal(t, policyContractInfo.Title, ccm.Contracts[policyContractName].Info.Title, "should document the policy contract")
+	assert.Equal(t, consentContractInfo.Title, ccm.Contracts[consentContractName].Info.Title, "should document the consent contract")
+	assert.Equal(t, sharingContractInfo.Title, ccm.Contracts[sharingContractName].Info.Title, "should document the sharing contract")
+}
+
+func TestGetEvaluateTransactions(t *testing.T) {
+	ccm := chaincodeMetadata(t)
+
+	contracts := map[string][]string{
+		didContractName:        new(DidContract).GetEvaluateTransactions(),
+		credentialContractName: new(CredentialContract).GetEvaluateTransactions(),
+		adminContractName:      []string{},
+		policyContractName:     new(PolicyContract).GetEvaluateTransactions(),
+		consentContractName:    new(ConsentContract).GetEvaluateTransactions(),
+		sharingContractName:    new(SharingContract).GetEvaluateTransactions(),
+	}
+
+	for contract, evaluate := range contracts {
+		tags := transactionTags(t, ccm, contract)
+
+		assert.NotContains(t, tags, "GetEvaluateTransactions", "should not expose GetEvaluateTransactions as a transaction")
+
+		evaluateSet := make(map[string]bool)
+
+		for _, name := range evaluate {
+			require.Contains(t, tags, name, "should list evaluate transactions that exist")
+			assert.Equal(t, []string{"evaluate"}, tags[name], "should tag %s as evaluate", name)
+
+			evaluateSet[name] = true
+		}
+
+		for name, tag := range tags {
+			if !evaluateSet[name] {
+				assert.Equal(t, []string{"submit"}, tag, "should tag %s as submit", name)
+			}
+		}
+	}
+
+	tags := transactionTags(t, ccm, didContractName)
+	assert.Equal(t, []string{"submit"}, tags["CreateAuthChallenge"], "should submit challenges as they are recorded")
+	assert.Equal(t, []string{"submit"}, tags["VerifyAuthResponse"], "should submit responses as they consume the challenge")
+}
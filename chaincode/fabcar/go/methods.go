@@ -0,0 +1,83 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AddVerificationMethod adds a verification method to a did. The public key may
+// be given as PEM, publicKeyMultibase or a JSON encoded JWK, so a document can mix
+// key representations. The signature must prove possession of the current
+// authentication key over the update payload of the extended document
+func (s *DidContract) AddVerificationMethod(ctx contractapi.TransactionContextInterface, didNumber string, methodId string, methodType string,
+	controller string, publicKey string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if hasMethod(did, methodId) {
+		return newError(codeAlreadyExists, "Verification method %s already exists in %s", methodId, didNumber)
+	}
+
+	method, err := newVerificationMethod(methodId, methodType, controller, publicKey)
+
+	if err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.VerificationMethods = append(append([]VerificationMethod{}, did.VerificationMethods...), *method)
+
+	if err := assertDidCap(ctx, didNumber, &update); err != nil {
+		return err
+	}
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
+
+// RemoveVerificationMethod removes an additional verification method from a did.
+// The primary authentication key can only be replaced with RotateKey
+func (s *DidContract) RemoveVerificationMethod(ctx contractapi.TransactionContextInterface, didNumber string, methodId string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	methods := []VerificationMethod{}
+
+	for _, method := range did.VerificationMethods {
+		if method.Id != methodId {
+			methods = append(methods, method)
+		}
+	}
+
+	if len(methods) == len(did.VerificationMethods) {
+		return newError(codeNotFound, "Verification method %s is not an additional verification method of %s", methodId, didNumber)
+	}
+
+	update := updatableDetails(did)
+	update.VerificationMethods = methods
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
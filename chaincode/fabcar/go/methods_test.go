@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signAddMethod returns the proof of possession of key for adding a
+// verification method to the stored did
+func signAddMethod(t *testing.T, l *testLedger, key *testKey, didNumber string, methodId string, methodType string, controller string, material string) string {
+	did, err := new(DidContract).QueryDidByKey(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	method, err := newVerificationMethod(methodId, methodType, controller, material)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.VerificationMethods = append(append([]VerificationMethod{}, did.VerificationMethods...), *method)
+
+	return signUpdate(t, l, key, didNumber, &update)
+}
+
+func TestAddVerificationMethod(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	material, err := keyencoding.PemFromPublicKey(public)
+	require.NoError(t, err)
+
+	signature := signAddMethod(t, l, key, id, id+"#keys-2", ed25519VerificationKey2020, id, material)
+
+	err = s.AddVerificationMethod(l.ctx, id, id+"#keys-2", ed25519VerificationKey2020, id, material, "")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature")
+
+	err = s.AddVerificationMethod(l.ctx, id, id+"#keys-2", ed25519VerificationKey2020, id, material, signature)
+	require.NoError(t, err, "should add the verification method")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	require.Len(t, did.VerificationMethods, 1, "should store the verification method")
+	assert.Equal(t, "", did.VerificationMethods[0].PublicKeyPem, "should not keep the key as PEM")
+	assert.NotEqual(t, "", did.VerificationMethods[0].PublicKeyMultibase, "should store the key in the canonical format of its type")
+
+	err = s.AddVerificationMethod(l.ctx, id, id+"#keys-2", ed25519VerificationKey2020, id, material, signature)
+	assertErrorCode(t, err, codeAlreadyExists, "should not add a method id twice")
+
+	err = s.AddVerificationMethod(l.ctx, id, id+"#keys-1", testKeyType, id, key.pem, signature)
+	assertErrorCode(t, err, codeAlreadyExists, "should not reuse the authentication key id")
+
+	err = s.AddVerificationMethod(l.ctx, id, id+"#keys-3", testKeyType, id, material, signature)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject keys of another type")
+
+	err = s.AddVerificationMethod(l.ctx, id, id+"#keys-3", "UnknownKey2099", id, material, signature)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject unknown types")
+
+	other := newTestKey(t).pem
+	err = s.AddVerificationMethod(l.ctx, id, "keys-3", testKeyType, id, other, signAddMethod(t, l, key, id, "keys-3", testKeyType, id, other))
+	assertErrorCode(t, err, codeInvalidArgument, "should require method ids to be did urls")
+
+	err = s.AddVerificationMethod(l.ctx, "DID9", id+"#keys-3", testKeyType, id, other, signature)
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+}
+
+func TestRemoveVerificationMethod(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	other := newTestKey(t).pem
+	require.NoError(t, s.AddVerificationMethod(l.ctx, id, id+"#keys-2", testKeyType, id, other, signAddMethod(t, l, key, id, id+"#keys-2", testKeyType, id, other)))
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.VerificationMethods = []VerificationMethod{}
+
+	err = s.RemoveVerificationMethod(l.ctx, id, id+"#keys-1", signUpdate(t, l, key, id, &update))
+	assertErrorCode(t, err, codeNotFound, "should not remove the authentication key")
+
+	err = s.RemoveVerificationMethod(l.ctx, id, id+"#keys-2", signUpdate(t, l, key, id, &update))
+	require.NoError(t, err, "should remove the verification method")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, did.VerificationMethods, "should remove the method from the document")
+
+	err = s.RemoveVerificationMethod(l.ctx, id, id+"#keys-2", "")
+	assertErrorCode(t, err, codeNotFound, "should fail for methods the did does not have")
+}
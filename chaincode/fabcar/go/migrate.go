@@ -0,0 +1,119 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// didSchemaVersion is the version of the format dids are stored in. Dids stored
+// before the format was versioned are of version 1
+const didSchemaVersion = 2
+
+// didMigrations are the migrations of stored dids, indexed by the schema
+// version they convert from, see recordSchema
+var didMigrations = map[int]recordMigration{
+	// Version 2 only adds the schema version, set when the did is written
+	1: func(record map[string]json.RawMessage) error { return nil },
+}
+
+// MigrateData rewrites every did stored in the given schema version in the
+// current one and returns their keys. Dids are also migrated when they are read
+// and written again by any transaction, so it is only needed to bring the whole
+// world state, and the queries run on it by CouchDB, to the current format after
+// a chaincode upgrade. At most maxQueryRecords dids are migrated per call, so
+// administrators call it again until it returns no keys. Only registry
+// administrators may call it
+func (a *AdminContract) MigrateData(ctx contractapi.TransactionContextInterface, fromSchemaVersion int) ([]string, error) {
+	if fromSchemaVersion < 1 || fromSchemaVersion >= didSchemaVersion {
+		return nil, newError(codeInvalidArgument, "Schema version %d is not a version before the current version %d", fromSchemaVersion, didSchemaVersion)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(didObjectType, []string{})
+
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := []string{}
+
+	for len(migrated) < maxQueryRecords && resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		didNumber, err := didNumberOfKey(ctx, queryResponse.Key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		stored, err := decompressRecord(didNumber, queryResponse.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		_, version, err := migrateRecord(didNumber, stored, recordSchemas[reflect.TypeOf(Did{})])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if version != fromSchemaVersion {
+			continue
+		}
+
+		did := new(Did)
+
+		if err := unmarshalRecord(didNumber, queryResponse.Value, did); err != nil {
+			return nil, err
+		}
+
+		didAsBytes, err := marshalRecord(didNumber, did)
+
+		if err != nil {
+			return nil, err
+		}
+
+		didAsBytes, err = compressRecord(didNumber, didAsBytes)
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = ctx.GetStub().PutState(queryResponse.Key, didAsBytes)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+		}
+
+		migrated = append(migrated, didNumber)
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,141 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeDidInSchemaVersion rewrites the stored did with given key as written by
+// a chaincode of the given schema version, 1 leaving out the schemaVersion
+func storeDidInSchemaVersion(t *testing.T, l *testLedger, didNumber string, version int) {
+	record := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(l.state[testDidKey(didNumber)], &record))
+
+	delete(record, "schemaVersion")
+
+	if version > 1 {
+		record["schemaVersion"] = json.RawMessage(strconv.Itoa(version))
+	}
+
+	recordAsBytes, err := json.Marshal(record)
+	require.NoError(t, err)
+
+	l.state[testDidKey(didNumber)] = recordAsBytes
+}
+
+// schemaVersionOf returns the schema version a record is stored in, 0 if it has none
+func schemaVersionOf(t *testing.T, recordAsBytes []byte) int {
+	record := struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}{}
+	require.NoError(t, json.Unmarshal(recordAsBytes, &record))
+
+	return record.SchemaVersion
+}
+
+func TestRecordSchemaVersion(t *testing.T) {
+	namespace := DidNamespace{MSPID: testMSPID}
+
+	namespaceAsBytes, err := marshalRecord("DID1", &namespace)
+	require.NoError(t, err)
+	assert.Equal(t, 1, schemaVersionOf(t, namespaceAsBytes), "should store records of unchanged types in version 1")
+
+	decoded := DidNamespace{}
+	require.NoError(t, unmarshalRecord("DID1", namespaceAsBytes, &decoded), "should read versioned records")
+	assert.Equal(t, namespace, decoded)
+
+	decoded = DidNamespace{}
+	require.NoError(t, unmarshalRecord("DID1", []byte(`{"mspId":"`+testMSPID+`"}`), &decoded), "should read records stored before versioning")
+	assert.Equal(t, namespace, decoded)
+
+	err = unmarshalRecord("DID1", []byte(`{"mspId":"`+testMSPID+`","schemaVersion":2}`), &decoded)
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject records of newer schema versions")
+
+	err = unmarshalRecord("DID1", []byte(`{"mspId":"`+testMSPID+`"} {}`), &decoded)
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject trailing data")
+
+	listAsBytes, err := marshalRecord("list", []string{"a"})
+	require.NoError(t, err)
+	assert.Equal(t, `["a"]`, string(listAsBytes), "should only version structs")
+}
+
+func TestMigrateDidRecord(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, didSchemaVersion, schemaVersionOf(t, l.state[testDidKey(id)]), "should store new dids in the current schema version")
+
+	storeDidInSchemaVersion(t, l, id, 1)
+
+	legacy, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err, "should read dids stored before versioning")
+	assert.Equal(t, did, legacy, "should migrate dids when they are read")
+
+	storeDidInSchemaVersion(t, l, id, didSchemaVersion+1)
+
+	_, err = s.QueryDidByKey(l.ctx, id)
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject dids of newer schema versions")
+
+	l.state[testDidKey(id)] = []byte(`{"id":"` + id + `","schemaVersion":"2"}`)
+
+	_, err = s.QueryDidByKey(l.ctx, id)
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject invalid schema versions")
+}
+
+func TestMigrateData(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	legacy := createTestDid(t, l, newTestKey(t))
+	current := createTestDid(t, l, newTestKey(t))
+	storeDidInSchemaVersion(t, l, legacy, 1)
+
+	err := l.before(a, "MigrateData")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators migrate data")
+
+	l.setAdmin(true)
+
+	_, err = a.MigrateData(l.ctx, didSchemaVersion)
+	assertErrorCode(t, err, codeInvalidArgument, "should only migrate from earlier schema versions")
+
+	_, err = a.MigrateData(l.ctx, 0)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject versions before the first")
+
+	currentAsBytes := l.state[testDidKey(current)]
+
+	migrated, err := a.MigrateData(l.ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{legacy}, migrated, "should migrate the dids of the given version")
+	assert.Equal(t, currentAsBytes, l.state[testDidKey(current)], "should leave dids of other versions")
+
+	assert.Equal(t, didSchemaVersion, schemaVersionOf(t, l.state[testDidKey(legacy)]), "should store the current schema version")
+
+	migrated, err = a.MigrateData(l.ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, migrated, "should find nothing left to migrate")
+}
@@ -0,0 +1,184 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// MultisigPolicy names the controller dids of a did and how many of them must
+// sign sensitive changes to it, see isSensitiveUpdate
+type MultisigPolicy struct {
+	Controllers []string `json:"controllers"`
+	Threshold   int      `json:"threshold"`
+}
+
+// ControllerSignature is the signature of one controller did over the update
+// payload of a change to a did with a multisig policy
+type ControllerSignature struct {
+	Controller string `json:"controller"`
+	Signature  string `json:"signature"`
+}
+
+// sensitiveDetails returns the details of the did whose change requires the
+// approval of the controllers of a multisig policy
+func sensitiveDetails(did *Did) Did {
+	return Did{
+		AuthenticationId:                 did.AuthenticationId,
+		AuthenticationType:               did.AuthenticationType,
+		AuthenticationPublicKeyPerm:      did.AuthenticationPublicKeyPerm,
+		AuthenticationPublicKeyMultibase: did.AuthenticationPublicKeyMultibase,
+		AuthenticationPublicKeyJwk:       did.AuthenticationPublicKeyJwk,
+		VerificationMethods:              did.VerificationMethods,
+		RecoveryMethods:                  did.RecoveryMethods,
+		Deactivated:                      did.Deactivated,
+		Multisig:                         did.Multisig,
+	}
+}
+
+// isSensitiveUpdate reports whether update rotates or adds keys of the did,
+// deactivates it or changes its multisig policy. Keys are compared in their
+// canonical representation, so passing the current key in another format is
+// not a change
+func isSensitiveUpdate(did *Did, update *Did) bool {
+	normalized := *update
+
+	if normalized.AuthenticationPublicKeyPerm != "" {
+		normalized.AuthenticationPublicKeyMultibase = ""
+		normalized.AuthenticationPublicKeyJwk = nil
+
+		if normalizePublicKey(&normalized) != nil {
+			return true
+		}
+	}
+
+	current, err := json.Marshal(sensitiveDetails(did))
+
+	if err != nil {
+		return true
+	}
+
+	updated, err := json.Marshal(sensitiveDetails(&normalized))
+
+	return err != nil || string(current) != string(updated)
+}
+
+// verifyUpdateApproval checks the signature of an update of the did. Sensitive
+// updates of dids with a multisig policy must be signed by the threshold of its
+// controllers, given as a JSON array of ControllerSignature, all other updates
+// with the current authentication key, see verifyKeyPossession
+func verifyUpdateApproval(ctx contractapi.TransactionContextInterface, didNumber string, did *Did, update *Did, signature string) error {
+	if did.Multisig == nil || !isSensitiveUpdate(did, update) {
+		return verifyKeyPossession(ctx, didNumber, did, update, signature)
+	}
+
+	signatures := []ControllerSignature{}
+
+	if err := decodeStrict([]byte(signature), &signatures); err != nil {
+		return newError(codeInvalidSignature, "Changes to the keys of %s must be signed by %d of its controllers as a JSON array of controller signatures. %s",
+			didNumber, did.Multisig.Threshold, err.Error())
+	}
+
+	signed := map[string]bool{}
+
+	for _, controllerSignature := range signatures {
+		if !containsString(did.Multisig.Controllers, controllerSignature.Controller) {
+			return newError(codeUnauthorized, "%s is not a controller of %s", controllerSignature.Controller, didNumber)
+		}
+
+		if signed[controllerSignature.Controller] {
+			return newError(codeInvalidSignature, "%s signed the change of %s twice", controllerSignature.Controller, didNumber)
+		}
+
+		signer := newQueryResult(didNumber, did)
+
+		if controllerSignature.Controller != did.Id {
+			var err error
+
+			if signer, err = findDidById(ctx, controllerSignature.Controller); err != nil {
+				return err
+			}
+
+			if signer.Record.Deactivated {
+				return newError(codeDidDeactivated, "%s is deactivated", controllerSignature.Controller)
+			}
+		}
+
+		if err := verifySignedUpdate(ctx, signer.Key, signer.Record, didNumber, did, update, controllerSignature.Signature); err != nil {
+			return err
+		}
+
+		signed[controllerSignature.Controller] = true
+	}
+
+	if len(signed) < did.Multisig.Threshold {
+		return newError(codeInvalidSignature, "Changes to the keys of %s must be signed by %d of its controllers, %d signed", didNumber, did.Multisig.Threshold, len(signed))
+	}
+
+	return nil
+}
+
+// SetMultisigPolicy makes rotating or adding keys of a did, deactivating it and
+// changing the policy itself require the signatures of threshold of the
+// controller dids given as a JSON array. A did may name itself as a controller.
+// An empty array removes the policy. Unless the did already has a policy the
+// signature must be made with the current authentication key
+func (s *DidContract) SetMultisigPolicy(ctx contractapi.TransactionContextInterface, didNumber string, controllers string, threshold int, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	policy := MultisigPolicy{Controllers: []string{}}
+
+	if err := decodeStrict([]byte(controllers), &policy.Controllers); err != nil {
+		return newError(codeInvalidArgument, "Controllers must be a JSON array of dids. %s", err.Error())
+	}
+
+	if len(policy.Controllers) > 0 {
+		policy.Threshold = threshold
+
+		if threshold < 1 || threshold > len(policy.Controllers) {
+			return newError(codeInvalidArgument, "Threshold must be between 1 and the number of controllers, %d", len(policy.Controllers))
+		}
+	}
+
+	for i, controller := range policy.Controllers {
+		if containsString(policy.Controllers[:i], controller) {
+			return newError(codeInvalidArgument, "Controller %s is listed twice", controller)
+		}
+
+		if controller == did.Id {
+			continue
+		}
+
+		if _, err := findDidById(ctx, controller); err != nil {
+			return err
+		}
+	}
+
+	update := updatableDetails(did)
+	update.Multisig = &policy
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
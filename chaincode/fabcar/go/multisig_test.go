@@ -0,0 +1,187 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signControllers returns the signatures of the controller dids with given keys
+// over the update payload of the stored did as passed to multisig dids
+func signControllers(t *testing.T, l *testLedger, didNumber string, update *Did, keys map[string]*testKey) string {
+	signatures := []ControllerSignature{}
+
+	for controller, key := range keys {
+		signatures = append(signatures, ControllerSignature{Controller: controller, Signature: signUpdate(t, l, key, didNumber, update)})
+	}
+
+	signaturesAsBytes, err := json.Marshal(signatures)
+	require.NoError(t, err)
+
+	return string(signaturesAsBytes)
+}
+
+// rotation returns the stored did with its authentication key replaced by key
+func rotation(t *testing.T, l *testLedger, didNumber string, key *testKey) *Did {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.AuthenticationId = didNumber + "#keys-2"
+	update.AuthenticationType = testKeyType
+	update.AuthenticationPublicKeyPerm = key.pem
+	update.AuthenticationPublicKeyMultibase = ""
+	update.AuthenticationPublicKeyJwk = nil
+
+	return &update
+}
+
+// setTestMultisigPolicy gives the did a multisig policy signed with key
+func setTestMultisigPolicy(t *testing.T, l *testLedger, key *testKey, didNumber string, controllers []string, threshold int) {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Multisig = &MultisigPolicy{Controllers: controllers, Threshold: threshold}
+
+	controllersAsBytes, err := json.Marshal(controllers)
+	require.NoError(t, err)
+
+	require.NoError(t, new(DidContract).SetMultisigPolicy(l.ctx, didNumber, string(controllersAsBytes), threshold, signUpdate(t, l, key, didNumber, &update)))
+	l.nextTx()
+}
+
+func TestSetMultisigPolicy(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	second := createTestDid(t, l, newTestKey(t))
+
+	invalid := map[string][]interface{}{
+		"should require a JSON array":        {`"` + second + `"`, 1},
+		"should require a threshold":         {`["` + second + `"]`, 0},
+		"should not exceed the controllers":  {`["` + id + `","` + second + `"]`, 3},
+		"should not list a controller twice": {`["` + second + `","` + second + `"]`, 1},
+	}
+
+	for message, args := range invalid {
+		assertErrorCode(t, s.SetMultisigPolicy(l.ctx, id, args[0].(string), args[1].(int), ""), codeInvalidArgument, message)
+	}
+
+	err := s.SetMultisigPolicy(l.ctx, id, `["did:fabric:unknown"]`, 1, "")
+	assertErrorCode(t, err, codeDidNotFound, "should require controllers to exist")
+
+	setTestMultisigPolicy(t, l, key, id, []string{id, second}, 2)
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, &MultisigPolicy{Controllers: []string{id, second}, Threshold: 2}, did.Multisig, "should store the policy")
+
+	result, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{id, second}, result.DidDocument.Controller, "should list the controllers in the document")
+}
+
+func TestMultisigApproval(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	secondKey := newTestKey(t)
+	second := createTestDid(t, l, secondKey)
+	thirdKey := newTestKey(t)
+	third := createTestDid(t, l, thirdKey)
+	outsider := newTestKey(t)
+	outsiderId := createTestDid(t, l, outsider)
+
+	setTestMultisigPolicy(t, l, key, id, []string{id, second, third}, 2)
+
+	rotated := newTestKey(t)
+	update := rotation(t, l, id, rotated)
+	rotateKey := func(signature string) error {
+		return s.RotateKey(l.ctx, id, update.AuthenticationId, update.AuthenticationType, update.AuthenticationPublicKeyPerm, signature)
+	}
+
+	assertErrorCode(t, rotateKey(signUpdate(t, l, key, id, update)), codeInvalidSignature, "should require controller signatures to rotate keys")
+	assertErrorCode(t, rotateKey(signControllers(t, l, id, update, map[string]*testKey{id: key})), codeInvalidSignature, "should require the threshold")
+	assertErrorCode(t, rotateKey(signControllers(t, l, id, update, map[string]*testKey{id: key, outsiderId: outsider})), codeUnauthorized,
+		"should only accept signatures of controllers")
+	assertErrorCode(t, rotateKey(signControllers(t, l, id, update, map[string]*testKey{id: key, second: thirdKey})), codeInvalidSignature,
+		"should verify each signature with the key of its controller")
+
+	duplicate := `[{"controller":"` + second + `","signature":"` + signUpdate(t, l, secondKey, id, update) + `"},{"controller":"` + second +
+		`","signature":"` + signUpdate(t, l, secondKey, id, update) + `"}]`
+	assertErrorCode(t, rotateKey(duplicate), codeInvalidSignature, "should not count a controller twice")
+
+	require.NoError(t, rotateKey(signControllers(t, l, id, update, map[string]*testKey{id: key, second: secondKey})), "should rotate keys signed by the threshold")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id+"#keys-2", did.AuthenticationId, "should apply the approved change")
+
+	service := testUpdate(id, rotated, "https://example.org/vc/")
+	service.AuthenticationId = id + "#keys-2"
+	require.NoError(t, updateDid(l, id, service, signUpdate(t, l, rotated, id, service)), "should not require controllers for other changes")
+	l.nextTx()
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	deactivated := updatableDetails(did)
+	deactivated.Deactivated = true
+
+	err = s.DeactivateDid(l.ctx, id, signUpdate(t, l, rotated, id, &deactivated))
+	assertErrorCode(t, err, codeInvalidSignature, "should require controller signatures to deactivate")
+
+	err = s.DeactivateDid(l.ctx, id, signControllers(t, l, id, &deactivated, map[string]*testKey{second: secondKey, third: thirdKey}))
+	require.NoError(t, err, "should deactivate dids signed by the threshold")
+}
+
+func TestRemoveMultisigPolicy(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	secondKey := newTestKey(t)
+	second := createTestDid(t, l, secondKey)
+
+	setTestMultisigPolicy(t, l, key, id, []string{second}, 1)
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Multisig = &MultisigPolicy{Controllers: []string{}}
+
+	err = s.SetMultisigPolicy(l.ctx, id, `[]`, 0, signUpdate(t, l, key, id, &update))
+	assertErrorCode(t, err, codeInvalidSignature, "should require the controllers to change the policy")
+
+	require.NoError(t, s.SetMultisigPolicy(l.ctx, id, `[]`, 0, signControllers(t, l, id, &update, map[string]*testKey{second: secondKey})), "should remove the policy")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, did.Multisig, "should remove the policy")
+}
@@ -0,0 +1,310 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// namespaceObjectType is the composite key object type under which the
+// namespace of every did is indexed by its key
+const namespaceObjectType = "namespace"
+
+// DidNamespace names the organization in whose namespace a did is stored and
+// the namespaces it was stored in before control of it was transferred
+type DidNamespace struct {
+	MSPID    string   `json:"mspId"`
+	Previous []string `json:"previous,omitempty" metadata:"previous,optional"`
+}
+
+// clientMSPID returns the MSP ID of the submitting client
+func clientMSPID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	return mspID, nil
+}
+
+// namespaceKey returns the world state key of the namespace of the did stored
+// with given key
+func namespaceKey(ctx contractapi.TransactionContextInterface, didNumber string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(namespaceObjectType, []string{didNumber})
+}
+
+// namespacedDidKey returns the world state key of the did stored with given key
+// in the namespace of mspID
+func namespacedDidKey(ctx contractapi.TransactionContextInterface, mspID string, didNumber string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(didObjectType, []string{mspID, didNumber})
+}
+
+// getDidNamespace returns the namespace of the did stored with given key, or nil
+// if no did is stored with it
+func getDidNamespace(ctx contractapi.TransactionContextInterface, didNumber string) (*DidNamespace, error) {
+	key, err := namespaceKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	namespaceAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if namespaceAsBytes == nil {
+		return nil, nil
+	}
+
+	namespace := new(DidNamespace)
+
+	if err := unmarshalRecord(key, namespaceAsBytes, namespace); err != nil {
+		return nil, err
+	}
+
+	return namespace, nil
+}
+
+// putDidNamespace indexes the namespace of the did stored with given key
+func putDidNamespace(ctx contractapi.TransactionContextInterface, didNumber string, namespace *DidNamespace) error {
+	key, err := namespaceKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	namespaceAsBytes, err := marshalRecord(key, namespace)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, namespaceAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// didKey returns the world state key of the did stored with given key. Dids are
+// stored in the namespace of the organization that registered them, or that of
+// the submitting client for dids that are not stored yet
+func didKey(ctx contractapi.TransactionContextInterface, didNumber string) (string, error) {
+	namespace, err := getDidNamespace(ctx, didNumber)
+
+	if err != nil {
+		return "", err
+	}
+
+	if namespace != nil {
+		return namespacedDidKey(ctx, namespace.MSPID, didNumber)
+	}
+
+	mspID, err := clientMSPID(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	return namespacedDidKey(ctx, mspID, didNumber)
+}
+
+// didNumberOfKey returns the key a did stored under the given world state key
+// was stored with
+func didNumberOfKey(ctx contractapi.TransactionContextInterface, key string) (string, error) {
+	_, keyParts, err := ctx.GetStub().SplitCompositeKey(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(keyParts) != 2 {
+		return "", newError(codeCorruptRecord, "%s is not a did key", printableKey(key))
+	}
+
+	return keyParts[1], nil
+}
+
+// assertNamespace returns an error unless the did stored with given key is in the
+// namespace of the submitting client's organization, as organizations may only
+// change the dids of their own namespace
+func assertNamespace(ctx contractapi.TransactionContextInterface, didNumber string) error {
+	namespace, err := getDidNamespace(ctx, didNumber)
+
+	if err != nil || namespace == nil {
+		return err
+	}
+
+	mspID, err := clientMSPID(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if namespace.MSPID != mspID {
+		return newError(codeUnauthorized, "%s is in the namespace of %s, %s may only change dids of its own namespace", didNumber, namespace.MSPID, mspID)
+	}
+
+	return nil
+}
+
+// moveDidNamespace moves the did stored with given key into the namespace of
+// mspID, keeping the namespace it leaves so that its history can still be read,
+// and returns its new world state key. The did must be written to the new key by
+// the caller, as the move is not visible to reads of the same transaction
+func moveDidNamespace(ctx contractapi.TransactionContextInterface, didNumber string, mspID string) (string, error) {
+	namespace, err := getDidNamespace(ctx, didNumber)
+
+	if err != nil {
+		return "", err
+	}
+
+	if namespace == nil {
+		return "", newError(codeDidNotFound, "%s does not exist", didNumber)
+	}
+
+	key, err := namespacedDidKey(ctx, mspID, didNumber)
+
+	if err != nil || namespace.MSPID == mspID {
+		return key, err
+	}
+
+	previous, err := namespacedDidKey(ctx, namespace.MSPID, didNumber)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := countDid(ctx, previous, didNumber, nil); err != nil {
+		return "", err
+	}
+
+	err = ctx.GetStub().DelState(previous)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	moved := DidNamespace{MSPID: mspID, Previous: append(namespace.Previous, namespace.MSPID)}
+
+	if err := putDidNamespace(ctx, didNumber, &moved); err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// didHistory returns every change of the did stored with given key recorded in
+// the ledger history, oldest first, across all namespaces it was stored in. The
+// deletions of a did from the namespaces it was moved out of are left out
+func didHistory(ctx contractapi.TransactionContextInterface, didNumber string) ([]*queryresult.KeyModification, error) {
+	namespace, err := getDidNamespace(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := didKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	modifications := []*queryresult.KeyModification{}
+
+	if namespace != nil {
+		for _, mspID := range namespace.Previous {
+			previous, err := namespacedDidKey(ctx, mspID, didNumber)
+
+			if err != nil {
+				return nil, err
+			}
+
+			versions, err := keyHistory(ctx, previous)
+
+			if err != nil {
+				return nil, err
+			}
+
+			for _, modification := range versions {
+				if !modification.IsDelete {
+					modifications = append(modifications, modification)
+				}
+			}
+		}
+	}
+
+	versions, err := keyHistory(ctx, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return append(modifications, versions...), nil
+}
+
+// QueryDidNamespace returns the namespace the did stored with given key is in
+func (s *DidContract) QueryDidNamespace(ctx contractapi.TransactionContextInterface, didNumber string) (*DidNamespace, error) {
+	namespace, err := getDidNamespace(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace == nil {
+		return nil, newError(codeDidNotFound, "%s does not exist", didNumber)
+	}
+
+	return namespace, nil
+}
+
+// QueryDidsByNamespace returns a page of at most pageSize dids of the namespace of
+// mspID, starting at the bookmark returned with the previous page
+func (s *DidContract) QueryDidsByNamespace(ctx contractapi.TransactionContextInterface, mspID string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if mspID == "" {
+		return nil, newError(codeInvalidArgument, "An MSP ID is required")
+	}
+
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	results, next, err := queryDidPage(ctx, []string{mspID}, nil, pageSize, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := PaginatedQueryResult{
+		Records:             results,
+		FetchedRecordsCount: int32(len(results)),
+		Bookmark:            next,
+	}
+
+	return &page, nil
+}
@@ -0,0 +1,132 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDidNamespace(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	namespace, err := s.QueryDidNamespace(l.ctx, id)
+	require.NoError(t, err, "should return the namespace")
+	assert.Equal(t, &DidNamespace{MSPID: testMSPID}, namespace, "should store dids in the namespace of the registering organization")
+	assert.Contains(t, l.state, testDidKey(id), "should prefix the key with the namespace")
+
+	_, err = s.QueryDidNamespace(l.ctx, "DID9")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.createDid(l.ctx, id, &Did{Id: id})
+	assertErrorCode(t, err, codeDidAlreadyExists, "should keep keys unique across namespaces")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err, "should let other organizations read the did")
+	assert.Equal(t, id, did.Id)
+
+	err = s.AddDidEndorser(l.ctx, id, otherMSPID)
+	assertErrorCode(t, err, codeUnauthorized, "should only let organizations change dids of their namespace")
+
+	l.setClient(testClientID, otherMSPID)
+	update := testUpdate(id, key, "https://example.org/vc/")
+	err = updateDid(l, id, update, signUpdate(t, l, key, id, update))
+	assertErrorCode(t, err, codeUnauthorized, "should check the namespace of updates")
+
+	l.setClient(testClientID, testMSPID)
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)), "should let the organization change its dids")
+	l.nextTx()
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+
+	namespace, err = s.QueryDidNamespace(l.ctx, "DID0")
+	require.NoError(t, err)
+	assert.Equal(t, testMSPID, namespace.MSPID, "should seed dids in the namespace of the administrator")
+}
+
+func TestTransferNamespace(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	require.NoError(t, s.ProposeTransfer(l.ctx, id, otherClientID))
+	l.nextTx()
+
+	l.setClient(otherClientID, otherMSPID)
+	require.NoError(t, s.AcceptTransfer(l.ctx, id))
+	l.nextTx()
+
+	assert.NotContains(t, l.state, testDidKey(id), "should remove the did from the previous namespace")
+	assert.Contains(t, l.state, testNamespacedDidKey(otherMSPID, id), "should move the did to the namespace of the new controller")
+
+	namespace, err := s.QueryDidNamespace(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, &DidNamespace{MSPID: otherMSPID, Previous: []string{testMSPID}}, namespace, "should keep the previous namespaces")
+
+	history, err := s.QueryDidHistory(l.ctx, id)
+	require.NoError(t, err)
+	require.Len(t, history, 2, "should read the history of every namespace")
+	assert.Equal(t, []string{"tx1", "tx3"}, []string{history[0].TxId, history[1].TxId}, "should leave out the move")
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)), "should let the new organization change the did")
+	assert.Equal(t, []string{otherMSPID}, l.endorsers(t, id), "should restrict endorsement of the new key")
+}
+
+func TestQueryDidsByNamespace(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	first := createTestDid(t, l, newTestKey(t))
+	second := createTestDid(t, l, newTestKey(t))
+
+	l.setClient(otherClientID, otherMSPID)
+	other := createTestDid(t, l, newTestKey(t))
+
+	page, err := s.QueryDidsByNamespace(l.ctx, otherMSPID, 10, "")
+	require.NoError(t, err, "should return the dids of the namespace")
+	require.Len(t, page.Records, 1)
+	assert.Equal(t, other, page.Records[0].Key, "should only return dids of the namespace")
+	assert.Empty(t, page.Bookmark, "should return an empty bookmark on the last page")
+
+	page, err = s.QueryDidsByNamespace(l.ctx, testMSPID, 1, "")
+	require.NoError(t, err)
+	require.Len(t, page.Records, 1)
+	assert.NotEmpty(t, page.Bookmark, "should page through the namespace")
+
+	next, err := s.QueryDidsByNamespace(l.ctx, testMSPID, 1, page.Bookmark)
+	require.NoError(t, err)
+	require.Len(t, next.Records, 1)
+	assert.ElementsMatch(t, []string{first, second}, []string{page.Records[0].Key, next.Records[0].Key}, "should continue at the bookmark")
+
+	_, err = s.QueryDidsByNamespace(l.ctx, testMSPID, 0, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject non-positive page sizes")
+
+	_, err = s.QueryDidsByNamespace(l.ctx, testMSPID, maxQueryRecords+1, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject oversized page sizes")
+
+	_, err = s.QueryDidsByNamespace(l.ctx, "", 10, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require an MSP ID")
+}
@@ -0,0 +1,228 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// offChainThresholdConfig names the configuration entry holding the size above
+// which documents may be stored off-chain
+const offChainThresholdConfig = "offChainThreshold"
+
+// defaultOffChainThreshold is the size in bytes above which documents may be
+// stored off-chain until a registry administrator configures another
+const defaultOffChainThreshold = 8192
+
+// maxOffChainDocumentSize is the size in bytes of the largest block IPFS
+// accepts, and so of the largest document a single raw CID can address
+const maxOffChainDocumentSize = 1 << 20
+
+// documentTransientKey is the transient map key holding the document stored
+// off-chain by CreateDidWithCid
+const documentTransientKey = "document"
+
+// Prefix of the CIDs of off-chain documents: the base32 multibase prefix,
+// followed by the CID version 1, raw codec and sha2-256 multihash bytes
+const (
+	cidMultibasePrefix = "b"
+	cidPrefix          = "\x01\x55\x12\x20"
+)
+
+// cidEncoding is the lower case, unpadded base32 encoding of CIDs
+var cidEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// OffChainThreshold describes the size in bytes above which documents may be
+// stored off-chain
+type OffChainThreshold struct {
+	Threshold int `json:"threshold"`
+}
+
+// OffChainDocument describes a document stored off-chain. Cid is the CIDv1 of
+// the document as an IPFS raw block, Hash its SHA-256 as hex and Size its
+// length in bytes
+type OffChainDocument struct {
+	Cid  string `json:"cid"`
+	Hash string `json:"hash"`
+	Size int    `json:"size"`
+}
+
+// documentCid returns the CIDv1 addressing document as an IPFS raw block
+// hashed with sha2-256, as created by ipfs block put --cid-codec raw
+func documentCid(document []byte) string {
+	digest := sha256.Sum256(document)
+
+	return cidMultibasePrefix + strings.ToLower(cidEncoding.EncodeToString(append([]byte(cidPrefix), digest[:]...)))
+}
+
+func offChainThresholdKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configObjectType, []string{offChainThresholdConfig})
+}
+
+// getOffChainThreshold returns the configured off-chain threshold, or the
+// default if none has been configured
+func getOffChainThreshold(ctx contractapi.TransactionContextInterface) (*OffChainThreshold, error) {
+	key, err := offChainThresholdKey(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	thresholdAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if thresholdAsBytes == nil {
+		return &OffChainThreshold{Threshold: defaultOffChainThreshold}, nil
+	}
+
+	threshold := new(OffChainThreshold)
+
+	if err := unmarshalRecord(key, thresholdAsBytes, threshold); err != nil {
+		return nil, err
+	}
+
+	return threshold, nil
+}
+
+// SetOffChainThreshold sets the size in bytes above which documents may be
+// stored off-chain. Only registry administrators may call it. Documents already
+// stored off-chain are kept
+func (a *AdminContract) SetOffChainThreshold(ctx contractapi.TransactionContextInterface, threshold int) error {
+	if threshold <= 0 || threshold >= maxOffChainDocumentSize {
+		return newError(codeInvalidArgument, "Off-chain threshold must be between 1 and %d bytes", maxOffChainDocumentSize-1)
+	}
+
+	key, err := offChainThresholdKey(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	thresholdAsBytes, err := marshalRecord(key, OffChainThreshold{Threshold: threshold})
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, thresholdAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryOffChainThreshold returns the size in bytes above which documents may
+// be stored off-chain
+func (s *DidContract) QueryOffChainThreshold(ctx contractapi.TransactionContextInterface) (*OffChainThreshold, error) {
+	return getOffChainThreshold(ctx)
+}
+
+// readOffChainDocument reads the document passed in the transient map and
+// checks that it is a JSON object above the off-chain threshold addressed by cid
+func readOffChainDocument(ctx contractapi.TransactionContextInterface, cid string) (*OffChainDocument, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read transient map. %s", err.Error())
+	}
+
+	document, ok := transientMap[documentTransientKey]
+
+	if !ok {
+		return nil, newError(codeInvalidArgument, "%s must be a key in the transient map", documentTransientKey)
+	}
+
+	var object map[string]json.RawMessage
+
+	if err := json.Unmarshal(document, &object); err != nil || object == nil {
+		return nil, newError(codeInvalidArgument, "Transient %s must be a JSON object", documentTransientKey)
+	}
+
+	threshold, err := getOffChainThreshold(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(document) <= threshold.Threshold {
+		return nil, newError(codeInvalidArgument, "Document is %d bytes, only documents above %d bytes may be stored off-chain", len(document), threshold.Threshold)
+	}
+
+	if len(document) > maxOffChainDocumentSize {
+		return nil, newError(codeInvalidArgument, "Document is %d bytes, off-chain documents may be at most %d", len(document), maxOffChainDocumentSize)
+	}
+
+	if expected := documentCid(document); cid != expected {
+		return nil, newError(codeInvalidArgument, "CID %s does not address the document, expected %s", cid, expected)
+	}
+
+	digest := sha256.Sum256(document)
+
+	return &OffChainDocument{Cid: cid, Hash: hex.EncodeToString(digest[:]), Size: len(document)}, nil
+}
+
+// CreateDidWithCid adds a new did whose document is stored off-chain, keeping
+// only the authentication key and the CID and hash of the document on the
+// ledger. The document is passed in the transient map under the document key so
+// it does not appear in the transaction, and must be larger than the off-chain
+// threshold. cid must be the CIDv1 of the document added to IPFS as a raw block
+func (s *DidContract) CreateDidWithCid(ctx contractapi.TransactionContextInterface, authenticationId string, authenticationType string,
+	authenticationController string, authenticationPublicKeyPerm string, cid string, expires string) (string, error) {
+	offChain, err := readOffChainDocument(ctx, cid)
+
+	if err != nil {
+		return "", err
+	}
+
+	did := Did{
+		AuthenticationId:            authenticationId,
+		AuthenticationType:          authenticationType,
+		AuthenticationController:    authenticationController,
+		AuthenticationPublicKeyPerm: authenticationPublicKeyPerm,
+		Expires:                     expires,
+		OffChainDocument:            offChain,
+	}
+
+	if err := admitRegistration(ctx, 1); err != nil {
+		return "", err
+	}
+
+	if err := assignIdentifier(ctx, &did); err != nil {
+		return "", err
+	}
+
+	if err := s.createDid(ctx, did.Id, &did); err != nil {
+		return "", err
+	}
+
+	return did.Id, nil
+}
@@ -0,0 +1,131 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// largeDocument returns a JSON object of more than size bytes
+func largeDocument(size int) []byte {
+	return []byte(`{"service":"` + strings.Repeat("x", size) + `"}`)
+}
+
+// setDocument passes document in the transient map of the proposal
+func setDocument(l *testLedger, document []byte) {
+	l.stub.GetTransientReturns(map[string][]byte{documentTransientKey: document}, nil)
+}
+
+func TestDocumentCid(t *testing.T) {
+	assert.Equal(t, "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e", documentCid([]byte("hello world")),
+		"should return the CID IPFS assigns to the raw block")
+}
+
+func TestSetOffChainThreshold(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+
+	threshold, err := s.QueryOffChainThreshold(l.ctx)
+	require.NoError(t, err, "should return the default threshold")
+	assert.Equal(t, defaultOffChainThreshold, threshold.Threshold)
+
+	err = l.before(a, "SetOffChainThreshold")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators set the threshold")
+
+	l.setAdmin(true)
+	require.NoError(t, a.SetOffChainThreshold(l.ctx, 1024), "should set the threshold")
+
+	threshold, err = s.QueryOffChainThreshold(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1024, threshold.Threshold, "should return the configured threshold")
+
+	assertErrorCode(t, a.SetOffChainThreshold(l.ctx, 0), codeInvalidArgument, "should require a positive threshold")
+	assertErrorCode(t, a.SetOffChainThreshold(l.ctx, maxOffChainDocumentSize), codeInvalidArgument, "should keep the threshold below the block size")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.QueryOffChainThreshold(l.ctx)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestCreateDidWithCid(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	document := largeDocument(defaultOffChainThreshold)
+	cid := documentCid(document)
+
+	setDocument(l, document)
+	id, err := s.CreateDidWithCid(l.ctx, "#keys-1", testKeyType, "", key.pem, cid, "")
+	require.NoError(t, err, "should create the did")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, key.pem, did.AuthenticationPublicKeyPerm, "should store the authentication key on-chain")
+	require.NotNil(t, did.OffChainDocument, "should store the off-chain document reference")
+	assert.Equal(t, cid, did.OffChainDocument.Cid, "should store the CID")
+	assert.Equal(t, fmt.Sprintf("%x", sha256.Sum256(document)), did.OffChainDocument.Hash, "should store the document hash")
+	assert.Equal(t, len(document), did.OffChainDocument.Size, "should store the document size")
+
+	resolution, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.Equal(t, did.OffChainDocument, resolution.DidResolutionMetadata.OffChainDocument, "should carry the CID in the resolution metadata")
+	assert.Empty(t, resolution.DidDocument.Service, "should not resolve services on-chain")
+
+	update := updatableDetails(did)
+	require.NoError(t, updateDid(l, id, &update, signUpdate(t, l, key, id, &update)))
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, cid, did.OffChainDocument.Cid, "should keep the CID on updates")
+
+	_, err = s.CreateDidWithCid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, documentCid([]byte("{}")), "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject CIDs of other documents")
+
+	small := []byte(`{"id":"did:example:small"}`)
+	setDocument(l, small)
+	_, err = s.CreateDidWithCid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, documentCid(small), "")
+	assertErrorCode(t, err, codeInvalidArgument, "should keep documents up to the threshold on-chain")
+
+	array := []byte("[" + strings.Repeat(`"x",`, defaultOffChainThreshold) + `"x"]`)
+	setDocument(l, array)
+	_, err = s.CreateDidWithCid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, documentCid(array), "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a JSON object")
+
+	huge := largeDocument(maxOffChainDocumentSize)
+	setDocument(l, huge)
+	_, err = s.CreateDidWithCid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, documentCid(huge), "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject documents larger than an IPFS block")
+
+	l.stub.GetTransientReturns(map[string][]byte{}, nil)
+	_, err = s.CreateDidWithCid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, cid, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require the document in the transient map")
+
+	setDocument(l, document)
+	_, err = s.CreateDidWithCid(l.ctx, "#keys-1", testKeyType, "", key.pem, cid, "")
+	assertErrorCode(t, err, codeDidAlreadyExists, "should reject dids that already exist")
+}
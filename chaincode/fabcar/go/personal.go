@@ -0,0 +1,266 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// didPersonalCollection is the private data collection holding the personal
+// data of did subjects. It must match the name used in collections_config.json
+const didPersonalCollection = "didPersonalCollection"
+
+// personalDataTransientKey is the transient map key holding the personal data
+// passed to SetDidPersonalData
+const personalDataTransientKey = "personalData"
+
+// personalFieldPattern matches the names of personal data fields
+var personalFieldPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,63}$`)
+
+// DidPersonalData describes the personal data of a did subject held in the
+// private data collection, and the salt its fields are hashed with
+type DidPersonalData struct {
+	Fields map[string]string `json:"fields"`
+	Salt   string            `json:"salt"`
+}
+
+// PersonalDataHashes describes the salted hashes of the personal data fields of
+// a did kept on the public ledger. ErasedBy is set once the personal data has
+// been erased from the private data collection
+type PersonalDataHashes struct {
+	Fields   map[string]string `json:"fields"`
+	ErasedBy *ProvenanceEntry  `json:"erasedBy,omitempty" metadata:"erasedBy,optional"`
+}
+
+// ErasureReceipt describes the erasure of the personal data of a did. It lists
+// the hashes of the erased fields, which stay on the public ledger
+type ErasureReceipt struct {
+	DidNumber  string            `json:"didNumber"`
+	Collection string            `json:"collection"`
+	Fields     map[string]string `json:"fields"`
+	ErasedBy   *ProvenanceEntry  `json:"erasedBy"`
+}
+
+// personalFieldHash returns the salted hash of a personal data field
+func personalFieldHash(name string, value string, salt string) string {
+	return saltedHash(name+"="+value, salt)
+}
+
+// readPersonalData reads the personal data passed in the transient map
+func readPersonalData(ctx contractapi.TransactionContextInterface) (*DidPersonalData, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read transient map. %s", err.Error())
+	}
+
+	dataAsBytes, ok := transientMap[personalDataTransientKey]
+
+	if !ok {
+		return nil, newError(codeInvalidArgument, "%s must be a key in the transient map", personalDataTransientKey)
+	}
+
+	data := new(DidPersonalData)
+
+	if err := decodeStrict(dataAsBytes, data); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode transient %s. %s", personalDataTransientKey, err.Error())
+	}
+
+	if data.Salt == "" {
+		return nil, newError(codeInvalidArgument, "Salt must not be empty")
+	}
+
+	if len(data.Fields) == 0 {
+		return nil, newError(codeInvalidArgument, "At least one personal data field is required")
+	}
+
+	for name := range data.Fields {
+		if !personalFieldPattern.MatchString(name) {
+			return nil, newError(codeInvalidArgument, "%q is not a valid personal data field name", name)
+		}
+	}
+
+	return data, nil
+}
+
+// SetDidPersonalData replaces the personal data of the subject of a did with
+// the fields passed in the transient map under the personalData key, so they
+// never appear in the transaction. The fields are kept in the personal data
+// collection and only their salted hashes in the public did. Only the
+// controller of the did may call it
+func (s *DidContract) SetDidPersonalData(ctx contractapi.TransactionContextInterface, didNumber string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	data, err := readPersonalData(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	dataAsBytes, err := marshalRecord(didNumber, data)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutPrivateData(didPersonalCollection, didNumber, dataAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to private data collection. %s", err.Error())
+	}
+
+	if err := setPrivateOwnerEndorsement(ctx, didPersonalCollection, didNumber); err != nil {
+		return err
+	}
+
+	hashes := PersonalDataHashes{Fields: map[string]string{}}
+
+	for name, value := range data.Fields {
+		hashes.Fields[name] = personalFieldHash(name, value, data.Salt)
+	}
+
+	did.PersonalData = &hashes
+
+	return putUpdatedDid(ctx, didNumber, did)
+}
+
+// QueryDidPersonalData returns the personal data of the subject of a did. The
+// fields are checked against the hashes on the public ledger. Organizations
+// other than the owner of the did need an active sharing agreement and only
+// receive the fields it covers
+func (s *DidContract) QueryDidPersonalData(ctx contractapi.TransactionContextInterface, didNumber string) (*DidPersonalData, error) {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	agreement, err := readerAgreement(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if did.PersonalData == nil || did.PersonalData.ErasedBy != nil {
+		return nil, newError(codeNotFound, "%s has no personal data", didNumber)
+	}
+
+	dataAsBytes, err := ctx.GetStub().GetPrivateData(didPersonalCollection, didNumber)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from private data collection. %s", err.Error())
+	}
+
+	if dataAsBytes == nil {
+		return nil, newError(codeNotFound, "%s has no personal data", didNumber)
+	}
+
+	data := new(DidPersonalData)
+
+	if err := unmarshalRecord(didNumber, dataAsBytes, data); err != nil {
+		return nil, err
+	}
+
+	if len(data.Fields) != len(did.PersonalData.Fields) {
+		return nil, newError(codeCorruptRecord, "Personal data of %s does not match the public hashes", didNumber)
+	}
+
+	for name, value := range data.Fields {
+		if personalFieldHash(name, value, data.Salt) != did.PersonalData.Fields[name] {
+			return nil, newError(codeCorruptRecord, "Personal data of %s does not match the public hashes", didNumber)
+		}
+	}
+
+	if agreement == nil {
+		return data, nil
+	}
+
+	for name := range data.Fields {
+		if !agreement.covers(personalDataFieldPrefix + name) {
+			delete(data.Fields, name)
+		}
+	}
+
+	if len(data.Fields) == 0 {
+		return nil, newError(codeUnauthorized, "%s shares no personal data fields with %s", agreement.OwnerMSPID, agreement.RecipientMSPID)
+	}
+
+	return data, nil
+}
+
+// EraseDidPersonalData erases the personal data of the subject of a did from
+// the personal data collection, leaving only the salted hashes of its fields
+// on the public ledger, and records an erasure receipt in the audit log of the
+// did. The data is deleted from the private state of the collection. Peers only
+// drop the private write sets of earlier blocks from their private data store
+// once the blockToLive of the collection has passed. Only the controller of the
+// did may call it, also after the did was deactivated or deleted
+func (s *DidContract) EraseDidPersonalData(ctx contractapi.TransactionContextInterface, didNumber string) (*ErasureReceipt, error) {
+	did, err := getDid(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return nil, err
+	}
+
+	if did.PersonalData == nil || did.PersonalData.ErasedBy != nil {
+		return nil, newError(codeNotFound, "%s has no personal data", didNumber)
+	}
+
+	err = ctx.GetStub().DelPrivateData(didPersonalCollection, didNumber)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to delete from private data collection. %s", err.Error())
+	}
+
+	erasedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	did.PersonalData.ErasedBy = erasedBy
+
+	if err := putUpdatedDid(ctx, didNumber, did); err != nil {
+		return nil, err
+	}
+
+	receipt := ErasureReceipt{DidNumber: didNumber, Collection: didPersonalCollection, Fields: did.PersonalData.Fields, ErasedBy: erasedBy}
+
+	if err := putAuditEntry(ctx, didNumber, &receipt); err != nil {
+		return nil, err
+	}
+
+	return &receipt, nil
+}
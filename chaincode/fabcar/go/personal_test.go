@@ -0,0 +1,116 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setPersonalData passes data in the transient map of the proposal
+func setPersonalData(l *testLedger, data string) {
+	l.stub.GetTransientReturns(map[string][]byte{personalDataTransientKey: []byte(data)}, nil)
+}
+
+func TestSetDidPersonalData(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	setPersonalData(l, `{"fields":{"name":"Alice Example","email":"alice@example.com"},"salt":"s1"}`)
+	require.NoError(t, s.SetDidPersonalData(l.ctx, id), "should store the personal data")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	require.NotNil(t, did.PersonalData, "should keep the hashes in the public did")
+	assert.Equal(t, personalFieldHash("name", "Alice Example", "s1"), did.PersonalData.Fields["name"], "should store the salted hash of each field")
+
+	key, err := didKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.NotContains(t, string(l.state[key]), "alice@example.com", "should not store personal data on the public ledger")
+
+	data, err := s.QueryDidPersonalData(l.ctx, id)
+	require.NoError(t, err, "should return the personal data")
+	assert.Equal(t, map[string]string{"name": "Alice Example", "email": "alice@example.com"}, data.Fields)
+
+	l.private[didPersonalCollection][id] = []byte(`{"fields":{"name":"Mallory","email":"alice@example.com"},"salt":"s1"}`)
+	_, err = s.QueryDidPersonalData(l.ctx, id)
+	assertErrorCode(t, err, codeCorruptRecord, "should check the personal data against the public hashes")
+
+	invalid := []string{`{"fields":{"name":"x"},"salt":""}`, `{"fields":{},"salt":"s1"}`, `{"fields":{"e-mail":"x"},"salt":"s1"}`, `{"fields":{"name":"x"},"salt":"s1","x":1}`}
+
+	for _, payload := range invalid {
+		setPersonalData(l, payload)
+		assertErrorCode(t, s.SetDidPersonalData(l.ctx, id), codeInvalidArgument, "should reject "+payload)
+	}
+
+	l.stub.GetTransientReturns(map[string][]byte{}, nil)
+	assertErrorCode(t, s.SetDidPersonalData(l.ctx, id), codeInvalidArgument, "should require the personal data in the transient map")
+
+	l.setClient(otherClientID, otherMSPID)
+	setPersonalData(l, `{"fields":{"name":"Mallory"},"salt":"s2"}`)
+	assertErrorCode(t, s.SetDidPersonalData(l.ctx, id), codeUnauthorized, "should only let the controller set the personal data")
+}
+
+func TestEraseDidPersonalData(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	_, err := s.EraseDidPersonalData(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should fail for dids without personal data")
+
+	setPersonalData(l, `{"fields":{"name":"Alice Example"},"salt":"s1"}`)
+	require.NoError(t, s.SetDidPersonalData(l.ctx, id))
+	l.nextTx()
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = s.EraseDidPersonalData(l.ctx, id)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller erase the personal data")
+
+	l.setClient(testClientID, testMSPID)
+	l.stub.GetFunctionAndParametersReturns("EraseDidPersonalData", []string{id})
+	receipt, err := s.EraseDidPersonalData(l.ctx, id)
+	require.NoError(t, err, "should erase the personal data")
+	assert.Equal(t, didPersonalCollection, receipt.Collection, "should name the purged collection")
+	assert.Equal(t, map[string]string{"name": personalFieldHash("name", "Alice Example", "s1")}, receipt.Fields, "should list the hashes of the erased fields")
+	assert.Equal(t, l.stub.GetTxID(), receipt.ErasedBy.TxID, "should record the erasing transaction")
+	assert.NotContains(t, l.private[didPersonalCollection], id, "should delete the private data")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, receipt.Fields, did.PersonalData.Fields, "should leave the hashes on-chain")
+	assert.Equal(t, receipt.ErasedBy, did.PersonalData.ErasedBy, "should mark the personal data erased")
+
+	records, err := s.QueryAuditLog(l.ctx, id)
+	require.NoError(t, err)
+	last := records[len(records)-1]
+	assert.Equal(t, "EraseDidPersonalData", last.Operation, "should record the erasure once for the transaction")
+	assert.Equal(t, receipt, last.Erasure, "should record the erasure receipt in the audit log")
+
+	_, err = s.QueryDidPersonalData(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should not return erased personal data")
+
+	_, err = s.EraseDidPersonalData(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should not erase personal data twice")
+	assert.Nil(t, records[0].Erasure, "should only attach receipts to erasures")
+}
@@ -0,0 +1,285 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PolicyContract stores the policies the did contract applies to its
+// transaction functions, so that registry administrators can change them
+// without upgrading the chaincode
+type PolicyContract struct {
+	contractapi.Contract
+	AdminAccess
+}
+
+// policyObjectType is the composite key object type under which operation
+// policies are stored, keyed by the transaction function they apply to
+const policyObjectType = "policy"
+
+// OperationPolicy describes the policy of a transaction function of the did
+// contract. Fee is charged to the caller of a submit function through the token
+// chaincode of the registration fee, AllowedMSPs lists the organizations whose
+// clients may call the function and MaxDocumentSize limits the size in bytes of
+// the dids it writes. Zero values and an empty list impose nothing
+type OperationPolicy struct {
+	Operation       string   `json:"operation"`
+	Fee             int      `json:"fee"`
+	AllowedMSPs     []string `json:"allowedMSPs"`
+	MaxDocumentSize int      `json:"maxDocumentSize"`
+}
+
+func policyKey(ctx contractapi.TransactionContextInterface, operation string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(policyObjectType, []string{operation})
+}
+
+// getOperationPolicy returns the policy of operation, or nil if none is stored
+func getOperationPolicy(ctx contractapi.TransactionContextInterface, operation string) (*OperationPolicy, error) {
+	key, err := policyKey(ctx, operation)
+
+	if err != nil {
+		return nil, err
+	}
+
+	policyAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if policyAsBytes == nil {
+		return nil, nil
+	}
+
+	policy := new(OperationPolicy)
+
+	if err := unmarshalRecord(key, policyAsBytes, policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// assertOperation returns an error unless operation is a transaction function
+// of the did contract
+func assertOperation(operation string) error {
+	if _, ok := didContractAccess[operation]; !ok {
+		return newError(codeInvalidArgument, "%s is not a transaction function of the %s contract", operation, didContractName)
+	}
+
+	return nil
+}
+
+// SetOperationPolicy stores the given JSON OperationPolicy, replacing the policy
+// of its operation. Fees can only be set on submit functions. Only registry
+// administrators may call it
+func (p *PolicyContract) SetOperationPolicy(ctx contractapi.TransactionContextInterface, policyJSON string) error {
+	policy := OperationPolicy{}
+
+	if err := decodeStrict([]byte(policyJSON), &policy); err != nil {
+		return newError(codeInvalidArgument, "Failed to decode policy. %s", err.Error())
+	}
+
+	if err := assertOperation(policy.Operation); err != nil {
+		return err
+	}
+
+	if policy.Fee < 0 || policy.MaxDocumentSize < 0 {
+		return newError(codeInvalidArgument, "Fee and maxDocumentSize must not be negative")
+	}
+
+	if policy.Fee > 0 && isEvaluateTransaction(new(DidContract), policy.Operation) {
+		return newError(codeInvalidArgument, "%s only reads the world state and cannot charge a fee", policy.Operation)
+	}
+
+	if policy.AllowedMSPs == nil {
+		policy.AllowedMSPs = []string{}
+	}
+
+	for _, mspID := range policy.AllowedMSPs {
+		if mspID == "" {
+			return newError(codeInvalidArgument, "Allowed MSP IDs must not be empty")
+		}
+	}
+
+	key, err := policyKey(ctx, policy.Operation)
+
+	if err != nil {
+		return err
+	}
+
+	policyAsBytes, err := marshalRecord(key, policy)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, policyAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// RemoveOperationPolicy removes the policy of operation. Only registry
+// administrators may call it
+func (p *PolicyContract) RemoveOperationPolicy(ctx contractapi.TransactionContextInterface, operation string) error {
+	policy, err := getOperationPolicy(ctx, operation)
+
+	if err != nil {
+		return err
+	}
+
+	if policy == nil {
+		return newError(codeNotFound, "%s has no policy", operation)
+	}
+
+	key, err := policyKey(ctx, operation)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryOperationPolicy returns the policy of operation, which imposes nothing
+// unless one has been set
+func (p *PolicyContract) QueryOperationPolicy(ctx contractapi.TransactionContextInterface, operation string) (*OperationPolicy, error) {
+	if err := assertOperation(operation); err != nil {
+		return nil, err
+	}
+
+	policy, err := getOperationPolicy(ctx, operation)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if policy == nil {
+		return &OperationPolicy{Operation: operation, AllowedMSPs: []string{}}, nil
+	}
+
+	return policy, nil
+}
+
+// QueryOperationPolicies returns every stored operation policy
+func (p *PolicyContract) QueryOperationPolicies(ctx contractapi.TransactionContextInterface) ([]OperationPolicy, error) {
+	policies := []OperationPolicy{}
+
+	err := scanState(ctx, policyObjectType, []string{}, func(key string, value []byte) (bool, error) {
+		if len(policies) == maxQueryRecords {
+			return false, newTooManyRecordsError("operation policies")
+		}
+
+		policy := OperationPolicy{}
+
+		if err := unmarshalRecord(key, value, &policy); err != nil {
+			return false, err
+		}
+
+		policies = append(policies, policy)
+
+		return true, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// enforceOperationPolicy applies the policy of the called function of the did
+// contract, rejecting clients of organizations it does not allow and charging
+// its fee for submit functions
+func enforceOperationPolicy(ctx contractapi.TransactionContextInterface, contract contractapi.ContractInterface) error {
+	function := transactionFunction(ctx)
+
+	if _, ok := didContractAccess[function]; !ok {
+		return nil
+	}
+
+	policy, err := getOperationPolicy(ctx, function)
+
+	if err != nil || policy == nil {
+		return err
+	}
+
+	if len(policy.AllowedMSPs) > 0 {
+		mspID, err := ctx.GetClientIdentity().GetMSPID()
+
+		if err != nil {
+			return fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+		}
+
+		if !containsString(policy.AllowedMSPs, mspID) {
+			return newError(codeUnauthorized, "Clients of %s may not call %s", mspID, function)
+		}
+	}
+
+	if policy.Fee == 0 || isEvaluateTransaction(contract, function) {
+		return nil
+	}
+
+	fee, err := getRegistrationFee(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if fee.Chaincode == "" {
+		return newError(codeFeeRejected, "No token chaincode is configured to pay the fee of %s", function)
+	}
+
+	return payFee(ctx, fee, policy.Fee)
+}
+
+// assertDocumentSize returns an error if the did encoded as didAsBytes is larger
+// than the policy of the called function allows
+func assertDocumentSize(ctx contractapi.TransactionContextInterface, didNumber string, didAsBytes []byte) error {
+	function := transactionFunction(ctx)
+
+	if function == "" {
+		return nil
+	}
+
+	policy, err := getOperationPolicy(ctx, function)
+
+	if err != nil || policy == nil {
+		return err
+	}
+
+	if policy.MaxDocumentSize > 0 && len(didAsBytes) > policy.MaxDocumentSize {
+		return newError(codeInvalidArgument, "%s is %d bytes, %s allows at most %d", didNumber, len(didAsBytes), function, policy.MaxDocumentSize)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetOperationPolicy(t *testing.T) {
+	l := newTestLedger(t)
+	p := new(PolicyContract)
+
+	policy, err := p.QueryOperationPolicy(l.ctx, "CreateDid")
+	require.NoError(t, err, "should return the default policy")
+	assert.Equal(t, &OperationPolicy{Operation: "CreateDid", AllowedMSPs: []string{}}, policy, "should impose nothing by default")
+
+	_, err = p.QueryOperationPolicy(l.ctx, "IssueCredential")
+	assertErrorCode(t, err, codeInvalidArgument, "should only know functions of the did contract")
+
+	err = l.before(p, "SetOperationPolicy")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators set policies")
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, "[]"))
+
+	err = l.before(p, "SetOperationPolicy")
+	require.NoError(t, err)
+
+	err = p.SetOperationPolicy(l.ctx, `{"operation":"CreateDid","fee":3,"allowedMSPs":["Org1MSP"],"maxDocumentSize":4096}`)
+	require.NoError(t, err, "should set the policy")
+
+	err = p.SetOperationPolicy(l.ctx, `{"operation":"UpdateDid","maxDocumentSize":2048}`)
+	require.NoError(t, err)
+
+	policy, err = p.QueryOperationPolicy(l.ctx, "CreateDid")
+	require.NoError(t, err)
+	assert.Equal(t, &OperationPolicy{Operation: "CreateDid", Fee: 3, AllowedMSPs: []string{"Org1MSP"}, MaxDocumentSize: 4096}, policy, "should store the policy")
+
+	policies, err := p.QueryOperationPolicies(l.ctx)
+	require.NoError(t, err)
+	assert.Len(t, policies, 2, "should list every policy")
+
+	invalid := map[string]string{
+		`{"operation":"Unknown"}`:                        "should reject unknown operations",
+		`{"operation":"CreateDid","fee":-1}`:             "should reject negative fees",
+		`{"operation":"CreateDid","maxDocumentSize":-1}`: "should reject negative sizes",
+		`{"operation":"Resolve","fee":1}`:                "should not charge for evaluate functions",
+		`{"operation":"CreateDid","allowedMSPs":[""]}`:   "should reject empty MSP IDs",
+		`{"operation":"CreateDid","limit":1}`:            "should reject unknown members",
+	}
+
+	for policyJSON, message := range invalid {
+		assertErrorCode(t, p.SetOperationPolicy(l.ctx, policyJSON), codeInvalidArgument, message)
+	}
+
+	require.NoError(t, p.RemoveOperationPolicy(l.ctx, "UpdateDid"), "should remove the policy")
+
+	policies, err = p.QueryOperationPolicies(l.ctx)
+	require.NoError(t, err)
+	assert.Len(t, policies, 1)
+
+	err = p.RemoveOperationPolicy(l.ctx, "UpdateDid")
+	assertErrorCode(t, err, codeNotFound, "should not remove missing policies")
+}
+
+func TestEnforceOperationPolicy(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	p := new(PolicyContract)
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, "[]"))
+	require.NoError(t, p.SetOperationPolicy(l.ctx, `{"operation":"CreateDid","fee":3,"allowedMSPs":["`+testMSPID+`"]}`))
+	require.NoError(t, p.SetOperationPolicy(l.ctx, `{"operation":"QueryDidByKey","allowedMSPs":["`+otherMSPID+`"]}`))
+
+	err := l.before(s, "CreateDid")
+	assertErrorCode(t, err, codeFeeRejected, "should require a token chaincode to charge fees")
+
+	require.NoError(t, new(AdminContract).SetRegistrationFee(l.ctx, `{"chaincode":"token_erc20","recipient":"registry","amount":1}`))
+	l.stub.InvokeChaincodeReturns(peer.Response{Status: shim.OK})
+
+	err = l.before(s, "CreateDid")
+	require.NoError(t, err, "should let allowed organizations call the function")
+
+	require.Equal(t, 1, l.stub.InvokeChaincodeCallCount(), "should charge the fee of the operation")
+	_, args, _ := l.stub.InvokeChaincodeArgsForCall(0)
+	assert.Equal(t, [][]byte{[]byte(defaultFeeFunction), []byte("registry"), []byte("3")}, args, "should transfer the fee to the recipient")
+
+	err = l.before(s, "QueryDidByKey")
+	assertErrorCode(t, err, codeUnauthorized, "should reject organizations the policy does not allow")
+
+	l.setClient(otherClientID, otherMSPID)
+
+	err = l.before(s, "CreateDid")
+	assertErrorCode(t, err, codeUnauthorized, "should apply the allowed organizations of each function")
+
+	err = l.before(s, "QueryDidByKey")
+	require.NoError(t, err, "should apply policies to evaluate functions")
+
+	err = l.before(s, "UpdateDid")
+	require.NoError(t, err, "should not restrict functions without a policy")
+	assert.Equal(t, 1, l.stub.InvokeChaincodeCallCount(), "should only charge functions with a fee")
+}
+
+func TestDocumentSizePolicy(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	l.setAdmin(true)
+	require.NoError(t, new(PolicyContract).SetOperationPolicy(l.ctx, `{"operation":"CreateDid","maxDocumentSize":100}`))
+	require.NoError(t, new(PolicyContract).SetOperationPolicy(l.ctx, `{"operation":"UpdateDid","maxDocumentSize":100}`))
+
+	l.stub.GetFunctionAndParametersReturns("CreateDid", []string{})
+	_, err := s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject dids larger than the policy allows")
+
+	l.stub.GetFunctionAndParametersReturns("UpdateDid", []string{})
+	update := testUpdate(id, key, "https://example.org/vc/")
+	err = updateDid(l, id, update, signUpdate(t, l, key, id, update))
+	assertErrorCode(t, err, codeInvalidArgument, "should check the size of updated dids")
+
+	l.stub.GetFunctionAndParametersReturns("RotateKey", []string{})
+	update = testUpdate(id, key, "https://example.org/vc/")
+	err = updateDid(l, id, update, signUpdate(t, l, key, id, update))
+	require.NoError(t, err, "should apply the policy of the called function only")
+}
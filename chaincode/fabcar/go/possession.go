@@ -0,0 +1,217 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// DidUpdatePayload describes the message signed with the current authentication
+// key of a did to prove possession of it when changing the did
+type DidUpdatePayload struct {
+	DidNumber    string `json:"didNumber"`
+	PreviousTxId string `json:"previousTxId"`
+	Document     *Did   `json:"document"`
+}
+
+// updatableDetails returns a copy of the details of the did that an update replaces
+func updatableDetails(did *Did) Did {
+	return Did{
+		AuthenticationId:                 did.AuthenticationId,
+		AuthenticationType:               did.AuthenticationType,
+		AuthenticationController:         did.AuthenticationController,
+		AuthenticationPublicKeyPerm:      did.AuthenticationPublicKeyPerm,
+		AuthenticationPublicKeyMultibase: did.AuthenticationPublicKeyMultibase,
+		AuthenticationPublicKeyJwk:       did.AuthenticationPublicKeyJwk,
+		VerificationMethods:              did.VerificationMethods,
+		RecoveryMethods:                  did.RecoveryMethods,
+		Multisig:                         did.Multisig,
+		ServiceId:                        did.ServiceId,
+		ServiceType:                      did.ServiceType,
+		ServiceEndPoint:                  did.ServiceEndPoint,
+		ServiceEndPointHash:              did.ServiceEndPointHash,
+		ServiceEndpoints:                 did.ServiceEndpoints,
+		EncryptedServiceEndPoint:         did.EncryptedServiceEndPoint,
+		Deactivated:                      did.Deactivated,
+		Expires:                          did.Expires,
+		Deleted:                          did.Deleted,
+		Metadata:                         did.Metadata,
+	}
+}
+
+// lastTxId returns the id of the transaction that last changed the did
+func lastTxId(did *Did) string {
+	if did.Provenance == nil {
+		return ""
+	}
+
+	if did.Provenance.Updated != nil {
+		return did.Provenance.Updated.TxID
+	}
+
+	if did.Provenance.Created != nil {
+		return did.Provenance.Created.TxID
+	}
+
+	return ""
+}
+
+// didUpdatePayload returns the canonical JSON, with object members ordered by
+// key, of the DidUpdatePayload for changing did to the details of update.
+// Including the id of the last transaction prevents a signature being replayed
+func didUpdatePayload(didNumber string, did *Did, update *Did) ([]byte, error) {
+	document := updatableDetails(update)
+	document.Id = did.Id
+	document.ServiceEndPointHash = ""
+
+	payload := DidUpdatePayload{
+		DidNumber:    didNumber,
+		PreviousTxId: lastTxId(did),
+		Document:     &document,
+	}
+
+	payloadAsBytes, err := json.Marshal(payload)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return signingPayload(string(payloadAsBytes))
+}
+
+// currentPublicKey returns the authentication public key of the did, reading it
+// from the private data collection when only its hash is on the public ledger
+func currentPublicKey(ctx contractapi.TransactionContextInterface, didNumber string, did *Did) (crypto.PublicKey, error) {
+	if did.AuthenticationPublicKeyPerm != "" || did.AuthenticationPublicKeyHash == "" {
+		return publicKeyOf(did, did.AuthenticationId)
+	}
+
+	detailsAsBytes, err := ctx.GetStub().GetPrivateData(didPrivateCollection, didNumber)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from private data collection. %s", err.Error())
+	}
+
+	if detailsAsBytes == nil {
+		return nil, newError(codeNotFound, "%s has no private details", didNumber)
+	}
+
+	details := new(DidPrivateDetails)
+
+	if err := unmarshalRecord(didNumber, detailsAsBytes, details); err != nil {
+		return nil, err
+	}
+
+	if hashValue(details.AuthenticationPublicKeyPerm) != did.AuthenticationPublicKeyHash {
+		return nil, newError(codeCorruptRecord, "Private details of %s do not match the public hash", didNumber)
+	}
+
+	withKey := *did
+	withKey.AuthenticationPublicKeyPerm = details.AuthenticationPublicKeyPerm
+	if err := normalizePublicKey(&withKey); err != nil {
+		return nil, err
+	}
+
+	return publicKeyOf(&withKey, did.AuthenticationId)
+}
+
+// verifyKeyPossession checks that signature is a base64 encoded signature over the
+// update payload made with the current authentication key of the did, so that
+// write access to the ledger alone is not enough to take over a did
+func verifyKeyPossession(ctx contractapi.TransactionContextInterface, didNumber string, did *Did, update *Did, signature string) error {
+	return verifySignedUpdate(ctx, didNumber, did, didNumber, did, update, signature)
+}
+
+// verifySignedUpdate checks that signature is a base64 encoded signature over the
+// update payload of did made with the current authentication key of the signer
+// did stored with signerNumber, which differs from did for delegated updates
+func verifySignedUpdate(ctx contractapi.TransactionContextInterface, signerNumber string, signer *Did, didNumber string, did *Did, update *Did, signature string) error {
+	signatureAsBytes, err := decodeUpdateSignature("the current authentication key of "+signerNumber, signature)
+
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := currentPublicKey(ctx, signerNumber, signer)
+
+	if err != nil {
+		return err
+	}
+
+	return verifyUpdatePayload(publicKey, "the authentication key of "+signerNumber, didNumber, did, update, signatureAsBytes)
+}
+
+// decodeUpdateSignature decodes the base64 encoded signature of an update, which
+// must be made with the named key
+func decodeUpdateSignature(keyName string, signature string) ([]byte, error) {
+	if signature == "" {
+		return nil, newError(codeInvalidSignature, "A signature made with %s is required", keyName)
+	}
+
+	signatureAsBytes, err := base64.StdEncoding.DecodeString(signature)
+
+	if err != nil {
+		return nil, newError(codeInvalidSignature, "Signature must be base64 encoded. %s", err.Error())
+	}
+
+	return signatureAsBytes, nil
+}
+
+// verifyUpdatePayload verifies a signature over the update payload of did made
+// with the private key matching publicKey, the named key
+func verifyUpdatePayload(publicKey crypto.PublicKey, keyName string, didNumber string, did *Did, update *Did, signature []byte) error {
+	payload, err := didUpdatePayload(didNumber, did, update)
+
+	if err != nil {
+		return fmt.Errorf("Failed to build update payload. %s", err.Error())
+	}
+
+	if err := verifySignature(publicKey, payload, signature); err != nil {
+		return newError(codeInvalidSignature, "Signature does not prove possession of %s. %s", keyName, err.Error())
+	}
+
+	return nil
+}
+
+// RotateKey replaces the authentication key of a did, keeping its other details.
+// The signature must be made with the current authentication key over the update
+// payload of the rotated document
+func (s *DidContract) RotateKey(ctx contractapi.TransactionContextInterface, didNumber string, authenticationId string, authenticationType string,
+	authenticationPublicKeyPerm string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.AuthenticationId = authenticationId
+	update.AuthenticationType = authenticationType
+	update.AuthenticationPublicKeyPerm = authenticationPublicKeyPerm
+	update.AuthenticationPublicKeyMultibase = ""
+	update.AuthenticationPublicKeyJwk = nil
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
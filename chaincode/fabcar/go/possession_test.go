@@ -0,0 +1,66 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotateKey(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	next := newTestKey(t)
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.AuthenticationId = id + "#keys-2"
+	update.AuthenticationPublicKeyPerm = next.pem
+
+	err = s.RotateKey(l.ctx, id, id+"#keys-2", testKeyType, next.pem, signUpdate(t, l, next, id, &update))
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature of the current key")
+
+	err = s.RotateKey(l.ctx, id, id+"#keys-2", testKeyType, next.pem, signUpdate(t, l, key, id, &update))
+	require.NoError(t, err, "should rotate the key")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id+"#keys-2", did.AuthenticationId, "should store the new key id")
+	assert.Equal(t, next.pem, did.AuthenticationPublicKeyPerm, "should store the new key")
+	assert.Equal(t, testEndpoint, did.ServiceEndPoint, "should keep the other details")
+	l.nextTx()
+
+	change := testUpdate(id, next, "https://example.org/vc/")
+	change.AuthenticationId = id + "#keys-2"
+
+	err = updateDid(l, id, change, signUpdate(t, l, key, id, change))
+	assertErrorCode(t, err, codeInvalidSignature, "should no longer accept the old key")
+
+	err = updateDid(l, id, change, signUpdate(t, l, next, id, change))
+	assert.Nil(t, err, "should accept the new key")
+
+	err = s.RotateKey(l.ctx, "DID9", id+"#keys-3", testKeyType, next.pem, "")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+}
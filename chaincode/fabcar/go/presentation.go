@@ -0,0 +1,130 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// VerifiablePresentation describes the members of a verifiable presentation used
+// during verification
+type VerifiablePresentation struct {
+	Id                   string            `json:"id,omitempty" metadata:"id,optional"`
+	Type                 []string          `json:"type"`
+	Holder               string            `json:"holder"`
+	VerifiableCredential []json.RawMessage `json:"verifiableCredential"`
+	Proof                *Proof            `json:"proof"`
+}
+
+// PresentationVerificationResult describes the outcome of verifying a
+// presentation and each credential it contains
+type PresentationVerificationResult struct {
+	Verified    bool                  `json:"verified"`
+	Checks      []VerificationCheck   `json:"checks"`
+	Credentials []*VerificationResult `json:"credentials"`
+}
+
+// VerifyPresentation verifies a verifiable presentation against the registry. The
+// holder did must not be deactivated, the holder's proof must be an authentication
+// proof bound to challenge and domain and made with an authentication key of the
+// holder did, and every embedded credential must pass VerifyCredential
+func (c *CredentialContract) VerifyPresentation(ctx contractapi.TransactionContextInterface, vpJSON string, challenge string, domain string) (*PresentationVerificationResult, error) {
+	holderResult := VerificationResult{Verified: true, Checks: []VerificationCheck{}}
+	result := PresentationVerificationResult{Credentials: []*VerificationResult{}}
+
+	verifyPresentationProof(ctx, vpJSON, challenge, domain, &holderResult)
+
+	result.Verified = holderResult.Verified
+	result.Checks = holderResult.Checks
+
+	vp := new(VerifiablePresentation)
+
+	if err := json.Unmarshal([]byte(vpJSON), vp); err != nil {
+		return &result, nil
+	}
+
+	for _, vcJSON := range vp.VerifiableCredential {
+		credentialResult := VerificationResult{Verified: true, Checks: []VerificationCheck{}}
+
+		verifyCredential(ctx, string(vcJSON), &credentialResult)
+
+		result.Verified = result.Verified && credentialResult.Verified
+		result.Credentials = append(result.Credentials, &credentialResult)
+	}
+
+	return &result, nil
+}
+
+// verifyPresentationProof verifies the holder's proof of a presentation and adds
+// the outcome of each step to result
+func verifyPresentationProof(ctx contractapi.TransactionContextInterface, vpJSON string, challenge string, domain string, result *VerificationResult) {
+	vp := new(VerifiablePresentation)
+
+	if !result.addCheck("format", json.Unmarshal([]byte(vpJSON), vp)) {
+		return
+	}
+
+	holder, err := findDidById(ctx, vp.Holder)
+
+	if err == nil && holder.Record.Deactivated {
+		err = newError(codeDidDeactivated, "%s is deactivated", vp.Holder)
+	}
+
+	if !result.addCheck("holder", err) {
+		return
+	}
+
+	if vp.Proof == nil {
+		result.addCheck("proof", fmt.Errorf("Presentation has no proof"))
+		return
+	}
+
+	if vp.Proof.ProofPurpose != "authentication" {
+		result.addCheck("proofPurpose", fmt.Errorf("Proof purpose must be authentication, not %s", vp.Proof.ProofPurpose))
+		return
+	}
+
+	if vp.Proof.Challenge != challenge {
+		result.addCheck("challenge", fmt.Errorf("Proof challenge does not match"))
+		return
+	}
+
+	if vp.Proof.Domain != domain {
+		result.addCheck("domain", fmt.Errorf("Proof domain does not match"))
+		return
+	}
+
+	if !strings.HasPrefix(vp.Proof.VerificationMethod, vp.Holder+"#") {
+		result.addCheck("verificationMethod", fmt.Errorf("Verification method %s does not belong to holder %s", vp.Proof.VerificationMethod, vp.Holder))
+		return
+	}
+
+	payload, err := signingPayload(vpJSON)
+
+	if err == nil {
+		err = verifyJws(holder.Record, vp.Proof, payload)
+	}
+
+	result.addCheck("proof", err)
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testPresentation returns a presentation of holder signed with key, carrying vcs
+func testPresentation(t *testing.T, key *testKey, holder string, challenge string, domain string, vcs ...string) string {
+	credentials := []json.RawMessage{}
+
+	for _, vc := range vcs {
+		credentials = append(credentials, json.RawMessage(vc))
+	}
+
+	vp := map[string]interface{}{
+		"type":                 []string{"VerifiablePresentation"},
+		"holder":               holder,
+		"verifiableCredential": credentials,
+	}
+
+	proof := Proof{Type: "JsonWebSignature2020", VerificationMethod: holder + "#keys-1", ProofPurpose: "authentication", Challenge: challenge, Domain: domain}
+
+	return signDocument(t, key, vp, proof)
+}
+
+func TestVerifyPresentation(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuerKey := newTestKey(t)
+	issuer := createTestDid(t, l, issuerKey)
+	holderKey := newTestKey(t)
+	holder := createTestDid(t, l, holderKey)
+
+	vc := signDocument(t, issuerKey, testCredential(issuer, holder), assertionProof(issuer))
+
+	result, err := c.VerifyPresentation(l.ctx, testPresentation(t, holderKey, holder, "nonce", "example.com", vc), "nonce", "example.com")
+	require.NoError(t, err, "should not fail the transaction")
+	assert.True(t, result.Verified, "should verify the presentation: %v", result.Checks)
+	require.Len(t, result.Credentials, 1, "should verify every credential")
+	assert.True(t, result.Credentials[0].Verified, "should verify the embedded credential")
+
+	result, err = c.VerifyPresentation(l.ctx, testPresentation(t, holderKey, holder, "nonce", "example.com", vc), "other", "example.com")
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should require the expected challenge")
+
+	result, err = c.VerifyPresentation(l.ctx, testPresentation(t, holderKey, holder, "nonce", "example.com", vc), "nonce", "example.org")
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should require the expected domain")
+
+	result, err = c.VerifyPresentation(l.ctx, testPresentation(t, issuerKey, holder, "nonce", "example.com", vc), "nonce", "example.com")
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should require a signature of the holder")
+	assert.True(t, result.Credentials[0].Verified, "should still verify the embedded credential")
+
+	forged := signDocument(t, holderKey, testCredential(issuer, holder), assertionProof(issuer))
+
+	result, err = c.VerifyPresentation(l.ctx, testPresentation(t, holderKey, holder, "nonce", "example.com", forged), "nonce", "example.com")
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should not verify presentations of forged credentials")
+	assert.False(t, result.Credentials[0].Verified, "should not verify the forged credential")
+
+	result, err = c.VerifyPresentation(l.ctx, "[]", "nonce", "example.com")
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should reject invalid presentations")
+	assert.Empty(t, result.Credentials, "should not verify credentials of invalid presentations")
+}
+
+func TestVerifyPresentationDeactivatedHolder(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	c := new(CredentialContract)
+	holderKey := newTestKey(t)
+	holder := createTestDid(t, l, holderKey)
+	vp := testPresentation(t, holderKey, holder, "nonce", "example.com")
+
+	did, err := s.QueryDidByKey(l.ctx, holder)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, holder, signUpdate(t, l, holderKey, holder, &update)))
+	l.nextTx()
+
+	result, err := c.VerifyPresentation(l.ctx, vp, "nonce", "example.com")
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should not verify presentations of deactivated holders")
+	assert.False(t, checkOf(t, &VerificationResult{Checks: result.Checks}, "holder").Passed, "should fail the holder check")
+}
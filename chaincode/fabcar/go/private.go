@@ -0,0 +1,154 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// didPrivateCollection is the private data collection holding sensitive did
+// details. It must match the name used in collections_config.json
+const didPrivateCollection = "didPrivateCollection"
+
+// DidPrivateDetails describes the details of a did kept out of the public world state
+type DidPrivateDetails struct {
+	AuthenticationPublicKeyPerm string `json:"authenticationPublicKeyPerm"`
+}
+
+// hashValue returns the hex encoded SHA-256 hash of value
+func hashValue(value string) string {
+	hash := sha256.Sum256([]byte(value))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateDidPrivate adds a new did to the world state, keeping the authentication
+// public key in the private data collection and only its hash on the public ledger
+func (s *DidContract) CreateDidPrivate(ctx contractapi.TransactionContextInterface, didNumber string, id string, authenticationId string, authenticationType string,
+	authenticationController string, authenticationPublicKeyPerm string, serviceId string, serviceType string, serviceEndPoint string) error {
+	did := Did{
+		Id:                          id,
+		AuthenticationId:            authenticationId,
+		AuthenticationType:          authenticationType,
+		AuthenticationController:    authenticationController,
+		AuthenticationPublicKeyPerm: authenticationPublicKeyPerm,
+		ServiceId:                   serviceId,
+		ServiceType:                 serviceType,
+		ServiceEndPoint:             serviceEndPoint,
+	}
+
+	if err := admitRegistration(ctx, 1); err != nil {
+		return err
+	}
+
+	return s.createDidPrivate(ctx, didNumber, &did)
+}
+
+// createDidPrivate stores a new did whose authentication public key is moved into
+// the private data collection
+func (s *DidContract) createDidPrivate(ctx contractapi.TransactionContextInterface, didNumber string, did *Did) error {
+	err := putPrivateDetails(ctx, didNumber, did)
+
+	if err != nil {
+		return err
+	}
+
+	return s.createDid(ctx, didNumber, did)
+}
+
+// putPrivateDetails writes the authentication public key of the did to the private
+// data collection and replaces it in the public document with its hash
+func putPrivateDetails(ctx contractapi.TransactionContextInterface, didNumber string, did *Did) error {
+	if _, err := parseKeyOfType(did.AuthenticationId, did.AuthenticationType, did.AuthenticationPublicKeyPerm); err != nil {
+		return err
+	}
+
+	details := DidPrivateDetails{AuthenticationPublicKeyPerm: did.AuthenticationPublicKeyPerm}
+	detailsAsBytes, err := marshalRecord(didNumber, details)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutPrivateData(didPrivateCollection, didNumber, detailsAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to private data collection. %s", err.Error())
+	}
+
+	if err := setPrivateOwnerEndorsement(ctx, didPrivateCollection, didNumber); err != nil {
+		return err
+	}
+
+	did.AuthenticationPublicKeyHash = hashValue(did.AuthenticationPublicKeyPerm)
+	did.AuthenticationPublicKeyPerm = ""
+
+	return nil
+}
+
+// QueryDidPrivate returns the did stored with given key including the details held
+// in the private data collection. The private details are checked against the hash
+// stored on the public ledger. Organizations other than the owner of the did
+// need an active sharing agreement covering the authentication key
+func (s *DidContract) QueryDidPrivate(ctx contractapi.TransactionContextInterface, didNumber string) (*Did, error) {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	agreement, err := readerAgreement(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if agreement != nil && !agreement.covers(authenticationKeyField) {
+		return nil, newError(codeUnauthorized, "%s does not share %s with %s", agreement.OwnerMSPID, authenticationKeyField, agreement.RecipientMSPID)
+	}
+
+	detailsAsBytes, err := ctx.GetStub().GetPrivateData(didPrivateCollection, didNumber)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from private data collection. %s", err.Error())
+	}
+
+	if detailsAsBytes == nil {
+		return nil, newError(codeNotFound, "%s has no private details", didNumber)
+	}
+
+	details := new(DidPrivateDetails)
+
+	if err := unmarshalRecord(didNumber, detailsAsBytes, details); err != nil {
+		return nil, err
+	}
+
+	if hashValue(details.AuthenticationPublicKeyPerm) != did.AuthenticationPublicKeyHash {
+		return nil, newError(codeCorruptRecord, "Private details of %s do not match the public hash", didNumber)
+	}
+
+	did.AuthenticationPublicKeyPerm = details.AuthenticationPublicKeyPerm
+
+	return did, nil
+}
@@ -0,0 +1,105 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testPrivateDid = "did:example:private"
+
+// createPrivateDid creates the did testPrivateDid under DID5 keeping key in the
+// private data collection
+func createPrivateDid(t *testing.T, l *testLedger, key *testKey) {
+	err := new(DidContract).CreateDidPrivate(l.ctx, "DID5", testPrivateDid, testPrivateDid+"#keys-1", testKeyType, testPrivateDid,
+		key.pem, testPrivateDid+"#vcs", "VerifiableCredentialService", testEndpoint)
+	require.NoError(t, err)
+
+	l.nextTx()
+}
+
+func TestCreateDidPrivate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	createPrivateDid(t, l, key)
+
+	did, err := s.QueryDidByKey(l.ctx, "DID5")
+	require.NoError(t, err)
+	assert.Equal(t, "", did.AuthenticationPublicKeyPerm, "should not store the key on the public ledger")
+	assert.Equal(t, hashValue(key.pem), did.AuthenticationPublicKeyHash, "should store the hash of the key")
+	assert.NotNil(t, l.private[didPrivateCollection]["DID5"], "should store the key in the private data collection")
+
+	err = s.CreateDidPrivate(l.ctx, "DID6", "did:example:private2", "did:example:private2#keys-1", testKeyType, "did:example:private2",
+		"not a key", "", "", "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject keys that do not parse")
+
+	l.stub.PutPrivateDataReturns(errors.New("PutPrivateData error"))
+	err = s.CreateDidPrivate(l.ctx, "DID6", "did:example:private2", "did:example:private2#keys-1", testKeyType, "did:example:private2",
+		key.pem, "", "", "")
+	assert.EqualError(t, err, "Failed to put to private data collection. PutPrivateData error", "should return private data errors")
+}
+
+func TestQueryDidPrivate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	createPrivateDid(t, l, key)
+
+	did, err := s.QueryDidPrivate(l.ctx, "DID5")
+	assert.Nil(t, err, "should return the did with its private details")
+	assert.Equal(t, key.pem, did.AuthenticationPublicKeyPerm, "should return the private key")
+
+	l.private[didPrivateCollection]["DID5"] = []byte(`{"authenticationPublicKeyPerm":"other"}`)
+	_, err = s.QueryDidPrivate(l.ctx, "DID5")
+	assertErrorCode(t, err, codeCorruptRecord, "should check the private details against the public hash")
+
+	delete(l.private[didPrivateCollection], "DID5")
+	_, err = s.QueryDidPrivate(l.ctx, "DID5")
+	assertErrorCode(t, err, codeNotFound, "should fail for dids without private details")
+
+	l.stub.GetPrivateDataReturns(nil, errors.New("GetPrivateData error"))
+	_, err = s.QueryDidPrivate(l.ctx, "DID5")
+	assert.EqualError(t, err, "Failed to read from private data collection. GetPrivateData error", "should return private data errors")
+}
+
+func TestUpdateDidPrivate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	createPrivateDid(t, l, key)
+	next := newTestKey(t)
+
+	update := testUpdate(testPrivateDid, next, "https://example.org/vc/")
+
+	err := updateDid(l, "DID5", update, signUpdate(t, l, next, "DID5", update))
+	assertErrorCode(t, err, codeInvalidSignature, "should verify against the private key")
+
+	err = updateDid(l, "DID5", update, signUpdate(t, l, key, "DID5", update))
+	require.NoError(t, err, "should update dids with a private key")
+
+	did, err := s.QueryDidPrivate(l.ctx, "DID5")
+	require.NoError(t, err)
+	assert.Equal(t, next.pem, did.AuthenticationPublicKeyPerm, "should keep the new key private")
+	assert.Equal(t, hashValue(next.pem), did.AuthenticationPublicKeyHash, "should store the hash of the new key")
+}
@@ -0,0 +1,381 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// proposalObjectType is the composite key object type under which the pending
+// update proposals of dids are stored
+const proposalObjectType = "proposal"
+
+// updateProposalTTL is how long an update proposal may wait for approval
+const updateProposalTTL = 7 * 24 * time.Hour
+
+// ProposalApproval records that an approver did signed an update proposal
+type ProposalApproval struct {
+	Approver   string           `json:"approver"`
+	ApprovedBy *ProvenanceEntry `json:"approvedBy"`
+}
+
+// DidUpdateProposal is a change of a did waiting for the approval of its
+// approvers. The proposal lapses when it expires or when the did is changed
+// after PreviousTxId
+type DidUpdateProposal struct {
+	DidNumber    string             `json:"didNumber"`
+	Document     *Did               `json:"document"`
+	PreviousTxId string             `json:"previousTxId"`
+	Approvers    []string           `json:"approvers"`
+	Threshold    int                `json:"threshold"`
+	Approvals    []ProposalApproval `json:"approvals"`
+	ProposedBy   *ProvenanceEntry   `json:"proposedBy"`
+	Expires      string             `json:"expires"`
+}
+
+// proposalKey returns the key of the update proposal of the did stored with
+// given key
+func proposalKey(ctx contractapi.TransactionContextInterface, didNumber string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(proposalObjectType, []string{didNumber})
+}
+
+// getPendingProposal reads the update proposal of the did stored with given
+// key, returning nil if there is none or it lapsed
+func getPendingProposal(ctx contractapi.TransactionContextInterface, didNumber string, did *Did) (*DidUpdateProposal, error) {
+	key, err := proposalKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	proposalAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if proposalAsBytes == nil {
+		return nil, nil
+	}
+
+	proposal := new(DidUpdateProposal)
+
+	if err := unmarshalRecord(key, proposalAsBytes, proposal); err != nil {
+		return nil, err
+	}
+
+	if proposal.PreviousTxId != lastTxId(did) {
+		return nil, nil
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	expiry, err := time.Parse(time.RFC3339, proposal.Expires)
+
+	if err != nil || !expiry.After(now) {
+		return nil, nil
+	}
+
+	return proposal, nil
+}
+
+// putProposal writes the update proposal of a did
+func putProposal(ctx contractapi.TransactionContextInterface, proposal *DidUpdateProposal) error {
+	key, err := proposalKey(ctx, proposal.DidNumber)
+
+	if err != nil {
+		return err
+	}
+
+	proposalAsBytes, err := marshalRecord(key, proposal)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, proposalAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// deleteProposal removes the update proposal of the did stored with given key
+func deleteProposal(ctx contractapi.TransactionContextInterface, didNumber string) error {
+	key, err := proposalKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// proposalApprovers returns the dids that approve update proposals of the did
+// and how many of them must approve, the controllers of its multisig policy or
+// else the did itself
+func proposalApprovers(did *Did) ([]string, int) {
+	if did.Multisig != nil {
+		return did.Multisig.Controllers, did.Multisig.Threshold
+	}
+
+	return []string{did.Id}, 1
+}
+
+// assertApprover returns the stored approver did if it approves update
+// proposals of the did stored with given key and the submitting client controls it
+func assertApprover(ctx contractapi.TransactionContextInterface, didNumber string, did *Did, proposal *DidUpdateProposal, approverDid string) (*QueryResult, error) {
+	if !containsString(proposal.Approvers, approverDid) {
+		return nil, newError(codeUnauthorized, "%s is not an approver of %s", approverDid, didNumber)
+	}
+
+	approver := newQueryResult(didNumber, did)
+
+	if approverDid != did.Id {
+		var err error
+
+		if approver, err = findDidById(ctx, approverDid); err != nil {
+			return nil, err
+		}
+
+		if approver.Record.Deactivated {
+			return nil, newError(codeDidDeactivated, "%s is deactivated", approverDid)
+		}
+	}
+
+	if err := assertController(ctx, approver.Key, approver.Record); err != nil {
+		return nil, err
+	}
+
+	return approver, nil
+}
+
+// ProposeDidUpdate stores a change of the did stored with given key for review.
+// The document is a JSON object of the updatable details of the did, members it
+// omits keep their current values. The change is applied once enough approvers
+// accepted it with ApproveDidUpdate, the controllers of the multisig policy of
+// the did or else the did itself. A did has at most one pending proposal, which
+// lapses after a week or when the did is changed in another way. Only the
+// controlling client identity of the did may propose changes
+func (s *DidContract) ProposeDidUpdate(ctx contractapi.TransactionContextInterface, didNumber string, document string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", didNumber)
+	}
+
+	pending, err := getPendingProposal(ctx, didNumber, did)
+
+	if err != nil {
+		return err
+	}
+
+	if pending != nil {
+		return newError(codeConflict, "%s already has a pending update proposal, which expires %s", didNumber, pending.Expires)
+	}
+
+	proposed := updatableDetails(did)
+
+	if err := decodeStrict([]byte(document), &proposed); err != nil {
+		return newError(codeInvalidArgument, "Document must be a JSON object of the updatable details of the did. %s", err.Error())
+	}
+
+	update := updatableDetails(&proposed)
+	update.Expires = did.Expires
+	update.Deleted = did.Deleted
+
+	if update.ServiceEndPoint != did.ServiceEndPoint {
+		update.ServiceEndPointHash = ""
+	}
+
+	candidate := *did
+
+	if err := mergeUpdate(ctx, &candidate, &update); err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	proposal := DidUpdateProposal{
+		DidNumber:    didNumber,
+		Document:     &update,
+		PreviousTxId: lastTxId(did),
+		Approvals:    []ProposalApproval{},
+		Expires:      now.Add(updateProposalTTL).Format(time.RFC3339),
+	}
+	proposal.Approvers, proposal.Threshold = proposalApprovers(did)
+
+	proposal.ProposedBy, err = newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if err := putProposal(ctx, &proposal); err != nil {
+		return err
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// ApproveDidUpdate accepts the pending update proposal of the did stored with
+// given key on behalf of approverDid. The submitting client must control the
+// approver did and the signature must be made with its authentication key over
+// the update payload of the proposed change. The approval completing the
+// threshold applies the change, so it must be submitted by a client of the
+// organization that holds the did
+func (s *DidContract) ApproveDidUpdate(ctx contractapi.TransactionContextInterface, didNumber string, approverDid string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	proposal, err := getPendingProposal(ctx, didNumber, did)
+
+	if err != nil {
+		return err
+	}
+
+	if proposal == nil {
+		return newError(codeNotFound, "%s has no pending update proposal", didNumber)
+	}
+
+	approver, err := assertApprover(ctx, didNumber, did, proposal, approverDid)
+
+	if err != nil {
+		return err
+	}
+
+	for _, approval := range proposal.Approvals {
+		if approval.Approver == approverDid {
+			return newError(codeConflict, "%s already approved the update proposal of %s", approverDid, didNumber)
+		}
+	}
+
+	if err := verifySignedUpdate(ctx, approver.Key, approver.Record, didNumber, did, proposal.Document, signature); err != nil {
+		return err
+	}
+
+	approval := ProposalApproval{Approver: approverDid}
+
+	approval.ApprovedBy, err = newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	proposal.Approvals = append(proposal.Approvals, approval)
+
+	if len(proposal.Approvals) < proposal.Threshold {
+		if err := putProposal(ctx, proposal); err != nil {
+			return err
+		}
+
+		return putAuditRecord(ctx, didNumber)
+	}
+
+	if err := deleteProposal(ctx, didNumber); err != nil {
+		return err
+	}
+
+	did.Deactivated = proposal.Document.Deactivated
+
+	return applyUpdate(ctx, didNumber, did, proposal.Document, "")
+}
+
+// RejectDidUpdate discards the pending update proposal of the did stored with
+// given key on behalf of approverDid, whose controlling client must submit it
+func (s *DidContract) RejectDidUpdate(ctx contractapi.TransactionContextInterface, didNumber string, approverDid string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	proposal, err := getPendingProposal(ctx, didNumber, did)
+
+	if err != nil {
+		return err
+	}
+
+	if proposal == nil {
+		return newError(codeNotFound, "%s has no pending update proposal", didNumber)
+	}
+
+	if _, err := assertApprover(ctx, didNumber, did, proposal, approverDid); err != nil {
+		return err
+	}
+
+	if err := deleteProposal(ctx, didNumber); err != nil {
+		return err
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// QueryDidUpdateProposal returns the pending update proposal of the did stored
+// with given key. Lapsed proposals are not found
+func (s *DidContract) QueryDidUpdateProposal(ctx contractapi.TransactionContextInterface, didNumber string) (*DidUpdateProposal, error) {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	proposal, err := getPendingProposal(ctx, didNumber, did)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if proposal == nil {
+		return nil, newError(codeNotFound, "%s has no pending update proposal", didNumber)
+	}
+
+	return proposal, nil
+}
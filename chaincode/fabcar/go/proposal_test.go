@@ -0,0 +1,201 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testProposal returns the pending update proposal of the did
+func testProposal(t *testing.T, l *testLedger, didNumber string) *DidUpdateProposal {
+	proposal, err := new(DidContract).QueryDidUpdateProposal(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	return proposal
+}
+
+func TestProposeDidUpdate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	l.setClient(otherClientID, testMSPID)
+	err := s.ProposeDidUpdate(l.ctx, id, `{"serviceEndPoint":"https://example.org/vc/"}`)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller propose changes")
+
+	l.setClient(testClientID, testMSPID)
+
+	invalid := map[string]string{
+		"should require a JSON object":        `"https://example.org/vc/"`,
+		"should reject unknown members":       `{"endpoint":"https://example.org/vc/"}`,
+		"should validate the proposed change": `{"serviceEndPoint":"http://example.org/vc/"}`,
+	}
+
+	for message, document := range invalid {
+		assertErrorCode(t, s.ProposeDidUpdate(l.ctx, id, document), codeInvalidArgument, message)
+	}
+
+	_, err = s.QueryDidUpdateProposal(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should not store rejected proposals")
+
+	require.NoError(t, s.ProposeDidUpdate(l.ctx, id, `{"serviceEndPoint":"https://example.org/vc/"}`), "should store the proposal")
+	l.nextTx()
+
+	proposal := testProposal(t, l, id)
+	assert.Equal(t, "https://example.org/vc/", proposal.Document.ServiceEndPoint, "should store the proposed change")
+	assert.Equal(t, key.pem, proposal.Document.AuthenticationPublicKeyPerm, "should keep the details the document omits")
+	assert.Equal(t, []string{id}, proposal.Approvers, "should let the did approve its own changes")
+	assert.Equal(t, 1, proposal.Threshold, "should require one approval without a multisig policy")
+	assert.Equal(t, "tx1", proposal.PreviousTxId, "should record the version of the did")
+	assert.Equal(t, testStart.Add(2*time.Second).Add(updateProposalTTL).Format(time.RFC3339), proposal.Expires, "should expire after a week")
+	assert.Equal(t, testClientID, proposal.ProposedBy.ClientID, "should record the proposing client")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, testEndpoint, did.ServiceEndPoint, "should not change the did before approval")
+
+	err = s.ProposeDidUpdate(l.ctx, id, `{"serviceId":"#other"}`)
+	assertErrorCode(t, err, codeConflict, "should keep one pending proposal per did")
+
+	err = s.ApproveDidUpdate(l.ctx, id, id, signUpdate(t, l, newTestKey(t), id, proposal.Document))
+	assertErrorCode(t, err, codeInvalidSignature, "should require the signature of the approver")
+
+	require.NoError(t, s.ApproveDidUpdate(l.ctx, id, id, signUpdate(t, l, key, id, proposal.Document)), "should apply approved changes")
+	l.nextTx()
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/vc/", did.ServiceEndPoint, "should apply the proposed change")
+
+	_, err = s.QueryDidUpdateProposal(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should remove applied proposals")
+
+	err = s.ApproveDidUpdate(l.ctx, id, id, signUpdate(t, l, key, id, proposal.Document))
+	assertErrorCode(t, err, codeNotFound, "should not apply a proposal twice")
+}
+
+func TestApproveDidUpdate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	secondKey := newTestKey(t)
+	second := createTestDid(t, l, secondKey)
+	thirdKey := newTestKey(t)
+	third := createTestDid(t, l, thirdKey)
+	outsider := newTestKey(t)
+	outsiderId := createTestDid(t, l, outsider)
+
+	l.setClient(otherClientID, testMSPID)
+	foreignKey := newTestKey(t)
+	foreign := createTestDid(t, l, foreignKey)
+	l.setClient(testClientID, testMSPID)
+
+	setTestMultisigPolicy(t, l, key, id, []string{id, second, third, foreign}, 2)
+
+	rotated := newTestKey(t)
+	document, err := json.Marshal(rotation(t, l, id, rotated))
+	require.NoError(t, err)
+
+	require.NoError(t, s.ProposeDidUpdate(l.ctx, id, string(document)))
+	l.nextTx()
+
+	proposal := testProposal(t, l, id)
+	assert.Equal(t, []string{id, second, third, foreign}, proposal.Approvers, "should let the controllers approve changes")
+	assert.Equal(t, 2, proposal.Threshold, "should require the threshold of the policy")
+
+	err = s.ApproveDidUpdate(l.ctx, id, outsiderId, signUpdate(t, l, outsider, id, proposal.Document))
+	assertErrorCode(t, err, codeUnauthorized, "should only accept approvers")
+
+	err = s.ApproveDidUpdate(l.ctx, id, foreign, signUpdate(t, l, foreignKey, id, proposal.Document))
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the approver")
+
+	err = s.ApproveDidUpdate(l.ctx, id, second, signUpdate(t, l, thirdKey, id, proposal.Document))
+	assertErrorCode(t, err, codeInvalidSignature, "should verify the signature with the key of the approver")
+
+	require.NoError(t, s.ApproveDidUpdate(l.ctx, id, second, signUpdate(t, l, secondKey, id, proposal.Document)), "should record the approval")
+	l.nextTx()
+
+	proposal = testProposal(t, l, id)
+	require.Len(t, proposal.Approvals, 1, "should keep the proposal pending below the threshold")
+	assert.Equal(t, second, proposal.Approvals[0].Approver, "should record the approver")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, key.pem, did.AuthenticationPublicKeyPerm, "should not change the did below the threshold")
+
+	err = s.ApproveDidUpdate(l.ctx, id, second, signUpdate(t, l, secondKey, id, proposal.Document))
+	assertErrorCode(t, err, codeConflict, "should not count an approver twice")
+
+	require.NoError(t, s.ApproveDidUpdate(l.ctx, id, third, signUpdate(t, l, thirdKey, id, proposal.Document)), "should apply the change at the threshold")
+	l.nextTx()
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id+"#keys-2", did.AuthenticationId, "should rotate the key")
+	assert.Equal(t, rotated.pem, did.AuthenticationPublicKeyPerm, "should rotate the key")
+}
+
+func TestUpdateProposalLapses(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	require.NoError(t, s.ProposeDidUpdate(l.ctx, id, `{"serviceEndPoint":"https://example.org/vc/"}`))
+	l.nextTx()
+	proposal := testProposal(t, l, id)
+
+	l.setTime(testStart.Add(updateProposalTTL + time.Hour))
+	_, err := s.QueryDidUpdateProposal(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should expire stale proposals")
+
+	err = s.ApproveDidUpdate(l.ctx, id, id, signUpdate(t, l, key, id, proposal.Document))
+	assertErrorCode(t, err, codeNotFound, "should not apply expired proposals")
+
+	require.NoError(t, s.ProposeDidUpdate(l.ctx, id, `{"serviceEndPoint":"https://example.org/vc/"}`), "should replace expired proposals")
+	l.nextTx()
+	proposal = testProposal(t, l, id)
+
+	update := serviceUpdate(t, l, id, "https://example.org/direct/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+	l.nextTx()
+
+	_, err = s.QueryDidUpdateProposal(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should drop proposals of dids changed since")
+
+	require.NoError(t, s.ProposeDidUpdate(l.ctx, id, `{"serviceEndPoint":"https://example.org/vc/"}`))
+	l.nextTx()
+
+	l.setClient(otherClientID, testMSPID)
+	err = s.RejectDidUpdate(l.ctx, id, id)
+	assertErrorCode(t, err, codeUnauthorized, "should only let approvers reject proposals")
+
+	l.setClient(testClientID, testMSPID)
+	require.NoError(t, s.RejectDidUpdate(l.ctx, id, id), "should reject the proposal")
+
+	_, err = s.QueryDidUpdateProposal(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should remove rejected proposals")
+}
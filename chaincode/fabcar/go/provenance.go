@@ -0,0 +1,211 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// Provenance records which client identities created and last updated a did.
+// CreatedAt and ModifiedAt are the times of the creating and the last writing
+// transaction in auditTimeLayout, which sorts in time order, see QueryAllDidsSorted
+type Provenance struct {
+	Created    *ProvenanceEntry `json:"created"`
+	Updated    *ProvenanceEntry `json:"updated,omitempty" metadata:"updated,optional"`
+	CreatedAt  string           `json:"createdAt,omitempty" metadata:"createdAt,optional"`
+	ModifiedAt string           `json:"modifiedAt,omitempty" metadata:"modifiedAt,optional"`
+}
+
+// ProvenanceEntry describes the client identity that submitted a change to a did
+type ProvenanceEntry struct {
+	ClientID  string `json:"clientId"`
+	MSPID     string `json:"mspId"`
+	TxID      string `json:"txId"`
+	Timestamp string `json:"timestamp"`
+}
+
+// DidProvenance describes the chain of custody of a did: the client identity that
+// created it, the author of every update and every proposed transfer of control
+type DidProvenance struct {
+	Created   *ProvenanceEntry  `json:"created"`
+	Updates   []ProvenanceEntry `json:"updates"`
+	Transfers []ControlTransfer `json:"transfers"`
+}
+
+// ControlTransfer describes a proposed transfer of control of a did and, once
+// it was accepted, the new controller's acceptance
+type ControlTransfer struct {
+	From       string           `json:"from"`
+	To         string           `json:"to"`
+	ProposedBy *ProvenanceEntry `json:"proposedBy"`
+	AcceptedBy *ProvenanceEntry `json:"acceptedBy,omitempty" metadata:"acceptedBy,optional"`
+}
+
+// newProvenanceEntry captures the submitting client identity and transaction
+// details of the current transaction
+func newProvenanceEntry(ctx contractapi.TransactionContextInterface) (*ProvenanceEntry, error) {
+	clientID, err := ctx.GetClientIdentity().GetID()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read client identity. %s", err.Error())
+	}
+
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read client MSP ID. %s", err.Error())
+	}
+
+	timestamp, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry := ProvenanceEntry{
+		ClientID:  clientID,
+		MSPID:     mspID,
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: timestamp.Format(time.RFC3339Nano),
+	}
+
+	return &entry, nil
+}
+
+// modificationTime returns the time of a change recorded in the ledger history
+func modificationTime(modification *queryresult.KeyModification) time.Time {
+	return time.Unix(modification.GetTimestamp().GetSeconds(), int64(modification.GetTimestamp().GetNanos()))
+}
+
+// keyHistory returns every change of key recorded in the ledger history, oldest
+// first. Keys changed more than maxQueryRecords times fail
+func keyHistory(ctx contractapi.TransactionContextInterface, key string) ([]*queryresult.KeyModification, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read history of %s. %s", printableKey(key), err.Error())
+	}
+	defer resultsIterator.Close()
+
+	modifications := []*queryresult.KeyModification{}
+
+	for resultsIterator.HasNext() {
+		if len(modifications) == maxQueryRecords {
+			return nil, newTooManyRecordsError("changes of " + printableKey(key))
+		}
+
+		modification, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		modifications = append(modifications, modification)
+	}
+
+	sort.SliceStable(modifications, func(i, j int) bool {
+		return modificationTime(modifications[i]).Before(modificationTime(modifications[j]))
+	})
+
+	return modifications, nil
+}
+
+// QueryDidProvenance returns the chain of custody of the did stored in the world
+// state with given key. The creator is read from the stored did, the authors of
+// updates from the versions of the did in the ledger history and the transfers
+// from the history of its transfer proposals, which AcceptTransfer deletes
+func (s *DidContract) QueryDidProvenance(ctx contractapi.TransactionContextInterface, didNumber string) (*DidProvenance, error) {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if did.Provenance == nil {
+		return nil, newError(codeNotFound, "%s has no recorded provenance", didNumber)
+	}
+
+	versions, err := didHistory(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	provenance := DidProvenance{Created: did.Provenance.Created, Updates: []ProvenanceEntry{}, Transfers: []ControlTransfer{}}
+	updates := map[string]*ProvenanceEntry{}
+
+	for _, modification := range versions {
+		if modification.IsDelete {
+			continue
+		}
+
+		version := new(Did)
+
+		if err := unmarshalRecord(didNumber, modification.Value, version); err != nil {
+			return nil, err
+		}
+
+		if version.Provenance == nil || version.Provenance.Updated == nil || version.Provenance.Updated.TxID != modification.TxId {
+			continue
+		}
+
+		provenance.Updates = append(provenance.Updates, *version.Provenance.Updated)
+		updates[modification.TxId] = version.Provenance.Updated
+	}
+
+	key, err := transferKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	proposals, err := keyHistory(ctx, key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, modification := range proposals {
+		last := len(provenance.Transfers) - 1
+
+		if modification.IsDelete {
+			if last >= 0 && provenance.Transfers[last].AcceptedBy == nil {
+				provenance.Transfers[last].AcceptedBy = updates[modification.TxId]
+			}
+
+			continue
+		}
+
+		proposal := new(TransferProposal)
+
+		if err := unmarshalRecord(key, modification.Value, proposal); err != nil {
+			return nil, err
+		}
+
+		provenance.Transfers = append(provenance.Transfers, ControlTransfer{From: proposal.From, To: proposal.To, ProposedBy: proposal.ProposedBy})
+	}
+
+	return &provenance, nil
+}
@@ -0,0 +1,87 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryDidProvenance(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	provenance, err := s.QueryDidProvenance(l.ctx, id)
+	require.NoError(t, err, "should return the provenance")
+	assert.Equal(t, &ProvenanceEntry{ClientID: testClientID, MSPID: testMSPID, TxID: "tx1", Timestamp: testStart.Add(time.Second).Format(time.RFC3339Nano)},
+		provenance.Created, "should record the creating client and transaction")
+	assert.Empty(t, provenance.Updates, "should not record updates of new dids")
+	assert.Empty(t, provenance.Transfers, "should not record transfers of new dids")
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+	l.nextTx()
+
+	require.NoError(t, s.ProposeTransfer(l.ctx, id, "x509::CN=user3"))
+	l.nextTx()
+	require.NoError(t, s.ProposeTransfer(l.ctx, id, otherClientID))
+	l.nextTx()
+
+	l.setClient(otherClientID, otherMSPID)
+	require.NoError(t, s.AcceptTransfer(l.ctx, id))
+	l.nextTx()
+
+	provenance, err = s.QueryDidProvenance(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, testClientID, provenance.Created.ClientID, "should keep the creator after transfers")
+	require.Len(t, provenance.Updates, 2, "should record every update")
+	assert.Equal(t, []string{"tx2", "tx5"}, []string{provenance.Updates[0].TxID, provenance.Updates[1].TxID}, "should record the updates oldest first")
+	assert.Equal(t, otherClientID, provenance.Updates[1].ClientID, "should record the author of each update")
+
+	require.Len(t, provenance.Transfers, 2, "should record every proposed transfer")
+	assert.Equal(t, "x509::CN=user3", provenance.Transfers[0].To, "should record the proposed controller")
+	assert.Nil(t, provenance.Transfers[0].AcceptedBy, "should not mark superseded proposals accepted")
+	assert.Equal(t, ControlTransfer{From: testClientID, To: otherClientID, ProposedBy: provenance.Transfers[1].ProposedBy, AcceptedBy: &provenance.Updates[1]},
+		provenance.Transfers[1], "should record the acceptance of the transfer")
+	assert.Equal(t, "tx4", provenance.Transfers[1].ProposedBy.TxID, "should record the proposing transaction")
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+
+	provenance, err = s.QueryDidProvenance(l.ctx, "DID0")
+	require.NoError(t, err, "should record the provenance of seeded dids")
+	assert.Equal(t, otherClientID, provenance.Created.ClientID, "should record the seeding administrator as creator")
+
+	_, err = s.QueryDidProvenance(l.ctx, "DID9")
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	l.stub.GetHistoryForKeyReturns(nil, errors.New("GetHistoryForKey error"))
+	_, err = s.QueryDidProvenance(l.ctx, id)
+	assert.EqualError(t, err, "Failed to read history of did "+testMSPID+" "+id+". GetHistoryForKey error", "should return history errors")
+
+	l.identity.GetMSPIDReturns("", errors.New("GetMSPID error"))
+	_, err = newProvenanceEntry(l.ctx)
+	assert.EqualError(t, err, "Failed to read client MSP ID. GetMSPID error", "should need the client MSP ID")
+}
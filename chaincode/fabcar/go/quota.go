@@ -0,0 +1,273 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// registrationQuotasConfig names the configuration entry holding the
+// registration quotas
+const registrationQuotasConfig = "registrationQuotas"
+
+// registrationObjectType is the composite key object type under which the number
+// of dids registered by a client identity is counted, keyed by client identity
+// and the start of the quota window
+const registrationObjectType = "registrations"
+
+// defaultRegistrationQuota applies until a registry administrator configures
+// quotas. A limit of zero lets clients register any number of dids
+var defaultRegistrationQuota = RegistrationQuota{Limit: 0, Window: "24h"}
+
+// RegistrationQuota limits the number of dids a client identity may register in
+// a window of time. Windows are aligned to the Unix epoch, and a limit of zero
+// means no limit
+type RegistrationQuota struct {
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+}
+
+// OrgRegistrationQuota overrides the default registration quota for the client
+// identities of an organization
+type OrgRegistrationQuota struct {
+	MSPID  string `json:"mspId"`
+	Limit  int    `json:"limit"`
+	Window string `json:"window"`
+}
+
+// RegistrationQuotas describes the default registration quota and the
+// overrides of organizations
+type RegistrationQuotas struct {
+	Default RegistrationQuota      `json:"default"`
+	Orgs    []OrgRegistrationQuota `json:"orgs"`
+}
+
+// RegistrationCount records the number of dids a client identity registered in
+// the quota window starting at WindowStart
+type RegistrationCount struct {
+	ClientID    string `json:"clientId"`
+	WindowStart string `json:"windowStart"`
+	Count       int    `json:"count"`
+}
+
+func registrationQuotasKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configObjectType, []string{registrationQuotasConfig})
+}
+
+// getRegistrationQuotas returns the configured registration quotas, or the
+// default if none have been configured
+func getRegistrationQuotas(ctx contractapi.TransactionContextInterface) (*RegistrationQuotas, error) {
+	key, err := registrationQuotasKey(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	quotasAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if quotasAsBytes == nil {
+		return &RegistrationQuotas{Default: defaultRegistrationQuota, Orgs: []OrgRegistrationQuota{}}, nil
+	}
+
+	quotas := new(RegistrationQuotas)
+
+	if err := unmarshalRecord(key, quotasAsBytes, quotas); err != nil {
+		return nil, err
+	}
+
+	return quotas, nil
+}
+
+// quotaOf returns the registration quota applying to client identities of the
+// given organization
+func (q *RegistrationQuotas) quotaOf(mspID string) RegistrationQuota {
+	for _, org := range q.Orgs {
+		if org.MSPID == mspID {
+			return RegistrationQuota{Limit: org.Limit, Window: org.Window}
+		}
+	}
+
+	return q.Default
+}
+
+// validateRegistrationQuota returns the window of quota, or an error unless its
+// limit is not negative and its window a positive duration such as 24h
+func validateRegistrationQuota(quota RegistrationQuota) (time.Duration, error) {
+	if quota.Limit < 0 {
+		return 0, newError(codeInvalidArgument, "Quota limit %d must not be negative", quota.Limit)
+	}
+
+	window, err := time.ParseDuration(quota.Window)
+
+	if err != nil {
+		return 0, newError(codeInvalidArgument, "Quota window %q is not a valid duration. %s", quota.Window, err.Error())
+	}
+
+	if window <= 0 {
+		return 0, newError(codeInvalidArgument, "Quota window %s must be positive", quota.Window)
+	}
+
+	return window, nil
+}
+
+// SetRegistrationQuotas replaces the registration quotas with the given JSON
+// RegistrationQuotas. Only registry administrators may call it. Counts of the
+// current windows are kept
+func (a *AdminContract) SetRegistrationQuotas(ctx contractapi.TransactionContextInterface, quotasJSON string) error {
+	quotas := RegistrationQuotas{Orgs: []OrgRegistrationQuota{}}
+
+	if err := decodeStrict([]byte(quotasJSON), &quotas); err != nil {
+		return newError(codeInvalidArgument, "Failed to decode quotas. %s", err.Error())
+	}
+
+	if _, err := validateRegistrationQuota(quotas.Default); err != nil {
+		return err
+	}
+
+	if quotas.Orgs == nil {
+		quotas.Orgs = []OrgRegistrationQuota{}
+	}
+
+	orgs := map[string]bool{}
+
+	for _, org := range quotas.Orgs {
+		if org.MSPID == "" {
+			return newError(codeInvalidArgument, "mspId must be set in the quotas of organizations")
+		}
+
+		if orgs[org.MSPID] {
+			return newError(codeInvalidArgument, "Quota of %s is given more than once", org.MSPID)
+		}
+
+		orgs[org.MSPID] = true
+
+		if _, err := validateRegistrationQuota(RegistrationQuota{Limit: org.Limit, Window: org.Window}); err != nil {
+			return err
+		}
+	}
+
+	key, err := registrationQuotasKey(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	quotasAsBytes, err := marshalRecord(key, quotas)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, quotasAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryRegistrationQuotas returns the registration quotas
+func (s *DidContract) QueryRegistrationQuotas(ctx contractapi.TransactionContextInterface) (*RegistrationQuotas, error) {
+	return getRegistrationQuotas(ctx)
+}
+
+// consumeRegistrationQuota counts the registration of count dids by the
+// submitting client identity in the current quota window, failing if that would
+// exceed the quota of the client's organization. Writes of a transaction are not
+// visible to its own reads, so it is called once per transaction with the number
+// of dids it creates, see admitRegistration
+func consumeRegistrationQuota(ctx contractapi.TransactionContextInterface, count int) error {
+	client, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	quotas, err := getRegistrationQuotas(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	quota := quotas.quotaOf(client.MSPID)
+
+	if quota.Limit == 0 {
+		return nil
+	}
+
+	window, err := validateRegistrationQuota(quota)
+
+	if err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	windowStart := now.Truncate(window).Format(time.RFC3339)
+	key, err := ctx.GetStub().CreateCompositeKey(registrationObjectType, []string{client.ClientID, windowStart})
+
+	if err != nil {
+		return err
+	}
+
+	countAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	registrations := RegistrationCount{ClientID: client.ClientID, WindowStart: windowStart}
+
+	if countAsBytes != nil {
+		if err := unmarshalRecord(key, countAsBytes, &registrations); err != nil {
+			return err
+		}
+	}
+
+	if registrations.Count+count > quota.Limit {
+		return newError(codeQuotaExceeded, "%s may register %d dids per %s and has registered %d since %s", client.ClientID, quota.Limit, quota.Window, registrations.Count, windowStart)
+	}
+
+	registrations.Count += count
+	countAsBytes, err = marshalRecord(key, registrations)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, countAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetRegistrationQuotas(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+
+	quotas, err := s.QueryRegistrationQuotas(l.ctx)
+	require.NoError(t, err, "should return the default quota")
+	assert.Equal(t, &RegistrationQuotas{Default: defaultRegistrationQuota, Orgs: []OrgRegistrationQuota{}}, quotas, "should not limit registrations by default")
+
+	err = l.before(a, "SetRegistrationQuotas")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators set the quotas")
+
+	l.setAdmin(true)
+
+	err = a.SetRegistrationQuotas(l.ctx, `{"default":{"limit":2,"window":"1h"},"orgs":[{"mspId":"Org2MSP","limit":0,"window":"24h"}]}`)
+	require.NoError(t, err, "should set the quotas")
+
+	quotas, err = s.QueryRegistrationQuotas(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, RegistrationQuota{Limit: 2, Window: "1h"}, quotas.Default, "should store the default quota")
+	assert.Equal(t, []OrgRegistrationQuota{{MSPID: "Org2MSP", Limit: 0, Window: "24h"}}, quotas.Orgs, "should store the overrides")
+
+	invalid := map[string]string{
+		`{"default":{"limit":-1,"window":"1h"}}`:                                   "should reject negative limits",
+		`{"default":{"limit":1,"window":"day"}}`:                                   "should reject invalid windows",
+		`{"default":{"limit":1,"window":"0s"}}`:                                    "should reject empty windows",
+		`{"default":{"limit":1,"window":"1h"},"orgs":[{"limit":1,"window":"1h"}]}`: "should require the MSP ID of overrides",
+		`{"default":{"limit":1,"window":"1h"},"orgs":[{"mspId":"Org2MSP","limit":1,"window":"1h"},{"mspId":"Org2MSP","limit":2,"window":"1h"}]}`: "should reject repeated overrides",
+		`{"default":{"limit":1,"window":"1h"},"limit":1}`: "should reject unknown members",
+	}
+
+	for quotasJSON, message := range invalid {
+		assertErrorCode(t, a.SetRegistrationQuotas(l.ctx, quotasJSON), codeInvalidArgument, message)
+	}
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.QueryRegistrationQuotas(l.ctx)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestRegistrationQuota(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).SetRegistrationQuotas(l.ctx, `{"default":{"limit":2,"window":"1h"},"orgs":[{"mspId":"`+otherMSPID+`","limit":1,"window":"1h"}]}`))
+
+	createTestDid(t, l, newTestKey(t))
+	createTestDid(t, l, newTestKey(t))
+
+	_, err := s.BatchCreateDids(l.ctx, batchJSON(t, batchItem(t, newTestKey(t))))
+	assertErrorCode(t, err, codeQuotaExceeded, "should reject registrations beyond the quota")
+
+	l.setClient(otherClientID, otherMSPID)
+	createTestDid(t, l, newTestKey(t))
+
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeQuotaExceeded, "should apply the quota of the client's organization")
+
+	l.setClient(testClientID, testMSPID)
+	l.setTime(testStart.Add(time.Hour))
+
+	_, err = s.BatchCreateDids(l.ctx, batchJSON(t, batchItem(t, newTestKey(t)), batchItem(t, newTestKey(t)), batchItem(t, newTestKey(t))))
+	assertErrorCode(t, err, codeQuotaExceeded, "should count every did of a batch")
+
+	ids, err := s.BatchCreateDids(l.ctx, batchJSON(t, batchItem(t, newTestKey(t)), batchItem(t, newTestKey(t))))
+	require.NoError(t, err, "should reset the count in the next window")
+	assert.Len(t, ids, 2)
+
+	l.setTime(testStart.Add(time.Hour + time.Second))
+	_, err = s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, "")
+	assertErrorCode(t, err, codeQuotaExceeded, "should count batches against the quota")
+	assert.Contains(t, err.Error(), "has registered 2 since 2020-06-01T13:00:00Z", "should report the count of the window")
+}
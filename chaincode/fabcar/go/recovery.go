@@ -0,0 +1,166 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// hasMethod reports whether the did has a verification or recovery method with
+// given id
+func hasMethod(did *Did, methodId string) bool {
+	for _, method := range append(did.verificationMethods(), did.RecoveryMethods...) {
+		if method.Id == methodId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recoveryMethod returns the recovery method of the did with given id
+func recoveryMethod(did *Did, didNumber string, methodId string) (*VerificationMethod, error) {
+	for _, method := range did.RecoveryMethods {
+		if method.Id == methodId {
+			return &method, nil
+		}
+	}
+
+	return nil, newError(codeNotFound, "Verification method %s is not a recovery method of %s", methodId, didNumber)
+}
+
+// AddRecoveryMethod registers a recovery method for a did. Recovery methods are
+// not part of the did document and cannot authenticate or sign proofs, they can
+// only be used with RecoverDid. The public key may be given as PEM,
+// publicKeyMultibase or a JSON encoded JWK and the signature must prove
+// possession of the current authentication key over the update payload of the
+// extended document
+func (s *DidContract) AddRecoveryMethod(ctx contractapi.TransactionContextInterface, didNumber string, methodId string, methodType string,
+	controller string, publicKey string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if hasMethod(did, methodId) {
+		return newError(codeAlreadyExists, "Verification method %s already exists in %s", methodId, didNumber)
+	}
+
+	method, err := newVerificationMethod(methodId, methodType, controller, publicKey)
+
+	if err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.RecoveryMethods = append(append([]VerificationMethod{}, did.RecoveryMethods...), *method)
+
+	if err := assertDidCap(ctx, didNumber, &update); err != nil {
+		return err
+	}
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
+
+// RemoveRecoveryMethod removes a recovery method from a did. The signature must
+// prove possession of the current authentication key
+func (s *DidContract) RemoveRecoveryMethod(ctx contractapi.TransactionContextInterface, didNumber string, methodId string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err := recoveryMethod(did, didNumber, methodId); err != nil {
+		return err
+	}
+
+	methods := []VerificationMethod{}
+
+	for _, method := range did.RecoveryMethods {
+		if method.Id != methodId {
+			methods = append(methods, method)
+		}
+	}
+
+	update := updatableDetails(did)
+	update.RecoveryMethods = methods
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
+
+// RecoverDid replaces all active keys of a did after the loss of its
+// authentication key: the authentication key is replaced and the additional
+// verification methods are removed, while the recovery methods are kept. The
+// signature must be made with the recovery method with given id over the update
+// payload of the recovered document. Any client identity holding the recovery
+// key, such as a guardian, may submit it, the controller of the did is kept
+func (s *DidContract) RecoverDid(ctx contractapi.TransactionContextInterface, didNumber string, recoveryMethodId string, authenticationId string,
+	authenticationType string, authenticationPublicKeyPerm string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if did.Deactivated {
+		return newError(codeDidDeactivated, "%s is deactivated", didNumber)
+	}
+
+	method, err := recoveryMethod(did, didNumber, recoveryMethodId)
+
+	if err != nil {
+		return err
+	}
+
+	for _, recovery := range did.RecoveryMethods {
+		if recovery.Id == authenticationId {
+			return newError(codeInvalidArgument, "Recovery method %s cannot become the authentication key of %s", authenticationId, didNumber)
+		}
+	}
+
+	update := updatableDetails(did)
+	update.AuthenticationId = authenticationId
+	update.AuthenticationType = authenticationType
+	update.AuthenticationPublicKeyPerm = authenticationPublicKeyPerm
+	update.AuthenticationPublicKeyMultibase = ""
+	update.AuthenticationPublicKeyJwk = nil
+	update.VerificationMethods = []VerificationMethod{}
+
+	keyName := "recovery method " + recoveryMethodId
+	signatureAsBytes, err := decodeUpdateSignature(keyName, signature)
+
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := method.publicKey()
+
+	if err != nil {
+		return newError(codeCorruptRecord, "Failed to parse public key of %s. %s", recoveryMethodId, err.Error())
+	}
+
+	if err := verifyUpdatePayload(publicKey, keyName, didNumber, did, &update, signatureAsBytes); err != nil {
+		return err
+	}
+
+	return applyUpdate(ctx, didNumber, did, &update, "")
+}
@@ -0,0 +1,147 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addTestRecoveryMethod registers recovery as the recovery method #recovery-1
+// of the stored did and starts the next transaction
+func addTestRecoveryMethod(t *testing.T, l *testLedger, key *testKey, didNumber string, recovery *testKey) {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	method, err := newVerificationMethod(didNumber+"#recovery-1", testKeyType, didNumber, recovery.pem)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.RecoveryMethods = []VerificationMethod{*method}
+
+	err = new(DidContract).AddRecoveryMethod(l.ctx, didNumber, method.Id, testKeyType, didNumber, recovery.pem, signUpdate(t, l, key, didNumber, &update))
+	require.NoError(t, err)
+
+	l.nextTx()
+}
+
+// recoveryUpdate returns the stored did with its keys replaced by key
+func recoveryUpdate(t *testing.T, l *testLedger, didNumber string, key *testKey) *Did {
+	did, err := getDid(l.ctx, didNumber)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.AuthenticationId = didNumber + "#keys-2"
+	update.AuthenticationType = testKeyType
+	update.AuthenticationPublicKeyPerm = key.pem
+	update.AuthenticationPublicKeyMultibase = ""
+	update.AuthenticationPublicKeyJwk = nil
+	update.VerificationMethods = []VerificationMethod{}
+
+	return &update
+}
+
+func TestAddRecoveryMethod(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	recovery := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	err := s.AddRecoveryMethod(l.ctx, id, id+"#recovery-1", testKeyType, id, recovery.pem, "")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature")
+
+	addTestRecoveryMethod(t, l, key, id, recovery)
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	require.Len(t, did.RecoveryMethods, 1, "should store the recovery method")
+	assert.Empty(t, did.VerificationMethods, "should not add recovery methods to the verification methods")
+
+	_, err = publicKeyOf(did, id+"#recovery-1")
+	assert.Error(t, err, "should not verify proofs with recovery methods")
+
+	result, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.Len(t, result.DidDocument.VerificationMethod, 1, "should leave recovery methods out of the document")
+
+	err = s.AddRecoveryMethod(l.ctx, id, id+"#keys-1", testKeyType, id, recovery.pem, "")
+	assertErrorCode(t, err, codeAlreadyExists, "should not reuse method ids")
+
+	err = s.RemoveRecoveryMethod(l.ctx, id, id+"#keys-1", "")
+	assertErrorCode(t, err, codeNotFound, "should only remove recovery methods")
+
+	update := updatableDetails(did)
+	update.RecoveryMethods = []VerificationMethod{}
+	require.NoError(t, s.RemoveRecoveryMethod(l.ctx, id, id+"#recovery-1", signUpdate(t, l, key, id, &update)), "should remove the recovery method")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Empty(t, did.RecoveryMethods, "should remove the method from the did")
+}
+
+func TestRecoverDid(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	recovery := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	other := newTestKey(t).pem
+	require.NoError(t, s.AddVerificationMethod(l.ctx, id, id+"#keys-3", testKeyType, id, other, signAddMethod(t, l, key, id, id+"#keys-3", testKeyType, id, other)))
+	l.nextTx()
+	addTestRecoveryMethod(t, l, key, id, recovery)
+
+	replacement := newTestKey(t)
+	update := recoveryUpdate(t, l, id, replacement)
+
+	recoverDid := func(methodId string, signature string) error {
+		return s.RecoverDid(l.ctx, id, methodId, update.AuthenticationId, update.AuthenticationType, update.AuthenticationPublicKeyPerm, signature)
+	}
+
+	assertErrorCode(t, recoverDid(id+"#recovery-1", signUpdate(t, l, key, id, update)), codeInvalidSignature, "should not recover with the authentication key")
+	assertErrorCode(t, recoverDid(id+"#keys-1", signUpdate(t, l, recovery, id, update)), codeNotFound, "should only recover with recovery methods")
+
+	err := s.RecoverDid(l.ctx, id, id+"#recovery-1", id+"#recovery-1", testKeyType, replacement.pem, signUpdate(t, l, recovery, id, update))
+	assertErrorCode(t, err, codeInvalidArgument, "should not make a recovery method the authentication key")
+
+	l.setClient(otherClientID, testMSPID)
+	require.NoError(t, recoverDid(id+"#recovery-1", signUpdate(t, l, recovery, id, update)), "should let the holder of the recovery key recover the did")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id+"#keys-2", did.AuthenticationId, "should replace the authentication key")
+	assert.Empty(t, did.VerificationMethods, "should remove the additional verification methods")
+	assert.Len(t, did.RecoveryMethods, 1, "should keep the recovery methods")
+	assert.Equal(t, testClientID, did.Controller, "should keep the controller")
+
+	l.setClient(testClientID, testMSPID)
+	lost := testUpdate(id, key, "https://example.org/vc/")
+	err = updateDid(l, id, lost, signUpdate(t, l, key, id, lost))
+	assertErrorCode(t, err, codeInvalidSignature, "should no longer accept the lost key")
+
+	rotated := testUpdate(id, replacement, "https://example.org/vc/")
+	rotated.AuthenticationId = id + "#keys-2"
+	require.NoError(t, updateDid(l, id, rotated, signUpdate(t, l, replacement, id, rotated)), "should accept the recovered key")
+
+	assertErrorCode(t, s.RecoverDid(l.ctx, "DID9", id+"#recovery-1", "", "", "", ""), codeDidNotFound, "should fail for unknown dids")
+}
@@ -0,0 +1,67 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ResolveRemote resolves the did stored with given key by this chaincode on
+// another channel, as Resolve resolves dids of this channel. The chaincode must
+// be installed on the peer and the peer joined to the other channel. Calls to
+// other channels cannot write, so it is an evaluate function
+func (s *DidContract) ResolveRemote(ctx contractapi.TransactionContextInterface, channel string, didNumber string) (*DidResolutionResult, error) {
+	if channel == "" {
+		return nil, newError(codeInvalidArgument, "Channel must not be empty")
+	}
+
+	if channel == ctx.GetStub().GetChannelID() {
+		return nil, newError(codeInvalidArgument, "%s is the channel of this transaction, use QueryDidByKey or Resolve", channel)
+	}
+
+	name, err := chaincodeName(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	args := [][]byte{[]byte(didContractName + ":QueryDidByKey"), []byte(didNumber)}
+	response := ctx.GetStub().InvokeChaincode(name, args, channel)
+
+	if response.Status != shim.OK {
+		remoteErr := new(ContractError)
+
+		if json.Unmarshal([]byte(response.Message), remoteErr) == nil && remoteErr.Code == codeDidNotFound {
+			return failedResolution(resolutionNotFound), nil
+		}
+
+		return nil, newError(codeTransactionFailed, "Failed to query %s on channel %s. %s", didNumber, channel, response.Message)
+	}
+
+	did := new(Did)
+
+	if err := unmarshalRecord(didNumber, response.Payload, did); err != nil {
+		return nil, err
+	}
+
+	return resolution(ctx, did)
+}
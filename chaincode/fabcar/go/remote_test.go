@@ -0,0 +1,75 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveRemote(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	didAsBytes, err := marshalRecord(id, did)
+	require.NoError(t, err)
+
+	l.stub.InvokeChaincodeReturns(peer.Response{Status: shim.OK, Payload: didAsBytes})
+
+	resolution, err := s.ResolveRemote(l.ctx, "otherchannel", id)
+	require.NoError(t, err, "should resolve dids of other channels")
+	assert.Equal(t, did.document(), resolution.DidDocument, "should return the document of the remote did")
+	assert.Equal(t, "tx1", resolution.DidDocumentMetadata.VersionId, "should return the metadata of the remote did")
+
+	name, args, channel := l.stub.InvokeChaincodeArgsForCall(0)
+	assert.Equal(t, testChaincode, name, "should call this chaincode")
+	assert.Equal(t, [][]byte{[]byte("did:QueryDidByKey"), []byte(id)}, args, "should query the did by key")
+	assert.Equal(t, "otherchannel", channel, "should call the other channel")
+
+	l.stub.InvokeChaincodeReturns(peer.Response{Status: shim.ERROR, Message: newError(codeDidNotFound, "%s does not exist", "DID9").Error()})
+
+	resolution, err = s.ResolveRemote(l.ctx, "otherchannel", "DID9")
+	require.NoError(t, err, "should not fail for unknown dids")
+	assert.Equal(t, resolutionNotFound, resolution.DidResolutionMetadata.Error, "should report unknown remote dids")
+
+	l.stub.InvokeChaincodeReturns(peer.Response{Status: shim.ERROR, Message: "chaincode fabcar not found"})
+
+	_, err = s.ResolveRemote(l.ctx, "otherchannel", id)
+	assertErrorCode(t, err, codeTransactionFailed, "should return failed calls")
+	assert.Contains(t, err.Error(), "chaincode fabcar not found", "should keep the message of failed calls")
+
+	l.stub.InvokeChaincodeReturns(peer.Response{Status: shim.OK, Payload: []byte("{")})
+
+	_, err = s.ResolveRemote(l.ctx, "otherchannel", id)
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject undecodable dids")
+
+	_, err = s.ResolveRemote(l.ctx, testChannel, id)
+	assertErrorCode(t, err, codeInvalidArgument, "should not call the channel of the transaction")
+
+	_, err = s.ResolveRemote(l.ctx, "", id)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a channel")
+}
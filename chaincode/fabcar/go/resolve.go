@@ -0,0 +1,321 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// didContext is the JSON-LD context of did documents
+const didContext = "https://www.w3.org/ns/did/v1"
+
+// didContentType is the media type of resolved did documents
+const didContentType = "application/did+ld+json"
+
+// Error codes of did resolution metadata
+const (
+	resolutionInvalidDid  = "invalidDid"
+	resolutionNotFound    = "notFound"
+	resolutionDeactivated = "deactivated"
+)
+
+// DidDocument describes a did in the representation defined by DID Core
+type DidDocument struct {
+	Context              []string             `json:"@context"`
+	Id                   string               `json:"id"`
+	Controller           []string             `json:"controller,omitempty" metadata:"controller,optional"`
+	VerificationMethod   []VerificationMethod `json:"verificationMethod"`
+	Authentication       []string             `json:"authentication"`
+	AssertionMethod      []string             `json:"assertionMethod,omitempty" metadata:"assertionMethod,optional"`
+	CapabilityInvocation []string             `json:"capabilityInvocation,omitempty" metadata:"capabilityInvocation,optional"`
+	CapabilityDelegation []string             `json:"capabilityDelegation,omitempty" metadata:"capabilityDelegation,optional"`
+	KeyAgreement         []string             `json:"keyAgreement,omitempty" metadata:"keyAgreement,optional"`
+	Service              []Service            `json:"service,omitempty" metadata:"service,optional"`
+}
+
+// Service describes a service of a did document. The service endpoint is a URI
+// or, for DIDCommMessaging services, an array of DidCommEndpoint
+type Service struct {
+	Id              string      `json:"id"`
+	Type            string      `json:"type"`
+	ServiceEndpoint interface{} `json:"serviceEndpoint"`
+}
+
+// DidMetadata describes the document metadata recorded by the controller of a
+// did: other dids of the same subject, the did resolvers should use instead, for
+// example after a method migration, and when the did is next expected to change
+type DidMetadata struct {
+	EquivalentId []string `json:"equivalentId"`
+	CanonicalId  string   `json:"canonicalId,omitempty" metadata:"canonicalId,optional"`
+	NextUpdate   string   `json:"nextUpdate,omitempty" metadata:"nextUpdate,optional"`
+}
+
+// DidDocumentMetadata describes the lifecycle of a resolved did document. Method
+// is only set for dids created by Sidetree operations, NextVersionId only for
+// past versions, see ResolveVersion. CertificateAttributes are the attributes
+// projected from the certificate of the did's registrant
+type DidDocumentMetadata struct {
+	Created               string                  `json:"created,omitempty" metadata:"created,optional"`
+	Updated               string                  `json:"updated,omitempty" metadata:"updated,optional"`
+	NextUpdate            string                  `json:"nextUpdate,omitempty" metadata:"nextUpdate,optional"`
+	Expires               string                  `json:"expires,omitempty" metadata:"expires,optional"`
+	VersionId             string                  `json:"versionId,omitempty" metadata:"versionId,optional"`
+	NextVersionId         string                  `json:"nextVersionId,omitempty" metadata:"nextVersionId,optional"`
+	Deactivated           bool                    `json:"deactivated"`
+	Deleted               bool                    `json:"deleted,omitempty" metadata:"deleted,optional"`
+	EquivalentId          []string                `json:"equivalentId,omitempty" metadata:"equivalentId,optional"`
+	CanonicalId           string                  `json:"canonicalId,omitempty" metadata:"canonicalId,optional"`
+	CertificateAttributes []CertificateAttribute  `json:"certificateAttributes,omitempty" metadata:"certificateAttributes,optional"`
+	Method                *SidetreeMethodMetadata `json:"method,omitempty" metadata:"method,optional"`
+}
+
+// DidResolutionMetadata describes the outcome of resolving a did. EncryptedEndpoints
+// is set when the service endpoint of the document is a JWE. Expired is set
+// when the did was resolved after its expiry. OffChainDocument is set when the
+// full document is stored off-chain and must be fetched by its CID
+type DidResolutionMetadata struct {
+	ContentType        string            `json:"contentType"`
+	Error              string            `json:"error,omitempty" metadata:"error,optional"`
+	Expired            bool              `json:"expired,omitempty" metadata:"expired,optional"`
+	EncryptedEndpoints bool              `json:"encryptedEndpoints,omitempty" metadata:"encryptedEndpoints,optional"`
+	OffChainDocument   *OffChainDocument `json:"offChainDocument,omitempty" metadata:"offChainDocument,optional"`
+}
+
+// DidResolutionResult is the result of resolving a did as defined by DID Resolution
+type DidResolutionResult struct {
+	DidDocument           *DidDocument           `json:"didDocument,omitempty" metadata:"didDocument,optional"`
+	DidDocumentMetadata   *DidDocumentMetadata   `json:"didDocumentMetadata"`
+	DidResolutionMetadata *DidResolutionMetadata `json:"didResolutionMetadata"`
+}
+
+// document returns the did in the representation defined by DID Core, listing
+// the controllers of its multisig policy as its controllers. Services whose
+// endpoint is only held privately are left out
+func (d *Did) document() *DidDocument {
+	document := DidDocument{
+		Context:            []string{didContext},
+		Id:                 d.Id,
+		VerificationMethod: d.verificationMethods(),
+		Authentication:     []string{d.AuthenticationId},
+	}
+
+	if d.Multisig != nil {
+		document.Controller = d.Multisig.Controllers
+	}
+
+	if d.ServiceId != "" && d.ServiceEndPoint != "" {
+		document.Service = []Service{Service{Id: d.ServiceId, Type: d.ServiceType, ServiceEndpoint: d.ServiceEndPoint}}
+	}
+
+	if d.ServiceId != "" && len(d.ServiceEndpoints) > 0 {
+		document.Service = []Service{Service{Id: d.ServiceId, Type: d.ServiceType, ServiceEndpoint: d.ServiceEndpoints}}
+	}
+
+	if d.ServiceId != "" && d.EncryptedServiceEndPoint != "" {
+		document.Service = []Service{Service{Id: d.ServiceId, Type: d.ServiceType, ServiceEndpoint: d.EncryptedServiceEndPoint}}
+	}
+
+	if kid := d.keyAgreementMethod(); kid != "" {
+		document.KeyAgreement = []string{kid}
+	}
+
+	return &document
+}
+
+// documentMetadata returns the metadata of the did derived from its provenance
+func (d *Did) documentMetadata() *DidDocumentMetadata {
+	metadata := DidDocumentMetadata{VersionId: lastTxId(d), Deactivated: d.Deactivated, Deleted: d.Deleted, Expires: d.Expires,
+		CertificateAttributes: d.CertificateAttributes}
+
+	if d.Provenance != nil && d.Provenance.Created != nil {
+		metadata.Created = d.Provenance.Created.Timestamp
+	}
+
+	if d.Provenance != nil && d.Provenance.Updated != nil {
+		metadata.Updated = d.Provenance.Updated.Timestamp
+	}
+
+	if d.Metadata != nil {
+		metadata.EquivalentId = d.Metadata.EquivalentId
+		metadata.CanonicalId = d.Metadata.CanonicalId
+		metadata.NextUpdate = d.Metadata.NextUpdate
+	}
+
+	return &metadata
+}
+
+// normalizeDidMetadata removes metadata of the did that sets nothing and
+// otherwise stores missing equivalent ids as an empty array
+func normalizeDidMetadata(did *Did) {
+	if did.Metadata == nil {
+		return
+	}
+
+	if len(did.Metadata.EquivalentId) == 0 && did.Metadata.CanonicalId == "" && did.Metadata.NextUpdate == "" {
+		did.Metadata = nil
+		return
+	}
+
+	if did.Metadata.EquivalentId == nil {
+		did.Metadata.EquivalentId = []string{}
+	}
+}
+
+// validateDidMetadata checks that the equivalent and canonical ids are dids and
+// that the next update is an RFC 3339 timestamp after the current transaction
+func validateDidMetadata(ctx contractapi.TransactionContextInterface, metadata *DidMetadata) error {
+	if metadata == nil {
+		return nil
+	}
+
+	for _, id := range metadata.EquivalentId {
+		if err := validateDid("equivalentId", id); err != nil {
+			return err
+		}
+	}
+
+	if metadata.CanonicalId != "" {
+		if err := validateDid("canonicalId", metadata.CanonicalId); err != nil {
+			return err
+		}
+	}
+
+	if metadata.NextUpdate == "" {
+		return nil
+	}
+
+	nextUpdate, err := time.Parse(time.RFC3339, metadata.NextUpdate)
+
+	if err != nil {
+		return newError(codeInvalidArgument, "nextUpdate %s must be an RFC 3339 timestamp", metadata.NextUpdate)
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !nextUpdate.After(now) {
+		return newError(codeInvalidArgument, "nextUpdate %s must be in the future", metadata.NextUpdate)
+	}
+
+	return nil
+}
+
+// SetDocumentMetadata replaces the equivalent ids, given as a JSON array, the
+// canonical id and the next update hint of a did, which Resolve returns with its
+// document metadata. Empty values clear the metadata. The signature must prove
+// possession of the current authentication key over the update payload of the
+// document with the new metadata
+func (s *DidContract) SetDocumentMetadata(ctx contractapi.TransactionContextInterface, didNumber string, equivalentId string, canonicalId string,
+	nextUpdate string, signature string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	metadata := DidMetadata{EquivalentId: []string{}, CanonicalId: canonicalId, NextUpdate: nextUpdate}
+
+	if equivalentId != "" {
+		if err := decodeStrict([]byte(equivalentId), &metadata.EquivalentId); err != nil {
+			return newError(codeInvalidArgument, "Equivalent ids must be a JSON array of dids. %s", err.Error())
+		}
+	}
+
+	if err := validateDidMetadata(ctx, &metadata); err != nil {
+		return err
+	}
+
+	update := updatableDetails(did)
+	update.Metadata = &metadata
+
+	return s.updateDid(ctx, didNumber, &update, signature, "")
+}
+
+// failedResolution returns the result of a resolution that failed with given error code
+func failedResolution(code string) *DidResolutionResult {
+	return &DidResolutionResult{
+		DidDocumentMetadata:   new(DidDocumentMetadata),
+		DidResolutionMetadata: &DidResolutionMetadata{ContentType: didContentType, Error: code},
+	}
+}
+
+// Resolve returns the did document with given id together with its document and
+// resolution metadata. Dids that do not exist or were deactivated resolve to a
+// result carrying the matching error code instead of failing the transaction.
+// Expired dids still resolve to their document, flagged in the resolution metadata.
+// Deleted dids are not found unless includeDeleted is set. Dids created by
+// Sidetree operations resolve to their Sidetree state
+func (s *DidContract) Resolve(ctx contractapi.TransactionContextInterface, did string, includeDeleted bool) (*DidResolutionResult, error) {
+	if !strings.HasPrefix(did, "did:") {
+		return failedResolution(resolutionInvalidDid), nil
+	}
+
+	sidetree, err := lookupSidetreeDid(ctx, did)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sidetree != nil {
+		return sidetree.resolution(), nil
+	}
+
+	result, err := lookupDidById(ctx, did)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil || (result.Record.Deleted && !includeDeleted) {
+		return failedResolution(resolutionNotFound), nil
+	}
+
+	return resolution(ctx, result.Record)
+}
+
+// resolution returns the resolution result of a stored did
+func resolution(ctx contractapi.TransactionContextInterface, did *Did) (*DidResolutionResult, error) {
+	if did.Deactivated {
+		resolution := failedResolution(resolutionDeactivated)
+		resolution.DidDocumentMetadata = did.documentMetadata()
+
+		return resolution, nil
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolution := DidResolutionResult{
+		DidDocument:           did.document(),
+		DidDocumentMetadata:   did.documentMetadata(),
+		DidResolutionMetadata: &DidResolutionMetadata{ContentType: didContentType, Expired: isExpired(did, now), EncryptedEndpoints: did.EncryptedServiceEndPoint != "", OffChainDocument: did.OffChainDocument},
+	}
+
+	return &resolution, nil
+}
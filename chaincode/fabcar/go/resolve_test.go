@@ -0,0 +1,126 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	resolution, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err, "should resolve the did")
+	assert.Equal(t, didContentType, resolution.DidResolutionMetadata.ContentType, "should return a did document")
+	assert.Equal(t, "", resolution.DidResolutionMetadata.Error, "should not return an error code")
+	assert.Equal(t, []string{didContext}, resolution.DidDocument.Context, "should return the did context")
+	assert.Equal(t, []string{id + "#keys-1"}, resolution.DidDocument.Authentication, "should reference the authentication key")
+	require.Len(t, resolution.DidDocument.VerificationMethod, 1, "should return the authentication key as verification method")
+	assert.Equal(t, key.pem, resolution.DidDocument.VerificationMethod[0].PublicKeyPem, "should return the key")
+	assert.Equal(t, []Service{{Id: id + "#vcs", Type: "VerifiableCredentialService", ServiceEndpoint: testEndpoint}}, resolution.DidDocument.Service, "should return the service")
+	assert.Equal(t, &DidDocumentMetadata{Created: testStart.Add(time.Second).Format(time.RFC3339Nano), VersionId: "tx1"}, resolution.DidDocumentMetadata, "should derive the metadata from the provenance")
+
+	resolution, err = s.Resolve(l.ctx, "example:1234", false)
+	assert.Nil(t, err, "should not fail for invalid dids")
+	assert.Equal(t, resolutionInvalidDid, resolution.DidResolutionMetadata.Error, "should report invalid dids")
+
+	resolution, err = s.Resolve(l.ctx, "did:example:unknown", false)
+	assert.Nil(t, err, "should not fail for unknown dids")
+	assert.Equal(t, resolutionNotFound, resolution.DidResolutionMetadata.Error, "should report unknown dids")
+	assert.Nil(t, resolution.DidDocument, "should not return a document for unknown dids")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, id, signUpdate(t, l, key, id, &update)))
+
+	resolution, err = s.Resolve(l.ctx, id, false)
+	assert.Nil(t, err, "should not fail for deactivated dids")
+	assert.Equal(t, resolutionDeactivated, resolution.DidResolutionMetadata.Error, "should report deactivated dids")
+	assert.True(t, resolution.DidDocumentMetadata.Deactivated, "should mark the metadata deactivated")
+	assert.Nil(t, resolution.DidDocument, "should not return a document for deactivated dids")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.Resolve(l.ctx, id, false)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestSetDocumentMetadata(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	nextUpdate := testStart.Add(24 * time.Hour).Format(time.RFC3339)
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Metadata = &DidMetadata{EquivalentId: []string{"did:example:1234"}, CanonicalId: "did:example:1234", NextUpdate: nextUpdate}
+	signature := signUpdate(t, l, key, id, &update)
+
+	err = s.SetDocumentMetadata(l.ctx, id, `["did:example:1234"]`, "did:example:1234", nextUpdate, "")
+	assertErrorCode(t, err, codeInvalidSignature, "should require a signature")
+
+	err = s.SetDocumentMetadata(l.ctx, id, `["did:example:1234"]`, "did:example:1234", nextUpdate, signature)
+	require.NoError(t, err, "should set the metadata")
+
+	resolution, err := s.Resolve(l.ctx, id, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"did:example:1234"}, resolution.DidDocumentMetadata.EquivalentId, "should resolve the equivalent ids")
+	assert.Equal(t, "did:example:1234", resolution.DidDocumentMetadata.CanonicalId, "should resolve the canonical id")
+	assert.Equal(t, nextUpdate, resolution.DidDocumentMetadata.NextUpdate, "should resolve the next update")
+	l.nextTx()
+
+	update = *testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, &update, signUpdate(t, l, key, id, &update)), "should update dids with metadata")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "did:example:1234", did.Metadata.CanonicalId, "should keep the metadata on updates")
+	l.nextTx()
+
+	for _, invalid := range [][]string{{`"did:example:1234"`, "", ""}, {`["example:1234"]`, "", ""}, {"", "did:example", ""}, {"", "", "tomorrow"}, {"", "", testStart.Format(time.RFC3339)}} {
+		err = s.SetDocumentMetadata(l.ctx, id, invalid[0], invalid[1], invalid[2], signature)
+		assertErrorCode(t, err, codeInvalidArgument, "should reject "+strings.Join(invalid, " "))
+	}
+
+	update = updatableDetails(did)
+	update.Metadata = &DidMetadata{EquivalentId: []string{}}
+	err = s.SetDocumentMetadata(l.ctx, id, "", "", "", signUpdate(t, l, key, id, &update))
+	require.NoError(t, err, "should clear the metadata")
+
+	did, err = s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Nil(t, did.Metadata, "should not store empty metadata")
+
+	err = s.SetDocumentMetadata(l.ctx, "DID9", "", "", "", signature)
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+}
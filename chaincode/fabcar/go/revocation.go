@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// certificateBindingObjectType is the composite key object type under which
+// the enrollment id bound with a certificate is stored, keyed by MSP ID and
+// certificate serial number, so that dids can be found from revocation lists
+const certificateBindingObjectType = "certificateBinding"
+
+// CertificateBinding names the enrollment id bound with a certificate
+type CertificateBinding struct {
+	EnrollmentId string `json:"enrollmentId"`
+}
+
+func certificateBindingKey(ctx contractapi.TransactionContextInterface, mspID string, serial string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(certificateBindingObjectType, []string{mspID, serial})
+}
+
+// clientCertificateSerial returns the hexadecimal serial number of the
+// certificate of the submitting client identity, or an empty string for
+// identities without one
+func clientCertificateSerial(ctx contractapi.TransactionContextInterface) (string, error) {
+	certificate, err := ctx.GetClientIdentity().GetX509Certificate()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read client certificate. %s", err.Error())
+	}
+
+	if certificate == nil || certificate.SerialNumber == nil {
+		return "", nil
+	}
+
+	return certificate.SerialNumber.Text(16), nil
+}
+
+// putCertificateBinding links the certificate serial number of a binding to
+// its enrollment id, removing the link of the certificate the enrollment id was
+// bound with before
+func putCertificateBinding(ctx contractapi.TransactionContextInterface, key string, binding *IdentityBinding) error {
+	previousAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if previousAsBytes != nil {
+		previous := IdentityBinding{}
+
+		if err := unmarshalRecord(key, previousAsBytes, &previous); err != nil {
+			return err
+		}
+
+		if previous.CertificateSerial != "" && previous.CertificateSerial != binding.CertificateSerial {
+			previousKey, err := certificateBindingKey(ctx, previous.MSPID, previous.CertificateSerial)
+
+			if err != nil {
+				return err
+			}
+
+			err = ctx.GetStub().DelState(previousKey)
+
+			if err != nil {
+				return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+			}
+		}
+	}
+
+	if binding.CertificateSerial == "" {
+		return nil
+	}
+
+	certificateKey, err := certificateBindingKey(ctx, binding.MSPID, binding.CertificateSerial)
+
+	if err != nil {
+		return err
+	}
+
+	certificateAsBytes, err := marshalRecord(certificateKey, &CertificateBinding{EnrollmentId: binding.EnrollmentId})
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(certificateKey, certificateAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// DeactivateRevokedCertificate deactivates the did bound with the certificate
+// of given hexadecimal serial number, as listed in the revocation list of the
+// certificate authority of the caller's organization, and returns the binding.
+// Certificates are only looked up among the bindings of the caller's
+// organization. Dids that are already deactivated are left as they are, so
+// revocation lists can be processed again
+func (s *AdminContract) DeactivateRevokedCertificate(ctx contractapi.TransactionContextInterface, serialNumber string) (*IdentityBinding, error) {
+	serial, ok := new(big.Int).SetString(serialNumber, 16)
+
+	if !ok || serial.Sign() < 0 {
+		return nil, newError(codeInvalidArgument, "%q is not a hexadecimal certificate serial number", serialNumber)
+	}
+
+	mspID, err := clientMSPID(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certificateKey, err := certificateBindingKey(ctx, mspID, serial.Text(16))
+
+	if err != nil {
+		return nil, err
+	}
+
+	certificateAsBytes, err := ctx.GetStub().GetState(certificateKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if certificateAsBytes == nil {
+		return nil, newError(codeNotFound, "No did is bound with certificate %s of %s", serial.Text(16), mspID)
+	}
+
+	certificate := CertificateBinding{}
+
+	if err := unmarshalRecord(certificateKey, certificateAsBytes, &certificate); err != nil {
+		return nil, err
+	}
+
+	key, err := identityBindingKey(ctx, certificate.EnrollmentId, mspID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	bindingAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if bindingAsBytes == nil {
+		return nil, newError(codeNotFound, "No did is bound to enrollment id %s", certificate.EnrollmentId)
+	}
+
+	binding := IdentityBinding{}
+
+	if err := unmarshalRecord(key, bindingAsBytes, &binding); err != nil {
+		return nil, err
+	}
+
+	did, err := getDid(ctx, binding.DidNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if did.Deleted || did.Deactivated {
+		return &binding, nil
+	}
+
+	did.Deactivated = true
+
+	if err := putUpdatedDid(ctx, binding.DidNumber, did); err != nil {
+		return nil, err
+	}
+
+	return &binding, nil
+}
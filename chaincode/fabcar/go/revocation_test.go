@@ -0,0 +1,100 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeactivateRevokedCertificate(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	l.attributes[enrollmentIdAttribute] = "user1"
+	l.identity.GetX509CertificateReturns(&x509.Certificate{SerialNumber: big.NewInt(0xabc)}, nil)
+	require.NoError(t, s.RegisterIdentityBinding(l.ctx, id))
+	l.nextTx()
+
+	binding, err := s.GetDidForIdentity(l.ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", binding.CertificateSerial, "should record the serial number of the certificate")
+
+	certificateKey, err := certificateBindingKey(l.ctx, testMSPID, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, 1, schemaVersionOf(t, l.state[certificateKey]), "should store the certificate binding as a versioned record")
+
+	certificate := CertificateBinding{}
+	require.NoError(t, unmarshalRecord(certificateKey, l.state[certificateKey], &certificate))
+	assert.Equal(t, "user1", certificate.EnrollmentId)
+
+	l.setAdmin(true)
+	revoked, err := a.DeactivateRevokedCertificate(l.ctx, "0ABC")
+	require.NoError(t, err, "should deactivate the did bound with the certificate")
+	assert.Equal(t, binding, revoked, "should return the binding")
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.True(t, did.Deactivated, "should deactivate the did")
+
+	_, err = a.DeactivateRevokedCertificate(l.ctx, "abc")
+	assert.Nil(t, err, "should accept certificates of deactivated dids again")
+
+	_, err = a.DeactivateRevokedCertificate(l.ctx, "def")
+	assertErrorCode(t, err, codeNotFound, "should fail for certificates without a binding")
+
+	_, err = a.DeactivateRevokedCertificate(l.ctx, "-1")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject negative serial numbers")
+
+	_, err = a.DeactivateRevokedCertificate(l.ctx, "xyz")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a hexadecimal serial number")
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = a.DeactivateRevokedCertificate(l.ctx, "abc")
+	assertErrorCode(t, err, codeNotFound, "should only look up certificates of the caller's organization")
+
+	l.setClient(testClientID, testMSPID)
+	other := createTestDid(t, l, newTestKey(t))
+	l.identity.GetX509CertificateReturns(&x509.Certificate{SerialNumber: big.NewInt(0xdef)}, nil)
+	require.NoError(t, s.RegisterIdentityBinding(l.ctx, other), "should bind the reenrolled identity to another did")
+	l.nextTx()
+
+	_, err = a.DeactivateRevokedCertificate(l.ctx, "abc")
+	assertErrorCode(t, err, codeNotFound, "should forget the certificate the identity was bound with before")
+
+	_, err = a.DeactivateRevokedCertificate(l.ctx, "def")
+	require.NoError(t, err)
+	l.nextTx()
+
+	did, err = s.QueryDidByKey(l.ctx, other)
+	require.NoError(t, err)
+	assert.True(t, did.Deactivated, "should deactivate the did bound with the new certificate")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = a.DeactivateRevokedCertificate(l.ctx, "def")
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
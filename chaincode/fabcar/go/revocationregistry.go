@@ -0,0 +1,439 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// revocationRegistryObjectType and revocationStateObjectType are the composite
+// key object types under which AnonCreds revocation registries and the versions
+// of their accumulators are stored
+const (
+	revocationRegistryObjectType = "revocationregistry"
+	revocationStateObjectType    = "revocationstate"
+)
+
+// revocationRegistryType is the only type of AnonCreds revocation registries,
+// CL accumulators with tails files
+const revocationRegistryType = "CL_ACCUM"
+
+// maxRevocationRegistrySize is the largest number of credentials a revocation
+// registry may hold, which bounds the size of its revocation states
+const maxRevocationRegistrySize = 32768
+
+// RevocationRegistryValue holds the public accumulator key of an AnonCreds
+// revocation registry and the location of its tails file
+type RevocationRegistryValue struct {
+	PublicKeys    interface{} `json:"publicKeys"`
+	MaxCredNum    int         `json:"maxCredNum"`
+	TailsLocation string      `json:"tailsLocation"`
+	TailsHash     string      `json:"tailsHash"`
+}
+
+// RevocationRegistry describes an AnonCreds revocation registry definition of a
+// credential definition. Id, LatestVersionId and RecordedBy are set by the ledger
+type RevocationRegistry struct {
+	Id              string                  `json:"id"`
+	IssuerId        string                  `json:"issuerId"`
+	RevocDefType    string                  `json:"revocDefType"`
+	CredDefId       string                  `json:"credDefId"`
+	Tag             string                  `json:"tag"`
+	Value           RevocationRegistryValue `json:"value"`
+	LatestVersionId string                  `json:"latestVersionId"`
+	RecordedBy      *ProvenanceEntry        `json:"recordedBy,omitempty" metadata:"recordedBy,optional"`
+}
+
+// AccumulatorUpdate describes an update of the accumulator of a revocation
+// registry, the credential indexes revoked and issued again since the previous
+// version and the accumulator value the issuer computed over them
+type AccumulatorUpdate struct {
+	Accumulator string `json:"accumulator"`
+	Issued      []int  `json:"issued"`
+	Revoked     []int  `json:"revoked"`
+}
+
+// RevocationState describes a version of the accumulator of a revocation
+// registry. RevocationList holds every index revoked at the version and Delta
+// the changes from the previous version, which holders need to update the
+// witnesses of their non-revocation proofs
+type RevocationState struct {
+	RevRegDefId       string            `json:"revRegDefId"`
+	VersionId         string            `json:"versionId"`
+	PreviousVersionId string            `json:"previousVersionId,omitempty" metadata:"previousVersionId,optional"`
+	Accumulator       string            `json:"currentAccumulator"`
+	RevocationList    []int             `json:"revocationList"`
+	Delta             AccumulatorUpdate `json:"delta"`
+	RecordedBy        *ProvenanceEntry  `json:"recordedBy"`
+}
+
+// revocationRegistryId returns the identifier of the revocation registry with
+// given tag of a credential definition, in the AnonCreds did-linked resource format
+func revocationRegistryId(definition *CredentialDefinition, tag string) string {
+	return definition.IssuerId + "/anoncreds/v0/REV_REG_DEF/" + definition.SchemaId + "/" + definition.Tag + "/" + tag
+}
+
+// revocationRegistryKey returns the key of the revocation registry with given id
+func revocationRegistryKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(revocationRegistryObjectType, []string{id})
+}
+
+// revocationStateKey returns the key of the version of a revocation registry
+func revocationStateKey(ctx contractapi.TransactionContextInterface, id string, versionId string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(revocationStateObjectType, []string{id, versionId})
+}
+
+// getRevocationRegistry reads the revocation registry with given id
+func getRevocationRegistry(ctx contractapi.TransactionContextInterface, id string) (*RevocationRegistry, error) {
+	key, err := revocationRegistryKey(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	registryAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if registryAsBytes == nil {
+		return nil, newError(codeNotFound, "Revocation registry %s does not exist", id)
+	}
+
+	registry := new(RevocationRegistry)
+
+	if err := unmarshalRecord(key, registryAsBytes, registry); err != nil {
+		return nil, err
+	}
+
+	return registry, nil
+}
+
+// getRevocationState reads a version of the revocation registry with given id
+func getRevocationState(ctx contractapi.TransactionContextInterface, id string, versionId string) (*RevocationState, error) {
+	key, err := revocationStateKey(ctx, id, versionId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stateAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if stateAsBytes == nil {
+		return nil, newError(codeNotFound, "Version %s of revocation registry %s does not exist", versionId, id)
+	}
+
+	state := new(RevocationState)
+
+	if err := unmarshalRecord(key, stateAsBytes, state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// putRevocationVersion writes a new version of the revocation registry to the
+// world state and makes it the latest version of the registry
+func putRevocationVersion(ctx contractapi.TransactionContextInterface, registry *RevocationRegistry, state *RevocationState) error {
+	stateKey, err := revocationStateKey(ctx, registry.Id, state.VersionId)
+
+	if err != nil {
+		return err
+	}
+
+	stateAsBytes, err := marshalRecord(stateKey, state)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(stateKey, stateAsBytes); err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	registry.LatestVersionId = state.VersionId
+
+	key, err := revocationRegistryKey(ctx, registry.Id)
+
+	if err != nil {
+		return err
+	}
+
+	registryAsBytes, err := marshalRecord(key, registry)
+
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, registryAsBytes); err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// applyAccumulatorUpdate returns the revocation list after the update, and an
+// error unless every index is within the registry, revoked indexes are not
+// already revoked and issued indexes are
+func applyAccumulatorUpdate(registry *RevocationRegistry, revocationList []int, update *AccumulatorUpdate) ([]int, error) {
+	revoked := map[int]bool{}
+
+	for _, index := range revocationList {
+		revoked[index] = true
+	}
+
+	changed := map[int]bool{}
+
+	for _, indexes := range [][]int{update.Revoked, update.Issued} {
+		for _, index := range indexes {
+			if index < 0 || index >= registry.Value.MaxCredNum {
+				return nil, newError(codeInvalidArgument, "Index %d is outside revocation registry %s of size %d", index, registry.Id, registry.Value.MaxCredNum)
+			}
+
+			if changed[index] {
+				return nil, newError(codeInvalidArgument, "Index %d is updated more than once", index)
+			}
+
+			changed[index] = true
+		}
+	}
+
+	for _, index := range update.Revoked {
+		if revoked[index] {
+			return nil, newError(codeAlreadyExists, "Index %d of revocation registry %s is already revoked", index, registry.Id)
+		}
+
+		revoked[index] = true
+	}
+
+	for _, index := range update.Issued {
+		if !revoked[index] {
+			return nil, newError(codeInvalidArgument, "Index %d of revocation registry %s is not revoked", index, registry.Id)
+		}
+
+		delete(revoked, index)
+	}
+
+	result := []int{}
+
+	for index := range revoked {
+		result = append(result, index)
+	}
+
+	sort.Ints(result)
+
+	return result, nil
+}
+
+// RegisterRevocationRegistry registers an AnonCreds revocation registry of a
+// credential definition with revocation keys and returns its identifier. The
+// registry is given as an AnonCreds revocation registry definition object with
+// the members issuerId, revocDefType, credDefId, tag and value. The initial
+// accumulator, with no credential revoked, becomes the first version of the registry
+func (c *CredentialContract) RegisterRevocationRegistry(ctx contractapi.TransactionContextInterface, revocationRegistryJSON string, accumulator string) (string, error) {
+	var registry RevocationRegistry
+
+	if err := decodeStrict([]byte(revocationRegistryJSON), &registry); err != nil {
+		return "", newError(codeInvalidArgument, "revocationRegistryJSON must be an AnonCreds revocation registry definition. %s", err.Error())
+	}
+
+	if registry.Id != "" || registry.LatestVersionId != "" || registry.RecordedBy != nil {
+		return "", newError(codeInvalidArgument, "id, latestVersionId and recordedBy are set by the ledger")
+	}
+
+	if registry.IssuerId == "" || registry.CredDefId == "" || registry.Tag == "" || accumulator == "" {
+		return "", newError(codeInvalidArgument, "issuerId, credDefId, tag and accumulator must not be empty")
+	}
+
+	if strings.Contains(registry.Tag, "/") {
+		return "", newError(codeInvalidArgument, "tag must not contain /")
+	}
+
+	if registry.RevocDefType != revocationRegistryType {
+		return "", newError(codeInvalidArgument, "Unsupported revocation registry type %s, expected %s", registry.RevocDefType, revocationRegistryType)
+	}
+
+	if !isJSONObject(registry.Value.PublicKeys) {
+		return "", newError(codeInvalidArgument, "value.publicKeys must be a JSON object")
+	}
+
+	if registry.Value.MaxCredNum < 1 || registry.Value.MaxCredNum > maxRevocationRegistrySize {
+		return "", newError(codeInvalidArgument, "value.maxCredNum must be between 1 and %d", maxRevocationRegistrySize)
+	}
+
+	if registry.Value.TailsLocation == "" || registry.Value.TailsHash == "" {
+		return "", newError(codeInvalidArgument, "value.tailsLocation and value.tailsHash must not be empty")
+	}
+
+	definition, err := getCredentialDefinition(ctx, registry.CredDefId)
+
+	if err != nil {
+		return "", err
+	}
+
+	if definition.IssuerId != registry.IssuerId {
+		return "", newError(codeInvalidArgument, "Credential definition %s is not issued by %s", registry.CredDefId, registry.IssuerId)
+	}
+
+	if definition.Value.Revocation == nil {
+		return "", newError(codeInvalidArgument, "Credential definition %s does not support revocation", registry.CredDefId)
+	}
+
+	if err := assertActiveIssuerController(ctx, registry.IssuerId); err != nil {
+		return "", err
+	}
+
+	registry.Id = revocationRegistryId(definition, registry.Tag)
+
+	if _, err := getRevocationRegistry(ctx, registry.Id); err == nil {
+		return "", newError(codeAlreadyExists, "Revocation registry %s already exists", registry.Id)
+	}
+
+	registry.RecordedBy, err = newProvenanceEntry(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	state := RevocationState{
+		RevRegDefId:    registry.Id,
+		VersionId:      registry.RecordedBy.TxID,
+		Accumulator:    accumulator,
+		RevocationList: []int{},
+		Delta:          AccumulatorUpdate{Accumulator: accumulator, Issued: []int{}, Revoked: []int{}},
+		RecordedBy:     registry.RecordedBy,
+	}
+
+	if err := putRevocationVersion(ctx, &registry, &state); err != nil {
+		return "", err
+	}
+
+	return registry.Id, nil
+}
+
+// UpdateAccumulator records a new version of the accumulator of a revocation
+// registry of an issuer did controlled by the submitting client and returns its
+// version id. The update lists the indexes revoked and issued again since the
+// latest version together with the new accumulator value
+func (c *CredentialContract) UpdateAccumulator(ctx contractapi.TransactionContextInterface, revocationRegistryId string, updateJSON string) (string, error) {
+	var update AccumulatorUpdate
+
+	if err := decodeStrict([]byte(updateJSON), &update); err != nil {
+		return "", newError(codeInvalidArgument, "updateJSON must be an accumulator update. %s", err.Error())
+	}
+
+	if update.Accumulator == "" {
+		return "", newError(codeInvalidArgument, "accumulator must not be empty")
+	}
+
+	if len(update.Issued) == 0 && len(update.Revoked) == 0 {
+		return "", newError(codeInvalidArgument, "The update must issue or revoke at least one index")
+	}
+
+	registry, err := getRevocationRegistry(ctx, revocationRegistryId)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := assertActiveIssuerController(ctx, registry.IssuerId); err != nil {
+		return "", err
+	}
+
+	latest, err := getRevocationState(ctx, registry.Id, registry.LatestVersionId)
+
+	if err != nil {
+		return "", err
+	}
+
+	revocationList, err := applyAccumulatorUpdate(registry, latest.RevocationList, &update)
+
+	if err != nil {
+		return "", err
+	}
+
+	recordedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	if recordedBy.TxID == latest.VersionId {
+		return "", newError(codeConflict, "Revocation registry %s is already updated in transaction %s", registry.Id, latest.VersionId)
+	}
+
+	if update.Issued == nil {
+		update.Issued = []int{}
+	}
+
+	if update.Revoked == nil {
+		update.Revoked = []int{}
+	}
+
+	sort.Ints(update.Issued)
+	sort.Ints(update.Revoked)
+
+	state := RevocationState{
+		RevRegDefId:       registry.Id,
+		VersionId:         recordedBy.TxID,
+		PreviousVersionId: latest.VersionId,
+		Accumulator:       update.Accumulator,
+		RevocationList:    revocationList,
+		Delta:             update,
+		RecordedBy:        recordedBy,
+	}
+
+	if err := putRevocationVersion(ctx, registry, &state); err != nil {
+		return "", err
+	}
+
+	return state.VersionId, nil
+}
+
+// GetRevocationRegistry returns the AnonCreds revocation registry definition with given id
+func (c *CredentialContract) GetRevocationRegistry(ctx contractapi.TransactionContextInterface, revocationRegistryId string) (*RevocationRegistry, error) {
+	return getRevocationRegistry(ctx, revocationRegistryId)
+}
+
+// GetRevocationState returns the accumulator and revocation list of a revocation
+// registry at the version with given id, or at its latest version if versionId is
+// empty, so holders can prove non-revocation against the state a verifier asks for
+func (c *CredentialContract) GetRevocationState(ctx contractapi.TransactionContextInterface, revocationRegistryId string, versionId string) (*RevocationState, error) {
+	registry, err := getRevocationRegistry(ctx, revocationRegistryId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if versionId == "" {
+		versionId = registry.LatestVersionId
+	}
+
+	return getRevocationState(ctx, registry.Id, versionId)
+}
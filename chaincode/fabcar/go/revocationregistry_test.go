@@ -0,0 +1,187 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRevocationRegistry returns a revocation registry definition of issuer for
+// the credential definition with given id and maxCredNum
+func testRevocationRegistry(issuer string, credDefId string, maxCredNum string) string {
+	return `{"issuerId":"` + issuer + `","revocDefType":"CL_ACCUM","credDefId":"` + credDefId + `","tag":"0",` +
+		`"value":{"publicKeys":{"accumKey":{"z":"1 0BB"}},"maxCredNum":` + maxCredNum + `,` +
+		`"tailsLocation":"https://tails.example.com/5f4e","tailsHash":"5f4e"}}`
+}
+
+// createTestRevocationRegistry registers a revocable credential definition of
+// issuer and a revocation registry of size 100 for it
+func createTestRevocationRegistry(t *testing.T, l *testLedger, issuer string) string {
+	c := new(CredentialContract)
+
+	credDefId, err := c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition(issuer, "default", `{"g":"1 1F"}`))
+	require.NoError(t, err)
+
+	id, err := c.RegisterRevocationRegistry(l.ctx, testRevocationRegistry(issuer, credDefId, "100"), "acc0")
+	require.NoError(t, err)
+	l.nextTx()
+
+	return id
+}
+
+func TestRegisterRevocationRegistry(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuer := createTestDid(t, l, newTestKey(t))
+
+	revocable, err := c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition(issuer, "default", `{"g":"1 1F"}`))
+	require.NoError(t, err)
+
+	id, err := c.RegisterRevocationRegistry(l.ctx, testRevocationRegistry(issuer, revocable, "100"), "acc0")
+	require.NoError(t, err, "should register the revocation registry")
+	assert.Equal(t, issuer+"/anoncreds/v0/REV_REG_DEF/"+testSchemaId+"/default/0", id, "should derive the id from the credential definition and tag")
+
+	registry, err := c.GetRevocationRegistry(l.ctx, id)
+	require.NoError(t, err, "should return the revocation registry")
+	assert.Equal(t, revocable, registry.CredDefId, "should link the credential definition")
+	assert.Equal(t, 100, registry.Value.MaxCredNum)
+	assert.Equal(t, l.stub.GetTxID(), registry.LatestVersionId, "should version the registry by transaction")
+
+	state, err := c.GetRevocationState(l.ctx, id, "")
+	require.NoError(t, err, "should return the initial state")
+	assert.Equal(t, "acc0", state.Accumulator, "should record the initial accumulator")
+	assert.Empty(t, state.RevocationList, "should not revoke any credential initially")
+	assert.Empty(t, state.PreviousVersionId, "should not link the initial state to a previous version")
+
+	_, err = c.RegisterRevocationRegistry(l.ctx, testRevocationRegistry(issuer, revocable, "100"), "acc0")
+	assertErrorCode(t, err, codeAlreadyExists, "should not register a registry twice")
+
+	irrevocable, err := c.RegisterCredentialDefinition(l.ctx, testCredentialDefinition(issuer, "irrevocable", ""))
+	require.NoError(t, err)
+
+	_, err = c.RegisterRevocationRegistry(l.ctx, testRevocationRegistry(issuer, irrevocable, "100"), "acc0")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a credential definition with revocation keys")
+
+	_, err = c.RegisterRevocationRegistry(l.ctx, testRevocationRegistry(issuer, revocable+"1", "100"), "acc0")
+	assertErrorCode(t, err, codeNotFound, "should require a registered credential definition")
+
+	invalid := map[string]string{
+		"should require an accumulator":      "",
+		"should require a positive size":     testRevocationRegistry(issuer, revocable, "0"),
+		"should bound the size":              testRevocationRegistry(issuer, revocable, "32769"),
+		"should reject unknown members":      `{"ver":"1.0"}`,
+		"should not accept ids":              `{"id":"x"}`,
+		"should only accept CL accumulators": `{"issuerId":"` + issuer + `","revocDefType":"RSA","credDefId":"` + revocable + `","tag":"1","value":{"publicKeys":{},"maxCredNum":1,"tailsLocation":"l","tailsHash":"h"}}`,
+		"should require public keys":         `{"issuerId":"` + issuer + `","revocDefType":"CL_ACCUM","credDefId":"` + revocable + `","tag":"1","value":{"maxCredNum":1,"tailsLocation":"l","tailsHash":"h"}}`,
+		"should require a tails file":        `{"issuerId":"` + issuer + `","revocDefType":"CL_ACCUM","credDefId":"` + revocable + `","tag":"1","value":{"publicKeys":{},"maxCredNum":1}}`,
+		"should reject tags with slashes":    `{"issuerId":"` + issuer + `","revocDefType":"CL_ACCUM","credDefId":"` + revocable + `","tag":"1/2","value":{"publicKeys":{},"maxCredNum":1,"tailsLocation":"l","tailsHash":"h"}}`,
+	}
+
+	for message, registryJSON := range invalid {
+		accumulator := "acc0"
+
+		if registryJSON == "" {
+			registryJSON, accumulator = testRevocationRegistry(issuer, revocable, "10"), ""
+		}
+
+		_, err = c.RegisterRevocationRegistry(l.ctx, registryJSON, accumulator)
+		assertErrorCode(t, err, codeInvalidArgument, message)
+	}
+
+	other := createTestDid(t, l, newTestKey(t))
+	_, err = c.RegisterRevocationRegistry(l.ctx, testRevocationRegistry(other, revocable, "100"), "acc0")
+	assertErrorCode(t, err, codeInvalidArgument, "should require the issuer of the credential definition")
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = c.RegisterRevocationRegistry(l.ctx, `{"issuerId":"`+issuer+`","revocDefType":"CL_ACCUM","credDefId":"`+revocable+`","tag":"1","value":{"publicKeys":{},"maxCredNum":1,"tailsLocation":"l","tailsHash":"h"}}`, "acc0")
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the issuer")
+}
+
+func TestUpdateAccumulator(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuer := createTestDid(t, l, newTestKey(t))
+	id := createTestRevocationRegistry(t, l, issuer)
+
+	initial, err := c.GetRevocationState(l.ctx, id, "")
+	require.NoError(t, err)
+
+	first, err := c.UpdateAccumulator(l.ctx, id, `{"accumulator":"acc1","revoked":[42,7]}`)
+	require.NoError(t, err, "should revoke the indexes")
+	assert.Equal(t, l.stub.GetTxID(), first, "should return the version of the update")
+
+	_, err = c.UpdateAccumulator(l.ctx, id, `{"accumulator":"acc2","revoked":[8]}`)
+	assertErrorCode(t, err, codeConflict, "should allow one update per transaction")
+	l.nextTx()
+
+	second, err := c.UpdateAccumulator(l.ctx, id, `{"accumulator":"acc2","issued":[42],"revoked":[99]}`)
+	require.NoError(t, err, "should issue and revoke indexes")
+	l.nextTx()
+
+	state, err := c.GetRevocationState(l.ctx, id, "")
+	require.NoError(t, err)
+	assert.Equal(t, second, state.VersionId, "should return the latest version by default")
+	assert.Equal(t, first, state.PreviousVersionId, "should link the previous version")
+	assert.Equal(t, "acc2", state.Accumulator, "should record the accumulator")
+	assert.Equal(t, []int{7, 99}, state.RevocationList, "should record every revoked index")
+	assert.Equal(t, AccumulatorUpdate{Accumulator: "acc2", Issued: []int{42}, Revoked: []int{99}}, state.Delta, "should record the changes of the version")
+
+	state, err = c.GetRevocationState(l.ctx, id, first)
+	require.NoError(t, err, "should return earlier versions")
+	assert.Equal(t, "acc1", state.Accumulator, "should keep the accumulator of earlier versions")
+	assert.Equal(t, []int{7, 42}, state.RevocationList, "should sort the revoked indexes")
+	assert.Equal(t, initial.VersionId, state.PreviousVersionId)
+	assert.Equal(t, []int{}, state.Delta.Issued, "should record empty changes")
+
+	invalid := map[string]string{
+		"should require an accumulator":     `{"revoked":[1]}`,
+		"should require a change":           `{"accumulator":"acc3"}`,
+		"should bound the indexes":          `{"accumulator":"acc3","revoked":[100]}`,
+		"should reject negative indexes":    `{"accumulator":"acc3","revoked":[-1]}`,
+		"should not update an index twice":  `{"accumulator":"acc3","revoked":[1],"issued":[1]}`,
+		"should only issue revoked indexes": `{"accumulator":"acc3","issued":[1]}`,
+		"should reject unknown members":     `{"accumulator":"acc3","revoked":[1],"prevAccum":"acc2"}`,
+	}
+
+	for message, updateJSON := range invalid {
+		_, err = c.UpdateAccumulator(l.ctx, id, updateJSON)
+		assertErrorCode(t, err, codeInvalidArgument, message)
+	}
+
+	_, err = c.UpdateAccumulator(l.ctx, id, `{"accumulator":"acc3","revoked":[7]}`)
+	assertErrorCode(t, err, codeAlreadyExists, "should not revoke an index twice")
+
+	_, err = c.UpdateAccumulator(l.ctx, id+"1", `{"accumulator":"acc3","revoked":[1]}`)
+	assertErrorCode(t, err, codeNotFound, "should require a registered registry")
+
+	_, err = c.GetRevocationState(l.ctx, id, "tx99")
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown versions")
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = c.UpdateAccumulator(l.ctx, id, `{"accumulator":"acc3","revoked":[1]}`)
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the issuer")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = c.GetRevocationState(l.ctx, id, "")
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
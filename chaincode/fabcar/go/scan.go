@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// scanState calls visit with the key and value of every record stored under
+// the partial composite key of objectType and attributes, in key order, until
+// visit returns false. The world state is read in pages of queryPageSize
+// records and each page is released before the next one is read, so a scan
+// holds a single page at a time. Fabric only runs paginated queries in
+// transactions that are evaluated, so functions that write must not scan
+func scanState(ctx contractapi.TransactionContextInterface, objectType string, attributes []string, visit func(key string, value []byte) (bool, error)) error {
+	bookmark := ""
+
+	for {
+		resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(objectType, attributes, queryPageSize, bookmark)
+
+		if err != nil {
+			return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		}
+
+		more, err := visitPage(resultsIterator, visit)
+		resultsIterator.Close()
+
+		if err != nil || !more {
+			return err
+		}
+
+		if metadata.Bookmark == "" || metadata.Bookmark == bookmark {
+			return nil
+		}
+
+		bookmark = metadata.Bookmark
+	}
+}
+
+// visitPage calls visit with every record of a page, returning false once
+// visit returned false
+func visitPage(resultsIterator shim.StateQueryIteratorInterface, visit func(key string, value []byte) (bool, error)) (bool, error) {
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return false, err
+		}
+
+		more, err := visit(queryResponse.Key, queryResponse.Value)
+
+		if err != nil || !more {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// newTooManyRecordsError returns the error of queries whose results would not
+// fit in the maxQueryRecords records a single call may return
+func newTooManyRecordsError(records string) error {
+	return newError(codeInvalidArgument, "More than %d %s match, the query cannot return them in a single call", maxQueryRecords, records)
+}
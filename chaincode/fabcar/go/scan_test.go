@@ -0,0 +1,99 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanState(t *testing.T) {
+	l := newTestLedger(t)
+	count := int(queryPageSize)*2 + 1
+
+	for i := 0; i < count; i++ {
+		require.NoError(t, putIndexEntry(l.ctx, "scan", "value", fmt.Sprintf("id%03d", i)))
+	}
+
+	keys := []string{}
+	err := scanState(l.ctx, "scan", []string{"value"}, func(key string, value []byte) (bool, error) {
+		keys = append(keys, key)
+		return true, nil
+	})
+	require.NoError(t, err, "should scan every record")
+	require.Len(t, keys, count)
+	first, _ := shim.CreateCompositeKey("scan", []string{"value", "id000"})
+	last, _ := shim.CreateCompositeKey("scan", []string{"value", fmt.Sprintf("id%03d", count-1)})
+	assert.Equal(t, []string{first, last}, []string{keys[0], keys[count-1]}, "should scan in key order")
+
+	assert.Equal(t, 3, l.stub.GetStateByPartialCompositeKeyWithPaginationCallCount(), "should read the records in pages")
+
+	for i := 0; i < l.stub.GetStateByPartialCompositeKeyWithPaginationCallCount(); i++ {
+		_, _, pageSize, _ := l.stub.GetStateByPartialCompositeKeyWithPaginationArgsForCall(i)
+		assert.Equal(t, queryPageSize, pageSize, "should read pages of queryPageSize records")
+	}
+
+	visited := 0
+	err = scanState(l.ctx, "scan", []string{"value"}, func(key string, value []byte) (bool, error) {
+		visited++
+		return visited < 2, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, visited, "should stop once visit returns false")
+	assert.Equal(t, 4, l.stub.GetStateByPartialCompositeKeyWithPaginationCallCount(), "should not read further pages once stopped")
+
+	err = scanState(l.ctx, "scan", []string{"value"}, func(key string, value []byte) (bool, error) {
+		return false, errors.New("visit error")
+	})
+	assert.EqualError(t, err, "visit error", "should return errors of visit")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	err = scanState(l.ctx, "scan", []string{"value"}, func(key string, value []byte) (bool, error) {
+		return true, nil
+	})
+	assert.EqualError(t, err, "Failed to read from world state. GetStateByPartialCompositeKeyWithPagination error", "should return query errors")
+}
+
+func TestBoundedQueries(t *testing.T) {
+	l := newTestLedger(t)
+
+	for i := 0; i <= maxQueryRecords; i++ {
+		require.NoError(t, putIndexEntry(l.ctx, credentialIssuerIndex, "did:example:issuer", fmt.Sprintf("credential%04d", i)))
+	}
+
+	_, err := queryIndexEntries(l.ctx, credentialIssuerIndex, "did:example:issuer")
+	assertErrorCode(t, err, codeInvalidArgument, "should not read more than maxQueryRecords index entries")
+
+	for i := 0; i <= maxQueryRecords; i++ {
+		key, err := policyKey(l.ctx, fmt.Sprintf("Operation%04d", i))
+		require.NoError(t, err)
+
+		policyAsBytes, err := marshalRecord(key, OperationPolicy{Operation: fmt.Sprintf("Operation%04d", i), AllowedMSPs: []string{}})
+		require.NoError(t, err)
+		l.state[key] = policyAsBytes
+	}
+
+	_, err = new(PolicyContract).QueryOperationPolicies(l.ctx)
+	assertErrorCode(t, err, codeInvalidArgument, "should not return more than maxQueryRecords policies")
+}
@@ -0,0 +1,175 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// configObjectType is the composite key object type under which registry
+// configuration is stored
+const configObjectType = "config"
+
+// endpointSchemesConfig names the configuration entry holding the URI schemes
+// allowed in service endpoints
+const endpointSchemesConfig = "endpointSchemes"
+
+// defaultEndpointSchemes are the schemes allowed in service endpoints until a
+// registry administrator configures others
+var defaultEndpointSchemes = []string{"https", "didcomm"}
+
+// schemePattern matches a URI scheme as defined by RFC 3986
+var schemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// EndpointSchemes describes the URI schemes allowed in service endpoints
+type EndpointSchemes struct {
+	Schemes []string `json:"schemes"`
+}
+
+func endpointSchemesKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(configObjectType, []string{endpointSchemesConfig})
+}
+
+// getEndpointSchemes returns the configured endpoint schemes, or the defaults if
+// none have been configured
+func getEndpointSchemes(ctx contractapi.TransactionContextInterface) (*EndpointSchemes, error) {
+	key, err := endpointSchemesKey(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schemesAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if schemesAsBytes == nil {
+		return &EndpointSchemes{Schemes: defaultEndpointSchemes}, nil
+	}
+
+	schemes := new(EndpointSchemes)
+
+	if err := unmarshalRecord(key, schemesAsBytes, schemes); err != nil {
+		return nil, err
+	}
+
+	return schemes, nil
+}
+
+// SetEndpointSchemes replaces the URI schemes allowed in service endpoints with
+// the given JSON array of schemes. Only registry administrators may call it.
+// Stored endpoints are not checked again
+func (a *AdminContract) SetEndpointSchemes(ctx contractapi.TransactionContextInterface, schemesJSON string) error {
+	schemes := EndpointSchemes{Schemes: []string{}}
+
+	if err := decodeStrict([]byte(schemesJSON), &schemes.Schemes); err != nil {
+		return newError(codeInvalidArgument, "Failed to decode schemes. %s", err.Error())
+	}
+
+	if len(schemes.Schemes) == 0 {
+		return newError(codeInvalidArgument, "At least one scheme must be allowed")
+	}
+
+	for i, scheme := range schemes.Schemes {
+		scheme = strings.ToLower(scheme)
+
+		if !schemePattern.MatchString(scheme) {
+			return newError(codeInvalidArgument, "%s is not a valid URI scheme", scheme)
+		}
+
+		schemes.Schemes[i] = scheme
+	}
+
+	key, err := endpointSchemesKey(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	schemesAsBytes, err := marshalRecord(key, schemes)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, schemesAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// QueryEndpointSchemes returns the URI schemes allowed in service endpoints
+func (s *DidContract) QueryEndpointSchemes(ctx contractapi.TransactionContextInterface) (*EndpointSchemes, error) {
+	return getEndpointSchemes(ctx)
+}
+
+// validateServiceEndpoint returns an error unless endpoint is empty or an
+// absolute URI with an allowed scheme within the endpoint length limit.
+// Hierarchical URIs must name a host
+func validateServiceEndpoint(ctx contractapi.TransactionContextInterface, endpoint string) error {
+	if endpoint == "" {
+		return nil
+	}
+
+	if err := assertEndpointLength(ctx, endpoint); err != nil {
+		return err
+	}
+
+	if strings.IndexFunc(endpoint, func(r rune) bool { return r <= ' ' || r == 0x7f }) >= 0 {
+		return newError(codeInvalidArgument, "Service endpoint %q must not contain spaces or control characters", endpoint)
+	}
+
+	endpointURL, err := url.Parse(endpoint)
+
+	if err != nil {
+		return newError(codeInvalidArgument, "Service endpoint %s is not a valid URI. %s", endpoint, err.Error())
+	}
+
+	if endpointURL.Scheme == "" {
+		return newError(codeInvalidArgument, "Service endpoint %s must be an absolute URI", endpoint)
+	}
+
+	if endpointURL.Opaque == "" && endpointURL.Host == "" {
+		return newError(codeInvalidArgument, "Service endpoint %s must name a host", endpoint)
+	}
+
+	schemes, err := getEndpointSchemes(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	for _, scheme := range schemes.Schemes {
+		if endpointURL.Scheme == scheme {
+			return nil
+		}
+	}
+
+	return newError(codeInvalidArgument, "Service endpoint scheme %s is not allowed, expected one of %s", endpointURL.Scheme, strings.Join(schemes.Schemes, ", "))
+}
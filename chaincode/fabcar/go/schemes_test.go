@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetEndpointSchemes(t *testing.T) {
+	l := newTestLedger(t)
+	a := new(AdminContract)
+	s := new(DidContract)
+
+	schemes, err := s.QueryEndpointSchemes(l.ctx)
+	require.NoError(t, err, "should return the default schemes")
+	assert.Equal(t, defaultEndpointSchemes, schemes.Schemes, "should default to https and didcomm")
+
+	err = l.before(a, "SetEndpointSchemes")
+	assertErrorCode(t, err, codeUnauthorized, "should only let administrators set the schemes")
+
+	l.setAdmin(true)
+
+	err = l.before(a, "SetEndpointSchemes")
+	assertErrorCode(t, err, codeNotInitialized, "should require the ledger to be initialized")
+
+	require.NoError(t, a.InitLedger(l.ctx, ""))
+
+	err = l.before(a, "SetEndpointSchemes")
+	require.NoError(t, err, "should let administrators set the schemes")
+
+	err = a.SetEndpointSchemes(l.ctx, `["HTTPS", "ipfs"]`)
+	require.NoError(t, err, "should set the schemes")
+
+	schemes, err = s.QueryEndpointSchemes(l.ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https", "ipfs"}, schemes.Schemes, "should store the schemes in lower case")
+
+	err = a.SetEndpointSchemes(l.ctx, `[]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require at least one scheme")
+
+	err = a.SetEndpointSchemes(l.ctx, `["1http"]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject invalid schemes")
+
+	err = a.SetEndpointSchemes(l.ctx, `"https"`)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a JSON array")
+
+	err = a.SetEndpointSchemes(l.ctx, `["https"] ["http"]`)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject data after the schemes")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = s.QueryEndpointSchemes(l.ctx)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestValidateServiceEndpoint(t *testing.T) {
+	l := newTestLedger(t)
+
+	valid := []string{"", testEndpoint, "didcomm:transport/queue", "https://example.com:8443/vc?x=1"}
+	invalid := []string{"http://example.com", "example.com/vc", "https://", "https://example.com/v c", "https://example.com/\x7f"}
+
+	for _, endpoint := range valid {
+		assert.Nil(t, validateServiceEndpoint(l.ctx, endpoint), "should accept %q", endpoint)
+	}
+
+	for _, endpoint := range invalid {
+		assertErrorCode(t, validateServiceEndpoint(l.ctx, endpoint), codeInvalidArgument, "should reject "+endpoint)
+	}
+
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).SetEndpointSchemes(l.ctx, `["http"]`))
+
+	assert.Nil(t, validateServiceEndpoint(l.ctx, "http://example.com"), "should accept configured schemes")
+	assertErrorCode(t, validateServiceEndpoint(l.ctx, testEndpoint), codeInvalidArgument, "should reject schemes that are no longer allowed")
+}
@@ -0,0 +1,153 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// searchField describes a field SearchDids accepts: the member of the stored
+// did and the name of the CouchDB index on it, which is packaged under
+// META-INF/statedb/couchdb/indexes in the design document of the same name
+// with a Doc suffix
+type searchField struct {
+	member string
+	index  string
+}
+
+// searchFields maps the fields SearchDids accepts to the members of the stored did
+var searchFields = map[string]searchField{
+	"id":              {member: "id", index: "indexId"},
+	"serviceEndpoint": {member: "serviceEndPoint", index: "indexServiceEndPoint"},
+	"controller":      {member: "controller", index: "indexController"},
+	"serviceType":     {member: "serviceType", index: "indexServiceType"},
+}
+
+// searchWildcard marks a search value as a prefix
+const searchWildcard = "*"
+
+// searchSelector returns the CouchDB selector condition matching value, or
+// any string starting with value if it ends with the wildcard. The range lets
+// CouchDB use an index on the member, the regular expression makes the match
+// exact under its Unicode collation
+func searchSelector(value string) interface{} {
+	prefix := strings.TrimSuffix(value, searchWildcard)
+
+	if prefix == value {
+		return value
+	}
+
+	return map[string]string{
+		"$gte":   prefix,
+		"$lt":    prefix + "\ufff0",
+		"$regex": "^" + regexp.QuoteMeta(prefix),
+	}
+}
+
+// searchQuery returns the CouchDB query for the dids whose field matches
+// value, using the index of the field. Did records are the only records with
+// an authenticationId member
+func searchQuery(field searchField, value string) (string, error) {
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"authenticationId": map[string]bool{"$exists": true},
+			"deleted":          map[string]bool{"$ne": true},
+			field.member:       searchSelector(value),
+		},
+		"use_index": []string{"_design/" + field.index + "Doc", field.index},
+		"limit":     maxQueryRecords,
+	}
+
+	queryAsBytes, err := json.Marshal(query)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryAsBytes), nil
+}
+
+// SearchDids returns at most maxQueryRecords dids whose field has the given
+// value, or starts with it if value ends with *. The field is one of id,
+// serviceEndpoint, controller and serviceType. Deleted dids are not returned.
+// It requires CouchDB as the state database
+func (s *DidContract) SearchDids(ctx contractapi.TransactionContextInterface, field string, value string) ([]QueryResult, error) {
+	searched, ok := searchFields[field]
+
+	if !ok {
+		fields := []string{}
+
+		for name := range searchFields {
+			fields = append(fields, name)
+		}
+
+		sort.Strings(fields)
+
+		return nil, newError(codeInvalidArgument, "%s cannot be searched, expected one of %s", field, strings.Join(fields, ", "))
+	}
+
+	if strings.TrimSuffix(value, searchWildcard) == "" {
+		return nil, newError(codeInvalidArgument, "Search value must not be empty")
+	}
+
+	query, err := searchQuery(searched, value)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query)
+
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	results := []QueryResult{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, err
+		}
+
+		objectType, _, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+
+		if err != nil || objectType != didObjectType {
+			continue
+		}
+
+		result, err := didQueryResult(ctx, queryResponse.Key, queryResponse.Value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, *result)
+	}
+
+	return results, nil
+}
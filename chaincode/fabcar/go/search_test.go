@@ -0,0 +1,187 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// matchCondition reports whether a member of a record satisfies a condition
+// of a CouchDB selector, supporting the operators used by the chaincode.
+// Strings are compared by bytes rather than by Unicode collation
+func matchCondition(value interface{}, present bool, condition interface{}) bool {
+	operators, ok := condition.(map[string]interface{})
+
+	if !ok {
+		return present && value == condition
+	}
+
+	for operator, operand := range operators {
+		text, _ := value.(string)
+
+		switch operator {
+		case "$exists":
+			if present != operand.(bool) {
+				return false
+			}
+		case "$ne":
+			if present && value == operand {
+				return false
+			}
+		case "$gte":
+			if !present || text < operand.(string) {
+				return false
+			}
+		case "$lt":
+			if !present || text >= operand.(string) {
+				return false
+			}
+		case "$regex":
+			if !present || !regexp.MustCompile(operand.(string)).MatchString(text) {
+				return false
+			}
+		default:
+			panic("unsupported selector operator " + operator)
+		}
+	}
+
+	return true
+}
+
+// enableRichQueries answers GetQueryResult with the JSON records of the world
+// state matching the selector of the query, in key order
+func (l *testLedger) enableRichQueries(t *testing.T) {
+	l.stub.GetQueryResultCalls(func(query string) (shim.StateQueryIteratorInterface, error) {
+		var parsed struct {
+			Selector map[string]interface{} `json:"selector"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(query), &parsed), "should pass a JSON query")
+
+		kvs := []*queryresult.KV{}
+
+		for _, kv := range l.prefixKVs("") {
+			record := map[string]interface{}{}
+
+			if json.Unmarshal(kv.Value, &record) != nil {
+				continue
+			}
+
+			matches := true
+
+			for member, condition := range parsed.Selector {
+				value, present := record[member]
+				matches = matches && matchCondition(value, present, condition)
+			}
+
+			if matches {
+				kvs = append(kvs, kv)
+			}
+		}
+
+		return newStateIterator(kvs), nil
+	})
+}
+
+func TestSearchDids(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	l.enableRichQueries(t)
+
+	first := createTestDid(t, l, newTestKey(t))
+	secondKey := newTestKey(t)
+	second := createTestDid(t, l, secondKey)
+	other, err := s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#hub", "IdentityHub", "https://hub.example.org/", "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	results, err := s.SearchDids(l.ctx, "serviceType", "VerifiableCredentialService")
+	require.NoError(t, err, "should search by exact value")
+	assert.ElementsMatch(t, []string{first, second}, resultKeys(results), "should return the matching dids")
+
+	results, err = s.SearchDids(l.ctx, "serviceEndpoint", "https://hub.example.*")
+	require.NoError(t, err, "should search by prefix")
+	assert.Equal(t, []string{other}, resultKeys(results), "should match the prefix literally")
+
+	results, err = s.SearchDids(l.ctx, "serviceEndpoint", "https://hub.example")
+	require.NoError(t, err)
+	assert.Empty(t, results, "should not match prefixes without the wildcard")
+
+	results, err = s.SearchDids(l.ctx, "serviceEndpoint", "https://hub.example-org*")
+	require.NoError(t, err)
+	assert.Empty(t, results, "should quote the prefix in the regular expression")
+
+	results, err = s.SearchDids(l.ctx, "id", first)
+	require.NoError(t, err)
+	assert.Equal(t, []string{first}, resultKeys(results), "should search by id")
+
+	results, err = s.SearchDids(l.ctx, "controller", testClientID)
+	require.NoError(t, err)
+	assert.Len(t, results, 3, "should search by controller")
+
+	deleteTestDid(t, l, secondKey, second)
+
+	results, err = s.SearchDids(l.ctx, "serviceType", "VerifiableCredentialService")
+	require.NoError(t, err)
+	assert.Equal(t, []string{first}, resultKeys(results), "should not return deleted dids")
+
+	_, err = s.SearchDids(l.ctx, "authenticationPublicKeyPerm", "x")
+	assertErrorCode(t, err, codeInvalidArgument, "should only search the supported fields")
+
+	_, err = s.SearchDids(l.ctx, "serviceType", "*")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a value")
+}
+
+func TestSearchQuery(t *testing.T) {
+	query, err := searchQuery(searchFields["serviceEndpoint"], "https://a.example/*")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"selector":{"authenticationId":{"$exists":true},"deleted":{"$ne":true},
+		"serviceEndPoint":{"$gte":"https://a.example/","$lt":"https://a.example/\ufff0","$regex":"^https://a\\.example/"}},
+		"use_index":["_design/indexServiceEndPointDoc","indexServiceEndPoint"],"limit":1000}`,
+		query, "should select the prefix by range and regular expression")
+}
+
+func TestSearchIndexes(t *testing.T) {
+	for field, searched := range searchFields {
+		indexAsBytes, err := ioutil.ReadFile(filepath.Join("META-INF", "statedb", "couchdb", "indexes", searched.index+".json"))
+		require.NoError(t, err, "should package the index of %s", field)
+
+		var index struct {
+			Index struct {
+				Fields []string `json:"fields"`
+			} `json:"index"`
+			Ddoc string `json:"ddoc"`
+			Name string `json:"name"`
+			Type string `json:"type"`
+		}
+		require.NoError(t, json.Unmarshal(indexAsBytes, &index), "should define the index of %s as JSON", field)
+		assert.Equal(t, []string{searched.member}, index.Index.Fields, "should index the member of %s", field)
+		assert.Equal(t, searched.index+"Doc", index.Ddoc, "should name the design document of %s", field)
+		assert.Equal(t, searched.index, index.Name, "should name the index of %s", field)
+		assert.Equal(t, "json", index.Type)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	"github.com/hyperledger/fabric-contract-api-go/serializer"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// documentSerializer is the transaction serializer of the chaincode. Arguments
+// are converted as by the JSONSerializer of the contract API. Struct, slice and
+// map results are returned as canonical JSON: object members ordered by key,
+// members that are null left out as if omitted, and no HTML escaping so that
+// service endpoints and did urls are returned as they are stored
+type documentSerializer struct {
+	serializer.JSONSerializer
+}
+
+// ToString returns the string form of the result of a transaction function,
+// validated against the schema of its return type
+func (ds *documentSerializer) ToString(result reflect.Value, resultType reflect.Type, returns *metadata.ReturnMetadata, components *metadata.ComponentMetadata) (string, error) {
+	if !isDocumentType(resultType) || isNilResult(result) {
+		return ds.JSONSerializer.ToString(result, resultType, returns, components)
+	}
+
+	documentAsBytes, err := canonicalJSON(result.Interface())
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode result. %s", err.Error())
+	}
+
+	if returns != nil && returns.CompiledSchema != nil {
+		if err := validateResult(documentAsBytes, returns.CompiledSchema); err != nil {
+			return "", err
+		}
+	}
+
+	return string(documentAsBytes), nil
+}
+
+// isDocumentType reports whether values of typ are returned as JSON documents
+func isDocumentType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	case reflect.Ptr:
+		return isDocumentType(typ.Elem())
+	default:
+		return false
+	}
+}
+
+// isNilResult reports whether result is a nil pointer, slice or map
+func isNilResult(result reflect.Value) bool {
+	switch result.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return result.IsNil()
+	default:
+		return false
+	}
+}
+
+// validateResult returns an error unless document matches the compiled schema
+// of a return value
+func validateResult(document []byte, schema *gojsonschema.Schema) error {
+	toValidate := append(append([]byte(`{"return":`), document...), '}')
+
+	result, err := schema.Validate(gojsonschema.NewBytesLoader(toValidate))
+
+	if err != nil {
+		return fmt.Errorf("Failed to validate result. %s", err.Error())
+	}
+
+	if !result.Valid() {
+		messages := []string{}
+
+		for _, resultErr := range result.Errors() {
+			messages = append(messages, resultErr.String())
+		}
+
+		sort.Strings(messages)
+
+		return fmt.Errorf("Value did not match schema:\n%s", strings.Join(messages, "\n"))
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// toString serializes result as a return value of its own type
+func toString(result interface{}, returns *metadata.ReturnMetadata) (string, error) {
+	value := reflect.ValueOf(result)
+
+	return new(documentSerializer).ToString(value, value.Type(), returns, nil)
+}
+
+func TestDocumentSerializer(t *testing.T) {
+	result := &DidResolutionResult{
+		DidDocument: &DidDocument{
+			Context:        []string{didContext},
+			Id:             "did:example:123",
+			Authentication: []string{"did:example:123#keys-1"},
+			Service:        []Service{Service{Id: "#vcs", Type: "LinkedDomains", ServiceEndpoint: "https://example.com/?a=1&b=2"}},
+		},
+		DidDocumentMetadata:   &DidDocumentMetadata{Created: "2020-06-01T12:00:00Z"},
+		DidResolutionMetadata: &DidResolutionMetadata{ContentType: didContentType},
+	}
+
+	str, err := toString(result, nil)
+	require.NoError(t, err)
+	assert.Equal(t, `{"didDocument":{"@context":["https://www.w3.org/ns/did/v1"],"authentication":["did:example:123#keys-1"],`+
+		`"id":"did:example:123","service":[{"id":"#vcs","serviceEndpoint":"https://example.com/?a=1&b=2","type":"LinkedDomains"}]},`+
+		`"didDocumentMetadata":{"created":"2020-06-01T12:00:00Z","deactivated":false},"didResolutionMetadata":{"contentType":"application/did+ld+json"}}`,
+		str, "should return canonical JSON without null members")
+
+	str, err = toString((*DidResolutionResult)(nil), nil)
+	require.NoError(t, err)
+	assert.Empty(t, str, "should return nil results as empty")
+
+	str, err = toString("DID1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "DID1", str, "should leave basic types to the JSON serializer")
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewStringLoader(`{"properties":{"return":{"type":"object","required":["didDocument"]}}}`))
+	require.NoError(t, err)
+
+	_, err = toString(result, &metadata.ReturnMetadata{CompiledSchema: schema})
+	assert.Nil(t, err, "should accept results matching the schema")
+
+	_, err = toString(failedResolution(resolutionNotFound), &metadata.ReturnMetadata{CompiledSchema: schema})
+	assert.Contains(t, err.Error(), "Value did not match schema", "should validate results against the schema")
+	assert.Contains(t, err.Error(), "didDocument is required", "should name the failing member")
+}
+
+func TestChaincodeSerializer(t *testing.T) {
+	chaincode, err := newChaincode()
+	require.NoError(t, err)
+	assert.IsType(t, new(documentSerializer), chaincode.TransactionSerializer, "should return results with the document serializer")
+
+	stub := newCodedStub(t)
+	captureAuditLog()
+	defer restoreAuditLog()
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("Resolve"), []byte("did:example:unknown"), []byte("false")})
+	require.Equal(t, int32(shim.OK), response.Status, "should resolve unknown dids to an error result")
+	assert.Equal(t, `{"didDocumentMetadata":{"deactivated":false},"didResolutionMetadata":{"contentType":"application/did+ld+json","error":"notFound"}}`,
+		string(response.Payload), "should return canonical JSON")
+}
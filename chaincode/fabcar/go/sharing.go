@@ -0,0 +1,423 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SharingContract records bilateral agreements between organizations on the
+// private did fields one of them shares with the other
+type SharingContract struct {
+	contractapi.Contract
+	AdminAccess
+}
+
+// sharingAgreementObjectType is the composite key object type under which
+// sharing agreements are stored, keyed by the MSP IDs of the owning and the
+// recipient organization
+const sharingAgreementObjectType = "sharingAgreement"
+
+// Names of the chaincode events emitted when a sharing agreement takes effect
+// and when it is revoked
+const (
+	sharingGrantedEvent = "sharing.agreement.granted"
+	sharingRevokedEvent = "sharing.agreement.revoked"
+)
+
+// Names of the private did fields an agreement can share. Personal data fields
+// are named by personalDataFieldPrefix and the field name, allPersonalDataFields
+// shares all of them
+const (
+	authenticationKeyField  = "authenticationPublicKeyPerm"
+	personalDataFieldPrefix = "personalData."
+	allPersonalDataFields   = "personalData.*"
+)
+
+// SharingAgreement describes which private fields of the dids in the namespace
+// of the owning organization the recipient organization may read. The owner
+// proposes the agreement, which takes effect once the recipient accepts it and
+// ends when either revokes it or it expires. Each direction of sharing is a
+// separate agreement
+type SharingAgreement struct {
+	OwnerMSPID     string           `json:"ownerMspId"`
+	RecipientMSPID string           `json:"recipientMspId"`
+	Fields         []string         `json:"fields"`
+	Expires        string           `json:"expires,omitempty" metadata:"expires,optional"`
+	ProposedBy     *ProvenanceEntry `json:"proposedBy"`
+	AcceptedBy     *ProvenanceEntry `json:"acceptedBy,omitempty" metadata:"acceptedBy,optional"`
+	RevokedBy      *ProvenanceEntry `json:"revokedBy,omitempty" metadata:"revokedBy,optional"`
+}
+
+// isActive reports whether the agreement was accepted and neither revoked nor
+// expired at now
+func (a *SharingAgreement) isActive(now time.Time) bool {
+	return a.AcceptedBy != nil && a.RevokedBy == nil && !a.isExpired(now)
+}
+
+// isExpired reports whether the agreement has an expiry that is not after now
+func (a *SharingAgreement) isExpired(now time.Time) bool {
+	if a.Expires == "" {
+		return false
+	}
+
+	expiry, err := time.Parse(time.RFC3339, a.Expires)
+
+	return err != nil || !expiry.After(now)
+}
+
+// covers reports whether the agreement shares the private field
+func (a *SharingAgreement) covers(field string) bool {
+	if containsString(a.Fields, field) {
+		return true
+	}
+
+	return strings.HasPrefix(field, personalDataFieldPrefix) && containsString(a.Fields, allPersonalDataFields)
+}
+
+func sharingAgreementKey(ctx contractapi.TransactionContextInterface, ownerMSPID string, recipientMSPID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(sharingAgreementObjectType, []string{ownerMSPID, recipientMSPID})
+}
+
+// getSharingAgreement reads the agreement of the owning organization with the
+// recipient, or nil if they have none
+func getSharingAgreement(ctx contractapi.TransactionContextInterface, ownerMSPID string, recipientMSPID string) (*SharingAgreement, error) {
+	key, err := sharingAgreementKey(ctx, ownerMSPID, recipientMSPID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	agreementAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if agreementAsBytes == nil {
+		return nil, nil
+	}
+
+	agreement := new(SharingAgreement)
+
+	if err := unmarshalRecord(key, agreementAsBytes, agreement); err != nil {
+		return nil, err
+	}
+
+	return agreement, nil
+}
+
+// putSharingAgreement writes the agreement to the world state and, if event is
+// not empty, emits it with the agreement as payload
+func putSharingAgreement(ctx contractapi.TransactionContextInterface, agreement *SharingAgreement, event string) error {
+	key, err := sharingAgreementKey(ctx, agreement.OwnerMSPID, agreement.RecipientMSPID)
+
+	if err != nil {
+		return err
+	}
+
+	agreementAsBytes, err := marshalRecord(key, agreement)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, agreementAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	if event == "" {
+		return nil
+	}
+
+	eventAsBytes, err := canonicalJSON(agreement)
+
+	if err != nil {
+		return fmt.Errorf("Failed to encode event %s. %s", event, err.Error())
+	}
+
+	err = ctx.GetStub().SetEvent(event, eventAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to set event %s. %s", event, err.Error())
+	}
+
+	return nil
+}
+
+// decodeSharedFields decodes a JSON array of private did fields, each listed
+// once
+func decodeSharedFields(fieldsJSON string) ([]string, error) {
+	fields := []string{}
+
+	if err := decodeStrict([]byte(fieldsJSON), &fields); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode fields. %s", err.Error())
+	}
+
+	if len(fields) == 0 {
+		return nil, newError(codeInvalidArgument, "An agreement must share at least one field")
+	}
+
+	for i, field := range fields {
+		if field != authenticationKeyField && (!strings.HasPrefix(field, personalDataFieldPrefix) || field == personalDataFieldPrefix) {
+			return nil, newError(codeInvalidArgument, "%s is not a private did field, expected %s or %s<field>", field, authenticationKeyField, personalDataFieldPrefix)
+		}
+
+		if containsString(fields[:i], field) {
+			return nil, newError(codeInvalidArgument, "%s is listed more than once", field)
+		}
+	}
+
+	return fields, nil
+}
+
+// ProposeSharingAgreement proposes to share the given JSON array of private
+// fields of the dids in the namespace of the submitting client's organization
+// with the recipient organization, until expires if it is not empty. A pending
+// proposal to the recipient is replaced, an active agreement must be revoked
+// first
+func (c *SharingContract) ProposeSharingAgreement(ctx contractapi.TransactionContextInterface, recipientMSPID string, fieldsJSON string,
+	expires string) (*SharingAgreement, error) {
+	fields, err := decodeSharedFields(fieldsJSON)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateExpires(ctx, expires); err != nil {
+		return nil, err
+	}
+
+	proposedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if recipientMSPID == "" || recipientMSPID == proposedBy.MSPID {
+		return nil, newError(codeInvalidArgument, "The recipient must be another organization")
+	}
+
+	existing, err := getSharingAgreement(ctx, proposedBy.MSPID, recipientMSPID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil && existing.isActive(now) {
+		return nil, newError(codeConflict, "%s already shares fields with %s, revoke the agreement first", proposedBy.MSPID, recipientMSPID)
+	}
+
+	agreement := SharingAgreement{
+		OwnerMSPID:     proposedBy.MSPID,
+		RecipientMSPID: recipientMSPID,
+		Fields:         fields,
+		Expires:        expires,
+		ProposedBy:     proposedBy,
+	}
+
+	if err := putSharingAgreement(ctx, &agreement, ""); err != nil {
+		return nil, err
+	}
+
+	return &agreement, nil
+}
+
+// AcceptSharingAgreement accepts the agreement proposed by the owning
+// organization to the submitting client's organization, which takes effect and
+// emits a sharing.agreement.granted event
+func (c *SharingContract) AcceptSharingAgreement(ctx contractapi.TransactionContextInterface, ownerMSPID string) (*SharingAgreement, error) {
+	acceptedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	agreement, err := getSharingAgreement(ctx, ownerMSPID, acceptedBy.MSPID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if agreement == nil {
+		return nil, newError(codeNotFound, "%s proposed no agreement to %s", ownerMSPID, acceptedBy.MSPID)
+	}
+
+	if agreement.RevokedBy != nil {
+		return nil, newError(codeConflict, "The agreement of %s with %s was revoked", ownerMSPID, acceptedBy.MSPID)
+	}
+
+	if agreement.AcceptedBy != nil {
+		return nil, newError(codeConflict, "The agreement of %s with %s was already accepted", ownerMSPID, acceptedBy.MSPID)
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if agreement.isExpired(now) {
+		return nil, newError(codeConflict, "The agreement of %s with %s has expired", ownerMSPID, acceptedBy.MSPID)
+	}
+
+	agreement.AcceptedBy = acceptedBy
+
+	if err := putSharingAgreement(ctx, agreement, sharingGrantedEvent); err != nil {
+		return nil, err
+	}
+
+	return agreement, nil
+}
+
+// RevokeSharingAgreement revokes the agreement of the owning organization with
+// the recipient, or rejects it if it is still proposed, and emits a
+// sharing.agreement.revoked event. Either organization may revoke it. The
+// agreement is kept with the revocation
+func (c *SharingContract) RevokeSharingAgreement(ctx contractapi.TransactionContextInterface, ownerMSPID string, recipientMSPID string) (*SharingAgreement, error) {
+	revokedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedBy.MSPID != ownerMSPID && revokedBy.MSPID != recipientMSPID {
+		return nil, newError(codeUnauthorized, "Only %s and %s may revoke their agreement", ownerMSPID, recipientMSPID)
+	}
+
+	agreement, err := getSharingAgreement(ctx, ownerMSPID, recipientMSPID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if agreement == nil {
+		return nil, newError(codeNotFound, "%s has no agreement with %s", ownerMSPID, recipientMSPID)
+	}
+
+	if agreement.RevokedBy != nil {
+		return nil, newError(codeConflict, "The agreement of %s with %s was already revoked", ownerMSPID, recipientMSPID)
+	}
+
+	agreement.RevokedBy = revokedBy
+
+	if err := putSharingAgreement(ctx, agreement, sharingRevokedEvent); err != nil {
+		return nil, err
+	}
+
+	return agreement, nil
+}
+
+// QuerySharingAgreement returns the agreement of the owning organization with
+// the recipient
+func (c *SharingContract) QuerySharingAgreement(ctx contractapi.TransactionContextInterface, ownerMSPID string, recipientMSPID string) (*SharingAgreement, error) {
+	agreement, err := getSharingAgreement(ctx, ownerMSPID, recipientMSPID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if agreement == nil {
+		return nil, newError(codeNotFound, "%s has no agreement with %s", ownerMSPID, recipientMSPID)
+	}
+
+	return agreement, nil
+}
+
+// QuerySharingAgreementsByOwner returns the agreements of the owning
+// organization with every recipient, including pending, revoked and expired
+// ones
+func (c *SharingContract) QuerySharingAgreementsByOwner(ctx contractapi.TransactionContextInterface, ownerMSPID string) ([]*SharingAgreement, error) {
+	agreements := []*SharingAgreement{}
+
+	err := scanState(ctx, sharingAgreementObjectType, []string{ownerMSPID}, func(key string, value []byte) (bool, error) {
+		if len(agreements) == maxQueryRecords {
+			return false, newTooManyRecordsError("agreements")
+		}
+
+		agreement := new(SharingAgreement)
+
+		if err := unmarshalRecord(key, value, agreement); err != nil {
+			return false, err
+		}
+
+		agreements = append(agreements, agreement)
+
+		return true, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return agreements, nil
+}
+
+// readerAgreement returns the active agreement under which the submitting
+// client's organization reads the private data of the did stored with given
+// key, or nil if the did is in its namespace or in no namespace. Organizations
+// without an active agreement with the owner are not authorized
+func readerAgreement(ctx contractapi.TransactionContextInterface, didNumber string) (*SharingAgreement, error) {
+	namespace, err := getDidNamespace(ctx, didNumber)
+
+	if err != nil || namespace == nil {
+		return nil, err
+	}
+
+	mspID, err := clientMSPID(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if namespace.MSPID == mspID {
+		return nil, nil
+	}
+
+	agreement, err := getSharingAgreement(ctx, namespace.MSPID, mspID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if agreement == nil || !agreement.isActive(now) {
+		return nil, newError(codeUnauthorized, "%s has no active agreement to read the private data of %s from %s", mspID, didNumber, namespace.MSPID)
+	}
+
+	return agreement, nil
+}
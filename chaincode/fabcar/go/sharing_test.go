@@ -0,0 +1,201 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSharingAgreement(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(SharingContract)
+
+	agreement, err := c.ProposeSharingAgreement(l.ctx, otherMSPID, `["authenticationPublicKeyPerm", "personalData.*"]`, "")
+	require.NoError(t, err, "should propose the agreement")
+	assert.Equal(t, testMSPID, agreement.OwnerMSPID, "should share the data of the proposing organization")
+	assert.Nil(t, agreement.AcceptedBy, "should wait for the recipient")
+	assert.Zero(t, l.stub.SetEventCallCount(), "should not emit events for proposals")
+	l.nextTx()
+
+	_, err = c.ProposeSharingAgreement(l.ctx, otherMSPID, `["personalData.name"]`, "")
+	require.NoError(t, err, "should replace pending proposals")
+	l.nextTx()
+
+	_, err = c.AcceptSharingAgreement(l.ctx, testMSPID)
+	assertErrorCode(t, err, codeNotFound, "should only let the recipient accept")
+
+	l.setClient(otherClientID, otherMSPID)
+	agreement, err = c.AcceptSharingAgreement(l.ctx, testMSPID)
+	require.NoError(t, err, "should accept the agreement")
+	assert.Equal(t, []string{"personalData.name"}, agreement.Fields, "should accept the latest proposal")
+	assert.Equal(t, otherMSPID, agreement.AcceptedBy.MSPID, "should record the acceptance")
+
+	name, payload := l.event(t)
+	assert.Equal(t, sharingGrantedEvent, name, "should emit an event when the agreement takes effect")
+	event := SharingAgreement{}
+	require.NoError(t, json.Unmarshal(payload, &event))
+	assert.Equal(t, *agreement, event, "should carry the agreement")
+	l.nextTx()
+
+	_, err = c.AcceptSharingAgreement(l.ctx, testMSPID)
+	assertErrorCode(t, err, codeConflict, "should not accept twice")
+
+	l.setClient(testClientID, testMSPID)
+	_, err = c.ProposeSharingAgreement(l.ctx, otherMSPID, `["personalData.*"]`, "")
+	assertErrorCode(t, err, codeConflict, "should require active agreements to be revoked first")
+
+	agreement, err = c.QuerySharingAgreement(l.ctx, testMSPID, otherMSPID)
+	require.NoError(t, err)
+	assert.NotNil(t, agreement.AcceptedBy, "should return the agreement")
+
+	l.setClient("x509::CN=user3", "Org3MSP")
+	_, err = c.RevokeSharingAgreement(l.ctx, testMSPID, otherMSPID)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the parties revoke")
+
+	l.setClient(otherClientID, otherMSPID)
+	agreement, err = c.RevokeSharingAgreement(l.ctx, testMSPID, otherMSPID)
+	require.NoError(t, err, "should let the recipient revoke")
+	assert.Equal(t, otherMSPID, agreement.RevokedBy.MSPID, "should record the revocation")
+
+	name, _ = l.event(t)
+	assert.Equal(t, sharingRevokedEvent, name, "should emit an event on revocation")
+	l.nextTx()
+
+	_, err = c.RevokeSharingAgreement(l.ctx, testMSPID, otherMSPID)
+	assertErrorCode(t, err, codeConflict, "should not revoke twice")
+
+	_, err = c.AcceptSharingAgreement(l.ctx, testMSPID)
+	assertErrorCode(t, err, codeConflict, "should not accept revoked agreements")
+
+	l.setClient(testClientID, testMSPID)
+	expires := testStart.Add(time.Hour).Format(time.RFC3339)
+	_, err = c.ProposeSharingAgreement(l.ctx, otherMSPID, `["personalData.*"]`, expires)
+	require.NoError(t, err, "should propose again once revoked")
+	_, err = c.ProposeSharingAgreement(l.ctx, "Org3MSP", `["authenticationPublicKeyPerm"]`, "")
+	require.NoError(t, err)
+
+	agreements, err := c.QuerySharingAgreementsByOwner(l.ctx, testMSPID)
+	require.NoError(t, err)
+	assert.Len(t, agreements, 2, "should return the agreements with every recipient")
+
+	l.setClient(otherClientID, otherMSPID)
+	l.setTime(testStart.Add(2 * time.Hour))
+	_, err = c.AcceptSharingAgreement(l.ctx, testMSPID)
+	assertErrorCode(t, err, codeConflict, "should not accept expired proposals")
+
+	invalid := map[string]string{
+		`[]`:                                    "should share at least one field",
+		`["serviceEndPoint"]`:                   "should only share private fields",
+		`["personalData."]`:                     "should require a personal data field name",
+		`["personalData.a", "personalData.a"]`:  "should reject duplicate fields",
+		`"personalData.*"`:                      "should require a JSON array",
+		`["personalData.a"] ["personalData.b"]`: "should reject data after the fields",
+	}
+
+	for fields, message := range invalid {
+		_, err = c.ProposeSharingAgreement(l.ctx, testMSPID, fields, "")
+		assertErrorCode(t, err, codeInvalidArgument, message)
+	}
+
+	_, err = c.ProposeSharingAgreement(l.ctx, otherMSPID, `["personalData.*"]`, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require another organization")
+
+	_, err = c.QuerySharingAgreement(l.ctx, otherMSPID, testMSPID)
+	assertErrorCode(t, err, codeNotFound, "should fail for organizations without an agreement")
+
+	l.stub.GetStateReturns(nil, errors.New("GetState error"))
+	_, err = c.QuerySharingAgreement(l.ctx, testMSPID, otherMSPID)
+	assert.EqualError(t, err, "Failed to read from world state. GetState error", "should return ledger errors")
+}
+
+func TestPrivateReadsCheckSharingAgreement(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	c := new(SharingContract)
+	createPrivateDid(t, l, newTestKey(t))
+
+	setPersonalData(l, `{"fields":{"name":"Alice Example","email":"alice@example.com"},"salt":"s1"}`)
+	require.NoError(t, s.SetDidPersonalData(l.ctx, "DID5"))
+	l.nextTx()
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err := s.QueryDidPrivate(l.ctx, "DID5")
+	assertErrorCode(t, err, codeUnauthorized, "should require an agreement of other organizations")
+
+	_, err = s.QueryDidPersonalData(l.ctx, "DID5")
+	assertErrorCode(t, err, codeUnauthorized, "should require an agreement of other organizations")
+
+	l.setClient(testClientID, testMSPID)
+	_, err = c.ProposeSharingAgreement(l.ctx, otherMSPID, `["personalData.name"]`, "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = s.QueryDidPersonalData(l.ctx, "DID5")
+	assertErrorCode(t, err, codeUnauthorized, "should require the agreement to be accepted")
+
+	_, err = c.AcceptSharingAgreement(l.ctx, testMSPID)
+	require.NoError(t, err)
+	l.nextTx()
+
+	data, err := s.QueryDidPersonalData(l.ctx, "DID5")
+	require.NoError(t, err, "should share the personal data under the agreement")
+	assert.Equal(t, map[string]string{"name": "Alice Example"}, data.Fields, "should only return the shared fields")
+
+	_, err = s.QueryDidPrivate(l.ctx, "DID5")
+	assertErrorCode(t, err, codeUnauthorized, "should not share fields the agreement does not cover")
+
+	_, err = c.RevokeSharingAgreement(l.ctx, testMSPID, otherMSPID)
+	require.NoError(t, err)
+	l.nextTx()
+
+	_, err = s.QueryDidPersonalData(l.ctx, "DID5")
+	assertErrorCode(t, err, codeUnauthorized, "should stop sharing once revoked")
+
+	l.setClient(testClientID, testMSPID)
+	_, err = c.ProposeSharingAgreement(l.ctx, otherMSPID, `["authenticationPublicKeyPerm", "personalData.*"]`, "")
+	require.NoError(t, err)
+	l.nextTx()
+
+	l.setClient(otherClientID, otherMSPID)
+	_, err = c.AcceptSharingAgreement(l.ctx, testMSPID)
+	require.NoError(t, err)
+	l.nextTx()
+
+	did, err := s.QueryDidPrivate(l.ctx, "DID5")
+	require.NoError(t, err, "should share the authentication key under the agreement")
+	assert.NotEmpty(t, did.AuthenticationPublicKeyPerm)
+
+	data, err = s.QueryDidPersonalData(l.ctx, "DID5")
+	require.NoError(t, err)
+	assert.Len(t, data.Fields, 2, "should share every personal data field")
+
+	l.setClient(testClientID, testMSPID)
+	_, err = c.RevokeSharingAgreement(l.ctx, testMSPID, otherMSPID)
+	require.NoError(t, err, "should let the owner revoke")
+
+	_, err = s.QueryDidPrivate(l.ctx, "DID5")
+	require.NoError(t, err, "should not restrict the owner")
+}
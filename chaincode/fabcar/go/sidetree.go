@@ -0,0 +1,849 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+)
+
+// sidetreeObjectType is the composite key object type under which the state of
+// dids created by Sidetree operations is stored, keyed by their unique suffix
+const sidetreeObjectType = "sidetree"
+
+// Sidetree operation types
+const (
+	sidetreeCreate     = "create"
+	sidetreeUpdate     = "update"
+	sidetreeRecover    = "recover"
+	sidetreeDeactivate = "deactivate"
+)
+
+// Sidetree patch actions. ietf-json-patch is not supported
+const (
+	patchAddPublicKeys    = "add-public-keys"
+	patchRemovePublicKeys = "remove-public-keys"
+	patchAddServices      = "add-services"
+	patchRemoveServices   = "remove-services"
+	patchReplace          = "replace"
+)
+
+// multihashSha256 is the multihash prefix of SHA2-256 digests, the hash
+// algorithm of Sidetree
+var multihashSha256 = []byte{0x12, 0x20}
+
+// sidetreeIdPattern matches the ids of Sidetree public keys and services
+var sidetreeIdPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+// sidetreePurposes are the verification relationships a Sidetree public key may have
+var sidetreePurposes = []string{"authentication", "assertionMethod", "capabilityInvocation", "capabilityDelegation", "keyAgreement"}
+
+// SidetreeOperation is a Sidetree operation request. Create operations carry
+// suffix data and a delta, the other operations name the did by its suffix and
+// reveal the key committed to by the previous operation
+type SidetreeOperation struct {
+	Type        string          `json:"type"`
+	SuffixData  json.RawMessage `json:"suffixData,omitempty"`
+	DidSuffix   string          `json:"didSuffix,omitempty"`
+	RevealValue string          `json:"revealValue,omitempty"`
+	Delta       json.RawMessage `json:"delta,omitempty"`
+	SignedData  string          `json:"signedData,omitempty"`
+}
+
+// SidetreeSuffixData is the suffix data of a create operation, whose hash is
+// the unique suffix of the created did
+type SidetreeSuffixData struct {
+	DeltaHash          string `json:"deltaHash"`
+	RecoveryCommitment string `json:"recoveryCommitment"`
+	Type               string `json:"type,omitempty"`
+	AnchorOrigin       string `json:"anchorOrigin,omitempty"`
+}
+
+// SidetreeSignedData is the payload of the JWS signed data of an update, recover
+// or deactivate operation
+type SidetreeSignedData struct {
+	UpdateKey          json.RawMessage `json:"updateKey,omitempty"`
+	RecoveryKey        json.RawMessage `json:"recoveryKey,omitempty"`
+	DeltaHash          string          `json:"deltaHash,omitempty"`
+	RecoveryCommitment string          `json:"recoveryCommitment,omitempty"`
+	DidSuffix          string          `json:"didSuffix,omitempty"`
+}
+
+// SidetreeDelta holds the patches of an operation and the commitment to the key
+// of the next update
+type SidetreeDelta struct {
+	Patches          []SidetreePatch `json:"patches"`
+	UpdateCommitment string          `json:"updateCommitment"`
+}
+
+// SidetreePatch is a change of a Sidetree document. The members set depend on
+// the action: publicKeys for add-public-keys, services for add-services, ids for
+// the remove actions and document for replace
+type SidetreePatch struct {
+	Action     string              `json:"action"`
+	PublicKeys []SidetreePublicKey `json:"publicKeys,omitempty"`
+	Services   []SidetreeService   `json:"services,omitempty"`
+	Ids        []string            `json:"ids,omitempty"`
+	Document   *SidetreeDocument   `json:"document,omitempty"`
+}
+
+// SidetreePublicKey is a public key of a Sidetree document
+type SidetreePublicKey struct {
+	Id           string           `json:"id"`
+	Type         string           `json:"type"`
+	PublicKeyJwk *keyencoding.Jwk `json:"publicKeyJwk"`
+	Purposes     []string         `json:"purposes,omitempty" metadata:"purposes,optional"`
+}
+
+// SidetreeService is a service of a Sidetree document. The endpoint is a URI
+// or a JSON object
+type SidetreeService struct {
+	Id              string      `json:"id"`
+	Type            string      `json:"type"`
+	ServiceEndpoint interface{} `json:"serviceEndpoint"`
+}
+
+// SidetreeDocument is the state of a Sidetree did document that patches apply to
+type SidetreeDocument struct {
+	PublicKeys []SidetreePublicKey `json:"publicKeys"`
+	Services   []SidetreeService   `json:"services"`
+}
+
+// SidetreeState describes a did created by a Sidetree operation: its document
+// and the commitments to the keys that may update and recover it. Deactivated
+// dids have no commitments left
+type SidetreeState struct {
+	DidSuffix          string           `json:"didSuffix"`
+	Document           SidetreeDocument `json:"document"`
+	UpdateCommitment   string           `json:"updateCommitment,omitempty"`
+	RecoveryCommitment string           `json:"recoveryCommitment,omitempty"`
+	Deactivated        bool             `json:"deactivated,omitempty"`
+	Provenance         *Provenance      `json:"provenance"`
+}
+
+// SidetreeMethodMetadata describes the Sidetree specific document metadata of a did
+type SidetreeMethodMetadata struct {
+	Published          bool   `json:"published"`
+	UpdateCommitment   string `json:"updateCommitment,omitempty" metadata:"updateCommitment,optional"`
+	RecoveryCommitment string `json:"recoveryCommitment,omitempty" metadata:"recoveryCommitment,optional"`
+}
+
+// sidetreeHash returns the base64url encoded SHA2-256 multihash of data
+func sidetreeHash(data []byte) string {
+	digest := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(append(append([]byte{}, multihashSha256...), digest[:]...))
+}
+
+// canonicalHash returns the Sidetree hash of the canonical JSON of value
+func canonicalHash(value interface{}) (string, error) {
+	valueAsBytes, err := canonicalJSON(value)
+
+	if err != nil {
+		return "", newError(codeInvalidArgument, "Failed to canonicalize JSON. %s", err.Error())
+	}
+
+	return sidetreeHash(valueAsBytes), nil
+}
+
+// validateMultihash returns an error unless value is a base64url encoded SHA2-256 multihash
+func validateMultihash(name string, value string) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil || len(decoded) != len(multihashSha256)+sha256.Size || !bytes.HasPrefix(decoded, multihashSha256) {
+		return newError(codeInvalidArgument, "%s must be a base64url encoded SHA2-256 multihash", name)
+	}
+
+	return nil
+}
+
+// checkCommitment returns an error unless the revealed key matches the reveal
+// value of the operation and the commitment made by the previous operation: the
+// reveal value is the hash of the canonical key and the commitment the hash of
+// its digest
+func checkCommitment(keyJSON json.RawMessage, revealValue string, commitment string) error {
+	keyAsBytes, err := canonicalJSON(keyJSON)
+
+	if err != nil {
+		return newError(codeInvalidArgument, "Failed to canonicalize the signing key. %s", err.Error())
+	}
+
+	if sidetreeHash(keyAsBytes) != revealValue {
+		return newError(codeInvalidSignature, "Reveal value does not match the signing key")
+	}
+
+	digest := sha256.Sum256(keyAsBytes)
+
+	if sidetreeHash(digest[:]) != commitment {
+		return newError(codeInvalidSignature, "Signing key does not match the commitment of the did")
+	}
+
+	return nil
+}
+
+// verifySignedData verifies the compact JWS signed data of an operation with
+// the key it carries and returns its payload and that key. recovery selects the
+// recovery key instead of the update key
+func verifySignedData(signedData string, recovery bool) (*SidetreeSignedData, json.RawMessage, error) {
+	parts := strings.Split(signedData, ".")
+
+	if len(parts) != 3 || parts[1] == "" {
+		return nil, nil, newError(codeInvalidArgument, "signedData must be a compact JWS")
+	}
+
+	headerAsBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+
+	if err != nil {
+		return nil, nil, newError(codeInvalidArgument, "Failed to decode JWS header. %s", err.Error())
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid,omitempty"`
+	}{}
+
+	if err := decodeStrict(headerAsBytes, &header); err != nil {
+		return nil, nil, newError(codeInvalidArgument, "Failed to decode JWS header. %s", err.Error())
+	}
+
+	payloadAsBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+
+	if err != nil {
+		return nil, nil, newError(codeInvalidArgument, "Failed to decode JWS payload. %s", err.Error())
+	}
+
+	payload := new(SidetreeSignedData)
+
+	if err := decodeStrict(payloadAsBytes, payload); err != nil {
+		return nil, nil, newError(codeInvalidArgument, "Failed to decode signed data. %s", err.Error())
+	}
+
+	keyJSON, keyName := payload.UpdateKey, "updateKey"
+
+	if recovery {
+		keyJSON, keyName = payload.RecoveryKey, "recoveryKey"
+	}
+
+	if len(keyJSON) == 0 {
+		return nil, nil, newError(codeInvalidArgument, "Signed data must contain the %s", keyName)
+	}
+
+	jwk, err := keyencoding.ParseJwk(string(keyJSON))
+
+	if err != nil {
+		return nil, nil, newError(codeInvalidArgument, "Failed to parse %s. %s", keyName, err.Error())
+	}
+
+	publicKey, err := jwk.PublicKey()
+
+	if err != nil {
+		return nil, nil, newError(codeInvalidArgument, "Failed to parse %s. %s", keyName, err.Error())
+	}
+
+	if err := checkJwsAlgorithm(header.Alg, publicKey); err != nil {
+		return nil, nil, newError(codeInvalidSignature, "%s", err.Error())
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, nil, newError(codeInvalidSignature, "Failed to decode JWS signature. %s", err.Error())
+	}
+
+	if err := verifySignature(publicKey, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, nil, newError(codeInvalidSignature, "Signed data is not signed by the %s. %s", keyName, err.Error())
+	}
+
+	return payload, keyJSON, nil
+}
+
+// decodeDelta decodes the delta of an operation and checks it against the delta
+// hash committed to by its suffix data or signed data
+func decodeDelta(deltaJSON json.RawMessage, deltaHash string) (*SidetreeDelta, error) {
+	if len(deltaJSON) == 0 {
+		return nil, newError(codeInvalidArgument, "The operation must contain a delta")
+	}
+
+	hash, err := canonicalHash(deltaJSON)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if hash != deltaHash {
+		return nil, newError(codeInvalidArgument, "Delta does not match the delta hash %s", deltaHash)
+	}
+
+	delta := new(SidetreeDelta)
+
+	if err := decodeStrict(deltaJSON, delta); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode delta. %s", err.Error())
+	}
+
+	if err := validateMultihash("updateCommitment", delta.UpdateCommitment); err != nil {
+		return nil, err
+	}
+
+	return delta, nil
+}
+
+// validatePublicKey checks the id, purposes and key of a Sidetree public key
+func validatePublicKey(key *SidetreePublicKey) error {
+	if !sidetreeIdPattern.MatchString(key.Id) {
+		return newError(codeInvalidArgument, "Public key id %s must be 1 to 50 base64url characters", key.Id)
+	}
+
+	if key.PublicKeyJwk == nil {
+		return newError(codeInvalidArgument, "Public key %s must have a publicKeyJwk", key.Id)
+	}
+
+	publicKey, err := key.PublicKeyJwk.PublicKey()
+
+	if err != nil {
+		return newError(codeInvalidArgument, "Failed to parse public key %s. %s", key.Id, err.Error())
+	}
+
+	if err := checkKeyType(key.Type, publicKey); err != nil {
+		return newError(codeInvalidArgument, "Public key %s cannot be used with %s. %s", key.Id, key.Type, err.Error())
+	}
+
+	seen := map[string]bool{}
+
+	for _, purpose := range key.Purposes {
+		if !containsString(sidetreePurposes, purpose) || seen[purpose] {
+			return newError(codeInvalidArgument, "Public key %s has an unknown or repeated purpose %s", key.Id, purpose)
+		}
+
+		seen[purpose] = true
+	}
+
+	return nil
+}
+
+// validateSidetreeService checks the id, type and endpoint of a Sidetree service
+func validateSidetreeService(ctx contractapi.TransactionContextInterface, service *SidetreeService) error {
+	if !sidetreeIdPattern.MatchString(service.Id) {
+		return newError(codeInvalidArgument, "Service id %s must be 1 to 50 base64url characters", service.Id)
+	}
+
+	if service.Type == "" {
+		return newError(codeInvalidArgument, "Service %s must have a type", service.Id)
+	}
+
+	if endpoint, ok := service.ServiceEndpoint.(string); ok && endpoint != "" {
+		return validateServiceEndpoint(ctx, endpoint)
+	}
+
+	if !isJSONObject(service.ServiceEndpoint) {
+		return newError(codeInvalidArgument, "Endpoint of service %s must be a URI or a JSON object", service.Id)
+	}
+
+	return nil
+}
+
+// applyPatches applies the patches of a delta to document in order. Added keys
+// and services replace those with the same id, removing unknown ids has no effect
+func applyPatches(ctx contractapi.TransactionContextInterface, document *SidetreeDocument, patches []SidetreePatch) error {
+	for i, patch := range patches {
+		members := 0
+
+		for _, set := range []bool{patch.PublicKeys != nil, patch.Services != nil, patch.Ids != nil, patch.Document != nil} {
+			if set {
+				members++
+			}
+		}
+
+		if members != 1 {
+			return newError(codeInvalidArgument, "Patch %d must set exactly the member of its action", i)
+		}
+
+		switch {
+		case patch.Action == patchAddPublicKeys && patch.PublicKeys != nil:
+			for _, key := range patch.PublicKeys {
+				if err := validatePublicKey(&key); err != nil {
+					return err
+				}
+
+				document.PublicKeys = append(removePublicKeys(document.PublicKeys, []string{key.Id}), key)
+			}
+		case patch.Action == patchRemovePublicKeys && patch.Ids != nil:
+			document.PublicKeys = removePublicKeys(document.PublicKeys, patch.Ids)
+		case patch.Action == patchAddServices && patch.Services != nil:
+			for _, service := range patch.Services {
+				if err := validateSidetreeService(ctx, &service); err != nil {
+					return err
+				}
+
+				document.Services = append(removeServices(document.Services, []string{service.Id}), service)
+			}
+		case patch.Action == patchRemoveServices && patch.Ids != nil:
+			document.Services = removeServices(document.Services, patch.Ids)
+		case patch.Action == patchReplace && patch.Document != nil:
+			replacement := SidetreeDocument{PublicKeys: []SidetreePublicKey{}, Services: []SidetreeService{}}
+
+			if err := applyPatches(ctx, &replacement, []SidetreePatch{
+				{Action: patchAddPublicKeys, PublicKeys: append([]SidetreePublicKey{}, patch.Document.PublicKeys...)},
+				{Action: patchAddServices, Services: append([]SidetreeService{}, patch.Document.Services...)},
+			}); err != nil {
+				return err
+			}
+
+			*document = replacement
+		default:
+			return newError(codeInvalidArgument, "Unsupported patch action %s", patch.Action)
+		}
+	}
+
+	return nil
+}
+
+// removePublicKeys returns keys without those with the given ids
+func removePublicKeys(keys []SidetreePublicKey, ids []string) []SidetreePublicKey {
+	result := []SidetreePublicKey{}
+
+	for _, key := range keys {
+		if !containsString(ids, key.Id) {
+			result = append(result, key)
+		}
+	}
+
+	return result
+}
+
+// removeServices returns services without those with the given ids
+func removeServices(services []SidetreeService, ids []string) []SidetreeService {
+	result := []SidetreeService{}
+
+	for _, service := range services {
+		if !containsString(ids, service.Id) {
+			result = append(result, service)
+		}
+	}
+
+	return result
+}
+
+// sidetreeKey returns the key of the Sidetree state of the did with given suffix
+func sidetreeKey(ctx contractapi.TransactionContextInterface, didSuffix string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(sidetreeObjectType, []string{didSuffix})
+}
+
+// getSidetreeState reads the Sidetree state of the did with given suffix, or
+// returns nil if there is none
+func getSidetreeState(ctx contractapi.TransactionContextInterface, didSuffix string) (*SidetreeState, error) {
+	key, err := sidetreeKey(ctx, didSuffix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	stateAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if stateAsBytes == nil {
+		return nil, nil
+	}
+
+	state := new(SidetreeState)
+
+	if err := unmarshalRecord(key, stateAsBytes, state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// lookupSidetreeDid returns the Sidetree state of a did of this registry, or nil
+// if the did was not created by a Sidetree operation
+func lookupSidetreeDid(ctx contractapi.TransactionContextInterface, did string) (*SidetreeState, error) {
+	if !strings.HasPrefix(did, didMethodPrefix) {
+		return nil, nil
+	}
+
+	return getSidetreeState(ctx, strings.TrimPrefix(did, didMethodPrefix))
+}
+
+// putSidetreeState writes the Sidetree state of a did to the world state
+func putSidetreeState(ctx contractapi.TransactionContextInterface, state *SidetreeState) error {
+	key, err := sidetreeKey(ctx, state.DidSuffix)
+
+	if err != nil {
+		return err
+	}
+
+	stateAsBytes, err := marshalRecord(key, state)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertDocumentSize(ctx, didMethodPrefix+state.DidSuffix, stateAsBytes); err != nil {
+		return err
+	}
+
+	if err := assertDocumentLimits(ctx, didMethodPrefix+state.DidSuffix, stateAsBytes, len(state.Document.PublicKeys), len(state.Document.Services)); err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, stateAsBytes); err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// activeSidetreeState reads the Sidetree state an update, recover or deactivate
+// operation applies to and records the operation as its latest update
+func activeSidetreeState(ctx contractapi.TransactionContextInterface, operation *SidetreeOperation) (*SidetreeState, error) {
+	if operation.DidSuffix == "" || operation.RevealValue == "" || operation.SignedData == "" || len(operation.SuffixData) != 0 {
+		return nil, newError(codeInvalidArgument, "%s operations must contain didSuffix, revealValue and signedData but no suffixData", operation.Type)
+	}
+
+	state, err := getSidetreeState(ctx, operation.DidSuffix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if state == nil {
+		return nil, newError(codeDidNotFound, "%s%s does not exist", didMethodPrefix, operation.DidSuffix)
+	}
+
+	if state.Deactivated {
+		return nil, newError(codeDidDeactivated, "%s%s is deactivated", didMethodPrefix, operation.DidSuffix)
+	}
+
+	updated, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	state.Provenance.Updated = updated
+
+	return state, nil
+}
+
+// processCreate creates the did whose suffix is the hash of the suffix data
+func processCreate(ctx contractapi.TransactionContextInterface, operation *SidetreeOperation) (*SidetreeState, error) {
+	if len(operation.SuffixData) == 0 || operation.DidSuffix != "" || operation.RevealValue != "" || operation.SignedData != "" {
+		return nil, newError(codeInvalidArgument, "create operations must contain suffixData and delta only")
+	}
+
+	suffixData := new(SidetreeSuffixData)
+
+	if err := decodeStrict(operation.SuffixData, suffixData); err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode suffix data. %s", err.Error())
+	}
+
+	if err := validateMultihash("recoveryCommitment", suffixData.RecoveryCommitment); err != nil {
+		return nil, err
+	}
+
+	delta, err := decodeDelta(operation.Delta, suffixData.DeltaHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	didSuffix, err := canonicalHash(operation.SuffixData)
+
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := getSidetreeState(ctx, didSuffix)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if existing != nil {
+		return nil, newError(codeDidAlreadyExists, "%s%s already exists", didMethodPrefix, didSuffix)
+	}
+
+	document := SidetreeDocument{PublicKeys: []SidetreePublicKey{}, Services: []SidetreeService{}}
+
+	if err := applyPatches(ctx, &document, delta.Patches); err != nil {
+		return nil, err
+	}
+
+	if err := admitRegistration(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	created, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	state := SidetreeState{
+		DidSuffix:          didSuffix,
+		Document:           document,
+		UpdateCommitment:   delta.UpdateCommitment,
+		RecoveryCommitment: suffixData.RecoveryCommitment,
+		Provenance:         &Provenance{Created: created},
+	}
+
+	return &state, nil
+}
+
+// processUpdate applies the delta of an update operation signed with the key
+// committed to by the previous update commitment
+func processUpdate(ctx contractapi.TransactionContextInterface, operation *SidetreeOperation) (*SidetreeState, error) {
+	state, err := activeSidetreeState(ctx, operation)
+
+	if err != nil {
+		return nil, err
+	}
+
+	signedData, updateKey, err := verifySignedData(operation.SignedData, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCommitment(updateKey, operation.RevealValue, state.UpdateCommitment); err != nil {
+		return nil, err
+	}
+
+	delta, err := decodeDelta(operation.Delta, signedData.DeltaHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if delta.UpdateCommitment == state.UpdateCommitment {
+		return nil, newError(codeInvalidArgument, "updateCommitment must not be reused")
+	}
+
+	if err := applyPatches(ctx, &state.Document, delta.Patches); err != nil {
+		return nil, err
+	}
+
+	state.UpdateCommitment = delta.UpdateCommitment
+
+	return state, nil
+}
+
+// processRecover replaces the document and both commitments of the did with an
+// operation signed with the key committed to by the recovery commitment
+func processRecover(ctx contractapi.TransactionContextInterface, operation *SidetreeOperation) (*SidetreeState, error) {
+	state, err := activeSidetreeState(ctx, operation)
+
+	if err != nil {
+		return nil, err
+	}
+
+	signedData, recoveryKey, err := verifySignedData(operation.SignedData, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCommitment(recoveryKey, operation.RevealValue, state.RecoveryCommitment); err != nil {
+		return nil, err
+	}
+
+	if err := validateMultihash("recoveryCommitment", signedData.RecoveryCommitment); err != nil {
+		return nil, err
+	}
+
+	if signedData.RecoveryCommitment == state.RecoveryCommitment {
+		return nil, newError(codeInvalidArgument, "recoveryCommitment must not be reused")
+	}
+
+	delta, err := decodeDelta(operation.Delta, signedData.DeltaHash)
+
+	if err != nil {
+		return nil, err
+	}
+
+	document := SidetreeDocument{PublicKeys: []SidetreePublicKey{}, Services: []SidetreeService{}}
+
+	if err := applyPatches(ctx, &document, delta.Patches); err != nil {
+		return nil, err
+	}
+
+	state.Document = document
+	state.UpdateCommitment = delta.UpdateCommitment
+	state.RecoveryCommitment = signedData.RecoveryCommitment
+
+	return state, nil
+}
+
+// processDeactivate deactivates the did with an operation signed with the key
+// committed to by the recovery commitment. The document and commitments are
+// cleared, so no later operation can apply
+func processDeactivate(ctx contractapi.TransactionContextInterface, operation *SidetreeOperation) (*SidetreeState, error) {
+	if len(operation.Delta) != 0 {
+		return nil, newError(codeInvalidArgument, "deactivate operations must not contain a delta")
+	}
+
+	state, err := activeSidetreeState(ctx, operation)
+
+	if err != nil {
+		return nil, err
+	}
+
+	signedData, recoveryKey, err := verifySignedData(operation.SignedData, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCommitment(recoveryKey, operation.RevealValue, state.RecoveryCommitment); err != nil {
+		return nil, err
+	}
+
+	if signedData.DidSuffix != operation.DidSuffix {
+		return nil, newError(codeInvalidSignature, "Signed data does not deactivate %s%s", didMethodPrefix, operation.DidSuffix)
+	}
+
+	state.Document = SidetreeDocument{PublicKeys: []SidetreePublicKey{}, Services: []SidetreeService{}}
+	state.UpdateCommitment = ""
+	state.RecoveryCommitment = ""
+	state.Deactivated = true
+
+	return state, nil
+}
+
+// ProcessSidetreeOperation processes a Sidetree create, update, recover or
+// deactivate operation request and returns the did it applies to. Dids are
+// identified by the hash of the suffix data of their create operation and
+// controlled only through the keys committed to by their operations: updates
+// must reveal the key of the update commitment and recovery and deactivation the
+// key of the recovery commitment, after which new commitments apply. JSON is
+// canonicalized as by canonicalJSON before hashing. The submitting client is
+// recorded in the provenance of the did but does not control it. Creating a did
+// counts against the registration quota and fee like CreateDid
+func (s *DidContract) ProcessSidetreeOperation(ctx contractapi.TransactionContextInterface, operationJSON string) (string, error) {
+	operation := new(SidetreeOperation)
+
+	if err := decodeStrict([]byte(operationJSON), operation); err != nil {
+		return "", newError(codeInvalidArgument, "operationJSON must be a Sidetree operation. %s", err.Error())
+	}
+
+	var state *SidetreeState
+	var err error
+
+	switch operation.Type {
+	case sidetreeCreate:
+		state, err = processCreate(ctx, operation)
+	case sidetreeUpdate:
+		state, err = processUpdate(ctx, operation)
+	case sidetreeRecover:
+		state, err = processRecover(ctx, operation)
+	case sidetreeDeactivate:
+		state, err = processDeactivate(ctx, operation)
+	default:
+		return "", newError(codeInvalidArgument, "Unsupported Sidetree operation type %s", operation.Type)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	if err := putSidetreeState(ctx, state); err != nil {
+		return "", err
+	}
+
+	return didMethodPrefix + state.DidSuffix, nil
+}
+
+// document returns the Sidetree did in the representation defined by DID Core,
+// listing each public key under the verification relationships of its purposes
+func (st *SidetreeState) document() *DidDocument {
+	did := didMethodPrefix + st.DidSuffix
+
+	document := DidDocument{
+		Context:            []string{didContext},
+		Id:                 did,
+		VerificationMethod: []VerificationMethod{},
+		Authentication:     []string{},
+	}
+
+	for _, key := range st.Document.PublicKeys {
+		id := qualifyId(did, "#"+key.Id)
+		document.VerificationMethod = append(document.VerificationMethod, VerificationMethod{Id: id, Type: key.Type, Controller: did, PublicKeyJwk: key.PublicKeyJwk})
+
+		for _, purpose := range key.Purposes {
+			switch purpose {
+			case "authentication":
+				document.Authentication = append(document.Authentication, id)
+			case "assertionMethod":
+				document.AssertionMethod = append(document.AssertionMethod, id)
+			case "capabilityInvocation":
+				document.CapabilityInvocation = append(document.CapabilityInvocation, id)
+			case "capabilityDelegation":
+				document.CapabilityDelegation = append(document.CapabilityDelegation, id)
+			case "keyAgreement":
+				document.KeyAgreement = append(document.KeyAgreement, id)
+			}
+		}
+	}
+
+	for _, service := range st.Document.Services {
+		document.Service = append(document.Service, Service{Id: qualifyId(did, "#"+service.Id), Type: service.Type, ServiceEndpoint: service.ServiceEndpoint})
+	}
+
+	return &document
+}
+
+// resolution returns the resolution result of the Sidetree did, carrying its
+// commitments in the method metadata
+func (st *SidetreeState) resolution() *DidResolutionResult {
+	metadata := DidDocumentMetadata{
+		Created:     st.Provenance.Created.Timestamp,
+		VersionId:   st.Provenance.Created.TxID,
+		Deactivated: st.Deactivated,
+		Method:      &SidetreeMethodMetadata{Published: true, UpdateCommitment: st.UpdateCommitment, RecoveryCommitment: st.RecoveryCommitment},
+	}
+
+	if st.Provenance.Updated != nil {
+		metadata.Updated = st.Provenance.Updated.Timestamp
+		metadata.VersionId = st.Provenance.Updated.TxID
+	}
+
+	if st.Deactivated {
+		resolution := failedResolution(resolutionDeactivated)
+		resolution.DidDocumentMetadata = &metadata
+
+		return resolution
+	}
+
+	return &DidResolutionResult{
+		DidDocument:           st.document(),
+		DidDocumentMetadata:   &metadata,
+		DidResolutionMetadata: &DidResolutionMetadata{ContentType: didContentType},
+	}
+}
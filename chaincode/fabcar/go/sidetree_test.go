@@ -0,0 +1,368 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/chaincode/fabcar/go/keyencoding"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sidetreeJwk returns the public JWK of key
+func sidetreeJwk(t *testing.T, key *testKey) *keyencoding.Jwk {
+	jwk, err := keyencoding.JwkFromPublicKey(&key.private.PublicKey)
+	require.NoError(t, err)
+
+	return jwk
+}
+
+// sidetreeCommitment returns the commitment to key and the value revealing it
+func sidetreeCommitment(t *testing.T, key *testKey) (string, string) {
+	keyAsBytes, err := canonicalJSON(sidetreeJwk(t, key))
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(keyAsBytes)
+
+	return sidetreeHash(digest[:]), sidetreeHash(keyAsBytes)
+}
+
+// signSidetree returns the compact ES256 JWS of payload signed with key
+func signSidetree(t *testing.T, key *testKey, payload interface{}) string {
+	payloadAsBytes, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + "." + base64.RawURLEncoding.EncodeToString(payloadAsBytes)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key.private, digest[:])
+	require.NoError(t, err)
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// sidetreeDelta returns a delta with patches committing to the next update key
+func sidetreeDelta(t *testing.T, next *testKey, patches ...map[string]interface{}) (map[string]interface{}, string) {
+	commitment, _ := sidetreeCommitment(t, next)
+	delta := map[string]interface{}{"patches": patches, "updateCommitment": commitment}
+
+	hash, err := canonicalHash(delta)
+	require.NoError(t, err)
+
+	return delta, hash
+}
+
+// addKeyPatch returns an add-public-keys patch of key with given id
+func addKeyPatch(t *testing.T, id string, key *testKey, purposes ...string) map[string]interface{} {
+	return map[string]interface{}{"action": patchAddPublicKeys, "publicKeys": []interface{}{
+		map[string]interface{}{"id": id, "type": "JsonWebKey2020", "publicKeyJwk": sidetreeJwk(t, key), "purposes": purposes},
+	}}
+}
+
+// operationJSON encodes an operation request
+func operationJSON(t *testing.T, operation map[string]interface{}) string {
+	operationAsBytes, err := json.Marshal(operation)
+	require.NoError(t, err)
+
+	return string(operationAsBytes)
+}
+
+// sidetreeCreateOperation returns a create operation adding signing as
+// authentication key and the did the operation creates
+func sidetreeCreateOperation(t *testing.T, signing *testKey, update *testKey, recovery *testKey) (string, string) {
+	delta, deltaHash := sidetreeDelta(t, update, addKeyPatch(t, "key-1", signing, "authentication", "assertionMethod"))
+	recoveryCommitment, _ := sidetreeCommitment(t, recovery)
+	suffixData := map[string]interface{}{"deltaHash": deltaHash, "recoveryCommitment": recoveryCommitment}
+
+	suffix, err := canonicalHash(suffixData)
+	require.NoError(t, err)
+
+	return operationJSON(t, map[string]interface{}{"type": sidetreeCreate, "suffixData": suffixData, "delta": delta}), didMethodPrefix + suffix
+}
+
+// sidetreeUpdateOperation returns an update operation of did signed with key,
+// applying patches and committing to next
+func sidetreeUpdateOperation(t *testing.T, did string, key *testKey, next *testKey, patches ...map[string]interface{}) string {
+	delta, deltaHash := sidetreeDelta(t, next, patches...)
+	_, reveal := sidetreeCommitment(t, key)
+
+	return operationJSON(t, map[string]interface{}{
+		"type":        sidetreeUpdate,
+		"didSuffix":   strings.TrimPrefix(did, didMethodPrefix),
+		"revealValue": reveal,
+		"delta":       delta,
+		"signedData":  signSidetree(t, key, map[string]interface{}{"updateKey": sidetreeJwk(t, key), "deltaHash": deltaHash}),
+	})
+}
+
+func TestSidetreeHash(t *testing.T) {
+	hash := sidetreeHash([]byte("abc"))
+	assert.Equal(t, "EiC6eBa_jwHP6kFBQN5driIjsANho5YXepy0EP9h8gAVrQ", hash, "should encode the SHA2-256 multihash as base64url")
+	assert.Nil(t, validateMultihash("hash", hash), "should accept SHA2-256 multihashes")
+	assertErrorCode(t, validateMultihash("hash", "EiC"), codeInvalidArgument, "should reject truncated hashes")
+	assertErrorCode(t, validateMultihash("hash", base64.RawURLEncoding.EncodeToString(make([]byte, 34))), codeInvalidArgument, "should require the SHA2-256 prefix")
+}
+
+func TestSidetreeCreate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	signing, update, recovery := newTestKey(t), newTestKey(t), newTestKey(t)
+	create, expected := sidetreeCreateOperation(t, signing, update, recovery)
+
+	did, err := s.ProcessSidetreeOperation(l.ctx, create)
+	require.NoError(t, err, "should create the did")
+	assert.Equal(t, expected, did, "should derive the did from the hash of the suffix data")
+	assert.True(t, strings.HasPrefix(did, didMethodPrefix+"Ei"), "should use a SHA2-256 multihash suffix")
+
+	resolution, err := s.Resolve(l.ctx, did, false)
+	require.NoError(t, err, "should resolve Sidetree dids")
+	require.NotNil(t, resolution.DidDocument)
+	assert.Equal(t, did, resolution.DidDocument.Id)
+	require.Len(t, resolution.DidDocument.VerificationMethod, 1)
+	assert.Equal(t, did+"#key-1", resolution.DidDocument.VerificationMethod[0].Id, "should qualify key ids")
+	assert.Equal(t, sidetreeJwk(t, signing), resolution.DidDocument.VerificationMethod[0].PublicKeyJwk, "should keep the JWK")
+	assert.Equal(t, []string{did + "#key-1"}, resolution.DidDocument.Authentication, "should add keys to the relationships of their purposes")
+	assert.Equal(t, []string{did + "#key-1"}, resolution.DidDocument.AssertionMethod)
+	assert.Empty(t, resolution.DidDocument.KeyAgreement, "should not add keys to other relationships")
+
+	updateCommitment, _ := sidetreeCommitment(t, update)
+	recoveryCommitment, _ := sidetreeCommitment(t, recovery)
+	assert.Equal(t, &SidetreeMethodMetadata{Published: true, UpdateCommitment: updateCommitment, RecoveryCommitment: recoveryCommitment},
+		resolution.DidDocumentMetadata.Method, "should return the commitments as method metadata")
+	assert.Equal(t, l.stub.GetTxID(), resolution.DidDocumentMetadata.VersionId)
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, create)
+	assertErrorCode(t, err, codeDidAlreadyExists, "should not create a did twice")
+
+	operation := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(create), &operation))
+	operation["delta"].(map[string]interface{})["updateCommitment"] = recoveryCommitment
+	_, err = s.ProcessSidetreeOperation(l.ctx, operationJSON(t, operation))
+	assertErrorCode(t, err, codeInvalidArgument, "should require the delta to match the delta hash")
+
+	invalid := map[string]string{
+		"should require a known type":       `{"type":"migrate"}`,
+		"should reject unknown members":     `{"type":"create","anchor":"x"}`,
+		"should require suffix data":        `{"type":"create","delta":{}}`,
+		"should reject reveal values":       strings.Replace(create, `"type":"create"`, `"type":"create","revealValue":"x"`, 1),
+		"should require a recovery hash":    `{"type":"create","suffixData":{"deltaHash":"x","recoveryCommitment":"x"},"delta":{}}`,
+		"should reject unknown suffix data": `{"type":"create","suffixData":{"deltaHash":"x","recoveryCommitment":"x","extra":1},"delta":{}}`,
+	}
+
+	for message, operationJSON := range invalid {
+		_, err = s.ProcessSidetreeOperation(l.ctx, operationJSON)
+		assertErrorCode(t, err, codeInvalidArgument, message)
+	}
+}
+
+func TestSidetreePatches(t *testing.T) {
+	l := newTestLedger(t)
+	document := SidetreeDocument{PublicKeys: []SidetreePublicKey{}, Services: []SidetreeService{}}
+	first, second := newTestKey(t), newTestKey(t)
+
+	patches := []SidetreePatch{
+		{Action: patchAddPublicKeys, PublicKeys: []SidetreePublicKey{{Id: "key-1", Type: jsonWebKey2020, PublicKeyJwk: sidetreeJwk(t, first)}}},
+		{Action: patchAddPublicKeys, PublicKeys: []SidetreePublicKey{{Id: "key-2", Type: ecdsaSecp256r1VerificationKey2019, PublicKeyJwk: sidetreeJwk(t, second), Purposes: []string{"keyAgreement"}}}},
+		{Action: patchAddServices, Services: []SidetreeService{{Id: "hub", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}}},
+		{Action: patchAddServices, Services: []SidetreeService{{Id: "dwn", Type: "DecentralizedWebNode", ServiceEndpoint: map[string]interface{}{"nodes": []interface{}{testEndpoint}}}}},
+		{Action: patchAddPublicKeys, PublicKeys: []SidetreePublicKey{{Id: "key-1", Type: jsonWebKey2020, PublicKeyJwk: sidetreeJwk(t, second)}}},
+		{Action: patchRemoveServices, Ids: []string{"hub", "unknown"}},
+	}
+
+	require.NoError(t, applyPatches(l.ctx, &document, patches), "should apply every patch")
+	require.Len(t, document.PublicKeys, 2)
+	assert.Equal(t, "key-2", document.PublicKeys[0].Id)
+	assert.Equal(t, sidetreeJwk(t, second), document.PublicKeys[1].PublicKeyJwk, "should replace keys with the same id")
+	require.Len(t, document.Services, 1, "should remove services by id")
+	assert.Equal(t, "dwn", document.Services[0].Id)
+
+	err := applyPatches(l.ctx, &document, []SidetreePatch{{Action: patchReplace, Document: &SidetreeDocument{Services: []SidetreeService{{Id: "hub", Type: "LinkedDomains", ServiceEndpoint: "https://example.com"}}}}})
+	require.NoError(t, err, "should replace the document")
+	assert.Empty(t, document.PublicKeys, "should remove keys missing from the replacement")
+	assert.Len(t, document.Services, 1)
+
+	require.NoError(t, applyPatches(l.ctx, &document, []SidetreePatch{{Action: patchRemovePublicKeys, Ids: []string{"key-1"}}}), "should ignore unknown ids")
+
+	invalid := map[string]SidetreePatch{
+		"should require the member of the action": {Action: patchAddPublicKeys, Ids: []string{"key-1"}},
+		"should reject several members":           {Action: patchRemoveServices, Ids: []string{"hub"}, Services: []SidetreeService{}},
+		"should reject unknown actions":           {Action: "ietf-json-patch", Ids: []string{}},
+		"should validate key ids":                 {Action: patchAddPublicKeys, PublicKeys: []SidetreePublicKey{{Id: "#key-1", Type: jsonWebKey2020, PublicKeyJwk: sidetreeJwk(t, first)}}},
+		"should require a JWK":                    {Action: patchAddPublicKeys, PublicKeys: []SidetreePublicKey{{Id: "key-1", Type: jsonWebKey2020}}},
+		"should check the key type":               {Action: patchAddPublicKeys, PublicKeys: []SidetreePublicKey{{Id: "key-1", Type: ecdsaSecp256k1VerificationKey2019, PublicKeyJwk: sidetreeJwk(t, first)}}},
+		"should reject unknown purposes":          {Action: patchAddPublicKeys, PublicKeys: []SidetreePublicKey{{Id: "key-1", Type: jsonWebKey2020, PublicKeyJwk: sidetreeJwk(t, first), Purposes: []string{"signing"}}}},
+		"should reject repeated purposes":         {Action: patchAddPublicKeys, PublicKeys: []SidetreePublicKey{{Id: "key-1", Type: jsonWebKey2020, PublicKeyJwk: sidetreeJwk(t, first), Purposes: []string{"authentication", "authentication"}}}},
+		"should require a service type":           {Action: patchAddServices, Services: []SidetreeService{{Id: "hub", ServiceEndpoint: testEndpoint}}},
+		"should validate endpoint uris":           {Action: patchAddServices, Services: []SidetreeService{{Id: "hub", Type: "LinkedDomains", ServiceEndpoint: "http://example.com"}}},
+		"should reject endpoint arrays":           {Action: patchAddServices, Services: []SidetreeService{{Id: "hub", Type: "LinkedDomains", ServiceEndpoint: []interface{}{testEndpoint}}}},
+	}
+
+	for message, patch := range invalid {
+		assertErrorCode(t, applyPatches(l.ctx, &document, []SidetreePatch{patch}), codeInvalidArgument, message)
+	}
+}
+
+func TestSidetreeUpdate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	signing, update, recovery, next := newTestKey(t), newTestKey(t), newTestKey(t), newTestKey(t)
+	create, did := sidetreeCreateOperation(t, signing, update, recovery)
+	_, err := s.ProcessSidetreeOperation(l.ctx, create)
+	require.NoError(t, err)
+	l.nextTx()
+
+	service := map[string]interface{}{"action": patchAddServices, "services": []interface{}{map[string]interface{}{"id": "vcs", "type": "VerifiableCredentialService", "serviceEndpoint": testEndpoint}}}
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, sidetreeUpdateOperation(t, did, recovery, next, service))
+	assertErrorCode(t, err, codeInvalidSignature, "should require the key of the update commitment")
+
+	updated, err := s.ProcessSidetreeOperation(l.ctx, sidetreeUpdateOperation(t, did, update, next, service))
+	require.NoError(t, err, "should apply the update")
+	assert.Equal(t, did, updated)
+	l.nextTx()
+
+	resolution, err := s.Resolve(l.ctx, did, false)
+	require.NoError(t, err)
+	require.Len(t, resolution.DidDocument.Service, 1, "should apply the patches")
+	assert.Equal(t, did+"#vcs", resolution.DidDocument.Service[0].Id)
+	assert.Len(t, resolution.DidDocument.VerificationMethod, 1, "should keep the other members of the document")
+	nextCommitment, _ := sidetreeCommitment(t, next)
+	assert.Equal(t, nextCommitment, resolution.DidDocumentMetadata.Method.UpdateCommitment, "should commit to the next update key")
+	assert.Equal(t, "tx2", resolution.DidDocumentMetadata.VersionId, "should version the did by its latest operation")
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, sidetreeUpdateOperation(t, did, update, newTestKey(t), service))
+	assertErrorCode(t, err, codeInvalidSignature, "should not accept a revealed key twice")
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, sidetreeUpdateOperation(t, did, next, next, service))
+	assertErrorCode(t, err, codeInvalidArgument, "should not reuse the commitment")
+
+	operation := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(sidetreeUpdateOperation(t, did, next, update, service)), &operation))
+
+	_, reveal := sidetreeCommitment(t, update)
+	tampered := map[string]interface{}{}
+	for name, value := range operation {
+		tampered[name] = value
+	}
+	tampered["revealValue"] = reveal
+	_, err = s.ProcessSidetreeOperation(l.ctx, operationJSON(t, tampered))
+	assertErrorCode(t, err, codeInvalidSignature, "should require the reveal value of the signing key")
+
+	tampered["revealValue"] = operation["revealValue"]
+	tampered["delta"] = map[string]interface{}{"patches": []interface{}{}, "updateCommitment": nextCommitment}
+	_, err = s.ProcessSidetreeOperation(l.ctx, operationJSON(t, tampered))
+	assertErrorCode(t, err, codeInvalidArgument, "should require the delta signed for")
+
+	parts := strings.Split(operation["signedData"].(string), ".")
+	tampered["delta"] = operation["delta"]
+	tampered["signedData"] = parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(make([]byte, 64))
+	_, err = s.ProcessSidetreeOperation(l.ctx, operationJSON(t, tampered))
+	assertErrorCode(t, err, codeInvalidSignature, "should verify the signature")
+
+	tampered["signedData"] = parts[0] + ".." + parts[2]
+	_, err = s.ProcessSidetreeOperation(l.ctx, operationJSON(t, tampered))
+	assertErrorCode(t, err, codeInvalidArgument, "should require an attached payload")
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, sidetreeUpdateOperation(t, didMethodPrefix+"EiUnknown", next, update, service))
+	assertErrorCode(t, err, codeDidNotFound, "should fail for unknown dids")
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, operationJSON(t, operation))
+	require.NoError(t, err, "should apply the untampered update")
+}
+
+func TestSidetreeRecoverAndDeactivate(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	signing, update, recovery := newTestKey(t), newTestKey(t), newTestKey(t)
+	create, did := sidetreeCreateOperation(t, signing, update, recovery)
+	_, err := s.ProcessSidetreeOperation(l.ctx, create)
+	require.NoError(t, err)
+	l.nextTx()
+
+	suffix := strings.TrimPrefix(did, didMethodPrefix)
+	recovered, newUpdate, newRecovery := newTestKey(t), newTestKey(t), newTestKey(t)
+	delta, deltaHash := sidetreeDelta(t, newUpdate, addKeyPatch(t, "key-2", recovered, "authentication"))
+	newRecoveryCommitment, _ := sidetreeCommitment(t, newRecovery)
+	recover := func(key *testKey) string {
+		_, reveal := sidetreeCommitment(t, key)
+
+		return operationJSON(t, map[string]interface{}{
+			"type":        sidetreeRecover,
+			"didSuffix":   suffix,
+			"revealValue": reveal,
+			"delta":       delta,
+			"signedData":  signSidetree(t, key, map[string]interface{}{"recoveryKey": sidetreeJwk(t, key), "recoveryCommitment": newRecoveryCommitment, "deltaHash": deltaHash}),
+		})
+	}
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, recover(update))
+	assertErrorCode(t, err, codeInvalidSignature, "should require the key of the recovery commitment")
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, recover(recovery))
+	require.NoError(t, err, "should recover the did")
+	l.nextTx()
+
+	resolution, err := s.Resolve(l.ctx, did, false)
+	require.NoError(t, err)
+	require.Len(t, resolution.DidDocument.VerificationMethod, 1, "should replace the document")
+	assert.Equal(t, did+"#key-2", resolution.DidDocument.VerificationMethod[0].Id)
+	assert.Equal(t, newRecoveryCommitment, resolution.DidDocumentMetadata.Method.RecoveryCommitment, "should commit to the new recovery key")
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, sidetreeUpdateOperation(t, did, update, newTestKey(t)))
+	assertErrorCode(t, err, codeInvalidSignature, "should invalidate the previous update commitment")
+
+	deactivate := func(key *testKey, didSuffix string) string {
+		_, reveal := sidetreeCommitment(t, key)
+
+		return operationJSON(t, map[string]interface{}{
+			"type":        sidetreeDeactivate,
+			"didSuffix":   suffix,
+			"revealValue": reveal,
+			"signedData":  signSidetree(t, key, map[string]interface{}{"recoveryKey": sidetreeJwk(t, key), "didSuffix": didSuffix}),
+		})
+	}
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, deactivate(newRecovery, suffix+"x"))
+	assertErrorCode(t, err, codeInvalidSignature, "should require the signed data to name the did")
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, deactivate(newRecovery, suffix))
+	require.NoError(t, err, "should deactivate the did")
+	l.nextTx()
+
+	resolution, err = s.Resolve(l.ctx, did, false)
+	require.NoError(t, err)
+	assert.Nil(t, resolution.DidDocument, "should not return the document of deactivated dids")
+	assert.Equal(t, resolutionDeactivated, resolution.DidResolutionMetadata.Error)
+	assert.True(t, resolution.DidDocumentMetadata.Deactivated)
+	assert.Equal(t, &SidetreeMethodMetadata{Published: true}, resolution.DidDocumentMetadata.Method, "should clear the commitments")
+
+	_, err = s.ProcessSidetreeOperation(l.ctx, deactivate(newRecovery, suffix))
+	assertErrorCode(t, err, codeDidDeactivated, "should not apply operations to deactivated dids")
+}
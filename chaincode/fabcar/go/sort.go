@@ -0,0 +1,276 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// modifiedIndex is the name of the composite key index of dids by the time of
+// their last change. Each did has a single entry, keyed by the ModifiedAt time
+// of its provenance and the did key, which is replaced on every change
+const modifiedIndex = "modified~key"
+
+// richQueryUnsupported is part of the error peers return for rich queries on a
+// LevelDB state database, ExecuteQuery not supported for leveldb
+const richQueryUnsupported = "not supported"
+
+// didSort describes an order QueryAllDidsSorted accepts: the member of the
+// stored did CouchDB sorts by, the CouchDB index on it, the composite key
+// index read instead when the state database does not run rich queries and
+// the value of a did the order sorts by, empty for dids left out of the order
+type didSort struct {
+	member   string
+	index    string
+	keyIndex string
+	value    func(did *Did) string
+}
+
+// didSorts maps the orders QueryAllDidsSorted accepts to their members and indexes
+var didSorts = map[string]didSort{
+	"created": {member: "provenance.createdAt", index: "indexCreatedAt", keyIndex: createdDateIndex, value: func(did *Did) string {
+		if did.Provenance == nil {
+			return ""
+		}
+
+		return did.Provenance.CreatedAt
+	}},
+	"updated": {member: "provenance.modifiedAt", index: "indexModifiedAt", keyIndex: modifiedIndex, value: func(did *Did) string {
+		if did.Provenance == nil {
+			return ""
+		}
+
+		return did.Provenance.ModifiedAt
+	}},
+	"id": {member: "id", index: "indexId", keyIndex: didIdIndex, value: func(did *Did) string {
+		return did.Id
+	}},
+}
+
+// putSortKeys records the time of the transaction as the time the did stored
+// with didNumber was last changed, and as its creation time if created is set,
+// and moves its entry in the modification index
+func putSortKeys(ctx contractapi.TransactionContextInterface, didNumber string, did *Did, created bool) error {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if previous := did.Provenance.ModifiedAt; previous != "" {
+		previousKey, err := ctx.GetStub().CreateCompositeKey(modifiedIndex, []string{previous, didNumber})
+
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.GetStub().DelState(previousKey); err != nil {
+			return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+		}
+	}
+
+	did.Provenance.ModifiedAt = now.Format(auditTimeLayout)
+
+	if created {
+		did.Provenance.CreatedAt = did.Provenance.ModifiedAt
+	}
+
+	indexKey, err := ctx.GetStub().CreateCompositeKey(modifiedIndex, []string{did.Provenance.ModifiedAt, didNumber})
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// sortQuery returns the CouchDB query for the dids in the given order, using
+// the index of its member
+func sortQuery(order didSort) (string, error) {
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"authenticationId": map[string]bool{"$exists": true},
+			"deleted":          map[string]bool{"$ne": true},
+			order.member:       map[string]bool{"$exists": true},
+		},
+		"sort":      []map[string]string{{order.member: "asc"}},
+		"use_index": []string{"_design/" + order.index + "Doc", order.index},
+	}
+
+	queryAsBytes, err := json.Marshal(query)
+
+	if err != nil {
+		return "", err
+	}
+
+	return string(queryAsBytes), nil
+}
+
+// sortedDidPage returns a page of the dids in the given order from CouchDB
+func sortedDidPage(ctx contractapi.TransactionContextInterface, order didSort, pageSize int32, bookmark string) ([]QueryResult, string, error) {
+	query, err := sortQuery(order)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+
+	if err != nil {
+		return nil, "", err
+	}
+	defer resultsIterator.Close()
+
+	results := []QueryResult{}
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		objectType, _, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+
+		if err != nil || objectType != didObjectType {
+			continue
+		}
+
+		result, err := didQueryResult(ctx, queryResponse.Key, queryResponse.Value)
+
+		if err != nil {
+			return nil, "", err
+		}
+
+		results = append(results, *result)
+	}
+
+	return results, metadata.Bookmark, nil
+}
+
+// indexedDidPage returns a page of the dids in the given order read from its
+// composite key index. Deleted dids and dids without a value to sort by are
+// skipped, and further entries read in their place
+func indexedDidPage(ctx contractapi.TransactionContextInterface, order didSort, pageSize int32, bookmark string) ([]QueryResult, string, error) {
+	results := []QueryResult{}
+
+	for {
+		resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(order.keyIndex, []string{}, pageSize-int32(len(results)), bookmark)
+
+		if err != nil {
+			return nil, "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+		}
+
+		for resultsIterator.HasNext() {
+			queryResponse, err := resultsIterator.Next()
+
+			if err != nil {
+				resultsIterator.Close()
+				return nil, "", err
+			}
+
+			_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+
+			if err != nil {
+				resultsIterator.Close()
+				return nil, "", err
+			}
+
+			didNumber := keyParts[len(keyParts)-1]
+			did, err := indexedDid(ctx, didNumber)
+
+			if err != nil {
+				resultsIterator.Close()
+				return nil, "", err
+			}
+
+			if did != nil && order.value(did) != "" {
+				results = append(results, *newQueryResult(didNumber, did))
+			}
+		}
+
+		resultsIterator.Close()
+		bookmark = metadata.Bookmark
+
+		if bookmark == "" || int32(len(results)) >= pageSize {
+			return results, bookmark, nil
+		}
+	}
+}
+
+// QueryAllDidsSorted returns a page of at most pageSize dids in ascending order
+// of sortBy, starting at the bookmark returned with the previous page. sortBy is
+// one of created, updated, the time of the last change, and id, the default.
+// The dids are sorted by CouchDB when it is the state database, or read from
+// composite key indexes otherwise, which order the ids by their colon separated
+// segments. Dids without provenance times, such as the seeded dids, are only
+// returned in id order. Deleted dids are skipped
+func (s *DidContract) QueryAllDidsSorted(ctx contractapi.TransactionContextInterface, sortBy string, pageSize int32, bookmark string) (*PaginatedQueryResult, error) {
+	if sortBy == "" {
+		sortBy = "id"
+	}
+
+	order, ok := didSorts[sortBy]
+
+	if !ok {
+		orders := []string{}
+
+		for name := range didSorts {
+			orders = append(orders, name)
+		}
+
+		sort.Strings(orders)
+
+		return nil, newError(codeInvalidArgument, "Dids cannot be sorted by %s, expected one of %s", sortBy, strings.Join(orders, ", "))
+	}
+
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	results, next, err := sortedDidPage(ctx, order, pageSize, bookmark)
+
+	if err != nil && strings.Contains(err.Error(), richQueryUnsupported) {
+		results, next, err = indexedDidPage(ctx, order, pageSize, bookmark)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	page := PaginatedQueryResult{
+		Records:             results,
+		FetchedRecordsCount: int32(len(results)),
+		Bookmark:            next,
+	}
+
+	return &page, nil
+}
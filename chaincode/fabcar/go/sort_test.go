@@ -0,0 +1,232 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordMember returns the member of a decoded record named by a CouchDB
+// field path such as provenance.createdAt
+func recordMember(record map[string]interface{}, path string) (interface{}, bool) {
+	var value interface{} = record
+
+	for _, name := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+
+		if !ok {
+			return nil, false
+		}
+
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+// enableRichQueryPages answers GetQueryResultWithPagination with the JSON
+// records of the world state matching the selector of the query, ordered by
+// the first sort member. Bookmarks are offsets into the ordered records
+func (l *testLedger) enableRichQueryPages(t *testing.T) {
+	l.stub.GetQueryResultWithPaginationCalls(func(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+		var parsed struct {
+			Selector map[string]interface{} `json:"selector"`
+			Sort     []map[string]string    `json:"sort"`
+		}
+		require.NoError(t, json.Unmarshal([]byte(query), &parsed), "should pass a JSON query")
+		require.Len(t, parsed.Sort, 1, "should sort by one member")
+
+		matched := []*queryresult.KV{}
+		values := map[string]string{}
+
+		for _, kv := range l.prefixKVs("") {
+			record := map[string]interface{}{}
+
+			if json.Unmarshal(kv.Value, &record) != nil {
+				continue
+			}
+
+			matches := true
+
+			for member, condition := range parsed.Selector {
+				value, present := recordMember(record, member)
+				matches = matches && matchCondition(value, present, condition)
+			}
+
+			for member := range parsed.Sort[0] {
+				value, _ := recordMember(record, member)
+				values[kv.Key], _ = value.(string)
+			}
+
+			if matches {
+				matched = append(matched, kv)
+			}
+		}
+
+		sort.SliceStable(matched, func(i, j int) bool { return values[matched[i].Key] < values[matched[j].Key] })
+
+		offset, _ := strconv.Atoi(bookmark)
+		end := offset + int(pageSize)
+		next := strconv.Itoa(end)
+
+		if end >= len(matched) {
+			end = len(matched)
+			next = ""
+		}
+
+		metadata := peer.QueryResponseMetadata{FetchedRecordsCount: int32(end - offset), Bookmark: next}
+
+		return newStateIterator(matched[offset:end]), &metadata, nil
+	})
+}
+
+// sortedTestDids seeds the ledger with the two sample dids and creates three
+// dids, then updates the first created and deletes a fourth, returning the keys
+// of the two seeds and the three dids in creation order
+func sortedTestDids(t *testing.T, l *testLedger) []string {
+	l.setAdmin(true)
+	require.NoError(t, new(AdminContract).InitLedger(l.ctx, ""))
+	l.nextTx()
+
+	key := newTestKey(t)
+	first := createTestDid(t, l, key)
+	second := createTestDid(t, l, newTestKey(t))
+	third := createTestDid(t, l, newTestKey(t))
+
+	update := testUpdate(first, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, first, update, signUpdate(t, l, key, first, update)))
+	l.nextTx()
+
+	deletedKey := newTestKey(t)
+	deleteTestDid(t, l, deletedKey, createTestDid(t, l, deletedKey))
+
+	return []string{"DID0", "DID1", first, second, third}
+}
+
+// assertSortedDids checks the orders of QueryAllDidsSorted for the dids of
+// sortedTestDids
+func assertSortedDids(t *testing.T, l *testLedger, created []string) {
+	s := new(DidContract)
+
+	page, err := s.QueryAllDidsSorted(l.ctx, "created", 10, "")
+	require.NoError(t, err, "should sort by creation")
+	assert.Equal(t, created, resultKeys(page.Records), "should return the dids oldest first")
+	assert.Equal(t, int32(5), page.FetchedRecordsCount, "should include the seeded dids")
+
+	page, err = s.QueryAllDidsSorted(l.ctx, "updated", 10, "")
+	require.NoError(t, err, "should sort by last change")
+	assert.Equal(t, []string{created[0], created[1], created[3], created[4], created[2]}, resultKeys(page.Records), "should order dids by their last change")
+
+	ids := append([]string{"did:example:12346789abcdefghi", "did:example:12346789asdfghjkl"}, created[2:]...)
+	sort.Strings(ids[2:])
+
+	page, err = s.QueryAllDidsSorted(l.ctx, "", 10, "")
+	require.NoError(t, err, "should sort by id by default")
+
+	sortedIds := []string{}
+
+	for _, result := range page.Records {
+		sortedIds = append(sortedIds, result.Record.Id)
+	}
+
+	assert.Equal(t, ids, sortedIds, "should return all dids in id order")
+
+	page, err = s.QueryAllDidsSorted(l.ctx, "created", 3, "")
+	require.NoError(t, err)
+	require.Equal(t, created[:3], resultKeys(page.Records), "should return at most pageSize dids")
+	require.NotEqual(t, "", page.Bookmark, "should return the bookmark of the next page")
+
+	page, err = s.QueryAllDidsSorted(l.ctx, "created", 3, page.Bookmark)
+	require.NoError(t, err)
+	assert.Equal(t, created[3:], resultKeys(page.Records), "should continue at the bookmark")
+	assert.Equal(t, "", page.Bookmark, "should return an empty bookmark after the last page")
+
+	_, err = s.QueryAllDidsSorted(l.ctx, "expires", 10, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject unknown orders")
+
+	_, err = s.QueryAllDidsSorted(l.ctx, "id", 0, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a positive page size")
+
+	_, err = s.QueryAllDidsSorted(l.ctx, "id", maxQueryRecords+1, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should bound the page size")
+}
+
+func TestQueryAllDidsSorted(t *testing.T) {
+	l := newTestLedger(t)
+	l.enableRichQueryPages(t)
+
+	created := sortedTestDids(t, l)
+	assertSortedDids(t, l, created)
+
+	query, _, _ := l.stub.GetQueryResultWithPaginationArgsForCall(l.stub.GetQueryResultWithPaginationCallCount() - 2)
+	assert.Contains(t, query, `"sort":[{"provenance.createdAt":"asc"}]`, "should sort in CouchDB")
+	assert.Contains(t, query, `"use_index":["_design/indexCreatedAtDoc","indexCreatedAt"]`, "should name the index of the order")
+	assert.Zero(t, l.stub.GetStateByPartialCompositeKeyWithPaginationCallCount(), "should not read the composite key indexes")
+
+	l.stub.GetQueryResultWithPaginationReturns(nil, nil, errors.New("GetQueryResultWithPagination error"))
+	_, err := new(DidContract).QueryAllDidsSorted(l.ctx, "id", 10, "")
+	assert.EqualError(t, err, "GetQueryResultWithPagination error", "should return query errors")
+}
+
+func TestQueryAllDidsSortedWithoutCouchDB(t *testing.T) {
+	l := newTestLedger(t)
+	l.stub.GetQueryResultWithPaginationReturns(nil, nil, errors.New("ExecuteQuery not supported for leveldb"))
+
+	created := sortedTestDids(t, l)
+	assertSortedDids(t, l, created)
+
+	prefix, err := shim.CreateCompositeKey(modifiedIndex, []string{})
+	require.NoError(t, err)
+	assert.Len(t, l.prefixKVs(prefix), 6, "should keep one modification entry per did")
+}
+
+func TestSortIndexes(t *testing.T) {
+	for name, order := range didSorts {
+		indexAsBytes, err := ioutil.ReadFile(filepath.Join("META-INF", "statedb", "couchdb", "indexes", order.index+".json"))
+		require.NoError(t, err, "should package the index of %s", name)
+
+		var index struct {
+			Index struct {
+				Fields []string `json:"fields"`
+			} `json:"index"`
+			Ddoc string `json:"ddoc"`
+			Name string `json:"name"`
+		}
+		require.NoError(t, json.Unmarshal(indexAsBytes, &index), "should define the index of %s as JSON", name)
+		assert.Equal(t, []string{order.member}, index.Index.Fields, "should index the member of %s", name)
+		assert.Equal(t, order.index+"Doc", index.Ddoc, "should name the design document of %s", name)
+		assert.Equal(t, order.index, index.Name, "should name the index of %s", name)
+	}
+}
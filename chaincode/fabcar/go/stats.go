@@ -0,0 +1,334 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// registryStatsObjectType is the composite key object type of the counts of the
+// registry statistics, keyed by their dimension and value
+const registryStatsObjectType = "registryStats"
+
+// Dimensions dids are counted by in the registry statistics
+const (
+	statsByStatus      = "status"
+	statsByKeyType     = "keyType"
+	statsByMSP         = "msp"
+	statsByServiceType = "serviceType"
+)
+
+// didStatusDeleted is the status deleted dids are counted by in the registry
+// statistics. List queries leave them out
+const didStatusDeleted = "deleted"
+
+// RegistryStats counts the dids of the registry by status, by the types of their
+// verification methods, by the MSP of the organization that registered them and
+// by the types of their services. Dids with several key or service types are
+// counted once for each, deleted dids only by status
+type RegistryStats struct {
+	ByStatus      map[string]int `json:"byStatus"`
+	ByKeyType     map[string]int `json:"byKeyType"`
+	ByMSP         map[string]int `json:"byMsp"`
+	ByServiceType map[string]int `json:"byServiceType"`
+}
+
+// RegistryStatsCount is the number of dids stored with a value of a dimension of
+// the registry statistics
+type RegistryStatsCount struct {
+	Dimension string `json:"dimension"`
+	Value     string `json:"value"`
+	Count     int    `json:"count"`
+}
+
+// statsBucket identifies a count of the registry statistics
+type statsBucket struct {
+	dimension string
+	value     string
+}
+
+// statsChanges holds the changes of the registry statistics made by a
+// transaction and the counts each did it wrote is now counted in
+type statsChanges struct {
+	counts map[statsBucket]int
+	dids   map[string][]statsBucket
+}
+
+// statsRecorder is implemented by transaction contexts that collect the changes
+// of the registry statistics until the transaction function returns. Writes of a
+// transaction are not visible to its own reads, so counts changed by several
+// dids of a transaction must be written once, see writeStatsChanges
+type statsRecorder interface {
+	statsChanges() *statsChanges
+}
+
+// registryContext is the transaction context of the contracts that write dids
+type registryContext struct {
+	contractapi.TransactionContext
+	changes *statsChanges
+}
+
+// statsChanges returns the changes of the registry statistics made so far by
+// the transaction
+func (c *registryContext) statsChanges() *statsChanges {
+	if c.changes == nil {
+		c.changes = &statsChanges{counts: map[statsBucket]int{}, dids: map[string][]statsBucket{}}
+	}
+
+	return c.changes
+}
+
+// GetTransactionContextHandler returns the transaction context the did
+// contract's functions are called with
+func (s *DidContract) GetTransactionContextHandler() contractapi.SettableTransactionContextInterface {
+	return new(registryContext)
+}
+
+// GetTransactionContextHandler returns the transaction context the admin
+// contract's functions are called with
+func (a *AdminContract) GetTransactionContextHandler() contractapi.SettableTransactionContextInterface {
+	return new(registryContext)
+}
+
+// GetAfterTransaction returns the handler called after every transaction
+// function of the did contract, which writes the changes of the registry
+// statistics
+func (s *DidContract) GetAfterTransaction() interface{} {
+	return writeStatsChanges
+}
+
+// GetAfterTransaction returns the handler called after every transaction
+// function of the admin contract, which writes the changes of the registry
+// statistics
+func (a *AdminContract) GetAfterTransaction() interface{} {
+	return writeStatsChanges
+}
+
+// statsBuckets returns the counts of the registry statistics did is counted in,
+// none for nil
+func statsBuckets(did *Did) []statsBucket {
+	if did == nil {
+		return nil
+	}
+
+	if did.Deleted {
+		return []statsBucket{{statsByStatus, didStatusDeleted}}
+	}
+
+	status := didStatusActive
+
+	if did.Deactivated {
+		status = didStatusDeactivated
+	}
+
+	buckets := []statsBucket{{statsByStatus, status}}
+
+	if did.Provenance != nil && did.Provenance.Created != nil {
+		buckets = append(buckets, statsBucket{statsByMSP, did.Provenance.Created.MSPID})
+	}
+
+	document := did.document()
+	seen := map[statsBucket]bool{}
+	add := func(bucket statsBucket) {
+		if bucket.value != "" && !seen[bucket] {
+			seen[bucket] = true
+			buckets = append(buckets, bucket)
+		}
+	}
+
+	for _, method := range document.VerificationMethod {
+		add(statsBucket{statsByKeyType, method.Type})
+	}
+
+	for _, service := range document.Service {
+		add(statsBucket{statsByServiceType, service.Type})
+	}
+
+	return buckets
+}
+
+// countDid changes the registry statistics for the did stored under the given
+// world state key becoming did, or nil when it is removed. It is called before
+// the did is written. Transaction contexts implementing statsRecorder collect
+// the changes, others have them written at once
+func countDid(ctx contractapi.TransactionContextInterface, key string, didNumber string, did *Did) error {
+	recorder, recording := ctx.(statsRecorder)
+
+	var previous []statsBucket
+	counted := false
+
+	if recording {
+		previous, counted = recorder.statsChanges().dids[didNumber]
+	}
+
+	if !counted {
+		storedAsBytes, err := ctx.GetStub().GetState(key)
+
+		if err != nil {
+			return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		}
+
+		if storedAsBytes != nil {
+			stored := new(Did)
+
+			if err := unmarshalRecord(didNumber, storedAsBytes, stored); err != nil {
+				return err
+			}
+
+			previous = statsBuckets(stored)
+		}
+	}
+
+	current := statsBuckets(did)
+	changes := map[statsBucket]int{}
+
+	for _, bucket := range previous {
+		changes[bucket]--
+	}
+
+	for _, bucket := range current {
+		changes[bucket]++
+	}
+
+	if !recording {
+		return putStatsChanges(ctx, changes)
+	}
+
+	pending := recorder.statsChanges()
+
+	for bucket, change := range changes {
+		pending.counts[bucket] += change
+	}
+	pending.dids[didNumber] = current
+
+	return nil
+}
+
+// writeStatsChanges writes the changes of the registry statistics collected by
+// the transaction context
+func writeStatsChanges(ctx contractapi.TransactionContextInterface) error {
+	recorder, ok := ctx.(statsRecorder)
+
+	if !ok {
+		return nil
+	}
+
+	pending := recorder.statsChanges()
+
+	if err := putStatsChanges(ctx, pending.counts); err != nil {
+		return err
+	}
+
+	pending.counts = map[statsBucket]int{}
+
+	return nil
+}
+
+// putStatsChanges adds the changes to the counts of the registry statistics,
+// deleting counts that drop to zero
+func putStatsChanges(ctx contractapi.TransactionContextInterface, changes map[statsBucket]int) error {
+	for bucket, change := range changes {
+		if change == 0 {
+			continue
+		}
+
+		key, err := ctx.GetStub().CreateCompositeKey(registryStatsObjectType, []string{bucket.dimension, bucket.value})
+
+		if err != nil {
+			return err
+		}
+
+		countAsBytes, err := ctx.GetStub().GetState(key)
+
+		if err != nil {
+			return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		}
+
+		count := RegistryStatsCount{Dimension: bucket.dimension, Value: bucket.value}
+
+		if countAsBytes != nil {
+			if err := unmarshalRecord(key, countAsBytes, &count); err != nil {
+				return err
+			}
+		}
+
+		count.Count += change
+
+		if count.Count <= 0 {
+			if err := ctx.GetStub().DelState(key); err != nil {
+				return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+			}
+
+			continue
+		}
+
+		countAsBytes, err = marshalRecord(key, count)
+
+		if err != nil {
+			return err
+		}
+
+		err = ctx.GetStub().PutState(key, countAsBytes)
+
+		if err != nil {
+			return fmt.Errorf("Failed to put to world state. %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// GetRegistryStats returns the number of dids of the registry by status, key
+// type, registering MSP and service type. The counts are kept up to date by the
+// transactions writing dids, so reading them does not scan the dids. Expiry
+// depends on the time of the query and is not counted, expired dids are counted
+// by the status they are stored with. Dids seeded by InitLedger have no
+// registering MSP
+func (s *DidContract) GetRegistryStats(ctx contractapi.TransactionContextInterface) (*RegistryStats, error) {
+	stats := RegistryStats{ByStatus: map[string]int{}, ByKeyType: map[string]int{}, ByMSP: map[string]int{}, ByServiceType: map[string]int{}}
+	dimensions := map[string]map[string]int{
+		statsByStatus:      stats.ByStatus,
+		statsByKeyType:     stats.ByKeyType,
+		statsByMSP:         stats.ByMSP,
+		statsByServiceType: stats.ByServiceType,
+	}
+
+	err := scanState(ctx, registryStatsObjectType, []string{}, func(key string, value []byte) (bool, error) {
+		count := RegistryStatsCount{}
+
+		if err := unmarshalRecord(key, value, &count); err != nil {
+			return false, err
+		}
+
+		if counts, ok := dimensions[count.Dimension]; ok {
+			counts[count.Value] = count.Count
+		}
+
+		return true, nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &stats, nil
+}
@@ -0,0 +1,129 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registryStats returns the registry statistics of the test ledger
+func registryStats(t *testing.T, l *testLedger) *RegistryStats {
+	stats, err := new(DidContract).GetRegistryStats(l.ctx)
+	require.NoError(t, err)
+
+	return stats
+}
+
+func TestGetRegistryStats(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	stats := registryStats(t, l)
+	assert.Equal(t, &RegistryStats{ByStatus: map[string]int{}, ByKeyType: map[string]int{}, ByMSP: map[string]int{}, ByServiceType: map[string]int{}},
+		stats, "should count nothing on an empty ledger")
+
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+	deactivatedKey := newTestKey(t)
+	deactivated := createTestDid(t, l, deactivatedKey)
+	deletedKey := newTestKey(t)
+	deleted := createTestDid(t, l, deletedKey)
+
+	stats = registryStats(t, l)
+	assert.Equal(t, map[string]int{didStatusActive: 3}, stats.ByStatus, "should count new dids as active")
+	assert.Equal(t, map[string]int{testKeyType: 3}, stats.ByKeyType, "should count the key types")
+	assert.Equal(t, map[string]int{testMSPID: 3}, stats.ByMSP, "should count the registering MSPs")
+	assert.Equal(t, map[string]int{"VerifiableCredentialService": 3}, stats.ByServiceType, "should count the service types")
+
+	did, err := s.QueryDidByKey(l.ctx, deactivated)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, deactivated, signUpdate(t, l, deactivatedKey, deactivated, &update)))
+	l.nextTx()
+
+	deleteTestDid(t, l, deletedKey, deleted)
+
+	stats = registryStats(t, l)
+	assert.Equal(t, map[string]int{didStatusActive: 1, didStatusDeactivated: 1, didStatusDeleted: 1}, stats.ByStatus, "should move dids between statuses")
+	assert.Equal(t, map[string]int{testKeyType: 2}, stats.ByKeyType, "should only count deleted dids by status")
+	assert.Equal(t, map[string]int{testMSPID: 2}, stats.ByMSP, "should only count deleted dids by status")
+
+	update = *testUpdate(id, key, "https://example.com")
+	update.ServiceType = "LinkedDomains"
+	require.NoError(t, updateDid(l, id, &update, signUpdate(t, l, key, id, &update)))
+	l.nextTx()
+
+	stats = registryStats(t, l)
+	assert.Equal(t, map[string]int{"VerifiableCredentialService": 1, "LinkedDomains": 1}, stats.ByServiceType, "should follow updates of the service")
+
+	require.NoError(t, s.ProposeTransfer(l.ctx, id, otherClientID))
+	l.nextTx()
+	l.setClient(otherClientID, otherMSPID)
+	require.NoError(t, s.AcceptTransfer(l.ctx, id))
+	l.nextTx()
+
+	stats = registryStats(t, l)
+	assert.Equal(t, map[string]int{didStatusActive: 1, didStatusDeactivated: 1, didStatusDeleted: 1}, stats.ByStatus, "should not count moved dids twice")
+	assert.Equal(t, map[string]int{testMSPID: 2}, stats.ByMSP, "should keep counting dids by the MSP that registered them")
+
+	l.setAdmin(true)
+	l.setTime(testStart.Add(deletedDidRetention + time.Minute))
+
+	purged, err := new(AdminContract).PurgeDeletedDids(l.ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{deleted}, purged)
+
+	stats = registryStats(t, l)
+	assert.Equal(t, map[string]int{didStatusActive: 1, didStatusDeactivated: 1}, stats.ByStatus, "should drop the counts of purged dids")
+
+	l.stub.GetStateByPartialCompositeKeyWithPaginationReturns(nil, nil, errors.New("GetStateByPartialCompositeKeyWithPagination error"))
+	_, err = s.GetRegistryStats(l.ctx)
+	assert.EqualError(t, err, "Failed to read from world state. GetStateByPartialCompositeKeyWithPagination error", "should return ledger errors")
+}
+
+func TestRegistryStatsOfTransactionContext(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	ctx := s.GetTransactionContextHandler().(*registryContext)
+	ctx.SetStub(l.stub)
+	ctx.SetClientIdentity(l.identity)
+
+	_, err := s.BatchCreateDids(ctx, batchJSON(t, batchItem(t, newTestKey(t)), batchItem(t, newTestKey(t))))
+	require.NoError(t, err)
+	assert.Empty(t, registryStats(t, l).ByStatus, "should collect the changes until the transaction function returns")
+
+	after := s.GetAfterTransaction().(func(ctx contractapi.TransactionContextInterface) error)
+	require.NoError(t, after(ctx))
+
+	stats := registryStats(t, l)
+	assert.Equal(t, map[string]int{didStatusActive: 2}, stats.ByStatus, "should write the changes of every did of the transaction")
+	assert.Equal(t, map[string]int{"VerifiableCredentialService": 2}, stats.ByServiceType, "should write the changes of every did of the transaction")
+
+	require.NoError(t, after(ctx))
+	assert.Equal(t, stats, registryStats(t, l), "should write the changes once")
+}
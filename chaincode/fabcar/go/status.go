@@ -0,0 +1,56 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Statuses list queries filter dids by. Deactivated dids are not counted as
+// expired, and active dids are neither
+const (
+	didStatusActive      = "active"
+	didStatusDeactivated = "deactivated"
+	didStatusExpired     = "expired"
+	didStatusAll         = "all"
+)
+
+// didStatusFilter returns the function reporting whether a did has the given
+// status at the time of the transaction. An empty status selects active dids
+func didStatusFilter(ctx contractapi.TransactionContextInterface, status string) (func(did *Did) bool, error) {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch status {
+	case "", didStatusActive:
+		return func(did *Did) bool { return !did.Deactivated && !isExpired(did, now) }, nil
+	case didStatusDeactivated:
+		return func(did *Did) bool { return did.Deactivated }, nil
+	case didStatusExpired:
+		return func(did *Did) bool { return !did.Deactivated && isExpired(did, now) }, nil
+	case didStatusAll:
+		return func(did *Did) bool { return true }, nil
+	}
+
+	return nil, newError(codeInvalidArgument, "Status %s is not one of %s, %s, %s and %s", status, didStatusActive, didStatusDeactivated, didStatusExpired, didStatusAll)
+}
@@ -0,0 +1,94 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryAllDidsByStatus(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	active := createTestDid(t, l, newTestKey(t))
+
+	deactivatedKey := newTestKey(t)
+	deactivated := createTestDid(t, l, deactivatedKey)
+	did, err := s.QueryDidByKey(l.ctx, deactivated)
+	require.NoError(t, err)
+	update := updatableDetails(did)
+	update.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, deactivated, signUpdate(t, l, deactivatedKey, deactivated, &update)))
+	l.nextTx()
+
+	expired, err := s.CreateDid(l.ctx, "#keys-1", testKeyType, "", newTestKey(t).pem, "#vcs", "VerifiableCredentialService", testEndpoint, testStart.Add(time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+	l.nextTx()
+
+	deletedKey := newTestKey(t)
+	deleteTestDid(t, l, deletedKey, createTestDid(t, l, deletedKey))
+
+	page, err := s.QueryAllDids(l.ctx, "", "")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{active, expired}, resultKeys(page.Records), "should return dids that did not expire yet")
+
+	l.setTime(testStart.Add(2 * time.Hour))
+
+	statuses := map[string][]string{
+		"":                   {active},
+		didStatusActive:      {active},
+		didStatusDeactivated: {deactivated},
+		didStatusExpired:     {expired},
+		didStatusAll:         {active, deactivated, expired},
+	}
+
+	for status, keys := range statuses {
+		page, err = s.QueryAllDids(l.ctx, status, "")
+		require.NoError(t, err, "should filter by status %q", status)
+		assert.ElementsMatch(t, keys, resultKeys(page.Records), "should return the dids of status %q", status)
+		assert.Equal(t, int32(len(keys)), page.FetchedRecordsCount, "should count the dids of status %q", status)
+
+		page, err = s.QueryAllDidsWithPagination(l.ctx, status, 10, "")
+		require.NoError(t, err, "should filter pages by status %q", status)
+		assert.ElementsMatch(t, keys, resultKeys(page.Records), "should return the dids of status %q in pages", status)
+	}
+
+	page, err = s.QueryAllDidsWithPagination(l.ctx, didStatusDeactivated, 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{deactivated}, resultKeys(page.Records), "should read past dids of other statuses to fill the page")
+
+	keyed, err := s.QueryAllDidsKeyedById(l.ctx, didStatusExpired, "")
+	require.NoError(t, err)
+	assert.Contains(t, keyed.Dids, expired, "should filter keyed results by status")
+	assert.Len(t, keyed.Dids, 1)
+
+	_, err = s.QueryAllDids(l.ctx, "revoked", "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject unknown statuses")
+
+	_, err = s.QueryAllDidsWithPagination(l.ctx, didStatusAll, maxQueryRecords+1, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should reject oversized page sizes")
+
+	_, err = s.QueryAllDidsWithPagination(l.ctx, didStatusAll, 0, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a positive page size")
+}
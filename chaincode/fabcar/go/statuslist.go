@@ -0,0 +1,243 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// statusListObjectType is the composite key object type under which revocation
+// status lists are stored
+const statusListObjectType = "statuslist"
+
+// statusListMinimumLength is the minimum number of entries of a status list, as
+// recommended by StatusList2021 for group privacy
+const statusListMinimumLength = 131072
+
+// StatusList describes a revocation bitstring owned by an issuer did. Bit i is
+// set once the credential allocated index i has been revoked
+type StatusList struct {
+	IssuerDid string `json:"issuerDid"`
+	ListId    string `json:"listId"`
+	Length    int    `json:"length"`
+	Bitstring []byte `json:"bitstring"`
+}
+
+// StatusListCredentialSubject describes a status list in the StatusList2021
+// credential subject format
+type StatusListCredentialSubject struct {
+	Id            string `json:"id"`
+	Type          string `json:"type"`
+	StatusPurpose string `json:"statusPurpose"`
+	EncodedList   string `json:"encodedList"`
+}
+
+// statusListKey returns the key of the status list with given issuer and id
+func statusListKey(ctx contractapi.TransactionContextInterface, issuerDid string, listId string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(statusListObjectType, []string{issuerDid, listId})
+}
+
+// isSet reports whether the bit at index is set
+func (l *StatusList) isSet(index int) bool {
+	return l.Bitstring[index/8]&(0x80>>uint(index%8)) != 0
+}
+
+// set sets the bit at index
+func (l *StatusList) set(index int) {
+	l.Bitstring[index/8] |= 0x80 >> uint(index%8)
+}
+
+// encode returns the GZIP compressed, base64url encoded bitstring
+func (l *StatusList) encode() (string, error) {
+	var buffer bytes.Buffer
+
+	writer := gzip.NewWriter(&buffer)
+
+	if _, err := writer.Write(l.Bitstring); err != nil {
+		return "", err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+// assertIssuerController returns an error unless the issuer did is registered
+// and controlled by the submitting client
+func assertIssuerController(ctx contractapi.TransactionContextInterface, issuerDid string) error {
+	issuer, err := findDidById(ctx, issuerDid)
+
+	if err != nil {
+		return err
+	}
+
+	return assertController(ctx, issuer.Key, issuer.Record)
+}
+
+// getStatusList reads the status list with given issuer and id
+func getStatusList(ctx contractapi.TransactionContextInterface, issuerDid string, listId string) (*StatusList, error) {
+	key, err := statusListKey(ctx, issuerDid, listId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	listAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if listAsBytes == nil {
+		return nil, newError(codeNotFound, "Status list %s of %s does not exist", listId, issuerDid)
+	}
+
+	list := new(StatusList)
+
+	if err := unmarshalRecord(key, listAsBytes, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// putStatusList writes the status list to the world state
+func putStatusList(ctx contractapi.TransactionContextInterface, list *StatusList) error {
+	key, err := statusListKey(ctx, list.IssuerDid, list.ListId)
+
+	if err != nil {
+		return err
+	}
+
+	listAsBytes, err := marshalRecord(key, list)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, listAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// CreateStatusList creates an empty revocation status list for an issuer did
+// controlled by the submitting client. Lists shorter than the StatusList2021
+// minimum are extended to it
+func (c *CredentialContract) CreateStatusList(ctx contractapi.TransactionContextInterface, issuerDid string, listId string, length int) error {
+	if err := assertIssuerController(ctx, issuerDid); err != nil {
+		return err
+	}
+
+	if _, err := getStatusList(ctx, issuerDid, listId); err == nil {
+		return newError(codeAlreadyExists, "Status list %s of %s already exists", listId, issuerDid)
+	}
+
+	if length < statusListMinimumLength {
+		length = statusListMinimumLength
+	}
+
+	length = (length + 7) / 8 * 8
+
+	list := StatusList{
+		IssuerDid: issuerDid,
+		ListId:    listId,
+		Length:    length,
+		Bitstring: make([]byte, length/8),
+	}
+
+	return putStatusList(ctx, &list)
+}
+
+// RevokeCredential sets the status bit at index in a status list of the issuer.
+// Revocation cannot be undone
+func (c *CredentialContract) RevokeCredential(ctx contractapi.TransactionContextInterface, issuerDid string, listId string, index int) error {
+	if err := assertIssuerController(ctx, issuerDid); err != nil {
+		return err
+	}
+
+	list, err := getStatusList(ctx, issuerDid, listId)
+
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= list.Length {
+		return newError(codeInvalidArgument, "Index %d is outside status list %s of length %d", index, listId, list.Length)
+	}
+
+	if list.isSet(index) {
+		return newError(codeAlreadyExists, "Index %d of status list %s is already revoked", index, listId)
+	}
+
+	list.set(index)
+
+	return putStatusList(ctx, list)
+}
+
+// IsRevoked reports whether the status bit at index is set in a status list of the issuer
+func (c *CredentialContract) IsRevoked(ctx contractapi.TransactionContextInterface, issuerDid string, listId string, index int) (bool, error) {
+	list, err := getStatusList(ctx, issuerDid, listId)
+
+	if err != nil {
+		return false, err
+	}
+
+	if index < 0 || index >= list.Length {
+		return false, newError(codeInvalidArgument, "Index %d is outside status list %s of length %d", index, listId, list.Length)
+	}
+
+	return list.isSet(index), nil
+}
+
+// GetStatusList returns a status list of the issuer as a StatusList2021 credential
+// subject, so verifiers can check credential status with standard tooling
+func (c *CredentialContract) GetStatusList(ctx contractapi.TransactionContextInterface, issuerDid string, listId string) (*StatusListCredentialSubject, error) {
+	list, err := getStatusList(ctx, issuerDid, listId)
+
+	if err != nil {
+		return nil, err
+	}
+
+	encodedList, err := list.encode()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode status list %s. %s", listId, err.Error())
+	}
+
+	subject := StatusListCredentialSubject{
+		Id:            issuerDid + "/status/" + listId,
+		Type:          "StatusList2021",
+		StatusPurpose: "revocation",
+		EncodedList:   encodedList,
+	}
+
+	return &subject, nil
+}
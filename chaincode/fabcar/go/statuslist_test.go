@@ -0,0 +1,119 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateStatusList(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuer := createTestDid(t, l, newTestKey(t))
+
+	err := c.CreateStatusList(l.ctx, issuer, "1", 100)
+	require.NoError(t, err, "should create the status list")
+
+	list, err := getStatusList(l.ctx, issuer, "1")
+	require.NoError(t, err)
+	assert.Equal(t, statusListMinimumLength, list.Length, "should extend short lists to the minimum length")
+	assert.Len(t, list.Bitstring, statusListMinimumLength/8, "should allocate a bit per entry")
+
+	err = c.CreateStatusList(l.ctx, issuer, "2", statusListMinimumLength+1)
+	require.NoError(t, err)
+
+	list, err = getStatusList(l.ctx, issuer, "2")
+	require.NoError(t, err)
+	assert.Equal(t, statusListMinimumLength+8, list.Length, "should round the length up to whole bytes")
+
+	err = c.CreateStatusList(l.ctx, issuer, "1", 100)
+	assertErrorCode(t, err, codeAlreadyExists, "should not create a list twice")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = c.CreateStatusList(l.ctx, issuer, "3", 100)
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the issuer")
+}
+
+func TestRevokeCredential(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuer := createTestDid(t, l, newTestKey(t))
+	require.NoError(t, c.CreateStatusList(l.ctx, issuer, "1", 0))
+
+	err := c.RevokeCredential(l.ctx, issuer, "1", 42)
+	require.NoError(t, err, "should revoke the index")
+
+	revoked, err := c.IsRevoked(l.ctx, issuer, "1", 42)
+	assert.Nil(t, err, "should read the status")
+	assert.True(t, revoked, "should set the bit of the index")
+
+	revoked, err = c.IsRevoked(l.ctx, issuer, "1", 43)
+	assert.Nil(t, err, "should read the status")
+	assert.False(t, revoked, "should not set other bits")
+
+	err = c.RevokeCredential(l.ctx, issuer, "1", 42)
+	assertErrorCode(t, err, codeAlreadyExists, "should not revoke an index twice")
+
+	err = c.RevokeCredential(l.ctx, issuer, "1", statusListMinimumLength)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject indexes outside the list")
+
+	_, err = c.IsRevoked(l.ctx, issuer, "1", -1)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject negative indexes")
+
+	err = c.RevokeCredential(l.ctx, issuer, "2", 1)
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown lists")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = c.RevokeCredential(l.ctx, issuer, "1", 1)
+	assertErrorCode(t, err, codeUnauthorized, "should require the caller to control the issuer")
+}
+
+func TestGetStatusList(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	issuer := createTestDid(t, l, newTestKey(t))
+	require.NoError(t, c.CreateStatusList(l.ctx, issuer, "1", 0))
+	require.NoError(t, c.RevokeCredential(l.ctx, issuer, "1", 9))
+
+	subject, err := c.GetStatusList(l.ctx, issuer, "1")
+	require.NoError(t, err, "should return the status list")
+	assert.Equal(t, issuer+"/status/1", subject.Id, "should identify the list by issuer and id")
+	assert.Equal(t, "StatusList2021", subject.Type, "should return a StatusList2021 subject")
+
+	compressed, err := base64.RawURLEncoding.DecodeString(subject.EncodedList)
+	require.NoError(t, err, "should base64url encode the list")
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	require.NoError(t, err, "should compress the list with GZIP")
+
+	bitstring, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Len(t, bitstring, statusListMinimumLength/8, "should encode every entry")
+	assert.Equal(t, byte(0x40), bitstring[1], "should encode revoked entries most significant bit first")
+
+	_, err = c.GetStatusList(l.ctx, issuer, "2")
+	assertErrorCode(t, err, codeNotFound, "should fail for unknown lists")
+}
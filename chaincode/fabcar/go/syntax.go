@@ -0,0 +1,168 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// isUnreserved reports whether c is an unreserved character of RFC 3986
+func isUnreserved(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte("-._~", c) >= 0
+}
+
+// checkChars returns an error naming the first character of value not allowed
+// by allowed. Percent-encoded octets are accepted wherever allowed accepts '%'
+func checkChars(value string, part string, allowed func(c byte) bool) error {
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+
+		if c == '%' && allowed('%') {
+			if i+2 >= len(value) || !isHex(value[i+1]) || !isHex(value[i+2]) {
+				return fmt.Errorf("%s has an invalid percent-encoding at position %d", part, i)
+			}
+
+			i += 2
+			continue
+		}
+
+		if c == '%' || !allowed(c) {
+			return fmt.Errorf("%s contains invalid character %q", part, c)
+		}
+	}
+
+	return nil
+}
+
+// isMethodChar reports whether c may appear in a did method name
+func isMethodChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9')
+}
+
+// isIdChar reports whether c may appear in a did method-specific id
+func isIdChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte(".-_:%", c) >= 0
+}
+
+// isPathChar reports whether c may appear in the path, query or fragment of a
+// did url
+func isPathChar(c byte) bool {
+	return isUnreserved(c) || strings.IndexByte("!$&'()*+,;=:@/?%", c) >= 0
+}
+
+// validateDid returns an error unless value is a did as defined by the did-core
+// ABNF: "did:" method-name ":" method-specific-id
+func validateDid(field string, value string) error {
+	if !strings.HasPrefix(value, "did:") {
+		return newError(codeInvalidArgument, "%s %s must start with did:", field, value)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, "did:"), ":", 2)
+
+	if parts[0] == "" {
+		return newError(codeInvalidArgument, "%s %s has an empty method name", field, value)
+	}
+
+	if err := checkChars(parts[0], "method name", isMethodChar); err != nil {
+		return newError(codeInvalidArgument, "%s %s is not a valid did. %s", field, value, err.Error())
+	}
+
+	if len(parts) == 1 || parts[1] == "" {
+		return newError(codeInvalidArgument, "%s %s has an empty method-specific id", field, value)
+	}
+
+	if strings.HasSuffix(parts[1], ":") {
+		return newError(codeInvalidArgument, "%s %s must not end with a colon", field, value)
+	}
+
+	if err := checkChars(parts[1], "method-specific id", isIdChar); err != nil {
+		return newError(codeInvalidArgument, "%s %s is not a valid did. %s", field, value, err.Error())
+	}
+
+	return nil
+}
+
+// validateDidUrl returns an error unless value is a did url made of a did
+// followed by an optional path, query and fragment. With requireFragment, value
+// must have a non empty fragment
+func validateDidUrl(field string, value string, requireFragment bool) error {
+	did, fragment, hasFragment := value, "", false
+
+	if i := strings.IndexByte(value, '#'); i >= 0 {
+		did, fragment, hasFragment = value[:i], value[i+1:], true
+	}
+
+	if requireFragment && fragment == "" {
+		return newError(codeInvalidArgument, "%s %s must have a fragment", field, value)
+	}
+
+	if hasFragment {
+		if err := checkChars(fragment, "fragment", isPathChar); err != nil {
+			return newError(codeInvalidArgument, "%s %s is not a valid did url. %s", field, value, err.Error())
+		}
+	}
+
+	rest := ""
+
+	if i := strings.IndexAny(did, "/?"); i >= 0 {
+		did, rest = did[:i], did[i:]
+	}
+
+	if err := checkChars(rest, "path and query", isPathChar); err != nil {
+		return newError(codeInvalidArgument, "%s %s is not a valid did url. %s", field, value, err.Error())
+	}
+
+	return validateDid(field, did)
+}
+
+// validateDidSyntax checks the identifiers of a did document: its id and
+// authentication controller must be dids, its verification and recovery
+// method ids must be did urls with a fragment and the endpoint of a
+// LinkedDomains service must be an origin
+func validateDidSyntax(did *Did) error {
+	if err := validateDid("id", did.Id); err != nil {
+		return err
+	}
+
+	if err := validateDidUrl("authenticationId", did.AuthenticationId, true); err != nil {
+		return err
+	}
+
+	if err := validateDid("authenticationController", did.AuthenticationController); err != nil {
+		return err
+	}
+
+	for _, method := range append(append([]VerificationMethod{}, did.VerificationMethods...), did.RecoveryMethods...) {
+		if err := validateDidUrl("verification method id", method.Id, true); err != nil {
+			return err
+		}
+
+		if err := validateDid("verification method controller", method.Controller); err != nil {
+			return err
+		}
+	}
+
+	return validateLinkedDomains(did)
+}
@@ -0,0 +1,65 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateDid(t *testing.T) {
+	valid := []string{"did:example:123456789abcdefghi", "did:fabric:4Fz9xN", "did:web:example.com%3A8443", "did:example:a:b:c"}
+	invalid := []string{"example:123", "did::123", "did:Example:123", "did:example", "did:example:", "did:example:123:", "did:example:12 3", "did:example:%zz"}
+
+	for _, did := range valid {
+		assert.Nil(t, validateDid("id", did), "should accept %s", did)
+	}
+
+	for _, did := range invalid {
+		assertErrorCode(t, validateDid("id", did), codeInvalidArgument, "should reject "+did)
+	}
+}
+
+func TestValidateDidUrl(t *testing.T) {
+	assert.Nil(t, validateDidUrl("id", "did:example:123#keys-1", true), "should accept did urls with a fragment")
+	assert.Nil(t, validateDidUrl("id", "did:example:123/path?query=1#keys-1", true), "should accept paths and queries")
+	assert.Nil(t, validateDidUrl("id", "did:example:123", false), "should accept bare dids unless a fragment is required")
+
+	assertErrorCode(t, validateDidUrl("id", "did:example:123", true), codeInvalidArgument, "should require a fragment")
+	assertErrorCode(t, validateDidUrl("id", "did:example:123#", true), codeInvalidArgument, "should require a non empty fragment")
+	assertErrorCode(t, validateDidUrl("id", "did:example:123#keys 1", true), codeInvalidArgument, "should reject invalid fragment characters")
+	assertErrorCode(t, validateDidUrl("id", "did:example:123/pa^th#keys-1", true), codeInvalidArgument, "should reject invalid path characters")
+	assertErrorCode(t, validateDidUrl("id", "#keys-1", true), codeInvalidArgument, "should require an absolute did url")
+}
+
+func TestValidateDidSyntax(t *testing.T) {
+	did := Did{Id: "did:example:123", AuthenticationId: "did:example:123#keys-1", AuthenticationController: "did:example:123"}
+	assert.Nil(t, validateDidSyntax(&did), "should accept valid documents")
+
+	did.VerificationMethods = []VerificationMethod{{Id: "did:example:123#keys-2", Controller: "controller"}}
+	assertErrorCode(t, validateDidSyntax(&did), codeInvalidArgument, "should check verification method controllers")
+
+	did.VerificationMethods = []VerificationMethod{{Id: "did:example:123", Controller: "did:example:123"}}
+	assertErrorCode(t, validateDidSyntax(&did), codeInvalidArgument, "should check verification method ids")
+
+	did.VerificationMethods = nil
+	did.AuthenticationController = "did:example:123#keys-1"
+	assertErrorCode(t, validateDidSyntax(&did), codeInvalidArgument, "should require the authentication controller to be a did")
+}
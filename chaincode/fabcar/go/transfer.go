@@ -0,0 +1,220 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// transferObjectType is the composite key object type under which pending
+// control transfers are stored
+const transferObjectType = "transfer"
+
+// TransferProposal describes a pending change of control of a did
+type TransferProposal struct {
+	DidNumber  string           `json:"didNumber"`
+	From       string           `json:"from"`
+	To         string           `json:"to"`
+	ProposedBy *ProvenanceEntry `json:"proposedBy"`
+}
+
+// controllerOf returns the client identity that controls the did
+func controllerOf(did *Did) string {
+	if did.Controller != "" {
+		return did.Controller
+	}
+
+	if did.Provenance != nil && did.Provenance.Created != nil {
+		return did.Provenance.Created.ClientID
+	}
+
+	return ""
+}
+
+// assertController returns an error unless the submitting client identity
+// controls the did stored with the given key
+func assertController(ctx contractapi.TransactionContextInterface, didNumber string, did *Did) error {
+	clientID, err := ctx.GetClientIdentity().GetID()
+
+	if err != nil {
+		return fmt.Errorf("Failed to read client identity. %s", err.Error())
+	}
+
+	controller := controllerOf(did)
+
+	if controller == "" {
+		return newError(codeUnauthorized, "%s has no controlling client identity", didNumber)
+	}
+
+	if controller != clientID {
+		return newError(codeUnauthorized, "Caller does not control %s", didNumber)
+	}
+
+	return nil
+}
+
+// transferKey returns the key of the pending transfer of the given did
+func transferKey(ctx contractapi.TransactionContextInterface, didNumber string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey(transferObjectType, []string{didNumber})
+}
+
+// ProposeTransfer offers control of a did to another client identity. Only the
+// current controller may propose a transfer, and the transfer takes effect once
+// the new controller calls AcceptTransfer
+func (s *DidContract) ProposeTransfer(ctx contractapi.TransactionContextInterface, didNumber string, newController string) error {
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if err := assertController(ctx, didNumber, did); err != nil {
+		return err
+	}
+
+	if newController == "" {
+		return newError(codeInvalidArgument, "New controller must not be empty")
+	}
+
+	proposedBy, err := newProvenanceEntry(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	proposal := TransferProposal{
+		DidNumber:  didNumber,
+		From:       controllerOf(did),
+		To:         newController,
+		ProposedBy: proposedBy,
+	}
+
+	key, err := transferKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	proposalAsBytes, err := marshalRecord(key, proposal)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().PutState(key, proposalAsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return putAuditRecord(ctx, didNumber)
+}
+
+// QueryTransfer returns the pending transfer of the did stored with given key
+func (s *DidContract) QueryTransfer(ctx contractapi.TransactionContextInterface, didNumber string) (*TransferProposal, error) {
+	key, err := transferKey(ctx, didNumber)
+
+	if err != nil {
+		return nil, err
+	}
+
+	proposalAsBytes, err := ctx.GetStub().GetState(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if proposalAsBytes == nil {
+		return nil, newError(codeNotFound, "%s has no pending transfer", didNumber)
+	}
+
+	proposal := new(TransferProposal)
+
+	if err := unmarshalRecord(key, proposalAsBytes, proposal); err != nil {
+		return nil, err
+	}
+
+	return proposal, nil
+}
+
+// AcceptTransfer completes a pending transfer. It must be submitted by the client
+// identity named in the proposal, whose organization becomes the endorser of the
+// did. The did is moved into the namespace of that organization
+func (s *DidContract) AcceptTransfer(ctx contractapi.TransactionContextInterface, didNumber string) error {
+	proposal, err := s.QueryTransfer(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+
+	if err != nil {
+		return fmt.Errorf("Failed to read client identity. %s", err.Error())
+	}
+
+	if clientID != proposal.To {
+		return newError(codeUnauthorized, "Caller is not the proposed controller of %s", didNumber)
+	}
+
+	did, err := s.QueryDidByKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	if controllerOf(did) != proposal.From {
+		return newError(codeConflict, "Control of %s changed after the transfer was proposed", didNumber)
+	}
+
+	did.Controller = clientID
+
+	mspID, err := clientMSPID(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	moved, err := moveDidNamespace(ctx, didNumber, mspID)
+
+	if err != nil {
+		return err
+	}
+
+	if err := putDid(ctx, moved, didNumber, did); err != nil {
+		return err
+	}
+
+	key, err := transferKey(ctx, didNumber)
+
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetStub().DelState(key)
+
+	if err != nil {
+		return fmt.Errorf("Failed to delete from world state. %s", err.Error())
+	}
+
+	return setOwnerEndorsement(ctx, moved)
+}
@@ -0,0 +1,114 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProposeTransfer(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	err := s.ProposeTransfer(l.ctx, id, "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a new controller")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.ProposeTransfer(l.ctx, id, otherClientID)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the controller propose a transfer")
+
+	l.setClient(testClientID, testMSPID)
+	err = s.ProposeTransfer(l.ctx, id, otherClientID)
+	require.NoError(t, err, "should propose the transfer")
+
+	proposal, err := s.QueryTransfer(l.ctx, id)
+	require.NoError(t, err, "should return the pending transfer")
+	assert.Equal(t, testClientID, proposal.From, "should record the current controller")
+	assert.Equal(t, otherClientID, proposal.To, "should record the new controller")
+	assert.Equal(t, "tx2", proposal.ProposedBy.TxID, "should record the proposing transaction")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, testClientID, did.Controller, "should not change the controller until accepted")
+
+	l.stub.PutStateReturns(errors.New("PutState error"))
+	err = s.ProposeTransfer(l.ctx, id, otherClientID)
+	assert.EqualError(t, err, "Failed to put to world state. PutState error", "should return ledger errors")
+}
+
+func TestQueryTransfer(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+
+	_, err := s.QueryTransfer(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should fail for dids without a pending transfer")
+
+	key, err := transferKey(l.ctx, id)
+	require.NoError(t, err)
+
+	l.state[key] = []byte(`{"didNumber":1}`)
+	_, err = s.QueryTransfer(l.ctx, id)
+	assert.IsType(t, &CorruptRecordError{}, err, "should reject corrupt proposals")
+
+	l.stub.CreateCompositeKeyReturns("", errors.New("CreateCompositeKey error"))
+	_, err = s.QueryTransfer(l.ctx, id)
+	assert.EqualError(t, err, "CreateCompositeKey error", "should return key errors")
+}
+
+func TestAcceptTransfer(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	id := createTestDid(t, l, newTestKey(t))
+	require.NoError(t, s.ProposeTransfer(l.ctx, id, otherClientID))
+	l.nextTx()
+
+	err := s.AcceptTransfer(l.ctx, id)
+	assertErrorCode(t, err, codeUnauthorized, "should only let the proposed controller accept")
+
+	l.setClient(otherClientID, otherMSPID)
+	err = s.AcceptTransfer(l.ctx, id)
+	require.NoError(t, err, "should accept the transfer")
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, otherClientID, did.Controller, "should change the controller")
+	assert.Equal(t, "tx3", did.Provenance.Updated.TxID, "should record the accepting transaction")
+	assert.Equal(t, []string{otherMSPID}, l.endorsers(t, id), "should make the new controller's organization the endorser")
+
+	_, err = s.QueryTransfer(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should remove the pending transfer")
+
+	err = s.AcceptTransfer(l.ctx, id)
+	assertErrorCode(t, err, codeNotFound, "should not accept a transfer twice")
+
+	require.NoError(t, s.ProposeTransfer(l.ctx, id, testClientID))
+	did.Controller = "x509::CN=user3"
+	l.state[testNamespacedDidKey(otherMSPID, id)], err = marshalRecord(id, did)
+	require.NoError(t, err)
+
+	l.setClient(testClientID, testMSPID)
+	err = s.AcceptTransfer(l.ctx, id)
+	assertErrorCode(t, err, codeConflict, "should reject transfers if control changed since the proposal")
+}
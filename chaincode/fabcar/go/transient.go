@@ -0,0 +1,115 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// didTransientKey is the transient map key holding the did payload for the
+// transient input functions
+const didTransientKey = "did"
+
+// DidInput describes a did payload passed through the transient map. PrivateKey
+// keeps the authentication public key in the private data collection and a non
+// empty ServiceEndPointSalt keeps the service endpoint in the caller's
+// organization collection
+type DidInput struct {
+	DidNumber string `json:"didNumber"`
+	Did
+	PrivateKey          bool   `json:"privateKey,omitempty" metadata:"privateKey,optional"`
+	ServiceEndPointSalt string `json:"serviceEndPointSalt,omitempty" metadata:"serviceEndPointSalt,optional"`
+	Signature           string `json:"signature,omitempty" metadata:"signature,optional"`
+}
+
+// readDidInput reads the did payload from the transient map of the proposal
+func readDidInput(ctx contractapi.TransactionContextInterface) (*DidInput, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read transient map. %s", err.Error())
+	}
+
+	inputAsBytes, ok := transientMap[didTransientKey]
+
+	if !ok {
+		return nil, newError(codeInvalidArgument, "%s must be a key in the transient map", didTransientKey)
+	}
+
+	input := new(DidInput)
+	err = decodeStrict(inputAsBytes, input)
+
+	if err != nil {
+		return nil, newError(codeInvalidArgument, "Failed to decode transient %s. %s", didTransientKey, err.Error())
+	}
+
+	if input.DidNumber == "" {
+		return nil, newError(codeInvalidArgument, "didNumber must be set in transient %s", didTransientKey)
+	}
+
+	return input, nil
+}
+
+// CreateDidTransient adds a new did using the payload passed in the transient map
+// under the did key, so that none of its details appear in the transaction arguments
+func (s *DidContract) CreateDidTransient(ctx contractapi.TransactionContextInterface) error {
+	input, err := readDidInput(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if err := admitRegistration(ctx, 1); err != nil {
+		return err
+	}
+
+	did := input.Did
+
+	if input.ServiceEndPointSalt != "" {
+		err = putPrivateServiceEndpoint(ctx, input.DidNumber, &did, input.ServiceEndPointSalt)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if input.PrivateKey {
+		return s.createDidPrivate(ctx, input.DidNumber, &did)
+	}
+
+	return s.createDid(ctx, input.DidNumber, &did)
+}
+
+// UpdateDidTransient updates an existing did using the payload passed in the
+// transient map under the did key. The payload must carry the proof of key
+// possession in its signature member
+func (s *DidContract) UpdateDidTransient(ctx contractapi.TransactionContextInterface) error {
+	input, err := readDidInput(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	update := input.Did
+
+	return s.updateDid(ctx, input.DidNumber, &update, input.Signature, input.ServiceEndPointSalt)
+}
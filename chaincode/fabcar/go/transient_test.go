@@ -0,0 +1,120 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTransientDid = "did:example:transient"
+
+// setDidInput passes input in the transient map of the proposal
+func setDidInput(t *testing.T, l *testLedger, input *DidInput) {
+	inputAsBytes, err := json.Marshal(input)
+	require.NoError(t, err)
+
+	l.stub.GetTransientReturns(map[string][]byte{didTransientKey: inputAsBytes}, nil)
+}
+
+// transientInput returns the transient input for the did testTransientDid under DID7
+func transientInput(key *testKey) *DidInput {
+	input := DidInput{DidNumber: "DID7", Did: *testUpdate(testTransientDid, key, testEndpoint)}
+	input.Id = testTransientDid
+
+	return &input
+}
+
+func TestCreateDidTransient(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+
+	setDidInput(t, l, transientInput(key))
+	err := s.CreateDidTransient(l.ctx)
+	require.NoError(t, err, "should create the did")
+
+	did, err := s.QueryDidByKey(l.ctx, "DID7")
+	require.NoError(t, err)
+	assert.Equal(t, testTransientDid, did.Id, "should store the did of the transient payload")
+	assert.Equal(t, key.pem, did.AuthenticationPublicKeyPerm, "should store the public key")
+
+	input := transientInput(key)
+	input.DidNumber = "DID8"
+	input.PrivateKey = true
+	input.ServiceEndPointSalt = "salt"
+	setDidInput(t, l, input)
+
+	err = s.CreateDidTransient(l.ctx)
+	require.NoError(t, err, "should create the did with private details")
+
+	did, err = s.QueryDidByKey(l.ctx, "DID8")
+	require.NoError(t, err)
+	assert.Equal(t, "", did.AuthenticationPublicKeyPerm, "should keep the key private")
+	assert.Equal(t, "", did.ServiceEndPoint, "should keep the service endpoint private")
+	assert.Equal(t, saltedHash(testEndpoint, "salt"), did.ServiceEndPointHash, "should store the salted endpoint hash")
+
+	input.DidNumber = ""
+	setDidInput(t, l, input)
+	err = s.CreateDidTransient(l.ctx)
+	assertErrorCode(t, err, codeInvalidArgument, "should require a did number")
+
+	l.stub.GetTransientReturns(map[string][]byte{didTransientKey: []byte(`{"didNumber":"DID9","unknown":true}`)}, nil)
+	err = s.CreateDidTransient(l.ctx)
+	assertErrorCode(t, err, codeInvalidArgument, "should reject unknown members")
+
+	l.stub.GetTransientReturns(map[string][]byte{}, nil)
+	err = s.CreateDidTransient(l.ctx)
+	assertErrorCode(t, err, codeInvalidArgument, "should require the did transient key")
+
+	l.stub.GetTransientReturns(nil, errors.New("GetTransient error"))
+	err = s.CreateDidTransient(l.ctx)
+	assert.EqualError(t, err, "Failed to read transient map. GetTransient error", "should return transient map errors")
+}
+
+func TestUpdateDidTransient(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+
+	setDidInput(t, l, transientInput(key))
+	require.NoError(t, s.CreateDidTransient(l.ctx))
+	l.nextTx()
+
+	input := transientInput(key)
+	input.ServiceEndPoint = "https://example.org/vc/"
+	setDidInput(t, l, input)
+
+	err := s.UpdateDidTransient(l.ctx)
+	assertErrorCode(t, err, codeInvalidSignature, "should require the signature member")
+
+	input.Signature = signUpdate(t, l, key, "DID7", &input.Did)
+	setDidInput(t, l, input)
+
+	err = s.UpdateDidTransient(l.ctx)
+	require.NoError(t, err, "should update the did")
+
+	did, err := s.QueryDidByKey(l.ctx, "DID7")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/vc/", did.ServiceEndPoint, "should store the new details")
+}
@@ -0,0 +1,180 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxSuggestionDistance is the largest edit distance between a called function
+// name and a transaction function for the latter to be suggested
+const maxSuggestionDistance = 3
+
+// maxSuggestions is the number of closest transaction functions suggested
+const maxSuggestions = 3
+
+// transactionContextType is the type of the context taken by transaction functions
+var transactionContextType = reflect.TypeOf((*contractapi.TransactionContextInterface)(nil)).Elem()
+
+// transactionFunctions returns the sorted names of the transaction functions of
+// a contract, that is its exported methods taking a transaction context
+func transactionFunctions(contract interface{}) []string {
+	contractType := reflect.TypeOf(contract)
+	functions := []string{}
+
+	for i := 0; i < contractType.NumMethod(); i++ {
+		method := contractType.Method(i)
+
+		if method.Type.NumIn() > 1 && method.Type.In(1) == transactionContextType {
+			functions = append(functions, method.Name)
+		}
+	}
+
+	sort.Strings(functions)
+
+	return functions
+}
+
+// editDistance returns the Levenshtein distance between a and b, ignoring case
+func editDistance(a string, b string) int {
+	source := []rune(strings.ToLower(a))
+	target := []rune(strings.ToLower(b))
+
+	previous := make([]int, len(target)+1)
+	current := make([]int, len(target)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(source); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(target); j++ {
+			cost := 1
+
+			if source[i-1] == target[j-1] {
+				cost = 0
+			}
+
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(target)]
+}
+
+// minInt returns the smaller of a and b
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// closestFunctions returns up to maxSuggestions of functions closest to name,
+// closest first
+func closestFunctions(name string, functions []string) []string {
+	distances := make(map[string]int)
+	suggestions := []string{}
+
+	for _, function := range functions {
+		distance := editDistance(name, function)
+
+		if distance <= maxSuggestionDistance {
+			distances[function] = distance
+			suggestions = append(suggestions, function)
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return distances[suggestions[i]] < distances[suggestions[j]]
+	})
+
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+
+	return suggestions
+}
+
+// unknownTransaction returns the error for a call of a function contract does
+// not have. It lists the transaction functions of the contract and suggests
+// those closest to the called name
+func unknownTransaction(ctx contractapi.TransactionContextInterface, contract contractapi.ContractInterface) error {
+	called, _ := ctx.GetStub().GetFunctionAndParameters()
+	name := called[strings.LastIndex(called, ":")+1:]
+
+	functions := transactionFunctions(contract)
+	suggestions := closestFunctions(name, functions)
+
+	err := newError(codeUnknownFunction, "Function %s not found in contract %s", name, contract.GetName())
+
+	if len(suggestions) > 0 {
+		err.Message += ". Did you mean " + strings.Join(suggestions, " or ") + "?"
+	}
+
+	err.Details = map[string]string{
+		"function":    name,
+		"available":   strings.Join(functions, ","),
+		"suggestions": strings.Join(suggestions, ","),
+	}
+
+	return err
+}
+
+// GetUnknownTransaction returns the handler called for functions the did
+// contract does not have
+func (s *DidContract) GetUnknownTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return unknownTransaction(ctx, s)
+	}
+}
+
+// GetUnknownTransaction returns the handler called for functions the credential
+// contract does not have
+func (c *CredentialContract) GetUnknownTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return unknownTransaction(ctx, c)
+	}
+}
+
+// GetUnknownTransaction returns the handler called for functions the admin
+// contract does not have
+func (a *AdminContract) GetUnknownTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return unknownTransaction(ctx, a)
+	}
+}
+
+// GetUnknownTransaction returns the handler called for functions the policy
+// contract does not have
+func (p *PolicyContract) GetUnknownTransaction() interface{} {
+	return func(ctx contractapi.TransactionContextInterface) error {
+		return unknownTransaction(ctx, p)
+	}
+}
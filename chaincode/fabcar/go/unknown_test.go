@@ -0,0 +1,84 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditDistance(t *testing.T) {
+	assert.Equal(t, 0, editDistance("QueryDidById", "querydidbyid"), "should ignore case")
+	assert.Equal(t, 1, editDistance("QueryDidByKy", "QueryDidByKey"), "should count insertions")
+	assert.Equal(t, 1, editDistance("QueryDidByKeys", "QueryDidByKey"), "should count deletions")
+	assert.Equal(t, 1, editDistance("QueryDidByKoy", "QueryDidByKey"), "should count substitutions")
+	assert.Equal(t, 3, editDistance("", "abc"), "should handle empty names")
+}
+
+func TestTransactionFunctions(t *testing.T) {
+	functions := transactionFunctions(new(DidContract))
+
+	assert.Contains(t, functions, "CreateDid", "should list transaction functions")
+	assert.NotContains(t, functions, "GetName", "should not list functions of the contract API")
+	assert.NotContains(t, functions, "GetUnknownTransaction", "should not list the handlers")
+	assert.True(t, sort.StringsAreSorted(functions), "should sort the functions")
+}
+
+func TestClosestFunctions(t *testing.T) {
+	functions := []string{"QueryDidById", "QueryDidByKey", "QueryAllDids", "CreateDid"}
+
+	assert.Equal(t, []string{"QueryDidByKey", "QueryDidById"}, closestFunctions("QueryDidByKy", functions), "should suggest the closest first")
+	assert.Equal(t, []string{"CreateDid"}, closestFunctions("createdid", functions), "should match regardless of case")
+	assert.Empty(t, closestFunctions("Transfer", functions), "should not suggest distant functions")
+}
+
+func TestUnknownTransaction(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+
+	l.stub.GetFunctionAndParametersReturns("did:QueryDidByKy", []string{})
+
+	handler := s.GetUnknownTransaction().(func(ctx contractapi.TransactionContextInterface) error)
+	err := handler(l.ctx)
+	assertErrorCode(t, err, codeUnknownFunction, "should code unknown functions")
+
+	contractErr := err.(*ContractError)
+	assert.Equal(t, "Function QueryDidByKy not found in contract did. Did you mean QueryDidByKey or QueryDidById?", contractErr.Message, "should suggest the closest functions")
+	assert.Equal(t, "QueryDidByKy", contractErr.Details["function"], "should name the called function")
+	assert.Equal(t, "QueryDidByKey,QueryDidById", contractErr.Details["suggestions"], "should list the suggestions")
+	assert.Contains(t, contractErr.Details["available"], "ProposeTransfer", "should list the available functions")
+
+	stub := newCodedStub(t)
+	captureAuditLog()
+	defer restoreAuditLog()
+
+	response := stub.MockInvoke("tx1", [][]byte{[]byte("credential:IssueCredentail")})
+	require.Equal(t, int32(shim.ERROR), response.Status, "should fail for unknown functions")
+
+	contractErr = responseError(t, response.Message)
+	assert.Equal(t, codeUnknownFunction, contractErr.Code, "should return the error of the handler")
+	assert.Equal(t, "Function IssueCredentail not found in contract credential. Did you mean IssueCredential?", contractErr.Message, "should handle every contract")
+	assert.Equal(t, "IssueCredential", contractErr.Details["suggestions"], "should suggest functions of the called contract")
+}
@@ -0,0 +1,331 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Proof describes a detached JWS proof attached to a credential or presentation.
+// The JWS signs the canonical JSON of the document without its proof member
+type Proof struct {
+	Type               string `json:"type"`
+	Created            string `json:"created,omitempty" metadata:"created,optional"`
+	VerificationMethod string `json:"verificationMethod"`
+	ProofPurpose       string `json:"proofPurpose"`
+	Challenge          string `json:"challenge,omitempty" metadata:"challenge,optional"`
+	Domain             string `json:"domain,omitempty" metadata:"domain,optional"`
+	Jws                string `json:"jws"`
+}
+
+// CredentialStatus describes a StatusList2021Entry of a credential
+type CredentialStatus struct {
+	Id                   string `json:"id"`
+	Type                 string `json:"type"`
+	StatusPurpose        string `json:"statusPurpose"`
+	StatusListIndex      string `json:"statusListIndex"`
+	StatusListCredential string `json:"statusListCredential"`
+}
+
+// VerifiableCredential describes the members of a verifiable credential used
+// during verification
+type VerifiableCredential struct {
+	Id                string            `json:"id"`
+	Type              []string          `json:"type"`
+	Issuer            json.RawMessage   `json:"issuer"`
+	IssuanceDate      string            `json:"issuanceDate"`
+	ExpirationDate    string            `json:"expirationDate,omitempty" metadata:"expirationDate,optional"`
+	CredentialSubject json.RawMessage   `json:"credentialSubject"`
+	CredentialStatus  *CredentialStatus `json:"credentialStatus,omitempty" metadata:"credentialStatus,optional"`
+	Proof             *Proof            `json:"proof"`
+}
+
+// VerificationCheck describes the outcome of a single verification step
+type VerificationCheck struct {
+	Check   string `json:"check"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message,omitempty" metadata:"message,optional"`
+}
+
+// VerificationResult describes the outcome of verifying a credential
+type VerificationResult struct {
+	Verified bool                `json:"verified"`
+	Checks   []VerificationCheck `json:"checks"`
+}
+
+// addCheck records the outcome of a verification step, marking the result as
+// not verified when the step failed
+func (r *VerificationResult) addCheck(check string, err error) bool {
+	result := VerificationCheck{Check: check, Passed: err == nil}
+
+	if err != nil {
+		result.Message = err.Error()
+		r.Verified = false
+	}
+
+	r.Checks = append(r.Checks, result)
+
+	return err == nil
+}
+
+// issuerId returns the id of a credential issuer given either as a string or
+// as an object with an id member
+func (vc *VerifiableCredential) issuerId() (string, error) {
+	var id string
+
+	if err := json.Unmarshal(vc.Issuer, &id); err == nil {
+		return id, nil
+	}
+
+	issuer := struct {
+		Id string `json:"id"`
+	}{}
+
+	if err := json.Unmarshal(vc.Issuer, &issuer); err != nil || issuer.Id == "" {
+		return "", fmt.Errorf("Credential issuer must be a did or an object with an id")
+	}
+
+	return issuer.Id, nil
+}
+
+// signingPayload returns the canonical JSON of a document without its proof
+// member. Object members are ordered by key by encoding/json
+func signingPayload(documentJSON string) ([]byte, error) {
+	decoder := json.NewDecoder(strings.NewReader(documentJSON))
+	decoder.UseNumber()
+
+	document := map[string]interface{}{}
+
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+
+	delete(document, "proof")
+
+	return json.Marshal(document)
+}
+
+// verifyJws verifies a detached JWS over payload, signed by the verification
+// method of the proof registered in the did
+func verifyJws(did *Did, proof *Proof, payload []byte) error {
+	publicKey, err := publicKeyOf(did, proof.VerificationMethod)
+
+	if err != nil {
+		return err
+	}
+
+	parts := strings.Split(proof.Jws, ".")
+
+	if len(parts) != 3 || parts[1] != "" {
+		return fmt.Errorf("Proof jws must be a detached JWS")
+	}
+
+	headerAsBytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+
+	if err != nil {
+		return fmt.Errorf("Failed to decode JWS header. %s", err.Error())
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+	}{}
+
+	if err := json.Unmarshal(headerAsBytes, &header); err != nil {
+		return fmt.Errorf("Failed to decode JWS header. %s", err.Error())
+	}
+
+	if err := checkJwsAlgorithm(header.Alg, publicKey); err != nil {
+		return err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return fmt.Errorf("Failed to decode JWS signature. %s", err.Error())
+	}
+
+	signingInput := append([]byte(parts[0]+"."), payload...)
+
+	return verifySignature(publicKey, signingInput, signature)
+}
+
+// checkJwsAlgorithm returns an error unless alg is the JWS algorithm used with
+// the type of publicKey
+func checkJwsAlgorithm(alg string, publicKey interface{}) error {
+	expected := ""
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		expected = "RS256"
+	case *ecdsa.PublicKey:
+		expected = "ES256"
+	case *secp256k1.PublicKey:
+		expected = "ES256K"
+	case ed25519.PublicKey:
+		expected = "EdDSA"
+	}
+
+	if alg != expected {
+		return fmt.Errorf("JWS algorithm %s does not match the verification method key", alg)
+	}
+
+	return nil
+}
+
+// checkCredentialStatus returns an error if the credential has been revoked in
+// the status list of its issuer
+func checkCredentialStatus(ctx contractapi.TransactionContextInterface, issuerDid string, status *CredentialStatus) error {
+	if status.Type != "StatusList2021Entry" {
+		return fmt.Errorf("Unsupported credential status type %s", status.Type)
+	}
+
+	prefix := issuerDid + "/status/"
+
+	if !strings.HasPrefix(status.StatusListCredential, prefix) {
+		return fmt.Errorf("Status list %s is not a status list of %s", status.StatusListCredential, issuerDid)
+	}
+
+	listId := strings.TrimPrefix(status.StatusListCredential, prefix)
+
+	index, err := strconv.Atoi(status.StatusListIndex)
+
+	if err != nil {
+		return fmt.Errorf("Status list index %s is not a number", status.StatusListIndex)
+	}
+
+	list, err := getStatusList(ctx, issuerDid, listId)
+
+	if err != nil {
+		return err
+	}
+
+	if index < 0 || index >= list.Length {
+		return fmt.Errorf("Index %d is outside status list %s of length %d", index, listId, list.Length)
+	}
+
+	if list.isSet(index) {
+		return fmt.Errorf("Credential has been revoked")
+	}
+
+	return nil
+}
+
+// verifyCredential verifies a credential against the registry and adds the outcome
+// of each step to result
+func verifyCredential(ctx contractapi.TransactionContextInterface, vcJSON string, result *VerificationResult) {
+	vc := new(VerifiableCredential)
+
+	if !result.addCheck("format", json.Unmarshal([]byte(vcJSON), vc)) {
+		return
+	}
+
+	issuerDid, err := vc.issuerId()
+
+	if !result.addCheck("issuer", err) {
+		return
+	}
+
+	issuer, err := findDidById(ctx, issuerDid)
+
+	if err == nil && issuer.Record.Deactivated {
+		err = newError(codeDidDeactivated, "%s is deactivated", issuerDid)
+	}
+
+	if !result.addCheck("issuer", err) {
+		return
+	}
+
+	if vc.Proof == nil {
+		result.addCheck("proof", fmt.Errorf("Credential has no proof"))
+		return
+	}
+
+	if !strings.HasPrefix(vc.Proof.VerificationMethod, issuerDid+"#") {
+		result.addCheck("verificationMethod", fmt.Errorf("Verification method %s does not belong to issuer %s", vc.Proof.VerificationMethod, issuerDid))
+		return
+	}
+
+	_, err = publicKeyOf(issuer.Record, vc.Proof.VerificationMethod)
+
+	if !result.addCheck("verificationMethod", err) {
+		return
+	}
+
+	payload, err := signingPayload(vcJSON)
+
+	if err == nil {
+		err = verifyJws(issuer.Record, vc.Proof, payload)
+	}
+
+	result.addCheck("proof", err)
+
+	if vc.ExpirationDate != "" {
+		expirationDate, err := time.Parse(time.RFC3339, vc.ExpirationDate)
+
+		if err == nil {
+			err = checkNotExpired(ctx, expirationDate)
+		}
+
+		result.addCheck("expiration", err)
+	}
+
+	if vc.CredentialStatus != nil {
+		result.addCheck("status", checkCredentialStatus(ctx, issuerDid, vc.CredentialStatus))
+	}
+}
+
+// checkNotExpired returns an error if the transaction timestamp is after expirationDate
+func checkNotExpired(ctx contractapi.TransactionContextInterface, expirationDate time.Time) error {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if now.After(expirationDate) {
+		return fmt.Errorf("Expired at %s", expirationDate.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// VerifyCredential verifies a verifiable credential against the registry. The
+// issuer did is resolved from the world state and must not be deactivated, the
+// proof must be made with a key currently registered for the issuer, and the
+// credential must not be revoked in the issuer's status list. The outcome of
+// every step is returned
+func (c *CredentialContract) VerifyCredential(ctx contractapi.TransactionContextInterface, vcJSON string) (*VerificationResult, error) {
+	result := VerificationResult{Verified: true, Checks: []VerificationCheck{}}
+
+	verifyCredential(ctx, vcJSON, &result)
+
+	return &result, nil
+}
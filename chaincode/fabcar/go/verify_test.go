@@ -0,0 +1,197 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signDocument returns the JSON of document with proof attached, its jws set to
+// a detached ES256 JWS made with key over the signing payload of document
+func signDocument(t *testing.T, key *testKey, document map[string]interface{}, proof Proof) string {
+	documentAsBytes, err := json.Marshal(document)
+	require.NoError(t, err)
+
+	payload, err := signingPayload(string(documentAsBytes))
+	require.NoError(t, err)
+
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256"}`))
+	digest := sha256.Sum256(append([]byte(header+"."), payload...))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key.private, digest[:])
+	require.NoError(t, err)
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	proof.Jws = header + ".." + base64.RawURLEncoding.EncodeToString(signature)
+	document["proof"] = proof
+
+	signedAsBytes, err := json.Marshal(document)
+	require.NoError(t, err)
+
+	return string(signedAsBytes)
+}
+
+// testCredential returns an unsigned credential of issuer about subject
+func testCredential(issuer string, subject string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                "urn:uuid:1",
+		"type":              []string{"VerifiableCredential", testCredentialType},
+		"issuer":            issuer,
+		"issuanceDate":      testIssuanceDate,
+		"credentialSubject": map[string]interface{}{"id": subject, "degree": "BSc"},
+	}
+}
+
+// assertionProof returns an assertion proof made with the first key of did
+func assertionProof(did string) Proof {
+	return Proof{Type: "JsonWebSignature2020", VerificationMethod: did + "#keys-1", ProofPurpose: "assertionMethod"}
+}
+
+// checkOf returns the last check of result with given name
+func checkOf(t *testing.T, result *VerificationResult, name string) VerificationCheck {
+	for i := len(result.Checks) - 1; i >= 0; i-- {
+		if result.Checks[i].Check == name {
+			return result.Checks[i]
+		}
+	}
+
+	require.FailNow(t, "missing check", "should run the %s check", name)
+
+	return VerificationCheck{}
+}
+
+func TestVerifyCredential(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	key := newTestKey(t)
+	issuer := createTestDid(t, l, key)
+	subject := createTestDid(t, l, newTestKey(t))
+
+	vc := signDocument(t, key, testCredential(issuer, subject), assertionProof(issuer))
+
+	result, err := c.VerifyCredential(l.ctx, vc)
+	require.NoError(t, err, "should not fail the transaction")
+	assert.True(t, result.Verified, "should verify a credential signed by the issuer: %v", result.Checks)
+
+	tampered := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal([]byte(vc), &tampered))
+	tampered["credentialSubject"] = map[string]interface{}{"id": subject, "degree": "PhD"}
+	tamperedAsBytes, err := json.Marshal(tampered)
+	require.NoError(t, err)
+
+	result, err = c.VerifyCredential(l.ctx, string(tamperedAsBytes))
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should not verify changed credentials")
+	assert.False(t, checkOf(t, result, "proof").Passed, "should fail the proof check")
+
+	result, err = c.VerifyCredential(l.ctx, signDocument(t, newTestKey(t), testCredential(issuer, subject), assertionProof(issuer)))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "proof").Passed, "should not verify signatures of other keys")
+
+	result, err = c.VerifyCredential(l.ctx, signDocument(t, key, testCredential(issuer, subject), assertionProof(subject)))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "verificationMethod").Passed, "should require a verification method of the issuer")
+
+	result, err = c.VerifyCredential(l.ctx, signDocument(t, key, testCredential("did:example:unknown", subject), assertionProof("did:example:unknown")))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "issuer").Passed, "should require a registered issuer")
+
+	expiring := testCredential(issuer, subject)
+	expiring["expirationDate"] = testStart.Add(-time.Hour).Format(time.RFC3339)
+
+	result, err = c.VerifyCredential(l.ctx, signDocument(t, key, expiring, assertionProof(issuer)))
+	require.NoError(t, err)
+	assert.True(t, checkOf(t, result, "proof").Passed, "should verify the proof of expired credentials")
+	assert.False(t, checkOf(t, result, "expiration").Passed, "should reject expired credentials")
+
+	result, err = c.VerifyCredential(l.ctx, `{"id":`)
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "format").Passed, "should reject invalid JSON")
+}
+
+func TestVerifyCredentialDeactivatedIssuer(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	c := new(CredentialContract)
+	key := newTestKey(t)
+	issuer := createTestDid(t, l, key)
+	subject := createTestDid(t, l, newTestKey(t))
+	vc := signDocument(t, key, testCredential(issuer, subject), assertionProof(issuer))
+
+	did, err := s.QueryDidByKey(l.ctx, issuer)
+	require.NoError(t, err)
+
+	update := updatableDetails(did)
+	update.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, issuer, signUpdate(t, l, key, issuer, &update)))
+	l.nextTx()
+
+	result, err := c.VerifyCredential(l.ctx, vc)
+	require.NoError(t, err)
+	assert.False(t, result.Verified, "should not verify credentials of deactivated issuers")
+	assert.False(t, checkOf(t, result, "issuer").Passed, "should fail the issuer check")
+}
+
+func TestVerifyCredentialStatus(t *testing.T) {
+	l := newTestLedger(t)
+	c := new(CredentialContract)
+	key := newTestKey(t)
+	issuer := createTestDid(t, l, key)
+	subject := createTestDid(t, l, newTestKey(t))
+	require.NoError(t, c.CreateStatusList(l.ctx, issuer, "1", 0))
+
+	withStatus := func(index string) string {
+		vc := testCredential(issuer, subject)
+		vc["credentialStatus"] = CredentialStatus{
+			Id:                   issuer + "/status/1#" + index,
+			Type:                 "StatusList2021Entry",
+			StatusPurpose:        "revocation",
+			StatusListIndex:      index,
+			StatusListCredential: issuer + "/status/1",
+		}
+
+		return signDocument(t, key, vc, assertionProof(issuer))
+	}
+
+	result, err := c.VerifyCredential(l.ctx, withStatus("7"))
+	require.NoError(t, err)
+	assert.True(t, result.Verified, "should verify credentials that are not revoked: %v", result.Checks)
+
+	require.NoError(t, c.RevokeCredential(l.ctx, issuer, "1", 7))
+
+	result, err = c.VerifyCredential(l.ctx, withStatus("7"))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "status").Passed, "should reject revoked credentials")
+
+	result, err = c.VerifyCredential(l.ctx, withStatus("seven"))
+	require.NoError(t, err)
+	assert.False(t, checkOf(t, result, "status").Passed, "should reject non numeric indexes")
+}
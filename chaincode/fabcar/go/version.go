@@ -0,0 +1,151 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// parseVersionTime checks that exactly one of versionId and versionTime selects
+// a version of a did, and returns the parsed versionTime if it is the one given
+func parseVersionTime(versionId string, versionTime string) (time.Time, error) {
+	if (versionId == "") == (versionTime == "") {
+		return time.Time{}, newError(codeInvalidArgument, "Exactly one of versionId and versionTime is required")
+	}
+
+	if versionTime == "" {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, versionTime)
+
+	if err != nil {
+		return time.Time{}, newError(codeInvalidArgument, "versionTime %s must be an RFC 3339 timestamp", versionTime)
+	}
+
+	return parsed, nil
+}
+
+// selectVersion returns the index of the version of modifications, oldest first,
+// that versionId names or that was current at versionTime, or -1 if there is none
+func selectVersion(modifications []*queryresult.KeyModification, versionId string, versionTime time.Time) int {
+	selected := -1
+
+	for i, modification := range modifications {
+		if versionId != "" {
+			if modification.TxId == versionId {
+				return i
+			}
+
+			continue
+		}
+
+		if modificationTime(modification).After(versionTime) {
+			break
+		}
+
+		selected = i
+	}
+
+	return selected
+}
+
+// ResolveVersion returns the did document with given id as it was stored in a
+// past version, together with its document and resolution metadata. The version
+// is selected by versionId, the id of the transaction that wrote it as returned
+// in the versionId document metadata, or by versionTime, an RFC 3339 timestamp
+// selecting the version current at that time. Exactly one of them must be given.
+// The version is read from the ledger history of the did and resolves as
+// Resolve resolved it then, unknown versions and versions of deleted dids
+// resolve to notFound. The metadata of versions that were replaced names the
+// time and versionId of the next version. Versions of Sidetree dids cannot be
+// resolved
+func (s *DidContract) ResolveVersion(ctx contractapi.TransactionContextInterface, did string, versionId string, versionTime string) (*DidResolutionResult, error) {
+	at, err := parseVersionTime(versionId, versionTime)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(did, "did:") {
+		return failedResolution(resolutionInvalidDid), nil
+	}
+
+	sidetree, err := lookupSidetreeDid(ctx, did)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if sidetree != nil {
+		return nil, newError(codeInvalidArgument, "Versions of the Sidetree did %s cannot be resolved", did)
+	}
+
+	result, err := lookupDidById(ctx, did)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if result == nil {
+		return failedResolution(resolutionNotFound), nil
+	}
+
+	modifications, err := didHistory(ctx, result.Key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	selected := selectVersion(modifications, versionId, at)
+
+	if selected < 0 || modifications[selected].IsDelete {
+		return failedResolution(resolutionNotFound), nil
+	}
+
+	version := new(Did)
+
+	if err := unmarshalRecord(result.Key, modifications[selected].Value, version); err != nil {
+		return nil, err
+	}
+
+	if version.Deleted {
+		return failedResolution(resolutionNotFound), nil
+	}
+
+	resolution, err := resolution(ctx, version)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resolution.DidDocumentMetadata.VersionId = modifications[selected].TxId
+
+	if next := selected + 1; next < len(modifications) {
+		resolution.DidDocumentMetadata.NextVersionId = modifications[next].TxId
+		resolution.DidDocumentMetadata.NextUpdate = modificationTime(modifications[next]).UTC().Format(time.RFC3339Nano)
+	}
+
+	return resolution, nil
+}
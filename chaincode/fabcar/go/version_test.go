@@ -0,0 +1,122 @@
+/*
+ * Licensed to the Apache Software Foundation (ASF) under one
+ * or more contributor license agreements.  See the NOTICE file
+ * distributed with this work for additional information
+ * regarding copyright ownership.  The ASF licenses this file
+ * to you under the Apache License, Version 2.0 (the
+ * "License"); you may not use this file except in compliance
+ * with the License.  You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing,
+ * software distributed under the License is distributed on an
+ * "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+ * KIND, either express or implied.  See the License for the
+ * specific language governing permissions and limitations
+ * under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveVersion(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+	l.nextTx()
+
+	did, err := s.QueryDidByKey(l.ctx, id)
+	require.NoError(t, err)
+
+	deactivation := updatableDetails(did)
+	deactivation.Deactivated = true
+	require.NoError(t, s.DeactivateDid(l.ctx, id, signUpdate(t, l, key, id, &deactivation)))
+	l.nextTx()
+
+	resolution, err := s.ResolveVersion(l.ctx, id, "tx1", "")
+	require.NoError(t, err, "should resolve versions by id")
+	assert.Equal(t, testEndpoint, resolution.DidDocument.Service[0].ServiceEndpoint, "should return the document of the version")
+	assert.Equal(t, &DidDocumentMetadata{Created: testStart.Add(time.Second).Format(time.RFC3339Nano), VersionId: "tx1",
+		NextVersionId: "tx2", NextUpdate: testStart.Add(2 * time.Second).Format(time.RFC3339Nano)}, resolution.DidDocumentMetadata, "should name the next version")
+
+	resolution, err = s.ResolveVersion(l.ctx, id, "", testStart.Add(1500*time.Millisecond).Format(time.RFC3339Nano))
+	require.NoError(t, err, "should resolve versions by time")
+	assert.Equal(t, "tx1", resolution.DidDocumentMetadata.VersionId, "should return the version current at the time")
+
+	resolution, err = s.ResolveVersion(l.ctx, id, "", testStart.Add(2*time.Second).Format(time.RFC3339))
+	require.NoError(t, err)
+	assert.Equal(t, "tx2", resolution.DidDocumentMetadata.VersionId, "should include versions written at the time")
+	assert.Equal(t, "https://example.org/vc/", resolution.DidDocument.Service[0].ServiceEndpoint)
+	assert.Equal(t, "tx3", resolution.DidDocumentMetadata.NextVersionId)
+
+	resolution, err = s.ResolveVersion(l.ctx, id, "", testStart.Add(time.Hour).Format(time.RFC3339))
+	require.NoError(t, err)
+	assert.Equal(t, resolutionDeactivated, resolution.DidResolutionMetadata.Error, "should resolve deactivated versions as deactivated")
+	assert.Equal(t, "tx3", resolution.DidDocumentMetadata.VersionId)
+	assert.Empty(t, resolution.DidDocumentMetadata.NextVersionId, "should not name a next version of the current version")
+
+	resolution, err = s.ResolveVersion(l.ctx, id, "", testStart.Format(time.RFC3339))
+	require.NoError(t, err)
+	assert.Equal(t, resolutionNotFound, resolution.DidResolutionMetadata.Error, "should not find versions before the creation")
+
+	resolution, err = s.ResolveVersion(l.ctx, id, "tx9", "")
+	require.NoError(t, err)
+	assert.Equal(t, resolutionNotFound, resolution.DidResolutionMetadata.Error, "should not find unknown versions")
+
+	resolution, err = s.ResolveVersion(l.ctx, "did:example:unknown", "tx1", "")
+	require.NoError(t, err)
+	assert.Equal(t, resolutionNotFound, resolution.DidResolutionMetadata.Error, "should not find unknown dids")
+
+	resolution, err = s.ResolveVersion(l.ctx, "example:1234", "tx1", "")
+	require.NoError(t, err)
+	assert.Equal(t, resolutionInvalidDid, resolution.DidResolutionMetadata.Error, "should report invalid dids")
+
+	_, err = s.ResolveVersion(l.ctx, id, "", "")
+	assertErrorCode(t, err, codeInvalidArgument, "should require a version")
+
+	_, err = s.ResolveVersion(l.ctx, id, "tx1", testStart.Format(time.RFC3339))
+	assertErrorCode(t, err, codeInvalidArgument, "should not accept both a versionId and a versionTime")
+
+	_, err = s.ResolveVersion(l.ctx, id, "", "2020-06-01")
+	assertErrorCode(t, err, codeInvalidArgument, "should require an RFC 3339 versionTime")
+}
+
+func TestDereferenceVersion(t *testing.T) {
+	l := newTestLedger(t)
+	s := new(DidContract)
+	key := newTestKey(t)
+	id := createTestDid(t, l, key)
+
+	update := testUpdate(id, key, "https://example.org/vc/")
+	require.NoError(t, updateDid(l, id, update, signUpdate(t, l, key, id, update)))
+	l.nextTx()
+
+	result, err := s.Dereference(l.ctx, id+"?versionId=tx1")
+	require.NoError(t, err, "should dereference past versions")
+	assert.Equal(t, "tx1", result.ContentMetadata.VersionId, "should return the metadata of the version")
+
+	document := new(DidDocument)
+	require.NoError(t, json.Unmarshal([]byte(result.ContentStream), document))
+	assert.Equal(t, testEndpoint, document.Service[0].ServiceEndpoint, "should return the document of the version")
+
+	result, err = s.Dereference(l.ctx, id+"?versionTime="+testStart.Add(time.Hour).Format(time.RFC3339)+"&service=vcs")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/vc/", result.ContentStream, "should select services of the version")
+
+	result, err = s.Dereference(l.ctx, id+"?versionId=tx1&versionTime=2020-06-01T12:00:00Z")
+	require.NoError(t, err)
+	assert.Equal(t, dereferencingInvalidDidUrl, result.DereferencingMetadata.Error, "should reject urls selecting two versions")
+}
@@ -0,0 +1,198 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+	"github.com/hyperledger/fabric-samples/fabcar/go/index"
+)
+
+// Tiers a resolved document was read from
+const (
+	sourceMemory = "memory"
+	sourceStore  = "store"
+	sourceLedger = "ledger"
+)
+
+// didHeader holds the members of a did document the cache keeps outside of it
+type didHeader struct {
+	Id          string `json:"id"`
+	Deactivated bool   `json:"deactivated"`
+	Deleted     bool   `json:"deleted"`
+}
+
+// didHash is the result of the QueryDidHash transaction
+type didHash struct {
+	DidNumber string `json:"didNumber"`
+	Hash      string `json:"hash"`
+}
+
+// evaluator evaluates the query transactions of the did contract
+type evaluator interface {
+	EvaluateTransaction(name string, args ...string) ([]byte, error)
+}
+
+// cache resolves dids from memory, then from the persistent store once its
+// document is checked against the hash on the ledger, then from the ledger
+type cache struct {
+	memory   *lru
+	store    *store
+	contract evaluator
+}
+
+// applyBlock writes the documents carried by the did events of a block to the
+// store and refreshes those held in memory. It returns the number of documents
+func (c *cache) applyBlock(block *common.Block, chaincodeName string) (int, error) {
+	events, err := index.BlockEvents(block, chaincodeName)
+
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+
+	for _, event := range events {
+		if !index.IsDidEvent(event.EventName) {
+			continue
+		}
+
+		dids, err := index.EventDids(event)
+
+		if err != nil {
+			return applied, fmt.Errorf("invalid %s event in transaction %s: %w", event.EventName, event.TransactionID, err)
+		}
+
+		for _, did := range dids {
+			if err := c.apply(did, event.BlockNumber); err != nil {
+				return applied, err
+			}
+
+			applied++
+		}
+	}
+
+	return applied, nil
+}
+
+// apply caches the document of a did event, whose hash is that of its
+// canonical JSON as carried by the event. Deleted dids are evicted
+func (c *cache) apply(did index.DidEvent, blockNumber uint64) error {
+	header := new(didHeader)
+
+	if err := json.Unmarshal(did.Did, header); err != nil {
+		return fmt.Errorf("invalid document of %s: %w", did.DidNumber, err)
+	}
+
+	if header.Deleted {
+		c.memory.remove(header.Id)
+		return c.store.remove(header.Id)
+	}
+
+	hash := sha256.Sum256(did.Did)
+	e := &entry{Id: header.Id, DidNumber: did.DidNumber, Document: did.Did, Hash: hex.EncodeToString(hash[:]), Deactivated: header.Deactivated}
+
+	if err := c.store.put(e, blockNumber); err != nil {
+		return err
+	}
+
+	c.memory.update(e)
+
+	return nil
+}
+
+// ledgerHash returns the hash of the document of the did on the ledger, or nil
+// if the did does not exist
+func (c *cache) ledgerHash(id string) (*didHash, error) {
+	result, err := c.contract.EvaluateTransaction("QueryDidHash", id)
+
+	if chaincodeErr := connection.ChaincodeErrorOf(err); chaincodeErr != nil && chaincodeErr.Code == connection.CodeDidNotFound {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hash of %s: %w", id, err)
+	}
+
+	hash := new(didHash)
+
+	if err := json.Unmarshal(result, hash); err != nil {
+		return nil, fmt.Errorf("failed to parse hash of %s: %w", id, err)
+	}
+
+	return hash, nil
+}
+
+// resolve returns the entry of the did with given id and the tier it was read
+// from, or nil if the did does not exist. On a memory miss the stored document
+// is used only if its hash matches the ledger, otherwise the document is read
+// from the ledger and replaces it
+func (c *cache) resolve(id string) (*entry, string, error) {
+	if e := c.memory.get(id); e != nil {
+		return e, sourceMemory, nil
+	}
+
+	stored, err := c.store.get(id)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	hash, err := c.ledgerHash(id)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	if hash == nil {
+		if stored != nil {
+			return nil, sourceLedger, c.store.remove(id)
+		}
+
+		return nil, sourceLedger, nil
+	}
+
+	if stored != nil && stored.Hash == hash.Hash {
+		c.memory.put(stored)
+
+		return stored, sourceStore, nil
+	}
+
+	if stored != nil {
+		log.Printf("Stored document of %s does not match the ledger, reading it again", id)
+	}
+
+	document, err := c.contract.EvaluateTransaction("QueryDidById", id)
+
+	if chaincodeErr := connection.ChaincodeErrorOf(err); chaincodeErr != nil && chaincodeErr.Code == connection.CodeDidNotFound {
+		return nil, sourceLedger, nil
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", id, err)
+	}
+
+	header := new(didHeader)
+
+	if err := json.Unmarshal(document, header); err != nil {
+		return nil, "", fmt.Errorf("invalid document of %s: %w", id, err)
+	}
+
+	e := &entry{Id: id, DidNumber: hash.DidNumber, Document: document, Hash: hash.Hash, Deactivated: header.Deactivated}
+
+	if err := c.store.put(e, 0); err != nil {
+		return nil, "", err
+	}
+
+	c.memory.put(e)
+
+	return e, sourceLedger, nil
+}
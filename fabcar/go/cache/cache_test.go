@@ -0,0 +1,181 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"github.com/hyperledger/fabric-samples/fabcar/go/index"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const testDid = "did:fabcar:1"
+
+// testLedger is a did contract holding the documents by did, which records the
+// transactions it evaluates
+type testLedger struct {
+	documents map[string]string
+	err       error
+	calls     []string
+}
+
+func (l *testLedger) EvaluateTransaction(name string, args ...string) ([]byte, error) {
+	l.calls = append(l.calls, name)
+
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	document, ok := l.documents[args[0]]
+
+	if !ok {
+		notFound, err := status.New(codes.Aborted, "evaluate call to endorser returned error").WithDetails(
+			&gateway.ErrorDetail{Address: "peer0.org1.example.com:7051", MspId: "Org1MSP", Message: `chaincode response 500, {"code":"DID_NOT_FOUND","message":"The did ` + args[0] + ` does not exist"}`},
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return nil, notFound.Err()
+	}
+
+	switch name {
+	case "QueryDidHash":
+		return json.Marshal(didHash{DidNumber: "1", Hash: hashOf(document)})
+	case "QueryDidById":
+		return []byte(document), nil
+	}
+
+	return nil, errors.New("unexpected transaction " + name)
+}
+
+func hashOf(document string) string {
+	hash := sha256.Sum256([]byte(document))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func newTestCache(t *testing.T, ledger *testLedger) *cache {
+	s, err := openStore("sqlite", filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	return &cache{memory: newLRU(10), store: s, contract: ledger}
+}
+
+func TestLRU(t *testing.T) {
+	c := newLRU(2)
+	c.put(&entry{Id: "a"})
+	c.put(&entry{Id: "b"})
+
+	require.NotNil(t, c.get("a"))
+	c.put(&entry{Id: "c"})
+	assert.Nil(t, c.get("b"), "should evict the least recently used entry")
+	assert.NotNil(t, c.get("a"))
+	assert.NotNil(t, c.get("c"))
+
+	c.update(&entry{Id: "d"})
+	assert.Nil(t, c.get("d"), "should not cache an updated entry that is not cached")
+
+	c.update(&entry{Id: "a", Hash: "h2"})
+	assert.Equal(t, "h2", c.get("a").Hash, "should update a cached entry")
+
+	c.remove("a")
+	assert.Nil(t, c.get("a"), "should evict a removed entry")
+}
+
+func TestResolve(t *testing.T) {
+	ledger := &testLedger{documents: map[string]string{testDid: `{"id":"did:fabcar:1","deactivated":false}`}}
+	c := newTestCache(t, ledger)
+
+	e, source, err := c.resolve(testDid)
+	require.NoError(t, err)
+	assert.Equal(t, sourceLedger, source, "should read an unknown did from the ledger")
+	assert.Equal(t, ledger.documents[testDid], string(e.Document))
+	assert.Equal(t, hashOf(ledger.documents[testDid]), e.Hash)
+	assert.Equal(t, "1", e.DidNumber)
+	assert.Equal(t, []string{"QueryDidHash", "QueryDidById"}, ledger.calls)
+
+	ledger.calls = nil
+	e, source, err = c.resolve(testDid)
+	require.NoError(t, err)
+	assert.Equal(t, sourceMemory, source, "should serve a resolved did from memory")
+	assert.Equal(t, ledger.documents[testDid], string(e.Document))
+	assert.Empty(t, ledger.calls, "should not read the ledger on a memory hit")
+
+	c.memory = newLRU(10)
+	e, source, err = c.resolve(testDid)
+	require.NoError(t, err)
+	assert.Equal(t, sourceStore, source, "should serve a stored did matching the ledger")
+	assert.Equal(t, ledger.documents[testDid], string(e.Document))
+	assert.Equal(t, []string{"QueryDidHash"}, ledger.calls, "should only read the hash of a stored did")
+
+	ledger.calls = nil
+	ledger.documents[testDid] = `{"id":"did:fabcar:1","deactivated":true}`
+	c.memory = newLRU(10)
+	e, source, err = c.resolve(testDid)
+	require.NoError(t, err)
+	assert.Equal(t, sourceLedger, source, "should read a stale stored did from the ledger")
+	assert.True(t, e.Deactivated)
+	assert.Equal(t, []string{"QueryDidHash", "QueryDidById"}, ledger.calls)
+
+	stored, err := c.store.get(testDid)
+	require.NoError(t, err)
+	assert.Equal(t, ledger.documents[testDid], string(stored.Document), "should replace the stale stored did")
+
+	delete(ledger.documents, testDid)
+	c.memory = newLRU(10)
+	e, source, err = c.resolve(testDid)
+	require.NoError(t, err)
+	assert.Nil(t, e, "should not resolve a did missing from the ledger")
+	assert.Equal(t, sourceLedger, source)
+
+	stored, err = c.store.get(testDid)
+	require.NoError(t, err)
+	assert.Nil(t, stored, "should remove a did missing from the ledger from the store")
+
+	ledger.err = errors.New("connection refused")
+	_, _, err = c.resolve(testDid)
+	assert.EqualError(t, err, "failed to read hash of did:fabcar:1: connection refused")
+}
+
+func TestApply(t *testing.T) {
+	c := newTestCache(t, &testLedger{})
+	document := `{"id":"did:fabcar:1","deactivated":false}`
+
+	require.NoError(t, c.apply(index.DidEvent{DidNumber: "1", Did: json.RawMessage(document)}, 5))
+	assert.Nil(t, c.memory.get(testDid), "should not cache a did nobody resolved in memory")
+
+	stored, err := c.store.get(testDid)
+	require.NoError(t, err)
+	require.NotNil(t, stored, "should store the document of the event")
+	assert.Equal(t, document, string(stored.Document))
+	assert.Equal(t, hashOf(document), stored.Hash, "should store the hash of the document of the event")
+
+	c.memory.put(stored)
+	deactivated := `{"id":"did:fabcar:1","deactivated":true}`
+	require.NoError(t, c.apply(index.DidEvent{DidNumber: "1", Did: json.RawMessage(deactivated)}, 6))
+	assert.True(t, c.memory.get(testDid).Deactivated, "should refresh a did held in memory")
+
+	require.NoError(t, c.apply(index.DidEvent{DidNumber: "1", Did: json.RawMessage(`{"id":"did:fabcar:1","deleted":true}`)}, 7))
+	assert.Nil(t, c.memory.get(testDid), "should evict a deleted did from memory")
+
+	stored, err = c.store.get(testDid)
+	require.NoError(t, err)
+	assert.Nil(t, stored, "should remove a deleted did from the store")
+
+	err = c.apply(index.DidEvent{DidNumber: "2", Did: json.RawMessage(`[]`)}, 8)
+	assert.ErrorContains(t, err, "invalid document of 2")
+}
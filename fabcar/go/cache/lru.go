@@ -0,0 +1,91 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"container/list"
+	"sync"
+)
+
+// entry is the current document of a did and the hash of its canonical JSON
+// on the ledger
+type entry struct {
+	Id          string
+	DidNumber   string
+	Document    []byte
+	Hash        string
+	Deactivated bool
+}
+
+// lru is the in-memory tier of the cache, holding at most capacity documents
+// and evicting the least recently used first
+type lru struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+func newLRU(capacity int) *lru {
+	return &lru{capacity: capacity, order: list.New(), entries: map[string]*list.Element{}}
+}
+
+// get returns the entry of the did with given id, or nil if it is not cached
+func (c *lru) get(id string) *entry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[id]
+
+	if !ok {
+		return nil
+	}
+
+	c.order.MoveToFront(element)
+
+	return element.Value.(*entry)
+}
+
+// put caches e, evicting the least recently used entry when the cache is full
+func (c *lru) put(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[e.Id]; ok {
+		element.Value = e
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[e.Id] = c.order.PushFront(e)
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry).Id)
+	}
+}
+
+// update replaces the entry of a cached did without changing its recency, so
+// that committed changes do not fill the cache with dids nobody resolves
+func (c *lru) update(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[e.Id]; ok {
+		element.Value = e
+	}
+}
+
+// remove evicts the entry of the did with given id
+func (c *lru) remove(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[id]; ok {
+		c.order.Remove(element)
+		delete(c.entries, id)
+	}
+}
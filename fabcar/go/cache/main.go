@@ -0,0 +1,158 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command cache serves the current did documents of the fabcar chaincode from a
+// read-optimized cache. It listens to committed blocks and writes the documents
+// carried by their did events to a persistent SQLite or Postgres store,
+// refreshing those held in an in-memory LRU cache of CACHE_SIZE documents (1000
+// by default). On a memory miss the stored document is served only if its hash
+// matches the one returned by QueryDidHash, otherwise it is read again with
+// QueryDidById, so a store left stale by downtime never serves an outdated
+// document.
+//
+// Documents are served at GET /identifiers/{did} on CACHE_ADDRESS (:8081 by
+// default), with the tier they came from in the X-Cache header. The store is
+// selected with DATABASE_DRIVER (sqlite or postgres) and DATABASE_URL, and the
+// next block to read is saved in CACHE_CHECKPOINT.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+func envOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// listen applies committed blocks to the cache until the block stream ends. The
+// checkpoint is advanced only once every document of a block has been stored
+func listen(ctx context.Context, conn *connection.Connection, c *cache, checkpointer *client.FileCheckpointer) error {
+	options := []client.BlockEventsOption{client.WithCheckpoint(checkpointer)}
+
+	if checkpointer.BlockNumber() == 0 {
+		options = []client.BlockEventsOption{client.WithStartBlock(0)}
+	} else {
+		log.Printf("Resuming from block %d", checkpointer.BlockNumber())
+	}
+
+	blocks, err := conn.Network.BlockEvents(ctx, options...)
+
+	if err != nil {
+		return fmt.Errorf("failed to start block event listening: %w", err)
+	}
+
+	for block := range blocks {
+		applied, err := c.applyBlock(block, conn.ChaincodeName)
+
+		if err != nil {
+			return err
+		}
+
+		if err := checkpointer.CheckpointBlock(block.GetHeader().GetNumber()); err != nil {
+			return fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+
+		if applied > 0 {
+			log.Printf("Cached %d documents from block %d", applied, block.GetHeader().GetNumber())
+		}
+	}
+
+	return ctx.Err()
+}
+
+func serve(address string, c *cache) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /identifiers/{did}", func(w http.ResponseWriter, r *http.Request) {
+		e, source, err := c.resolve(r.PathValue("did"))
+
+		if source != "" {
+			w.Header().Set("X-Cache", source)
+		}
+
+		switch {
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		case e == nil:
+			http.Error(w, "not found", http.StatusNotFound)
+		case e.Deactivated:
+			http.Error(w, "deactivated", http.StatusGone)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(e.Document)
+		}
+	})
+
+	log.Printf("Serving cached dids on %s", address)
+	log.Fatal(http.ListenAndServe(address, mux))
+}
+
+func main() {
+	config := connection.ConfigFromEnv()
+
+	size, err := strconv.Atoi(envOrDefault("CACHE_SIZE", "1000"))
+
+	if err != nil || size < 1 {
+		log.Fatalf("CACHE_SIZE must be a positive number")
+	}
+
+	s, err := openStore(envOrDefault("DATABASE_DRIVER", "sqlite"), envOrDefault("DATABASE_URL", "cache.db"))
+
+	if err != nil {
+		log.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	checkpointer, err := client.NewFileCheckpointer(envOrDefault("CACHE_CHECKPOINT", "cache-checkpoint.json"))
+
+	if err != nil {
+		log.Fatalf("Failed to open checkpoint: %v", err)
+	}
+	defer checkpointer.Close()
+
+	conn, err := connection.Connect(config)
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	c := &cache{memory: newLRU(size), store: s, contract: conn.Contract}
+
+	go serve(envOrDefault("CACHE_ADDRESS", ":8081"), c)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for ctx.Err() == nil {
+		err := listen(ctx, conn, c, checkpointer)
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		log.Printf("Block listening stopped, restarting in 5s: %v", err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
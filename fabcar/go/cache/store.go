@@ -0,0 +1,90 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// schema creates the table of the persistent tier. The SQL is shared by SQLite
+// and Postgres
+const schema = `CREATE TABLE IF NOT EXISTS cached_dids (
+	id           TEXT PRIMARY KEY,
+	did_number   TEXT NOT NULL,
+	document     TEXT NOT NULL,
+	hash         TEXT NOT NULL,
+	deactivated  BOOLEAN NOT NULL DEFAULT FALSE,
+	block_number BIGINT NOT NULL
+)`
+
+const upsertEntry = `INSERT INTO cached_dids (id, did_number, document, hash, deactivated, block_number)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (id) DO UPDATE SET did_number = excluded.did_number, document = excluded.document,
+		hash = excluded.hash, deactivated = excluded.deactivated, block_number = excluded.block_number`
+
+// store is the persistent tier of the cache, which survives restarts and holds
+// every did seen in a block or resolved from the ledger
+type store struct {
+	db *sql.DB
+}
+
+func openStore(driver string, dataSource string) (*store, error) {
+	db, err := sql.Open(driver, dataSource)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", driver, err)
+	}
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	return &store{db: db}, nil
+}
+
+func (s *store) Close() error {
+	return s.db.Close()
+}
+
+// get returns the entry of the did with given id, or nil if it is not stored
+func (s *store) get(id string) (*entry, error) {
+	e := &entry{Id: id}
+	var document string
+
+	err := s.db.QueryRow(`SELECT did_number, document, hash, deactivated FROM cached_dids WHERE id = $1`, id).Scan(&e.DidNumber, &document, &e.Hash, &e.Deactivated)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", id, err)
+	}
+
+	e.Document = []byte(document)
+
+	return e, nil
+}
+
+// put stores e as read from the given block, 0 when it was read from the
+// world state
+func (s *store) put(e *entry, blockNumber uint64) error {
+	if _, err := s.db.Exec(upsertEntry, e.Id, e.DidNumber, string(e.Document), e.Hash, e.Deactivated, blockNumber); err != nil {
+		return fmt.Errorf("failed to store %s: %w", e.Id, err)
+	}
+
+	return nil
+}
+
+// remove deletes the entry of the did with given id
+func (s *store) remove(id string) error {
+	if _, err := s.db.Exec(`DELETE FROM cached_dids WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", id, err)
+	}
+
+	return nil
+}
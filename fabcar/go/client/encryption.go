@@ -0,0 +1,89 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/pem"
+	"log"
+)
+
+// ecdhPublicKeyPem returns the PEM encoding of a key agreement public key
+func ecdhPublicKeyPem(publicKey *ecdh.PublicKey) string {
+	der, err := x509.MarshalPKIXPublicKey(publicKey)
+
+	if err != nil {
+		log.Fatalf("Failed to encode public key: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+// concatKdf derives the content encryption key of an ECDH-ES JWE from the
+// shared secret as defined by RFC 7518 section 4.6.2
+func concatKdf(secret []byte, enc string, keyBits int) []byte {
+	lengthPrefixed := func(value []byte) []byte {
+		return append(binary.BigEndian.AppendUint32(nil, uint32(len(value))), value...)
+	}
+
+	info := lengthPrefixed([]byte(enc))
+	info = append(info, lengthPrefixed(nil)...)
+	info = append(info, lengthPrefixed(nil)...)
+	info = binary.BigEndian.AppendUint32(info, uint32(keyBits))
+
+	digest := sha256.Sum256(append(append([]byte{0, 0, 0, 1}, secret...), info...))
+
+	return digest[:keyBits/8]
+}
+
+// encryptJwe encrypts plaintext to the P-256 key agreement key kid as a compact
+// ECDH-ES JWE with A256GCM content encryption
+func encryptJwe(recipient *ecdh.PublicKey, kid string, plaintext []byte) string {
+	ephemeral, err := ecdh.P256().GenerateKey(rand.Reader)
+
+	if err != nil {
+		log.Fatalf("Failed to generate ephemeral key: %v", err)
+	}
+
+	secret, err := ephemeral.ECDH(recipient)
+
+	if err != nil {
+		log.Fatalf("Failed to derive shared secret: %v", err)
+	}
+
+	point := ephemeral.PublicKey().Bytes()
+	header, _ := json.Marshal(map[string]interface{}{
+		"alg": "ECDH-ES",
+		"enc": "A256GCM",
+		"kid": kid,
+		"epk": map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(point[1:33]),
+			"y":   base64.RawURLEncoding.EncodeToString(point[33:]),
+		},
+	})
+	protected := base64.RawURLEncoding.EncodeToString(header)
+
+	block, _ := aes.NewCipher(concatKdf(secret, "A256GCM", 256))
+	gcm, _ := cipher.NewGCM(block)
+
+	iv := make([]byte, gcm.NonceSize())
+	_, _ = rand.Read(iv)
+
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(protected))
+	ciphertext, tag := sealed[:len(sealed)-gcm.Overhead()], sealed[len(sealed)-gcm.Overhead():]
+
+	return protected + ".." + base64.RawURLEncoding.EncodeToString(iv) + "." + base64.RawURLEncoding.EncodeToString(ciphertext) + "." +
+		base64.RawURLEncoding.EncodeToString(tag)
+}
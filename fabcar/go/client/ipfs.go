@@ -0,0 +1,135 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// ipfsApiEnv names the environment variable holding the address of the IPFS
+// node HTTP API off-chain documents are stored with
+const ipfsApiEnv = "IPFS_API"
+
+const defaultIpfsApi = "http://localhost:5001"
+
+// offChainDocument is the reference to an off-chain document the registry
+// returns in the resolution metadata of a did
+type offChainDocument struct {
+	Cid  string `json:"cid"`
+	Hash string `json:"hash"`
+	Size int    `json:"size"`
+}
+
+func ipfsApi() string {
+	if api := os.Getenv(ipfsApiEnv); api != "" {
+		return api
+	}
+
+	return defaultIpfsApi
+}
+
+// documentCid returns the CIDv1 of document stored as an IPFS raw block hashed
+// with sha2-256, which the registry requires for off-chain documents
+func documentCid(document []byte) string {
+	digest := sha256.Sum256(document)
+	cid := append([]byte{0x01, 0x55, 0x12, 0x20}, digest[:]...)
+
+	return "b" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(cid))
+}
+
+// ipfsCall posts to an endpoint of the IPFS HTTP API, attaching data as a file
+// when it is not nil, and returns the response body
+func ipfsCall(path string, query url.Values, data []byte) ([]byte, error) {
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+
+	if data != nil {
+		part, err := writer.CreateFormFile("file", "document.json")
+
+		if err != nil {
+			return nil, err
+		}
+
+		part.Write(data)
+	}
+
+	writer.Close()
+
+	response, err := http.Post(ipfsApi()+path+"?"+query.Encode(), writer.FormDataContentType(), body)
+
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	result, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("IPFS %s failed with %s: %s", path, response.Status, result)
+	}
+
+	return result, nil
+}
+
+// addToIpfs stores document as a raw block and returns its CID
+func addToIpfs(document []byte) (string, error) {
+	result, err := ipfsCall("/api/v0/block/put", url.Values{"cid-codec": {"raw"}, "mhtype": {"sha2-256"}, "pin": {"true"}}, document)
+
+	if err != nil {
+		return "", err
+	}
+
+	var block struct {
+		Key string `json:"Key"`
+	}
+
+	if err := json.Unmarshal(result, &block); err != nil {
+		return "", err
+	}
+
+	return block.Key, nil
+}
+
+// fetchOffChainDocument reads the document referenced by the resolution
+// metadata of a did from IPFS and verifies it against the size, hash and CID
+// recorded on the ledger, so documents altered by the IPFS node are rejected
+func fetchOffChainDocument(reference offChainDocument) ([]byte, error) {
+	document, err := ipfsCall("/api/v0/block/get", url.Values{"arg": {reference.Cid}}, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(document) != reference.Size {
+		return nil, fmt.Errorf("document %s is %d bytes, the ledger records %d", reference.Cid, len(document), reference.Size)
+	}
+
+	digest := sha256.Sum256(document)
+
+	if hex.EncodeToString(digest[:]) != reference.Hash {
+		return nil, fmt.Errorf("hash of document %s does not match the ledger", reference.Cid)
+	}
+
+	if documentCid(document) != reference.Cid {
+		return nil, fmt.Errorf("document does not match CID %s", reference.Cid)
+	}
+
+	return document, nil
+}
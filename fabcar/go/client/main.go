@@ -0,0 +1,914 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command client is a reference client for the fabcar did registry. It connects
+// through the Fabric Gateway and submits or evaluates every transaction of the
+// did, credential and admin contracts in turn, signing updates with an
+// Ed25519 key it generates for the did it creates.
+package main
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+const (
+	ed25519Type2020 = "Ed25519VerificationKey2020"
+	ed25519Type2018 = "Ed25519VerificationKey2018"
+	statusListSize  = 131072
+)
+
+func main() {
+	conn, err := connection.Connect(connection.ConfigFromEnv())
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	contract := conn.Contract
+	credentials := conn.Network.GetContractWithName(conn.ChaincodeName, connection.CredentialContract)
+	admin := conn.Network.GetContractWithName(conn.ChaincodeName, connection.AdminContract)
+	policies := conn.Network.GetContractWithName(conn.ChaincodeName, connection.PolicyContract)
+	consents := conn.Network.GetContractWithName(conn.ChaincodeName, connection.ConsentContract)
+	sharing := conn.Network.GetContractWithName(conn.ChaincodeName, connection.SharingContract)
+
+	// InitLedger requires the registry administrator attribute, which the
+	// default test network users do not have, so this shows a failed endorsement.
+	// Until an administrator has initialized the ledger, the transactions below
+	// that write to it fail with NOT_INITIALIZED
+	evaluate(contract, "GetContractInfo")
+	submit(admin, "InitLedger", client.WithArguments(""))
+	submit(admin, "MigrateData", client.WithArguments("1"))
+	evaluate(contract, "QueryEndpointSchemes")
+	evaluate(contract, "QueryRegistrationQuotas")
+	evaluate(contract, "QueryRegistrationFee")
+	evaluate(contract, "QueryOffChainThreshold")
+	evaluate(contract, "QueryDocumentLimits")
+	evaluate(contract, "QueryDidCaps")
+	evaluate(contract, "QueryCertificateAttributes")
+	evaluate(policies, "QueryOperationPolicies")
+
+	key := newKey()
+	didId := createDid(contract, key)
+
+	evaluate(contract, "QueryDidByKey", didId)
+	evaluate(contract, "QueryDidById", didId)
+	evaluate(contract, "QueryDidHash", didId)
+	evaluate(contract, "Resolve", didId, "false")
+	evaluate(contract, "Dereference", didId+"#keys-1")
+	evaluate(contract, "Dereference", didId+"?service=vcs&relativeRef=/status")
+	evaluate(contract, "QueryAllDids", "", "")
+	evaluate(contract, "QueryAllDidsWithPagination", "deactivated", "10", "")
+	evaluate(contract, "QueryAllDidsKeyedById", "all", "")
+	evaluate(contract, "QueryDidsByIdPrefix", "did:example:*", "10", "")
+	evaluate(contract, "QueryAllDidsSorted", "updated", "10", "")
+	evaluate(contract, "QueryDidNamespace", didId)
+	evaluate(contract, "QueryDidsByNamespace", "Org1MSP", "10", "")
+	evaluate(contract, "SearchDids", "serviceEndpoint", "https://example.com/*")
+	evaluate(contract, "QueryDidsCreatedBetween", time.Now().UTC().AddDate(0, -1, 0).Format("2006-01-02"), time.Now().UTC().Format("2006-01-02"))
+	evaluate(contract, "ExportAllDids", "10", "")
+	evaluate(contract, "QueryDidProvenance", didId)
+	submit(contract, "RegisterIdentityBinding", client.WithArguments(didId))
+	evaluate(contract, "GetDidForIdentity", "User1@org1.example.com")
+
+	authenticate(contract, didId, key)
+	updateDid(contract, didId, key)
+	setDocumentMetadata(contract, didId, key)
+	renewDid(contract, didId, key)
+	reviewUpdate(contract, didId, key)
+	key = manageKeys(contract, didId, key)
+	key = recoverDid(contract, didId, key)
+	setDidCommService(contract, didId, key)
+	encryptServiceEndpoint(contract, didId, key)
+	manageServiceEndpoint(contract, didId, key)
+	erasePersonalData(contract, didId)
+	manageEndorsement(contract, didId)
+	delegateControl(contract, didId)
+	transferControl(contract, didId)
+	submit(admin, "SetCertificateAttributes", client.WithArguments(`["hf.Type", "hf.Affiliation"]`))
+	certificateDid, err := submit(contract, "CreateDidFromCertificate", client.WithArguments("#vcs", "VerifiableCredentialService", "https://example.com/vc/", ""))
+
+	if err == nil {
+		submit(contract, "SyncIdentityAttributes", client.WithArguments(string(certificateDid)))
+	}
+	createPrivateDids(contract)
+	batchCreateDids(contract)
+	anchorDids(contract)
+	createDidWithCid(contract)
+	processSidetreeOperations(contract)
+	manageCredentials(contract, credentials, didId, key)
+	manageConsent(contract, consents, didId)
+	shareDidData(sharing)
+
+	evaluate(contract, "QueryDidHistory", didId)
+	evaluate(contract, "QueryAuditLog", didId)
+	evaluate(contract, "QueryAuditLogByOrg", "Org1MSP")
+	deactivateDid(contract, didId, key)
+	evaluate(contract, "Resolve", didId, "false")
+	evaluate(contract, "GetRegistryStats")
+	evaluate(contract, "ResolveVersion", didId, "", time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+}
+
+func newKey() ed25519.PrivateKey {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+
+	return key
+}
+
+func publicKeyPem(key ed25519.PrivateKey) string {
+	der, err := x509.MarshalPKIXPublicKey(key.Public())
+
+	if err != nil {
+		log.Fatalf("Failed to encode public key: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func randomHex() string {
+	value := make([]byte, 8)
+	_, _ = rand.Read(value)
+
+	return hex.EncodeToString(value)
+}
+
+// queryDid reads the stored did, exiting if it cannot be read
+func queryDid(contract *client.Contract, didNumber string) storedDid {
+	result, err := evaluate(contract, "QueryDidByKey", didNumber)
+
+	if err != nil {
+		log.Fatalf("Failed to read %s", didNumber)
+	}
+
+	did := storedDid{}
+
+	if err := json.Unmarshal(result, &did); err != nil {
+		log.Fatalf("Failed to parse %s: %v", didNumber, err)
+	}
+
+	return did
+}
+
+func createDid(contract *client.Contract, key ed25519.PrivateKey) string {
+	result, err := submit(contract, "CreateDid", client.WithArguments("#keys-1", ed25519Type2020, "", publicKeyPem(key),
+		"#vcs", "VerifiableCredentialService", "https://example.com/vc/", ""))
+
+	if err != nil {
+		log.Fatalf("Failed to create did")
+	}
+
+	fmt.Printf("*** Created %s\n", result)
+
+	return string(result)
+}
+
+// authenticate answers an authentication challenge with the did's key
+func authenticate(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	result, err := submit(contract, "CreateAuthChallenge", client.WithArguments(didNumber))
+
+	if err != nil {
+		return
+	}
+
+	challenge := struct {
+		Nonce string `json:"nonce"`
+	}{}
+	_ = json.Unmarshal(result, &challenge)
+
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(challenge.Nonce)))
+
+	if result, err := submit(contract, "VerifyAuthResponse", client.WithArguments(didNumber, signature)); err == nil {
+		fmt.Printf("*** Authenticated: %s\n", result)
+	}
+}
+
+func updateDid(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	did := queryDid(contract, didNumber)
+
+	args := map[string]string{
+		"authenticationId":            did.str("authenticationId"),
+		"authenticationType":          ed25519Type2020,
+		"authenticationController":    did.str("authenticationController"),
+		"authenticationPublicKeyPerm": publicKeyPem(key),
+		"serviceId":                   did.str("serviceId"),
+		"serviceType":                 "VerifiableCredentialService",
+		"serviceEndPoint":             "https://example.com/credentials/",
+	}
+
+	document := did.document()
+	delete(document, "authenticationPublicKeyMultibase")
+	delete(document, "authenticationPublicKeyJwk")
+
+	for member, value := range args {
+		document[member] = value
+	}
+
+	signature := signUpdate(key, didNumber, did, document)
+
+	submit(contract, "UpdateDid", client.WithArguments(didNumber, args["authenticationId"], args["authenticationType"], args["authenticationController"],
+		args["authenticationPublicKeyPerm"], args["serviceId"], args["serviceType"], args["serviceEndPoint"], signature))
+}
+
+// setDocumentMetadata records when the did is next expected to change, which
+// resolvers may use to decide how long to cache it
+func setDocumentMetadata(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	did := queryDid(contract, didNumber)
+	nextUpdate := time.Now().Add(30 * 24 * time.Hour).UTC().Format(time.RFC3339)
+
+	document := did.document()
+	document["metadata"] = map[string]interface{}{"equivalentId": []string{}, "nextUpdate": nextUpdate}
+
+	submit(contract, "SetDocumentMetadata", client.WithArguments(didNumber, "", "", nextUpdate, signUpdate(key, didNumber, did, document)))
+	evaluate(contract, "Resolve", didNumber, "false")
+}
+
+// renewDid makes the did expire in a year
+func renewDid(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	did := queryDid(contract, didNumber)
+	expires := time.Now().AddDate(1, 0, 0).UTC().Format(time.RFC3339)
+
+	document := did.document()
+	document["expires"] = expires
+
+	submit(contract, "RenewDid", client.WithArguments(didNumber, expires, signUpdate(key, didNumber, did, document)))
+}
+
+// reviewUpdate proposes a new service endpoint and approves it with the did's
+// own key, as the did has no multisig policy
+func reviewUpdate(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	did := queryDid(contract, didNumber)
+	endpoint := "https://example.com/reviewed/"
+
+	document := did.document()
+	document["serviceEndPoint"] = endpoint
+
+	if _, err := submit(contract, "ProposeDidUpdate", client.WithArguments(didNumber, `{"serviceEndPoint":"`+endpoint+`"}`)); err != nil {
+		return
+	}
+
+	evaluate(contract, "QueryDidUpdateProposal", didNumber)
+	submit(contract, "ApproveDidUpdate", client.WithArguments(didNumber, didNumber, signUpdate(key, didNumber, did, document)))
+}
+
+// manageKeys adds and removes an additional verification method and then rotates
+// the authentication key, returning the new key
+func manageKeys(contract *client.Contract, didNumber string, key ed25519.PrivateKey) ed25519.PrivateKey {
+	did := queryDid(contract, didNumber)
+	methodKey := newKey()
+	method := map[string]string{
+		"id":           didNumber + "#keys-2",
+		"type":         ed25519Type2018,
+		"controller":   didNumber,
+		"publicKeyPem": publicKeyPem(methodKey),
+	}
+
+	methods := []interface{}{}
+	_ = json.Unmarshal(did["verificationMethods"], &methods)
+
+	document := did.document()
+	document["verificationMethods"] = append(methods, method)
+
+	submit(contract, "AddVerificationMethod", client.WithArguments(didNumber, method["id"], method["type"], method["controller"],
+		method["publicKeyPem"], signUpdate(key, didNumber, did, document)))
+
+	did = queryDid(contract, didNumber)
+	document = did.document()
+	delete(document, "verificationMethods")
+
+	submit(contract, "RemoveVerificationMethod", client.WithArguments(didNumber, method["id"], signUpdate(key, didNumber, did, document)))
+
+	did = queryDid(contract, didNumber)
+	rotated := newKey()
+	rotatedId := didNumber + "#keys-3"
+
+	document = did.document()
+	delete(document, "authenticationPublicKeyMultibase")
+	delete(document, "authenticationPublicKeyJwk")
+	document["authenticationId"] = rotatedId
+	document["authenticationType"] = ed25519Type2020
+	document["authenticationPublicKeyPerm"] = publicKeyPem(rotated)
+
+	_, err := submit(contract, "RotateKey", client.WithArguments(didNumber, rotatedId, ed25519Type2020, publicKeyPem(rotated),
+		signUpdate(key, didNumber, did, document)))
+
+	if err != nil {
+		return key
+	}
+
+	return rotated
+}
+
+// recoverDid registers a recovery key for the did and uses it to replace the keys
+// of the did as after their loss, returning the new authentication key
+func recoverDid(contract *client.Contract, didNumber string, key ed25519.PrivateKey) ed25519.PrivateKey {
+	did := queryDid(contract, didNumber)
+	recoveryKey := newKey()
+	method := map[string]string{
+		"id":           didNumber + "#recovery-1",
+		"type":         ed25519Type2018,
+		"controller":   didNumber,
+		"publicKeyPem": publicKeyPem(recoveryKey),
+	}
+
+	document := did.document()
+	document["recoveryMethods"] = []interface{}{method}
+
+	_, err := submit(contract, "AddRecoveryMethod", client.WithArguments(didNumber, method["id"], method["type"], method["controller"],
+		method["publicKeyPem"], signUpdate(key, didNumber, did, document)))
+
+	if err != nil {
+		return key
+	}
+
+	did = queryDid(contract, didNumber)
+	recovered := newKey()
+	recoveredId := didNumber + "#keys-4"
+
+	document = did.document()
+	delete(document, "authenticationPublicKeyMultibase")
+	delete(document, "authenticationPublicKeyJwk")
+	delete(document, "verificationMethods")
+	document["authenticationId"] = recoveredId
+	document["authenticationType"] = ed25519Type2020
+	document["authenticationPublicKeyPerm"] = publicKeyPem(recovered)
+
+	_, err = submit(contract, "RecoverDid", client.WithArguments(didNumber, method["id"], recoveredId, ed25519Type2020, publicKeyPem(recovered),
+		signUpdate(recoveryKey, didNumber, did, document)))
+
+	if err != nil {
+		return key
+	}
+
+	return recovered
+}
+
+// setDidCommService replaces the service of the did with a DIDComm v2 messaging
+// service routed through a mediator
+func setDidCommService(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	did := queryDid(contract, didNumber)
+	endpoints := []map[string]interface{}{{
+		"uri":         "https://mediator.example.com/didcomm",
+		"accept":      []string{"didcomm/v2"},
+		"routingKeys": []string{"did:example:mediator#key-x25519-1"},
+	}}
+	endpointsAsBytes, _ := json.Marshal(endpoints)
+
+	document := did.document()
+	document["serviceId"] = didNumber + "#didcomm-1"
+	document["serviceType"] = "DIDCommMessaging"
+	document["serviceEndPoint"] = ""
+	document["serviceEndpoints"] = endpoints
+
+	submit(contract, "SetDidCommService", client.WithArguments(didNumber, didNumber+"#didcomm-1", string(endpointsAsBytes), signUpdate(key, didNumber, did, document)))
+	evaluate(contract, "Resolve", didNumber, "false")
+}
+
+// encryptServiceEndpoint adds a P-256 key agreement key to the did and replaces
+// its service endpoint with the endpoint encrypted to that key
+func encryptServiceEndpoint(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	did := queryDid(contract, didNumber)
+	agreementKey, err := ecdh.P256().GenerateKey(rand.Reader)
+
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+
+	method := map[string]string{
+		"id":           didNumber + "#key-agreement-1",
+		"type":         "EcdsaSecp256r1VerificationKey2019",
+		"controller":   didNumber,
+		"publicKeyPem": ecdhPublicKeyPem(agreementKey.PublicKey()),
+	}
+
+	methods := []interface{}{}
+	_ = json.Unmarshal(did["verificationMethods"], &methods)
+
+	document := did.document()
+	document["verificationMethods"] = append(methods, method)
+
+	if _, err := submit(contract, "AddVerificationMethod", client.WithArguments(didNumber, method["id"], method["type"], method["controller"],
+		method["publicKeyPem"], signUpdate(key, didNumber, did, document))); err != nil {
+		return
+	}
+
+	did = queryDid(contract, didNumber)
+	jwe := encryptJwe(agreementKey.PublicKey(), method["id"], []byte("https://private.example.com/didcomm"))
+
+	document = did.document()
+	delete(document, "serviceEndpoints")
+	document["serviceEndPoint"] = ""
+	document["encryptedServiceEndPoint"] = jwe
+
+	submit(contract, "SetEncryptedServiceEndpoint", client.WithArguments(didNumber, jwe, signUpdate(key, didNumber, did, document)))
+	evaluate(contract, "Resolve", didNumber, "false")
+}
+
+func manageServiceEndpoint(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	endpoint := "https://private.example.com/vc/"
+	salt := randomHex()
+
+	did := queryDid(contract, didNumber)
+	document := did.document()
+	delete(document, "serviceEndpoints")
+	delete(document, "encryptedServiceEndPoint")
+	document["serviceEndPoint"] = endpoint
+
+	submit(contract, "SetPrivateServiceEndpoint", client.WithArguments(didNumber, endpoint, salt, signUpdate(key, didNumber, did, document)))
+	evaluate(contract, "QueryPrivateServiceEndpoint", didNumber)
+	evaluate(contract, "VerifyServiceEndpoint", didNumber, endpoint, salt)
+}
+
+// erasePersonalData records personal data of the did subject through the
+// transient map, so only its salted hashes reach the ledger, then erases it and
+// reads the erasure receipt from the audit log
+func erasePersonalData(contract *client.Contract, didNumber string) {
+	data, _ := json.Marshal(map[string]interface{}{
+		"fields": map[string]string{"name": "Alice Example", "email": "alice@example.com"},
+		"salt":   randomHex(),
+	})
+
+	if _, err := submit(contract, "SetDidPersonalData", client.WithArguments(didNumber), client.WithTransient(map[string][]byte{"personalData": data})); err != nil {
+		return
+	}
+
+	evaluate(contract, "QueryDidPersonalData", didNumber)
+
+	if receipt, err := submit(contract, "EraseDidPersonalData", client.WithArguments(didNumber)); err == nil {
+		fmt.Printf("*** Erasure receipt: %s\n", receipt)
+	}
+
+	evaluate(contract, "QueryAuditLog", didNumber)
+}
+
+func manageEndorsement(contract *client.Contract, didNumber string) {
+	submit(contract, "AddDidEndorser", client.WithArguments(didNumber, "Org2MSP"))
+	// Changes now need an endorsement from Org2 as well, which the gateway
+	// collects through service discovery
+	submit(contract, "RemoveDidEndorser", client.WithArguments(didNumber, "Org2MSP"))
+	submit(contract, "SetDidEndorsementPolicy", client.WithArguments(didNumber, `["Org1MSP", "Org2MSP"]`))
+	evaluate(contract, "GetDidEndorsementPolicy", didNumber)
+	submit(contract, "SetDidEndorsementPolicy", client.WithArguments(didNumber, `["Org1MSP"]`))
+}
+
+// delegateControl lets a new did update the service of the did for a day, shows
+// a delegated update signed with the delegate's key and revokes the delegation
+func delegateControl(contract *client.Contract, didNumber string) {
+	delegateKey := newKey()
+	delegate := createDid(contract, delegateKey)
+	expiry := time.Now().Add(24 * time.Hour).UTC().Format(time.RFC3339)
+
+	if _, err := submit(contract, "DelegateControl", client.WithArguments(didNumber, delegate, `["updateService"]`, expiry)); err != nil {
+		return
+	}
+
+	evaluate(contract, "QueryDelegations", didNumber)
+
+	did := queryDid(contract, didNumber)
+	document := did.document()
+	document["serviceEndPoint"] = "https://example.com/delegated/"
+
+	submit(contract, "UpdateService", client.WithArguments(didNumber, did.str("serviceId"), did.str("serviceType"), "https://example.com/delegated/",
+		delegate, signUpdate(delegateKey, didNumber, did, document)))
+	submit(contract, "RevokeDelegation", client.WithArguments(didNumber, delegate))
+}
+
+// transferControl proposes and accepts a transfer of the did to the calling
+// client identity, which is recorded as the controller of the did
+func transferControl(contract *client.Contract, didNumber string) {
+	controller := queryDid(contract, didNumber).str("controller")
+
+	if _, err := submit(contract, "ProposeTransfer", client.WithArguments(didNumber, controller)); err != nil {
+		return
+	}
+
+	evaluate(contract, "QueryTransfer", didNumber)
+	submit(contract, "AcceptTransfer", client.WithArguments(didNumber))
+}
+
+// createPrivateDids creates dids whose key is held in the private data collection,
+// passing the details as arguments and through the transient map
+func createPrivateDids(contract *client.Contract) {
+	didNumber := "DID-" + randomHex()
+	id := "did:example:" + randomHex()
+	key := newKey()
+
+	submit(contract, "CreateDidPrivate", client.WithArguments(didNumber, id, id+"#keys-1", ed25519Type2018, id, publicKeyPem(key),
+		id+"#vcs", "VerifiableCredentialService", "https://example.com/vc/"))
+	evaluate(contract, "QueryDidPrivate", didNumber)
+	submit(contract, "SetPrivateDidEndorsement", client.WithArguments(didNumber, `["Org1MSP"]`))
+
+	transientNumber := "DID-" + randomHex()
+	transientId := "did:example:" + randomHex()
+	input := map[string]interface{}{
+		"didNumber":                   transientNumber,
+		"id":                          transientId,
+		"authenticationId":            transientId + "#keys-1",
+		"authenticationType":          ed25519Type2018,
+		"authenticationController":    transientId,
+		"authenticationPublicKeyPerm": publicKeyPem(key),
+		"serviceId":                   transientId + "#vcs",
+		"serviceType":                 "VerifiableCredentialService",
+		"serviceEndPoint":             "https://example.com/vc/",
+		"serviceEndPointSalt":         randomHex(),
+	}
+	inputAsBytes, _ := json.Marshal(input)
+
+	if _, err := submit(contract, "CreateDidTransient", client.WithTransient(map[string][]byte{"did": inputAsBytes})); err != nil {
+		return
+	}
+
+	did := queryDid(contract, transientNumber)
+	document := did.document()
+	document["serviceEndPoint"] = "https://example.com/updated/"
+
+	input["serviceEndPoint"] = document["serviceEndPoint"]
+	input["signature"] = signUpdate(key, transientNumber, did, document)
+	delete(input, "serviceEndPointSalt")
+	inputAsBytes, _ = json.Marshal(input)
+
+	submit(contract, "UpdateDidTransient", client.WithTransient(map[string][]byte{"did": inputAsBytes}))
+}
+
+// batchCreateDids creates several dids in a single transaction, then shows a
+// batch rejected as a whole because one of its items is invalid
+func batchCreateDids(contract *client.Contract) {
+	items := []map[string]string{}
+
+	for i := 0; i < 3; i++ {
+		items = append(items, map[string]string{
+			"authenticationId":            "#keys-1",
+			"authenticationType":          ed25519Type2020,
+			"authenticationController":    "",
+			"authenticationPublicKeyPerm": publicKeyPem(newKey()),
+			"serviceId":                   "#vcs",
+			"serviceType":                 "VerifiableCredentialService",
+			"serviceEndPoint":             "https://example.com/vc/",
+		})
+	}
+
+	itemsAsBytes, _ := json.Marshal(items)
+
+	if result, err := submit(contract, "BatchCreateDids", client.WithArguments(string(itemsAsBytes))); err == nil {
+		fmt.Printf("*** Created %s\n", result)
+	}
+
+	items = append(items, map[string]string{"authenticationPublicKeyPerm": items[0]["authenticationPublicKeyPerm"]})
+	itemsAsBytes, _ = json.Marshal(items[len(items)-2:])
+
+	submit(contract, "BatchCreateDids", client.WithArguments(string(itemsAsBytes)))
+}
+
+// anchorDids anchors a batch of dids, which stores only the Merkle root of the
+// batch and a proof per did, then reads the inclusion proof of the first did
+func anchorDids(contract *client.Contract) {
+	items := []map[string]string{}
+
+	for i := 0; i < 5; i++ {
+		items = append(items, map[string]string{
+			"authenticationId":            "#keys-1",
+			"authenticationType":          ed25519Type2020,
+			"authenticationController":    "",
+			"authenticationPublicKeyPerm": publicKeyPem(newKey()),
+			"serviceId":                   "#telemetry",
+			"serviceType":                 "LinkedDomains",
+			"serviceEndPoint":             "https://sensors.example.com",
+		})
+	}
+
+	itemsAsBytes, _ := json.Marshal(items)
+	result, err := submit(contract, "AnchorDidBatch", client.WithArguments(string(itemsAsBytes)))
+
+	if err != nil {
+		return
+	}
+
+	var anchored struct {
+		Batch struct {
+			BatchId string `json:"batchId"`
+		} `json:"batch"`
+		Dids []storedDid `json:"dids"`
+	}
+
+	if json.Unmarshal(result, &anchored) == nil && len(anchored.Dids) > 0 {
+		evaluate(contract, "GetAnchorBatch", anchored.Batch.BatchId)
+		evaluate(contract, "GetInclusionProof", anchored.Dids[0].str("id"))
+	}
+}
+
+// createDidWithCid stores a document too large for the ledger in IPFS and
+// registers a did holding only its CID and hash, passing the document through
+// the transient map for the chaincode to check. It then resolves the did and
+// reads the document back, verifying it against the resolution metadata
+func createDidWithCid(contract *client.Contract) {
+	services := []map[string]string{}
+
+	for i := 0; i < 100; i++ {
+		services = append(services, map[string]string{
+			"id":              fmt.Sprintf("#sensor-%d", i),
+			"type":            "LinkedDomains",
+			"serviceEndpoint": fmt.Sprintf("https://sensors.example.com/%d", i),
+		})
+	}
+
+	document := canonicalJSON(map[string]interface{}{"@context": "https://www.w3.org/ns/did/v1", "service": services})
+	cid := documentCid(document)
+
+	if stored, err := addToIpfs(document); err != nil {
+		fmt.Printf("*** Failed to store the document in IPFS, registering it anyway: %v\n", err)
+	} else if stored != cid {
+		fmt.Printf("*** IPFS stored the document as %s instead of %s\n", stored, cid)
+		return
+	}
+
+	id, err := submit(contract, "CreateDidWithCid", client.WithArguments("#keys-1", ed25519Type2020, "", publicKeyPem(newKey()), cid, ""),
+		client.WithTransient(map[string][]byte{"document": document}))
+
+	if err != nil {
+		return
+	}
+
+	result, err := evaluate(contract, "Resolve", string(id), "false")
+
+	if err != nil {
+		return
+	}
+
+	var resolution struct {
+		DidResolutionMetadata struct {
+			OffChainDocument *offChainDocument `json:"offChainDocument"`
+		} `json:"didResolutionMetadata"`
+	}
+
+	if json.Unmarshal(result, &resolution) != nil || resolution.DidResolutionMetadata.OffChainDocument == nil {
+		return
+	}
+
+	if fetched, err := fetchOffChainDocument(*resolution.DidResolutionMetadata.OffChainDocument); err != nil {
+		fmt.Printf("*** Failed to read back the off-chain document: %v\n", err)
+	} else {
+		fmt.Printf("*** Verified off-chain document of %d bytes\n", len(fetched))
+	}
+}
+
+// processSidetreeOperations creates a did with a Sidetree create operation,
+// adds a service with an update revealing the committed update key and resolves
+// the result, then deactivates the did with the recovery key
+func processSidetreeOperations(contract *client.Contract) {
+	signing, update, recovery, next := newKey(), newKey(), newKey(), newKey()
+
+	delta, deltaHash := sidetreeDelta(update, map[string]interface{}{
+		"action": "add-public-keys",
+		"publicKeys": []interface{}{map[string]interface{}{
+			"id": "key-1", "type": "JsonWebKey2020", "publicKeyJwk": sidetreeJwk(signing), "purposes": []string{"authentication"},
+		}},
+	})
+	suffixData := map[string]string{"deltaHash": deltaHash, "recoveryCommitment": sidetreeCommitment(recovery)}
+	create, _ := json.Marshal(map[string]interface{}{"type": "create", "suffixData": suffixData, "delta": delta})
+
+	did, err := submit(contract, "ProcessSidetreeOperation", client.WithArguments(string(create)))
+
+	if err != nil {
+		return
+	}
+
+	fmt.Printf("*** Created %s\n", did)
+	didSuffix := sidetreeHash(canonicalJSON(suffixData))
+
+	delta, deltaHash = sidetreeDelta(next, map[string]interface{}{
+		"action":   "add-services",
+		"services": []interface{}{map[string]string{"id": "vcs", "type": "VerifiableCredentialService", "serviceEndpoint": "https://example.com/vc/"}},
+	})
+	updateOperation, _ := json.Marshal(map[string]interface{}{
+		"type":        "update",
+		"didSuffix":   didSuffix,
+		"revealValue": sidetreeRevealValue(update),
+		"delta":       delta,
+		"signedData":  signSidetree(update, map[string]interface{}{"updateKey": sidetreeJwk(update), "deltaHash": deltaHash}),
+	})
+
+	submit(contract, "ProcessSidetreeOperation", client.WithArguments(string(updateOperation)))
+	evaluate(contract, "Resolve", string(did), "false")
+
+	deactivate, _ := json.Marshal(map[string]interface{}{
+		"type":        "deactivate",
+		"didSuffix":   didSuffix,
+		"revealValue": sidetreeRevealValue(recovery),
+		"signedData":  signSidetree(recovery, map[string]interface{}{"recoveryKey": sidetreeJwk(recovery), "didSuffix": didSuffix}),
+	})
+
+	submit(contract, "ProcessSidetreeOperation", client.WithArguments(string(deactivate)))
+	evaluate(contract, "Resolve", string(did), "false")
+}
+
+// manageCredentials records and verifies a credential issued by the did. Issuing
+// requires an accreditation granted by the registry administrator, so without
+// one only the status list and verification functions succeed
+func manageCredentials(contract *client.Contract, credentials *client.Contract, issuerDid string, key ed25519.PrivateKey) {
+	credentialType := "UniversityDegreeCredential"
+	credentialId := "urn:uuid:" + randomHex()
+	issuanceDate := time.Now().UTC().Format(time.RFC3339)
+	listId := "revocation-" + randomHex()
+
+	submit(credentials, "AccreditIssuer", client.WithArguments(issuerDid, credentialType, ""))
+	evaluate(credentials, "IsAccredited", issuerDid, credentialType)
+	evaluate(credentials, "QueryAccreditation", issuerDid, credentialType)
+
+	submit(credentials, "CreateStatusList", client.WithArguments(issuerDid, listId, fmt.Sprint(statusListSize)))
+
+	vc := map[string]interface{}{
+		"@context":          []string{"https://www.w3.org/2018/credentials/v1"},
+		"id":                credentialId,
+		"type":              []string{"VerifiableCredential", credentialType},
+		"issuer":            issuerDid,
+		"issuanceDate":      issuanceDate,
+		"credentialSubject": map[string]string{"id": issuerDid, "degree": "Bachelor of Science"},
+		"credentialStatus": map[string]string{
+			"id":                   issuerDid + "/status/" + listId + "#7",
+			"type":                 "StatusList2021Entry",
+			"statusPurpose":        "revocation",
+			"statusListIndex":      "7",
+			"statusListCredential": issuerDid + "/status/" + listId,
+		},
+	}
+
+	did := queryDid(contract, issuerDid)
+	vc["proof"] = map[string]string{
+		"type":               "JsonWebSignature2020",
+		"created":            issuanceDate,
+		"verificationMethod": did.str("authenticationId"),
+		"proofPurpose":       "assertionMethod",
+		"jws":                signJws(key, vc),
+	}
+	vcAsBytes, _ := json.Marshal(vc)
+
+	digest := sha256.Sum256(canonicalJSON(vc))
+	hash := hex.EncodeToString(digest[:])
+
+	submit(credentials, "IssueCredential", client.WithArguments(credentialId, issuerDid, issuerDid, credentialType, issuanceDate, hash))
+	evaluate(credentials, "QueryCredential", credentialId)
+	evaluate(credentials, "QueryCredentialsByIssuer", issuerDid)
+	evaluate(credentials, "QueryCredentialsBySubject", issuerDid)
+	evaluate(credentials, "VerifyCredential", string(vcAsBytes))
+
+	challenge := randomHex()
+	domain := "example.com"
+	vp := map[string]interface{}{
+		"type":                 []string{"VerifiablePresentation"},
+		"holder":               issuerDid,
+		"verifiableCredential": []json.RawMessage{vcAsBytes},
+	}
+	vp["proof"] = map[string]string{
+		"type":               "JsonWebSignature2020",
+		"verificationMethod": did.str("authenticationId"),
+		"proofPurpose":       "authentication",
+		"challenge":          challenge,
+		"domain":             domain,
+		"jws":                signJws(key, vp),
+	}
+	vpAsBytes, _ := json.Marshal(vp)
+
+	evaluate(credentials, "VerifyPresentation", string(vpAsBytes), challenge, domain)
+
+	submit(credentials, "RevokeCredential", client.WithArguments(issuerDid, listId, "7"))
+	evaluate(credentials, "IsRevoked", issuerDid, listId, "7")
+	evaluate(credentials, "GetStatusList", issuerDid, listId)
+	evaluate(credentials, "VerifyCredential", string(vcAsBytes))
+
+	registerCredentialDefinition(credentials, issuerDid, credentialType)
+
+	submit(credentials, "RevokeAccreditation", client.WithArguments(issuerDid, credentialType))
+}
+
+// registerCredentialDefinition registers an AnonCreds credential definition of
+// the did for the schema. The key values stand in for the ones an AnonCreds
+// library generates with the issuer's private key
+func registerCredentialDefinition(credentials *client.Contract, issuerDid string, schemaId string) {
+	definition := map[string]interface{}{
+		"issuerId": issuerDid,
+		"schemaId": schemaId,
+		"type":     "CL",
+		"tag":      "default",
+		"value": map[string]interface{}{
+			"primary": map[string]interface{}{
+				"n":     "779",
+				"s":     "750",
+				"r":     map[string]string{"master_secret": "521", "degree": "410"},
+				"rctxt": "774",
+				"z":     "632",
+			},
+			"revocation": map[string]string{"g": "1 1F", "h": "25", "pk": "1 0D"},
+		},
+	}
+	definitionAsBytes, _ := json.Marshal(definition)
+
+	id, err := submit(credentials, "RegisterCredentialDefinition", client.WithArguments(string(definitionAsBytes)))
+
+	if err == nil {
+		evaluate(credentials, "GetCredentialDefinition", string(id))
+		manageRevocationRegistry(credentials, issuerDid, string(id))
+	}
+}
+
+// manageRevocationRegistry registers a revocation registry of the credential
+// definition, revokes a credential and reads the accumulator at its latest and
+// initial versions
+func manageRevocationRegistry(credentials *client.Contract, issuerDid string, credDefId string) {
+	registry := map[string]interface{}{
+		"issuerId":     issuerDid,
+		"revocDefType": "CL_ACCUM",
+		"credDefId":    credDefId,
+		"tag":          "0",
+		"value": map[string]interface{}{
+			"publicKeys":    map[string]interface{}{"accumKey": map[string]string{"z": "1 0BB"}},
+			"maxCredNum":    100,
+			"tailsLocation": "https://tails.example.com/" + randomHex(),
+			"tailsHash":     randomHex(),
+		},
+	}
+	registryAsBytes, _ := json.Marshal(registry)
+
+	// The accumulator values are computed by the issuer's AnonCreds library
+	// from the tails file, the ledger records them as they are
+	id, err := submit(credentials, "RegisterRevocationRegistry", client.WithArguments(string(registryAsBytes), "21 124C594B6B20E41B681E92B2C43FD165EA9E68BC3C9D63A82C8893124983CAE94"))
+
+	if err != nil {
+		return
+	}
+
+	initial, _ := evaluate(credentials, "GetRevocationState", string(id), "")
+	submit(credentials, "UpdateAccumulator", client.WithArguments(string(id), `{"accumulator":"21 136D54EA439FC26F03DB4A6F3A5A1D6D9E","revoked":[7]}`))
+	evaluate(credentials, "GetRevocationState", string(id), "")
+
+	var state struct {
+		VersionId string `json:"versionId"`
+	}
+
+	if json.Unmarshal(initial, &state) == nil {
+		evaluate(credentials, "GetRevocationState", string(id), state.VersionId)
+	}
+}
+
+// manageConsent records the consent of the did to the processing of its data
+// by a new controller did, queries it from both sides and revokes it
+func manageConsent(contract *client.Contract, consents *client.Contract, subjectDid string) {
+	controllerDid := createDid(contract, newKey())
+	expires := time.Now().Add(365 * 24 * time.Hour).UTC().Format(time.RFC3339)
+	result, err := submit(consents, "GrantConsent", client.WithArguments(subjectDid, controllerDid, "marketing", expires))
+
+	if err != nil {
+		return
+	}
+
+	var consent struct {
+		ConsentId string `json:"consentId"`
+	}
+
+	if json.Unmarshal(result, &consent) != nil {
+		return
+	}
+
+	evaluate(consents, "HasConsent", subjectDid, controllerDid, "marketing")
+	evaluate(consents, "QueryConsentsBySubject", subjectDid)
+	evaluate(consents, "QueryConsentsByController", controllerDid)
+	submit(consents, "RevokeConsent", client.WithArguments(consent.ConsentId))
+	evaluate(consents, "QueryConsent", consent.ConsentId)
+}
+
+// shareDidData proposes to share the personal data of the dids of Org1 with
+// Org2. Proposals and acceptances require the registry administrator attribute,
+// and the agreement only takes effect once an administrator of Org2 accepts it
+// with AcceptSharingAgreement, so this shows failed endorsements with the
+// default test network users
+func shareDidData(sharing *client.Contract) {
+	submit(sharing, "ProposeSharingAgreement", client.WithArguments("Org2MSP", `["personalData.*"]`, ""))
+	evaluate(sharing, "QuerySharingAgreement", "Org1MSP", "Org2MSP")
+	evaluate(sharing, "QuerySharingAgreementsByOwner", "Org1MSP")
+	submit(sharing, "RevokeSharingAgreement", client.WithArguments("Org1MSP", "Org2MSP"))
+}
+
+func deactivateDid(contract *client.Contract, didNumber string, key ed25519.PrivateKey) {
+	did := queryDid(contract, didNumber)
+	document := did.document()
+	document["deactivated"] = true
+
+	submit(contract, "DeactivateDid", client.WithArguments(didNumber, signUpdate(key, didNumber, did, document)))
+}
@@ -0,0 +1,55 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// sidetreeHash returns the base64url encoded SHA2-256 multihash of data, the
+// hash of Sidetree operations
+func sidetreeHash(data []byte) string {
+	digest := sha256.Sum256(data)
+
+	return base64.RawURLEncoding.EncodeToString(append([]byte{0x12, 0x20}, digest[:]...))
+}
+
+// sidetreeJwk returns the public JWK of key
+func sidetreeJwk(key ed25519.PrivateKey) map[string]string {
+	return map[string]string{"kty": "OKP", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(key.Public().(ed25519.PublicKey))}
+}
+
+// sidetreeCommitment returns the commitment to the public key of key, which the
+// operation using the key later reveals with sidetreeRevealValue
+func sidetreeCommitment(key ed25519.PrivateKey) string {
+	digest := sha256.Sum256(canonicalJSON(sidetreeJwk(key)))
+
+	return sidetreeHash(digest[:])
+}
+
+// sidetreeRevealValue returns the value revealing the public key of key
+func sidetreeRevealValue(key ed25519.PrivateKey) string {
+	return sidetreeHash(canonicalJSON(sidetreeJwk(key)))
+}
+
+// signSidetree returns the compact EdDSA JWS of payload, the signed data of
+// Sidetree operations
+func signSidetree(key ed25519.PrivateKey, payload interface{}) string {
+	payloadAsBytes, _ := json.Marshal(payload)
+	signingInput := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA"}`)) + "." + base64.RawURLEncoding.EncodeToString(payloadAsBytes)
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(signingInput)))
+}
+
+// sidetreeDelta returns a delta applying patches and committing to the next
+// update key, and its hash
+func sidetreeDelta(next ed25519.PrivateKey, patches ...map[string]interface{}) (map[string]interface{}, string) {
+	delta := map[string]interface{}{"patches": patches, "updateCommitment": sidetreeCommitment(next)}
+
+	return delta, sidetreeHash(canonicalJSON(delta))
+}
@@ -0,0 +1,119 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// updatableMembers are the members of a stored did covered by the update payload
+// signed to prove possession of its authentication key
+var updatableMembers = []string{
+	"id", "authenticationId", "authenticationType", "authenticationController", "authenticationPublicKeyPerm",
+	"authenticationPublicKeyMultibase", "authenticationPublicKeyJwk", "verificationMethods", "recoveryMethods",
+	"serviceId", "serviceType", "serviceEndPoint", "serviceEndpoints", "encryptedServiceEndPoint", "deactivated", "expires", "deleted", "metadata", "multisig",
+}
+
+// storedDid is a did as returned by QueryDidByKey, keeping each member as raw JSON
+type storedDid map[string]json.RawMessage
+
+func (d storedDid) str(member string) string {
+	var value string
+	_ = json.Unmarshal(d[member], &value)
+
+	return value
+}
+
+// lastTxId returns the id of the transaction that last changed the did
+func (d storedDid) lastTxId() string {
+	provenance := struct {
+		Created *struct {
+			TxId string `json:"txId"`
+		} `json:"created"`
+		Updated *struct {
+			TxId string `json:"txId"`
+		} `json:"updated"`
+	}{}
+	_ = json.Unmarshal(d["provenance"], &provenance)
+
+	if provenance.Updated != nil {
+		return provenance.Updated.TxId
+	}
+
+	if provenance.Created != nil {
+		return provenance.Created.TxId
+	}
+
+	return ""
+}
+
+// document returns the updatable members of the did, to which changes are applied
+// before signing the update payload
+func (d storedDid) document() map[string]interface{} {
+	document := map[string]interface{}{}
+
+	for _, member := range updatableMembers {
+		if value, ok := d[member]; ok {
+			document[member] = value
+		}
+	}
+
+	return document
+}
+
+// canonicalJSON returns value as JSON with object members ordered by key, the
+// form in which the chaincode verifies signed payloads
+func canonicalJSON(value interface{}) []byte {
+	valueAsBytes, err := json.Marshal(value)
+
+	if err != nil {
+		panic(err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(valueAsBytes))
+	decoder.UseNumber()
+
+	var generic interface{}
+
+	if err := decoder.Decode(&generic); err != nil {
+		panic(err)
+	}
+
+	if object, ok := generic.(map[string]interface{}); ok {
+		delete(object, "proof")
+	}
+
+	canonical, err := json.Marshal(generic)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return canonical
+}
+
+// signUpdate signs the update payload changing the did stored under didNumber to
+// document, as expected by UpdateDid, RotateKey, AddVerificationMethod,
+// RemoveVerificationMethod and DeactivateDid
+func signUpdate(key ed25519.PrivateKey, didNumber string, did storedDid, document map[string]interface{}) string {
+	payload := map[string]interface{}{
+		"didNumber":    didNumber,
+		"previousTxId": did.lastTxId(),
+		"document":     document,
+	}
+
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(key, canonicalJSON(payload)))
+}
+
+// signJws returns a detached EdDSA JWS over the canonical JSON of document
+func signJws(key ed25519.PrivateKey, document interface{}) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA","b64":false,"crit":["b64"]}`))
+	signingInput := append([]byte(header+"."), canonicalJSON(document)...)
+
+	return header + ".." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, signingInput))
+}
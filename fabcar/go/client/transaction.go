@@ -0,0 +1,92 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+const maxAttempts = 3
+
+// submit submits a transaction, retrying with backoff while it fails for reasons
+// that may be transient
+func submit(contract *client.Contract, name string, options ...client.ProposalOption) ([]byte, error) {
+	var result []byte
+	var err error
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		fmt.Printf("\n--> Submit Transaction: %s\n", name)
+
+		result, err = contract.Submit(name, options...)
+
+		if err == nil {
+			fmt.Printf("*** Transaction committed successfully\n")
+			return result, nil
+		}
+
+		if !connection.IsTransient(err) || attempt == maxAttempts {
+			break
+		}
+
+		backoff := time.Duration(attempt) * time.Second
+		fmt.Printf("*** Attempt %d failed, retrying in %s: %v\n", attempt, backoff, err)
+		time.Sleep(backoff)
+	}
+
+	printTransactionError(err)
+
+	return nil, err
+}
+
+// evaluate evaluates a transaction, printing its result or error
+func evaluate(contract *client.Contract, name string, args ...string) ([]byte, error) {
+	fmt.Printf("\n--> Evaluate Transaction: %s\n", name)
+
+	result, err := contract.EvaluateTransaction(name, args...)
+
+	if err != nil {
+		printTransactionError(err)
+		return nil, err
+	}
+
+	fmt.Printf("*** Result: %s\n", result)
+
+	return result, nil
+}
+
+// printTransactionError describes at which stage a transaction failed and the
+// errors returned by the peers
+func printTransactionError(err error) {
+	var endorseErr *client.EndorseError
+	var submitErr *client.SubmitError
+	var commitStatusErr *client.CommitStatusError
+	var commitErr *client.CommitError
+
+	switch {
+	case errors.As(err, &endorseErr):
+		fmt.Printf("*** Endorsement failed for transaction %s: %v\n", endorseErr.TransactionID, endorseErr)
+	case errors.As(err, &submitErr):
+		fmt.Printf("*** Submit to orderer failed for transaction %s: %v\n", submitErr.TransactionID, submitErr)
+	case errors.As(err, &commitStatusErr):
+		fmt.Printf("*** Failed to obtain commit status of transaction %s: %v\n", commitStatusErr.TransactionID, commitStatusErr)
+	case errors.As(err, &commitErr):
+		fmt.Printf("*** Transaction %s failed to commit with status %s\n", commitErr.TransactionID, commitErr.Code)
+	default:
+		fmt.Printf("*** Transaction failed: %v\n", err)
+	}
+
+	if chaincodeErr := connection.ChaincodeErrorOf(err); chaincodeErr != nil {
+		fmt.Printf("*** Chaincode error %s: %s\n", chaincodeErr.Code, chaincodeErr.Message)
+	}
+
+	for _, detail := range connection.ErrorDetails(err) {
+		fmt.Printf("    - %s\n", detail)
+	}
+}
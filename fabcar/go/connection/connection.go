@@ -0,0 +1,340 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package connection connects the fabcar Go applications to a Fabric Gateway
+// peer described by a connection profile, using an identity read from an MSP
+// directory.
+package connection
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-gateway/pkg/hash"
+	"github.com/hyperledger/fabric-gateway/pkg/identity"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+const org1Path = "../../test-network/organizations/peerOrganizations/org1.example.com"
+
+// Names of the contracts of the fabcar chaincode
+const (
+	DidContract        = "did"
+	CredentialContract = "credential"
+	AdminContract      = "admin"
+	PolicyContract     = "policy"
+	ConsentContract    = "consent"
+	SharingContract    = "sharing"
+)
+
+// Config describes the network, identity and chaincode an application connects to
+type Config struct {
+	// ConnectionProfile is the path of the JSON connection profile of the organization
+	ConnectionProfile string
+	// Peer names the peer of the connection profile to connect to. The first
+	// peer of the organization is used when empty
+	Peer string
+	// MspDir is the MSP directory holding the signcerts and keystore of the client identity
+	MspDir        string
+	ChannelName   string
+	ChaincodeName string
+}
+
+// ConfigFromEnv returns the configuration given by the FABRIC_CONNECTION_PROFILE,
+// FABRIC_PEER, FABRIC_MSP_DIR, CHANNEL_NAME and CHAINCODE_NAME environment
+// variables, defaulting to User1 of Org1 in the test network
+func ConfigFromEnv() Config {
+	return Config{
+		ConnectionProfile: envOrDefault("FABRIC_CONNECTION_PROFILE", filepath.Join(org1Path, "connection-org1.json")),
+		Peer:              os.Getenv("FABRIC_PEER"),
+		MspDir:            envOrDefault("FABRIC_MSP_DIR", filepath.Join(org1Path, "users", "User1@org1.example.com", "msp")),
+		ChannelName:       envOrDefault("CHANNEL_NAME", "mychannel"),
+		ChaincodeName:     envOrDefault("CHAINCODE_NAME", "fabcar"),
+	}
+}
+
+func envOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// profile is the subset of a connection profile needed to reach a peer
+type profile struct {
+	Client struct {
+		Organization string `json:"organization"`
+	} `json:"client"`
+	Organizations map[string]struct {
+		MspID                  string   `json:"mspid"`
+		Peers                  []string `json:"peers"`
+		CertificateAuthorities []string `json:"certificateAuthorities"`
+	} `json:"organizations"`
+	Peers map[string]struct {
+		URL        string `json:"url"`
+		TLSCACerts struct {
+			Pem  string `json:"pem"`
+			Path string `json:"path"`
+		} `json:"tlsCACerts"`
+		GRPCOptions struct {
+			SSLTargetNameOverride string `json:"ssl-target-name-override"`
+		} `json:"grpcOptions"`
+	} `json:"peers"`
+	CertificateAuthorities map[string]struct {
+		URL        string `json:"url"`
+		CAName     string `json:"caName"`
+		TLSCACerts struct {
+			Pem  string `json:"pem"`
+			Path string `json:"path"`
+		} `json:"tlsCACerts"`
+	} `json:"certificateAuthorities"`
+}
+
+// CertificateAuthority describes a Fabric CA of the client organization as
+// given in the connection profile
+type CertificateAuthority struct {
+	URL       string
+	CAName    string
+	TLSCACert *x509.Certificate
+}
+
+// Connection is an open gateway connection together with the network selected
+// by its configuration and the did contract of its chaincode
+type Connection struct {
+	Gateway       *client.Gateway
+	Network       *client.Network
+	Contract      *client.Contract
+	ChaincodeName string
+	MspID         string
+
+	clientConnection *grpc.ClientConn
+}
+
+// Connect opens a gateway connection to the peer selected by the configuration
+func Connect(config Config) (*Connection, error) {
+	p, err := readProfile(config.ConnectionProfile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	organization, ok := p.Organizations[p.Client.Organization]
+
+	if !ok {
+		return nil, fmt.Errorf("connection profile does not describe client organization %q", p.Client.Organization)
+	}
+
+	peerName := config.Peer
+
+	if peerName == "" {
+		if len(organization.Peers) == 0 {
+			return nil, fmt.Errorf("organization %s has no peers in the connection profile", p.Client.Organization)
+		}
+
+		peerName = organization.Peers[0]
+	}
+
+	peer, ok := p.Peers[peerName]
+
+	if !ok {
+		return nil, fmt.Errorf("connection profile does not describe peer %s", peerName)
+	}
+
+	tlsCACert, err := readTLSCACert(peer.TLSCACerts.Pem, peer.TLSCACerts.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(tlsCACert)
+
+	serverName := peer.GRPCOptions.SSLTargetNameOverride
+
+	if serverName == "" {
+		serverName = peerName
+	}
+
+	target := strings.TrimPrefix(strings.TrimPrefix(peer.URL, "grpcs://"), "grpc://")
+	transportCredentials := credentials.NewClientTLSFromCert(certPool, serverName)
+
+	clientConnection, err := grpc.NewClient(target, grpc.WithTransportCredentials(transportCredentials))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create gRPC connection to %s: %w", target, err)
+	}
+
+	id, sign, err := readIdentity(organization.MspID, config.MspDir)
+
+	if err != nil {
+		clientConnection.Close()
+		return nil, err
+	}
+
+	gateway, err := client.Connect(
+		id,
+		client.WithSign(sign),
+		client.WithHash(hash.SHA256),
+		client.WithClientConnection(clientConnection),
+		client.WithEvaluateTimeout(5*time.Second),
+		client.WithEndorseTimeout(15*time.Second),
+		client.WithSubmitTimeout(5*time.Second),
+		client.WithCommitStatusTimeout(1*time.Minute),
+	)
+
+	if err != nil {
+		clientConnection.Close()
+		return nil, fmt.Errorf("failed to connect to gateway: %w", err)
+	}
+
+	network := gateway.GetNetwork(config.ChannelName)
+
+	connection := Connection{
+		Gateway:          gateway,
+		Network:          network,
+		Contract:         network.GetContractWithName(config.ChaincodeName, DidContract),
+		ChaincodeName:    config.ChaincodeName,
+		MspID:            organization.MspID,
+		clientConnection: clientConnection,
+	}
+
+	return &connection, nil
+}
+
+// ReadCertificateAuthority returns the first certificate authority of the
+// client organization in the connection profile of the configuration
+func ReadCertificateAuthority(config Config) (*CertificateAuthority, error) {
+	p, err := readProfile(config.ConnectionProfile)
+
+	if err != nil {
+		return nil, err
+	}
+
+	organization, ok := p.Organizations[p.Client.Organization]
+
+	if !ok {
+		return nil, fmt.Errorf("connection profile does not describe client organization %q", p.Client.Organization)
+	}
+
+	if len(organization.CertificateAuthorities) == 0 {
+		return nil, fmt.Errorf("organization %s has no certificate authorities in the connection profile", p.Client.Organization)
+	}
+
+	name := organization.CertificateAuthorities[0]
+	ca, ok := p.CertificateAuthorities[name]
+
+	if !ok {
+		return nil, fmt.Errorf("connection profile does not describe certificate authority %s", name)
+	}
+
+	tlsCACert, err := readTLSCACert(ca.TLSCACerts.Pem, ca.TLSCACerts.Path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CertificateAuthority{URL: ca.URL, CAName: ca.CAName, TLSCACert: tlsCACert}, nil
+}
+
+// Close closes the gateway and its gRPC connection
+func (c *Connection) Close() {
+	c.Gateway.Close()
+	c.clientConnection.Close()
+}
+
+func readProfile(path string) (*profile, error) {
+	profileAsBytes, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read connection profile: %w", err)
+	}
+
+	p := new(profile)
+
+	if err := json.Unmarshal(profileAsBytes, p); err != nil {
+		return nil, fmt.Errorf("failed to parse connection profile %s: %w", path, err)
+	}
+
+	return p, nil
+}
+
+func readTLSCACert(pemValue string, path string) (*x509.Certificate, error) {
+	if pemValue == "" {
+		pemAsBytes, err := os.ReadFile(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read peer TLS CA certificate: %w", err)
+		}
+
+		pemValue = string(pemAsBytes)
+	}
+
+	return identity.CertificateFromPEM([]byte(pemValue))
+}
+
+// readIdentity reads the certificate and private key of the client identity from
+// the first file of the signcerts and keystore folders of an MSP directory
+func readIdentity(mspID string, mspDir string) (*identity.X509Identity, identity.Sign, error) {
+	certificatePEM, err := readFirstFile(filepath.Join(mspDir, "signcerts"))
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read certificate: %w", err)
+	}
+
+	certificate, err := identity.CertificateFromPEM(certificatePEM)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	id, err := identity.NewX509Identity(mspID, certificate)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privateKeyPEM, err := readFirstFile(filepath.Join(mspDir, "keystore"))
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	privateKey, err := identity.PrivateKeyFromPEM(privateKeyPEM)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sign, err := identity.NewPrivateKeySign(privateKey)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return id, sign, nil
+}
+
+func readFirstFile(dir string) ([]byte, error) {
+	entries, err := os.ReadDir(dir)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			return os.ReadFile(filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	return nil, fmt.Errorf("no files in %s", dir)
+}
@@ -0,0 +1,107 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package connection
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/gateway"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrorDetails returns the messages returned by the peers that failed to endorse
+// or evaluate a transaction, which carry the error raised by the chaincode
+func ErrorDetails(err error) []string {
+	messages := []string{}
+	grpcStatus, ok := status.FromError(err)
+
+	if !ok {
+		return messages
+	}
+
+	for _, detail := range grpcStatus.Details() {
+		if errorDetail, ok := detail.(*gateway.ErrorDetail); ok {
+			messages = append(messages, errorDetail.GetMessage())
+		}
+	}
+
+	return messages
+}
+
+// Codes of the errors returned by the fabcar chaincode
+const (
+	CodeInvalidArgument   = "INVALID_ARGUMENT"
+	CodeDidNotFound       = "DID_NOT_FOUND"
+	CodeDidAlreadyExists  = "DID_ALREADY_EXISTS"
+	CodeDidDeactivated    = "DID_DEACTIVATED"
+	CodeNotFound          = "NOT_FOUND"
+	CodeAlreadyExists     = "ALREADY_EXISTS"
+	CodeUnauthorized      = "UNAUTHORIZED"
+	CodeInvalidSignature  = "INVALID_SIGNATURE"
+	CodeChallengeExpired  = "CHALLENGE_EXPIRED"
+	CodeConflict          = "CONFLICT"
+	CodeCorruptRecord     = "CORRUPT_RECORD"
+	CodeBatchRejected     = "BATCH_REJECTED"
+	CodeQuotaExceeded     = "QUOTA_EXCEEDED"
+	CodeFeeRejected       = "FEE_REJECTED"
+	CodeUnknownFunction   = "UNKNOWN_FUNCTION"
+	CodeNotInitialized    = "NOT_INITIALIZED"
+	CodeTransactionFailed = "TRANSACTION_FAILED"
+)
+
+// ChaincodeError is an error raised by the fabcar chaincode, which encodes it as
+// JSON in the message of its response
+type ChaincodeError struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+func (e *ChaincodeError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// ChaincodeErrorOf returns the error raised by the chaincode for a failed
+// transaction, or nil if no peer returned one
+func ChaincodeErrorOf(err error) *ChaincodeError {
+	for _, message := range ErrorDetails(err) {
+		start := strings.Index(message, "{")
+
+		if start < 0 {
+			continue
+		}
+
+		chaincodeErr := new(ChaincodeError)
+
+		if json.Unmarshal([]byte(message[start:]), chaincodeErr) == nil && chaincodeErr.Code != "" {
+			return chaincodeErr
+		}
+	}
+
+	return nil
+}
+
+// IsTransient reports whether a failed transaction may succeed when retried,
+// such as when endorsement timed out, the gateway was unavailable or the
+// transaction lost an MVCC read conflict
+func IsTransient(err error) bool {
+	var commitErr *client.CommitError
+
+	if errors.As(err, &commitErr) {
+		return commitErr.Code == peer.TxValidationCode_MVCC_READ_CONFLICT || commitErr.Code == peer.TxValidationCode_PHANTOM_READ_CONFLICT
+	}
+
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted:
+		return true
+	default:
+		return false
+	}
+}
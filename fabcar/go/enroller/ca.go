@@ -0,0 +1,174 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+// enrollment is the identity issued by a Fabric CA
+type enrollment struct {
+	PrivateKey  crypto.PrivateKey
+	Certificate []byte
+	CAChain     []byte
+}
+
+// enrollRequest is the body of the enroll request of the Fabric CA REST API
+type enrollRequest struct {
+	CertificateRequest string `json:"certificate_request"`
+	CAName             string `json:"caname,omitempty"`
+}
+
+// enrollResponse is the response of the enroll request. Cert and CAChain are
+// base64 encoded PEM
+type enrollResponse struct {
+	Success bool `json:"success"`
+	Result  struct {
+		Cert       string `json:"Cert"`
+		ServerInfo struct {
+			CAChain string `json:"CAChain"`
+		} `json:"ServerInfo"`
+	} `json:"result"`
+	Errors []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// enroll generates a P-256 key and enrolls it with the certificate authority
+// for the registered identity enrollmentID
+func enroll(ca *connection.CertificateAuthority, enrollmentID string, secret string) (*enrollment, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{Subject: pkix.Name{CommonName: enrollmentID}}, privateKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate request: %w", err)
+	}
+
+	body, err := json.Marshal(enrollRequest{
+		CertificateRequest: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})),
+		CAName:             ca.CAName,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(ca.URL, "/")+"/api/v1/enroll", bytes.NewReader(body))
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+	request.SetBasicAuth(enrollmentID, secret)
+
+	certPool := x509.NewCertPool()
+	certPool.AddCert(ca.TLSCACert)
+
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: certPool}},
+	}
+
+	response, err := httpClient.Do(request)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to send enroll request: %w", err)
+	}
+	defer response.Body.Close()
+
+	result := new(enrollResponse)
+
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("failed to parse enroll response (HTTP %d): %w", response.StatusCode, err)
+	}
+
+	if !result.Success {
+		messages := make([]string, 0, len(result.Errors))
+
+		for _, e := range result.Errors {
+			messages = append(messages, fmt.Sprintf("%s (code %d)", e.Message, e.Code))
+		}
+
+		return nil, fmt.Errorf("certificate authority rejected enrollment of %s: %s", enrollmentID, strings.Join(messages, "; "))
+	}
+
+	certificate, err := base64.StdEncoding.DecodeString(result.Result.Cert)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode certificate: %w", err)
+	}
+
+	caChain, err := base64.StdEncoding.DecodeString(result.Result.ServerInfo.CAChain)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode CA chain: %w", err)
+	}
+
+	return &enrollment{PrivateKey: privateKey, Certificate: certificate, CAChain: caChain}, nil
+}
+
+// writeMsp writes the enrolled identity to the signcerts, keystore and cacerts
+// folders of a new MSP directory
+func writeMsp(mspDir string, e *enrollment) error {
+	if entries, err := os.ReadDir(mspDir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("MSP directory %s is not empty", mspDir)
+	}
+
+	privateKey, err := x509.MarshalPKCS8PrivateKey(e.PrivateKey)
+
+	if err != nil {
+		return fmt.Errorf("failed to encode private key: %w", err)
+	}
+
+	files := []struct {
+		dir      string
+		name     string
+		contents []byte
+		mode     os.FileMode
+	}{
+		{"signcerts", "cert.pem", e.Certificate, 0644},
+		{"keystore", "key.pem", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateKey}), 0600},
+		{"cacerts", "ca.pem", e.CAChain, 0644},
+	}
+
+	for _, file := range files {
+		dir := filepath.Join(mspDir, file.dir)
+
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(dir, file.name), file.contents, file.mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
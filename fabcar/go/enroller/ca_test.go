@@ -0,0 +1,114 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCA starts a Fabric CA stub that enrolls user1 with secret user1pw and
+// echoes the PEM certificate request as the issued certificate
+func newTestCA(t *testing.T) *connection.CertificateAuthority {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response := new(enrollResponse)
+
+		request := new(enrollRequest)
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(request))
+
+		if user, secret, ok := r.BasicAuth(); r.URL.Path != "/api/v1/enroll" || request.CAName != "ca-org1" || !ok || user != "user1" || secret != "user1pw" {
+			response.Errors = append(response.Errors, struct {
+				Code    int    `json:"code"`
+				Message string `json:"message"`
+			}{20, "Authentication failure"})
+
+			w.WriteHeader(http.StatusUnauthorized)
+		} else {
+			response.Success = true
+			response.Result.Cert = base64.StdEncoding.EncodeToString([]byte(request.CertificateRequest))
+			response.Result.ServerInfo.CAChain = base64.StdEncoding.EncodeToString([]byte("ca chain"))
+		}
+
+		assert.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return &connection.CertificateAuthority{URL: server.URL + "/", CAName: "ca-org1", TLSCACert: server.Certificate()}
+}
+
+func TestEnroll(t *testing.T) {
+	ca := newTestCA(t)
+
+	e, err := enroll(ca, "user1", "user1pw")
+	require.NoError(t, err, "should enroll the registered identity")
+	assert.Equal(t, []byte("ca chain"), e.CAChain, "should decode the CA chain")
+
+	block, _ := pem.Decode(e.Certificate)
+	require.NotNil(t, block, "should decode the certificate")
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	require.NoError(t, err)
+	assert.Equal(t, "user1", csr.Subject.CommonName, "should request a certificate for the enrollment id")
+	assert.NoError(t, csr.CheckSignature(), "should sign the certificate request")
+
+	privateKey, ok := e.PrivateKey.(*ecdsa.PrivateKey)
+	require.True(t, ok, "should generate an ECDSA key")
+	assert.Equal(t, elliptic.P256(), privateKey.Curve, "should generate a P-256 key")
+	assert.True(t, privateKey.PublicKey.Equal(csr.PublicKey), "should request a certificate for the generated key")
+
+	_, err = enroll(ca, "user1", "wrong")
+	assert.EqualError(t, err, "certificate authority rejected enrollment of user1: Authentication failure (code 20)")
+}
+
+func TestWriteMsp(t *testing.T) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	e := &enrollment{PrivateKey: privateKey, Certificate: []byte("certificate"), CAChain: []byte("ca chain")}
+	mspDir := filepath.Join(t.TempDir(), "msp")
+
+	require.NoError(t, writeMsp(mspDir, e), "should write a new MSP directory")
+
+	certificate, err := os.ReadFile(filepath.Join(mspDir, "signcerts", "cert.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, e.Certificate, certificate)
+
+	caChain, err := os.ReadFile(filepath.Join(mspDir, "cacerts", "ca.pem"))
+	require.NoError(t, err)
+	assert.Equal(t, e.CAChain, caChain)
+
+	keyFile := filepath.Join(mspDir, "keystore", "key.pem")
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "should only let the owner read the private key")
+
+	keyPEM, err := os.ReadFile(keyFile)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(keyPEM)
+	require.NotNil(t, block)
+	assert.Equal(t, "PRIVATE KEY", block.Type)
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	assert.True(t, privateKey.Equal(key), "should write the enrolled key")
+
+	err = writeMsp(mspDir, e)
+	assert.EqualError(t, err, "MSP directory "+mspDir+" is not empty", "should not overwrite an existing MSP")
+}
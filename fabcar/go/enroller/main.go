@@ -0,0 +1,78 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command enroller onboards a client in a single operation: it enrolls an
+// identity registered with the Fabric CA of the organization, writes it to a new
+// MSP directory, creates a did from the key of the issued certificate with
+// CreateDidFromCertificate and binds the enrollment id to the did with
+// RegisterIdentityBinding. The CA is the first certificate authority of the
+// organization in the connection profile.
+//
+//	go run ./enroller -id user2 -secret user2pw -msp-dir user2/msp [-endpoint https://example.com/vc/]
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+func main() {
+	enrollmentID := flag.String("id", "", "enrollment id of the identity registered with the CA")
+	secret := flag.String("secret", "", "enrollment secret of the identity")
+	mspDir := flag.String("msp-dir", "", "new MSP directory the enrolled identity is written to")
+	serviceID := flag.String("service-id", "#vcs", "id of the service of the did")
+	serviceType := flag.String("service-type", "VerifiableCredentialService", "type of the service of the did")
+	endpoint := flag.String("endpoint", "https://example.com/vc/", "service endpoint of the did")
+	expires := flag.String("expires", "", "RFC 3339 expiry of the did, none when empty")
+	flag.Parse()
+
+	if *enrollmentID == "" || *secret == "" || *mspDir == "" {
+		log.Fatalf("The -id, -secret and -msp-dir flags are required")
+	}
+
+	config := connection.ConfigFromEnv()
+
+	ca, err := connection.ReadCertificateAuthority(config)
+
+	if err != nil {
+		log.Fatalf("Failed to read certificate authority: %v", err)
+	}
+
+	e, err := enroll(ca, *enrollmentID, *secret)
+
+	if err != nil {
+		log.Fatalf("Failed to enroll %s: %v", *enrollmentID, err)
+	}
+
+	if err := writeMsp(*mspDir, e); err != nil {
+		log.Fatalf("Failed to write MSP directory: %v", err)
+	}
+
+	log.Printf("Enrolled %s into %s", *enrollmentID, *mspDir)
+
+	config.MspDir = *mspDir
+	conn, err := connection.Connect(config)
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	didID, err := conn.Contract.Submit("CreateDidFromCertificate", client.WithArguments(*serviceID, *serviceType, *endpoint, *expires))
+
+	if err != nil {
+		log.Fatalf("Failed to create did of %s: %v", *enrollmentID, err)
+	}
+
+	log.Printf("Created did %s", didID)
+
+	if _, err := conn.Contract.SubmitTransaction("RegisterIdentityBinding", string(didID)); err != nil {
+		log.Fatalf("Failed to bind %s to did %s: %v", *enrollmentID, didID, err)
+	}
+
+	log.Printf("Bound %s of %s to did %s", *enrollmentID, conn.MspID, didID)
+}
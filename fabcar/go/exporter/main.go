@@ -0,0 +1,175 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command exporter writes a snapshot of every did in the fabcar chaincode,
+// assembled from the pages of ExportAllDids, for backup or migration. The
+// snapshot records the format version, the channel and chaincode it was read
+// from and a SHA-256 checksum of its dids, so a restore can check that the file
+// is complete and unchanged.
+//
+//	go run ./exporter [-page-size 100] [-o dids-snapshot.json]
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+// snapshotVersion is the version of the export page format the exporter reads
+const snapshotVersion = "1"
+
+// record is a did as exported, kept as returned by the chaincode
+type record struct {
+	Key    string          `json:"Key"`
+	Record json.RawMessage `json:"Record"`
+}
+
+// page is a page returned by ExportAllDids
+type page struct {
+	Version    string   `json:"version"`
+	Channel    string   `json:"channel"`
+	Chaincode  string   `json:"chaincode"`
+	ExportedAt string   `json:"exportedAt"`
+	Records    []record `json:"records"`
+	Bookmark   string   `json:"bookmark"`
+}
+
+// snapshot is the exported registry. ExportedAt and CompletedAt are the times the
+// first and last pages were read
+type snapshot struct {
+	Version     string   `json:"version"`
+	Channel     string   `json:"channel"`
+	Chaincode   string   `json:"chaincode"`
+	ExportedAt  string   `json:"exportedAt"`
+	CompletedAt string   `json:"completedAt"`
+	Count       int      `json:"count"`
+	Checksum    string   `json:"checksum"`
+	Dids        []record `json:"dids"`
+}
+
+// export reads every page of ExportAllDids and assembles them into a snapshot
+func export(contract *client.Contract, pageSize int) (*snapshot, error) {
+	s := &snapshot{Dids: []record{}}
+	bookmark := ""
+
+	for {
+		result, err := contract.EvaluateTransaction("ExportAllDids", strconv.Itoa(pageSize), bookmark)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to export page: %w", err)
+		}
+
+		p := new(page)
+
+		if err := json.Unmarshal(result, p); err != nil {
+			return nil, fmt.Errorf("failed to parse page: %w", err)
+		}
+
+		if p.Version != snapshotVersion {
+			return nil, fmt.Errorf("unsupported export version %s, expected %s", p.Version, snapshotVersion)
+		}
+
+		if s.Version == "" {
+			s.Version, s.Channel, s.Chaincode, s.ExportedAt = p.Version, p.Channel, p.Chaincode, p.ExportedAt
+		} else if p.Channel != s.Channel || p.Chaincode != s.Chaincode {
+			return nil, fmt.Errorf("page read from %s/%s, expected %s/%s", p.Channel, p.Chaincode, s.Channel, s.Chaincode)
+		}
+
+		s.CompletedAt = p.ExportedAt
+		s.Dids = append(s.Dids, p.Records...)
+
+		log.Printf("Exported %d dids", len(s.Dids))
+
+		if len(p.Records) < pageSize || p.Bookmark == "" {
+			break
+		}
+
+		bookmark = p.Bookmark
+	}
+
+	didsAsBytes, err := json.Marshal(s.Dids)
+
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := sha256.Sum256(didsAsBytes)
+
+	s.Count = len(s.Dids)
+	s.Checksum = "sha256:" + hex.EncodeToString(checksum[:])
+
+	return s, nil
+}
+
+// writeSnapshot writes a snapshot to a temporary file renamed into place, so that
+// an interrupted export does not leave a partial snapshot behind
+func writeSnapshot(name string, s *snapshot) error {
+	snapshotAsBytes, err := json.MarshalIndent(s, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	if name == "-" {
+		_, err := os.Stdout.Write(append(snapshotAsBytes, '\n'))
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(snapshotAsBytes); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+func main() {
+	pageSize := flag.Int("page-size", 100, "number of dids read in each query")
+	output := flag.String("o", "dids-snapshot.json", "file the snapshot is written to, or - for standard output")
+	flag.Parse()
+
+	if *pageSize < 1 {
+		log.Fatalf("Page size must be positive")
+	}
+
+	conn, err := connection.Connect(connection.ConfigFromEnv())
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	s, err := export(conn.Contract, *pageSize)
+
+	if err != nil {
+		log.Fatalf("Failed to export dids: %v", err)
+	}
+
+	if err := writeSnapshot(*output, s); err != nil {
+		log.Fatalf("Failed to write snapshot: %v", err)
+	}
+
+	log.Printf("Wrote %d dids from %s/%s to %s", s.Count, s.Channel, s.Chaincode, *output)
+}
@@ -0,0 +1,171 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// didDetails are the details of a new did, as accepted by BatchCreateDids
+type didDetails struct {
+	AuthenticationId            string `json:"authenticationId"`
+	AuthenticationType          string `json:"authenticationType"`
+	AuthenticationController    string `json:"authenticationController"`
+	AuthenticationPublicKeyPerm string `json:"authenticationPublicKeyPerm"`
+	ServiceId                   string `json:"serviceId"`
+	ServiceType                 string `json:"serviceType"`
+	ServiceEndPoint             string `json:"serviceEndPoint"`
+}
+
+// document is a did document read from the import source
+type document struct {
+	Source  string          `json:"source"`
+	Raw     json.RawMessage `json:"document"`
+	Details *didDetails     `json:"-"`
+}
+
+// readDocuments reads the documents of a directory of .json files, each holding a
+// document or an array of documents, or of an NDJSON file with a document per line
+func readDocuments(path string) ([]document, error) {
+	info, err := os.Stat(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return readNDJSON(path)
+	}
+
+	names, err := filepath.Glob(filepath.Join(path, "*.json"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+
+	documents := []document{}
+
+	for _, name := range names {
+		fileDocuments, err := readJSONFile(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		documents = append(documents, fileDocuments...)
+	}
+
+	return documents, nil
+}
+
+func readJSONFile(name string) ([]document, error) {
+	content, err := os.ReadFile(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	content = bytes.TrimSpace(content)
+
+	if !bytes.HasPrefix(content, []byte("[")) {
+		return []document{{Source: name, Raw: content}}, nil
+	}
+
+	items := []json.RawMessage{}
+
+	if err := json.Unmarshal(content, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+
+	documents := []document{}
+
+	for i, item := range items {
+		documents = append(documents, document{Source: fmt.Sprintf("%s[%d]", name, i), Raw: item})
+	}
+
+	return documents, nil
+}
+
+func readNDJSON(name string) ([]document, error) {
+	file, err := os.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	documents := []document{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		content := bytes.TrimSpace(scanner.Bytes())
+
+		if len(content) == 0 {
+			continue
+		}
+
+		documents = append(documents, document{Source: fmt.Sprintf("%s:%d", name, line), Raw: append([]byte{}, content...)})
+	}
+
+	return documents, scanner.Err()
+}
+
+// validate decodes the details of a document and checks them before they are
+// submitted, so that a batch is not rejected for errors found locally
+func (d *document) validate() error {
+	decoder := json.NewDecoder(bytes.NewReader(d.Raw))
+	decoder.DisallowUnknownFields()
+
+	details := new(didDetails)
+
+	if err := decoder.Decode(details); err != nil {
+		return fmt.Errorf("invalid document: %w", err)
+	}
+
+	if details.AuthenticationType == "" {
+		return errors.New("authenticationType must be set")
+	}
+
+	block, _ := pem.Decode([]byte(details.AuthenticationPublicKeyPerm))
+
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return errors.New("authenticationPublicKeyPerm must be a PEM encoded public key")
+	}
+
+	if details.ServiceEndPoint != "" {
+		endpoint, err := url.Parse(details.ServiceEndPoint)
+
+		if err != nil || !endpoint.IsAbs() {
+			return fmt.Errorf("serviceEndPoint %s must be an absolute URL", details.ServiceEndPoint)
+		}
+	}
+
+	if details.ServiceId != "" && details.ServiceType == "" {
+		return errors.New("serviceType must be set with serviceId")
+	}
+
+	d.Details = details
+
+	return nil
+}
+
+// publicKey returns the public key of a validated document, which determines the
+// identifier generated for it
+func (d *document) publicKey() string {
+	return strings.TrimSpace(d.Details.AuthenticationPublicKeyPerm)
+}
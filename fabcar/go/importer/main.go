@@ -0,0 +1,215 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command importer creates dids in bulk from a directory of .json files or an
+// NDJSON file of did details, as accepted by CreateDid. Documents are validated
+// locally, then submitted with BatchCreateDids in batches. When the chaincode
+// rejects a batch, the items it reports as failing are left out and the rest of
+// the batch is submitted again. Documents that could not be imported are written
+// with their errors to the failure report, one JSON object per line.
+//
+//	go run ./importer [-batch-size 50] [-failures import-failures.ndjson] <path>
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+const maxAttempts = 3
+
+// failure is a line of the failure report
+type failure struct {
+	Source   string          `json:"source"`
+	Error    string          `json:"error"`
+	Document json.RawMessage `json:"document"`
+}
+
+// importer submits batches of documents and collects the failures
+type importer struct {
+	contract *client.Contract
+	imported int
+	failures []failure
+}
+
+func (i *importer) fail(d document, err error) {
+	i.failures = append(i.failures, failure{Source: d.Source, Error: err.Error(), Document: d.Raw})
+}
+
+// submit submits a batch, retrying with backoff while it fails for reasons that
+// may be transient
+func (i *importer) submit(batch []document) ([]byte, error) {
+	items := []*didDetails{}
+
+	for _, d := range batch {
+		items = append(items, d.Details)
+	}
+
+	itemsAsBytes, err := json.Marshal(items)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		result, err := i.contract.Submit("BatchCreateDids", client.WithArguments(string(itemsAsBytes)))
+
+		if err == nil || !connection.IsTransient(err) || attempt == maxAttempts {
+			return result, err
+		}
+
+		backoff := time.Duration(attempt) * time.Second
+		log.Printf("Batch attempt %d failed, retrying in %s: %v", attempt, backoff, err)
+		time.Sleep(backoff)
+	}
+}
+
+// importBatch submits a batch until it is committed or none of its remaining
+// items can be attributed a failure
+func (i *importer) importBatch(batch []document) {
+	for len(batch) > 0 {
+		_, err := i.submit(batch)
+
+		if err == nil {
+			i.imported += len(batch)
+			return
+		}
+
+		failed := batchFailures(err)
+
+		remaining := []document{}
+
+		for index, d := range batch {
+			if message, ok := failed[index]; ok {
+				i.fail(d, errors.New(message))
+			} else {
+				remaining = append(remaining, d)
+			}
+		}
+
+		if len(remaining) == len(batch) {
+			for _, d := range batch {
+				i.fail(d, err)
+			}
+
+			return
+		}
+
+		batch = remaining
+	}
+}
+
+// batchFailures returns the failure messages of the items of a rejected batch by
+// their index in the batch
+func batchFailures(err error) map[int]string {
+	failed := map[int]string{}
+	chaincodeErr := connection.ChaincodeErrorOf(err)
+
+	if chaincodeErr == nil || chaincodeErr.Code != connection.CodeBatchRejected {
+		return failed
+	}
+
+	for item, message := range chaincodeErr.Details {
+		if index, err := strconv.Atoi(item); err == nil {
+			failed[index] = message
+		}
+	}
+
+	return failed
+}
+
+func writeFailures(name string, failures []failure) error {
+	file, err := os.Create(name)
+
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+
+	for _, f := range failures {
+		if err := encoder.Encode(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func main() {
+	batchSize := flag.Int("batch-size", 50, "number of dids submitted in each transaction")
+	failuresFile := flag.String("failures", "import-failures.ndjson", "file the failed documents are reported to")
+	flag.Parse()
+
+	if flag.NArg() != 1 || *batchSize < 1 {
+		fmt.Fprintln(os.Stderr, "usage: importer [-batch-size n] [-failures file] <directory or NDJSON file>")
+		os.Exit(2)
+	}
+
+	documents, err := readDocuments(flag.Arg(0))
+
+	if err != nil {
+		log.Fatalf("Failed to read documents: %v", err)
+	}
+
+	conn, err := connection.Connect(connection.ConfigFromEnv())
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	i := &importer{contract: conn.Contract}
+	valid := []document{}
+	sources := map[string]string{}
+
+	for _, d := range documents {
+		if err := d.validate(); err != nil {
+			i.fail(d, err)
+			continue
+		}
+
+		if source, ok := sources[d.publicKey()]; ok {
+			i.fail(d, fmt.Errorf("same authentication key as %s", source))
+			continue
+		}
+
+		sources[d.publicKey()] = d.Source
+		valid = append(valid, d)
+	}
+
+	log.Printf("Read %d documents, %d failed validation", len(documents), len(i.failures))
+
+	for start := 0; start < len(valid); start += *batchSize {
+		end := min(start+*batchSize, len(valid))
+
+		i.importBatch(valid[start:end])
+
+		log.Printf("Processed %d/%d documents: %d imported, %d failed", end, len(valid), i.imported, len(i.failures))
+	}
+
+	if len(i.failures) == 0 {
+		log.Printf("Imported %d dids", i.imported)
+		return
+	}
+
+	conn.Close()
+
+	if err := writeFailures(*failuresFile, i.failures); err != nil {
+		log.Fatalf("Failed to write failure report: %v", err)
+	}
+
+	log.Printf("Imported %d dids, %d failures reported to %s", i.imported, len(i.failures), *failuresFile)
+	os.Exit(1)
+}
@@ -0,0 +1,117 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package index
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// BlockEvents returns the chaincode events set by the valid endorser
+// transactions of chaincodeName in a block, in block order, as they are
+// delivered to chaincode event listeners
+func BlockEvents(block *common.Block, chaincodeName string) ([]*client.ChaincodeEvent, error) {
+	blockNumber := block.GetHeader().GetNumber()
+	validationCodes := block.GetMetadata().GetMetadata()[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	events := []*client.ChaincodeEvent{}
+
+	for i, envelopeBytes := range block.GetData().GetData() {
+		if i < len(validationCodes) && peer.TxValidationCode(validationCodes[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+
+		envelope := new(common.Envelope)
+
+		if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+			return nil, fmt.Errorf("block %d transaction %d: %w", blockNumber, i, err)
+		}
+
+		payload := new(common.Payload)
+
+		if err := proto.Unmarshal(envelope.GetPayload(), payload); err != nil {
+			return nil, fmt.Errorf("block %d transaction %d: %w", blockNumber, i, err)
+		}
+
+		channelHeader := new(common.ChannelHeader)
+
+		if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader); err != nil {
+			return nil, fmt.Errorf("block %d transaction %d: %w", blockNumber, i, err)
+		}
+
+		if common.HeaderType(channelHeader.GetType()) != common.HeaderType_ENDORSER_TRANSACTION {
+			continue
+		}
+
+		txEvents, err := transactionEvents(payload.GetData(), chaincodeName)
+
+		if err != nil {
+			return nil, fmt.Errorf("block %d transaction %s: %w", blockNumber, channelHeader.GetTxId(), err)
+		}
+
+		for _, event := range txEvents {
+			events = append(events, &client.ChaincodeEvent{
+				BlockNumber:   blockNumber,
+				TransactionID: channelHeader.GetTxId(),
+				ChaincodeName: event.GetChaincodeId(),
+				EventName:     event.GetEventName(),
+				Payload:       event.GetPayload(),
+			})
+		}
+	}
+
+	return events, nil
+}
+
+// transactionEvents returns the events of chaincodeName in the actions of an
+// endorser transaction
+func transactionEvents(transactionBytes []byte, chaincodeName string) ([]*peer.ChaincodeEvent, error) {
+	transaction := new(peer.Transaction)
+
+	if err := proto.Unmarshal(transactionBytes, transaction); err != nil {
+		return nil, err
+	}
+
+	events := []*peer.ChaincodeEvent{}
+
+	for _, action := range transaction.GetActions() {
+		actionPayload := new(peer.ChaincodeActionPayload)
+
+		if err := proto.Unmarshal(action.GetPayload(), actionPayload); err != nil {
+			return nil, err
+		}
+
+		responsePayload := new(peer.ProposalResponsePayload)
+
+		if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload); err != nil {
+			return nil, err
+		}
+
+		chaincodeAction := new(peer.ChaincodeAction)
+
+		if err := proto.Unmarshal(responsePayload.GetExtension(), chaincodeAction); err != nil {
+			return nil, err
+		}
+
+		if len(chaincodeAction.GetEvents()) == 0 {
+			continue
+		}
+
+		event := new(peer.ChaincodeEvent)
+
+		if err := proto.Unmarshal(chaincodeAction.GetEvents(), event); err != nil {
+			return nil, err
+		}
+
+		if event.GetChaincodeId() == chaincodeName && event.GetEventName() != "" {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
@@ -0,0 +1,102 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package index
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func marshal(t *testing.T, m proto.Message) []byte {
+	b, err := proto.Marshal(m)
+	require.NoError(t, err)
+
+	return b
+}
+
+// newTestEnvelope returns a transaction envelope of the header type whose
+// actions set the events, an action without event when nil
+func newTestEnvelope(t *testing.T, headerType common.HeaderType, txID string, events ...*peer.ChaincodeEvent) []byte {
+	transaction := new(peer.Transaction)
+
+	for _, event := range events {
+		chaincodeAction := new(peer.ChaincodeAction)
+
+		if event != nil {
+			chaincodeAction.Events = marshal(t, event)
+		}
+
+		actionPayload := &peer.ChaincodeActionPayload{
+			Action: &peer.ChaincodeEndorsedAction{
+				ProposalResponsePayload: marshal(t, &peer.ProposalResponsePayload{Extension: marshal(t, chaincodeAction)}),
+			},
+		}
+
+		transaction.Actions = append(transaction.Actions, &peer.TransactionAction{Payload: marshal(t, actionPayload)})
+	}
+
+	payload := &common.Payload{
+		Header: &common.Header{ChannelHeader: marshal(t, &common.ChannelHeader{Type: int32(headerType), TxId: txID})},
+		Data:   marshal(t, transaction),
+	}
+
+	return marshal(t, &common.Envelope{Payload: marshal(t, payload)})
+}
+
+// newTestBlock returns a block of the envelopes with their validation codes
+func newTestBlock(number uint64, envelopes [][]byte, validationCodes ...peer.TxValidationCode) *common.Block {
+	metadata := make([][]byte, len(common.BlockMetadataIndex_name))
+	transactionsFilter := make([]byte, len(validationCodes))
+
+	for i, code := range validationCodes {
+		transactionsFilter[i] = byte(code)
+	}
+
+	metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = transactionsFilter
+
+	return &common.Block{
+		Header:   &common.BlockHeader{Number: number},
+		Data:     &common.BlockData{Data: envelopes},
+		Metadata: &common.BlockMetadata{Metadata: metadata},
+	}
+}
+
+func TestBlockEvents(t *testing.T) {
+	created := &peer.ChaincodeEvent{ChaincodeId: "fabcar", TxId: "tx1", EventName: "did.created.CreateDid", Payload: []byte(`{"didNumber":"1"}`)}
+	updated := &peer.ChaincodeEvent{ChaincodeId: "fabcar", TxId: "tx4", EventName: "did.updated.UpdateDid", Payload: []byte(`{"didNumber":"1"}`)}
+	deleted := &peer.ChaincodeEvent{ChaincodeId: "fabcar", TxId: "tx4", EventName: "did.deleted.DeleteDid", Payload: []byte(`{"didNumber":"2"}`)}
+
+	block := newTestBlock(7, [][]byte{
+		newTestEnvelope(t, common.HeaderType_ENDORSER_TRANSACTION, "tx1", created),
+		newTestEnvelope(t, common.HeaderType_ENDORSER_TRANSACTION, "tx2", &peer.ChaincodeEvent{ChaincodeId: "fabcar", EventName: "did.updated.UpdateDid"}),
+		newTestEnvelope(t, common.HeaderType_ENDORSER_TRANSACTION, "tx3", &peer.ChaincodeEvent{ChaincodeId: "other", EventName: "did.created.CreateDid"}, nil),
+		newTestEnvelope(t, common.HeaderType_ENDORSER_TRANSACTION, "tx4", updated, &peer.ChaincodeEvent{ChaincodeId: "fabcar"}, deleted),
+		newTestEnvelope(t, common.HeaderType_CONFIG, "tx5"),
+	}, peer.TxValidationCode_VALID, peer.TxValidationCode_MVCC_READ_CONFLICT, peer.TxValidationCode_VALID, peer.TxValidationCode_VALID, peer.TxValidationCode_VALID)
+
+	events, err := BlockEvents(block, "fabcar")
+	require.NoError(t, err)
+	require.Len(t, events, 3, "should only return the named events of valid transactions of the chaincode")
+
+	for i, expected := range []*peer.ChaincodeEvent{created, updated, deleted} {
+		assert.Equal(t, uint64(7), events[i].BlockNumber)
+		assert.Equal(t, expected.TxId, events[i].TransactionID)
+		assert.Equal(t, "fabcar", events[i].ChaincodeName)
+		assert.Equal(t, expected.EventName, events[i].EventName, "should return the events in block order")
+		assert.Equal(t, expected.Payload, events[i].Payload)
+	}
+
+	events, err = BlockEvents(newTestBlock(8, nil), "fabcar")
+	require.NoError(t, err)
+	assert.Empty(t, events, "should accept a block without transactions")
+
+	_, err = BlockEvents(newTestBlock(9, [][]byte{[]byte("not an envelope")}), "fabcar")
+	assert.ErrorContains(t, err, "block 9 transaction 0", "should report the malformed transaction")
+}
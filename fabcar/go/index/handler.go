@@ -0,0 +1,51 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package index
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Names of the events applied to the index: the events of single dids are named
+// after the operation and did method, such as did.created.fabric
+const (
+	didEventPrefix   = "did."
+	didsCreatedEvent = "dids.created"
+	didsPurgedEvent  = "dids.purged"
+)
+
+// legacyDidEvents are the names of the did events emitted by chaincode versions
+// before events were named after the operation, found when replaying old blocks
+var legacyDidEvents = map[string]bool{"DidCreated": true, "DidUpdated": true, "DidDeactivated": true, legacyDidsCreatedEvent: true}
+
+const legacyDidsCreatedEvent = "DidsCreated"
+
+// IsDidEvent reports whether the event carries did documents for the index
+func IsDidEvent(name string) bool {
+	return strings.HasPrefix(name, didEventPrefix) || name == didsCreatedEvent || name == didsPurgedEvent || legacyDidEvents[name]
+}
+
+// Handler applies the did events of the chaincode to an off-chain index.
+// Applying an event again must leave the index unchanged
+type Handler interface {
+	Apply(event *client.ChaincodeEvent) error
+}
+
+// Handle applies event with handler if it carries did documents, and reports
+// whether it did
+func Handle(handler Handler, event *client.ChaincodeEvent) (bool, error) {
+	if !IsDidEvent(event.EventName) {
+		return false, nil
+	}
+
+	if err := handler.Apply(event); err != nil {
+		return false, fmt.Errorf("failed to apply event of transaction %s: %w", event.TransactionID, err)
+	}
+
+	return true, nil
+}
@@ -0,0 +1,241 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Package index maintains the off-chain index of did documents built from the
+// chaincode events of the fabcar chaincode. The listener applies events as they
+// are committed and the replayer applies the events of past blocks, both
+// through a Handler.
+package index
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// schema creates the tables of the index. The SQL is shared by SQLite and Postgres
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS dids (
+		did_number   TEXT PRIMARY KEY,
+		id           TEXT NOT NULL,
+		document     TEXT NOT NULL,
+		deactivated  BOOLEAN NOT NULL DEFAULT FALSE,
+		deleted      BOOLEAN NOT NULL DEFAULT FALSE,
+		block_number BIGINT NOT NULL,
+		tx_id        TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS dids_id ON dids (id)`,
+	`CREATE TABLE IF NOT EXISTS checkpoint (
+		name         TEXT PRIMARY KEY,
+		block_number BIGINT NOT NULL,
+		tx_id        TEXT NOT NULL
+	)`,
+}
+
+const upsertDid = `INSERT INTO dids (did_number, id, document, deactivated, deleted, block_number, tx_id)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (did_number) DO UPDATE SET id = excluded.id, document = excluded.document,
+		deactivated = excluded.deactivated, deleted = excluded.deleted, block_number = excluded.block_number, tx_id = excluded.tx_id`
+
+const upsertCheckpoint = `INSERT INTO checkpoint (name, block_number, tx_id) VALUES ($1, $2, $3)
+	ON CONFLICT (name) DO UPDATE SET block_number = excluded.block_number, tx_id = excluded.tx_id`
+
+// DidEvent is the payload of the did events emitted by the chaincode. Did is
+// the canonical JSON of the document, as hashed by QueryDidHash
+type DidEvent struct {
+	DidNumber string          `json:"didNumber"`
+	Did       json.RawMessage `json:"did"`
+}
+
+// didsEvent is the payload of the event emitted when several dids are created
+// or purged in one transaction
+type didsEvent struct {
+	Dids []DidEvent `json:"dids"`
+}
+
+// didHeader holds the members of a did document the index keeps in columns
+type didHeader struct {
+	Id          string `json:"id"`
+	Deactivated bool   `json:"deactivated"`
+	Deleted     bool   `json:"deleted"`
+}
+
+// Store is the off-chain index of did documents and the checkpoint of the last
+// applied event. It is the Handler of the listener and the replayer
+type Store struct {
+	db   *sql.DB
+	name string
+}
+
+// Open opens the index in a SQLite or Postgres database, creating its tables.
+// The checkpoint is kept under name, such as the channel and chaincode
+func Open(driver string, dataSource string, name string) (*Store, error) {
+	db, err := sql.Open(driver, dataSource)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", driver, err)
+	}
+
+	s := &Store{db: db, name: name}
+
+	if err := s.createSchema(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Store) createSchema() error {
+	for _, statement := range schema {
+		if _, err := s.db.Exec(statement); err != nil {
+			return fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Reset drops the indexed documents and the checkpoint and recreates the tables,
+// so that a replay from the first block rebuilds the index with the current schema
+func (s *Store) Reset() error {
+	if _, err := s.db.Exec(`DROP TABLE IF EXISTS dids`); err != nil {
+		return fmt.Errorf("failed to drop index: %w", err)
+	}
+
+	if _, err := s.db.Exec(`DELETE FROM checkpoint WHERE name = $1`, s.name); err != nil {
+		return fmt.Errorf("failed to delete checkpoint: %w", err)
+	}
+
+	return s.createSchema()
+}
+
+// Checkpoint returns the position after the last applied event, and whether any
+// event or snapshot has been applied yet
+func (s *Store) Checkpoint() (*client.InMemoryCheckpointer, bool, error) {
+	checkpointer := new(client.InMemoryCheckpointer)
+
+	var blockNumber uint64
+	var txId string
+
+	err := s.db.QueryRow(`SELECT block_number, tx_id FROM checkpoint WHERE name = $1`, s.name).Scan(&blockNumber, &txId)
+
+	if err == sql.ErrNoRows {
+		return checkpointer, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	checkpointer.CheckpointTransaction(blockNumber, txId)
+
+	return checkpointer, true, nil
+}
+
+// putDid writes a did document to the index
+func putDid(tx *sql.Tx, didNumber string, document []byte, blockNumber uint64, txId string) error {
+	header := new(didHeader)
+
+	if err := json.Unmarshal(document, header); err != nil {
+		return fmt.Errorf("invalid document of %s: %w", didNumber, err)
+	}
+
+	_, err := tx.Exec(upsertDid, didNumber, header.Id, string(document), header.Deactivated, header.Deleted, blockNumber, txId)
+
+	return err
+}
+
+// EventDids returns the dids carried by a did event
+func EventDids(event *client.ChaincodeEvent) ([]DidEvent, error) {
+	if event.EventName == didsCreatedEvent || event.EventName == didsPurgedEvent || event.EventName == legacyDidsCreatedEvent {
+		payload := new(didsEvent)
+		err := json.Unmarshal(event.Payload, payload)
+
+		return payload.Dids, err
+	}
+
+	payload := new(DidEvent)
+	err := json.Unmarshal(event.Payload, payload)
+
+	return []DidEvent{*payload}, err
+}
+
+// Apply writes the documents carried by a did event and advances the checkpoint in
+// a single database transaction. Applying an event again leaves the index unchanged
+func (s *Store) Apply(event *client.ChaincodeEvent) error {
+	dids, err := EventDids(event)
+
+	if err != nil {
+		return fmt.Errorf("invalid %s event in transaction %s: %w", event.EventName, event.TransactionID, err)
+	}
+
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, did := range dids {
+		if err := putDid(tx, did.DidNumber, did.Did, event.BlockNumber, event.TransactionID); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(upsertCheckpoint, s.name, event.BlockNumber, event.TransactionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SnapshotRecord is a did as returned by QueryAllDidsWithPagination
+type SnapshotRecord struct {
+	Key    string          `json:"Key"`
+	Record json.RawMessage `json:"Record"`
+}
+
+// PutSnapshot writes dids read from the world state to the index
+func (s *Store) PutSnapshot(records []SnapshotRecord) error {
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, record := range records {
+		if err := putDid(tx, record.Key, record.Record, 0, ""); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Document returns the document of the did with given id, or nil if it is not
+// indexed or has been deleted or purged, and whether it is deactivated
+func (s *Store) Document(id string) ([]byte, bool, error) {
+	var document string
+	var deactivated bool
+
+	err := s.db.QueryRow(`SELECT document, deactivated FROM dids WHERE id = $1 AND NOT deleted`, id).Scan(&document, &deactivated)
+
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return []byte(document), deactivated, nil
+}
@@ -0,0 +1,81 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package index
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	_ "modernc.org/sqlite"
+)
+
+func newTestStore(t *testing.T) *Store {
+	s, err := Open("sqlite", filepath.Join(t.TempDir(), "dids.db"), "mychannel/fabcar")
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func TestApply(t *testing.T) {
+	s := newTestStore(t)
+	document := `{"id":"did:fabcar:1","deactivated":false}`
+
+	require.NoError(t, s.Apply(&client.ChaincodeEvent{BlockNumber: 3, TransactionID: "tx1", EventName: "did.created.fabric", Payload: []byte(`{"didNumber":"1","did":` + document + `}`)}))
+
+	indexed, deactivated, err := s.Document("did:fabcar:1")
+	require.NoError(t, err)
+	assert.Equal(t, document, string(indexed), "should index the document of the event")
+	assert.False(t, deactivated)
+
+	checkpointer, found, err := s.Checkpoint()
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, uint64(3), checkpointer.BlockNumber(), "should advance the checkpoint to the applied event")
+
+	require.NoError(t, s.Apply(&client.ChaincodeEvent{BlockNumber: 4, TransactionID: "tx2", EventName: "did.deactivated.fabric", Payload: []byte(`{"didNumber":"1","did":{"id":"did:fabcar:1","deactivated":true}}`)}))
+
+	indexed, deactivated, err = s.Document("did:fabcar:1")
+	require.NoError(t, err)
+	assert.NotNil(t, indexed)
+	assert.True(t, deactivated, "should report a deactivated did")
+
+	require.NoError(t, s.Apply(&client.ChaincodeEvent{BlockNumber: 5, TransactionID: "tx3", EventName: "did.deleted.fabric", Payload: []byte(`{"didNumber":"1","did":{"id":"did:fabcar:1","deactivated":true,"deleted":true}}`)}))
+
+	indexed, _, err = s.Document("did:fabcar:1")
+	require.NoError(t, err)
+	assert.Nil(t, indexed, "should not serve a deleted did")
+
+	err = s.Apply(&client.ChaincodeEvent{BlockNumber: 6, TransactionID: "tx4", EventName: "did.updated.fabric", Payload: []byte(`{"didNumber":"2","did":[]}`)})
+	assert.ErrorContains(t, err, "invalid document of 2")
+}
+
+func TestApplyPurged(t *testing.T) {
+	s := newTestStore(t)
+
+	require.NoError(t, s.Apply(&client.ChaincodeEvent{BlockNumber: 3, TransactionID: "tx1", EventName: "dids.created", Payload: []byte(`{"dids":[{"didNumber":"1","did":{"id":"did:fabcar:1"}},{"didNumber":"2","did":{"id":"did:fabcar:2"}}]}`)}))
+
+	handled, err := Handle(s, &client.ChaincodeEvent{BlockNumber: 4, TransactionID: "tx2", EventName: "dids.purged", Payload: []byte(`{"dids":[{"didNumber":"1","did":{"id":"did:fabcar:1","deleted":true}}]}`)})
+	require.NoError(t, err)
+	assert.True(t, handled, "should apply the purged dids to the index")
+
+	indexed, _, err := s.Document("did:fabcar:1")
+	require.NoError(t, err)
+	assert.Nil(t, indexed, "should not serve a purged did")
+
+	indexed, _, err = s.Document("did:fabcar:2")
+	require.NoError(t, err)
+	assert.NotNil(t, indexed, "should keep the dids that are not purged")
+
+	require.NoError(t, s.Apply(&client.ChaincodeEvent{BlockNumber: 5, TransactionID: "tx3", EventName: "did.created.fabric", Payload: []byte(`{"didNumber":"3","did":{"id":"did:fabcar:1"}}`)}))
+
+	indexed, _, err = s.Document("did:fabcar:1")
+	require.NoError(t, err)
+	assert.Equal(t, `{"id":"did:fabcar:1"}`, string(indexed), "should serve a did created again with the id of a purged did")
+}
@@ -0,0 +1,180 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command listener maintains an off-chain index of the dids in the fabcar
+// chaincode. It applies the did.<operation>.<method> events of single dids and
+// the dids.created events of batches to a SQLite or Postgres database and checkpoints
+// the last applied event there, so a restarted listener resumes where it
+// stopped. On its first run it copies the current world state and replays the events from the first block.
+//
+// The database is selected with DATABASE_DRIVER (sqlite or postgres) and
+// DATABASE_URL. When LISTENER_ADDRESS is set, indexed documents are served at
+// GET /identifiers/{did}.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+	"github.com/hyperledger/fabric-samples/fabcar/go/index"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const snapshotPageSize = 100
+
+func envOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// snapshot copies every did in the world state to the index
+func snapshot(contract *client.Contract, s *index.Store) error {
+	bookmark := ""
+
+	for {
+		result, err := contract.EvaluateTransaction("QueryAllDidsWithPagination", "all", strconv.Itoa(snapshotPageSize), bookmark)
+
+		if err != nil {
+			return fmt.Errorf("failed to read world state: %w", err)
+		}
+
+		page := struct {
+			Records  []index.SnapshotRecord `json:"records"`
+			Bookmark string                 `json:"bookmark"`
+		}{}
+
+		if err := json.Unmarshal(result, &page); err != nil {
+			return fmt.Errorf("failed to parse world state page: %w", err)
+		}
+
+		if err := s.PutSnapshot(page.Records); err != nil {
+			return err
+		}
+
+		log.Printf("Indexed %d dids from the world state", len(page.Records))
+
+		if len(page.Records) < snapshotPageSize || page.Bookmark == "" {
+			return nil
+		}
+
+		bookmark = page.Bookmark
+	}
+}
+
+// listen applies chaincode events to the index until the event stream ends
+func listen(ctx context.Context, conn *connection.Connection, s *index.Store) error {
+	checkpointer, resumed, err := s.Checkpoint()
+
+	if err != nil {
+		return err
+	}
+
+	options := []client.ChaincodeEventsOption{client.WithCheckpoint(checkpointer)}
+
+	if !resumed {
+		if err := snapshot(conn.Contract, s); err != nil {
+			return err
+		}
+
+		options = []client.ChaincodeEventsOption{client.WithStartBlock(0)}
+	} else {
+		log.Printf("Resuming from block %d", checkpointer.BlockNumber())
+	}
+
+	events, err := conn.Network.ChaincodeEvents(ctx, conn.ChaincodeName, options...)
+
+	if err != nil {
+		return fmt.Errorf("failed to start chaincode event listening: %w", err)
+	}
+
+	for event := range events {
+		applied, err := index.Handle(s, event)
+
+		if err != nil {
+			return err
+		}
+
+		if !applied {
+			continue
+		}
+
+		log.Printf("Applied %s from block %d transaction %s", event.EventName, event.BlockNumber, event.TransactionID)
+	}
+
+	return ctx.Err()
+}
+
+func serve(address string, s *index.Store) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /identifiers/{did}", func(w http.ResponseWriter, r *http.Request) {
+		document, deactivated, err := s.Document(r.PathValue("did"))
+
+		switch {
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		case document == nil:
+			http.Error(w, "not found", http.StatusNotFound)
+		case deactivated:
+			http.Error(w, "deactivated", http.StatusGone)
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(document)
+		}
+	})
+
+	log.Printf("Serving indexed dids on %s", address)
+	log.Fatal(http.ListenAndServe(address, mux))
+}
+
+func main() {
+	config := connection.ConfigFromEnv()
+
+	s, err := index.Open(envOrDefault("DATABASE_DRIVER", "sqlite"), envOrDefault("DATABASE_URL", "dids.db"), config.ChannelName+"/"+config.ChaincodeName)
+
+	if err != nil {
+		log.Fatalf("Failed to open index: %v", err)
+	}
+	defer s.Close()
+
+	if address := os.Getenv("LISTENER_ADDRESS"); address != "" {
+		go serve(address, s)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for ctx.Err() == nil {
+		conn, err := connection.Connect(config)
+
+		if err == nil {
+			err = listen(ctx, conn, s)
+			conn.Close()
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		log.Printf("Event listening stopped, reconnecting in 5s: %v", err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
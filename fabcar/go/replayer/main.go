@@ -0,0 +1,173 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command replayer rebuilds the off-chain did index of the listener after
+// downtime or a change of the index schema. It reads the blocks of the channel
+// from -start-block, the first block by default, to -end-block, the last
+// committed block by default, and applies the chaincode events of their valid
+// transactions through the same index.Handler as the live listener. The
+// checkpoint is advanced with each event, so a listener started afterwards
+// resumes where the replay stopped.
+//
+// With -reset the indexed documents and the checkpoint are dropped and the
+// tables recreated before replaying from the first block. The index is selected
+// with DATABASE_DRIVER and DATABASE_URL as for the listener, which should be
+// stopped during a replay.
+//
+//	go run ./replayer [-start-block 0] [-end-block 120] [-reset]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+	"github.com/hyperledger/fabric-samples/fabcar/go/index"
+	"google.golang.org/protobuf/proto"
+)
+
+func envOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// lastBlock returns the number of the last block committed to the channel
+func lastBlock(conn *connection.Connection) (uint64, error) {
+	result, err := conn.Network.GetContract("qscc").EvaluateTransaction("GetChainInfo", conn.Network.Name())
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read chain info: %w", err)
+	}
+
+	info := new(common.BlockchainInfo)
+
+	if err := proto.Unmarshal(result, info); err != nil {
+		return 0, fmt.Errorf("failed to parse chain info: %w", err)
+	}
+
+	if info.GetHeight() == 0 {
+		return 0, fmt.Errorf("channel %s has no blocks", conn.Network.Name())
+	}
+
+	return info.GetHeight() - 1, nil
+}
+
+// replay applies the did events of the blocks from start to end with handler
+// and returns the number of applied events
+func replay(ctx context.Context, conn *connection.Connection, handler index.Handler, start uint64, end uint64) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	blocks, err := conn.Network.BlockEvents(ctx, client.WithStartBlock(start))
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to start block event listening: %w", err)
+	}
+
+	applied := 0
+
+	for block := range blocks {
+		blockNumber := block.GetHeader().GetNumber()
+		events, err := index.BlockEvents(block, conn.ChaincodeName)
+
+		if err != nil {
+			return applied, err
+		}
+
+		for _, event := range events {
+			ok, err := index.Handle(handler, event)
+
+			if err != nil {
+				return applied, err
+			}
+
+			if ok {
+				applied++
+			}
+		}
+
+		if len(events) > 0 {
+			log.Printf("Replayed %d events from block %d", len(events), blockNumber)
+		}
+
+		if blockNumber >= end {
+			return applied, nil
+		}
+	}
+
+	if ctx.Err() != nil {
+		return applied, ctx.Err()
+	}
+
+	return applied, fmt.Errorf("block stream ended before block %d", end)
+}
+
+func main() {
+	startBlock := flag.Uint64("start-block", 0, "first block to replay")
+	endBlock := flag.Int64("end-block", -1, "last block to replay, the last committed block when negative")
+	reset := flag.Bool("reset", false, "drop the index and rebuild it from the first block")
+	flag.Parse()
+
+	if *reset && *startBlock != 0 {
+		log.Fatalf("The -reset flag rebuilds the index from the first block and cannot be combined with -start-block")
+	}
+
+	config := connection.ConfigFromEnv()
+
+	s, err := index.Open(envOrDefault("DATABASE_DRIVER", "sqlite"), envOrDefault("DATABASE_URL", "dids.db"), config.ChannelName+"/"+config.ChaincodeName)
+
+	if err != nil {
+		log.Fatalf("Failed to open index: %v", err)
+	}
+	defer s.Close()
+
+	conn, err := connection.Connect(config)
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	end := uint64(*endBlock)
+
+	if *endBlock < 0 {
+		end, err = lastBlock(conn)
+
+		if err != nil {
+			log.Fatalf("Failed to find the last block: %v", err)
+		}
+	}
+
+	if *startBlock > end {
+		log.Fatalf("The start block %d is after the end block %d", *startBlock, end)
+	}
+
+	if *reset {
+		if err := s.Reset(); err != nil {
+			log.Fatalf("Failed to reset index: %v", err)
+		}
+
+		log.Printf("Dropped the index")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	applied, err := replay(ctx, conn, s, *startBlock, end)
+
+	if err != nil {
+		log.Fatalf("Replay stopped after %d events: %v", applied, err)
+	}
+
+	log.Printf("Replayed blocks %d to %d, applying %d events", *startBlock, end, applied)
+}
@@ -0,0 +1,137 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-protos-go-apiv2/common"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/ledger/rwset/kvrwset"
+	"github.com/hyperledger/fabric-protos-go-apiv2/peer"
+	"google.golang.org/protobuf/proto"
+)
+
+// write is a change of a world state key made by a valid transaction
+type write struct {
+	BlockNumber uint64
+	TxId        string
+	Key         string
+	Value       []byte
+	IsDelete    bool
+}
+
+// blockWrites returns the writes made to the world state of namespace by the
+// valid endorser transactions in a block, in block order. Writes to composite
+// keys, which the chaincode uses for auxiliary records, are left out
+func blockWrites(block *common.Block, namespace string) ([]write, error) {
+	blockNumber := block.GetHeader().GetNumber()
+	validationCodes := block.GetMetadata().GetMetadata()[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	writes := []write{}
+
+	for i, envelopeBytes := range block.GetData().GetData() {
+		if i < len(validationCodes) && peer.TxValidationCode(validationCodes[i]) != peer.TxValidationCode_VALID {
+			continue
+		}
+
+		envelope := new(common.Envelope)
+
+		if err := proto.Unmarshal(envelopeBytes, envelope); err != nil {
+			return nil, fmt.Errorf("block %d transaction %d: %w", blockNumber, i, err)
+		}
+
+		payload := new(common.Payload)
+
+		if err := proto.Unmarshal(envelope.GetPayload(), payload); err != nil {
+			return nil, fmt.Errorf("block %d transaction %d: %w", blockNumber, i, err)
+		}
+
+		channelHeader := new(common.ChannelHeader)
+
+		if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader); err != nil {
+			return nil, fmt.Errorf("block %d transaction %d: %w", blockNumber, i, err)
+		}
+
+		if common.HeaderType(channelHeader.GetType()) != common.HeaderType_ENDORSER_TRANSACTION {
+			continue
+		}
+
+		txWrites, err := transactionWrites(payload.GetData(), namespace)
+
+		if err != nil {
+			return nil, fmt.Errorf("block %d transaction %s: %w", blockNumber, channelHeader.GetTxId(), err)
+		}
+
+		for _, kvWrite := range txWrites {
+			writes = append(writes, write{
+				BlockNumber: blockNumber,
+				TxId:        channelHeader.GetTxId(),
+				Key:         kvWrite.GetKey(),
+				Value:       kvWrite.GetValue(),
+				IsDelete:    kvWrite.GetIsDelete(),
+			})
+		}
+	}
+
+	return writes, nil
+}
+
+// transactionWrites returns the writes to simple keys of namespace in the read
+// write sets of an endorser transaction
+func transactionWrites(transactionBytes []byte, namespace string) ([]*kvrwset.KVWrite, error) {
+	transaction := new(peer.Transaction)
+
+	if err := proto.Unmarshal(transactionBytes, transaction); err != nil {
+		return nil, err
+	}
+
+	writes := []*kvrwset.KVWrite{}
+
+	for _, action := range transaction.GetActions() {
+		actionPayload := new(peer.ChaincodeActionPayload)
+
+		if err := proto.Unmarshal(action.GetPayload(), actionPayload); err != nil {
+			return nil, err
+		}
+
+		responsePayload := new(peer.ProposalResponsePayload)
+
+		if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload); err != nil {
+			return nil, err
+		}
+
+		chaincodeAction := new(peer.ChaincodeAction)
+
+		if err := proto.Unmarshal(responsePayload.GetExtension(), chaincodeAction); err != nil {
+			return nil, err
+		}
+
+		txRwSet := new(rwset.TxReadWriteSet)
+
+		if err := proto.Unmarshal(chaincodeAction.GetResults(), txRwSet); err != nil {
+			return nil, err
+		}
+
+		for _, nsRwSet := range txRwSet.GetNsRwset() {
+			if nsRwSet.GetNamespace() != namespace {
+				continue
+			}
+
+			kvRwSet := new(kvrwset.KVRWSet)
+
+			if err := proto.Unmarshal(nsRwSet.GetRwset(), kvRwSet); err != nil {
+				return nil, err
+			}
+
+			for _, kvWrite := range kvRwSet.GetWrites() {
+				if len(kvWrite.GetKey()) > 0 && kvWrite.GetKey()[0] != 0x00 {
+					writes = append(writes, kvWrite)
+				}
+			}
+		}
+	}
+
+	return writes, nil
+}
@@ -0,0 +1,87 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// couchDB mirrors dids into a CouchDB database, one document per did key
+type couchDB struct {
+	database string
+}
+
+// couchDocument is a replica with the CouchDB document members
+type couchDocument struct {
+	Id  string `json:"_id"`
+	Rev string `json:"_rev,omitempty"`
+	replica
+}
+
+func newCouchDB(address string, database string) (*couchDB, error) {
+	c := &couchDB{database: strings.TrimSuffix(address, "/") + "/" + url.PathEscape(database)}
+
+	status, err := doJSON(http.MethodPut, c.database, nil, nil)
+
+	if err != nil && status != http.StatusPreconditionFailed {
+		return nil, fmt.Errorf("failed to create database %s: %w", database, err)
+	}
+
+	index := map[string]interface{}{
+		"index": map[string]interface{}{"fields": []string{"deleted"}},
+		"name":  "deleted",
+	}
+
+	if _, err := doJSON(http.MethodPost, c.database+"/_index", index, nil); err != nil {
+		return nil, fmt.Errorf("failed to create index: %w", err)
+	}
+
+	return c, nil
+}
+
+func (c *couchDB) put(r *replica) error {
+	documentURL := c.database + "/" + url.PathEscape(r.DidNumber)
+	stored := new(couchDocument)
+
+	status, err := doJSON(http.MethodGet, documentURL, nil, stored)
+
+	if err != nil && status != http.StatusNotFound {
+		return err
+	}
+
+	if status == http.StatusNotFound {
+		stored = &couchDocument{}
+	} else if stored.BlockNumber > r.BlockNumber {
+		return nil
+	}
+
+	_, err = doJSON(http.MethodPut, documentURL, &couchDocument{Id: r.DidNumber, Rev: stored.Rev, replica: *r}, nil)
+
+	return err
+}
+
+func (c *couchDB) search(query string, limit int) ([]replica, error) {
+	request := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"deleted": false,
+			"text":    map[string]string{"$regex": "(?i)" + regexp.QuoteMeta(query)},
+		},
+		"limit": limit,
+	}
+
+	result := struct {
+		Docs []replica `json:"docs"`
+	}{}
+
+	if _, err := doJSON(http.MethodPost, c.database+"/_find", request, &result); err != nil {
+		return nil, err
+	}
+
+	return result.Docs, nil
+}
@@ -0,0 +1,92 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// elasticsearch mirrors dids into an Elasticsearch index, one document per did
+// key. External versions set to the block number make Elasticsearch itself
+// reject writes older than the indexed document
+type elasticsearch struct {
+	index string
+}
+
+func newElasticsearch(address string, index string) (*elasticsearch, error) {
+	e := &elasticsearch{index: strings.TrimSuffix(address, "/") + "/" + url.PathEscape(index)}
+
+	mappings := map[string]interface{}{
+		"mappings": map[string]interface{}{
+			"properties": map[string]interface{}{
+				"didNumber":   map[string]string{"type": "keyword"},
+				"did":         map[string]interface{}{"type": "object", "enabled": false},
+				"text":        map[string]string{"type": "text"},
+				"deleted":     map[string]string{"type": "boolean"},
+				"blockNumber": map[string]string{"type": "long"},
+				"txId":        map[string]string{"type": "keyword"},
+			},
+		},
+	}
+
+	status, err := doJSON(http.MethodHead, e.index, nil, nil)
+
+	if status == http.StatusNotFound {
+		_, err = doJSON(http.MethodPut, e.index, mappings, nil)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index %s: %w", index, err)
+	}
+
+	return e, nil
+}
+
+func (e *elasticsearch) put(r *replica) error {
+	documentURL := fmt.Sprintf("%s/_doc/%s?version=%d&version_type=external_gte", e.index, url.PathEscape(r.DidNumber), r.BlockNumber)
+
+	status, err := doJSON(http.MethodPut, documentURL, r, nil)
+
+	if status == http.StatusConflict {
+		return nil
+	}
+
+	return err
+}
+
+func (e *elasticsearch) search(query string, limit int) ([]replica, error) {
+	request := map[string]interface{}{
+		"size": limit,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"must":   map[string]interface{}{"match": map[string]interface{}{"text": query}},
+				"filter": map[string]interface{}{"term": map[string]bool{"deleted": false}},
+			},
+		},
+	}
+
+	result := struct {
+		Hits struct {
+			Hits []struct {
+				Source replica `json:"_source"`
+			} `json:"hits"`
+		} `json:"hits"`
+	}{}
+
+	if _, err := doJSON(http.MethodPost, e.index+"/_search", request, &result); err != nil {
+		return nil, err
+	}
+
+	replicas := []replica{}
+
+	for _, hit := range result.Hits.Hits {
+		replicas = append(replicas, hit.Source)
+	}
+
+	return replicas, nil
+}
@@ -0,0 +1,142 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// replica is the document mirrored for each did key. Deleted keys are kept as
+// tombstones so that re-applying an older write cannot bring a did back
+type replica struct {
+	DidNumber   string          `json:"didNumber"`
+	Did         json.RawMessage `json:"did,omitempty"`
+	Text        string          `json:"text"`
+	Deleted     bool            `json:"deleted"`
+	BlockNumber uint64          `json:"blockNumber"`
+	TxId        string          `json:"txId"`
+}
+
+// newReplica returns the document mirroring a write to a did key
+func newReplica(w write) (*replica, error) {
+	r := &replica{DidNumber: w.Key, Deleted: w.IsDelete, BlockNumber: w.BlockNumber, TxId: w.TxId}
+
+	if w.IsDelete {
+		return r, nil
+	}
+
+	var document interface{}
+
+	if err := json.Unmarshal(w.Value, &document); err != nil {
+		return nil, fmt.Errorf("value of %s is not a did document: %w", w.Key, err)
+	}
+
+	r.Did = w.Value
+	r.Text = strings.Join(append([]string{w.Key}, textOf(document)...), " ")
+
+	return r, nil
+}
+
+// textOf returns the string values of a decoded JSON document in a stable order,
+// for stores whose full-text search works on a single field
+func textOf(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		text := []string{}
+
+		for _, member := range v {
+			text = append(text, textOf(member)...)
+		}
+
+		return text
+	case map[string]interface{}:
+		names := make([]string, 0, len(v))
+
+		for name := range v {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		text := []string{}
+
+		for _, name := range names {
+			text = append(text, textOf(v[name])...)
+		}
+
+		return text
+	}
+
+	return nil
+}
+
+// sink is an external document store dids are mirrored to
+type sink interface {
+	// put stores r unless the stored replica of the key comes from a later block
+	put(r *replica) error
+	// search returns the live replicas matching a full-text query
+	search(query string, limit int) ([]replica, error)
+}
+
+// doJSON sends a request with an optional JSON body and decodes a JSON response
+// into result. It returns the status code of the response
+func doJSON(method string, url string, body interface{}, result interface{}) (int, error) {
+	var reader io.Reader
+
+	if body != nil {
+		bodyAsBytes, err := json.Marshal(body)
+
+		if err != nil {
+			return 0, err
+		}
+
+		reader = bytes.NewReader(bodyAsBytes)
+	}
+
+	request, err := http.NewRequest(method, url, reader)
+
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := http.DefaultClient.Do(request)
+
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	responseBody, err := io.ReadAll(response.Body)
+
+	if err != nil {
+		return response.StatusCode, err
+	}
+
+	if response.StatusCode >= http.StatusBadRequest {
+		return response.StatusCode, fmt.Errorf("%s %s returned %s: %s", method, url, response.Status, bytes.TrimSpace(responseBody))
+	}
+
+	if result != nil {
+		if err := json.Unmarshal(responseBody, result); err != nil {
+			return response.StatusCode, fmt.Errorf("failed to parse response of %s %s: %w", method, url, err)
+		}
+	}
+
+	return response.StatusCode, nil
+}
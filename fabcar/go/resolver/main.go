@@ -0,0 +1,189 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command resolver is a Universal Resolver driver for the dids registered in the
+// fabcar chaincode. It serves GET /1.0/identifiers/{did} by evaluating the
+// Resolve transaction, or Dereference for did urls with a query or fragment.
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+const (
+	identifiersPath       = "/1.0/identifiers/"
+	didContentType        = "application/did+ld+json"
+	resolutionContentType = `application/ld+json;profile="https://w3id.org/did-resolution"`
+)
+
+// resolutionResult is the result of the Resolve transaction
+type resolutionResult struct {
+	DidDocument           json.RawMessage `json:"didDocument,omitempty"`
+	DidDocumentMetadata   json.RawMessage `json:"didDocumentMetadata"`
+	DidResolutionMetadata struct {
+		ContentType string `json:"contentType"`
+		Error       string `json:"error,omitempty"`
+	} `json:"didResolutionMetadata"`
+}
+
+// dereferencingResult is the result of the Dereference transaction
+type dereferencingResult struct {
+	ContentStream         string          `json:"contentStream,omitempty"`
+	ContentMetadata       json.RawMessage `json:"contentMetadata"`
+	DereferencingMetadata struct {
+		ContentType string `json:"contentType"`
+		Error       string `json:"error,omitempty"`
+	} `json:"dereferencingMetadata"`
+}
+
+// statusOf maps a did resolution error code to the HTTP status defined for
+// Universal Resolver drivers
+func statusOf(code string) int {
+	switch code {
+	case "":
+		return http.StatusOK
+	case "invalidDid", "invalidDidUrl":
+		return http.StatusBadRequest
+	case "notFound":
+		return http.StatusNotFound
+	case "deactivated":
+		return http.StatusGone
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+type driver struct {
+	contract *client.Contract
+}
+
+func (d *driver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	identifier := strings.TrimPrefix(r.URL.EscapedPath(), identifiersPath)
+
+	if identifier == "" {
+		http.Error(w, "missing identifier", http.StatusBadRequest)
+		return
+	}
+
+	identifier, err := url.PathUnescape(identifier)
+
+	if err != nil {
+		http.Error(w, "invalid identifier", http.StatusBadRequest)
+		return
+	}
+
+	if r.URL.RawQuery != "" {
+		identifier += "?" + r.URL.RawQuery
+	}
+
+	if strings.ContainsAny(identifier, "?#") {
+		d.dereference(w, identifier)
+		return
+	}
+
+	d.resolve(w, r, identifier)
+}
+
+func (d *driver) resolve(w http.ResponseWriter, r *http.Request, did string) {
+	resultAsBytes, err := d.contract.EvaluateTransaction("Resolve", did, "false")
+
+	if err != nil {
+		log.Printf("Failed to resolve %s: %v", did, err)
+		http.Error(w, "resolution failed", http.StatusInternalServerError)
+		return
+	}
+
+	result := new(resolutionResult)
+
+	if err := json.Unmarshal(resultAsBytes, result); err != nil {
+		log.Printf("Failed to parse resolution result of %s: %v", did, err)
+		http.Error(w, "invalid resolution result", http.StatusInternalServerError)
+		return
+	}
+
+	status := statusOf(result.DidResolutionMetadata.Error)
+
+	if status == http.StatusOK && acceptsDocument(r) {
+		w.Header().Set("Content-Type", didContentType)
+		w.WriteHeader(status)
+		w.Write(result.DidDocument)
+		return
+	}
+
+	w.Header().Set("Content-Type", resolutionContentType)
+	w.WriteHeader(status)
+	w.Write(resultAsBytes)
+}
+
+func (d *driver) dereference(w http.ResponseWriter, didUrl string) {
+	resultAsBytes, err := d.contract.EvaluateTransaction("Dereference", didUrl)
+
+	if err != nil {
+		log.Printf("Failed to dereference %s: %v", didUrl, err)
+		http.Error(w, "dereferencing failed", http.StatusInternalServerError)
+		return
+	}
+
+	result := new(dereferencingResult)
+
+	if err := json.Unmarshal(resultAsBytes, result); err != nil {
+		log.Printf("Failed to parse dereferencing result of %s: %v", didUrl, err)
+		http.Error(w, "invalid dereferencing result", http.StatusInternalServerError)
+		return
+	}
+
+	status := statusOf(result.DereferencingMetadata.Error)
+
+	if status != http.StatusOK {
+		w.Header().Set("Content-Type", resolutionContentType)
+		w.WriteHeader(status)
+		w.Write(resultAsBytes)
+		return
+	}
+
+	w.Header().Set("Content-Type", result.DereferencingMetadata.ContentType)
+	w.WriteHeader(status)
+	w.Write([]byte(result.ContentStream))
+}
+
+// acceptsDocument reports whether the request asks for the did document alone
+// rather than the full resolution result
+func acceptsDocument(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+
+	return strings.Contains(accept, didContentType) || strings.Contains(accept, "application/did+json")
+}
+
+func main() {
+	conn, err := connection.Connect(connection.ConfigFromEnv())
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	address := os.Getenv("RESOLVER_ADDRESS")
+
+	if address == "" {
+		address = ":8080"
+	}
+
+	http.Handle(identifiersPath, &driver{contract: conn.Contract})
+
+	log.Printf("Universal Resolver driver listening on %s", address)
+	log.Fatal(http.ListenAndServe(address, nil))
+}
@@ -0,0 +1,261 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command rest exposes the did registry of the fabcar chaincode as a REST API
+// through the Fabric Gateway.
+//
+//	GET    /dids?status=&pageSize=&bookmark=  page through the dids of a status, active by default
+//	POST   /dids                              create a did, returning its generated id
+//	GET    /dids/{key}                        read a did by its ledger key
+//	PUT    /dids/{key}                        update a did
+//	DELETE /dids/{key}?signature=             deactivate a did
+//	GET    /dids/{key}/history                list every change of a did
+//	GET    /identifiers/{did}                 resolve a did, or a version of it given versionId or versionTime
+//	GET    /health                            report the chaincode version and whether the ledger is initialized
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+const defaultPageSize = 20
+
+// didRequest is the body of create and update requests
+type didRequest struct {
+	AuthenticationId            string `json:"authenticationId"`
+	AuthenticationType          string `json:"authenticationType"`
+	AuthenticationController    string `json:"authenticationController"`
+	AuthenticationPublicKeyPerm string `json:"authenticationPublicKeyPerm"`
+	ServiceId                   string `json:"serviceId"`
+	ServiceType                 string `json:"serviceType"`
+	ServiceEndPoint             string `json:"serviceEndPoint"`
+	Expires                     string `json:"expires,omitempty"`
+	Signature                   string `json:"signature,omitempty"`
+}
+
+// errorResponse is the body returned for failed requests
+type errorResponse struct {
+	Error   string   `json:"error"`
+	Code    string   `json:"code,omitempty"`
+	Details []string `json:"details,omitempty"`
+}
+
+type server struct {
+	contract *client.Contract
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /dids", s.listDids)
+	mux.HandleFunc("POST /dids", s.createDid)
+	mux.HandleFunc("GET /dids/{key}", s.getDid)
+	mux.HandleFunc("PUT /dids/{key}", s.updateDid)
+	mux.HandleFunc("DELETE /dids/{key}", s.deactivateDid)
+	mux.HandleFunc("GET /dids/{key}/history", s.getHistory)
+	mux.HandleFunc("GET /identifiers/{did}", s.resolve)
+	mux.HandleFunc("GET /health", s.health)
+
+	return mux
+}
+
+func (s *server) listDids(w http.ResponseWriter, r *http.Request) {
+	pageSize := defaultPageSize
+
+	if value := r.URL.Query().Get("pageSize"); value != "" {
+		size, err := strconv.Atoi(value)
+
+		if err != nil || size < 1 {
+			writeError(w, http.StatusBadRequest, "pageSize must be a positive number", connection.CodeInvalidArgument, nil)
+			return
+		}
+
+		pageSize = size
+	}
+
+	s.evaluate(w, "QueryAllDidsWithPagination", r.URL.Query().Get("status"), strconv.Itoa(pageSize), r.URL.Query().Get("bookmark"))
+}
+
+func (s *server) createDid(w http.ResponseWriter, r *http.Request) {
+	body, ok := readDidRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	id, err := s.contract.SubmitTransaction("CreateDid", body.AuthenticationId, body.AuthenticationType, body.AuthenticationController,
+		body.AuthenticationPublicKeyPerm, body.ServiceId, body.ServiceType, body.ServiceEndPoint, body.Expires)
+
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/dids/"+string(id))
+	writeJSON(w, http.StatusCreated, map[string]string{"id": string(id)})
+}
+
+func (s *server) getDid(w http.ResponseWriter, r *http.Request) {
+	s.evaluate(w, "QueryDidByKey", r.PathValue("key"))
+}
+
+func (s *server) updateDid(w http.ResponseWriter, r *http.Request) {
+	body, ok := readDidRequest(w, r)
+
+	if !ok {
+		return
+	}
+
+	_, err := s.contract.SubmitTransaction("UpdateDid", r.PathValue("key"), body.AuthenticationId, body.AuthenticationType, body.AuthenticationController,
+		body.AuthenticationPublicKeyPerm, body.ServiceId, body.ServiceType, body.ServiceEndPoint, body.Signature)
+
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) deactivateDid(w http.ResponseWriter, r *http.Request) {
+	_, err := s.contract.SubmitTransaction("DeactivateDid", r.PathValue("key"), r.URL.Query().Get("signature"))
+
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) getHistory(w http.ResponseWriter, r *http.Request) {
+	s.evaluate(w, "QueryDidHistory", r.PathValue("key"))
+}
+
+func (s *server) resolve(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	if query.Has("versionId") || query.Has("versionTime") {
+		s.evaluate(w, "ResolveVersion", r.PathValue("did"), query.Get("versionId"), query.Get("versionTime"))
+		return
+	}
+
+	s.evaluate(w, "Resolve", r.PathValue("did"), strconv.FormatBool(query.Get("includeDeleted") == "true"))
+}
+
+func (s *server) health(w http.ResponseWriter, r *http.Request) {
+	s.evaluate(w, "GetContractInfo")
+}
+
+// evaluate evaluates a transaction and writes its JSON result
+func (s *server) evaluate(w http.ResponseWriter, name string, args ...string) {
+	result, err := s.contract.EvaluateTransaction(name, args...)
+
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(result)
+}
+
+func readDidRequest(w http.ResponseWriter, r *http.Request) (*didRequest, bool) {
+	body := new(didRequest)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error(), connection.CodeInvalidArgument, nil)
+		return nil, false
+	}
+
+	return body, true
+}
+
+// writeGatewayError maps a failed transaction to an HTTP status derived from the
+// code of the error raised by the chaincode
+func writeGatewayError(w http.ResponseWriter, err error) {
+	details := connection.ErrorDetails(err)
+
+	var commitErr *client.CommitError
+
+	if errors.As(err, &commitErr) {
+		writeError(w, http.StatusConflict, err.Error(), "", details)
+		return
+	}
+
+	if connection.IsTransient(err) {
+		writeError(w, http.StatusServiceUnavailable, err.Error(), "", details)
+		return
+	}
+
+	chaincodeErr := connection.ChaincodeErrorOf(err)
+
+	if chaincodeErr == nil {
+		log.Printf("Transaction failed: %v", err)
+		writeError(w, http.StatusBadGateway, err.Error(), "", details)
+		return
+	}
+
+	statusCode := http.StatusBadRequest
+
+	switch chaincodeErr.Code {
+	case connection.CodeDidNotFound, connection.CodeNotFound:
+		statusCode = http.StatusNotFound
+	case connection.CodeDidAlreadyExists, connection.CodeAlreadyExists, connection.CodeConflict:
+		statusCode = http.StatusConflict
+	case connection.CodeDidDeactivated:
+		statusCode = http.StatusGone
+	case connection.CodeUnauthorized, connection.CodeInvalidSignature, connection.CodeChallengeExpired:
+		statusCode = http.StatusForbidden
+	case connection.CodeQuotaExceeded:
+		statusCode = http.StatusTooManyRequests
+	case connection.CodeFeeRejected:
+		statusCode = http.StatusPaymentRequired
+	case connection.CodeCorruptRecord:
+		statusCode = http.StatusInternalServerError
+	}
+
+	writeJSON(w, statusCode, errorResponse{Error: chaincodeErr.Message, Code: chaincodeErr.Code})
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string, code string, details []string) {
+	writeJSON(w, statusCode, errorResponse{Error: message, Code: code, Details: details})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(value)
+}
+
+func main() {
+	conn, err := connection.Connect(connection.ConfigFromEnv())
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	address := os.Getenv("REST_ADDRESS")
+
+	if address == "" {
+		address = ":3000"
+	}
+
+	s := &server{contract: conn.Contract}
+
+	log.Printf("REST API listening on %s", address)
+	log.Fatal(http.ListenAndServe(address, s.routes()))
+}
@@ -0,0 +1,85 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxCRLBytes bounds the size of a downloaded revocation list
+const maxCRLBytes = 16 << 20
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// readCRL reads a PEM or DER encoded revocation list from a file or an http(s)
+// URL. When issuer is set the list must be signed by it
+func readCRL(location string, issuer *x509.Certificate) (*x509.RevocationList, error) {
+	crlAsBytes, err := readLocation(location)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(crlAsBytes); block != nil {
+		crlAsBytes = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(crlAsBytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse revocation list: %w", err)
+	}
+
+	if issuer != nil {
+		if err := crl.CheckSignatureFrom(issuer); err != nil {
+			return nil, fmt.Errorf("revocation list is not signed by %s: %w", issuer.Subject, err)
+		}
+	}
+
+	return crl, nil
+}
+
+func readLocation(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		return os.ReadFile(location)
+	}
+
+	response, err := httpClient.Get(location)
+
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s returned %s", location, response.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(response.Body, maxCRLBytes))
+}
+
+// readCertificate reads a PEM encoded certificate
+func readCertificate(path string) (*x509.Certificate, error) {
+	certificatePEM, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(certificatePEM)
+
+	if block == nil {
+		return nil, fmt.Errorf("no PEM certificate in %s", path)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
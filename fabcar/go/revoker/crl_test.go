@@ -0,0 +1,136 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCA returns a self-signed CA certificate and its key
+func newTestCA(t *testing.T, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return certificate, key
+}
+
+// newTestCRL returns the DER revocation list of the CA revoking the serials
+func newTestCRL(t *testing.T, ca *x509.Certificate, key *ecdsa.PrivateKey, serials ...int64) []byte {
+	template := &x509.RevocationList{Number: big.NewInt(1), ThisUpdate: time.Now(), NextUpdate: time.Now().Add(time.Hour)}
+
+	for _, serial := range serials {
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries, x509.RevocationListEntry{
+			SerialNumber:   big.NewInt(serial),
+			RevocationTime: time.Now(),
+		})
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca, key)
+	require.NoError(t, err)
+
+	return der
+}
+
+func writeTestFile(t *testing.T, name string, contents []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, contents, 0644))
+
+	return path
+}
+
+func TestReadCRL(t *testing.T) {
+	ca, key := newTestCA(t, "ca-org1")
+	other, _ := newTestCA(t, "ca-org2")
+	der := newTestCRL(t, ca, key, 0x1a2b, 0x3c)
+
+	derPath := writeTestFile(t, "crl.der", der)
+	pemPath := writeTestFile(t, "crl.pem", pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}))
+
+	for _, location := range []string{derPath, pemPath} {
+		crl, err := readCRL(location, ca)
+		require.NoError(t, err, "should read %s", location)
+		require.Len(t, crl.RevokedCertificateEntries, 2)
+		assert.Equal(t, "1a2b", crl.RevokedCertificateEntries[0].SerialNumber.Text(16))
+		assert.Equal(t, "3c", crl.RevokedCertificateEntries[1].SerialNumber.Text(16))
+	}
+
+	_, err := readCRL(pemPath, nil)
+	assert.NoError(t, err, "should not check the signature without an issuer")
+
+	_, err = readCRL(pemPath, other)
+	assert.ErrorContains(t, err, "revocation list is not signed by CN=ca-org2", "should check the signature of the list")
+
+	_, err = readCRL(writeTestFile(t, "crl.pem", []byte("not a list")), nil)
+	assert.ErrorContains(t, err, "failed to parse revocation list")
+
+	_, err = readCRL(filepath.Join(t.TempDir(), "missing.pem"), nil)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestReadCRLFromURL(t *testing.T) {
+	ca, key := newTestCA(t, "ca-org1")
+	der := newTestCRL(t, ca, key, 7)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/crl" {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Write(der)
+	}))
+	defer server.Close()
+
+	crl, err := readCRL(server.URL+"/crl", ca)
+	require.NoError(t, err, "should download the list")
+	require.Len(t, crl.RevokedCertificateEntries, 1)
+	assert.Equal(t, int64(7), crl.RevokedCertificateEntries[0].SerialNumber.Int64())
+
+	_, err = readCRL(server.URL+"/missing", ca)
+	assert.EqualError(t, err, "GET "+server.URL+"/missing returned 404 Not Found")
+}
+
+func TestReadCertificate(t *testing.T) {
+	ca, _ := newTestCA(t, "ca-org1")
+
+	certificate, err := readCertificate(writeTestFile(t, "ca.pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})))
+	require.NoError(t, err)
+	assert.True(t, ca.Equal(certificate), "should read the PEM certificate")
+
+	path := writeTestFile(t, "ca.der", ca.Raw)
+	_, err = readCertificate(path)
+	assert.EqualError(t, err, "no PEM certificate in "+path)
+}
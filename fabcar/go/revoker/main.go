@@ -0,0 +1,125 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command revoker keeps the did registry consistent with the certificate
+// revocation list of the Fabric CA of the organization. It polls the list and,
+// for every revoked certificate, calls DeactivateRevokedCertificate so that the
+// did bound with the certificate through RegisterIdentityBinding is
+// deactivated. The client identity must be a registry administrator of the
+// organization.
+//
+// The revocation list is read from the file or http(s) URL in REVOKER_CRL, such
+// as the msp/crls/crl.pem written by fabric-ca-client gencrl, every
+// REVOKER_INTERVAL (1m by default). When REVOKER_CA_CERT names the CA
+// certificate, lists not signed by it are ignored.
+package main
+
+import (
+	"context"
+	"crypto/x509"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+)
+
+func envOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// revoker deactivates the dids of revoked certificates, remembering the
+// certificates it processed so that each is submitted once
+type revoker struct {
+	contract  *client.Contract
+	processed map[string]bool
+}
+
+// apply submits DeactivateRevokedCertificate for the certificates of the list
+// that were not processed yet. Certificates without a binding are skipped
+func (r *revoker) apply(crl *x509.RevocationList) error {
+	for _, entry := range crl.RevokedCertificateEntries {
+		serial := entry.SerialNumber.Text(16)
+
+		if r.processed[serial] {
+			continue
+		}
+
+		_, err := r.contract.SubmitTransaction("DeactivateRevokedCertificate", serial)
+
+		if chaincodeErr := connection.ChaincodeErrorOf(err); chaincodeErr != nil && chaincodeErr.Code == connection.CodeNotFound {
+			err = nil
+		} else if err == nil {
+			log.Printf("Deactivated the did bound with certificate %s, revoked %s", serial, entry.RevocationTime.Format(time.RFC3339))
+		}
+
+		if err != nil {
+			return err
+		}
+
+		r.processed[serial] = true
+	}
+
+	return nil
+}
+
+func main() {
+	config := connection.ConfigFromEnv()
+	location := os.Getenv("REVOKER_CRL")
+
+	if location == "" {
+		log.Fatalf("REVOKER_CRL must name the revocation list")
+	}
+
+	interval, err := time.ParseDuration(envOrDefault("REVOKER_INTERVAL", "1m"))
+
+	if err != nil || interval <= 0 {
+		log.Fatalf("REVOKER_INTERVAL must be a positive duration")
+	}
+
+	var issuer *x509.Certificate
+
+	if path := os.Getenv("REVOKER_CA_CERT"); path != "" {
+		issuer, err = readCertificate(path)
+
+		if err != nil {
+			log.Fatalf("Failed to read CA certificate: %v", err)
+		}
+	}
+
+	conn, err := connection.Connect(config)
+
+	if err != nil {
+		log.Fatalf("Failed to connect to gateway: %v", err)
+	}
+	defer conn.Close()
+
+	r := &revoker{contract: conn.Network.GetContractWithName(config.ChaincodeName, connection.AdminContract), processed: map[string]bool{}}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for ctx.Err() == nil {
+		crl, err := readCRL(location, issuer)
+
+		if err == nil {
+			err = r.apply(crl)
+		}
+
+		if err != nil {
+			log.Printf("Failed to process revocation list, retrying in %s: %v", interval, err)
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(interval):
+		}
+	}
+}
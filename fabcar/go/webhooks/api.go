@@ -0,0 +1,219 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultDeliveryLimit = 50
+	maxDeliveryLimit     = 500
+)
+
+// subscriberRequest is the body of a registration
+type subscriberRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+}
+
+// errorResponse is the body returned for failed requests
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type server struct {
+	store *store
+}
+
+func (s *server) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /subscribers", s.createSubscriber)
+	mux.HandleFunc("GET /subscribers", s.listSubscribers)
+	mux.HandleFunc("GET /subscribers/{id}", s.getSubscriber)
+	mux.HandleFunc("DELETE /subscribers/{id}", s.deleteSubscriber)
+	mux.HandleFunc("GET /subscribers/{id}/deliveries", s.listDeliveries)
+	mux.HandleFunc("GET /deliveries/{id}", s.getDelivery)
+
+	return mux
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	value := make([]byte, n)
+
+	if _, err := rand.Read(value); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(value), nil
+}
+
+// createSubscriber registers a webhook. The secret signing its notifications is
+// only returned here
+func (s *server) createSubscriber(w http.ResponseWriter, r *http.Request) {
+	body := new(subscriberRequest)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+
+	endpoint, err := url.Parse(body.URL)
+
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		writeError(w, http.StatusBadRequest, "url must be an absolute http or https url")
+		return
+	}
+
+	for _, prefix := range body.Events {
+		if prefix == "" || strings.Contains(prefix, ",") {
+			writeError(w, http.StatusBadRequest, "events must be non-empty event name prefixes without commas")
+			return
+		}
+	}
+
+	id, err := randomHex(16)
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	secret, err := randomHex(32)
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	created := time.Now()
+	sub := &subscriber{Id: id, URL: body.URL, Events: append([]string{}, body.Events...), Secret: secret, Created: created.UTC().Format(time.RFC3339Nano)}
+
+	if err := s.store.putSubscriber(sub, created); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.Printf("Registered subscriber %s for %s", id, body.URL)
+	w.Header().Set("Location", "/subscribers/"+id)
+	writeJSON(w, http.StatusCreated, sub)
+}
+
+func (s *server) listSubscribers(w http.ResponseWriter, r *http.Request) {
+	subscribers, err := s.store.subscribers(false)
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, subscribers)
+}
+
+func (s *server) getSubscriber(w http.ResponseWriter, r *http.Request) {
+	sub, err := s.store.subscriber(r.PathValue("id"))
+
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case sub == nil:
+		writeError(w, http.StatusNotFound, "subscriber not found")
+	default:
+		writeJSON(w, http.StatusOK, sub)
+	}
+}
+
+func (s *server) deleteSubscriber(w http.ResponseWriter, r *http.Request) {
+	removed, err := s.store.removeSubscriber(r.PathValue("id"))
+
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case !removed:
+		writeError(w, http.StatusNotFound, "subscriber not found")
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listDeliveries lists the deliveries of a subscriber, newest first, optionally
+// of a status and at most limit
+func (s *server) listDeliveries(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+
+	if status != "" && status != statusPending && status != statusDelivered && status != statusFailed {
+		writeError(w, http.StatusBadRequest, "status must be pending, delivered or failed")
+		return
+	}
+
+	limit := defaultDeliveryLimit
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+
+		if err != nil || parsed < 1 || parsed > maxDeliveryLimit {
+			writeError(w, http.StatusBadRequest, "limit must be a number from 1 to "+strconv.Itoa(maxDeliveryLimit))
+			return
+		}
+
+		limit = parsed
+	}
+
+	sub, err := s.store.subscriber(r.PathValue("id"))
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if sub == nil {
+		writeError(w, http.StatusNotFound, "subscriber not found")
+		return
+	}
+
+	deliveries, err := s.store.deliveries(r.PathValue("id"), status, limit)
+
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (s *server) getDelivery(w http.ResponseWriter, r *http.Request) {
+	d, err := s.store.delivery(r.PathValue("id"))
+
+	switch {
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	case d == nil:
+		writeError(w, http.StatusNotFound, "delivery not found")
+	default:
+		writeJSON(w, http.StatusOK, d)
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, errorResponse{Error: message})
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,157 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers of a notification. The signature is the hex HMAC-SHA256, keyed with
+// the secret of the subscriber, of the timestamp, a dot and the body
+const (
+	idHeader        = "X-Webhook-Id"
+	eventHeader     = "X-Webhook-Event"
+	timestampHeader = "X-Webhook-Timestamp"
+	signatureHeader = "X-Webhook-Signature"
+)
+
+const (
+	dispatchInterval = time.Second
+	dispatchBatch    = 50
+	maxRetryDelay    = time.Hour
+)
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// dispatcher posts pending deliveries, attempting failed ones again with
+// exponential backoff until maxAttempts
+type dispatcher struct {
+	store       *store
+	retryDelay  time.Duration
+	maxAttempts int
+}
+
+// sign returns the signature header of a body sent at timestamp
+func sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff returns the delay before the attempt following the given number of
+// failed attempts
+func (d *dispatcher) backoff(attempts int) time.Duration {
+	delay := d.retryDelay
+
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
+
+// post sends a delivery and returns the HTTP status of the response. Any status
+// other than 2xx is an error
+func post(delivery *dueDelivery, now time.Time) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(idHeader, delivery.Id)
+	request.Header.Set(eventHeader, delivery.Event)
+	request.Header.Set(timestampHeader, timestamp)
+	request.Header.Set(signatureHeader, sign(delivery.Secret, timestamp, delivery.Body))
+
+	response, err := httpClient.Do(request)
+
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("%s returned %s", delivery.URL, response.Status)
+	}
+
+	return response.StatusCode, nil
+}
+
+// attempt posts a delivery and records the outcome
+func (d *dispatcher) attempt(delivery *dueDelivery) {
+	now := time.Now()
+	responseCode, postErr := post(delivery, now)
+
+	var nextAttempt time.Time
+
+	if postErr != nil && delivery.Attempts+1 < d.maxAttempts {
+		nextAttempt = now.Add(d.backoff(delivery.Attempts + 1))
+	}
+
+	if postErr != nil {
+		log.Printf("Failed to deliver %s to %s (attempt %d): %v", delivery.Id, delivery.URL, delivery.Attempts+1, postErr)
+	}
+
+	if err := d.store.recordAttempt(delivery.Id, time.Now(), responseCode, postErr, nextAttempt); err != nil {
+		log.Printf("Failed to record attempt of %s: %v", delivery.Id, err)
+	}
+}
+
+// run posts the due deliveries every dispatchInterval until ctx is done.
+// Deliveries of a batch are posted concurrently
+func (d *dispatcher) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		due, err := d.store.dueDeliveries(time.Now(), dispatchBatch)
+
+		if err != nil {
+			log.Printf("Failed to read due deliveries: %v", err)
+		}
+
+		var wg sync.WaitGroup
+
+		for _, delivery := range due {
+			wg.Add(1)
+
+			go func(delivery *dueDelivery) {
+				defer wg.Done()
+				d.attempt(delivery)
+			}(delivery)
+		}
+
+		wg.Wait()
+
+		if len(due) == dispatchBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(dispatchInterval):
+		}
+	}
+}
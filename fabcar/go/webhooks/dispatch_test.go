@@ -0,0 +1,108 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "sha256=60734808e731b08d45bee887cade715d87211348f1bcb975b46c8d2e7fa5dbcd", sign("whsec", "1700000000", []byte(`{"id":"1"}`)))
+	assert.NotEqual(t, sign("whsec", "1700000000", []byte(`{"id":"1"}`)), sign("whsec", "1700000001", []byte(`{"id":"1"}`)), "should sign the timestamp")
+}
+
+func TestBackoff(t *testing.T) {
+	d := &dispatcher{retryDelay: 5 * time.Second}
+
+	assert.Equal(t, 5*time.Second, d.backoff(1))
+	assert.Equal(t, 10*time.Second, d.backoff(2), "should double the delay after each failed attempt")
+	assert.Equal(t, 40*time.Second, d.backoff(4))
+	assert.Equal(t, maxRetryDelay, d.backoff(11), "should cap the delay")
+	assert.Equal(t, maxRetryDelay, d.backoff(1000))
+
+	d = &dispatcher{retryDelay: 2 * time.Hour}
+	assert.Equal(t, maxRetryDelay, d.backoff(1))
+}
+
+func TestAttempt(t *testing.T) {
+	responseCode := http.StatusInternalServerError
+	var request *http.Request
+	var body []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(responseCode)
+	}))
+	defer server.Close()
+
+	s := newTestStore(t)
+	addTestSubscriber(t, s, "all", server.URL)
+
+	event := &client.ChaincodeEvent{BlockNumber: 3, TransactionID: "tx1", EventName: "did.created.CreateDid", Payload: []byte(`{"didNumber":"1"}`)}
+	_, err := s.queue(event, time.Now())
+	require.NoError(t, err)
+
+	d := &dispatcher{store: s, retryDelay: time.Minute, maxAttempts: 2}
+	id := deliveryId("all", event)
+
+	due, err := s.dueDeliveries(time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	d.attempt(due[0])
+
+	require.NotNil(t, request, "should post the delivery")
+	assert.Equal(t, id, request.Header.Get(idHeader))
+	assert.Equal(t, event.EventName, request.Header.Get(eventHeader))
+	assert.Equal(t, "application/json", request.Header.Get("Content-Type"))
+	assert.Equal(t, due[0].Body, body)
+
+	timestamp := request.Header.Get(timestampHeader)
+	_, err = strconv.ParseInt(timestamp, 10, 64)
+	require.NoError(t, err, "should send a Unix timestamp")
+	assert.Equal(t, sign("secret-all", timestamp, body), request.Header.Get(signatureHeader), "should sign the body with the secret of the subscriber")
+
+	delivery, err := s.delivery(id)
+	require.NoError(t, err)
+	assert.Equal(t, statusPending, delivery.Status, "should attempt a failed delivery again")
+	assert.Equal(t, http.StatusInternalServerError, delivery.ResponseCode)
+	assert.Contains(t, delivery.LastError, "returned 500 Internal Server Error")
+
+	due, err = s.dueDeliveries(time.Now().Add(time.Minute), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1, "should attempt the delivery again after the backoff")
+
+	d.attempt(due[0])
+
+	delivery, err = s.delivery(id)
+	require.NoError(t, err)
+	assert.Equal(t, statusFailed, delivery.Status, "should give up after maxAttempts")
+	assert.Equal(t, 2, delivery.Attempts)
+
+	_, err = s.db.Exec(`UPDATE deliveries SET status = $1, attempts = 0, next_attempt = 0 WHERE id = $2`, statusPending, id)
+	require.NoError(t, err)
+	responseCode = http.StatusNoContent
+
+	due, err = s.dueDeliveries(time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, due, 1)
+
+	d.attempt(due[0])
+
+	delivery, err = s.delivery(id)
+	require.NoError(t, err)
+	assert.Equal(t, statusDelivered, delivery.Status, "should record a 2xx response as delivered")
+	assert.Equal(t, http.StatusNoContent, delivery.ResponseCode)
+}
@@ -0,0 +1,155 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+// Command webhooks notifies external systems of the did lifecycle events of the
+// fabcar chaincode. It listens to the did.<operation>.<method> and dids.*
+// chaincode events and queues a delivery for every registered subscriber whose
+// event name prefixes match, then POSTs each delivery to the subscriber URL as a
+// JSON notification signed with the subscriber secret. Failed deliveries are
+// attempted again with exponential backoff from WEBHOOKS_RETRY_DELAY (5s by
+// default) until WEBHOOKS_MAX_ATTEMPTS (8 by default) have failed. Queued
+// deliveries and the checkpoint of the last queued event are saved together, so
+// a restarted dispatcher neither loses nor repeats notifications.
+//
+//	POST   /subscribers                  register a webhook {url, events}, returning its secret
+//	GET    /subscribers                  list the subscribers
+//	GET    /subscribers/{id}             read a subscriber
+//	DELETE /subscribers/{id}             remove a subscriber and its deliveries
+//	GET    /subscribers/{id}/deliveries  list deliveries, newest first, given status and limit
+//	GET    /deliveries/{id}              read the status of a delivery
+//
+// Notifications carry their delivery id in X-Webhook-Id, and in
+// X-Webhook-Signature the hex HMAC-SHA256 of X-Webhook-Timestamp, a dot and the
+// body. The API is served on WEBHOOKS_ADDRESS (:8082 by default) and the
+// database is selected with DATABASE_DRIVER (sqlite or postgres) and
+// DATABASE_URL.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/hyperledger/fabric-samples/fabcar/go/connection"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+func envOrDefault(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// isLifecycleEvent reports whether the event with given name is emitted when
+// dids change
+func isLifecycleEvent(name string) bool {
+	return strings.HasPrefix(name, "did.") || strings.HasPrefix(name, "dids.")
+}
+
+// listen queues the lifecycle events until the event stream ends. On its first
+// run the dispatcher starts with the next committed block
+func listen(ctx context.Context, conn *connection.Connection, s *store) error {
+	checkpointer, resumed, err := s.checkpoint()
+
+	if err != nil {
+		return err
+	}
+
+	options := []client.ChaincodeEventsOption{}
+
+	if resumed {
+		options = append(options, client.WithCheckpoint(checkpointer))
+		log.Printf("Resuming from block %d", checkpointer.BlockNumber())
+	}
+
+	events, err := conn.Network.ChaincodeEvents(ctx, conn.ChaincodeName, options...)
+
+	if err != nil {
+		return fmt.Errorf("failed to start chaincode event listening: %w", err)
+	}
+
+	for event := range events {
+		if !isLifecycleEvent(event.EventName) {
+			continue
+		}
+
+		queued, err := s.queue(event, time.Now())
+
+		if err != nil {
+			return fmt.Errorf("failed to queue event of transaction %s: %w", event.TransactionID, err)
+		}
+
+		if queued > 0 {
+			log.Printf("Queued %s from block %d transaction %s for %d subscribers", event.EventName, event.BlockNumber, event.TransactionID, queued)
+		}
+	}
+
+	return ctx.Err()
+}
+
+func main() {
+	config := connection.ConfigFromEnv()
+
+	retryDelay, err := time.ParseDuration(envOrDefault("WEBHOOKS_RETRY_DELAY", "5s"))
+
+	if err != nil || retryDelay <= 0 {
+		log.Fatalf("WEBHOOKS_RETRY_DELAY must be a positive duration")
+	}
+
+	maxAttempts, err := strconv.Atoi(envOrDefault("WEBHOOKS_MAX_ATTEMPTS", "8"))
+
+	if err != nil || maxAttempts < 1 {
+		log.Fatalf("WEBHOOKS_MAX_ATTEMPTS must be a positive number")
+	}
+
+	s, err := openStore(envOrDefault("DATABASE_DRIVER", "sqlite"), envOrDefault("DATABASE_URL", "webhooks.db"), config.ChannelName+"/"+config.ChaincodeName)
+
+	if err != nil {
+		log.Fatalf("Failed to open store: %v", err)
+	}
+	defer s.Close()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	address := envOrDefault("WEBHOOKS_ADDRESS", ":8082")
+	api := &server{store: s}
+
+	go func() {
+		log.Printf("Webhook API listening on %s", address)
+		log.Fatal(http.ListenAndServe(address, api.routes()))
+	}()
+
+	go (&dispatcher{store: s, retryDelay: retryDelay, maxAttempts: maxAttempts}).run(ctx)
+
+	for ctx.Err() == nil {
+		conn, err := connection.Connect(config)
+
+		if err == nil {
+			err = listen(ctx, conn, s)
+			conn.Close()
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		log.Printf("Event listening stopped, reconnecting in 5s: %v", err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
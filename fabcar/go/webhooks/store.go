@@ -0,0 +1,487 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+)
+
+// Statuses of a delivery
+const (
+	statusPending   = "pending"
+	statusDelivered = "delivered"
+	statusFailed    = "failed"
+)
+
+// schema creates the tables of the dispatcher. The SQL is shared by SQLite and
+// Postgres. Times are Unix milliseconds, 0 when unset
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS subscribers (
+		id      TEXT PRIMARY KEY,
+		url     TEXT NOT NULL,
+		secret  TEXT NOT NULL,
+		events  TEXT NOT NULL,
+		created BIGINT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS deliveries (
+		id            TEXT PRIMARY KEY,
+		subscriber_id TEXT NOT NULL,
+		event_name    TEXT NOT NULL,
+		block_number  BIGINT NOT NULL,
+		tx_id         TEXT NOT NULL,
+		body          TEXT NOT NULL,
+		status        TEXT NOT NULL,
+		attempts      INTEGER NOT NULL DEFAULT 0,
+		next_attempt  BIGINT NOT NULL,
+		last_error    TEXT NOT NULL DEFAULT '',
+		response_code INTEGER NOT NULL DEFAULT 0,
+		created       BIGINT NOT NULL,
+		delivered     BIGINT NOT NULL DEFAULT 0
+	)`,
+	`CREATE INDEX IF NOT EXISTS deliveries_due ON deliveries (status, next_attempt)`,
+	`CREATE INDEX IF NOT EXISTS deliveries_subscriber ON deliveries (subscriber_id, created)`,
+	`CREATE TABLE IF NOT EXISTS checkpoint (
+		name         TEXT PRIMARY KEY,
+		block_number BIGINT NOT NULL,
+		tx_id        TEXT NOT NULL
+	)`,
+}
+
+const insertDelivery = `INSERT INTO deliveries (id, subscriber_id, event_name, block_number, tx_id, body, status, next_attempt, created)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	ON CONFLICT (id) DO NOTHING`
+
+const upsertCheckpoint = `INSERT INTO checkpoint (name, block_number, tx_id) VALUES ($1, $2, $3)
+	ON CONFLICT (name) DO UPDATE SET block_number = excluded.block_number, tx_id = excluded.tx_id`
+
+const deliveryColumns = `id, subscriber_id, event_name, block_number, tx_id, status, attempts, next_attempt, last_error, response_code, created, delivered`
+
+// subscriber is a registered webhook. Events are the prefixes of the event
+// names it is notified of, every did lifecycle event when empty
+type subscriber struct {
+	Id      string   `json:"id"`
+	URL     string   `json:"url"`
+	Events  []string `json:"events"`
+	Secret  string   `json:"secret,omitempty"`
+	Created string   `json:"created"`
+}
+
+// wants reports whether the subscriber is notified of the event with given name
+func (s *subscriber) wants(name string) bool {
+	if len(s.Events) == 0 {
+		return true
+	}
+
+	for _, prefix := range s.Events {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// delivery is the status of the notification of an event to a subscriber
+type delivery struct {
+	Id            string `json:"id"`
+	SubscriberId  string `json:"subscriberId"`
+	Event         string `json:"event"`
+	BlockNumber   uint64 `json:"blockNumber"`
+	TransactionId string `json:"transactionId"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	NextAttempt   string `json:"nextAttempt,omitempty"`
+	LastError     string `json:"lastError,omitempty"`
+	ResponseCode  int    `json:"responseCode,omitempty"`
+	Created       string `json:"created"`
+	Delivered     string `json:"delivered,omitempty"`
+}
+
+// notification is the JSON body posted to a subscriber. Id is the id of the
+// delivery, the same for every attempt
+type notification struct {
+	Id            string          `json:"id"`
+	Event         string          `json:"event"`
+	BlockNumber   uint64          `json:"blockNumber"`
+	TransactionId string          `json:"transactionId"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// dueDelivery is a pending delivery with the webhook it is posted to
+type dueDelivery struct {
+	Id       string
+	Attempts int
+	Event    string
+	Body     []byte
+	URL      string
+	Secret   string
+}
+
+func millis(t time.Time) int64 {
+	return t.UnixMilli()
+}
+
+// formatMillis formats a time of the store as RFC 3339, empty when unset
+func formatMillis(ms int64) string {
+	if ms == 0 {
+		return ""
+	}
+
+	return time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)
+}
+
+// deliveryId derives the id of the delivery of the event of a transaction to a
+// subscriber, so that queuing an event again after a restart adds nothing
+func deliveryId(subscriberId string, event *client.ChaincodeEvent) string {
+	hash := sha256.Sum256([]byte(subscriberId + "/" + event.TransactionID + "/" + event.EventName))
+
+	return hex.EncodeToString(hash[:16])
+}
+
+// store keeps the subscribers, their deliveries and the checkpoint of the last
+// queued event
+type store struct {
+	db   *sql.DB
+	name string
+}
+
+func openStore(driver string, dataSource string, name string) (*store, error) {
+	db, err := sql.Open(driver, dataSource)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s database: %w", driver, err)
+	}
+
+	// SQLite allows a single writer, so the dispatcher and the listener share one
+	// connection rather than failing with a busy database
+	if driver == "sqlite" {
+		db.SetMaxOpenConns(1)
+	}
+
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to create schema: %w", err)
+		}
+	}
+
+	return &store{db: db, name: name}, nil
+}
+
+func (s *store) Close() error {
+	return s.db.Close()
+}
+
+// checkpoint returns the position after the last queued event, and whether any
+// event has been queued yet
+func (s *store) checkpoint() (*client.InMemoryCheckpointer, bool, error) {
+	checkpointer := new(client.InMemoryCheckpointer)
+
+	var blockNumber uint64
+	var txId string
+
+	err := s.db.QueryRow(`SELECT block_number, tx_id FROM checkpoint WHERE name = $1`, s.name).Scan(&blockNumber, &txId)
+
+	if err == sql.ErrNoRows {
+		return checkpointer, false, nil
+	}
+
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	checkpointer.CheckpointTransaction(blockNumber, txId)
+
+	return checkpointer, true, nil
+}
+
+func (s *store) putSubscriber(sub *subscriber, created time.Time) error {
+	_, err := s.db.Exec(`INSERT INTO subscribers (id, url, secret, events, created) VALUES ($1, $2, $3, $4, $5)`,
+		sub.Id, sub.URL, sub.Secret, strings.Join(sub.Events, ","), millis(created))
+
+	return err
+}
+
+// subscribers returns every subscriber, without their secrets unless withSecrets
+func (s *store) subscribers(withSecrets bool) ([]*subscriber, error) {
+	rows, err := s.db.Query(`SELECT id, url, secret, events, created FROM subscribers ORDER BY created, id`)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscribers := []*subscriber{}
+
+	for rows.Next() {
+		sub, err := scanSubscriber(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !withSecrets {
+			sub.Secret = ""
+		}
+
+		subscribers = append(subscribers, sub)
+	}
+
+	return subscribers, rows.Err()
+}
+
+// subscriber returns the subscriber with given id without its secret, or nil if
+// it is not registered
+func (s *store) subscriber(id string) (*subscriber, error) {
+	sub, err := scanSubscriber(s.db.QueryRow(`SELECT id, url, secret, events, created FROM subscribers WHERE id = $1`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	sub.Secret = ""
+
+	return sub, nil
+}
+
+func scanSubscriber(row interface{ Scan(...interface{}) error }) (*subscriber, error) {
+	sub := new(subscriber)
+	var events string
+	var created int64
+
+	if err := row.Scan(&sub.Id, &sub.URL, &sub.Secret, &events, &created); err != nil {
+		return nil, err
+	}
+
+	sub.Events = []string{}
+
+	if events != "" {
+		sub.Events = strings.Split(events, ",")
+	}
+
+	sub.Created = formatMillis(created)
+
+	return sub, nil
+}
+
+// removeSubscriber deletes a subscriber and its deliveries, and reports whether
+// it was registered
+func (s *store) removeSubscriber(id string) (bool, error) {
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`DELETE FROM subscribers WHERE id = $1`, id)
+
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM deliveries WHERE subscriber_id = $1`, id); err != nil {
+		return false, err
+	}
+
+	removed, err := result.RowsAffected()
+
+	if err != nil {
+		return false, err
+	}
+
+	return removed > 0, tx.Commit()
+}
+
+// queue adds a delivery of event for every subscriber that wants it and
+// advances the checkpoint in a single database transaction. Events whose payload
+// is not JSON are skipped
+func (s *store) queue(event *client.ChaincodeEvent, now time.Time) (int, error) {
+	subscribers, err := s.subscribers(true)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to read subscribers: %w", err)
+	}
+
+	tx, err := s.db.Begin()
+
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	queued := 0
+
+	if !json.Valid(event.Payload) {
+		log.Printf("Skipping %s event of transaction %s with invalid payload", event.EventName, event.TransactionID)
+		subscribers = nil
+	}
+
+	for _, sub := range subscribers {
+		if !sub.wants(event.EventName) {
+			continue
+		}
+
+		id := deliveryId(sub.Id, event)
+		body, err := json.Marshal(notification{
+			Id:            id,
+			Event:         event.EventName,
+			BlockNumber:   event.BlockNumber,
+			TransactionId: event.TransactionID,
+			Payload:       event.Payload,
+		})
+
+		if err != nil {
+			return 0, err
+		}
+
+		result, err := tx.Exec(insertDelivery, id, sub.Id, event.EventName, event.BlockNumber, event.TransactionID, string(body), statusPending, millis(now), millis(now))
+
+		if err != nil {
+			return 0, err
+		}
+
+		inserted, err := result.RowsAffected()
+
+		if err != nil {
+			return 0, err
+		}
+
+		queued += int(inserted)
+	}
+
+	if _, err := tx.Exec(upsertCheckpoint, s.name, event.BlockNumber, event.TransactionID); err != nil {
+		return 0, err
+	}
+
+	return queued, tx.Commit()
+}
+
+// dueDeliveries returns at most limit pending deliveries whose next attempt is
+// due, oldest first
+func (s *store) dueDeliveries(now time.Time, limit int) ([]*dueDelivery, error) {
+	rows, err := s.db.Query(`SELECT d.id, d.attempts, d.event_name, d.body, s.url, s.secret
+		FROM deliveries d JOIN subscribers s ON s.id = d.subscriber_id
+		WHERE d.status = $1 AND d.next_attempt <= $2
+		ORDER BY d.next_attempt, d.created LIMIT $3`, statusPending, millis(now), limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	due := []*dueDelivery{}
+
+	for rows.Next() {
+		d := new(dueDelivery)
+		var body string
+
+		if err := rows.Scan(&d.Id, &d.Attempts, &d.Event, &body, &d.URL, &d.Secret); err != nil {
+			return nil, err
+		}
+
+		d.Body = []byte(body)
+		due = append(due, d)
+	}
+
+	return due, rows.Err()
+}
+
+// recordAttempt records the outcome of an attempt. A delivery that failed is
+// attempted again at nextAttempt, or marked failed when it is zero
+func (s *store) recordAttempt(id string, now time.Time, responseCode int, attemptErr error, nextAttempt time.Time) error {
+	if attemptErr == nil {
+		_, err := s.db.Exec(`UPDATE deliveries SET status = $1, attempts = attempts + 1, response_code = $2, last_error = '', delivered = $3 WHERE id = $4`,
+			statusDelivered, responseCode, millis(now), id)
+
+		return err
+	}
+
+	status := statusPending
+	next := int64(0)
+
+	if nextAttempt.IsZero() {
+		status = statusFailed
+	} else {
+		next = millis(nextAttempt)
+	}
+
+	_, err := s.db.Exec(`UPDATE deliveries SET status = $1, attempts = attempts + 1, response_code = $2, last_error = $3, next_attempt = $4 WHERE id = $5`,
+		status, responseCode, attemptErr.Error(), next, id)
+
+	return err
+}
+
+// deliveries returns at most limit deliveries of a subscriber with given status,
+// or of any status when empty, newest first
+func (s *store) deliveries(subscriberId string, status string, limit int) ([]*delivery, error) {
+	rows, err := s.db.Query(`SELECT `+deliveryColumns+` FROM deliveries
+		WHERE subscriber_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created DESC, id LIMIT $3`, subscriberId, status, limit)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*delivery{}
+
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// delivery returns the delivery with given id, or nil if it does not exist
+func (s *store) delivery(id string) (*delivery, error) {
+	d, err := scanDelivery(s.db.QueryRow(`SELECT `+deliveryColumns+` FROM deliveries WHERE id = $1`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return d, err
+}
+
+func scanDelivery(row interface{ Scan(...interface{}) error }) (*delivery, error) {
+	d := new(delivery)
+	var nextAttempt, created, delivered int64
+
+	err := row.Scan(&d.Id, &d.SubscriberId, &d.Event, &d.BlockNumber, &d.TransactionId, &d.Status, &d.Attempts,
+		&nextAttempt, &d.LastError, &d.ResponseCode, &created, &delivered)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Status == statusPending {
+		d.NextAttempt = formatMillis(nextAttempt)
+	}
+
+	d.Created = formatMillis(created)
+	d.Delivered = formatMillis(delivered)
+
+	return d, nil
+}
@@ -0,0 +1,156 @@
+/*
+ * SPDX-License-Identifier: Apache-2.0
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestStore(t *testing.T) *store {
+	s, err := openStore("sqlite", filepath.Join(t.TempDir(), "webhooks.db"), "webhooks")
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+func addTestSubscriber(t *testing.T, s *store, id string, url string, events ...string) {
+	require.NoError(t, s.putSubscriber(&subscriber{Id: id, URL: url, Secret: "secret-" + id, Events: events}, time.Now()))
+}
+
+func TestSubscriberWants(t *testing.T) {
+	all := &subscriber{}
+	assert.True(t, all.wants("did.created.CreateDid"), "should notify a subscriber without prefixes of every event")
+
+	sub := &subscriber{Events: []string{"did.deactivated.", "dids."}}
+	assert.True(t, sub.wants("did.deactivated.DeactivateDid"))
+	assert.True(t, sub.wants("dids.purged"))
+	assert.False(t, sub.wants("did.created.CreateDid"), "should only notify a subscriber of the events matching its prefixes")
+}
+
+func TestQueue(t *testing.T) {
+	s := newTestStore(t)
+	addTestSubscriber(t, s, "all", "https://a.example.com/hook")
+	addTestSubscriber(t, s, "deactivations", "https://b.example.com/hook", "did.deactivated.")
+
+	_, found, err := s.checkpoint()
+	require.NoError(t, err)
+	assert.False(t, found, "should not have a checkpoint before queuing")
+
+	now := time.Now()
+	created := &client.ChaincodeEvent{BlockNumber: 3, TransactionID: "tx1", EventName: "did.created.CreateDid", Payload: []byte(`{"didNumber":"1"}`)}
+
+	queued, err := s.queue(created, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, queued, "should only queue the event for the subscribers that want it")
+
+	queued, err = s.queue(created, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, queued, "should not queue an event again")
+
+	checkpointer, found, err := s.checkpoint()
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, uint64(3), checkpointer.BlockNumber(), "should advance the checkpoint to the queued event")
+	assert.Equal(t, "tx1", checkpointer.TransactionID())
+
+	deactivated := &client.ChaincodeEvent{BlockNumber: 4, TransactionID: "tx2", EventName: "did.deactivated.DeactivateDid", Payload: []byte(`{"didNumber":"1"}`)}
+
+	queued, err = s.queue(deactivated, now)
+	require.NoError(t, err)
+	assert.Equal(t, 2, queued)
+
+	invalid := &client.ChaincodeEvent{BlockNumber: 5, TransactionID: "tx3", EventName: "did.updated.UpdateDid", Payload: []byte(`not json`)}
+
+	queued, err = s.queue(invalid, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, queued, "should skip an event with an invalid payload")
+
+	checkpointer, _, err = s.checkpoint()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), checkpointer.BlockNumber(), "should advance the checkpoint past a skipped event")
+	assert.Equal(t, "tx3", checkpointer.TransactionID())
+
+	due, err := s.dueDeliveries(now, 10)
+	require.NoError(t, err)
+	require.Len(t, due, 3)
+
+	var first *dueDelivery
+
+	for _, d := range due {
+		if d.Id == deliveryId("all", created) {
+			first = d
+		}
+	}
+
+	require.NotNil(t, first, "should derive the delivery id from the subscriber and the event")
+	assert.Equal(t, "https://a.example.com/hook", first.URL)
+	assert.Equal(t, "secret-all", first.Secret)
+	assert.Equal(t, created.EventName, first.Event)
+
+	body := new(notification)
+	require.NoError(t, json.Unmarshal(first.Body, body))
+	assert.Equal(t, notification{Id: first.Id, Event: created.EventName, BlockNumber: 3, TransactionId: "tx1", Payload: json.RawMessage(`{"didNumber":"1"}`)}, *body)
+
+	later, err := s.dueDeliveries(now.Add(-time.Minute), 10)
+	require.NoError(t, err)
+	assert.Empty(t, later, "should not return deliveries that are not due yet")
+}
+
+func TestRecordAttempt(t *testing.T) {
+	s := newTestStore(t)
+	addTestSubscriber(t, s, "all", "https://a.example.com/hook")
+
+	now := time.Now()
+	event := &client.ChaincodeEvent{BlockNumber: 3, TransactionID: "tx1", EventName: "did.created.CreateDid", Payload: []byte(`{}`)}
+	_, err := s.queue(event, now)
+	require.NoError(t, err)
+
+	id := deliveryId("all", event)
+	require.NoError(t, s.recordAttempt(id, now, 503, assert.AnError, now.Add(time.Minute)))
+
+	d, err := s.delivery(id)
+	require.NoError(t, err)
+	assert.Equal(t, statusPending, d.Status, "should attempt a failed delivery again")
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, 503, d.ResponseCode)
+	assert.Equal(t, assert.AnError.Error(), d.LastError)
+	assert.Equal(t, formatMillis(millis(now.Add(time.Minute))), d.NextAttempt)
+
+	due, err := s.dueDeliveries(now, 10)
+	require.NoError(t, err)
+	assert.Empty(t, due, "should not return a delivery before its next attempt")
+
+	require.NoError(t, s.recordAttempt(id, now, 200, nil, time.Time{}))
+
+	d, err = s.delivery(id)
+	require.NoError(t, err)
+	assert.Equal(t, statusDelivered, d.Status)
+	assert.Equal(t, 2, d.Attempts)
+	assert.Empty(t, d.LastError)
+	assert.Empty(t, d.NextAttempt)
+	assert.NotEmpty(t, d.Delivered)
+
+	require.NoError(t, s.recordAttempt(id, now, 0, assert.AnError, time.Time{}))
+
+	d, err = s.delivery(id)
+	require.NoError(t, err)
+	assert.Equal(t, statusFailed, d.Status, "should mark a delivery without next attempt failed")
+
+	removed, err := s.removeSubscriber("all")
+	require.NoError(t, err)
+	assert.True(t, removed)
+
+	d, err = s.delivery(id)
+	require.NoError(t, err)
+	assert.Nil(t, d, "should remove the deliveries of a removed subscriber")
+}
@@ -5,6 +5,7 @@
 'use strict';
 
 const { Gateway, Wallets } = require('fabric-network');
+const crypto = require('crypto');
 const fs = require('fs');
 const path = require('path');
 
@@ -37,10 +38,19 @@ async function main() {
         // Get the contract from the network.
         const contract = network.getContract('fabcar');
 
+        // Generate the authentication key of the new did. The did is derived from
+        // the public key, so the private key is needed to update it later.
+        const { publicKey, privateKey } = crypto.generateKeyPairSync('rsa', {
+            modulusLength: 2048,
+            publicKeyEncoding: { type: 'spki', format: 'pem' },
+            privateKeyEncoding: { type: 'pkcs8', format: 'pem' },
+        });
+        fs.writeFileSync(path.join(process.cwd(), 'did-key.pem'), privateKey, { mode: 0o600 });
+
         // Submit the specified transaction.
-        await contract.submitTransaction('createDid', 'DID2', 'did:example:new', 'did:example:new#keys-1', 'RsaVerificationKey2018', 'did:example:new',
-        '-----BEGIN PUBLIC KEY...END PUBLIC KEY-----\r\n', 'did:example:new#vcs', 'VerifiableCredentialService', 'https://exampleNew.com/vc/');
-        console.log('Transaction has been submitted');
+        const did = await contract.submitTransaction('createDid', '#keys-1', 'RsaVerificationKey2018', '',
+        publicKey, '#vcs', 'VerifiableCredentialService', 'https://exampleNew.com/vc/', '');
+        console.log(`Transaction has been submitted, created ${did.toString()}`);
 
         // Disconnect from the gateway.
         await gateway.disconnect();
@@ -39,7 +39,7 @@ async function main() {
         const contract = network.getContract('fabcar');
 
         // Evaluate the specified transaction.
-        const result = await contract.evaluateTransaction('queryAllDids');
+        const result = await contract.evaluateTransaction('queryAllDids', '', '');
         console.log(`Transaction has been evaluated, result is: ${result.toString()}`);
  
     } catch (error) {
@@ -103,4 +103,55 @@ Java:
     - Submit a transaction to change the owner of this car
     - Evaluate a transaction (query) to return the updated details of this car
 
+Go:
+
+  Start by changing into the "go" directory:
+    cd go
+
+  The Go applications connect through the Fabric Gateway as User1 of Org1 using the
+  test network connection profile. Run the Universal Resolver driver, which serves
+  GET /1.0/identifiers/{did} on port 8080, as follows:
+    go run ./resolver
+
+  Run the REST API, which serves the did registry on port 3000, as follows:
+    go run ./rest
+
+  Run the reference client, which submits and evaluates every transaction of the
+  did registry in turn, as follows:
+    go run ./client
+
+  Run the event listener, which keeps an off-chain index of the dids in a SQLite
+  database (set DATABASE_DRIVER=postgres and DATABASE_URL to use Postgres), as follows:
+    go run ./listener
+
+  Run the replicator, which mirrors the dids into CouchDB for full-text search
+  (set REPLICATOR_SINK=elasticsearch and REPLICATOR_URL to use Elasticsearch), as follows:
+    REPLICATOR_ADDRESS=:8081 go run ./replicator
+
+  Import dids in bulk from a directory of .json files or an NDJSON file, as follows:
+    go run ./importer -batch-size 50 dids.ndjson
+
+  Export a snapshot of every did for backup or migration, as follows:
+    go run ./exporter -o dids-snapshot.json
+
+  Enroll an identity registered with the Fabric CA into a new MSP directory, create
+  its did from the issued certificate and bind the enrollment id to it, as follows:
+    go run ./enroller -id user2 -secret user2pw -msp-dir user2/msp
+
+  Run the revoker, which deactivates the dids bound with the certificates in the
+  revocation list of the CA (set REVOKER_CA_CERT to check its signature), as follows:
+    REVOKER_CRL=msp/crls/crl.pem go run ./revoker
+
+  Rebuild the index of the event listener from the blocks of the channel, after
+  downtime or a change of the index schema, with the listener stopped, as follows:
+    go run ./replayer -reset
+
+  Run the cache, which serves GET /identifiers/{did} on port 8081 from memory and a
+  SQLite store checked against the hash of each did on the ledger, as follows:
+    CACHE_SIZE=1000 go run ./cache
+
+  Run the webhook dispatcher, which serves the subscribers API on port 8082 and posts
+  signed notifications of the did lifecycle events to each subscriber, as follows:
+    go run ./webhooks
+
 EOF